@@ -0,0 +1,18 @@
+// Package format 节点端与控制端共用的展示格式
+package format
+
+import "fmt"
+
+// Bytes 格式化字节数，如 "1.50 GB"
+func Bytes(bytes uint64) string {
+	const unit = 1024
+	if bytes < unit {
+		return fmt.Sprintf("%d B", bytes)
+	}
+	div, exp := uint64(unit), 0
+	for n := bytes / unit; n >= unit; n /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.2f %cB", float64(bytes)/float64(div), "KMGTPE"[exp])
+}
@@ -0,0 +1,94 @@
+// Package spec 声明式的容器描述，节点端按其创建容器，控制端按其部署栈和下发期望状态
+//
+// 两端通过 Hash 判断容器是否与声明一致，因此类型和哈希算法只在这里定义。
+package spec
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+)
+
+// ContainerSpec 声明式的容器描述
+type ContainerSpec struct {
+	Name       string            `json:"name"`
+	Image      string            `json:"image"`
+	PullPolicy string            `json:"pull_policy,omitempty"`
+	Command    []string          `json:"command,omitempty"`
+	Entrypoint []string          `json:"entrypoint,omitempty"`
+	WorkingDir string            `json:"working_dir,omitempty"`
+	User       string            `json:"user,omitempty"`
+	Hostname   string            `json:"hostname,omitempty"`
+	Env        map[string]string `json:"env,omitempty"`
+	Labels     map[string]string `json:"labels,omitempty"`
+	Mounts     []MountSpec       `json:"mounts,omitempty"`
+	Ports      []PortSpec        `json:"ports,omitempty"`
+	// NetworkMode bridge（默认）、host、none 或自定义网络名
+	NetworkMode   string            `json:"network_mode,omitempty"`
+	Resources     ResourceSpec      `json:"resources,omitempty"`
+	RestartPolicy RestartPolicySpec `json:"restart_policy,omitempty"`
+	Privileged    bool              `json:"privileged,omitempty"`
+	CapAdd        []string          `json:"cap_add,omitempty"`
+	Init          bool              `json:"init,omitempty"`
+	// CgroupParent 容器所在的父 cgroup
+	CgroupParent string `json:"cgroup_parent,omitempty"`
+	// CgroupnsMode host 或 private，为空时使用 Docker 的默认值
+	CgroupnsMode string            `json:"cgroupns_mode,omitempty"`
+	Sysctls      map[string]string `json:"sysctls,omitempty"`
+	// Healthcheck 为空时沿用镜像中定义的健康检查
+	Healthcheck *HealthcheckSpec `json:"healthcheck,omitempty"`
+}
+
+// HealthcheckSpec 健康检查，时间取值如 10s、1m
+type HealthcheckSpec struct {
+	// Test 如 ["CMD", "healthcheck.sh"] 或 ["CMD-SHELL", "..."]，["NONE"] 禁用镜像的健康检查
+	Test        []string `json:"test"`
+	Interval    string   `json:"interval,omitempty"`
+	Timeout     string   `json:"timeout,omitempty"`
+	StartPeriod string   `json:"start_period,omitempty"`
+	Retries     int      `json:"retries,omitempty"`
+}
+
+// MountSpec 挂载
+type MountSpec struct {
+	// Type bind（默认）、volume 或 tmpfs
+	Type     string `json:"type,omitempty"`
+	Source   string `json:"source,omitempty"`
+	Target   string `json:"target"`
+	ReadOnly bool   `json:"read_only,omitempty"`
+}
+
+// PortSpec 端口映射
+type PortSpec struct {
+	HostIP        string `json:"host_ip,omitempty"`
+	HostPort      int    `json:"host_port,omitempty"`
+	ContainerPort int    `json:"container_port"`
+	// Protocol tcp（默认）或 udp
+	Protocol string `json:"protocol,omitempty"`
+}
+
+// ResourceSpec 资源限制，内存类取值支持 512m、2g 等写法
+type ResourceSpec struct {
+	CPUs       float64 `json:"cpus,omitempty"`
+	CpusetCpus string  `json:"cpuset_cpus,omitempty"`
+	CpusetMems string  `json:"cpuset_mems,omitempty"`
+	Memory     string  `json:"memory,omitempty"`
+	MemorySwap string  `json:"memory_swap,omitempty"`
+	ShmSize    string  `json:"shm_size,omitempty"`
+	PidsLimit  int64   `json:"pids_limit,omitempty"`
+}
+
+// RestartPolicySpec 重启策略
+type RestartPolicySpec struct {
+	// Name no、always、unless-stopped 或 on-failure
+	Name       string `json:"name,omitempty"`
+	MaxRetries int    `json:"max_retries,omitempty"`
+}
+
+// Hash 声明的哈希，节点端将其写入容器标签，两端比较即可判断容器是否需要重建
+func (s *ContainerSpec) Hash() string {
+	// map 按键排序序列化，相同的声明得到相同的哈希
+	data, _ := json.Marshal(s)
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:16])
+}
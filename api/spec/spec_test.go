@@ -0,0 +1,35 @@
+package spec
+
+import "testing"
+
+func TestHash(t *testing.T) {
+	a := &ContainerSpec{
+		Name:   "contest-judgehost-c2",
+		Image:  "domjudge/judgehost:8.3",
+		Env:    map[string]string{"DAEMON_ID": "2", "TZ": "UTC"},
+		Mounts: []MountSpec{{Source: "/sys/fs/cgroup", Target: "/sys/fs/cgroup"}},
+	}
+	b := &ContainerSpec{
+		Name:   "contest-judgehost-c2",
+		Image:  "domjudge/judgehost:8.3",
+		Env:    map[string]string{"TZ": "UTC", "DAEMON_ID": "2"},
+		Mounts: []MountSpec{{Source: "/sys/fs/cgroup", Target: "/sys/fs/cgroup"}},
+	}
+	if a.Hash() != b.Hash() {
+		t.Errorf("hash depends on map order: %s != %s", a.Hash(), b.Hash())
+	}
+	if len(a.Hash()) != 32 {
+		t.Errorf("hash %q is not 32 hex characters", a.Hash())
+	}
+
+	// 已部署容器的标签中保存着旧的哈希，算法或序列化变化会导致全部容器被重建
+	const want = "471f18ec99ef5bb275180586caa1d2fd"
+	if got := a.Hash(); got != want {
+		t.Errorf("Hash() = %s, want %s", got, want)
+	}
+
+	b.Env["DAEMON_ID"] = "3"
+	if a.Hash() == b.Hash() {
+		t.Error("different specs have the same hash")
+	}
+}
@@ -0,0 +1,152 @@
+// Package statusdelta 节点状态增量上报中节点与控制端共用的约定
+//
+// 状态增量采用 JSON Merge Patch（RFC 7386）语义，数组只能整体替换。CPU 核心、网卡、容器等数组
+// 中的元素每次采样都有字段变化，整体替换会使每个增量都包含完整的数组，因此两端在比较和保存快照前
+// 按 KeyedArrays 将它们转换为以元素键为键的对象，补丁只包含变化的元素及其变化的字段。
+package statusdelta
+
+import (
+	"sort"
+	"strconv"
+)
+
+// KeyedArray 一个按元素键比较的数组
+type KeyedArray struct {
+	// Path 数组在状态文档中的位置，以对象键表示
+	Path []string
+	// Key 元素中作为键的字段，取值为字符串或数字
+	Key string
+	// SortBy 还原为数组时的排序字段，为空时按键排序
+	SortBy string
+}
+
+// KeyedArrays 状态文档中按元素键比较的数组
+var KeyedArrays = []KeyedArray{
+	{Path: []string{"system_resources", "cpu", "cores"}, Key: "id"},
+	{Path: []string{"system_resources", "network", "interfaces"}, Key: "name"},
+	{Path: []string{"system_resources", "disks"}, Key: "path"},
+	{Path: []string{"system_resources", "disk_io"}, Key: "device"},
+	{Path: []string{"docker", "containers"}, Key: "id", SortBy: "name"},
+	{Path: []string{"probes"}, Key: "name"},
+}
+
+// KeyArrays 将文档中 KeyedArrays 所列的数组原地转换为以元素键为键的对象
+//
+// 缺少键的元素被丢弃；已经是对象或不存在的路径保持原样。
+func KeyArrays(doc map[string]interface{}) {
+	for _, ka := range KeyedArrays {
+		parent := lookupParent(doc, ka.Path)
+		if parent == nil {
+			continue
+		}
+
+		last := ka.Path[len(ka.Path)-1]
+		list, ok := parent[last].([]interface{})
+		if !ok {
+			continue
+		}
+		keyed := make(map[string]interface{}, len(list))
+		for _, item := range list {
+			obj, ok := item.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			if key, ok := elementKey(obj[ka.Key]); ok {
+				keyed[key] = obj
+			}
+		}
+		parent[last] = keyed
+	}
+}
+
+// UnkeyArrays 返回将 KeyedArrays 所列对象还原为数组后的文档，不修改原文档
+//
+// 只复制路径上的对象；仍为数组的路径（如旧版本节点整体替换的数组）保持原样。
+func UnkeyArrays(doc map[string]interface{}) map[string]interface{} {
+	out := doc
+	for _, ka := range KeyedArrays {
+		parent := lookupParent(out, ka.Path)
+		if parent == nil {
+			continue
+		}
+		last := ka.Path[len(ka.Path)-1]
+		keyed, ok := parent[last].(map[string]interface{})
+		if !ok {
+			continue
+		}
+
+		out = copyMap(out)
+		parent = out
+		for _, key := range ka.Path[:len(ka.Path)-1] {
+			child := copyMap(parent[key].(map[string]interface{}))
+			parent[key] = child
+			parent = child
+		}
+
+		keys := make([]string, 0, len(keyed))
+		for key := range keyed {
+			keys = append(keys, key)
+		}
+		sort.Slice(keys, func(i, j int) bool {
+			return ka.less(keyed, keys[i], keys[j])
+		})
+		list := make([]interface{}, 0, len(keys))
+		for _, key := range keys {
+			list = append(list, keyed[key])
+		}
+		parent[last] = list
+	}
+	return out
+}
+
+// less 比较两个元素的顺序：先按 SortBy 字段，再按键，数字键按数值比较
+func (ka KeyedArray) less(keyed map[string]interface{}, a, b string) bool {
+	if ka.SortBy != "" {
+		objA, _ := keyed[a].(map[string]interface{})
+		objB, _ := keyed[b].(map[string]interface{})
+		sa, _ := elementKey(objA[ka.SortBy])
+		sb, _ := elementKey(objB[ka.SortBy])
+		if sa != sb {
+			return sa < sb
+		}
+	}
+
+	na, errA := strconv.ParseFloat(a, 64)
+	nb, errB := strconv.ParseFloat(b, 64)
+	if errA == nil && errB == nil {
+		return na < nb
+	}
+	return a < b
+}
+
+// elementKey 将元素的键字段转换为对象键
+func elementKey(v interface{}) (string, bool) {
+	switch k := v.(type) {
+	case string:
+		return k, k != ""
+	case float64:
+		return strconv.FormatFloat(k, 'f', -1, 64), true
+	default:
+		return "", false
+	}
+}
+
+// lookupParent 返回路径最后一个键所在的对象，路径不存在时返回 nil
+func lookupParent(doc map[string]interface{}, path []string) map[string]interface{} {
+	parent := doc
+	for _, key := range path[:len(path)-1] {
+		if parent, _ = parent[key].(map[string]interface{}); parent == nil {
+			return nil
+		}
+	}
+	return parent
+}
+
+// copyMap 浅复制对象
+func copyMap(m map[string]interface{}) map[string]interface{} {
+	out := make(map[string]interface{}, len(m))
+	for k, v := range m {
+		out[k] = v
+	}
+	return out
+}
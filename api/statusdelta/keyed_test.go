@@ -0,0 +1,74 @@
+package statusdelta
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+// decode 将 JSON 解码为通用文档
+func decode(t *testing.T, s string) map[string]interface{} {
+	t.Helper()
+	var doc map[string]interface{}
+	if err := json.Unmarshal([]byte(s), &doc); err != nil {
+		t.Fatal(err)
+	}
+	return doc
+}
+
+func TestKeyArrays(t *testing.T) {
+	doc := decode(t, `{
+		"system_resources": {
+			"cpu": {"cores": [{"id": 0, "usage_percent": 10}, {"id": 1, "usage_percent": 20}]},
+			"network": {"interfaces": [{"name": "eth0"}, {"rx_bytes": 1}]},
+			"disks": [{"path": "/"}],
+			"disk_io": null
+		},
+		"docker": {"containers": [{"id": "aaa", "name": "web"}]}
+	}`)
+	KeyArrays(doc)
+
+	want := decode(t, `{
+		"system_resources": {
+			"cpu": {"cores": {"0": {"id": 0, "usage_percent": 10}, "1": {"id": 1, "usage_percent": 20}}},
+			"network": {"interfaces": {"eth0": {"name": "eth0"}}},
+			"disks": {"/": {"path": "/"}},
+			"disk_io": null
+		},
+		"docker": {"containers": {"aaa": {"id": "aaa", "name": "web"}}}
+	}`)
+	if !reflect.DeepEqual(doc, want) {
+		t.Errorf("KeyArrays = %v, want %v", doc, want)
+	}
+}
+
+func TestUnkeyArrays(t *testing.T) {
+	doc := decode(t, `{
+		"system_resources": {
+			"cpu": {"cores": {"10": {"id": 10}, "2": {"id": 2}}},
+			"disks": [{"path": "/"}]
+		},
+		"docker": {"containers": {
+			"bbb": {"id": "bbb", "name": "web"},
+			"aaa": {"id": "aaa", "name": "db"}
+		}}
+	}`)
+	got := UnkeyArrays(doc)
+
+	// 数字键按数值排序，容器按名字排序，仍为数组的路径保持原样
+	want := decode(t, `{
+		"system_resources": {
+			"cpu": {"cores": [{"id": 2}, {"id": 10}]},
+			"disks": [{"path": "/"}]
+		},
+		"docker": {"containers": [{"id": "aaa", "name": "db"}, {"id": "bbb", "name": "web"}]}
+	}`)
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("UnkeyArrays = %v, want %v", got, want)
+	}
+
+	// 原文档不被修改
+	if _, ok := doc["docker"].(map[string]interface{})["containers"].(map[string]interface{}); !ok {
+		t.Error("UnkeyArrays modified the input document")
+	}
+}
@@ -6,6 +6,9 @@ import (
 	"github.com/gin-gonic/gin"
 )
 
+// SessionContextKey Gin 上下文中保存已认证会话的键
+const SessionContextKey = "session"
+
 // AuthMiddleware 认证中间件
 func AuthMiddleware(next http.HandlerFunc) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
@@ -36,12 +39,24 @@ func GinAuthMiddleware() gin.HandlerFunc {
 			return
 		}
 
-		if !GetSessionManager().ValidateSession(token) {
+		session, ok := GetSessionManager().GetSession(token)
+		if !ok {
 			c.JSON(http.StatusUnauthorized, gin.H{"error": "session expired or invalid"})
 			c.Abort()
 			return
 		}
 
+		c.Set(SessionContextKey, session)
 		c.Next()
 	}
-}
\ No newline at end of file
+}
+
+// SessionFromContext 获取经 GinAuthMiddleware 认证的会话，未经过认证中间件时返回 false
+func SessionFromContext(c *gin.Context) (*Session, bool) {
+	v, ok := c.Get(SessionContextKey)
+	if !ok {
+		return nil, false
+	}
+	session, ok := v.(*Session)
+	return session, ok && session != nil
+}
@@ -2,16 +2,29 @@ package auth
 
 import (
 	"crypto/rand"
+	"crypto/sha256"
 	"encoding/hex"
 	"sync"
 	"time"
 )
 
+// PasswordUser 通过共用密码登录的操作者身份
+//
+// 控制端只有一个共用密码，认证能确认的只有"持有该密码"，无法区分具体的人；
+// 需要追溯到个人时结合会话 ID 和登录来源地址。
+const PasswordUser = "password"
+
 // Session 会话
 type Session struct {
-	Token     string
-	CreatedAt time.Time
-	ExpiresAt time.Time
+	Token string
+	// ID 会话标识，由令牌派生，可以写入日志和审计记录而不泄露令牌
+	ID string
+	// User 认证得到的操作者身份
+	User string
+	// RemoteAddr 登录时的客户端地址
+	RemoteAddr string
+	CreatedAt  time.Time
+	ExpiresAt  time.Time
 }
 
 // SessionManager 会话管理器
@@ -38,8 +51,8 @@ func GetSessionManager() *SessionManager {
 	return sessionInstance
 }
 
-// CreateSession 创建新会话
-func (sm *SessionManager) CreateSession() (string, error) {
+// CreateSession 为认证通过的操作者创建新会话，返回会话令牌和会话
+func (sm *SessionManager) CreateSession(user, remoteAddr string) (string, *Session, error) {
 	sm.cleanupOnce.Do(func() {
 		go sm.runCleanupRoutine()
 	})
@@ -49,20 +62,24 @@ func (sm *SessionManager) CreateSession() (string, error) {
 
 	b := make([]byte, 32)
 	if _, err := rand.Read(b); err != nil {
-		return "", err
+		return "", nil, err
 	}
 	token := hex.EncodeToString(b)
+	sum := sha256.Sum256([]byte(token))
 
 	now := time.Now()
 	session := &Session{
-		Token:     token,
-		CreatedAt: now,
-		ExpiresAt: now.Add(24 * time.Hour),
+		Token:      token,
+		ID:         hex.EncodeToString(sum[:8]),
+		User:       user,
+		RemoteAddr: remoteAddr,
+		CreatedAt:  now,
+		ExpiresAt:  now.Add(24 * time.Hour),
 	}
 
 	sm.sessions[token] = session
 
-	return token, nil
+	return token, session, nil
 }
 
 // ValidateSession 验证会话
@@ -82,6 +99,18 @@ func (sm *SessionManager) ValidateSession(token string) bool {
 	return true
 }
 
+// GetSession 获取有效会话，会话不存在或已过期时返回 false
+func (sm *SessionManager) GetSession(token string) (*Session, bool) {
+	sm.mu.RLock()
+	defer sm.mu.RUnlock()
+
+	session, exists := sm.sessions[token]
+	if !exists || time.Now().After(session.ExpiresAt) {
+		return nil, false
+	}
+	return session, true
+}
+
 // DeleteSession 删除会话
 func (sm *SessionManager) DeleteSession(token string) {
 	sm.mu.Lock()
@@ -0,0 +1,236 @@
+package cli
+
+import (
+	"flag"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"d8rctl/daemon"
+	"d8rctl/services"
+)
+
+// AutoscaleSet 创建或修改栈的 judgehost 自动扩缩容策略
+func AutoscaleSet(args []string) error {
+	fs := flag.NewFlagSet("autoscale set", flag.ContinueOnError)
+	stack := fs.String("stack", "", "stack whose judgehosts are scaled")
+	minSize := fs.Int("min", 0, "minimum number of running judgehosts")
+	maxSize := fs.Int("max", 0, "maximum number of running judgehosts (default: all planned judgehosts)")
+	perJudgehost := fs.Int("per", 0, "queued submissions per running judgehost (default 2)")
+	interval := fs.Int("interval", 0, "seconds between queue checks (default 15)")
+	upCooldown := fs.Int("up-cooldown", 0, "seconds after scaling before scaling up again (default 60)")
+	downCooldown := fs.Int("down-cooldown", 0, "seconds after scaling before scaling down (default 300)")
+	maxStep := fs.Int("max-step", 0, "maximum judgehosts started or stopped at once (default unlimited)")
+	probe := fs.String("probe", "", "HTTP URL returning the queue length instead of the DomJudge API")
+	probeField := fs.String("probe-field", "", "JSON field holding the queue length, e.g. data.queue")
+	disabled := fs.Bool("disabled", false, "save the policy without enabling it")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *stack == "" || fs.NArg() != 0 {
+		return fmt.Errorf("usage: d8rctl autoscale set -stack <name> [-min n] [-max n] [-per n] [-probe url] ...")
+	}
+
+	if !daemon.IsRunning() {
+		fmt.Println("Daemon is not running")
+		return nil
+	}
+
+	policy := services.AutoscalePolicy{
+		Stack:                    *stack,
+		Enabled:                  !*disabled,
+		Min:                      *minSize,
+		Max:                      *maxSize,
+		QueuePerJudgehost:        *perJudgehost,
+		IntervalSeconds:          *interval,
+		ScaleUpCooldownSeconds:   *upCooldown,
+		ScaleDownCooldownSeconds: *downCooldown,
+		MaxStep:                  *maxStep,
+	}
+	if *probe != "" {
+		policy.Probe = &services.QueueProbe{URL: *probe, Field: *probeField}
+	}
+
+	status, err := daemon.SetAutoscalePolicy(policy)
+	if err != nil {
+		return err
+	}
+	printAutoscaleStatus(status)
+	return nil
+}
+
+// AutoscaleStatus 显示自动扩缩容状态，指定栈时同时显示最近的事件
+func AutoscaleStatus(args []string) error {
+	fs := flag.NewFlagSet("autoscale status", flag.ContinueOnError)
+	events := fs.Int("n", 20, "number of recent events to show")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() > 1 {
+		return fmt.Errorf("usage: d8rctl autoscale status [-n 20] [stack]")
+	}
+
+	if !daemon.IsRunning() {
+		fmt.Println("Daemon is not running")
+		return nil
+	}
+
+	if fs.NArg() == 0 {
+		list, err := daemon.ListAutoscale()
+		if err != nil {
+			return err
+		}
+		if len(list) == 0 {
+			fmt.Println("No autoscale policies")
+			return nil
+		}
+		fmt.Printf("%-20s %-9s %-8s %-8s %-8s %-10s %s\n", "STACK", "ENABLED", "QUEUE", "RUNNING", "DESIRED", "BOUNDS", "PINNED")
+		for _, st := range list {
+			fmt.Printf("%-20s %-9v %-8s %-8d %-8d %-10s %s\n", st.Policy.Stack, st.Policy.Enabled,
+				formatQueue(st.Queue), st.Running, st.Desired, formatBounds(st.Policy), formatPinned(st.Policy.Pinned))
+		}
+		return nil
+	}
+
+	status, err := daemon.GetAutoscaleStatus(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	printAutoscaleStatus(status)
+
+	list := status.Events
+	if *events >= 0 && len(list) > *events {
+		list = list[len(list)-*events:]
+	}
+	if len(list) == 0 {
+		return nil
+	}
+	fmt.Println()
+	fmt.Printf("%-20s %-11s %-6s %-8s %s\n", "TIME", "ACTION", "QUEUE", "SIZE", "DETAIL")
+	for _, ev := range list {
+		detail := ev.Reason
+		if len(ev.Containers) > 0 {
+			detail += ": " + strings.Join(ev.Containers, ", ")
+		}
+		if ev.Error != "" {
+			if detail != "" {
+				detail += "; "
+			}
+			detail += "error: " + ev.Error
+		}
+		fmt.Printf("%-20s %-11s %-6s %-8s %s\n", ev.Time.Local().Format("2006-01-02 15:04:05"), ev.Action,
+			formatQueue(ev.Queue), fmt.Sprintf("%d->%d", ev.Running, ev.Desired), detail)
+	}
+	return nil
+}
+
+// AutoscalePin 固定栈运行的 judgehost 数量，不指定数量时固定为当前数量
+func AutoscalePin(args []string) error {
+	if len(args) < 1 || len(args) > 2 {
+		return fmt.Errorf("usage: d8rctl autoscale pin <stack> [size]")
+	}
+	var size *int
+	if len(args) == 2 {
+		n, err := strconv.Atoi(args[1])
+		if err != nil || n < 0 {
+			return fmt.Errorf("invalid size %q", args[1])
+		}
+		size = &n
+	}
+
+	if !daemon.IsRunning() {
+		fmt.Println("Daemon is not running")
+		return nil
+	}
+
+	status, err := daemon.PinAutoscale(args[0], size)
+	if err != nil {
+		return err
+	}
+	printAutoscaleStatus(status)
+	return nil
+}
+
+// AutoscaleUnpin 取消固定数量
+func AutoscaleUnpin(args []string) error {
+	if len(args) != 1 {
+		return fmt.Errorf("usage: d8rctl autoscale unpin <stack>")
+	}
+
+	if !daemon.IsRunning() {
+		fmt.Println("Daemon is not running")
+		return nil
+	}
+
+	status, err := daemon.UnpinAutoscale(args[0])
+	if err != nil {
+		return err
+	}
+	printAutoscaleStatus(status)
+	return nil
+}
+
+// AutoscaleRemove 删除栈的自动扩缩容策略
+func AutoscaleRemove(args []string) error {
+	if len(args) != 1 {
+		return fmt.Errorf("usage: d8rctl autoscale rm <stack>")
+	}
+
+	if !daemon.IsRunning() {
+		fmt.Println("Daemon is not running")
+		return nil
+	}
+
+	if err := daemon.RemoveAutoscalePolicy(args[0]); err != nil {
+		return err
+	}
+	fmt.Printf("Autoscale policy of stack %s removed\n", args[0])
+	return nil
+}
+
+// printAutoscaleStatus 输出自动扩缩容状态
+func printAutoscaleStatus(st *services.AutoscaleStatus) {
+	p := st.Policy
+	fmt.Printf("Stack:      %s\n", p.Stack)
+	fmt.Printf("Enabled:    %v\n", p.Enabled)
+	fmt.Printf("Bounds:     %s, %d queued per judgehost\n", formatBounds(p), p.QueuePerJudgehost)
+	fmt.Printf("Cooldowns:  up %ds, down %ds\n", p.ScaleUpCooldownSeconds, p.ScaleDownCooldownSeconds)
+	if p.Probe != nil {
+		fmt.Printf("Probe:      %s %s\n", p.Probe.URL, p.Probe.Field)
+	}
+	fmt.Printf("Pinned:     %s\n", formatPinned(p.Pinned))
+	if !st.LastEval.IsZero() {
+		fmt.Printf("Queue:      %s\n", formatQueue(st.Queue))
+		fmt.Printf("Judgehosts: %d running, %d desired, %d available\n", st.Running, st.Desired, st.Available)
+	}
+	if !st.LastScale.IsZero() {
+		fmt.Printf("Last scale: %s\n", st.LastScale.Local().Format("2006-01-02 15:04:05"))
+	}
+	if st.LastError != "" {
+		fmt.Printf("Error:      %s\n", st.LastError)
+	}
+}
+
+// formatBounds 格式化上下限
+func formatBounds(p services.AutoscalePolicy) string {
+	if p.Max == 0 {
+		return fmt.Sprintf("%d-all", p.Min)
+	}
+	return fmt.Sprintf("%d-%d", p.Min, p.Max)
+}
+
+// formatPinned 格式化固定数量
+func formatPinned(pinned *int) string {
+	if pinned == nil {
+		return "-"
+	}
+	return strconv.Itoa(*pinned)
+}
+
+// formatQueue 格式化队列长度，未知时为 -
+func formatQueue(queue *int) string {
+	if queue == nil {
+		return "-"
+	}
+	return strconv.Itoa(*queue)
+}
@@ -0,0 +1,153 @@
+package cli
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"d8rctl/daemon"
+	"d8rctl/services"
+
+	"github.com/goccy/go-yaml"
+)
+
+// ContainerCreate 按声明文件在节点上创建容器
+func ContainerCreate(args []string) error {
+	fs := flag.NewFlagSet("container create", flag.ContinueOnError)
+	node := fs.String("node", "", "target node ID")
+	file := fs.String("f", "", "container spec file (YAML or JSON)")
+	start := fs.Bool("start", false, "start the container after creating it")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *node == "" || *file == "" || fs.NArg() != 0 {
+		return fmt.Errorf("usage: d8rctl container create -node <id> -f <spec.yaml> [-start]")
+	}
+
+	spec, err := loadContainerSpec(*file)
+	if err != nil {
+		return err
+	}
+	if !daemon.IsRunning() {
+		fmt.Println("Daemon is not running")
+		return nil
+	}
+
+	fmt.Printf("Creating %s on %s from %s...\n", spec.Name, *node, spec.Image)
+	result, err := daemon.CreateContainer(daemon.ContainerCreateRequest{
+		NodeID: *node,
+		Spec:   spec,
+		Start:  *start,
+	}, false)
+	if err != nil {
+		return err
+	}
+	printContainerResult(result)
+	return nil
+}
+
+// ContainerRecreate 按声明文件重建节点上的同名容器，不存在时直接创建
+func ContainerRecreate(args []string) error {
+	fs := flag.NewFlagSet("container recreate", flag.ContinueOnError)
+	node := fs.String("node", "", "target node ID")
+	file := fs.String("f", "", "container spec file (YAML or JSON)")
+	noStart := fs.Bool("no-start", false, "do not start the new container")
+	stopTimeout := fs.Int("t", 0, "seconds to wait for the old container to stop (default 10)")
+	onlyIfChanged := fs.Bool("only-if-changed", false, "keep the existing container if its spec is unchanged")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *node == "" || *file == "" || fs.NArg() != 0 {
+		return fmt.Errorf("usage: d8rctl container recreate -node <id> -f <spec.yaml> [-no-start] [-t seconds] [-only-if-changed]")
+	}
+
+	spec, err := loadContainerSpec(*file)
+	if err != nil {
+		return err
+	}
+	if !daemon.IsRunning() {
+		fmt.Println("Daemon is not running")
+		return nil
+	}
+
+	fmt.Printf("Recreating %s on %s from %s...\n", spec.Name, *node, spec.Image)
+	result, err := daemon.CreateContainer(daemon.ContainerCreateRequest{
+		NodeID:        *node,
+		Spec:          spec,
+		NoStart:       *noStart,
+		StopTimeout:   *stopTimeout,
+		OnlyIfChanged: *onlyIfChanged,
+	}, true)
+	if err != nil {
+		return err
+	}
+	printContainerResult(result)
+	return nil
+}
+
+// ContainerRemove 删除节点上的容器
+func ContainerRemove(args []string) error {
+	fs := flag.NewFlagSet("container rm", flag.ContinueOnError)
+	node := fs.String("node", "", "target node ID")
+	force := fs.Bool("force", false, "stop the container first if it is running")
+	volumes := fs.Bool("v", false, "remove anonymous volumes of the container")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *node == "" || fs.NArg() != 1 {
+		return fmt.Errorf("usage: d8rctl container rm -node <id> [-force] [-v] <container>")
+	}
+
+	if !daemon.IsRunning() {
+		fmt.Println("Daemon is not running")
+		return nil
+	}
+
+	err := daemon.RemoveContainer(daemon.ContainerRemoveRequest{
+		NodeID:      *node,
+		ContainerID: fs.Arg(0),
+		Force:       *force,
+		Volumes:     *volumes,
+	})
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Removed %s from %s\n", fs.Arg(0), *node)
+	return nil
+}
+
+// loadContainerSpec 读取容器声明文件，JSON 是 YAML 的子集，两种格式都可以
+func loadContainerSpec(path string) (*services.ContainerSpec, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var spec services.ContainerSpec
+	if err := yaml.Unmarshal(data, &spec); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+	if spec.Name == "" || spec.Image == "" {
+		return nil, fmt.Errorf("%s: name and image are required", path)
+	}
+	return &spec, nil
+}
+
+// printContainerResult 输出创建或重建的结果
+func printContainerResult(result *services.ContainerResult) {
+	id := shortImageID(result.ContainerID)
+	switch {
+	case result.Unchanged:
+		fmt.Printf("%s (%s) is up to date\n", result.Name, id)
+	case result.Replaced != "":
+		fmt.Printf("%s recreated: %s -> %s\n", result.Name, shortImageID(result.Replaced), id)
+	default:
+		fmt.Printf("%s created (%s)\n", result.Name, id)
+	}
+	for _, w := range result.Warnings {
+		fmt.Printf("Warning: %s\n", w)
+	}
+	if result.Started {
+		fmt.Println("Container is running")
+	}
+}
@@ -0,0 +1,253 @@
+package cli
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"d8rctl/daemon"
+	"domcluster/api/format"
+)
+
+// cpDownloadRetries 下载中断后自动续传的次数
+const cpDownloadRetries = 3
+
+// Cp 在本地与节点（或节点上的容器）之间复制文件
+//
+// 远程路径的格式为 <node>:<path> 或 <node>/<container>:<path>，路径以 / 结尾时保留源文件名。
+// 下载时内容先写入 <dest>.part，中断后再次执行同一命令会从已下载的位置继续。
+func Cp(args []string) error {
+	fs := flag.NewFlagSet("cp", flag.ContinueOnError)
+	mode := fs.String("mode", "", "permissions of the uploaded file, octal (default 0644)")
+	maxSizeFlag := fs.String("max-size", "", "refuse files larger than this (e.g. 512K, 100M, 2G)")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	if fs.NArg() != 2 {
+		return fmt.Errorf("usage: d8rctl cp [-mode 0644] [-max-size 100M] <src> <dst>\n" +
+			"  remote paths: <node>:<path> or <node>/<container>:<path>")
+	}
+	maxSize, err := parseSize(*maxSizeFlag)
+	if err != nil {
+		return err
+	}
+
+	src, dst := fs.Arg(0), fs.Arg(1)
+	srcRemote, srcIsRemote := parseRemoteSpec(src)
+	dstRemote, dstIsRemote := parseRemoteSpec(dst)
+	switch {
+	case srcIsRemote && dstIsRemote:
+		return fmt.Errorf("copying between two nodes is not supported")
+	case !srcIsRemote && !dstIsRemote:
+		return fmt.Errorf("one of <src> and <dst> must be a remote path")
+	}
+
+	if !daemon.IsRunning() {
+		fmt.Println("Daemon is not running")
+		return nil
+	}
+
+	if dstIsRemote {
+		if *mode != "" {
+			if m, err := strconv.ParseUint(*mode, 8, 32); err != nil || m > 0o7777 {
+				return fmt.Errorf("invalid mode %q", *mode)
+			}
+		}
+		return upload(src, dstRemote, *mode, maxSize)
+	}
+	return download(srcRemote, dst, maxSize)
+}
+
+// upload 上传本地文件
+func upload(local string, target daemon.CopyTarget, mode string, maxSize int64) error {
+	f, err := os.Open(local)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	if !info.Mode().IsRegular() {
+		return fmt.Errorf("%s is not a regular file", local)
+	}
+	if maxSize > 0 && info.Size() > maxSize {
+		return fmt.Errorf("%s is %s, exceeds limit of %s", local, format.Bytes(uint64(info.Size())), format.Bytes(uint64(maxSize)))
+	}
+	if strings.HasSuffix(target.Path, "/") {
+		target.Path += filepath.Base(local)
+	}
+
+	result, err := daemon.UploadToNode(target, f, info.Size(), mode, maxSize)
+	if err != nil {
+		return fmt.Errorf("upload failed: %w", err)
+	}
+
+	fmt.Printf("%s -> %s (%s, mode %s, sha256 %s)\n",
+		local, formatTarget(target.NodeID, target.ContainerID, result.Path), format.Bytes(uint64(result.Size)), result.Mode, result.SHA256)
+	return nil
+}
+
+// download 下载远程文件，连接中断时从 .part 文件的末尾续传
+func download(target daemon.CopyTarget, local string, maxSize int64) error {
+	if info, err := os.Stat(local); (err == nil && info.IsDir()) || strings.HasSuffix(local, string(os.PathSeparator)) {
+		local = filepath.Join(local, path.Base(target.Path))
+	}
+	part := local + ".part"
+
+	var lastErr error
+	for attempt := 0; attempt <= cpDownloadRetries; attempt++ {
+		if attempt > 0 {
+			fmt.Fprintf(os.Stderr, "Download interrupted (%v), resuming...\n", lastErr)
+		}
+
+		done, err := downloadOnce(target, part, maxSize)
+		if err == nil {
+			if err := os.Rename(part, local); err != nil {
+				return err
+			}
+			fmt.Printf("%s -> %s (%s, mode %s, sha256 %s)\n",
+				formatTarget(target.NodeID, target.ContainerID, target.Path), local, format.Bytes(uint64(done.Size)), done.Mode, done.SHA256)
+			return nil
+		}
+		var permanent *permanentError
+		if errors.As(err, &permanent) {
+			return err
+		}
+		lastErr = err
+	}
+	return fmt.Errorf("download failed: %w (partial file kept at %s, rerun to resume)", lastErr, part)
+}
+
+// permanentError 重试无法解决的下载错误
+type permanentError struct{ err error }
+
+func (e *permanentError) Error() string { return e.err.Error() }
+func (e *permanentError) Unwrap() error { return e.err }
+
+// downloadOnce 从 .part 文件的末尾请求剩余内容，完成后校验整个文件
+func downloadOnce(target daemon.CopyTarget, part string, maxSize int64) (*daemon.CopyDownload, error) {
+	f, err := os.OpenFile(part, os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return nil, &permanentError{err}
+	}
+	defer f.Close()
+
+	offset, err := f.Seek(0, io.SeekEnd)
+	if err != nil {
+		return nil, &permanentError{err}
+	}
+
+	dl, err := daemon.DownloadFromNode(target, offset, maxSize)
+	if err != nil && offset > 0 && strings.Contains(err.Error(), "offset beyond end of file") {
+		// 远程文件已变小，之前的部分内容作废
+		if err := f.Truncate(0); err != nil {
+			return nil, &permanentError{err}
+		}
+		offset = 0
+		dl, err = daemon.DownloadFromNode(target, 0, maxSize)
+	}
+	if err != nil {
+		return nil, &permanentError{err}
+	}
+	defer dl.Body.Close()
+
+	if _, err := f.Seek(offset, io.SeekStart); err != nil {
+		return nil, &permanentError{err}
+	}
+	n, err := io.Copy(f, dl.Body)
+	if err != nil {
+		return nil, err
+	}
+	if offset+n != dl.Size {
+		return nil, fmt.Errorf("received %d of %d bytes", offset+n, dl.Size)
+	}
+
+	if err := f.Sync(); err != nil {
+		return nil, &permanentError{err}
+	}
+	sum, err := fileSHA256(part)
+	if err != nil {
+		return nil, &permanentError{err}
+	}
+	if sum != dl.SHA256 {
+		// 续传前后远程文件已变化，丢弃后重新下载
+		os.Remove(part)
+		return nil, fmt.Errorf("checksum mismatch, remote file changed")
+	}
+	if m, err := strconv.ParseUint(dl.Mode, 8, 32); err == nil {
+		f.Chmod(os.FileMode(m).Perm())
+	}
+	return dl, nil
+}
+
+// parseRemoteSpec 解析 <node>:<path> 或 <node>/<container>:<path>，远程路径必须是绝对路径
+func parseRemoteSpec(spec string) (daemon.CopyTarget, bool) {
+	host, p, ok := strings.Cut(spec, ":")
+	if !ok || host == "" || !strings.HasPrefix(p, "/") || strings.HasPrefix(host, ".") || strings.HasPrefix(host, "/") {
+		return daemon.CopyTarget{}, false
+	}
+	node, container, _ := strings.Cut(host, "/")
+	if node == "" || strings.Contains(container, "/") {
+		return daemon.CopyTarget{}, false
+	}
+	return daemon.CopyTarget{NodeID: node, ContainerID: container, Path: p}, true
+}
+
+// formatTarget 以 cp 参数的格式显示远程路径
+func formatTarget(nodeID, containerID, p string) string {
+	if containerID != "" {
+		return fmt.Sprintf("%s/%s:%s", nodeID, containerID, p)
+	}
+	return fmt.Sprintf("%s:%s", nodeID, p)
+}
+
+// parseSize 解析带 K/M/G 后缀的大小，空字符串表示不限制
+func parseSize(s string) (int64, error) {
+	if s == "" {
+		return 0, nil
+	}
+	num, multiplier := s, int64(1)
+	switch strings.ToUpper(s[len(s)-1:]) {
+	case "K":
+		multiplier = 1 << 10
+	case "M":
+		multiplier = 1 << 20
+	case "G":
+		multiplier = 1 << 30
+	}
+	if multiplier > 1 {
+		num = s[:len(s)-1]
+	}
+	n, err := strconv.ParseInt(num, 10, 64)
+	if err != nil || n <= 0 {
+		return 0, fmt.Errorf("invalid size %q", s)
+	}
+	return n * multiplier, nil
+}
+
+// fileSHA256 计算本地文件的 SHA-256
+func fileSHA256(p string) (string, error) {
+	f, err := os.Open(p)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	hash := sha256.New()
+	if _, err := io.Copy(hash, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
@@ -0,0 +1,237 @@
+package cli
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"d8rctl/daemon"
+	"d8rctl/services"
+
+	"github.com/goccy/go-yaml"
+)
+
+// DesiredStatus 显示节点的期望状态和收敛情况，不指定节点时列出所有节点
+func DesiredStatus(args []string) error {
+	fs := flag.NewFlagSet("desired status", flag.ContinueOnError)
+	live := fs.Bool("live", false, "ask the node for its latest report instead of the last one it pushed")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() > 1 {
+		return fmt.Errorf("usage: d8rctl desired status [-live] [node]")
+	}
+
+	if !daemon.IsRunning() {
+		fmt.Println("Daemon is not running")
+		return nil
+	}
+
+	if fs.NArg() == 0 {
+		list, err := daemon.ListDesired()
+		if err != nil {
+			return err
+		}
+		if len(list) == 0 {
+			fmt.Println("No desired state has been pushed to any node")
+			return nil
+		}
+		fmt.Printf("%-20s %-10s %-9s %-9s %-11s %-8s %s\n", "NODE", "CONNECTED", "REVISION", "REPORTED", "CONTAINERS", "DRIFTED", "SYNC")
+		for _, st := range list {
+			revision, containers := "-", "-"
+			if st.State != nil {
+				revision = fmt.Sprintf("%d", st.State.Revision)
+				containers = fmt.Sprintf("%d", len(st.State.Containers))
+			}
+			reported := "-"
+			if st.Report != nil {
+				reported = fmt.Sprintf("%d", st.Report.Revision)
+			}
+			fmt.Printf("%-20s %-10v %-9s %-9s %-11s %-8d %s\n", st.NodeID, st.Connected, revision, reported, containers, st.Drifted, desiredSyncState(st))
+		}
+		return nil
+	}
+
+	status, err := daemon.GetDesiredStatus(fs.Arg(0), *live)
+	if err != nil {
+		return err
+	}
+	printDesiredStatus(status)
+	return nil
+}
+
+// DesiredSet 将声明文件中的容器写入节点的期望状态，替换同一来源的容器
+func DesiredSet(args []string) error {
+	fs := flag.NewFlagSet("desired set", flag.ContinueOnError)
+	node := fs.String("node", "", "target node ID")
+	file := fs.String("f", "", "file with a list of {policy, spec} entries (YAML or JSON)")
+	source := fs.String("source", services.DesiredSourceManual, "source whose containers are replaced")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *node == "" || *file == "" || fs.NArg() != 0 {
+		return fmt.Errorf("usage: d8rctl desired set -node <id> -f <desired.yaml> [-source manual]")
+	}
+
+	data, err := os.ReadFile(*file)
+	if err != nil {
+		return err
+	}
+	var containers []services.DesiredContainer
+	if err := yaml.Unmarshal(data, &containers); err != nil {
+		return fmt.Errorf("failed to parse %s: %w", *file, err)
+	}
+
+	if !daemon.IsRunning() {
+		fmt.Println("Daemon is not running")
+		return nil
+	}
+
+	state, err := daemon.SetNodeDesired(daemon.DesiredSetRequest{
+		Node:       *node,
+		Source:     *source,
+		Containers: containers,
+	})
+	if err != nil {
+		return err
+	}
+	printDesiredPush(state)
+	return nil
+}
+
+// DesiredClear 从节点的期望状态中删除一个来源的容器，容器本身保留
+func DesiredClear(args []string) error {
+	fs := flag.NewFlagSet("desired clear", flag.ContinueOnError)
+	node := fs.String("node", "", "target node ID")
+	source := fs.String("source", services.DesiredSourceManual, "source whose containers are removed, e.g. stack/<name>")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *node == "" || fs.NArg() != 0 {
+		return fmt.Errorf("usage: d8rctl desired clear -node <id> [-source manual]")
+	}
+
+	if !daemon.IsRunning() {
+		fmt.Println("Daemon is not running")
+		return nil
+	}
+
+	state, err := daemon.SetNodeDesired(daemon.DesiredSetRequest{
+		Node:       *node,
+		Source:     *source,
+		Containers: []services.DesiredContainer{},
+	})
+	if err != nil {
+		return err
+	}
+	printDesiredPush(state)
+	return nil
+}
+
+// DesiredSync 将栈当前的声明同步到各节点的期望状态
+func DesiredSync(args []string) error {
+	if len(args) != 1 {
+		return fmt.Errorf("usage: d8rctl desired sync <stack>")
+	}
+
+	if !daemon.IsRunning() {
+		fmt.Println("Daemon is not running")
+		return nil
+	}
+
+	result, err := daemon.SyncStackDesired(args[0])
+	if err != nil {
+		return err
+	}
+	nodes := make([]string, 0, len(result.Nodes))
+	for node := range result.Nodes {
+		nodes = append(nodes, node)
+	}
+	sort.Strings(nodes)
+	for _, node := range nodes {
+		if msg, ok := result.Errors[node]; ok {
+			fmt.Printf("%-20s %d containers, not pushed: %s\n", node, result.Nodes[node], msg)
+			continue
+		}
+		fmt.Printf("%-20s %d containers\n", node, result.Nodes[node])
+	}
+	if len(result.Errors) > 0 {
+		return fmt.Errorf("desired state of stack %s not pushed to %d nodes", result.Stack, len(result.Errors))
+	}
+	return nil
+}
+
+// printDesiredPush 输出下发后的期望状态
+func printDesiredPush(st *services.NodeDesiredState) {
+	fmt.Printf("Node %s: revision %d, %d containers\n", st.NodeID, st.Revision, len(st.Containers))
+	switch {
+	case st.PushError != "":
+		fmt.Printf("Push failed: %s\n", st.PushError)
+	case st.Pushed != st.Revision:
+		fmt.Println("Node is not connected, the desired state will be pushed when it reconnects")
+	}
+}
+
+// printDesiredStatus 输出节点的期望容器及其收敛情况
+func printDesiredStatus(st *services.DesiredStatus) {
+	fmt.Printf("Node:      %s\n", st.NodeID)
+	fmt.Printf("Connected: %v\n", st.Connected)
+	fmt.Printf("Sync:      %s\n", desiredSyncState(*st))
+	if st.State != nil {
+		fmt.Printf("Revision:  %d (pushed %d, updated %s)\n", st.State.Revision, st.State.Pushed,
+			st.State.Updated.Local().Format("2006-01-02 15:04:05"))
+		if st.State.PushError != "" {
+			fmt.Printf("Error:     %s\n", st.State.PushError)
+		}
+	}
+	if st.Report == nil {
+		return
+	}
+	fmt.Printf("Checked:   %s (revision %d)\n", st.Report.Checked.Local().Format("2006-01-02 15:04:05"), st.Report.Revision)
+	if st.Report.Error != "" {
+		fmt.Printf("Error:     %s\n", st.Report.Error)
+	}
+
+	fmt.Printf("\n%-28s %-16s %-15s %-11s %-9s %s\n", "CONTAINER", "SOURCE", "POLICY", "STATE", "HELD", "DETAIL")
+	for _, c := range st.Report.Containers {
+		held := c.Held
+		if held == "" {
+			held = "-"
+		}
+		var detail []string
+		detail = append(detail, c.Drift...)
+		if c.LastAction != "" {
+			detail = append(detail, fmt.Sprintf("%s at %s", c.LastAction, c.LastActionAt.Local().Format("15:04:05")))
+		}
+		if c.Failures > 1 {
+			detail = append(detail, fmt.Sprintf("%d restarts", c.Failures))
+		}
+		if c.Error != "" {
+			detail = append(detail, "error: "+c.Error)
+		}
+		fmt.Printf("%-28s %-16s %-15s %-11s %-9s %s\n", c.Name, c.Source, c.Policy, c.State, held, strings.Join(detail, "; "))
+	}
+	for _, name := range st.Report.Extra {
+		fmt.Printf("%-28s %-16s %-15s %-11s %-9s %s\n", name, "-", "-", "extra", "-", "not in the desired state")
+	}
+}
+
+// desiredSyncState 概括节点是否已按最新的期望状态收敛
+func desiredSyncState(st services.DesiredStatus) string {
+	switch {
+	case st.State == nil:
+		return "-"
+	case st.State.PushError != "":
+		return "push failed"
+	case st.State.Pushed != st.State.Revision:
+		return "pending"
+	case !st.InSync:
+		return "waiting for report"
+	case st.Drifted > 0:
+		return "drifted"
+	default:
+		return "in sync"
+	}
+}
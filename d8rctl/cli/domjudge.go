@@ -0,0 +1,151 @@
+package cli
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"d8rctl/daemon"
+	"d8rctl/services"
+	"d8rctl/services/domjudge"
+)
+
+// DomjudgeConfig 查看或修改 DomJudge API 的连接配置
+func DomjudgeConfig(args []string) error {
+	fs := flag.NewFlagSet("domjudge config", flag.ContinueOnError)
+	apiURL := fs.String("url", "", "domserver base URL, e.g. https://domjudge.example.com/")
+	username := fs.String("user", "", "API user with the admin or api_writer role")
+	passwordStdin := fs.Bool("password-stdin", false, "read the API password from stdin")
+	timeout := fs.Int("timeout", 0, "request timeout in seconds (default 10)")
+	insecure := fs.Bool("insecure", false, "skip TLS certificate verification")
+	disable := fs.Bool("disable", false, "remove the configuration and disable the integration")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 0 {
+		return fmt.Errorf("usage: d8rctl domjudge config [-url <url> -user <name> [-password-stdin] [-timeout 10] [-insecure]] [-disable]")
+	}
+
+	if !daemon.IsRunning() {
+		fmt.Println("Daemon is not running")
+		return nil
+	}
+
+	if *disable {
+		if err := daemon.SetDomjudgeConfig(domjudge.Config{}); err != nil {
+			return err
+		}
+		fmt.Println("DomJudge integration disabled")
+		return nil
+	}
+
+	if *apiURL == "" {
+		cfg, err := daemon.GetDomjudgeConfig()
+		if err != nil {
+			return err
+		}
+		if cfg == nil {
+			fmt.Println("DomJudge integration is not configured")
+			return nil
+		}
+		fmt.Printf("URL:      %s\n", cfg.URL)
+		fmt.Printf("User:     %s\n", cfg.Username)
+		if cfg.TimeoutSeconds > 0 {
+			fmt.Printf("Timeout:  %ds\n", cfg.TimeoutSeconds)
+		}
+		if cfg.InsecureSkipVerify {
+			fmt.Println("TLS:      certificate verification disabled")
+		}
+		return nil
+	}
+
+	cfg := domjudge.Config{
+		URL:                *apiURL,
+		Username:           *username,
+		TimeoutSeconds:     *timeout,
+		InsecureSkipVerify: *insecure,
+	}
+	if *passwordStdin {
+		line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+		if err != nil && line == "" {
+			return fmt.Errorf("failed to read password: %w", err)
+		}
+		cfg.Password = strings.TrimRight(line, "\r\n")
+	}
+	if err := daemon.SetDomjudgeConfig(cfg); err != nil {
+		return err
+	}
+	fmt.Printf("DomJudge integration configured for %s\n", cfg.URL)
+	return nil
+}
+
+// DomjudgeJudgehosts 列出 DomJudge 中的 judgehost 及其所在节点
+func DomjudgeJudgehosts(args []string) error {
+	if len(args) != 0 {
+		return fmt.Errorf("usage: d8rctl domjudge judgehosts")
+	}
+
+	if !daemon.IsRunning() {
+		fmt.Println("Daemon is not running")
+		return nil
+	}
+
+	hosts, err := daemon.ListJudgehosts()
+	if err != nil {
+		return err
+	}
+	if len(hosts) == 0 {
+		fmt.Println("No judgehosts registered in DomJudge")
+		return nil
+	}
+	printJudgehosts(hosts)
+	return nil
+}
+
+// DomjudgeSetEnabled 在 DomJudge 中启用或禁用 judgehost，参数为 judgehost 名字或节点 ID
+func DomjudgeSetEnabled(args []string, enabled bool) error {
+	if len(args) != 1 {
+		action := "enable"
+		if !enabled {
+			action = "disable"
+		}
+		return fmt.Errorf("usage: d8rctl domjudge %s <judgehost|node>", action)
+	}
+
+	if !daemon.IsRunning() {
+		fmt.Println("Daemon is not running")
+		return nil
+	}
+
+	hosts, err := daemon.SetJudgehostEnabled(args[0], enabled)
+	if err != nil {
+		return err
+	}
+	printJudgehosts(hosts)
+	return nil
+}
+
+// printJudgehosts 以表格形式输出 judgehost
+func printJudgehosts(hosts []services.JudgehostStatus) {
+	fmt.Printf("%-24s %-8s %-10s %-20s %-28s %s\n", "HOSTNAME", "ENABLED", "POLL AGE", "NODE", "CONTAINER", "JUDGING")
+	for _, h := range hosts {
+		pollAge := "-"
+		if h.Polltime != nil {
+			pollAge = fmt.Sprintf("%.0fs", h.PollAge)
+		}
+		node, container := h.NodeID, h.Container
+		if node == "" {
+			node = "-"
+		}
+		if container == "" {
+			container = "-"
+		}
+		judging := "-"
+		if j := h.CurrentJudging; j != nil {
+			judging = fmt.Sprintf("j%s (s%s)", j.ID, j.SubmissionID)
+		}
+		fmt.Printf("%-24s %-8v %-10s %-20s %-28s %s\n", h.Hostname, h.Enabled, pollAge, node, container, judging)
+	}
+}
@@ -0,0 +1,176 @@
+package cli
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"d8rctl/daemon"
+	"d8rctl/services"
+	"domcluster/api/format"
+)
+
+// imageDistPollInterval 查询分发进度的间隔
+const imageDistPollInterval = time.Second
+
+// stringList 可重复的字符串参数
+type stringList []string
+
+func (l *stringList) String() string { return strings.Join(*l, ",") }
+
+func (l *stringList) Set(v string) error {
+	*l = append(*l, v)
+	return nil
+}
+
+// ImageDist 将镜像离线分发到节点
+//
+// 参数为本地的 docker save 镜像包（.tar 文件），或一个或多个镜像引用（从控制端本机的 Docker 导出）。
+// 节点上已有相同镜像 ID 时跳过；中断后再次执行同一命令会从节点已接收的位置继续。
+func ImageDist(args []string) error {
+	fs := flag.NewFlagSet("image dist", flag.ContinueOnError)
+	var nodes, labels stringList
+	fs.Var(&nodes, "node", "target node ID (repeatable)")
+	role := fs.String("role", "", "target all connected nodes with this role")
+	fs.Var(&labels, "label", "target nodes with label key=value (repeatable)")
+	concurrency := fs.Int("c", 0, "number of nodes to transfer to at once (default 4)")
+	force := fs.Bool("force", false, "transfer even if the node already has the image")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	if fs.NArg() == 0 || (len(nodes) == 0 && *role == "" && len(labels) == 0) {
+		return fmt.Errorf("usage: d8rctl image dist [-node id]... [-role role] [-label k=v]... [-c 4] [-force] <archive.tar | image...>")
+	}
+	labelMap := make(map[string]string)
+	for _, l := range labels {
+		k, v, ok := strings.Cut(l, "=")
+		if !ok || k == "" {
+			return fmt.Errorf("invalid label %q, expected key=value", l)
+		}
+		labelMap[k] = v
+	}
+
+	if !daemon.IsRunning() {
+		fmt.Println("Daemon is not running")
+		return nil
+	}
+
+	archive, err := prepareImageArchive(fs.Args())
+	if err != nil {
+		return err
+	}
+	for _, img := range archive.Images {
+		fmt.Printf("  %s %s\n", shortImageID(img.ID), strings.Join(img.RepoTags, ", "))
+	}
+
+	dist, err := daemon.StartImageDistribution(daemon.ImageDistRequest{
+		ArchiveID:   archive.ID,
+		NodeIDs:     nodes,
+		Role:        *role,
+		Labels:      labelMap,
+		Concurrency: *concurrency,
+		Force:       *force,
+	})
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Distributing to %d nodes (distribution %s)\n", len(dist.Nodes), dist.ID)
+
+	return watchImageDistribution(dist)
+}
+
+// prepareImageArchive 上传本地镜像包，或让控制端从本机 Docker 导出镜像
+func prepareImageArchive(args []string) (*services.ImageArchive, error) {
+	if len(args) == 1 {
+		if info, err := os.Stat(args[0]); err == nil && info.Mode().IsRegular() {
+			f, err := os.Open(args[0])
+			if err != nil {
+				return nil, err
+			}
+			defer f.Close()
+
+			fmt.Printf("Uploading %s (%s) to the controller...\n", args[0], format.Bytes(uint64(info.Size())))
+			archive, err := daemon.UploadImageArchive(f, info.Size())
+			if err != nil {
+				return nil, fmt.Errorf("upload failed: %w", err)
+			}
+			return archive, nil
+		}
+	}
+
+	fmt.Printf("Saving %s from the controller's Docker...\n", strings.Join(args, ", "))
+	archive, err := daemon.SaveLocalImages(args)
+	if err != nil {
+		return nil, fmt.Errorf("docker save failed: %w", err)
+	}
+	fmt.Printf("Archive %s (%s)\n", archive.ID[:12], format.Bytes(uint64(archive.Size)))
+	return archive, nil
+}
+
+// watchImageDistribution 输出每个节点的状态变化，直到任务结束
+func watchImageDistribution(dist *services.ImageDistribution) error {
+	lastState := make(map[string]string)
+	lastPercent := make(map[string]int64)
+	for {
+		for _, n := range dist.Nodes {
+			percent := int64(100)
+			if n.Total > 0 {
+				percent = n.Sent * 100 / n.Total
+			}
+			// 状态变化时输出，传输中每 10% 输出一次
+			if n.State == lastState[n.NodeID] && (n.State != services.ImageDistUploading || percent/10 == lastPercent[n.NodeID]/10) {
+				continue
+			}
+			lastState[n.NodeID] = n.State
+			lastPercent[n.NodeID] = percent
+
+			switch n.State {
+			case services.ImageDistUploading:
+				fmt.Printf("%-20s uploading %3d%% (%s / %s)\n", n.NodeID, percent, format.Bytes(uint64(n.Sent)), format.Bytes(uint64(n.Total)))
+			case services.ImageDistDone:
+				fmt.Printf("%-20s loaded %s\n", n.NodeID, strings.Join(n.Loaded, ", "))
+			case services.ImageDistSkipped:
+				fmt.Printf("%-20s already has the image, skipped\n", n.NodeID)
+			case services.ImageDistFailed, services.ImageDistCanceled:
+				fmt.Printf("%-20s %s: %s\n", n.NodeID, n.State, n.Error)
+			default:
+				fmt.Printf("%-20s %s\n", n.NodeID, n.State)
+			}
+		}
+
+		if dist.Done {
+			break
+		}
+		time.Sleep(imageDistPollInterval)
+
+		next, err := daemon.GetImageDistribution(dist.ID)
+		if err != nil {
+			return err
+		}
+		dist = next
+	}
+
+	counts := make(map[string]int)
+	for _, n := range dist.Nodes {
+		counts[n.State]++
+	}
+	fmt.Printf("Done: %d loaded, %d skipped, %d failed, %d canceled\n",
+		counts[services.ImageDistDone], counts[services.ImageDistSkipped],
+		counts[services.ImageDistFailed], counts[services.ImageDistCanceled])
+	if failed := counts[services.ImageDistFailed] + counts[services.ImageDistCanceled]; failed > 0 {
+		return fmt.Errorf("distribution to %d nodes did not complete, rerun the same command to resume", failed)
+	}
+	return nil
+}
+
+// shortImageID 返回镜像 ID 的前 12 位
+func shortImageID(id string) string {
+	id = strings.TrimPrefix(id, "sha256:")
+	if len(id) > 12 {
+		return id[:12]
+	}
+	return id
+}
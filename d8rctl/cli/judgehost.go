@@ -0,0 +1,78 @@
+package cli
+
+import (
+	"flag"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"d8rctl/daemon"
+)
+
+// JudgehostProvision 在节点上为每个选定的物理核心部署一个绑定到该核心的 judgehost
+func JudgehostProvision(args []string) error {
+	fs := flag.NewFlagSet("judgehost provision", flag.ContinueOnError)
+	stack := fs.String("stack", "", "stack the judgehosts belong to")
+	node := fs.String("node", "", "target node ID")
+	cores := fs.String("cores", "", "CPUs to run judgehosts on, e.g. 2-7 (default: all but the reserved cores)")
+	detach := fs.Bool("d", false, "return without waiting for provisioning to finish")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *stack == "" || *node == "" || fs.NArg() != 0 {
+		return fmt.Errorf("usage: d8rctl judgehost provision -stack <name> -node <id> [-cores 2-7] [-d]")
+	}
+
+	if !daemon.IsRunning() {
+		fmt.Println("Daemon is not running")
+		return nil
+	}
+
+	dep, err := daemon.ProvisionJudgehosts(daemon.JudgehostProvisionRequest{
+		Stack:  *stack,
+		NodeID: *node,
+		Cores:  *cores,
+	})
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Provisioning judgehosts of stack %s on %s\n", dep.Stack, *node)
+	if *detach {
+		return nil
+	}
+	return watchStackDeployment(dep)
+}
+
+// JudgehostTopology 显示节点的物理核心及 SMT 兄弟线程
+func JudgehostTopology(args []string) error {
+	if len(args) != 1 {
+		return fmt.Errorf("usage: d8rctl judgehost topology <node>")
+	}
+
+	if !daemon.IsRunning() {
+		fmt.Println("Daemon is not running")
+		return nil
+	}
+
+	topo, err := daemon.GetNodeTopology(args[0])
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Node %s: %d CPUs, %d physical cores, %d threads per core\n",
+		topo.NodeID, topo.NumCPU, len(topo.Cores), topo.ThreadsPerCore)
+	fmt.Printf("%-6s %-8s %-6s %s\n", "CPU", "PACKAGE", "CORE", "THREADS")
+	for _, c := range topo.Cores {
+		fmt.Printf("%-6d %-8d %-6d %s\n", c.CPU, c.Package, c.Core, formatInts(c.Threads))
+	}
+	return nil
+}
+
+// formatInts 以逗号连接整数
+func formatInts(values []int) string {
+	parts := make([]string, len(values))
+	for i, v := range values {
+		parts[i] = strconv.Itoa(v)
+	}
+	return strings.Join(parts, ",")
+}
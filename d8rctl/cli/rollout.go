@@ -0,0 +1,157 @@
+package cli
+
+import (
+	"flag"
+	"fmt"
+	"strings"
+
+	"d8rctl/daemon"
+	"d8rctl/services"
+)
+
+// RolloutStart 分批将栈的 judgehost 更新为新镜像
+func RolloutStart(args []string) error {
+	fs := flag.NewFlagSet("rollout start", flag.ContinueOnError)
+	stack := fs.String("stack", "", "stack whose judgehosts are updated")
+	image := fs.String("image", "", "new judgehost image")
+	nodes := fs.String("nodes", "", "comma-separated nodes to update (default: all judgehost nodes)")
+	batch := fs.Int("batch", 1, "number of judgehosts updated at a time")
+	drainTimeout := fs.Int("drain-timeout", 0, "seconds to wait for in-flight judgings (default 600)")
+	healthTimeout := fs.Int("health-timeout", 0, "seconds to wait for a new judgehost to become healthy (default: stack health_timeout)")
+	noRollback := fs.Bool("no-rollback", false, "halt on failure instead of rolling back, so the rollout can be resumed")
+	detach := fs.Bool("d", false, "return without waiting for the rollout to finish")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *stack == "" || *image == "" || fs.NArg() != 0 {
+		return fmt.Errorf("usage: d8rctl rollout start -stack <name> -image <image> [-nodes a,b] [-batch n] [-no-rollback] [-d]")
+	}
+
+	if !daemon.IsRunning() {
+		fmt.Println("Daemon is not running")
+		return nil
+	}
+
+	req := services.RolloutRequest{
+		Stack:                *stack,
+		Image:                *image,
+		BatchSize:            *batch,
+		DrainTimeoutSeconds:  *drainTimeout,
+		HealthTimeoutSeconds: *healthTimeout,
+		NoRollback:           *noRollback,
+	}
+	if *nodes != "" {
+		req.Nodes = strings.Split(*nodes, ",")
+	}
+
+	rollout, err := daemon.StartRollout(req)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Rolling out %s to %d judgehosts of stack %s in batches of %d\n",
+		rollout.Image, len(rollout.Targets), rollout.Stack, rollout.BatchSize)
+	if *detach {
+		return nil
+	}
+	return watchRollout(rollout.Stack)
+}
+
+// RolloutStatus 显示栈最近一次 rollout 的状态
+func RolloutStatus(args []string) error {
+	if len(args) != 1 {
+		return fmt.Errorf("usage: d8rctl rollout status <stack>")
+	}
+
+	if !daemon.IsRunning() {
+		fmt.Println("Daemon is not running")
+		return nil
+	}
+
+	rollout, err := daemon.GetRollout(args[0])
+	if err != nil {
+		return err
+	}
+	printRollout(rollout)
+	return nil
+}
+
+// RolloutResume 继续已暂停的 rollout
+func RolloutResume(args []string) error {
+	return rolloutAction(args, "resume", daemon.ResumeRollout)
+}
+
+// RolloutRollback 回滚已暂停的 rollout
+func RolloutRollback(args []string) error {
+	return rolloutAction(args, "rollback", daemon.RollbackRollout)
+}
+
+// rolloutAction 执行继续或回滚并等待完成
+func rolloutAction(args []string, name string, fn func(string) (*services.Rollout, error)) error {
+	fs := flag.NewFlagSet("rollout "+name, flag.ContinueOnError)
+	detach := fs.Bool("d", false, "return without waiting for the operation to finish")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		return fmt.Errorf("usage: d8rctl rollout %s [-d] <stack>", name)
+	}
+
+	if !daemon.IsRunning() {
+		fmt.Println("Daemon is not running")
+		return nil
+	}
+
+	rollout, err := fn(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	if *detach {
+		printRollout(rollout)
+		return nil
+	}
+	return watchRollout(rollout.Stack)
+}
+
+// watchRollout 输出 rollout 的步骤直到结束
+func watchRollout(stack string) error {
+	dep, err := daemon.GetStackDeployment(stack)
+	if err != nil {
+		return err
+	}
+	// 栈操作结束前 rollout 状态已保存，步骤的错误会在最终状态中体现
+	watchErr := watchStackDeployment(dep)
+
+	rollout, err := daemon.GetRollout(stack)
+	if err != nil {
+		return err
+	}
+	fmt.Println()
+	printRollout(rollout)
+	if rollout.State != services.RolloutSucceeded {
+		return fmt.Errorf("rollout of stack %s is %s", stack, rollout.State)
+	}
+	return watchErr
+}
+
+// printRollout 输出 rollout 的状态和各容器的进度
+func printRollout(r *services.Rollout) {
+	fmt.Printf("Stack:    %s\n", r.Stack)
+	fmt.Printf("Image:    %s\n", r.Image)
+	fmt.Printf("State:    %s\n", r.State)
+	fmt.Printf("Started:  %s\n", r.Started.Local().Format("2006-01-02 15:04:05"))
+	if !r.Finished.IsZero() {
+		fmt.Printf("Finished: %s\n", r.Finished.Local().Format("2006-01-02 15:04:05"))
+	}
+	if r.Error != "" {
+		fmt.Printf("Error:    %s\n", r.Error)
+	}
+
+	fmt.Printf("\n%-20s %-28s %-24s %-12s %s\n", "NODE", "CONTAINER", "HOSTNAME", "STATUS", "PREVIOUS IMAGE")
+	for _, t := range r.Targets {
+		line := fmt.Sprintf("%-20s %-28s %-24s %-12s %s", t.NodeID, t.Container, t.Hostname, t.Status, t.PreviousImage)
+		if t.Error != "" {
+			line += "  (" + t.Error + ")"
+		}
+		fmt.Println(line)
+	}
+}
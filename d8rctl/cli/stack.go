@@ -0,0 +1,173 @@
+package cli
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"d8rctl/daemon"
+	"d8rctl/services"
+)
+
+// stackPollInterval 查询部署进度的间隔
+const stackPollInterval = time.Second
+
+// StackUp 按栈声明部署 DomJudge，重复执行会收敛到声明的状态
+func StackUp(args []string) error {
+	fs := flag.NewFlagSet("stack up", flag.ContinueOnError)
+	file := fs.String("f", "", "stack spec file (YAML or JSON)")
+	detach := fs.Bool("d", false, "return without waiting for the deployment to finish")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *file == "" || fs.NArg() != 0 {
+		return fmt.Errorf("usage: d8rctl stack up -f <stack.yaml> [-d]")
+	}
+
+	data, err := os.ReadFile(*file)
+	if err != nil {
+		return err
+	}
+	if !daemon.IsRunning() {
+		fmt.Println("Daemon is not running")
+		return nil
+	}
+
+	dep, err := daemon.StackUp(data)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Deploying stack %s\n", dep.Stack)
+	if *detach {
+		return nil
+	}
+	return watchStackDeployment(dep)
+}
+
+// StackDown 删除栈的全部容器，数据库目录保留在节点上
+func StackDown(args []string) error {
+	fs := flag.NewFlagSet("stack down", flag.ContinueOnError)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		return fmt.Errorf("usage: d8rctl stack down <name>")
+	}
+
+	if !daemon.IsRunning() {
+		fmt.Println("Daemon is not running")
+		return nil
+	}
+
+	dep, err := daemon.StackDown(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Removing stack %s\n", dep.Stack)
+	return watchStackDeployment(dep)
+}
+
+// StackStatus 显示栈中各容器的状态，不指定名称时列出全部栈
+func StackStatus(args []string) error {
+	fs := flag.NewFlagSet("stack status", flag.ContinueOnError)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() > 1 {
+		return fmt.Errorf("usage: d8rctl stack status [name]")
+	}
+
+	if !daemon.IsRunning() {
+		fmt.Println("Daemon is not running")
+		return nil
+	}
+
+	if fs.NArg() == 0 {
+		names, err := daemon.ListStacks()
+		if err != nil {
+			return err
+		}
+		if len(names) == 0 {
+			fmt.Println("No stacks deployed")
+			return nil
+		}
+		for _, name := range names {
+			fmt.Println(name)
+		}
+		return nil
+	}
+
+	status, err := daemon.GetStackStatus(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("%-10s %-20s %-28s %-20s %s\n", "COMPONENT", "NODE", "CONTAINER", "STATE", "NOTE")
+	for _, c := range status.Containers {
+		state := c.State
+		if c.Health != "" {
+			state += " (" + c.Health + ")"
+		}
+		var note string
+		switch {
+		case c.Error != "":
+			state, note = "unknown", c.Error
+		case c.Missing:
+			state, note = "-", "missing"
+		case c.Extra:
+			note = "not in spec, removed on next up"
+		case !c.UpToDate:
+			note = "outdated"
+		}
+		fmt.Printf("%-10s %-20s %-28s %-20s %s\n", c.Component, c.NodeID, c.Name, state, note)
+	}
+
+	if status.Healthy {
+		fmt.Printf("\nStack %s is healthy\n", status.Name)
+	} else {
+		fmt.Printf("\nStack %s is not healthy, run 'd8rctl stack up' to converge\n", status.Name)
+	}
+	if dep := status.Deployment; dep != nil {
+		switch {
+		case !dep.Done:
+			fmt.Printf("A %s operation is in progress since %s\n", dep.Operation, dep.Started.Format(time.RFC3339))
+		case dep.Error != "":
+			fmt.Printf("Last %s failed at %s: %s\n", dep.Operation, dep.Finished.Format(time.RFC3339), dep.Error)
+		}
+	}
+	return nil
+}
+
+// watchStackDeployment 输出新的部署步骤，直到操作结束
+func watchStackDeployment(dep *services.StackDeployment) error {
+	printed := 0
+	for {
+		for _, st := range dep.Steps[printed:] {
+			line := fmt.Sprintf("%-10s %-20s %-28s %s", st.Component, st.NodeID, st.Container, st.Action)
+			if st.Error != "" {
+				line += ": " + st.Error
+			}
+			fmt.Println(strings.TrimRight(line, " "))
+		}
+		printed = len(dep.Steps)
+
+		if dep.Done {
+			break
+		}
+		time.Sleep(stackPollInterval)
+
+		next, err := daemon.GetStackDeployment(dep.Stack)
+		if err != nil {
+			return err
+		}
+		dep = next
+	}
+
+	if dep.Error != "" {
+		return fmt.Errorf("stack %s %s failed: %s", dep.Stack, dep.Operation, dep.Error)
+	}
+	fmt.Printf("Stack %s %s completed in %s\n", dep.Stack, dep.Operation, dep.Finished.Sub(dep.Started).Round(time.Second))
+	return nil
+}
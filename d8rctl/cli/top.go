@@ -0,0 +1,65 @@
+package cli
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"d8rctl/daemon"
+	"domcluster/api/format"
+)
+
+// Top 查看节点上资源占用最高的进程
+func Top(args []string) error {
+	fs := flag.NewFlagSet("top", flag.ContinueOnError)
+	sortBy := fs.String("sort", "cpu", "sort by cpu or memory")
+	limit := fs.Int("n", 10, "number of processes to show")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	if fs.NArg() != 1 {
+		return fmt.Errorf("usage: d8rctl top [-sort cpu|memory] [-n count] <node>")
+	}
+	nodeID := fs.Arg(0)
+
+	if !daemon.IsRunning() {
+		fmt.Println("Daemon is not running")
+		return nil
+	}
+
+	top, err := daemon.GetTopProcesses(nodeID, *sortBy, *limit)
+	if err != nil {
+		return fmt.Errorf("failed to get top processes: %w", err)
+	}
+
+	fmt.Printf("Node %s: top %d of %d processes by %s\n\n", nodeID, len(top.Processes), top.Total, top.SortBy)
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "PID\tUSER\tCPU%\tMEM%\tRSS\tTHR\tFDS\tCONTAINER\tCOMMAND")
+	for _, p := range top.Processes {
+		fds := "-"
+		if p.OpenFDs >= 0 {
+			fds = fmt.Sprintf("%d", p.OpenFDs)
+		}
+		containerID := p.ContainerID
+		if len(containerID) > 12 {
+			containerID = containerID[:12]
+		}
+		if containerID == "" {
+			containerID = "-"
+		}
+		command := p.Cmdline
+		if command == "" {
+			command = "[" + p.Name + "]"
+		}
+		if len(command) > 80 {
+			command = command[:77] + "..."
+		}
+		fmt.Fprintf(w, "%d\t%s\t%.1f\t%.1f\t%s\t%d\t%s\t%s\t%s\n",
+			p.PID, p.User, p.CPUPercent, p.MemoryPercent, format.Bytes(p.MemoryRSS), p.Threads, fds, containerID, command)
+	}
+
+	return w.Flush()
+}
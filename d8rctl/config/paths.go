@@ -16,6 +16,11 @@ func GetLogDir() string {
 	return "/var/log/d8rctl" 
 }
 
+// GetDataDir 获取持久化数据目录
+func GetDataDir() string {
+	return "/var/lib/d8rctl"
+}
+
 // GetPIDFile 获取PID文件路径
 func GetPIDFile() string {
 	return filepath.Join(GetPIDDir(), "d8rctl.pid")
@@ -38,6 +43,11 @@ func EnsureDirs() error {
 		return err
 	}
 
+	// 确保数据目录存在
+	if err := os.MkdirAll(GetDataDir(), 0755); err != nil {
+		return err
+	}
+
 	return nil
 }
 
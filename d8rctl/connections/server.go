@@ -11,6 +11,7 @@ import (
 
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/credentials"
+	_ "google.golang.org/grpc/encoding/gzip" // 注册 gzip 解压器，节点上报使用 gzip 压缩
 	"google.golang.org/grpc/keepalive"
 	"go.uber.org/zap"
 )
@@ -0,0 +1,141 @@
+package daemon
+
+import (
+	"errors"
+	"net/http"
+
+	"d8rctl/services"
+	"d8rctl/services/agentconfig"
+
+	"github.com/gin-gonic/gin"
+)
+
+// handleGetAgentConfig 获取全部节点配置
+func (hs *HTTPServer) handleGetAgentConfig(c *gin.Context) {
+	store := hs.svc.(*services.DomclusterServer).GetAgentConfig()
+	c.JSON(http.StatusOK, store.Snapshot())
+}
+
+// handleSetDefaultAgentConfig 设置默认节点配置
+func (hs *HTTPServer) handleSetDefaultAgentConfig(c *gin.Context) {
+	var cfg agentconfig.AgentConfig
+	if err := c.ShouldBindJSON(&cfg); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request"})
+		return
+	}
+
+	svc := hs.svc.(*services.DomclusterServer)
+	revision, err := svc.GetAgentConfig().SetDefault(cfg)
+	hs.respondAgentConfigUpdate(c, svc, revision, err)
+}
+
+// handleSetLabelAgentConfig 设置标签配置
+func (hs *HTTPServer) handleSetLabelAgentConfig(c *gin.Context) {
+	var cfg agentconfig.AgentConfig
+	if err := c.ShouldBindJSON(&cfg); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request"})
+		return
+	}
+
+	svc := hs.svc.(*services.DomclusterServer)
+	revision, err := svc.GetAgentConfig().SetLabelConfig(c.Param("label"), cfg)
+	hs.respondAgentConfigUpdate(c, svc, revision, err)
+}
+
+// handleDeleteLabelAgentConfig 删除标签配置
+func (hs *HTTPServer) handleDeleteLabelAgentConfig(c *gin.Context) {
+	svc := hs.svc.(*services.DomclusterServer)
+	revision, err := svc.GetAgentConfig().DeleteLabelConfig(c.Param("label"))
+	hs.respondAgentConfigUpdate(c, svc, revision, err)
+}
+
+// handleSetNodeAgentConfig 设置节点配置
+func (hs *HTTPServer) handleSetNodeAgentConfig(c *gin.Context) {
+	var cfg agentconfig.AgentConfig
+	if err := c.ShouldBindJSON(&cfg); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request"})
+		return
+	}
+
+	svc := hs.svc.(*services.DomclusterServer)
+	revision, err := svc.GetAgentConfig().SetNodeConfig(c.Param("nodeId"), cfg)
+	hs.respondAgentConfigUpdate(c, svc, revision, err)
+}
+
+// handleDeleteNodeAgentConfig 删除节点配置
+func (hs *HTTPServer) handleDeleteNodeAgentConfig(c *gin.Context) {
+	svc := hs.svc.(*services.DomclusterServer)
+	revision, err := svc.GetAgentConfig().DeleteNodeConfig(c.Param("nodeId"))
+	hs.respondAgentConfigUpdate(c, svc, revision, err)
+}
+
+// handleGetEffectiveAgentConfig 获取节点的生效配置及同步状态
+func (hs *HTTPServer) handleGetEffectiveAgentConfig(c *gin.Context) {
+	svc := hs.svc.(*services.DomclusterServer)
+	nodeID := c.Param("nodeId")
+	labels := svc.NodeLabels(nodeID)
+
+	cfg, revision := svc.GetAgentConfig().Effective(nodeID, labels)
+	c.JSON(http.StatusOK, gin.H{
+		"node_id":  nodeID,
+		"labels":   labels,
+		"revision": revision,
+		"config":   cfg,
+		"state":    svc.GetAgentConfig().NodeState(nodeID, labels),
+	})
+}
+
+// handleAgentConfigDrift 列出所有节点的配置同步状态
+func (hs *HTTPServer) handleAgentConfigDrift(c *gin.Context) {
+	svc := hs.svc.(*services.DomclusterServer)
+	store := svc.GetAgentConfig()
+
+	states := make([]agentconfig.NodeConfigState, 0)
+	drifted := 0
+	for nodeID := range svc.GetNodeManager().ListNodes() {
+		state := store.NodeState(nodeID, svc.NodeLabels(nodeID))
+		if !state.InSync {
+			drifted++
+		}
+		states = append(states, state)
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"revision": store.Revision(),
+		"drifted":  drifted,
+		"nodes":    states,
+	})
+}
+
+// handleSetNodeLabels 设置控制端分配的节点标签
+func (hs *HTTPServer) handleSetNodeLabels(c *gin.Context) {
+	var labels map[string]string
+	if err := c.ShouldBindJSON(&labels); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request"})
+		return
+	}
+
+	svc := hs.svc.(*services.DomclusterServer)
+	revision, err := svc.GetAgentConfig().SetNodeLabels(c.Param("nodeId"), labels)
+	hs.respondAgentConfigUpdate(c, svc, revision, err)
+}
+
+// respondAgentConfigUpdate 配置修改后下发到所有节点并返回新的修订号
+func (hs *HTTPServer) respondAgentConfigUpdate(c *gin.Context, svc *services.DomclusterServer, revision uint64, err error) {
+	if err != nil {
+		var validationErr *agentconfig.ValidationError
+		if errors.As(err, &validationErr) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		}
+		return
+	}
+
+	go svc.PushAgentConfigAll()
+
+	c.JSON(http.StatusOK, gin.H{
+		"success":  true,
+		"revision": revision,
+	})
+}
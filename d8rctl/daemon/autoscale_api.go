@@ -0,0 +1,133 @@
+package daemon
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"d8rctl/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// AutoscalePinRequest 固定 judgehost 数量的请求
+type AutoscalePinRequest struct {
+	Stack string `json:"stack"`
+	// Size 为空时固定为当前运行的数量
+	Size *int `json:"size,omitempty"`
+}
+
+// handleListAutoscale 列出全部自动扩缩容策略
+func (hs *HTTPServer) handleListAutoscale(c *gin.Context) {
+	serveAutoscaleList(c.Writer, hs.svc.(*services.DomclusterServer))
+}
+
+// handleGetAutoscale 查询栈的自动扩缩容状态和事件
+func (hs *HTTPServer) handleGetAutoscale(c *gin.Context) {
+	serveAutoscaleStatus(c.Writer, hs.svc.(*services.DomclusterServer), c.Param("stack"))
+}
+
+// handleSetAutoscale 创建或修改栈的自动扩缩容策略
+func (hs *HTTPServer) handleSetAutoscale(c *gin.Context) {
+	serveAutoscaleSet(c.Writer, c.Request, hs.svc.(*services.DomclusterServer), c.Param("stack"))
+}
+
+// handleDeleteAutoscale 删除栈的自动扩缩容策略
+func (hs *HTTPServer) handleDeleteAutoscale(c *gin.Context) {
+	serveAutoscaleDelete(c.Writer, hs.svc.(*services.DomclusterServer), c.Param("stack"))
+}
+
+// handlePinAutoscale 固定栈运行的 judgehost 数量
+func (hs *HTTPServer) handlePinAutoscale(c *gin.Context) {
+	serveAutoscalePin(c.Writer, c.Request, hs.svc.(*services.DomclusterServer), c.Param("stack"))
+}
+
+// handleUnpinAutoscale 取消固定数量
+func (hs *HTTPServer) handleUnpinAutoscale(c *gin.Context) {
+	serveAutoscaleUnpin(c.Writer, hs.svc.(*services.DomclusterServer), c.Param("stack"))
+}
+
+// serveAutoscaleList 列出策略，HTTP API 与 CLI 共用
+func serveAutoscaleList(w http.ResponseWriter, svc *services.DomclusterServer) {
+	writeJSON(w, http.StatusOK, map[string]interface{}{"policies": svc.ListAutoscaleStatus()})
+}
+
+// serveAutoscaleStatus 返回栈的自动扩缩容状态，HTTP API 与 CLI 共用
+func serveAutoscaleStatus(w http.ResponseWriter, svc *services.DomclusterServer, stack string) {
+	status, err := svc.GetAutoscaleStatus(stack)
+	if err != nil {
+		writeError(w, autoscaleErrorStatus(err), err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, status)
+}
+
+// serveAutoscaleSet 保存策略，stack 为空时从请求体读取，HTTP API 与 CLI 共用
+func serveAutoscaleSet(w http.ResponseWriter, r *http.Request, svc *services.DomclusterServer, stack string) {
+	var policy services.AutoscalePolicy
+	if err := json.NewDecoder(r.Body).Decode(&policy); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid request: "+err.Error())
+		return
+	}
+	if stack != "" {
+		policy.Stack = stack
+	}
+
+	status, err := svc.SetAutoscalePolicy(policy)
+	if err != nil {
+		writeError(w, autoscaleErrorStatus(err), err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, status)
+}
+
+// serveAutoscaleDelete 删除策略，HTTP API 与 CLI 共用
+func serveAutoscaleDelete(w http.ResponseWriter, svc *services.DomclusterServer, stack string) {
+	if err := svc.RemoveAutoscalePolicy(stack); err != nil {
+		writeError(w, autoscaleErrorStatus(err), err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"success": true})
+}
+
+// serveAutoscalePin 固定数量，stack 为空时从请求体读取，HTTP API 与 CLI 共用
+func serveAutoscalePin(w http.ResponseWriter, r *http.Request, svc *services.DomclusterServer, stack string) {
+	var req AutoscalePinRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid request: "+err.Error())
+		return
+	}
+	if stack != "" {
+		req.Stack = stack
+	}
+
+	status, err := svc.PinAutoscale(req.Stack, req.Size)
+	if err != nil {
+		writeError(w, autoscaleErrorStatus(err), err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, status)
+}
+
+// serveAutoscaleUnpin 取消固定数量，HTTP API 与 CLI 共用
+func serveAutoscaleUnpin(w http.ResponseWriter, svc *services.DomclusterServer, stack string) {
+	status, err := svc.UnpinAutoscale(stack)
+	if err != nil {
+		writeError(w, autoscaleErrorStatus(err), err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, status)
+}
+
+// autoscaleErrorStatus 根据错误选择状态码
+func autoscaleErrorStatus(err error) int {
+	msg := err.Error()
+	switch {
+	case strings.Contains(msg, "not found"):
+		return http.StatusNotFound
+	case strings.Contains(msg, "invalid"), strings.Contains(msg, "must"), strings.Contains(msg, "unknown"):
+		return http.StatusBadRequest
+	default:
+		return http.StatusInternalServerError
+	}
+}
@@ -7,6 +7,8 @@ import (
 	"net"
 	"net/http"
 	"os"
+	"strconv"
+	"strings"
 	"time"
 
 	"d8rctl/services"
@@ -34,6 +36,26 @@ func NewCLIServer(svc *services.DomclusterServer) *CLIServer {
 	mux.HandleFunc("/stop", hs.handleStop)
 	mux.HandleFunc("/restart", hs.handleRestart)
 	mux.HandleFunc("/nodes", hs.handleNodes)
+	mux.HandleFunc("/top", hs.handleTop)
+	mux.HandleFunc("/cp", hs.handleCopy)
+	mux.HandleFunc("/images/archives", hs.handleImageArchives)
+	mux.HandleFunc("/images/distributions", hs.handleImageDistributions)
+	mux.HandleFunc("/containers/create", hs.handleContainers)
+	mux.HandleFunc("/containers/recreate", hs.handleContainers)
+	mux.HandleFunc("/containers/remove", hs.handleContainers)
+	mux.HandleFunc("/stacks", hs.handleStacks)
+	mux.HandleFunc("/stacks/deployment", hs.handleStackDeployment)
+	mux.HandleFunc("/judgehosts/provision", hs.handleJudgehostProvision)
+	mux.HandleFunc("/judgehosts/topology", hs.handleNodeTopology)
+	mux.HandleFunc("/domjudge/config", hs.handleDomjudgeConfig)
+	mux.HandleFunc("/domjudge/judgehosts", hs.handleDomjudgeJudgehosts)
+	mux.HandleFunc("/autoscale", hs.handleAutoscale)
+	mux.HandleFunc("/autoscale/pin", hs.handleAutoscalePin)
+	mux.HandleFunc("/rollouts", hs.handleRollouts)
+	mux.HandleFunc("/rollouts/resume", hs.handleRolloutAction)
+	mux.HandleFunc("/rollouts/rollback", hs.handleRolloutAction)
+	mux.HandleFunc("/desired", hs.handleDesired)
+	mux.HandleFunc("/desired/sync", hs.handleDesiredSync)
 
 	hs.server = &http.Server{
 		Handler:      mux,
@@ -154,6 +176,302 @@ func (cs *CLIServer) handleNodes(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(result)
 }
 
+// handleTop 处理节点进程排行请求
+func (cs *CLIServer) handleTop(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	if cs.svc == nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]interface{}{"error": "service not available"})
+		return
+	}
+
+	nodeID := r.URL.Query().Get("node")
+	if !cs.svc.IsNodeConnected(nodeID) {
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(map[string]interface{}{"error": fmt.Sprintf("node %s not connected", nodeID)})
+		return
+	}
+
+	limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
+
+	// CLI 服务器的写超时为 10 秒，查询需在此之前返回
+	ctx, cancel := context.WithTimeout(r.Context(), 8*time.Second)
+	defer cancel()
+
+	top, err := cs.svc.QueryTopProcesses(ctx, nodeID, r.URL.Query().Get("sort"), limit)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]interface{}{"error": err.Error()})
+		return
+	}
+
+	json.NewEncoder(w).Encode(top)
+}
+
+// handleCopy 处理文件复制请求，PUT 上传到节点，GET 从节点下载
+func (cs *CLIServer) handleCopy(w http.ResponseWriter, r *http.Request) {
+	if cs.svc == nil {
+		writeError(w, http.StatusInternalServerError, "service not available")
+		return
+	}
+
+	target := fileTargetFromRequest(r, r.URL.Query().Get("node"))
+	switch r.Method {
+	case http.MethodPut:
+		serveFileUpload(w, r, cs.svc, target)
+	case http.MethodGet:
+		serveFileDownload(w, r, cs.svc, target)
+	default:
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+	}
+}
+
+// handleImageArchives 处理镜像包请求，PUT 上传 docker save 格式的 tar，POST 从本机 Docker 导出
+func (cs *CLIServer) handleImageArchives(w http.ResponseWriter, r *http.Request) {
+	if cs.svc == nil {
+		writeError(w, http.StatusInternalServerError, "service not available")
+		return
+	}
+
+	switch r.Method {
+	case http.MethodPut:
+		serveImageArchiveUpload(w, r, cs.svc)
+	case http.MethodPost:
+		serveImageArchiveSave(w, r, cs.svc)
+	default:
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+	}
+}
+
+// handleImageDistributions 处理镜像分发请求，POST 开始分发，GET 按 id 查询进度
+func (cs *CLIServer) handleImageDistributions(w http.ResponseWriter, r *http.Request) {
+	if cs.svc == nil {
+		writeError(w, http.StatusInternalServerError, "service not available")
+		return
+	}
+
+	switch r.Method {
+	case http.MethodPost:
+		serveImageDistributionStart(w, r, cs.svc)
+	case http.MethodGet:
+		serveImageDistribution(w, cs.svc, r.URL.Query().Get("id"))
+	default:
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+	}
+}
+
+// handleContainers 处理容器的创建、重建和删除请求
+func (cs *CLIServer) handleContainers(w http.ResponseWriter, r *http.Request) {
+	if cs.svc == nil {
+		writeError(w, http.StatusInternalServerError, "service not available")
+		return
+	}
+	if r.Method != http.MethodPost {
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+
+	switch r.URL.Path {
+	case "/containers/create":
+		serveContainerCreate(w, r, cs.svc, false)
+	case "/containers/recreate":
+		serveContainerCreate(w, r, cs.svc, true)
+	case "/containers/remove":
+		serveContainerRemove(w, r, cs.svc)
+	default:
+		writeError(w, http.StatusNotFound, "not found")
+	}
+}
+
+// handleStacks 处理栈请求，POST 部署，GET 查询状态（不带 name 时列出全部），DELETE 删除
+func (cs *CLIServer) handleStacks(w http.ResponseWriter, r *http.Request) {
+	if cs.svc == nil {
+		writeError(w, http.StatusInternalServerError, "service not available")
+		return
+	}
+
+	name := r.URL.Query().Get("name")
+	switch r.Method {
+	case http.MethodPost:
+		serveStackUp(w, r, cs.svc)
+	case http.MethodGet:
+		if name == "" {
+			serveStackList(w, cs.svc)
+			return
+		}
+		serveStackStatus(w, cs.svc, name)
+	case http.MethodDelete:
+		serveStackDown(w, cs.svc, name)
+	default:
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+	}
+}
+
+// handleStackDeployment 查询栈最近一次操作的进度
+func (cs *CLIServer) handleStackDeployment(w http.ResponseWriter, r *http.Request) {
+	if cs.svc == nil {
+		writeError(w, http.StatusInternalServerError, "service not available")
+		return
+	}
+	serveStackDeployment(w, cs.svc, r.URL.Query().Get("name"))
+}
+
+// handleJudgehostProvision 开始在节点上按核心部署 judgehost
+func (cs *CLIServer) handleJudgehostProvision(w http.ResponseWriter, r *http.Request) {
+	if cs.svc == nil {
+		writeError(w, http.StatusInternalServerError, "service not available")
+		return
+	}
+	if r.Method != http.MethodPost {
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+	serveJudgehostProvision(w, r, cs.svc, "")
+}
+
+// handleNodeTopology 返回节点的 CPU 拓扑
+func (cs *CLIServer) handleNodeTopology(w http.ResponseWriter, r *http.Request) {
+	if cs.svc == nil {
+		writeError(w, http.StatusInternalServerError, "service not available")
+		return
+	}
+	serveNodeTopology(w, cs.svc, r.URL.Query().Get("node"))
+}
+
+// handleDomjudgeConfig 查询或修改 DomJudge 集成配置
+func (cs *CLIServer) handleDomjudgeConfig(w http.ResponseWriter, r *http.Request) {
+	if cs.svc == nil {
+		writeError(w, http.StatusInternalServerError, "service not available")
+		return
+	}
+	serveDomjudgeConfig(w, r, cs.svc)
+}
+
+// handleDomjudgeJudgehosts GET 列出 judgehost，PUT 启用或禁用 judgehost
+func (cs *CLIServer) handleDomjudgeJudgehosts(w http.ResponseWriter, r *http.Request) {
+	if cs.svc == nil {
+		writeError(w, http.StatusInternalServerError, "service not available")
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		serveJudgehostList(w, r, cs.svc)
+	case http.MethodPut:
+		serveJudgehostEnable(w, r, cs.svc, "")
+	default:
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+	}
+}
+
+// handleAutoscale GET 查询状态（不带 stack 时列出全部），PUT 保存策略，DELETE 删除策略
+func (cs *CLIServer) handleAutoscale(w http.ResponseWriter, r *http.Request) {
+	if cs.svc == nil {
+		writeError(w, http.StatusInternalServerError, "service not available")
+		return
+	}
+
+	stack := r.URL.Query().Get("stack")
+	switch r.Method {
+	case http.MethodGet:
+		if stack == "" {
+			serveAutoscaleList(w, cs.svc)
+			return
+		}
+		serveAutoscaleStatus(w, cs.svc, stack)
+	case http.MethodPut:
+		serveAutoscaleSet(w, r, cs.svc, "")
+	case http.MethodDelete:
+		serveAutoscaleDelete(w, cs.svc, stack)
+	default:
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+	}
+}
+
+// handleAutoscalePin POST 固定 judgehost 数量，DELETE 取消固定
+func (cs *CLIServer) handleAutoscalePin(w http.ResponseWriter, r *http.Request) {
+	if cs.svc == nil {
+		writeError(w, http.StatusInternalServerError, "service not available")
+		return
+	}
+
+	switch r.Method {
+	case http.MethodPost:
+		serveAutoscalePin(w, r, cs.svc, "")
+	case http.MethodDelete:
+		serveAutoscaleUnpin(w, cs.svc, r.URL.Query().Get("stack"))
+	default:
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+	}
+}
+
+// handleRollouts POST 开始 rollout，GET 查询状态
+func (cs *CLIServer) handleRollouts(w http.ResponseWriter, r *http.Request) {
+	if cs.svc == nil {
+		writeError(w, http.StatusInternalServerError, "service not available")
+		return
+	}
+
+	switch r.Method {
+	case http.MethodPost:
+		serveRolloutStart(w, r, cs.svc, "")
+	case http.MethodGet:
+		serveRolloutStatus(w, cs.svc, r.URL.Query().Get("stack"))
+	default:
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+	}
+}
+
+// handleRolloutAction 继续或回滚已暂停的 rollout
+func (cs *CLIServer) handleRolloutAction(w http.ResponseWriter, r *http.Request) {
+	if cs.svc == nil {
+		writeError(w, http.StatusInternalServerError, "service not available")
+		return
+	}
+	if r.Method != http.MethodPost {
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+	action := strings.TrimPrefix(r.URL.Path, "/rollouts/")
+	serveRolloutAction(w, cs.svc, r.URL.Query().Get("stack"), action)
+}
+
+// handleDesired GET 查询期望状态（指定 node 时返回该节点），PUT 替换节点的期望容器
+func (cs *CLIServer) handleDesired(w http.ResponseWriter, r *http.Request) {
+	if cs.svc == nil {
+		writeError(w, http.StatusInternalServerError, "service not available")
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		node := r.URL.Query().Get("node")
+		if node == "" {
+			serveDesiredList(w, cs.svc)
+			return
+		}
+		serveDesiredStatus(w, r, cs.svc, node)
+	case http.MethodPut:
+		serveDesiredSet(w, r, cs.svc, "")
+	default:
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+	}
+}
+
+// handleDesiredSync 将栈同步到各节点的期望状态
+func (cs *CLIServer) handleDesiredSync(w http.ResponseWriter, r *http.Request) {
+	if cs.svc == nil {
+		writeError(w, http.StatusInternalServerError, "service not available")
+		return
+	}
+	if r.Method != http.MethodPost {
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+	serveDesiredSync(w, r, cs.svc, "")
+}
+
 // GetCLISocketPath 获取 CLI socket 路径
 func GetCLISocketPath() string {
 	return cliSocketPath
@@ -0,0 +1,136 @@
+package daemon
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+
+	"d8rctl/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// containerCreateTimeout 创建和重建容器的超时时间，节点可能需要先拉取镜像
+const containerCreateTimeout = 30 * time.Minute
+
+// ContainerCreateRequest 创建或重建容器的请求
+type ContainerCreateRequest struct {
+	NodeID string                  `json:"node_id"`
+	Spec   *services.ContainerSpec `json:"spec"`
+	// Start 创建后启动，仅用于 create
+	Start bool `json:"start"`
+	// NoStart 重建后不启动，仅用于 recreate
+	NoStart       bool `json:"no_start"`
+	StopTimeout   int  `json:"stop_timeout"`
+	OnlyIfChanged bool `json:"only_if_changed"`
+}
+
+// ContainerRemoveRequest 删除容器的请求
+type ContainerRemoveRequest struct {
+	NodeID      string `json:"node_id"`
+	ContainerID string `json:"container_id"`
+	Force       bool   `json:"force"`
+	Volumes     bool   `json:"volumes"`
+}
+
+// handleDockerCreate 按声明在节点上创建容器
+func (hs *HTTPServer) handleDockerCreate(c *gin.Context) {
+	serveContainerCreate(c.Writer, c.Request, hs.svc.(*services.DomclusterServer), false)
+}
+
+// handleDockerRecreate 按声明重建节点上的同名容器，不存在时直接创建
+func (hs *HTTPServer) handleDockerRecreate(c *gin.Context) {
+	serveContainerCreate(c.Writer, c.Request, hs.svc.(*services.DomclusterServer), true)
+}
+
+// handleDockerRemove 删除节点上的容器
+func (hs *HTTPServer) handleDockerRemove(c *gin.Context) {
+	serveContainerRemove(c.Writer, c.Request, hs.svc.(*services.DomclusterServer))
+}
+
+// serveContainerCreate 创建或重建容器，HTTP API 与 CLI 共用
+func serveContainerCreate(w http.ResponseWriter, r *http.Request, svc *services.DomclusterServer, recreate bool) {
+	var req ContainerCreateRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid request: "+err.Error())
+		return
+	}
+	if req.NodeID == "" || req.Spec == nil {
+		writeError(w, http.StatusBadRequest, "node_id and spec are required")
+		return
+	}
+	if !svc.IsNodeConnected(req.NodeID) {
+		writeError(w, http.StatusNotFound, "node not connected")
+		return
+	}
+
+	extendDeadlines(w)
+	ctx, cancel := context.WithTimeout(r.Context(), containerCreateTimeout)
+	defer cancel()
+
+	var result *services.ContainerResult
+	var err error
+	if recreate {
+		result, err = svc.RecreateContainer(ctx, req.NodeID, req.Spec, services.RecreateOptions{
+			NoStart:       req.NoStart,
+			StopTimeout:   req.StopTimeout,
+			OnlyIfChanged: req.OnlyIfChanged,
+		})
+	} else {
+		result, err = svc.CreateContainer(ctx, req.NodeID, req.Spec, req.Start)
+	}
+	if err != nil {
+		writeContainerError(w, ctx, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, result)
+}
+
+// serveContainerRemove 删除容器，HTTP API 与 CLI 共用
+func serveContainerRemove(w http.ResponseWriter, r *http.Request, svc *services.DomclusterServer) {
+	var req ContainerRemoveRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid request: "+err.Error())
+		return
+	}
+	if req.NodeID == "" || req.ContainerID == "" {
+		writeError(w, http.StatusBadRequest, "node_id and container_id are required")
+		return
+	}
+	if !svc.IsNodeConnected(req.NodeID) {
+		writeError(w, http.StatusNotFound, "node not connected")
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), DefaultRequestTimeout)
+	defer cancel()
+
+	result, err := svc.RemoveContainer(ctx, req.NodeID, req.ContainerID, req.Force, req.Volumes)
+	if err != nil {
+		writeContainerError(w, ctx, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, result)
+}
+
+// writeContainerError 根据节点返回的错误选择状态码
+func writeContainerError(w http.ResponseWriter, ctx context.Context, err error) {
+	msg := err.Error()
+	lower := strings.ToLower(msg)
+	switch {
+	case ctx.Err() == context.DeadlineExceeded:
+		writeError(w, http.StatusGatewayTimeout, "request timeout")
+	case strings.Contains(lower, "no such container"), strings.Contains(lower, "no such image"),
+		strings.Contains(lower, "not present on this node"):
+		writeError(w, http.StatusNotFound, msg)
+	case strings.Contains(lower, "conflict"), strings.Contains(lower, "already in use"):
+		writeError(w, http.StatusConflict, msg)
+	case strings.Contains(lower, "invalid"), strings.Contains(lower, "is required"),
+		strings.Contains(lower, "must be"), strings.Contains(lower, "only valid"):
+		writeError(w, http.StatusBadRequest, msg)
+	default:
+		writeError(w, http.StatusInternalServerError, msg)
+	}
+}
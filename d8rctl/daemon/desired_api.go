@@ -0,0 +1,120 @@
+package daemon
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+
+	"d8rctl/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// desiredQueryTimeout 向节点查询最新收敛结果的超时时间
+const desiredQueryTimeout = 10 * time.Second
+
+// DesiredSetRequest 替换节点期望状态中一个来源的容器
+type DesiredSetRequest struct {
+	Node string `json:"node"`
+	// Source 为空时为 manual
+	Source     string                      `json:"source,omitempty"`
+	Containers []services.DesiredContainer `json:"containers"`
+}
+
+// DesiredSyncRequest 将栈同步到节点期望状态的请求
+type DesiredSyncRequest struct {
+	Stack string `json:"stack"`
+}
+
+// handleListDesired 列出各节点的期望状态和收敛情况
+func (hs *HTTPServer) handleListDesired(c *gin.Context) {
+	serveDesiredList(c.Writer, hs.svc.(*services.DomclusterServer))
+}
+
+// handleGetDesired 查询节点的期望状态，live=true 时向节点获取最新的收敛结果
+func (hs *HTTPServer) handleGetDesired(c *gin.Context) {
+	serveDesiredStatus(c.Writer, c.Request, hs.svc.(*services.DomclusterServer), c.Param("nodeId"))
+}
+
+// handleSetDesired 替换节点期望状态中一个来源的容器
+func (hs *HTTPServer) handleSetDesired(c *gin.Context) {
+	serveDesiredSet(c.Writer, c.Request, hs.svc.(*services.DomclusterServer), c.Param("nodeId"))
+}
+
+// handleSyncStackDesired 将栈同步到各节点的期望状态
+func (hs *HTTPServer) handleSyncStackDesired(c *gin.Context) {
+	serveDesiredSync(c.Writer, c.Request, hs.svc.(*services.DomclusterServer), c.Param("name"))
+}
+
+// serveDesiredList 列出期望状态，HTTP API 与 CLI 共用
+func serveDesiredList(w http.ResponseWriter, svc *services.DomclusterServer) {
+	writeJSON(w, http.StatusOK, map[string]interface{}{"nodes": svc.ListDesiredStatus()})
+}
+
+// serveDesiredStatus 返回节点的期望状态和收敛情况，HTTP API 与 CLI 共用
+func serveDesiredStatus(w http.ResponseWriter, r *http.Request, svc *services.DomclusterServer, node string) {
+	ctx, cancel := context.WithTimeout(r.Context(), desiredQueryTimeout)
+	defer cancel()
+
+	status, err := svc.GetDesiredStatus(ctx, node, r.URL.Query().Get("live") == "true")
+	if err != nil {
+		writeError(w, desiredErrorStatus(err), err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, status)
+}
+
+// serveDesiredSet 替换期望容器，node 为空时从请求体读取，HTTP API 与 CLI 共用
+func serveDesiredSet(w http.ResponseWriter, r *http.Request, svc *services.DomclusterServer, node string) {
+	var req DesiredSetRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid request: "+err.Error())
+		return
+	}
+	if node != "" {
+		req.Node = node
+	}
+
+	state, err := svc.SetNodeDesired(req.Node, req.Source, req.Containers)
+	if err != nil {
+		writeError(w, desiredErrorStatus(err), err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, state)
+}
+
+// serveDesiredSync 同步栈，stack 为空时从请求体读取，HTTP API 与 CLI 共用
+func serveDesiredSync(w http.ResponseWriter, r *http.Request, svc *services.DomclusterServer, stack string) {
+	if stack == "" {
+		var req DesiredSyncRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeError(w, http.StatusBadRequest, "invalid request: "+err.Error())
+			return
+		}
+		stack = req.Stack
+	}
+
+	result, err := svc.SyncStackDesired(stack)
+	if err != nil {
+		writeError(w, desiredErrorStatus(err), err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, result)
+}
+
+// desiredErrorStatus 根据错误选择状态码
+func desiredErrorStatus(err error) int {
+	msg := err.Error()
+	switch {
+	case strings.Contains(msg, "not found"):
+		return http.StatusNotFound
+	case strings.Contains(msg, "not connected"):
+		return http.StatusServiceUnavailable
+	case strings.Contains(msg, "invalid"), strings.Contains(msg, "required"), strings.Contains(msg, "already desired"):
+		return http.StatusBadRequest
+	default:
+		return http.StatusInternalServerError
+	}
+}
@@ -0,0 +1,177 @@
+package daemon
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+
+	"d8rctl/services"
+	"d8rctl/services/domjudge"
+	"d8rctl/services/monitor"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// domjudgeRequestTimeout 请求 DomJudge 的超时时间，需小于 CLI 服务器的 10 秒写超时
+const domjudgeRequestTimeout = 8 * time.Second
+
+// nodeStatusResponse 节点状态，附带节点上 judgehost 在 DomJudge 中的状态
+type nodeStatusResponse struct {
+	*monitor.NodeStatus
+	Domjudge      []services.JudgehostStatus `json:"domjudge,omitempty"`
+	DomjudgeError string                     `json:"domjudge_error,omitempty"`
+}
+
+// nodeJudgehostSummary 节点列表中每个节点的 judgehost 概况
+type nodeJudgehostSummary struct {
+	Judgehosts int `json:"judgehosts"`
+	Enabled    int `json:"enabled"`
+	Judging    int `json:"judging"`
+	// LastPoll 节点上最近一次轮询距今的秒数
+	LastPoll float64 `json:"last_poll,omitempty"`
+}
+
+// JudgehostEnableRequest 启用或禁用 judgehost 的请求
+type JudgehostEnableRequest struct {
+	// Target DomJudge 中的 judgehost 名字，或节点 ID（作用于节点上的全部 judgehost）
+	Target  string `json:"target"`
+	Enabled bool   `json:"enabled"`
+}
+
+// domjudgeNodeSummaries 按节点汇总 judgehost，未配置 DomJudge 或查询失败时返回空
+func domjudgeNodeSummaries(ctx context.Context, svc *services.DomclusterServer) map[string]*nodeJudgehostSummary {
+	result := make(map[string]*nodeJudgehostSummary)
+	if svc.GetDomjudgeConfig() == nil {
+		return result
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, domjudgeRequestTimeout)
+	defer cancel()
+	hosts, err := svc.ListJudgehosts(ctx, false)
+	if err != nil {
+		zap.L().Sugar().Debugf("Failed to query DomJudge judgehosts: %v", err)
+		return result
+	}
+
+	for _, h := range hosts {
+		if h.NodeID == "" {
+			continue
+		}
+		sum, ok := result[h.NodeID]
+		if !ok {
+			sum = &nodeJudgehostSummary{}
+			result[h.NodeID] = sum
+		}
+		sum.Judgehosts++
+		if h.Enabled {
+			sum.Enabled++
+		}
+		if h.CurrentJudging != nil {
+			sum.Judging++
+		}
+		if h.Polltime != nil && (sum.LastPoll == 0 || h.PollAge < sum.LastPoll) {
+			sum.LastPoll = h.PollAge
+		}
+	}
+	return result
+}
+
+// handleGetDomjudgeConfig 返回 DomJudge 集成配置
+func (hs *HTTPServer) handleGetDomjudgeConfig(c *gin.Context) {
+	serveDomjudgeConfig(c.Writer, c.Request, hs.svc.(*services.DomclusterServer))
+}
+
+// handleSetDomjudgeConfig 修改 DomJudge 集成配置
+func (hs *HTTPServer) handleSetDomjudgeConfig(c *gin.Context) {
+	serveDomjudgeConfig(c.Writer, c.Request, hs.svc.(*services.DomclusterServer))
+}
+
+// handleListJudgehosts 列出 DomJudge 中的 judgehost
+func (hs *HTTPServer) handleListJudgehosts(c *gin.Context) {
+	serveJudgehostList(c.Writer, c.Request, hs.svc.(*services.DomclusterServer))
+}
+
+// handleSetJudgehostEnabled 在 DomJudge 中启用或禁用 judgehost，路径参数可以是 judgehost 名字或节点 ID
+func (hs *HTTPServer) handleSetJudgehostEnabled(c *gin.Context) {
+	serveJudgehostEnable(c.Writer, c.Request, hs.svc.(*services.DomclusterServer), c.Param("target"))
+}
+
+// serveDomjudgeConfig GET 返回配置，PUT 保存配置，HTTP API 与 CLI 共用
+func serveDomjudgeConfig(w http.ResponseWriter, r *http.Request, svc *services.DomclusterServer) {
+	switch r.Method {
+	case http.MethodGet:
+		writeJSON(w, http.StatusOK, map[string]interface{}{"config": svc.GetDomjudgeConfig()})
+	case http.MethodPut:
+		var cfg domjudge.Config
+		if err := json.NewDecoder(r.Body).Decode(&cfg); err != nil {
+			writeError(w, http.StatusBadRequest, "invalid request: "+err.Error())
+			return
+		}
+		if err := svc.SetDomjudgeConfig(cfg); err != nil {
+			writeError(w, domjudgeErrorStatus(err), err.Error())
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]interface{}{"config": svc.GetDomjudgeConfig()})
+	default:
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+	}
+}
+
+// serveJudgehostList 列出 judgehost，?fresh=true 时跳过缓存，HTTP API 与 CLI 共用
+func serveJudgehostList(w http.ResponseWriter, r *http.Request, svc *services.DomclusterServer) {
+	ctx, cancel := context.WithTimeout(r.Context(), domjudgeRequestTimeout)
+	defer cancel()
+
+	hosts, err := svc.ListJudgehosts(ctx, r.URL.Query().Get("fresh") == "true")
+	if err != nil {
+		writeError(w, domjudgeErrorStatus(err), err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"judgehosts": hosts})
+}
+
+// serveJudgehostEnable 启用或禁用 judgehost，target 为空时从请求体读取，HTTP API 与 CLI 共用
+func serveJudgehostEnable(w http.ResponseWriter, r *http.Request, svc *services.DomclusterServer, target string) {
+	var req JudgehostEnableRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid request: "+err.Error())
+		return
+	}
+	if target != "" {
+		req.Target = target
+	}
+	if req.Target == "" {
+		writeError(w, http.StatusBadRequest, "target is required")
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), domjudgeRequestTimeout)
+	defer cancel()
+
+	hosts, err := svc.SetJudgehostEnabled(ctx, req.Target, req.Enabled)
+	if err != nil {
+		writeError(w, domjudgeErrorStatus(err), err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"judgehosts": hosts})
+}
+
+// domjudgeErrorStatus 根据错误选择状态码，domserver 返回的错误以 502 转告
+func domjudgeErrorStatus(err error) int {
+	msg := err.Error()
+	switch {
+	case strings.Contains(msg, "not configured"):
+		return http.StatusConflict
+	case strings.Contains(msg, "not found in DomJudge"):
+		return http.StatusNotFound
+	case strings.Contains(msg, "invalid"), strings.Contains(msg, "required"), strings.Contains(msg, "must"):
+		return http.StatusBadRequest
+	case strings.Contains(msg, "domjudge"):
+		return http.StatusBadGateway
+	default:
+		return http.StatusInternalServerError
+	}
+}
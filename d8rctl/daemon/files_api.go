@@ -0,0 +1,250 @@
+package daemon
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"d8rctl/config"
+	"d8rctl/services"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// fileTransferTimeout 单次文件上传或下载请求的最长时间
+const fileTransferTimeout = 2 * time.Hour
+
+// handleFileStat 查询节点或容器内文件的信息
+func (hs *HTTPServer) handleFileStat(c *gin.Context) {
+	domclusterServer := hs.svc.(*services.DomclusterServer)
+	serveFileStat(c.Writer, c.Request, domclusterServer, fileTargetFromRequest(c.Request, c.Param("nodeId")))
+}
+
+// handleFileUpload 上传文件到节点或容器
+//
+// 请求体为文件内容；查询参数 path（目标绝对路径）、container（可选，容器 ID 或名称）、
+// mode（八进制权限，默认 0644）、max_size（字节数，只能收紧节点配置的上限）。
+func (hs *HTTPServer) handleFileUpload(c *gin.Context) {
+	domclusterServer := hs.svc.(*services.DomclusterServer)
+	serveFileUpload(c.Writer, c.Request, domclusterServer, fileTargetFromRequest(c.Request, c.Param("nodeId")))
+}
+
+// handleFileDownload 从节点或容器下载文件
+//
+// 查询参数与上传相同；支持 offset 参数或 Range: bytes=N- 请求头从断点继续，
+// 响应头 X-Content-Sha256 为完整文件的 SHA-256，可用于续传后校验。
+func (hs *HTTPServer) handleFileDownload(c *gin.Context) {
+	domclusterServer := hs.svc.(*services.DomclusterServer)
+	serveFileDownload(c.Writer, c.Request, domclusterServer, fileTargetFromRequest(c.Request, c.Param("nodeId")))
+}
+
+// fileTargetFromRequest 从查询参数解析传输目标
+func fileTargetFromRequest(r *http.Request, nodeID string) services.FileTarget {
+	return services.FileTarget{
+		NodeID:      nodeID,
+		ContainerID: r.URL.Query().Get("container"),
+		Path:        r.URL.Query().Get("path"),
+	}
+}
+
+// serveFileStat 文件信息查询，HTTP API 与 CLI 共用
+func serveFileStat(w http.ResponseWriter, r *http.Request, svc *services.DomclusterServer, target services.FileTarget) {
+	if !checkFileTarget(w, svc, target) {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), DefaultRequestTimeout)
+	defer cancel()
+
+	info, err := svc.StatFile(ctx, target)
+	if err != nil {
+		writeError(w, http.StatusBadGateway, err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, info)
+}
+
+// serveFileUpload 文件上传，HTTP API 与 CLI 共用
+//
+// 请求体先完整写入控制端的暂存文件，再分块发送到节点；节点断线重连后从已接收的位置继续。
+func serveFileUpload(w http.ResponseWriter, r *http.Request, svc *services.DomclusterServer, target services.FileTarget) {
+	if !checkFileTarget(w, svc, target) {
+		return
+	}
+	extendDeadlines(w)
+
+	mode := r.URL.Query().Get("mode")
+	if mode != "" {
+		if m, err := strconv.ParseUint(mode, 8, 32); err != nil || m > 0o7777 {
+			writeError(w, http.StatusBadRequest, "mode must be an octal permission such as 0644")
+			return
+		}
+	}
+	maxSize, err := parseMaxSize(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if maxSize > 0 && r.ContentLength > maxSize {
+		writeError(w, http.StatusRequestEntityTooLarge, fmt.Sprintf("file is %d bytes, exceeds limit of %d bytes", r.ContentLength, maxSize))
+		return
+	}
+
+	spoolDir := filepath.Join(config.GetDataDir(), "transfers")
+	if err := os.MkdirAll(spoolDir, 0700); err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	spool, err := os.CreateTemp(spoolDir, "upload-*")
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	defer os.Remove(spool.Name())
+	defer spool.Close()
+
+	body := io.Reader(r.Body)
+	if maxSize > 0 {
+		body = io.LimitReader(r.Body, maxSize+1)
+	}
+	size, err := io.Copy(spool, body)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "failed to read request body: "+err.Error())
+		return
+	}
+	if maxSize > 0 && size > maxSize {
+		writeError(w, http.StatusRequestEntityTooLarge, fmt.Sprintf("file exceeds limit of %d bytes", maxSize))
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), fileTransferTimeout)
+	defer cancel()
+
+	result, err := svc.UploadFile(ctx, target, spool, size, mode, maxSize)
+	if err != nil {
+		zap.L().Sugar().Warnf("Upload to %s failed: %v", target, err)
+		writeError(w, http.StatusBadGateway, err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, result)
+}
+
+// serveFileDownload 文件下载，HTTP API 与 CLI 共用
+func serveFileDownload(w http.ResponseWriter, r *http.Request, svc *services.DomclusterServer, target services.FileTarget) {
+	if !checkFileTarget(w, svc, target) {
+		return
+	}
+	extendDeadlines(w)
+
+	offset, err := parseOffset(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	maxSize, err := parseMaxSize(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), fileTransferTimeout)
+	defer cancel()
+
+	info, err := svc.BeginDownload(ctx, target, maxSize)
+	if err != nil {
+		writeError(w, http.StatusBadGateway, err.Error())
+		return
+	}
+	defer svc.EndDownload(target, info)
+
+	if offset > info.Size {
+		w.Header().Set("Content-Range", fmt.Sprintf("bytes */%d", info.Size))
+		writeError(w, http.StatusRequestedRangeNotSatisfiable, "offset beyond end of file")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/octet-stream")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filepath.Base(target.Path)))
+	w.Header().Set("Content-Length", strconv.FormatInt(info.Size-offset, 10))
+	w.Header().Set("Accept-Ranges", "bytes")
+	w.Header().Set("X-Content-Sha256", info.SHA256)
+	w.Header().Set("X-File-Mode", info.Mode)
+	w.Header().Set("X-File-Size", strconv.FormatInt(info.Size, 10))
+	if offset > 0 {
+		w.Header().Set("Content-Range", fmt.Sprintf("bytes %d-%d/%d", offset, info.Size-1, info.Size))
+		w.WriteHeader(http.StatusPartialContent)
+	} else {
+		w.WriteHeader(http.StatusOK)
+	}
+
+	// 响应头已发出，中途失败时写入的长度少于 Content-Length，连接会被关闭，客户端据此判断并续传
+	if err := svc.DownloadFile(ctx, target, info, offset, w); err != nil {
+		zap.L().Sugar().Warnf("Download from %s failed: %v", target, err)
+	}
+}
+
+// checkFileTarget 检查目标参数和节点连接状态
+func checkFileTarget(w http.ResponseWriter, svc *services.DomclusterServer, target services.FileTarget) bool {
+	if target.Path == "" || !strings.HasPrefix(target.Path, "/") {
+		writeError(w, http.StatusBadRequest, "path must be absolute")
+		return false
+	}
+	if !svc.IsNodeConnected(target.NodeID) {
+		writeError(w, http.StatusNotFound, fmt.Sprintf("node %s not connected", target.NodeID))
+		return false
+	}
+	return true
+}
+
+// extendDeadlines 文件传输耗时可能远超服务器的读写超时，为本次请求单独放宽
+func extendDeadlines(w http.ResponseWriter) {
+	rc := http.NewResponseController(w)
+	deadline := time.Now().Add(fileTransferTimeout)
+	if err := rc.SetReadDeadline(deadline); err != nil {
+		zap.L().Sugar().Debugf("Failed to extend read deadline: %v", err)
+	}
+	if err := rc.SetWriteDeadline(deadline); err != nil {
+		zap.L().Sugar().Debugf("Failed to extend write deadline: %v", err)
+	}
+}
+
+// parseOffset 解析续传位置，支持 offset 参数和 Range: bytes=N- 请求头
+func parseOffset(r *http.Request) (int64, error) {
+	if v := r.URL.Query().Get("offset"); v != "" {
+		offset, err := strconv.ParseInt(v, 10, 64)
+		if err != nil || offset < 0 {
+			return 0, fmt.Errorf("offset must be a non-negative integer")
+		}
+		return offset, nil
+	}
+	if rng := r.Header.Get("Range"); rng != "" {
+		spec, ok := strings.CutPrefix(rng, "bytes=")
+		start, rest, _ := strings.Cut(spec, "-")
+		offset, err := strconv.ParseInt(start, 10, 64)
+		if !ok || rest != "" || err != nil || offset < 0 {
+			return 0, fmt.Errorf("only open-ended ranges (bytes=N-) are supported")
+		}
+		return offset, nil
+	}
+	return 0, nil
+}
+
+// parseMaxSize 解析 max_size 参数，0 表示使用节点配置的上限
+func parseMaxSize(r *http.Request) (int64, error) {
+	v := r.URL.Query().Get("max_size")
+	if v == "" {
+		return 0, nil
+	}
+	maxSize, err := strconv.ParseInt(v, 10, 64)
+	if err != nil || maxSize < 0 {
+		return 0, fmt.Errorf("max_size must be a non-negative integer")
+	}
+	return maxSize, nil
+}
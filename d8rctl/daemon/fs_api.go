@@ -0,0 +1,279 @@
+package daemon
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"d8rctl/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// fsChecksumTimeout 计算大文件校验和的超时时间
+	fsChecksumTimeout = 5 * time.Minute
+	// fsMaxWriteSize 通过文件浏览接口写入的最大字节数，与节点的限制一致
+	fsMaxWriteSize = 1024 * 1024
+)
+
+// handleFSList 列出节点上的目录
+//
+// 查询参数：path、hidden（是否包含隐藏文件）、limit（最多返回的条目数）。
+func (hs *HTTPServer) handleFSList(c *gin.Context) {
+	data := map[string]interface{}{
+		"path":   c.Query("path"),
+		"hidden": c.Query("hidden") == "true",
+	}
+	if limit, err := strconv.Atoi(c.DefaultQuery("limit", "0")); err == nil {
+		data["limit"] = limit
+	}
+	hs.proxyFSQuery(c, "fs_list", data, DefaultRequestTimeout)
+}
+
+// handleFSStat 查询节点上路径的信息
+func (hs *HTTPServer) handleFSStat(c *gin.Context) {
+	hs.proxyFSQuery(c, "fs_stat", map[string]interface{}{"path": c.Query("path")}, DefaultRequestTimeout)
+}
+
+// handleFSRead 按范围读取节点上的文件
+//
+// 查询参数：path、offset、length（最大 1MB）。默认返回 JSON，内容为 UTF-8 文本时
+// encoding 为 utf-8，否则为 base64；raw=true 时直接返回文件内容。
+func (hs *HTTPServer) handleFSRead(c *gin.Context) {
+	offset, err := strconv.ParseInt(c.DefaultQuery("offset", "0"), 10, 64)
+	if err != nil || offset < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "offset must be a non-negative integer"})
+		return
+	}
+	length, err := strconv.Atoi(c.DefaultQuery("length", "0"))
+	if err != nil || length < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "length must be a non-negative integer"})
+		return
+	}
+
+	result, ok := hs.queryFS(c, "fs_read", map[string]interface{}{
+		"path":   c.Query("path"),
+		"offset": offset,
+		"length": length,
+	}, DefaultRequestTimeout)
+	if !ok {
+		return
+	}
+
+	var resp struct {
+		Path   string `json:"path"`
+		Offset int64  `json:"offset"`
+		Size   int64  `json:"size"`
+		Data   []byte `json:"data"`
+		EOF    bool   `json:"eof"`
+	}
+	if err := json.Unmarshal(result, &resp); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "invalid response from node"})
+		return
+	}
+
+	if c.Query("raw") == "true" {
+		c.Header("X-File-Size", strconv.FormatInt(resp.Size, 10))
+		c.Data(http.StatusOK, "application/octet-stream", resp.Data)
+		return
+	}
+
+	content, encoding := encodeContent(resp.Data)
+	c.JSON(http.StatusOK, gin.H{
+		"path":     resp.Path,
+		"offset":   resp.Offset,
+		"size":     resp.Size,
+		"length":   len(resp.Data),
+		"eof":      resp.EOF,
+		"content":  content,
+		"encoding": encoding,
+	})
+}
+
+// handleFSTail 读取节点上文件的最后若干行
+//
+// 查询参数：path、lines（默认 100），最多返回 1MB。
+func (hs *HTTPServer) handleFSTail(c *gin.Context) {
+	lines, err := strconv.Atoi(c.DefaultQuery("lines", "0"))
+	if err != nil || lines < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "lines must be a non-negative integer"})
+		return
+	}
+
+	result, ok := hs.queryFS(c, "fs_tail", map[string]interface{}{
+		"path":  c.Query("path"),
+		"lines": lines,
+	}, DefaultRequestTimeout)
+	if !ok {
+		return
+	}
+
+	var resp struct {
+		Path      string `json:"path"`
+		Size      int64  `json:"size"`
+		Offset    int64  `json:"offset"`
+		Data      []byte `json:"data"`
+		Truncated bool   `json:"truncated"`
+	}
+	if err := json.Unmarshal(result, &resp); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "invalid response from node"})
+		return
+	}
+
+	content, encoding := encodeContent(resp.Data)
+	c.JSON(http.StatusOK, gin.H{
+		"path":      resp.Path,
+		"size":      resp.Size,
+		"offset":    resp.Offset,
+		"truncated": resp.Truncated,
+		"content":   content,
+		"encoding":  encoding,
+	})
+}
+
+// handleFSChecksum 计算节点上文件的校验和
+//
+// 查询参数：path、algorithm（sha256、sha1 或 md5，默认 sha256）。
+func (hs *HTTPServer) handleFSChecksum(c *gin.Context) {
+	extendDeadlines(c.Writer)
+	hs.proxyFSQuery(c, "fs_checksum", map[string]interface{}{
+		"path":      c.Query("path"),
+		"algorithm": c.Query("algorithm"),
+	}, fsChecksumTimeout)
+}
+
+// handleFSWrite 写入节点上的小文件，只允许在节点配置的写入根目录之下
+//
+// 查询参数：path、mode（八进制，文件已存在时默认保留原权限）；请求体为文件内容，最大 1MB。
+func (hs *HTTPServer) handleFSWrite(c *gin.Context) {
+	content, err := io.ReadAll(io.LimitReader(c.Request.Body, fsMaxWriteSize+1))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "failed to read request body"})
+		return
+	}
+	if len(content) > fsMaxWriteSize {
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": "content exceeds 1MB, use the file transfer API instead"})
+		return
+	}
+
+	hs.proxyFSQuery(c, "fs_write", map[string]interface{}{
+		"path": c.Query("path"),
+		"mode": c.Query("mode"),
+		"data": content,
+	}, DefaultRequestTimeout)
+}
+
+// handleFSMkdir 在节点上创建目录
+func (hs *HTTPServer) handleFSMkdir(c *gin.Context) {
+	var req struct {
+		Path    string `json:"path" binding:"required"`
+		Mode    string `json:"mode"`
+		Parents bool   `json:"parents"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	hs.proxyFSQuery(c, "fs_mkdir", map[string]interface{}{
+		"path":    req.Path,
+		"mode":    req.Mode,
+		"parents": req.Parents,
+	}, DefaultRequestTimeout)
+}
+
+// handleFSRename 重命名或移动节点上的文件
+func (hs *HTTPServer) handleFSRename(c *gin.Context) {
+	var req struct {
+		Path    string `json:"path" binding:"required"`
+		NewPath string `json:"new_path" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	hs.proxyFSQuery(c, "fs_rename", map[string]interface{}{
+		"path":     req.Path,
+		"new_path": req.NewPath,
+	}, DefaultRequestTimeout)
+}
+
+// handleFSRemove 删除节点上的文件或目录，非空目录需要 recursive=true
+func (hs *HTTPServer) handleFSRemove(c *gin.Context) {
+	hs.proxyFSQuery(c, "fs_remove", map[string]interface{}{
+		"path":      c.Query("path"),
+		"recursive": c.Query("recursive") == "true",
+	}, DefaultRequestTimeout)
+}
+
+// proxyFSQuery 向节点发送文件浏览请求，原样返回节点的 JSON 结果
+func (hs *HTTPServer) proxyFSQuery(c *gin.Context, cmd string, data map[string]interface{}, timeout time.Duration) {
+	result, ok := hs.queryFS(c, cmd, data, timeout)
+	if !ok {
+		return
+	}
+	c.Data(http.StatusOK, "application/json", result)
+}
+
+// queryFS 向节点发送文件浏览请求，失败时写入错误响应并返回 false
+func (hs *HTTPServer) queryFS(c *gin.Context, cmd string, data map[string]interface{}, timeout time.Duration) ([]byte, bool) {
+	domclusterServer := hs.svc.(*services.DomclusterServer)
+
+	nodeID := c.Param("nodeId")
+	if !domclusterServer.IsNodeConnected(nodeID) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "node not connected"})
+		return nil, false
+	}
+	if path, _ := data["path"].(string); !strings.HasPrefix(path, "/") {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "path must be absolute"})
+		return nil, false
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), timeout)
+	defer cancel()
+
+	result, err := domclusterServer.QueryNode(ctx, nodeID, cmd, data)
+	if err != nil {
+		if ctx.Err() == context.DeadlineExceeded {
+			c.JSON(http.StatusGatewayTimeout, gin.H{"error": "request timeout"})
+		} else {
+			c.JSON(fsErrorStatus(err), gin.H{"error": err.Error()})
+		}
+		return nil, false
+	}
+	return result, true
+}
+
+// fsErrorStatus 根据节点返回的错误选择状态码
+func fsErrorStatus(err error) int {
+	msg := err.Error()
+	switch {
+	case strings.Contains(msg, "no such file or directory"):
+		return http.StatusNotFound
+	case strings.Contains(msg, "permission denied"),
+		strings.Contains(msg, "outside the allowed write roots"),
+		strings.Contains(msg, "file writes are disabled"):
+		return http.StatusForbidden
+	case strings.Contains(msg, "already exists"), strings.Contains(msg, "file exists"),
+		strings.Contains(msg, "directory not empty"):
+		return http.StatusConflict
+	default:
+		return http.StatusBadRequest
+	}
+}
+
+// encodeContent UTF-8 文本原样返回，二进制内容使用 base64
+func encodeContent(data []byte) (string, string) {
+	if utf8.Valid(data) {
+		return string(data), "utf-8"
+	}
+	return base64.StdEncoding.EncodeToString(data), "base64"
+}
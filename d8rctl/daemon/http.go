@@ -1,15 +1,22 @@
 package daemon
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
 	"fmt"
+	"io"
 	"net"
 	"net/http"
+	"net/url"
+	"os"
+	"strconv"
 	"time"
 
 	"d8rctl/auth"
 	"d8rctl/services"
+	"d8rctl/services/domjudge"
+	"d8rctl/services/monitor"
 
 	"github.com/gin-gonic/gin"
 	"go.uber.org/zap"
@@ -77,15 +84,90 @@ func NewHTTPServer(status *ServerStatus, svc interface{}) *HTTPServer {
 			authRequired.POST("/restart", hs.handleRestart)
 			authRequired.GET("/nodes", hs.handleNodes)
 			authRequired.GET("/nodes/:nodeId/status", hs.handleNodeStatus)
+			authRequired.GET("/nodes/:nodeId/processes", hs.handleNodeProcesses)
+			authRequired.GET("/nodes/:nodeId/probes", hs.handleNodeProbes)
+			authRequired.GET("/nodes/:nodeId/files", hs.handleFileDownload)
+			authRequired.PUT("/nodes/:nodeId/files", hs.handleFileUpload)
+			authRequired.GET("/nodes/:nodeId/files/stat", hs.handleFileStat)
+			authRequired.GET("/nodes/:nodeId/fs/list", hs.handleFSList)
+			authRequired.GET("/nodes/:nodeId/fs/stat", hs.handleFSStat)
+			authRequired.GET("/nodes/:nodeId/fs/read", hs.handleFSRead)
+			authRequired.GET("/nodes/:nodeId/fs/tail", hs.handleFSTail)
+			authRequired.GET("/nodes/:nodeId/fs/checksum", hs.handleFSChecksum)
+			authRequired.PUT("/nodes/:nodeId/fs/file", hs.handleFSWrite)
+			authRequired.POST("/nodes/:nodeId/fs/mkdir", hs.handleFSMkdir)
+			authRequired.POST("/nodes/:nodeId/fs/rename", hs.handleFSRename)
+			authRequired.DELETE("/nodes/:nodeId/fs", hs.handleFSRemove)
+			authRequired.GET("/probes", hs.handleProbes)
+			authRequired.GET("/logs/search", hs.handleLogSearch)
 			authRequired.GET("/docker/containers", hs.handleDockerList)
 			authRequired.POST("/docker/start", hs.handleDockerStart)
 			authRequired.POST("/docker/stop", hs.handleDockerStop)
 			authRequired.POST("/docker/restart", hs.handleDockerRestart)
+			authRequired.POST("/docker/create", hs.handleDockerCreate)
+			authRequired.POST("/docker/recreate", hs.handleDockerRecreate)
+			authRequired.POST("/docker/remove", hs.handleDockerRemove)
 			authRequired.GET("/docker/logs", hs.handleDockerLogs)
+			authRequired.GET("/docker/logs/ws", hs.handleDockerLogsFollow)
 			authRequired.GET("/docker/stats", hs.handleDockerStats)
+			authRequired.GET("/docker/stats/ws", hs.handleDockerStatsStream)
 			authRequired.GET("/docker/inspect", hs.handleDockerInspect)
 			authRequired.GET("/docker/nodes", hs.handleDockerNodes)
+			authRequired.GET("/docker/images", hs.handleImageList)
+			authRequired.DELETE("/docker/images", hs.handleImageRemove)
+			authRequired.GET("/docker/images/cluster", hs.handleClusterImages)
+			authRequired.GET("/docker/images/consistency", hs.handleImageConsistency)
+			authRequired.POST("/docker/images/pull", hs.handleImagePull)
+			authRequired.GET("/docker/images/pull/ws", hs.handleImagePullStream)
+			authRequired.POST("/docker/images/tag", hs.handleImageTag)
+			authRequired.POST("/docker/images/prune", hs.handleImagePrune)
+			authRequired.GET("/docker/images/archives", hs.handleListImageArchives)
+			authRequired.POST("/docker/images/archives", hs.handleUploadImageArchive)
+			authRequired.POST("/docker/images/archives/local", hs.handleSaveLocalImages)
+			authRequired.DELETE("/docker/images/archives/:id", hs.handleDeleteImageArchive)
+			authRequired.GET("/docker/images/distributions", hs.handleListImageDistributions)
+			authRequired.POST("/docker/images/distributions", hs.handleStartImageDistribution)
+			authRequired.GET("/docker/images/distributions/:id", hs.handleGetImageDistribution)
+			authRequired.DELETE("/docker/images/distributions/:id", hs.handleCancelImageDistribution)
 			authRequired.GET("/terminal/ws", hs.handleTerminalWebSocket)
+			authRequired.GET("/recordings", hs.handleListRecordings)
+			authRequired.GET("/recordings/:id", hs.handleGetRecording)
+			authRequired.GET("/recordings/:id/download", hs.handleDownloadRecording)
+			authRequired.GET("/recordings/:id/replay", hs.handleReplayRecording)
+			authRequired.PUT("/nodes/:nodeId/labels", hs.handleSetNodeLabels)
+			authRequired.GET("/agent-config", hs.handleGetAgentConfig)
+			authRequired.PUT("/agent-config/default", hs.handleSetDefaultAgentConfig)
+			authRequired.PUT("/agent-config/labels/:label", hs.handleSetLabelAgentConfig)
+			authRequired.DELETE("/agent-config/labels/:label", hs.handleDeleteLabelAgentConfig)
+			authRequired.PUT("/agent-config/nodes/:nodeId", hs.handleSetNodeAgentConfig)
+			authRequired.DELETE("/agent-config/nodes/:nodeId", hs.handleDeleteNodeAgentConfig)
+			authRequired.GET("/agent-config/nodes/:nodeId/effective", hs.handleGetEffectiveAgentConfig)
+			authRequired.GET("/agent-config/drift", hs.handleAgentConfigDrift)
+			authRequired.GET("/stacks", hs.handleListStacks)
+			authRequired.POST("/stacks", hs.handleStackUp)
+			authRequired.GET("/stacks/:name", hs.handleStackStatus)
+			authRequired.DELETE("/stacks/:name", hs.handleStackDown)
+			authRequired.GET("/stacks/:name/deployment", hs.handleStackDeployment)
+			authRequired.POST("/stacks/:name/judgehosts/provision", hs.handleProvisionJudgehosts)
+			authRequired.POST("/stacks/:name/rollout", hs.handleStartRollout)
+			authRequired.GET("/stacks/:name/rollout", hs.handleGetRollout)
+			authRequired.POST("/stacks/:name/rollout/resume", hs.handleResumeRollout)
+			authRequired.POST("/stacks/:name/rollout/rollback", hs.handleRollbackRollout)
+			authRequired.POST("/stacks/:name/desired", hs.handleSyncStackDesired)
+			authRequired.GET("/nodes/:nodeId/topology", hs.handleNodeTopology)
+			authRequired.GET("/domjudge/config", hs.handleGetDomjudgeConfig)
+			authRequired.PUT("/domjudge/config", hs.handleSetDomjudgeConfig)
+			authRequired.GET("/domjudge/judgehosts", hs.handleListJudgehosts)
+			authRequired.PUT("/domjudge/judgehosts/:target", hs.handleSetJudgehostEnabled)
+			authRequired.GET("/autoscale", hs.handleListAutoscale)
+			authRequired.GET("/autoscale/:stack", hs.handleGetAutoscale)
+			authRequired.PUT("/autoscale/:stack", hs.handleSetAutoscale)
+			authRequired.DELETE("/autoscale/:stack", hs.handleDeleteAutoscale)
+			authRequired.POST("/autoscale/:stack/pin", hs.handlePinAutoscale)
+			authRequired.DELETE("/autoscale/:stack/pin", hs.handleUnpinAutoscale)
+			authRequired.GET("/desired", hs.handleListDesired)
+			authRequired.GET("/desired/:nodeId", hs.handleGetDesired)
+			authRequired.PUT("/desired/:nodeId", hs.handleSetDesired)
 		}
 	}
 
@@ -206,13 +288,24 @@ func (hs *HTTPServer) handleNodes(c *gin.Context) {
 	nodeManager := domclusterServer.GetNodeManager()
 	nodes := nodeManager.ListNodes()
 
+	collector := domclusterServer.GetMonitor().GetCollector()
+	judgehosts := domjudgeNodeSummaries(c.Request.Context(), domclusterServer)
 	result := make(map[string]interface{})
 	for id, info := range nodes {
-		result[id] = map[string]interface{}{
+		node := map[string]interface{}{
 			"name":    info.Name,
 			"role":    info.Role,
 			"version": info.Version,
+			"labels":  domclusterServer.NodeLabels(id),
+			"config":  domclusterServer.GetAgentConfig().NodeState(id, domclusterServer.NodeLabels(id)),
 		}
+		if status, ok := collector.GetStatus(id); ok {
+			node["probes"] = probeSummary(status.Probes)
+		}
+		if summary, ok := judgehosts[id]; ok {
+			node["domjudge"] = summary
+		}
+		result[id] = node
 	}
 
 	c.JSON(http.StatusOK, result)
@@ -250,7 +343,70 @@ func (hs *HTTPServer) handleNodeStatus(c *gin.Context) {
 		return
 	}
 
-	c.JSON(http.StatusOK, status)
+	// 配置了 DomJudge 集成时附带节点上 judgehost 在 DomJudge 中的状态
+	ctx, cancel := context.WithTimeout(c.Request.Context(), domjudgeRequestTimeout)
+	defer cancel()
+	result := nodeStatusResponse{NodeStatus: status}
+	judgehosts, err := domclusterServer.NodeJudgehosts(ctx, nodeID)
+	if err != nil {
+		result.DomjudgeError = err.Error()
+	}
+	result.Domjudge = judgehosts
+
+	c.JSON(http.StatusOK, result)
+}
+
+// handleNodeProcesses 处理节点进程排行请求
+func (hs *HTTPServer) handleNodeProcesses(c *gin.Context) {
+	domclusterServer, ok := hs.svc.(*services.DomclusterServer)
+	if !ok {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "invalid service type"})
+		return
+	}
+
+	nodeID := c.Param("nodeId")
+	if !domclusterServer.IsNodeConnected(nodeID) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "node not connected"})
+		return
+	}
+
+	sortBy := c.DefaultQuery("sort", "cpu")
+	if sortBy != "cpu" && sortBy != "memory" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "sort must be cpu or memory"})
+		return
+	}
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "10"))
+	if err != nil || limit <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "limit must be a positive integer"})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), DefaultRequestTimeout)
+	defer cancel()
+
+	result, err := domclusterServer.QueryTopProcesses(ctx, nodeID, sortBy, limit)
+	if err != nil {
+		if ctx.Err() == context.DeadlineExceeded {
+			c.JSON(http.StatusGatewayTimeout, gin.H{"error": "request timeout"})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, result)
+}
+
+// writeJSON 以 JSON 返回结果，HTTP API 与 CLI 共用的处理函数使用
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+// writeError 以 {"error": msg} 返回错误
+func writeError(w http.ResponseWriter, status int, msg string) {
+	writeJSON(w, status, map[string]interface{}{"error": msg})
 }
 
 // GetNodeList 获取节点列表
@@ -280,3 +436,962 @@ func GetNodeList() (map[string]interface{}, error) {
 
 	return nodes, nil
 }
+
+// GetTopProcesses 获取节点的进程排行
+func GetTopProcesses(nodeID, sortBy string, limit int) (*monitor.TopProcesses, error) {
+	client := &http.Client{
+		Transport: &http.Transport{
+			DialContext: func(_ context.Context, _, _ string) (net.Conn, error) {
+				return net.Dial("unix", cliSocketPath)
+			},
+		},
+	}
+
+	query := url.Values{}
+	query.Set("node", nodeID)
+	query.Set("sort", sortBy)
+	query.Set("limit", strconv.Itoa(limit))
+
+	resp, err := client.Get("http://unix/top?" + query.Encode())
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		var errResp struct {
+			Error string `json:"error"`
+		}
+		if err := json.NewDecoder(resp.Body).Decode(&errResp); err == nil && errResp.Error != "" {
+			return nil, fmt.Errorf("%s", errResp.Error)
+		}
+		return nil, fmt.Errorf("failed to get top processes, status: %d", resp.StatusCode)
+	}
+
+	var top monitor.TopProcesses
+	if err := json.NewDecoder(resp.Body).Decode(&top); err != nil {
+		return nil, err
+	}
+
+	return &top, nil
+}
+
+// CopyTarget 节点或节点上容器内的文件
+type CopyTarget struct {
+	NodeID      string
+	ContainerID string
+	Path        string
+}
+
+// copyQuery 构造 /cp 请求的查询参数
+func (t CopyTarget) copyQuery() url.Values {
+	query := url.Values{}
+	query.Set("node", t.NodeID)
+	query.Set("path", t.Path)
+	if t.ContainerID != "" {
+		query.Set("container", t.ContainerID)
+	}
+	return query
+}
+
+// CopyUploadResult 上传完成后节点上的文件
+type CopyUploadResult struct {
+	Path   string `json:"path"`
+	Size   int64  `json:"size"`
+	SHA256 string `json:"sha256"`
+	Mode   string `json:"mode"`
+}
+
+// CopyDownload 下载响应，Body 为从 Offset 开始的文件内容，调用方负责关闭
+type CopyDownload struct {
+	Body   io.ReadCloser
+	Offset int64
+	Size   int64
+	SHA256 string
+	Mode   string
+}
+
+// UploadToNode 将本地文件上传到节点，mode 为空时使用 0644，maxSize 为 0 时使用节点配置的上限
+func UploadToNode(target CopyTarget, src *os.File, size int64, mode string, maxSize int64) (*CopyUploadResult, error) {
+	client := &http.Client{
+		Transport: &http.Transport{
+			DialContext: func(_ context.Context, _, _ string) (net.Conn, error) {
+				return net.Dial("unix", cliSocketPath)
+			},
+		},
+	}
+
+	query := target.copyQuery()
+	if mode != "" {
+		query.Set("mode", mode)
+	}
+	if maxSize > 0 {
+		query.Set("max_size", strconv.FormatInt(maxSize, 10))
+	}
+
+	req, err := http.NewRequest(http.MethodPut, "http://unix/cp?"+query.Encode(), src)
+	if err != nil {
+		return nil, err
+	}
+	req.ContentLength = size
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, decodeCopyError(resp)
+	}
+
+	var result CopyUploadResult
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// DownloadFromNode 从 offset 开始下载节点上的文件
+func DownloadFromNode(target CopyTarget, offset, maxSize int64) (*CopyDownload, error) {
+	client := &http.Client{
+		Transport: &http.Transport{
+			DialContext: func(_ context.Context, _, _ string) (net.Conn, error) {
+				return net.Dial("unix", cliSocketPath)
+			},
+		},
+	}
+
+	query := target.copyQuery()
+	if offset > 0 {
+		query.Set("offset", strconv.FormatInt(offset, 10))
+	}
+	if maxSize > 0 {
+		query.Set("max_size", strconv.FormatInt(maxSize, 10))
+	}
+
+	resp, err := client.Get("http://unix/cp?" + query.Encode())
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusPartialContent {
+		defer resp.Body.Close()
+		return nil, decodeCopyError(resp)
+	}
+
+	size, _ := strconv.ParseInt(resp.Header.Get("X-File-Size"), 10, 64)
+	return &CopyDownload{
+		Body:   resp.Body,
+		Offset: offset,
+		Size:   size,
+		SHA256: resp.Header.Get("X-Content-Sha256"),
+		Mode:   resp.Header.Get("X-File-Mode"),
+	}, nil
+}
+
+// decodeCopyError 解析 /cp 返回的错误
+func decodeCopyError(resp *http.Response) error {
+	var errResp struct {
+		Error string `json:"error"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&errResp); err == nil && errResp.Error != "" {
+		return fmt.Errorf("%s", errResp.Error)
+	}
+	return fmt.Errorf("copy failed, status: %d", resp.StatusCode)
+}
+
+// UploadImageArchive 将本地的 docker save 镜像包上传到控制端
+func UploadImageArchive(src *os.File, size int64) (*services.ImageArchive, error) {
+	client := &http.Client{
+		Transport: &http.Transport{
+			DialContext: func(_ context.Context, _, _ string) (net.Conn, error) {
+				return net.Dial("unix", cliSocketPath)
+			},
+		},
+	}
+
+	req, err := http.NewRequest(http.MethodPut, "http://unix/images/archives", src)
+	if err != nil {
+		return nil, err
+	}
+	req.ContentLength = size
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, decodeImageError(resp)
+	}
+
+	var archive services.ImageArchive
+	if err := json.NewDecoder(resp.Body).Decode(&archive); err != nil {
+		return nil, err
+	}
+	return &archive, nil
+}
+
+// SaveLocalImages 让控制端从本机 Docker 导出镜像作为镜像包
+func SaveLocalImages(refs []string) (*services.ImageArchive, error) {
+	client := &http.Client{
+		Transport: &http.Transport{
+			DialContext: func(_ context.Context, _, _ string) (net.Conn, error) {
+				return net.Dial("unix", cliSocketPath)
+			},
+		},
+	}
+
+	body, err := json.Marshal(map[string]interface{}{"images": refs})
+	if err != nil {
+		return nil, err
+	}
+	resp, err := client.Post("http://unix/images/archives", "application/json", bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, decodeImageError(resp)
+	}
+
+	var archive services.ImageArchive
+	if err := json.NewDecoder(resp.Body).Decode(&archive); err != nil {
+		return nil, err
+	}
+	return &archive, nil
+}
+
+// StartImageDistribution 开始将镜像包分发到节点
+func StartImageDistribution(request ImageDistRequest) (*services.ImageDistribution, error) {
+	client := &http.Client{
+		Transport: &http.Transport{
+			DialContext: func(_ context.Context, _, _ string) (net.Conn, error) {
+				return net.Dial("unix", cliSocketPath)
+			},
+		},
+	}
+
+	body, err := json.Marshal(request)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := client.Post("http://unix/images/distributions", "application/json", bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusAccepted {
+		return nil, decodeImageError(resp)
+	}
+
+	var dist services.ImageDistribution
+	if err := json.NewDecoder(resp.Body).Decode(&dist); err != nil {
+		return nil, err
+	}
+	return &dist, nil
+}
+
+// GetImageDistribution 查询镜像分发任务的进度
+func GetImageDistribution(id string) (*services.ImageDistribution, error) {
+	client := &http.Client{
+		Transport: &http.Transport{
+			DialContext: func(_ context.Context, _, _ string) (net.Conn, error) {
+				return net.Dial("unix", cliSocketPath)
+			},
+		},
+	}
+
+	resp, err := client.Get("http://unix/images/distributions?id=" + url.QueryEscape(id))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, decodeImageError(resp)
+	}
+
+	var dist services.ImageDistribution
+	if err := json.NewDecoder(resp.Body).Decode(&dist); err != nil {
+		return nil, err
+	}
+	return &dist, nil
+}
+
+// decodeImageError 解析镜像包和分发请求返回的错误
+func decodeImageError(resp *http.Response) error {
+	var errResp struct {
+		Error string `json:"error"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&errResp); err == nil && errResp.Error != "" {
+		return fmt.Errorf("%s", errResp.Error)
+	}
+	return fmt.Errorf("request failed, status: %d", resp.StatusCode)
+}
+
+// CreateContainer 按声明在节点上创建容器，recreate 为 true 时重建同名容器
+func CreateContainer(request ContainerCreateRequest, recreate bool) (*services.ContainerResult, error) {
+	client := &http.Client{
+		Transport: &http.Transport{
+			DialContext: func(_ context.Context, _, _ string) (net.Conn, error) {
+				return net.Dial("unix", cliSocketPath)
+			},
+		},
+	}
+
+	body, err := json.Marshal(request)
+	if err != nil {
+		return nil, err
+	}
+	path := "/containers/create"
+	if recreate {
+		path = "/containers/recreate"
+	}
+	resp, err := client.Post("http://unix"+path, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, decodeImageError(resp)
+	}
+
+	var result services.ContainerResult
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// RemoveContainer 删除节点上的容器
+func RemoveContainer(request ContainerRemoveRequest) error {
+	client := &http.Client{
+		Transport: &http.Transport{
+			DialContext: func(_ context.Context, _, _ string) (net.Conn, error) {
+				return net.Dial("unix", cliSocketPath)
+			},
+		},
+	}
+
+	body, err := json.Marshal(request)
+	if err != nil {
+		return err
+	}
+	resp, err := client.Post("http://unix/containers/remove", "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return decodeImageError(resp)
+	}
+	return nil
+}
+
+// StackUp 提交栈声明并开始部署
+func StackUp(spec []byte) (*services.StackDeployment, error) {
+	client := &http.Client{
+		Transport: &http.Transport{
+			DialContext: func(_ context.Context, _, _ string) (net.Conn, error) {
+				return net.Dial("unix", cliSocketPath)
+			},
+		},
+	}
+
+	resp, err := client.Post("http://unix/stacks", "application/yaml", bytes.NewReader(spec))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusAccepted {
+		return nil, decodeImageError(resp)
+	}
+
+	var dep services.StackDeployment
+	if err := json.NewDecoder(resp.Body).Decode(&dep); err != nil {
+		return nil, err
+	}
+	return &dep, nil
+}
+
+// StackDown 开始删除栈的全部容器
+func StackDown(name string) (*services.StackDeployment, error) {
+	client := &http.Client{
+		Transport: &http.Transport{
+			DialContext: func(_ context.Context, _, _ string) (net.Conn, error) {
+				return net.Dial("unix", cliSocketPath)
+			},
+		},
+	}
+
+	req, err := http.NewRequest(http.MethodDelete, "http://unix/stacks?name="+url.QueryEscape(name), nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusAccepted {
+		return nil, decodeImageError(resp)
+	}
+
+	var dep services.StackDeployment
+	if err := json.NewDecoder(resp.Body).Decode(&dep); err != nil {
+		return nil, err
+	}
+	return &dep, nil
+}
+
+// GetStackStatus 查询栈的状态
+func GetStackStatus(name string) (*services.StackStatus, error) {
+	client := &http.Client{
+		Transport: &http.Transport{
+			DialContext: func(_ context.Context, _, _ string) (net.Conn, error) {
+				return net.Dial("unix", cliSocketPath)
+			},
+		},
+	}
+
+	resp, err := client.Get("http://unix/stacks?name=" + url.QueryEscape(name))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, decodeImageError(resp)
+	}
+
+	var status services.StackStatus
+	if err := json.NewDecoder(resp.Body).Decode(&status); err != nil {
+		return nil, err
+	}
+	return &status, nil
+}
+
+// ListStacks 列出已部署的栈
+func ListStacks() ([]string, error) {
+	client := &http.Client{
+		Transport: &http.Transport{
+			DialContext: func(_ context.Context, _, _ string) (net.Conn, error) {
+				return net.Dial("unix", cliSocketPath)
+			},
+		},
+	}
+
+	resp, err := client.Get("http://unix/stacks")
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, decodeImageError(resp)
+	}
+
+	var result struct {
+		Stacks []string `json:"stacks"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, err
+	}
+	return result.Stacks, nil
+}
+
+// GetStackDeployment 查询栈最近一次操作的进度
+func GetStackDeployment(name string) (*services.StackDeployment, error) {
+	client := &http.Client{
+		Transport: &http.Transport{
+			DialContext: func(_ context.Context, _, _ string) (net.Conn, error) {
+				return net.Dial("unix", cliSocketPath)
+			},
+		},
+	}
+
+	resp, err := client.Get("http://unix/stacks/deployment?name=" + url.QueryEscape(name))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, decodeImageError(resp)
+	}
+
+	var dep services.StackDeployment
+	if err := json.NewDecoder(resp.Body).Decode(&dep); err != nil {
+		return nil, err
+	}
+	return &dep, nil
+}
+
+// ProvisionJudgehosts 开始在节点上按核心部署 judgehost
+func ProvisionJudgehosts(request JudgehostProvisionRequest) (*services.StackDeployment, error) {
+	client := &http.Client{
+		Transport: &http.Transport{
+			DialContext: func(_ context.Context, _, _ string) (net.Conn, error) {
+				return net.Dial("unix", cliSocketPath)
+			},
+		},
+	}
+
+	body, err := json.Marshal(request)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := client.Post("http://unix/judgehosts/provision", "application/json", bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusAccepted {
+		return nil, decodeImageError(resp)
+	}
+
+	var dep services.StackDeployment
+	if err := json.NewDecoder(resp.Body).Decode(&dep); err != nil {
+		return nil, err
+	}
+	return &dep, nil
+}
+
+// GetNodeTopology 查询节点的 CPU 拓扑
+func GetNodeTopology(nodeID string) (*services.NodeTopology, error) {
+	client := &http.Client{
+		Transport: &http.Transport{
+			DialContext: func(_ context.Context, _, _ string) (net.Conn, error) {
+				return net.Dial("unix", cliSocketPath)
+			},
+		},
+	}
+
+	resp, err := client.Get("http://unix/judgehosts/topology?node=" + url.QueryEscape(nodeID))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, decodeImageError(resp)
+	}
+
+	var topo services.NodeTopology
+	if err := json.NewDecoder(resp.Body).Decode(&topo); err != nil {
+		return nil, err
+	}
+	return &topo, nil
+}
+
+// GetDomjudgeConfig 查询 DomJudge 集成配置，未配置时返回 nil
+func GetDomjudgeConfig() (*domjudge.Config, error) {
+	client := &http.Client{
+		Transport: &http.Transport{
+			DialContext: func(_ context.Context, _, _ string) (net.Conn, error) {
+				return net.Dial("unix", cliSocketPath)
+			},
+		},
+	}
+
+	resp, err := client.Get("http://unix/domjudge/config")
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, decodeImageError(resp)
+	}
+
+	var result struct {
+		Config *domjudge.Config `json:"config"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, err
+	}
+	return result.Config, nil
+}
+
+// SetDomjudgeConfig 保存 DomJudge 集成配置
+func SetDomjudgeConfig(cfg domjudge.Config) error {
+	client := &http.Client{
+		Transport: &http.Transport{
+			DialContext: func(_ context.Context, _, _ string) (net.Conn, error) {
+				return net.Dial("unix", cliSocketPath)
+			},
+		},
+	}
+
+	body, err := json.Marshal(cfg)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest(http.MethodPut, "http://unix/domjudge/config", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return decodeImageError(resp)
+	}
+	return nil
+}
+
+// ListJudgehosts 列出 DomJudge 中的 judgehost
+func ListJudgehosts() ([]services.JudgehostStatus, error) {
+	client := &http.Client{
+		Transport: &http.Transport{
+			DialContext: func(_ context.Context, _, _ string) (net.Conn, error) {
+				return net.Dial("unix", cliSocketPath)
+			},
+		},
+	}
+
+	resp, err := client.Get("http://unix/domjudge/judgehosts?fresh=true")
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, decodeImageError(resp)
+	}
+
+	var result struct {
+		Judgehosts []services.JudgehostStatus `json:"judgehosts"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, err
+	}
+	return result.Judgehosts, nil
+}
+
+// SetJudgehostEnabled 在 DomJudge 中启用或禁用 judgehost，target 为 judgehost 名字或节点 ID
+func SetJudgehostEnabled(target string, enabled bool) ([]services.JudgehostStatus, error) {
+	client := &http.Client{
+		Transport: &http.Transport{
+			DialContext: func(_ context.Context, _, _ string) (net.Conn, error) {
+				return net.Dial("unix", cliSocketPath)
+			},
+		},
+	}
+
+	body, err := json.Marshal(JudgehostEnableRequest{Target: target, Enabled: enabled})
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequest(http.MethodPut, "http://unix/domjudge/judgehosts", bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, decodeImageError(resp)
+	}
+
+	var result struct {
+		Judgehosts []services.JudgehostStatus `json:"judgehosts"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, err
+	}
+	return result.Judgehosts, nil
+}
+
+// ListAutoscale 列出全部自动扩缩容策略
+func ListAutoscale() ([]services.AutoscaleStatus, error) {
+	client := &http.Client{
+		Transport: &http.Transport{
+			DialContext: func(_ context.Context, _, _ string) (net.Conn, error) {
+				return net.Dial("unix", cliSocketPath)
+			},
+		},
+	}
+
+	resp, err := client.Get("http://unix/autoscale")
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, decodeImageError(resp)
+	}
+
+	var result struct {
+		Policies []services.AutoscaleStatus `json:"policies"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, err
+	}
+	return result.Policies, nil
+}
+
+// GetAutoscaleStatus 查询栈的自动扩缩容状态和事件
+func GetAutoscaleStatus(stack string) (*services.AutoscaleStatus, error) {
+	client := &http.Client{
+		Transport: &http.Transport{
+			DialContext: func(_ context.Context, _, _ string) (net.Conn, error) {
+				return net.Dial("unix", cliSocketPath)
+			},
+		},
+	}
+
+	resp, err := client.Get("http://unix/autoscale?stack=" + url.QueryEscape(stack))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, decodeImageError(resp)
+	}
+
+	var status services.AutoscaleStatus
+	if err := json.NewDecoder(resp.Body).Decode(&status); err != nil {
+		return nil, err
+	}
+	return &status, nil
+}
+
+// SetAutoscalePolicy 创建或修改栈的自动扩缩容策略
+func SetAutoscalePolicy(policy services.AutoscalePolicy) (*services.AutoscaleStatus, error) {
+	return autoscaleRequest(http.MethodPut, "/autoscale", policy)
+}
+
+// RemoveAutoscalePolicy 删除栈的自动扩缩容策略
+func RemoveAutoscalePolicy(stack string) error {
+	client := &http.Client{
+		Transport: &http.Transport{
+			DialContext: func(_ context.Context, _, _ string) (net.Conn, error) {
+				return net.Dial("unix", cliSocketPath)
+			},
+		},
+	}
+
+	req, err := http.NewRequest(http.MethodDelete, "http://unix/autoscale?stack="+url.QueryEscape(stack), nil)
+	if err != nil {
+		return err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return decodeImageError(resp)
+	}
+	return nil
+}
+
+// PinAutoscale 固定栈运行的 judgehost 数量，size 为 nil 时固定为当前数量
+func PinAutoscale(stack string, size *int) (*services.AutoscaleStatus, error) {
+	return autoscaleRequest(http.MethodPost, "/autoscale/pin", AutoscalePinRequest{Stack: stack, Size: size})
+}
+
+// UnpinAutoscale 取消固定数量
+func UnpinAutoscale(stack string) (*services.AutoscaleStatus, error) {
+	return autoscaleRequest(http.MethodDelete, "/autoscale/pin?stack="+url.QueryEscape(stack), nil)
+}
+
+// autoscaleRequest 发送自动扩缩容请求并解析返回的状态
+func autoscaleRequest(method, path string, body interface{}) (*services.AutoscaleStatus, error) {
+	client := &http.Client{
+		Transport: &http.Transport{
+			DialContext: func(_ context.Context, _, _ string) (net.Conn, error) {
+				return net.Dial("unix", cliSocketPath)
+			},
+		},
+	}
+
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return nil, err
+		}
+		reader = bytes.NewReader(data)
+	}
+	req, err := http.NewRequest(method, "http://unix"+path, reader)
+	if err != nil {
+		return nil, err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, decodeImageError(resp)
+	}
+
+	var status services.AutoscaleStatus
+	if err := json.NewDecoder(resp.Body).Decode(&status); err != nil {
+		return nil, err
+	}
+	return &status, nil
+}
+
+// StartRollout 开始滚动更新栈的 judgehost 镜像
+func StartRollout(request services.RolloutRequest) (*services.Rollout, error) {
+	body, err := json.Marshal(request)
+	if err != nil {
+		return nil, err
+	}
+	return rolloutRequest(http.MethodPost, "/rollouts", body, http.StatusAccepted)
+}
+
+// GetRollout 查询栈最近一次 rollout 的状态
+func GetRollout(stack string) (*services.Rollout, error) {
+	return rolloutRequest(http.MethodGet, "/rollouts?stack="+url.QueryEscape(stack), nil, http.StatusOK)
+}
+
+// ResumeRollout 继续已暂停的 rollout
+func ResumeRollout(stack string) (*services.Rollout, error) {
+	return rolloutRequest(http.MethodPost, "/rollouts/resume?stack="+url.QueryEscape(stack), nil, http.StatusAccepted)
+}
+
+// RollbackRollout 回滚已暂停的 rollout
+func RollbackRollout(stack string) (*services.Rollout, error) {
+	return rolloutRequest(http.MethodPost, "/rollouts/rollback?stack="+url.QueryEscape(stack), nil, http.StatusAccepted)
+}
+
+// rolloutRequest 发送 rollout 请求并解析返回的状态
+func rolloutRequest(method, path string, body []byte, expected int) (*services.Rollout, error) {
+	client := &http.Client{
+		Transport: &http.Transport{
+			DialContext: func(_ context.Context, _, _ string) (net.Conn, error) {
+				return net.Dial("unix", cliSocketPath)
+			},
+		},
+	}
+
+	req, err := http.NewRequest(method, "http://unix"+path, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != expected {
+		return nil, decodeImageError(resp)
+	}
+
+	var rollout services.Rollout
+	if err := json.NewDecoder(resp.Body).Decode(&rollout); err != nil {
+		return nil, err
+	}
+	return &rollout, nil
+}
+
+// ListDesired 列出各节点的期望状态和收敛情况
+func ListDesired() ([]services.DesiredStatus, error) {
+	var result struct {
+		Nodes []services.DesiredStatus `json:"nodes"`
+	}
+	if err := desiredRequest(http.MethodGet, "/desired", nil, &result); err != nil {
+		return nil, err
+	}
+	return result.Nodes, nil
+}
+
+// GetDesiredStatus 查询节点的期望状态，live 为 true 时向节点获取最新的收敛结果
+func GetDesiredStatus(node string, live bool) (*services.DesiredStatus, error) {
+	path := "/desired?node=" + url.QueryEscape(node)
+	if live {
+		path += "&live=true"
+	}
+	var status services.DesiredStatus
+	if err := desiredRequest(http.MethodGet, path, nil, &status); err != nil {
+		return nil, err
+	}
+	return &status, nil
+}
+
+// SetNodeDesired 替换节点期望状态中一个来源的容器
+func SetNodeDesired(request DesiredSetRequest) (*services.NodeDesiredState, error) {
+	var state services.NodeDesiredState
+	if err := desiredRequest(http.MethodPut, "/desired", request, &state); err != nil {
+		return nil, err
+	}
+	return &state, nil
+}
+
+// SyncStackDesired 将栈同步到各节点的期望状态
+func SyncStackDesired(stack string) (*services.DesiredSyncResult, error) {
+	var result services.DesiredSyncResult
+	if err := desiredRequest(http.MethodPost, "/desired/sync", DesiredSyncRequest{Stack: stack}, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// desiredRequest 发送期望状态请求并将结果解析到 out
+func desiredRequest(method, path string, body, out interface{}) error {
+	client := &http.Client{
+		Transport: &http.Transport{
+			DialContext: func(_ context.Context, _, _ string) (net.Conn, error) {
+				return net.Dial("unix", cliSocketPath)
+			},
+		},
+	}
+
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reader = bytes.NewReader(data)
+	}
+	req, err := http.NewRequest(method, "http://unix"+path, reader)
+	if err != nil {
+		return err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return decodeImageError(resp)
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
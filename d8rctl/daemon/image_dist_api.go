@@ -0,0 +1,182 @@
+package daemon
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"d8rctl/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ImageDistRequest 镜像分发请求，node_ids 与 role/labels 二选一
+type ImageDistRequest struct {
+	ArchiveID   string            `json:"archive_id"`
+	NodeIDs     []string          `json:"node_ids"`
+	Role        string            `json:"role"`
+	Labels      map[string]string `json:"labels"`
+	Concurrency int               `json:"concurrency"`
+	Force       bool              `json:"force"`
+}
+
+// handleListImageArchives 列出控制端暂存的镜像包
+func (hs *HTTPServer) handleListImageArchives(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"archives": hs.svc.(*services.DomclusterServer).ListImageArchives()})
+}
+
+// handleUploadImageArchive 上传 docker save 格式的镜像包
+//
+// 请求体为 tar 内容；查询参数 max_size（字节数，可选）。内容相同的镜像包只保存一份。
+func (hs *HTTPServer) handleUploadImageArchive(c *gin.Context) {
+	serveImageArchiveUpload(c.Writer, c.Request, hs.svc.(*services.DomclusterServer))
+}
+
+// handleSaveLocalImages 从控制端本机的 Docker 导出镜像作为镜像包，请求体：{"images": [...]}
+func (hs *HTTPServer) handleSaveLocalImages(c *gin.Context) {
+	serveImageArchiveSave(c.Writer, c.Request, hs.svc.(*services.DomclusterServer))
+}
+
+// handleDeleteImageArchive 删除镜像包
+func (hs *HTTPServer) handleDeleteImageArchive(c *gin.Context) {
+	if err := hs.svc.(*services.DomclusterServer).DeleteImageArchive(c.Param("id")); err != nil {
+		c.JSON(imageDistErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "image archive deleted"})
+}
+
+// handleStartImageDistribution 开始将镜像包分发到节点，返回 202 和任务，进度通过任务 ID 查询
+func (hs *HTTPServer) handleStartImageDistribution(c *gin.Context) {
+	serveImageDistributionStart(c.Writer, c.Request, hs.svc.(*services.DomclusterServer))
+}
+
+// handleListImageDistributions 列出镜像分发任务
+func (hs *HTTPServer) handleListImageDistributions(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"distributions": hs.svc.(*services.DomclusterServer).ListImageDistributions()})
+}
+
+// handleGetImageDistribution 查询镜像分发任务的进度
+func (hs *HTTPServer) handleGetImageDistribution(c *gin.Context) {
+	serveImageDistribution(c.Writer, hs.svc.(*services.DomclusterServer), c.Param("id"))
+}
+
+// handleCancelImageDistribution 取消镜像分发任务，节点上已接收的部分保留，重新分发时继续
+func (hs *HTTPServer) handleCancelImageDistribution(c *gin.Context) {
+	if !hs.svc.(*services.DomclusterServer).CancelImageDistribution(c.Param("id")) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "distribution not found"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "distribution canceled"})
+}
+
+// serveImageArchiveUpload 保存请求体中的镜像包，HTTP API 与 CLI 共用
+func serveImageArchiveUpload(w http.ResponseWriter, r *http.Request, svc *services.DomclusterServer) {
+	extendDeadlines(w)
+
+	maxSize, err := parseMaxSize(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if maxSize > 0 && r.ContentLength > maxSize {
+		writeError(w, http.StatusRequestEntityTooLarge, fmt.Sprintf("archive is %d bytes, exceeds limit of %d bytes", r.ContentLength, maxSize))
+		return
+	}
+
+	archive, err := svc.ImportImageArchive(r.Body, maxSize)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, archive)
+}
+
+// serveImageArchiveSave 从控制端本机的 Docker 导出镜像，HTTP API 与 CLI 共用
+func serveImageArchiveSave(w http.ResponseWriter, r *http.Request, svc *services.DomclusterServer) {
+	extendDeadlines(w)
+
+	var req struct {
+		Images []string `json:"images"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || len(req.Images) == 0 {
+		writeError(w, http.StatusBadRequest, "images is required")
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), fileTransferTimeout)
+	defer cancel()
+
+	archive, err := svc.SaveLocalImages(ctx, req.Images)
+	if err != nil {
+		writeError(w, imageDistErrorStatus(err), err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, archive)
+}
+
+// serveImageDistributionStart 开始镜像分发，HTTP API 与 CLI 共用
+func serveImageDistributionStart(w http.ResponseWriter, r *http.Request, svc *services.DomclusterServer) {
+	var req ImageDistRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid request: "+err.Error())
+		return
+	}
+	if req.ArchiveID == "" {
+		writeError(w, http.StatusBadRequest, "archive_id is required")
+		return
+	}
+
+	nodeIDs := req.NodeIDs
+	if len(nodeIDs) == 0 {
+		if req.Role == "" && len(req.Labels) == 0 {
+			writeError(w, http.StatusBadRequest, "node_ids, role or labels is required")
+			return
+		}
+		nodeIDs = svc.SelectNodes(req.Role, req.Labels)
+		if len(nodeIDs) == 0 {
+			writeError(w, http.StatusNotFound, "no connected nodes match the filter")
+			return
+		}
+	}
+	for _, nodeID := range nodeIDs {
+		if !svc.IsNodeConnected(nodeID) {
+			writeError(w, http.StatusNotFound, fmt.Sprintf("node %s not connected", nodeID))
+			return
+		}
+	}
+
+	dist, err := svc.StartImageDistribution(req.ArchiveID, nodeIDs, req.Concurrency, req.Force)
+	if err != nil {
+		writeError(w, imageDistErrorStatus(err), err.Error())
+		return
+	}
+	writeJSON(w, http.StatusAccepted, dist)
+}
+
+// serveImageDistribution 返回镜像分发任务的进度，HTTP API 与 CLI 共用
+func serveImageDistribution(w http.ResponseWriter, svc *services.DomclusterServer, id string) {
+	dist, ok := svc.GetImageDistribution(id)
+	if !ok {
+		writeError(w, http.StatusNotFound, "distribution not found")
+		return
+	}
+	writeJSON(w, http.StatusOK, dist)
+}
+
+// imageDistErrorStatus 根据错误选择状态码
+func imageDistErrorStatus(err error) int {
+	msg := err.Error()
+	switch {
+	case strings.Contains(msg, "not found"), strings.Contains(strings.ToLower(msg), "no such image"):
+		return http.StatusNotFound
+	case strings.Contains(msg, "being distributed"):
+		return http.StatusConflict
+	case strings.Contains(msg, "invalid"):
+		return http.StatusBadRequest
+	default:
+		return http.StatusInternalServerError
+	}
+}
@@ -0,0 +1,258 @@
+package daemon
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"time"
+
+	"d8rctl/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// imagePullRequestTimeout 同步拉取镜像请求的超时时间
+	imagePullRequestTimeout = 30 * time.Minute
+	// imagePruneTimeout 清理镜像的超时时间
+	imagePruneTimeout = 5 * time.Minute
+	// defaultConsistencyRole 一致性检查默认检查的节点角色
+	defaultConsistencyRole = "judgehost"
+)
+
+// handleImageList 列出节点上的镜像
+//
+// 查询参数：node_id、all（是否包含中间层镜像）。
+func (hs *HTTPServer) handleImageList(c *gin.Context) {
+	nodeID := c.Query("node_id")
+	if nodeID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "node_id is required"})
+		return
+	}
+	all := c.Query("all") == "true" || c.Query("all") == "1"
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), DefaultRequestTimeout)
+	defer cancel()
+
+	images, err := hs.svc.(*services.DomclusterServer).ListNodeImages(ctx, nodeID, all)
+	if err != nil {
+		writeImageError(c, ctx, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"node_id": nodeID, "images": images})
+}
+
+// handleClusterImages 集群镜像视图：每个镜像引用在各节点上对应的镜像 ID
+//
+// 查询参数：role、label（key=value，可重复）用于筛选节点，默认包含所有已连接节点。
+func (hs *HTTPServer) handleClusterImages(c *gin.Context) {
+	domclusterServer := hs.svc.(*services.DomclusterServer)
+
+	nodeIDs, ok := selectImageNodes(c, domclusterServer, c.Query("role"))
+	if !ok {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), DefaultRequestTimeout)
+	defer cancel()
+
+	c.JSON(http.StatusOK, domclusterServer.GetClusterImages(ctx, nodeIDs))
+}
+
+// handleImageConsistency 检查一组节点上的镜像是否一致
+//
+// 查询参数：image（必填）、role（默认 judgehost）、label（key=value，可重复）。
+// 所有节点都有该镜像且镜像 ID 相同时返回 200，否则返回 409，响应体相同。
+func (hs *HTTPServer) handleImageConsistency(c *gin.Context) {
+	ref := c.Query("image")
+	if ref == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "image is required"})
+		return
+	}
+
+	domclusterServer := hs.svc.(*services.DomclusterServer)
+	nodeIDs, ok := selectImageNodes(c, domclusterServer, c.DefaultQuery("role", defaultConsistencyRole))
+	if !ok {
+		return
+	}
+	if len(nodeIDs) == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "no connected nodes match the filter"})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), DefaultRequestTimeout)
+	defer cancel()
+
+	check := domclusterServer.CheckImageConsistency(ctx, ref, nodeIDs)
+	status := http.StatusOK
+	if !check.Consistent {
+		status = http.StatusConflict
+	}
+	c.JSON(status, check)
+}
+
+// handleImagePull 在多个节点上拉取镜像，等待全部完成后返回每个节点的结果
+//
+// 请求体：image、node_ids 或 role/labels（二选一）、registry_auth（可选，Docker 的 base64 认证信息）。
+func (hs *HTTPServer) handleImagePull(c *gin.Context) {
+	var req struct {
+		Image        string            `json:"image" binding:"required"`
+		NodeIDs      []string          `json:"node_ids"`
+		Role         string            `json:"role"`
+		Labels       map[string]string `json:"labels"`
+		RegistryAuth string            `json:"registry_auth"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	domclusterServer := hs.svc.(*services.DomclusterServer)
+	nodeIDs := req.NodeIDs
+	if len(nodeIDs) == 0 {
+		if req.Role == "" && len(req.Labels) == 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "node_ids, role or labels is required"})
+			return
+		}
+		nodeIDs = domclusterServer.SelectNodes(req.Role, req.Labels)
+	}
+	if len(nodeIDs) == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "no connected nodes match the filter"})
+		return
+	}
+
+	extendDeadlines(c.Writer)
+	ctx, cancel := context.WithTimeout(c.Request.Context(), imagePullRequestTimeout)
+	defer cancel()
+
+	results := domclusterServer.PullImageOnNodes(ctx, req.Image, req.RegistryAuth, nodeIDs)
+	failed := 0
+	for _, r := range results {
+		if r.Error != "" {
+			failed++
+		}
+	}
+	status := http.StatusOK
+	if failed > 0 {
+		status = http.StatusBadGateway
+	}
+	c.JSON(status, gin.H{"image": req.Image, "results": results, "failed": failed})
+}
+
+// handleImagePullStream 通过 WebSocket 推送单个节点拉取镜像的进度
+//
+// 推送的消息为节点原样转发的 JSON：{"type":"progress","layers","current","total"}，
+// 完成时推送 {"type":"pulled","image","id","repo_digests"}，最后推送 {"type":"end","error"?}。
+func (hs *HTTPServer) handleImagePullStream(c *gin.Context) {
+	nodeID := c.Query("node_id")
+	ref := c.Query("image")
+	if nodeID == "" || ref == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "node_id and image are required"})
+		return
+	}
+
+	hs.serveAgentStream(c, "docker_image_pull", nodeID, map[string]interface{}{"image": ref})
+}
+
+// handleImageTag 在节点上为镜像添加标签
+func (hs *HTTPServer) handleImageTag(c *gin.Context) {
+	var req struct {
+		NodeID string `json:"node_id" binding:"required"`
+		Source string `json:"source" binding:"required"`
+		Target string `json:"target" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), DefaultRequestTimeout)
+	defer cancel()
+
+	result, err := hs.svc.(*services.DomclusterServer).TagNodeImage(ctx, req.NodeID, req.Source, req.Target)
+	if err != nil {
+		writeImageError(c, ctx, err)
+		return
+	}
+	c.JSON(http.StatusOK, result)
+}
+
+// handleImageRemove 删除节点上的镜像
+//
+// 查询参数：node_id、image、force。
+func (hs *HTTPServer) handleImageRemove(c *gin.Context) {
+	nodeID := c.Query("node_id")
+	ref := c.Query("image")
+	if nodeID == "" || ref == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "node_id and image are required"})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), DefaultRequestTimeout)
+	defer cancel()
+
+	result, err := hs.svc.(*services.DomclusterServer).RemoveNodeImage(ctx, nodeID, ref, c.Query("force") == "true")
+	if err != nil {
+		writeImageError(c, ctx, err)
+		return
+	}
+	c.JSON(http.StatusOK, result)
+}
+
+// handleImagePrune 清理节点上的镜像，all 为 true 时清理所有未被容器使用的镜像
+func (hs *HTTPServer) handleImagePrune(c *gin.Context) {
+	var req struct {
+		NodeID string `json:"node_id" binding:"required"`
+		All    bool   `json:"all"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	extendDeadlines(c.Writer)
+	ctx, cancel := context.WithTimeout(c.Request.Context(), imagePruneTimeout)
+	defer cancel()
+
+	result, err := hs.svc.(*services.DomclusterServer).PruneNodeImages(ctx, req.NodeID, req.All)
+	if err != nil {
+		writeImageError(c, ctx, err)
+		return
+	}
+	c.JSON(http.StatusOK, result)
+}
+
+// selectImageNodes 按角色和 label 查询参数筛选已连接的节点
+func selectImageNodes(c *gin.Context, server *services.DomclusterServer, role string) ([]string, bool) {
+	labels := make(map[string]string)
+	for _, l := range c.QueryArray("label") {
+		k, v, ok := strings.Cut(l, "=")
+		if !ok || k == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "label must be key=value"})
+			return nil, false
+		}
+		labels[k] = v
+	}
+	nodeIDs := server.SelectNodes(role, labels)
+	if nodeIDs == nil {
+		nodeIDs = []string{}
+	}
+	return nodeIDs, true
+}
+
+// writeImageError 写入镜像操作的错误响应
+func writeImageError(c *gin.Context, ctx context.Context, err error) {
+	msg := err.Error()
+	switch {
+	case ctx.Err() == context.DeadlineExceeded:
+		c.JSON(http.StatusGatewayTimeout, gin.H{"error": "request timeout"})
+	case strings.Contains(msg, "not connected"):
+		c.JSON(http.StatusNotFound, gin.H{"error": msg})
+	case strings.Contains(strings.ToLower(msg), "no such image"):
+		c.JSON(http.StatusNotFound, gin.H{"error": msg})
+	case strings.Contains(msg, "conflict"):
+		c.JSON(http.StatusConflict, gin.H{"error": msg})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": msg})
+	}
+}
@@ -0,0 +1,82 @@
+package daemon
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"d8rctl/services"
+	"d8rctl/services/logstore"
+
+	"github.com/gin-gonic/gin"
+)
+
+// maxLogSearchLimit 单次搜索最多返回的日志条数
+const maxLogSearchLimit = 5000
+
+// handleLogSearch 处理集中日志搜索请求
+//
+// 支持的查询参数：node、container、since、until、q、limit。
+// since/until 可以是 RFC3339 时间、Unix 秒，或表示“距今多久”的时长（如 30m）。
+func (hs *HTTPServer) handleLogSearch(c *gin.Context) {
+	domclusterServer, ok := hs.svc.(*services.DomclusterServer)
+	if !ok {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "invalid service type"})
+		return
+	}
+
+	query := logstore.Query{
+		NodeID:    c.Query("node"),
+		Container: c.Query("container"),
+		Text:      c.Query("q"),
+		Limit:     500,
+	}
+
+	var err error
+	if query.Since, err = parseTimeParam(c.Query("since")); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid since: " + err.Error()})
+		return
+	}
+	if query.Until, err = parseTimeParam(c.Query("until")); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid until: " + err.Error()})
+		return
+	}
+	if !query.Since.IsZero() && !query.Until.IsZero() && query.Until.Before(query.Since) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "until must not be before since"})
+		return
+	}
+	if limitStr := c.Query("limit"); limitStr != "" {
+		limit, err := strconv.Atoi(limitStr)
+		if err != nil || limit <= 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "limit must be a positive integer"})
+			return
+		}
+		query.Limit = min(limit, maxLogSearchLimit)
+	}
+
+	result, err := domclusterServer.GetLogStore().Search(query)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, result)
+}
+
+// parseTimeParam 解析时间参数，空字符串返回零值
+func parseTimeParam(value string) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+	if t, err := time.Parse(time.RFC3339Nano, value); err == nil {
+		return t, nil
+	}
+	if secs, err := strconv.ParseInt(value, 10, 64); err == nil {
+		return time.Unix(secs, 0), nil
+	}
+	if d, err := time.ParseDuration(value); err == nil && d >= 0 {
+		return time.Now().Add(-d), nil
+	}
+	return time.Time{}, fmt.Errorf("expected RFC3339 time, unix seconds or duration, got %q", value)
+}
@@ -0,0 +1,81 @@
+package daemon
+
+import (
+	"net/http"
+
+	"d8rctl/services"
+	"d8rctl/services/monitor"
+
+	"github.com/gin-gonic/gin"
+)
+
+// probeSummary 统计各状态的探针数量
+func probeSummary(probes []monitor.ProbeStatus) gin.H {
+	counts := map[string]int{"healthy": 0, "unhealthy": 0, "unknown": 0}
+	for _, p := range probes {
+		counts[p.State]++
+	}
+	return gin.H{
+		"total":     len(probes),
+		"healthy":   counts["healthy"],
+		"unhealthy": counts["unhealthy"],
+		"unknown":   counts["unknown"],
+	}
+}
+
+// handleNodeProbes 处理获取单个节点健康探针请求
+func (hs *HTTPServer) handleNodeProbes(c *gin.Context) {
+	domclusterServer, ok := hs.svc.(*services.DomclusterServer)
+	if !ok {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "invalid service type"})
+		return
+	}
+
+	nodeID := c.Param("nodeId")
+	status, exists := domclusterServer.GetMonitor().GetCollector().GetStatus(nodeID)
+	if !exists {
+		c.JSON(http.StatusNotFound, gin.H{"error": "node not found"})
+		return
+	}
+
+	probes := status.Probes
+	if probes == nil {
+		probes = []monitor.ProbeStatus{}
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"node_id":     nodeID,
+		"last_update": status.LastUpdate,
+		"summary":     probeSummary(probes),
+		"probes":      probes,
+	})
+}
+
+// handleProbes 处理获取所有节点健康探针请求，可通过 state 参数筛选
+func (hs *HTTPServer) handleProbes(c *gin.Context) {
+	domclusterServer, ok := hs.svc.(*services.DomclusterServer)
+	if !ok {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "invalid service type"})
+		return
+	}
+
+	state := c.Query("state")
+	result := make(map[string]interface{})
+	for nodeID, status := range domclusterServer.GetMonitor().GetCollector().GetAllStatus() {
+		probes := make([]monitor.ProbeStatus, 0, len(status.Probes))
+		for _, p := range status.Probes {
+			if state == "" || p.State == state {
+				probes = append(probes, p)
+			}
+		}
+		if state != "" && len(probes) == 0 {
+			continue
+		}
+		result[nodeID] = gin.H{
+			"summary": probeSummary(status.Probes),
+			"probes":  probes,
+		}
+	}
+
+	c.JSON(http.StatusOK, result)
+}
@@ -0,0 +1,189 @@
+package daemon
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+
+	"d8rctl/services"
+	"d8rctl/services/recording"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+	"go.uber.org/zap"
+)
+
+// handleListRecordings 列出终端录像
+//
+// 查询参数：node、user（登录会话的操作者身份）、since、until（筛选与时间段有重叠的会话）、
+// q（在输入输出中搜索文本）。
+func (hs *HTTPServer) handleListRecordings(c *gin.Context) {
+	domclusterServer, ok := hs.svc.(*services.DomclusterServer)
+	if !ok {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "invalid service type"})
+		return
+	}
+
+	query := recording.Query{
+		NodeID: c.Query("node"),
+		User:   c.Query("user"),
+		Text:   c.Query("q"),
+	}
+
+	var err error
+	if query.Since, err = parseTimeParam(c.Query("since")); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid since: " + err.Error()})
+		return
+	}
+	if query.Until, err = parseTimeParam(c.Query("until")); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid until: " + err.Error()})
+		return
+	}
+
+	recordings, err := domclusterServer.GetRecordingStore().List(query)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"recordings": recordings,
+		"total":      len(recordings),
+	})
+}
+
+// handleGetRecording 获取录像元数据
+func (hs *HTTPServer) handleGetRecording(c *gin.Context) {
+	domclusterServer := hs.svc.(*services.DomclusterServer)
+
+	meta, err := domclusterServer.GetRecordingStore().Get(c.Param("id"))
+	if err != nil {
+		respondRecordingError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, meta)
+}
+
+// handleDownloadRecording 下载 asciicast v2 录像文件，可直接用 asciinema play 回放
+func (hs *HTTPServer) handleDownloadRecording(c *gin.Context) {
+	domclusterServer := hs.svc.(*services.DomclusterServer)
+
+	id := c.Param("id")
+	path, err := domclusterServer.GetRecordingStore().Path(id)
+	if err != nil {
+		respondRecordingError(c, err)
+		return
+	}
+	c.FileAttachment(path, id+".cast")
+}
+
+// handleReplayRecording 通过 WebSocket 按原始节奏回放录像
+//
+// 推送的消息与终端会话相同（{"type":"output","data"}），窗口大小变化推送
+// {"type":"resize","cols","rows"}，结束时推送 {"type":"end"}。
+// 查询参数 speed 为回放倍速（默认 1），max_idle 为两次输出之间的最长等待秒数（默认 2）。
+func (hs *HTTPServer) handleReplayRecording(c *gin.Context) {
+	domclusterServer := hs.svc.(*services.DomclusterServer)
+
+	path, err := domclusterServer.GetRecordingStore().Path(c.Param("id"))
+	if err != nil {
+		respondRecordingError(c, err)
+		return
+	}
+
+	speed, err := strconv.ParseFloat(c.DefaultQuery("speed", "1"), 64)
+	if err != nil || speed <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "speed must be a positive number"})
+		return
+	}
+	maxIdle, err := strconv.ParseFloat(c.DefaultQuery("max_idle", "2"), 64)
+	if err != nil || maxIdle <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "max_idle must be a positive number"})
+		return
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		respondRecordingError(c, err)
+		return
+	}
+	defer f.Close()
+
+	conn, err := upgrader.Upgrade(c.Writer, c.Request, nil)
+	if err != nil {
+		zap.L().Sugar().Errorf("Failed to upgrade to websocket: %v", err)
+		return
+	}
+	defer conn.Close()
+
+	// 读取协程只用于发现客户端断开
+	clientGone := make(chan struct{})
+	go func() {
+		defer close(clientGone)
+		for {
+			if _, _, err := conn.ReadMessage(); err != nil {
+				return
+			}
+		}
+	}()
+
+	write := func(msg interface{}) bool {
+		conn.SetWriteDeadline(time.Now().Add(streamWriteTimeout))
+		return conn.WriteJSON(msg) == nil
+	}
+
+	var header recording.Header
+	last := 0.0
+	first := true
+	err = recording.ReadEvents(f, &header, func(e recording.Event) bool {
+		if first {
+			first = false
+			if !write(gin.H{"type": "resize", "cols": header.Width, "rows": header.Height}) {
+				return false
+			}
+		}
+		// 回放只还原终端画面，输入和标记（控制信号）不显示
+		if e.Type == "i" || e.Type == "m" {
+			return true
+		}
+
+		wait := min((e.Time-last)/speed, maxIdle)
+		last = e.Time
+		if wait > 0 {
+			select {
+			case <-time.After(time.Duration(wait * float64(time.Second))):
+			case <-clientGone:
+				return false
+			}
+		}
+
+		switch e.Type {
+		case "o":
+			return write(gin.H{"type": "output", "data": e.Data})
+		case "r":
+			var cols, rows uint
+			if _, err := fmt.Sscanf(e.Data, "%dx%d", &cols, &rows); err == nil {
+				return write(gin.H{"type": "resize", "cols": cols, "rows": rows})
+			}
+		}
+		return true
+	})
+	if err != nil {
+		write(gin.H{"type": "end", "error": err.Error()})
+		return
+	}
+	write(gin.H{"type": "end"})
+	conn.SetWriteDeadline(time.Now().Add(streamWriteTimeout))
+	conn.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""))
+}
+
+// respondRecordingError 录像不存在时返回 404，其他错误返回 500
+func respondRecordingError(c *gin.Context, err error) {
+	if os.IsNotExist(err) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "recording not found"})
+		return
+	}
+	c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+}
@@ -0,0 +1,245 @@
+package daemon
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+
+	"d8rctl/services"
+
+	"github.com/gin-gonic/gin"
+	"github.com/goccy/go-yaml"
+)
+
+// maxStackSpecSize 栈声明的大小上限
+const maxStackSpecSize = 1 << 20
+
+// handleListStacks 列出已部署的栈
+func (hs *HTTPServer) handleListStacks(c *gin.Context) {
+	serveStackList(c.Writer, hs.svc.(*services.DomclusterServer))
+}
+
+// handleStackUp 部署或更新栈，请求体为 YAML 或 JSON 格式的栈声明
+func (hs *HTTPServer) handleStackUp(c *gin.Context) {
+	serveStackUp(c.Writer, c.Request, hs.svc.(*services.DomclusterServer))
+}
+
+// handleStackStatus 查询栈中各容器的状态
+func (hs *HTTPServer) handleStackStatus(c *gin.Context) {
+	serveStackStatus(c.Writer, hs.svc.(*services.DomclusterServer), c.Param("name"))
+}
+
+// handleStackDeployment 查询栈最近一次 up 或 down 的进度
+func (hs *HTTPServer) handleStackDeployment(c *gin.Context) {
+	serveStackDeployment(c.Writer, hs.svc.(*services.DomclusterServer), c.Param("name"))
+}
+
+// handleStackDown 删除栈的全部容器
+func (hs *HTTPServer) handleStackDown(c *gin.Context) {
+	serveStackDown(c.Writer, hs.svc.(*services.DomclusterServer), c.Param("name"))
+}
+
+// JudgehostProvisionRequest 按核心部署 judgehost 的请求
+type JudgehostProvisionRequest struct {
+	Stack  string `json:"stack"`
+	NodeID string `json:"node_id"`
+	// Cores 逻辑 CPU 列表如 "2-7"，为空时使用保留 reserve_cores 后的全部核心
+	Cores string `json:"cores"`
+}
+
+// handleProvisionJudgehosts 在节点上为选定的每个物理核心部署一个 judgehost
+func (hs *HTTPServer) handleProvisionJudgehosts(c *gin.Context) {
+	serveJudgehostProvision(c.Writer, c.Request, hs.svc.(*services.DomclusterServer), c.Param("name"))
+}
+
+// handleNodeTopology 返回节点的 CPU 拓扑
+func (hs *HTTPServer) handleNodeTopology(c *gin.Context) {
+	serveNodeTopology(c.Writer, hs.svc.(*services.DomclusterServer), c.Param("nodeId"))
+}
+
+// serveStackList 列出栈，HTTP API 与 CLI 共用
+func serveStackList(w http.ResponseWriter, svc *services.DomclusterServer) {
+	names, err := svc.ListStacks()
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"stacks": names})
+}
+
+// serveStackUp 解析栈声明并开始部署，HTTP API 与 CLI 共用
+func serveStackUp(w http.ResponseWriter, r *http.Request, svc *services.DomclusterServer) {
+	data, err := io.ReadAll(io.LimitReader(r.Body, maxStackSpecSize+1))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "failed to read request: "+err.Error())
+		return
+	}
+	if len(data) > maxStackSpecSize {
+		writeError(w, http.StatusRequestEntityTooLarge, "stack spec too large")
+		return
+	}
+
+	// JSON 是 YAML 的子集，两种格式都可以
+	var spec services.StackSpec
+	if err := yaml.Unmarshal(data, &spec); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid stack spec: "+err.Error())
+		return
+	}
+
+	dep, err := svc.StackUp(&spec)
+	if err != nil {
+		writeError(w, stackErrorStatus(err), err.Error())
+		return
+	}
+	writeJSON(w, http.StatusAccepted, dep)
+}
+
+// serveStackStatus 返回栈的状态，HTTP API 与 CLI 共用
+func serveStackStatus(w http.ResponseWriter, svc *services.DomclusterServer, name string) {
+	status, err := svc.GetStackStatus(name)
+	if err != nil {
+		writeError(w, stackErrorStatus(err), err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, status)
+}
+
+// serveStackDeployment 返回栈操作的进度，HTTP API 与 CLI 共用
+func serveStackDeployment(w http.ResponseWriter, svc *services.DomclusterServer, name string) {
+	dep, err := svc.GetStackDeployment(name)
+	if err != nil {
+		writeError(w, stackErrorStatus(err), err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, dep)
+}
+
+// serveStackDown 开始删除栈，HTTP API 与 CLI 共用
+func serveStackDown(w http.ResponseWriter, svc *services.DomclusterServer, name string) {
+	dep, err := svc.StackDown(name)
+	if err != nil {
+		writeError(w, stackErrorStatus(err), err.Error())
+		return
+	}
+	writeJSON(w, http.StatusAccepted, dep)
+}
+
+// serveJudgehostProvision 开始按核心部署 judgehost，stack 为空时从请求体读取，HTTP API 与 CLI 共用
+func serveJudgehostProvision(w http.ResponseWriter, r *http.Request, svc *services.DomclusterServer, stack string) {
+	var req JudgehostProvisionRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid request: "+err.Error())
+		return
+	}
+	if stack != "" {
+		req.Stack = stack
+	}
+	if req.Stack == "" || req.NodeID == "" {
+		writeError(w, http.StatusBadRequest, "stack and node_id are required")
+		return
+	}
+
+	dep, err := svc.ProvisionJudgehosts(req.Stack, req.NodeID, req.Cores)
+	if err != nil {
+		writeError(w, stackErrorStatus(err), err.Error())
+		return
+	}
+	writeJSON(w, http.StatusAccepted, dep)
+}
+
+// serveNodeTopology 返回节点的 CPU 拓扑，HTTP API 与 CLI 共用
+func serveNodeTopology(w http.ResponseWriter, svc *services.DomclusterServer, nodeID string) {
+	topo, err := svc.GetNodeTopology(nodeID)
+	if err != nil {
+		writeError(w, stackErrorStatus(err), err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, topo)
+}
+
+// stackErrorStatus 根据错误选择状态码
+func stackErrorStatus(err error) int {
+	msg := err.Error()
+	switch {
+	case strings.Contains(msg, "not found"):
+		return http.StatusNotFound
+	case strings.Contains(msg, "conflict"), strings.Contains(msg, "not connected"), strings.Contains(msg, "is unknown"):
+		return http.StatusConflict
+	case strings.Contains(msg, "invalid"), strings.Contains(msg, "required"), strings.Contains(msg, "must"),
+		strings.Contains(msg, "needs"), strings.Contains(msg, "not online"), strings.Contains(msg, "all reserved"),
+		strings.Contains(msg, "not a judgehost node"):
+		return http.StatusBadRequest
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+// handleStartRollout 开始滚动更新栈的 judgehost 镜像
+func (hs *HTTPServer) handleStartRollout(c *gin.Context) {
+	serveRolloutStart(c.Writer, c.Request, hs.svc.(*services.DomclusterServer), c.Param("name"))
+}
+
+// handleGetRollout 查询栈最近一次 rollout 的状态
+func (hs *HTTPServer) handleGetRollout(c *gin.Context) {
+	serveRolloutStatus(c.Writer, hs.svc.(*services.DomclusterServer), c.Param("name"))
+}
+
+// handleResumeRollout 继续已暂停的 rollout
+func (hs *HTTPServer) handleResumeRollout(c *gin.Context) {
+	serveRolloutAction(c.Writer, hs.svc.(*services.DomclusterServer), c.Param("name"), "resume")
+}
+
+// handleRollbackRollout 回滚已暂停的 rollout
+func (hs *HTTPServer) handleRollbackRollout(c *gin.Context) {
+	serveRolloutAction(c.Writer, hs.svc.(*services.DomclusterServer), c.Param("name"), "rollback")
+}
+
+// serveRolloutStart 开始 rollout，stack 为空时从请求体读取，HTTP API 与 CLI 共用
+func serveRolloutStart(w http.ResponseWriter, r *http.Request, svc *services.DomclusterServer, stack string) {
+	var req services.RolloutRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid request: "+err.Error())
+		return
+	}
+	if stack != "" {
+		req.Stack = stack
+	}
+
+	rollout, err := svc.StartRollout(req)
+	if err != nil {
+		writeError(w, stackErrorStatus(err), err.Error())
+		return
+	}
+	writeJSON(w, http.StatusAccepted, rollout)
+}
+
+// serveRolloutStatus 返回 rollout 的状态，HTTP API 与 CLI 共用
+func serveRolloutStatus(w http.ResponseWriter, svc *services.DomclusterServer, stack string) {
+	rollout, err := svc.GetRollout(stack)
+	if err != nil {
+		writeError(w, stackErrorStatus(err), err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, rollout)
+}
+
+// serveRolloutAction 继续或回滚已暂停的 rollout，HTTP API 与 CLI 共用
+func serveRolloutAction(w http.ResponseWriter, svc *services.DomclusterServer, stack, action string) {
+	var rollout *services.Rollout
+	var err error
+	switch action {
+	case "resume":
+		rollout, err = svc.ResumeRollout(stack)
+	case "rollback":
+		rollout, err = svc.RollbackRollout(stack)
+	default:
+		writeError(w, http.StatusNotFound, "not found")
+		return
+	}
+	if err != nil {
+		writeError(w, stackErrorStatus(err), err.Error())
+		return
+	}
+	writeJSON(w, http.StatusAccepted, rollout)
+}
@@ -0,0 +1,127 @@
+package daemon
+
+import (
+	"net/http"
+	"time"
+
+	"d8rctl/services"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+	"go.uber.org/zap"
+)
+
+const (
+	// streamWriteTimeout 向浏览器写入一条流消息的超时时间
+	streamWriteTimeout = 10 * time.Second
+	// streamPingInterval 流式 WebSocket 的心跳间隔，用于及时发现已断开的客户端
+	streamPingInterval = 30 * time.Second
+)
+
+// handleDockerLogsFollow 通过 WebSocket 实时跟随容器日志
+//
+// 推送的消息为节点原样转发的 JSON：{"type":"logs","lines":[{"stream","ts","line"}]}，
+// 日志结束（容器停止、出错或节点断开）时推送 {"type":"end","error"?} 并关闭连接。
+func (hs *HTTPServer) handleDockerLogsFollow(c *gin.Context) {
+	nodeID := c.Query("node_id")
+	containerID := c.Query("container_id")
+	if nodeID == "" || containerID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "node_id and container_id are required"})
+		return
+	}
+
+	server := hs.svc.(*services.DomclusterServer)
+	if !server.IsNodeConnected(nodeID) {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "node not connected"})
+		return
+	}
+
+	timestamps := c.Query("timestamps")
+	hs.serveAgentStream(c, "docker_logs_follow", nodeID, map[string]interface{}{
+		"container_id": containerID,
+		"since":        c.Query("since"),
+		"tail":         c.DefaultQuery("tail", "100"),
+		"timestamps":   timestamps == "true" || timestamps == "1",
+	})
+}
+
+// handleDockerStatsStream 通过 WebSocket 每秒推送容器资源统计
+//
+// container_id 为空时推送节点上所有运行中的容器，消息格式为
+// {"type":"stats","timestamp","containers":[{"id","name","cpu_percent","memory_percent","network_rx_rate",...}]}。
+func (hs *HTTPServer) handleDockerStatsStream(c *gin.Context) {
+	nodeID := c.Query("node_id")
+	if nodeID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "node_id is required"})
+		return
+	}
+
+	server := hs.svc.(*services.DomclusterServer)
+	if !server.IsNodeConnected(nodeID) {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "node not connected"})
+		return
+	}
+
+	hs.serveAgentStream(c, "docker_stats_stream", nodeID, map[string]interface{}{
+		"container_id": c.Query("container_id"),
+	})
+}
+
+// serveAgentStream 升级为 WebSocket，在节点上发起流式命令并把节点推送的消息转发给客户端
+//
+// 客户端断开时取消节点上的流，节点结束流时关闭连接。
+func (hs *HTTPServer) serveAgentStream(c *gin.Context, cmd, nodeID string, data map[string]interface{}) {
+	conn, err := upgrader.Upgrade(c.Writer, c.Request, nil)
+	if err != nil {
+		zap.L().Sugar().Errorf("Failed to upgrade to websocket: %v", err)
+		return
+	}
+	defer conn.Close()
+
+	server := hs.svc.(*services.DomclusterServer)
+	st, err := server.OpenStream(nodeID, cmd, data)
+	if err != nil {
+		conn.SetWriteDeadline(time.Now().Add(streamWriteTimeout))
+		conn.WriteJSON(gin.H{"type": "end", "error": err.Error()})
+		return
+	}
+	defer st.Close()
+
+	// 读取协程只用于发现客户端断开
+	clientGone := make(chan struct{})
+	go func() {
+		defer close(clientGone)
+		for {
+			if _, _, err := conn.ReadMessage(); err != nil {
+				return
+			}
+		}
+	}()
+
+	ping := time.NewTicker(streamPingInterval)
+	defer ping.Stop()
+
+	for {
+		select {
+		case msg, ok := <-st.C:
+			if !ok {
+				conn.SetWriteDeadline(time.Now().Add(streamWriteTimeout))
+				conn.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""))
+				return
+			}
+			conn.SetWriteDeadline(time.Now().Add(streamWriteTimeout))
+			if err := conn.WriteMessage(websocket.TextMessage, msg); err != nil {
+				zap.L().Sugar().Debugf("Stream %s: client write failed: %v", st.ID, err)
+				return
+			}
+		case <-ping.C:
+			conn.SetWriteDeadline(time.Now().Add(streamWriteTimeout))
+			if err := conn.WriteMessage(websocket.PingMessage, nil); err != nil {
+				return
+			}
+		case <-clientGone:
+			zap.L().Sugar().Infof("Stream %s: client disconnected", st.ID)
+			return
+		}
+	}
+}
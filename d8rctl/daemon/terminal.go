@@ -4,16 +4,25 @@ import (
 	"encoding/json"
 	"fmt"
 	"net/http"
+	"strconv"
 	"sync"
 	"time"
 
+	"d8rctl/auth"
 	"d8rctl/services"
+	"d8rctl/services/recording"
 
 	"github.com/gin-gonic/gin"
 	"github.com/gorilla/websocket"
 	"go.uber.org/zap"
 )
 
+// 节点未收到窗口大小时使用的默认值，与 domclusterd 一致
+const (
+	defaultTerminalCols = 80
+	defaultTerminalRows = 24
+)
+
 var upgrader = websocket.Upgrader{
 	ReadBufferSize:  1024,
 	WriteBufferSize: 1024,
@@ -22,17 +31,30 @@ var upgrader = websocket.Upgrader{
 	},
 }
 
-// TerminalSession 终端会话
-type TerminalSession struct {
-	nodeID    string
-	sessionID string
-	conn      *websocket.Conn
-	server    *services.DomclusterServer
-	closeChan chan struct{}
-	mu        sync.Mutex
+// terminalInput 浏览器终端发送的消息
+type terminalInput struct {
+	Type   string `json:"type"`
+	Data   string `json:"data"`
+	Cols   uint   `json:"cols"`
+	Rows   uint   `json:"rows"`
+	Signal string `json:"signal"`
+}
+
+// agentTerminalSession 通过节点流运行的交互式终端（主机 PTY shell、容器内 exec）
+//
+// 浏览器与节点之间的消息格式相同：输入为 input/resize/signal，输出为 output，
+// 进程退出时节点推送 exit，流结束时推送 end。
+type agentTerminalSession struct {
+	conn   *websocket.Conn
+	stream *services.AgentStream
+	rec    *recording.Recorder
+	mu     sync.Mutex
 }
 
 // handleTerminalWebSocket 处理终端 WebSocket 连接
+//
+// 默认在节点上分配 PTY 运行长期 shell；指定 container_id 时在容器内执行交互式会话。
+// 可选参数 cols、rows 为初始窗口大小。
 func (hs *HTTPServer) handleTerminalWebSocket(c *gin.Context) {
 	nodeID := c.Query("node_id")
 	if nodeID == "" {
@@ -40,6 +62,26 @@ func (hs *HTTPServer) handleTerminalWebSocket(c *gin.Context) {
 		return
 	}
 
+	// 指定容器时在容器内执行交互式会话
+	if containerID := c.Query("container_id"); containerID != "" {
+		hs.handleContainerTerminal(c, nodeID, containerID)
+		return
+	}
+
+	hs.serveTerminal(c, "shell", nodeID, "shell_session", map[string]interface{}{})
+}
+
+// serveTerminal 升级为 WebSocket，在节点上发起终端流并录制整个会话
+//
+// 每个会话都以 asciicast v2 格式录制，录像无法创建时拒绝建立会话。
+func (hs *HTTPServer) serveTerminal(c *gin.Context, kind, nodeID, cmd string, data map[string]interface{}) {
+	server := hs.svc.(*services.DomclusterServer)
+	if !server.IsNodeConnected(nodeID) {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "node not connected"})
+		return
+	}
+	addTerminalSize(c, data)
+
 	// 升级为 WebSocket 连接
 	conn, err := upgrader.Upgrade(c.Writer, c.Request, nil)
 	if err != nil {
@@ -48,37 +90,96 @@ func (hs *HTTPServer) handleTerminalWebSocket(c *gin.Context) {
 	}
 	defer conn.Close()
 
-	sessionID := fmt.Sprintf("terminal_%d", time.Now().UnixNano())
-	
-	session := &TerminalSession{
-		nodeID:    nodeID,
-		sessionID: sessionID,
-		conn:      conn,
-		server:    hs.svc.(*services.DomclusterServer),
-		closeChan: make(chan struct{}),
-	}
+	session := &agentTerminalSession{conn: conn}
 
-	zap.L().Sugar().Infof("Terminal session %s started for node %s", sessionID, nodeID)
+	st, err := server.OpenInteractiveStream(nodeID, cmd, data)
+	if err != nil {
+		session.writeOutput(fmt.Sprintf("Error: %v\r\n", err))
+		return
+	}
+	defer st.Close()
+	session.stream = st
 
-	// 发送欢迎消息
-	welcomeMsg := fmt.Sprintf("Connected to node %s\r\n", nodeID)
-	if err := session.writeMessage(welcomeMsg); err != nil {
-		zap.L().Sugar().Errorf("Failed to send welcome message: %v", err)
+	containerID, _ := data["container_id"].(string)
+	width, height := uint(defaultTerminalCols), uint(defaultTerminalRows)
+	if cols, ok := data["cols"].(int); ok {
+		width = uint(cols)
+	}
+	if rows, ok := data["rows"].(int); ok {
+		height = uint(rows)
+	}
+	meta := recording.Metadata{
+		ID:          st.ID,
+		Kind:        kind,
+		Auth:        recording.AuthNone,
+		RemoteAddr:  c.ClientIP(),
+		NodeID:      nodeID,
+		ContainerID: containerID,
+		StartTime:   time.Now(),
+		Width:       width,
+		Height:      height,
+	}
+	// 操作者身份只取自认证中间件确认的登录会话，不接受客户端提供的名称
+	if session, ok := auth.SessionFromContext(c); ok {
+		meta.Auth = recording.AuthSession
+		meta.User = session.User
+		meta.LoginSession = session.ID
+	}
+	rec, err := server.GetRecordingStore().Start(meta)
+	if err != nil {
+		zap.L().Sugar().Errorf("Failed to start terminal recording: %v", err)
+		session.writeOutput("Error: failed to start session recording\r\n")
 		return
 	}
+	defer rec.Close()
+	session.rec = rec
 
-	// 处理消息
-	go session.handleMessages()
+	zap.L().Sugar().Infof("Terminal session %s (%s) started for node %s from %s (auth %s, user %q, login session %s)",
+		st.ID, kind, nodeID, c.ClientIP(), meta.Auth, meta.User, meta.LoginSession)
+	session.run()
+	zap.L().Sugar().Infof("Terminal session %s closed", st.ID)
+}
 
-	// 等待连接关闭
-	<-session.closeChan
-	zap.L().Sugar().Infof("Terminal session %s closed", sessionID)
+// addTerminalSize 读取请求中的初始窗口大小
+func addTerminalSize(c *gin.Context, data map[string]interface{}) {
+	if cols, err := strconv.Atoi(c.Query("cols")); err == nil && cols > 0 {
+		data["cols"] = cols
+	}
+	if rows, err := strconv.Atoi(c.Query("rows")); err == nil && rows > 0 {
+		data["rows"] = rows
+	}
 }
 
-// handleMessages 处理来自客户端的消息
-func (s *TerminalSession) handleMessages() {
-	defer close(s.closeChan)
+// run 双向转发消息，任意一端结束时返回
+func (s *agentTerminalSession) run() {
+	clientGone := make(chan struct{})
+	go func() {
+		defer close(clientGone)
+		s.readClient()
+	}()
+
+	for {
+		select {
+		case msg, ok := <-s.stream.C:
+			if !ok {
+				if err := s.stream.Err(); err != nil {
+					s.writeOutput(fmt.Sprintf("\r\nError: %v\r\n", err))
+				}
+				return
+			}
+			if !s.relay(msg) {
+				return
+			}
+		case <-clientGone:
+			return
+		}
+	}
+}
 
+// readClient 读取浏览器输入并转发给节点
+//
+// 只转发已知字段，避免客户端在消息中夹带 cmd 字段调用节点上的其他处理器。
+func (s *agentTerminalSession) readClient() {
 	for {
 		_, message, err := s.conn.ReadMessage()
 		if err != nil {
@@ -88,93 +189,71 @@ func (s *TerminalSession) handleMessages() {
 			return
 		}
 
-		// 解析消息
-		var msg map[string]interface{}
-		if err := json.Unmarshal(message, &msg); err != nil {
+		var in terminalInput
+		if err := json.Unmarshal(message, &in); err != nil {
 			zap.L().Sugar().Warnf("Failed to parse message: %v", err)
 			continue
 		}
 
-		msgType, ok := msg["type"].(string)
-		if !ok {
-			continue
-		}
-
-		switch msgType {
+		var payload map[string]interface{}
+		switch in.Type {
 		case "input":
-			// 处理用户输入
-			input, ok := msg["data"].(string)
-			if !ok {
-				continue
-			}
-			s.handleInput(input)
-
+			payload = map[string]interface{}{"cmd": "stream_input", "type": "input", "data": in.Data}
+			s.rec.Input(in.Data)
 		case "resize":
-			// 处理终端大小调整
-			// 这里可以实现终端大小调整功能
-			zap.L().Sugar().Debugf("Terminal resize: %v", msg)
+			payload = map[string]interface{}{"cmd": "stream_input", "type": "resize", "cols": in.Cols, "rows": in.Rows}
+			s.rec.Resize(in.Cols, in.Rows)
+		case "signal":
+			payload = map[string]interface{}{"cmd": "stream_input", "type": "signal", "signal": in.Signal}
+			s.rec.Signal(in.Signal)
+		default:
+			continue
+		}
+		if err := s.stream.Send("stream_input", payload); err != nil {
+			s.writeOutput(fmt.Sprintf("\r\nError: %v\r\n", err))
+			return
 		}
 	}
 }
 
-// handleInput 处理用户输入
-func (s *TerminalSession) handleInput(input string) {
-	// 将输入发送到节点执行
-	reqID := fmt.Sprintf("shell_%d", time.Now().UnixNano())
-	
-	data := map[string]interface{}{
-		"command": input,
-		"session_id": s.sessionID,
-	}
-	
-	dataBytes, err := json.Marshal(data)
-	if err != nil {
-		zap.L().Sugar().Errorf("Failed to marshal shell command: %v", err)
-		return
+// relay 将节点消息转换为浏览器终端消息，返回 false 表示会话结束
+func (s *agentTerminalSession) relay(msg []byte) bool {
+	var out struct {
+		Type     string `json:"type"`
+		Data     string `json:"data"`
+		ExitCode int    `json:"exit_code"`
+		Error    string `json:"error"`
 	}
-
-	// 注册响应处理器
-	responseChan := make(chan []byte, 1)
-	s.server.RegisterShellResponse(reqID, responseChan)
-
-	// 发送命令到节点
-	if err := s.server.SendToNode(s.nodeID, "shell_exec", reqID, dataBytes); err != nil {
-		zap.L().Sugar().Errorf("Failed to send shell command: %v", err)
-		s.writeMessage(fmt.Sprintf("Error: %v\r\n", err))
-		return
+	if err := json.Unmarshal(msg, &out); err != nil {
+		return true
 	}
 
-	// 等待响应（带超时）
-	select {
-	case response := <-responseChan:
-		var result map[string]interface{}
-		if err := json.Unmarshal(response, &result); err != nil {
-			zap.L().Sugar().Errorf("Failed to unmarshal response: %v", err)
-			return
+	switch out.Type {
+	case "output":
+		return s.writeOutput(out.Data) == nil
+	case "exit":
+		s.writeOutput(fmt.Sprintf("\r\n[process exited with code %d]\r\n", out.ExitCode))
+	case "end":
+		if out.Error != "" {
+			s.writeOutput(fmt.Sprintf("\r\nError: %s\r\n", out.Error))
 		}
-
-		// 发送输出到客户端
-		if output, ok := result["output"].(string); ok {
-			s.writeMessage(output)
-		}
-		if stderr, ok := result["stderr"].(string); ok && stderr != "" {
-			s.writeMessage(stderr)
-		}
-
-	case <-time.After(30 * time.Second):
-		s.writeMessage("Command timeout\r\n")
+		return false
 	}
+	return true
 }
 
-// writeMessage 写入消息到 WebSocket
-func (s *TerminalSession) writeMessage(message string) error {
+// writeOutput 写入输出到 WebSocket 并记录到录像
+func (s *agentTerminalSession) writeOutput(data string) error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
-	msg := map[string]interface{}{
-		"type": "output",
-		"data": message,
+	if s.rec != nil {
+		s.rec.Output(data)
 	}
 
-	return s.conn.WriteJSON(msg)
+	s.conn.SetWriteDeadline(time.Now().Add(streamWriteTimeout))
+	return s.conn.WriteJSON(map[string]interface{}{
+		"type": "output",
+		"data": data,
+	})
 }
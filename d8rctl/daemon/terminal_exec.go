@@ -0,0 +1,22 @@
+package daemon
+
+import (
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// handleContainerTerminal 处理容器内的交互式终端，由 handleTerminalWebSocket 在指定 container_id 时调用
+//
+// 可选参数：user、workdir、cmd（以空格分隔，默认优先 bash）、cols、rows。
+func (hs *HTTPServer) handleContainerTerminal(c *gin.Context, nodeID, containerID string) {
+	data := map[string]interface{}{
+		"container_id": containerID,
+		"user":         c.Query("user"),
+		"workdir":      c.Query("workdir"),
+	}
+	if cmd := strings.Fields(c.Query("cmd")); len(cmd) > 0 {
+		data["command"] = cmd
+	}
+	hs.serveTerminal(c, "exec", nodeID, "docker_exec_interactive", data)
+}
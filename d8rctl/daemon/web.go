@@ -25,7 +25,7 @@ func (hs *HTTPServer) handleLogin(c *gin.Context) {
 		return
 	}
 
-	token, err := auth.GetSessionManager().CreateSession()
+	token, session, err := auth.GetSessionManager().CreateSession(auth.PasswordUser, c.ClientIP())
 	if err != nil {
 		zap.L().Sugar().Error("Failed to create session", zap.Error(err))
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to create session"})
@@ -47,7 +47,7 @@ func (hs *HTTPServer) handleLogin(c *gin.Context) {
 		"message": "login successful",
 	})
 
-	zap.L().Sugar().Info("User logged in successfully")
+	zap.L().Sugar().Infof("Login from %s succeeded (user %s, session %s)", session.RemoteAddr, session.User, session.ID)
 }
 
 // handleLogout 处理登出请求
@@ -75,6 +75,228 @@ func main() {
 			fmt.Printf("Unknown pod command: %s\n", podCommand)
 			os.Exit(1)
 		}
+	case "top":
+		if err := cli.Top(os.Args[2:]); err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+	case "image":
+		if len(os.Args) < 3 {
+			fmt.Println("Usage: d8rctl image <command>")
+			fmt.Println("Commands:")
+			fmt.Println("  dist    Distribute an image to nodes without a registry")
+			os.Exit(1)
+		}
+		imageCommand := os.Args[2]
+		switch imageCommand {
+		case "dist":
+			if err := cli.ImageDist(os.Args[3:]); err != nil {
+				fmt.Printf("Error: %v\n", err)
+				os.Exit(1)
+			}
+		default:
+			fmt.Printf("Unknown image command: %s\n", imageCommand)
+			os.Exit(1)
+		}
+	case "container":
+		if len(os.Args) < 3 {
+			fmt.Println("Usage: d8rctl container <command>")
+			fmt.Println("Commands:")
+			fmt.Println("  create      Create a container on a node from a spec file")
+			fmt.Println("  recreate    Replace a container on a node with one built from a spec file")
+			fmt.Println("  rm          Remove a container from a node")
+			os.Exit(1)
+		}
+		containerCommand := os.Args[2]
+		var err error
+		switch containerCommand {
+		case "create":
+			err = cli.ContainerCreate(os.Args[3:])
+		case "recreate":
+			err = cli.ContainerRecreate(os.Args[3:])
+		case "rm":
+			err = cli.ContainerRemove(os.Args[3:])
+		default:
+			fmt.Printf("Unknown container command: %s\n", containerCommand)
+			os.Exit(1)
+		}
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+	case "stack":
+		if len(os.Args) < 3 {
+			fmt.Println("Usage: d8rctl stack <command>")
+			fmt.Println("Commands:")
+			fmt.Println("  up        Deploy or update a DomJudge stack from a spec file")
+			fmt.Println("  down      Remove all containers of a stack")
+			fmt.Println("  status    Show the containers of a stack")
+			os.Exit(1)
+		}
+		stackCommand := os.Args[2]
+		var err error
+		switch stackCommand {
+		case "up":
+			err = cli.StackUp(os.Args[3:])
+		case "down":
+			err = cli.StackDown(os.Args[3:])
+		case "status":
+			err = cli.StackStatus(os.Args[3:])
+		default:
+			fmt.Printf("Unknown stack command: %s\n", stackCommand)
+			os.Exit(1)
+		}
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+	case "judgehost":
+		if len(os.Args) < 3 {
+			fmt.Println("Usage: d8rctl judgehost <command>")
+			fmt.Println("Commands:")
+			fmt.Println("  provision    Run one judgehost per physical core on a node")
+			fmt.Println("  topology     Show the physical cores and SMT siblings of a node")
+			os.Exit(1)
+		}
+		judgehostCommand := os.Args[2]
+		var err error
+		switch judgehostCommand {
+		case "provision":
+			err = cli.JudgehostProvision(os.Args[3:])
+		case "topology":
+			err = cli.JudgehostTopology(os.Args[3:])
+		default:
+			fmt.Printf("Unknown judgehost command: %s\n", judgehostCommand)
+			os.Exit(1)
+		}
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+	case "domjudge":
+		if len(os.Args) < 3 {
+			fmt.Println("Usage: d8rctl domjudge <command>")
+			fmt.Println("Commands:")
+			fmt.Println("  config        Show or set the domserver API URL and credentials")
+			fmt.Println("  judgehosts    List judgehosts registered in DomJudge")
+			fmt.Println("  enable        Enable a judgehost (or all judgehosts of a node)")
+			fmt.Println("  disable       Disable a judgehost (or all judgehosts of a node)")
+			os.Exit(1)
+		}
+		domjudgeCommand := os.Args[2]
+		var err error
+		switch domjudgeCommand {
+		case "config":
+			err = cli.DomjudgeConfig(os.Args[3:])
+		case "judgehosts":
+			err = cli.DomjudgeJudgehosts(os.Args[3:])
+		case "enable":
+			err = cli.DomjudgeSetEnabled(os.Args[3:], true)
+		case "disable":
+			err = cli.DomjudgeSetEnabled(os.Args[3:], false)
+		default:
+			fmt.Printf("Unknown domjudge command: %s\n", domjudgeCommand)
+			os.Exit(1)
+		}
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+	case "autoscale":
+		if len(os.Args) < 3 {
+			fmt.Println("Usage: d8rctl autoscale <command>")
+			fmt.Println("Commands:")
+			fmt.Println("  set       Create or update the judgehost autoscale policy of a stack")
+			fmt.Println("  status    Show autoscale state and recent scaling events")
+			fmt.Println("  pin       Pin the number of running judgehosts, e.g. during the freeze")
+			fmt.Println("  unpin     Resume scaling on the judging queue")
+			fmt.Println("  rm        Remove the autoscale policy of a stack")
+			os.Exit(1)
+		}
+		autoscaleCommand := os.Args[2]
+		var err error
+		switch autoscaleCommand {
+		case "set":
+			err = cli.AutoscaleSet(os.Args[3:])
+		case "status":
+			err = cli.AutoscaleStatus(os.Args[3:])
+		case "pin":
+			err = cli.AutoscalePin(os.Args[3:])
+		case "unpin":
+			err = cli.AutoscaleUnpin(os.Args[3:])
+		case "rm":
+			err = cli.AutoscaleRemove(os.Args[3:])
+		default:
+			fmt.Printf("Unknown autoscale command: %s\n", autoscaleCommand)
+			os.Exit(1)
+		}
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+	case "rollout":
+		if len(os.Args) < 3 {
+			fmt.Println("Usage: d8rctl rollout <command>")
+			fmt.Println("Commands:")
+			fmt.Println("  start       Update judgehosts of a stack to a new image in batches")
+			fmt.Println("  status      Show the progress of the latest rollout")
+			fmt.Println("  resume      Continue a halted rollout")
+			fmt.Println("  rollback    Restore the previous image on judgehosts of a halted rollout")
+			os.Exit(1)
+		}
+		rolloutCommand := os.Args[2]
+		var err error
+		switch rolloutCommand {
+		case "start":
+			err = cli.RolloutStart(os.Args[3:])
+		case "status":
+			err = cli.RolloutStatus(os.Args[3:])
+		case "resume":
+			err = cli.RolloutResume(os.Args[3:])
+		case "rollback":
+			err = cli.RolloutRollback(os.Args[3:])
+		default:
+			fmt.Printf("Unknown rollout command: %s\n", rolloutCommand)
+			os.Exit(1)
+		}
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+	case "desired":
+		if len(os.Args) < 3 {
+			fmt.Println("Usage: d8rctl desired <command>")
+			fmt.Println("Commands:")
+			fmt.Println("  status    Show the desired containers of nodes and the drift they report")
+			fmt.Println("  set       Replace the desired containers of a node from a file")
+			fmt.Println("  clear     Remove a source's containers from the desired state of a node")
+			fmt.Println("  sync      Push the containers of a stack to the desired state of its nodes")
+			os.Exit(1)
+		}
+		desiredCommand := os.Args[2]
+		var err error
+		switch desiredCommand {
+		case "status":
+			err = cli.DesiredStatus(os.Args[3:])
+		case "set":
+			err = cli.DesiredSet(os.Args[3:])
+		case "clear":
+			err = cli.DesiredClear(os.Args[3:])
+		case "sync":
+			err = cli.DesiredSync(os.Args[3:])
+		default:
+			fmt.Printf("Unknown desired command: %s\n", desiredCommand)
+			os.Exit(1)
+		}
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+	case "cp":
+		if err := cli.Cp(os.Args[2:]); err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
 	default:
 		fmt.Printf("Unknown command: %s\n", command)
 		printUsage()
@@ -133,4 +355,14 @@ func printUsage() {
 	fmt.Println("  restart          Restart daemon")
 	fmt.Println("  password [reset] Show password info or reset password")
 	fmt.Println("  pod list         List all connected domclusterd nodes")
+	fmt.Println("  top <node>       Show top processes on a node (-sort cpu|memory, -n count)")
+	fmt.Println("  cp <src> <dst>   Copy files to/from <node>:<path> or <node>/<container>:<path>")
+	fmt.Println("  image dist <archive.tar | image...>  Distribute an image to nodes (-node, -role, -label)")
+	fmt.Println("  container create|recreate|rm         Manage containers on a node from a spec file (-node, -f)")
+	fmt.Println("  stack up|down|status                 Deploy a DomJudge stack (MariaDB, domserver, judgehosts)")
+	fmt.Println("  judgehost provision|topology         Pin one judgehost per physical core (-stack, -node, -cores)")
+	fmt.Println("  domjudge config|judgehosts|enable|disable  Query and toggle judgehosts through the DomJudge API")
+	fmt.Println("  autoscale set|status|pin|unpin|rm    Scale judgehosts on the judging queue (-stack, -min, -max)")
+	fmt.Println("  rollout start|status|resume|rollback Update judgehost images in health-gated batches (-stack, -image)")
+	fmt.Println("  desired status|set|clear|sync        Containers each node keeps running on its own (-node, -f)")
 }
\ No newline at end of file
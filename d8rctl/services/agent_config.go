@@ -0,0 +1,105 @@
+package services
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+
+	pb "domcluster/api/proto"
+	"go.uber.org/zap"
+)
+
+// NodeLabels 获取节点的生效标签：节点上报的标签叠加控制端分配的标签
+func (s *DomclusterServer) NodeLabels(nodeID string) map[string]string {
+	labels := make(map[string]string)
+	if info, ok := s.nodeManager.GetNode(nodeID); ok {
+		for k, v := range info.Labels {
+			labels[k] = v
+		}
+	}
+	for k, v := range s.agentConfig.NodeLabels(nodeID) {
+		labels[k] = v
+	}
+	return labels
+}
+
+// SelectNodes 返回已连接且匹配角色和标签的节点 ID（按 ID 排序），role 为空或 labels 为空时不按该条件过滤
+func (s *DomclusterServer) SelectNodes(role string, labels map[string]string) []string {
+	var result []string
+	for id, info := range s.nodeManager.ListNodes() {
+		if !s.IsNodeConnected(id) {
+			continue
+		}
+		if role != "" && info.Role != role {
+			continue
+		}
+		nodeLabels := s.NodeLabels(id)
+		matched := true
+		for k, v := range labels {
+			if nodeLabels[k] != v {
+				matched = false
+				break
+			}
+		}
+		if matched {
+			result = append(result, id)
+		}
+	}
+	sort.Strings(result)
+	return result
+}
+
+// PushAgentConfig 将生效配置下发到指定节点
+func (s *DomclusterServer) PushAgentConfig(nodeID string) error {
+	cfg, revision := s.agentConfig.Effective(nodeID, s.NodeLabels(nodeID))
+
+	dataBytes, err := json.Marshal(map[string]interface{}{
+		"revision": revision,
+		"config":   cfg,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to marshal agent config: %w", err)
+	}
+
+	reqID := fmt.Sprintf("agent_config_%s_%d", nodeID, revision)
+	if err := s.SendToNode(nodeID, "agent_config", reqID, dataBytes); err != nil {
+		return err
+	}
+
+	zap.L().Sugar().Infof("Pushed agent config revision %d to node %s", revision, nodeID)
+	return nil
+}
+
+// PushAgentConfigAll 将生效配置下发到所有已连接节点
+func (s *DomclusterServer) PushAgentConfigAll() {
+	for nodeID := range s.nodeManager.ListNodes() {
+		if !s.IsNodeConnected(nodeID) {
+			continue
+		}
+		if err := s.PushAgentConfig(nodeID); err != nil {
+			zap.L().Sugar().Warnf("Failed to push agent config to %s: %v", nodeID, err)
+		}
+	}
+}
+
+// handleAgentConfigAck 处理节点对配置下发的确认
+func (s *DomclusterServer) handleAgentConfigAck(req *pb.PublishRequest) *pb.PublishResponse {
+	var ack struct {
+		Revision uint64 `json:"revision"`
+		Error    string `json:"error"`
+	}
+	if err := json.Unmarshal(req.Data, &ack); err != nil {
+		return errorResponse(req.ReqId, "invalid data")
+	}
+
+	s.agentConfig.RecordApplied(req.Issuer, ack.Revision, ack.Error)
+	if ack.Error != "" {
+		zap.L().Sugar().Warnf("Node %s failed to apply agent config revision %d: %s", req.Issuer, ack.Revision, ack.Error)
+	} else {
+		zap.L().Sugar().Infof("Node %s applied agent config revision %d", req.Issuer, ack.Revision)
+	}
+
+	return successResponse(req.ReqId, map[string]interface{}{
+		"message": "acknowledged",
+	})
+}
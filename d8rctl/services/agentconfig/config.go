@@ -0,0 +1,196 @@
+package agentconfig
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// 节点未设置心跳参数时使用的默认值（秒），与 domclusterd 一致
+const (
+	defaultHeartbeatInterval = 5
+	defaultHeartbeatTimeout  = 15
+)
+
+// AgentConfig 下发给 domclusterd 的运行时配置
+//
+// 所有字段均为可选，未设置的字段沿用下一层的值：
+// 默认配置 < 标签配置 < 节点配置。时间单位均为秒。
+type AgentConfig struct {
+	ReportInterval           *int      `json:"report_interval,omitempty"`
+	HeartbeatInterval        *int      `json:"heartbeat_interval,omitempty"`
+	HeartbeatTimeout         *int      `json:"heartbeat_timeout,omitempty"`
+	ShellTimeout             *int      `json:"shell_timeout,omitempty"`
+	TerminalIdleTimeout      *int      `json:"terminal_idle_timeout,omitempty"`
+	DiskMounts               *[]string `json:"disk_mounts,omitempty"`
+	IncludeVirtualInterfaces *bool     `json:"include_virtual_interfaces,omitempty"`
+	ExcludeInterfaces        *[]string `json:"exclude_interfaces,omitempty"`
+	// Probes 健康探针，设置后替换节点本地定义的全部探针
+	Probes *[]ProbeSpec `json:"probes,omitempty"`
+}
+
+// ProbeSpec 健康探针定义，字段含义与 domclusterd 一致
+type ProbeSpec struct {
+	Name             string   `json:"name"`
+	Type             string   `json:"type"`
+	URL              string   `json:"url,omitempty"`
+	ExpectStatus     int      `json:"expect_status,omitempty"`
+	Address          string   `json:"address,omitempty"`
+	Container        string   `json:"container,omitempty"`
+	Command          []string `json:"command,omitempty"`
+	Path             string   `json:"path,omitempty"`
+	Process          string   `json:"process,omitempty"`
+	IntervalSeconds  int      `json:"interval_seconds,omitempty"`
+	TimeoutSeconds   int      `json:"timeout_seconds,omitempty"`
+	SuccessThreshold int      `json:"success_threshold,omitempty"`
+	FailureThreshold int      `json:"failure_threshold,omitempty"`
+}
+
+// probeRequiredField 各类型探针的必填字段
+var probeRequiredField = map[string]func(p ProbeSpec) (string, bool){
+	"http": func(p ProbeSpec) (string, bool) { return "url", p.URL != "" },
+	"tcp":  func(p ProbeSpec) (string, bool) { return "address", p.Address != "" },
+	"exec": func(p ProbeSpec) (string, bool) {
+		return "container and command", p.Container != "" && len(p.Command) > 0
+	},
+	"file":    func(p ProbeSpec) (string, bool) { return "path", p.Path != "" },
+	"process": func(p ProbeSpec) (string, bool) { return "process", p.Process != "" },
+}
+
+// validateProbes 校验探针定义
+func validateProbes(probes []ProbeSpec) error {
+	names := make(map[string]bool, len(probes))
+	for i, p := range probes {
+		field := fmt.Sprintf("probes[%d]", i)
+		if p.Name == "" {
+			return &ValidationError{Field: field, Reason: "name is required"}
+		}
+		if names[p.Name] {
+			return &ValidationError{Field: field, Reason: "duplicate probe name " + p.Name}
+		}
+		names[p.Name] = true
+
+		required, ok := probeRequiredField[p.Type]
+		if !ok {
+			return &ValidationError{Field: field, Reason: "unsupported type " + strconv.Quote(p.Type)}
+		}
+		if name, ok := required(p); !ok {
+			return &ValidationError{Field: field, Reason: name + " is required for " + p.Type + " probes"}
+		}
+		if p.IntervalSeconds < 0 || p.TimeoutSeconds < 0 || p.SuccessThreshold < 0 || p.FailureThreshold < 0 {
+			return &ValidationError{Field: field, Reason: "intervals and thresholds must not be negative"}
+		}
+	}
+	return nil
+}
+
+// Merge 用 overlay 中已设置的字段覆盖当前配置，返回新配置
+func (c AgentConfig) Merge(overlay AgentConfig) AgentConfig {
+	if overlay.ReportInterval != nil {
+		c.ReportInterval = overlay.ReportInterval
+	}
+	if overlay.HeartbeatInterval != nil {
+		c.HeartbeatInterval = overlay.HeartbeatInterval
+	}
+	if overlay.HeartbeatTimeout != nil {
+		c.HeartbeatTimeout = overlay.HeartbeatTimeout
+	}
+	if overlay.ShellTimeout != nil {
+		c.ShellTimeout = overlay.ShellTimeout
+	}
+	if overlay.TerminalIdleTimeout != nil {
+		c.TerminalIdleTimeout = overlay.TerminalIdleTimeout
+	}
+	if overlay.DiskMounts != nil {
+		c.DiskMounts = overlay.DiskMounts
+	}
+	if overlay.IncludeVirtualInterfaces != nil {
+		c.IncludeVirtualInterfaces = overlay.IncludeVirtualInterfaces
+	}
+	if overlay.ExcludeInterfaces != nil {
+		c.ExcludeInterfaces = overlay.ExcludeInterfaces
+	}
+	if overlay.Probes != nil {
+		c.Probes = overlay.Probes
+	}
+	return c
+}
+
+// Validate 校验配置取值
+func (c AgentConfig) Validate() error {
+	checks := []struct {
+		name  string
+		value *int
+		min   int
+	}{
+		{"report_interval", c.ReportInterval, 1},
+		{"heartbeat_interval", c.HeartbeatInterval, 1},
+		{"heartbeat_timeout", c.HeartbeatTimeout, 1},
+		{"shell_timeout", c.ShellTimeout, 1},
+		{"terminal_idle_timeout", c.TerminalIdleTimeout, 1},
+	}
+	for _, check := range checks {
+		if check.value != nil && *check.value < check.min {
+			return &ValidationError{Field: check.name, Reason: "must be at least 1 second"}
+		}
+	}
+	if c.HeartbeatInterval != nil && c.HeartbeatTimeout != nil && *c.HeartbeatTimeout <= *c.HeartbeatInterval {
+		return &ValidationError{Field: "heartbeat_timeout", Reason: "must be greater than heartbeat_interval"}
+	}
+	if c.Probes != nil {
+		return validateProbes(*c.Probes)
+	}
+	return nil
+}
+
+// ValidateEffective 校验叠加后的生效配置，未设置的心跳参数按 domclusterd 的默认值计算
+//
+// 心跳超时不大于心跳间隔时节点会在在线和离线之间反复切换。
+func (c AgentConfig) ValidateEffective() error {
+	interval := intOr(c.HeartbeatInterval, defaultHeartbeatInterval)
+	timeout := intOr(c.HeartbeatTimeout, defaultHeartbeatTimeout)
+	if timeout <= interval {
+		return &ValidationError{
+			Field:  "heartbeat_timeout",
+			Reason: fmt.Sprintf("must be greater than heartbeat_interval (%ds <= %ds)", timeout, interval),
+		}
+	}
+	return nil
+}
+
+// Hash 返回配置内容的哈希，用于判断节点的生效配置是否变化
+func (c AgentConfig) Hash() string {
+	data, _ := json.Marshal(c)
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:8])
+}
+
+// intOr 返回已设置的值，未设置时返回默认值
+func intOr(v *int, def int) int {
+	if v == nil {
+		return def
+	}
+	return *v
+}
+
+// ValidationError 配置校验错误
+type ValidationError struct {
+	Field  string
+	Reason string
+}
+
+func (e *ValidationError) Error() string {
+	return e.Field + ": " + e.Reason
+}
+
+// ParseLabel 解析 "key=value" 形式的标签选择器
+func ParseLabel(label string) (key, value string, ok bool) {
+	key, value, ok = strings.Cut(label, "=")
+	if !ok || key == "" {
+		return "", "", false
+	}
+	return key, value, true
+}
@@ -0,0 +1,431 @@
+package agentconfig
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// NodeConfigState 节点配置同步状态
+type NodeConfigState struct {
+	NodeID          string    `json:"node_id"`
+	DesiredRevision uint64    `json:"desired_revision"`
+	DesiredHash     string    `json:"desired_hash"`
+	AppliedRevision uint64    `json:"applied_revision"`
+	AppliedHash     string    `json:"applied_hash,omitempty"`
+	InSync          bool      `json:"in_sync"`
+	Error           string    `json:"error,omitempty"`
+	AppliedAt       time.Time `json:"applied_at,omitempty"`
+}
+
+// nodeRecord 单个节点的生效配置修订号及确认状态，随配置一起持久化
+type nodeRecord struct {
+	// Labels 最近一次计算生效配置时节点的标签，修改配置时据此校验叠加结果
+	Labels map[string]string `json:"labels,omitempty"`
+	// Revision 节点生效配置最近一次变化时的全局修订号
+	Revision uint64 `json:"revision"`
+	Hash     string `json:"hash"`
+
+	AppliedRevision uint64    `json:"applied_revision"`
+	AppliedHash     string    `json:"applied_hash,omitempty"`
+	AppliedAt       time.Time `json:"applied_at,omitempty"`
+	Error           string    `json:"error,omitempty"`
+}
+
+// snapshot 持久化到磁盘的内容
+type snapshot struct {
+	Revision   uint64                       `json:"revision"`
+	Default    AgentConfig                  `json:"default"`
+	Labels     map[string]AgentConfig       `json:"labels"`
+	Nodes      map[string]AgentConfig       `json:"nodes"`
+	NodeLabels map[string]map[string]string `json:"node_labels"`
+	NodeStates map[string]*nodeRecord       `json:"node_states"`
+}
+
+// clone 复制各层配置的索引，用于修改校验失败时回滚
+func (snap snapshot) clone() snapshot {
+	out := snap
+	out.Labels = make(map[string]AgentConfig, len(snap.Labels))
+	for k, v := range snap.Labels {
+		out.Labels[k] = v
+	}
+	out.Nodes = make(map[string]AgentConfig, len(snap.Nodes))
+	for k, v := range snap.Nodes {
+		out.Nodes[k] = v
+	}
+	out.NodeLabels = make(map[string]map[string]string, len(snap.NodeLabels))
+	for k, v := range snap.NodeLabels {
+		out.NodeLabels[k] = v
+	}
+	return out
+}
+
+// Store 节点配置存储
+//
+// 每次修改都会递增全局修订号，但节点的期望修订号只在其生效配置（按哈希比较）变化时更新，
+// 修改其他节点或标签的配置不会使无关节点显示为漂移。节点确认的状态同样持久化，控制端重启后保留。
+type Store struct {
+	mu   sync.RWMutex
+	path string
+	data snapshot
+}
+
+// NewStore 创建配置存储，path 为空时不持久化
+func NewStore(path string) *Store {
+	s := &Store{
+		path: path,
+		data: snapshot{
+			Labels:     make(map[string]AgentConfig),
+			Nodes:      make(map[string]AgentConfig),
+			NodeLabels: make(map[string]map[string]string),
+			NodeStates: make(map[string]*nodeRecord),
+		},
+	}
+
+	if path != "" {
+		if err := s.load(); err != nil && !os.IsNotExist(err) {
+			zap.L().Sugar().Warnf("Failed to load agent config from %s: %v", path, err)
+		}
+	}
+	return s
+}
+
+// load 从磁盘读取配置
+func (s *Store) load() error {
+	data, err := os.ReadFile(s.path)
+	if err != nil {
+		return err
+	}
+
+	var snap snapshot
+	if err := json.Unmarshal(data, &snap); err != nil {
+		return err
+	}
+	if snap.Labels == nil {
+		snap.Labels = make(map[string]AgentConfig)
+	}
+	if snap.Nodes == nil {
+		snap.Nodes = make(map[string]AgentConfig)
+	}
+	if snap.NodeLabels == nil {
+		snap.NodeLabels = make(map[string]map[string]string)
+	}
+	if snap.NodeStates == nil {
+		snap.NodeStates = make(map[string]*nodeRecord)
+	}
+	s.data = snap
+	return nil
+}
+
+// saveLocked 将配置写入磁盘，调用方需持有写锁
+func (s *Store) saveLocked() error {
+	if s.path == "" {
+		return nil
+	}
+
+	data, err := json.MarshalIndent(s.data, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(s.path), 0755); err != nil {
+		return err
+	}
+
+	// 先写临时文件再重命名，避免写入中途崩溃导致文件损坏
+	tmp := s.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, s.path)
+}
+
+// update 在写锁内执行修改并递增修订号
+//
+// 修改后校验默认配置及已知节点叠加后的生效配置，不合法时回滚。
+func (s *Store) update(fn func() error) (uint64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	prev := s.data.clone()
+	if err := fn(); err != nil {
+		s.data = prev
+		return 0, err
+	}
+	if err := s.validateLocked(); err != nil {
+		s.data = prev
+		return 0, err
+	}
+	s.data.Revision++
+	if err := s.saveLocked(); err != nil {
+		return 0, fmt.Errorf("failed to save agent config: %w", err)
+	}
+	return s.data.Revision, nil
+}
+
+// SetDefault 设置默认配置
+func (s *Store) SetDefault(cfg AgentConfig) (uint64, error) {
+	if err := cfg.Validate(); err != nil {
+		return 0, err
+	}
+	return s.update(func() error {
+		s.data.Default = cfg
+		return nil
+	})
+}
+
+// SetLabelConfig 设置标签配置，label 形如 "role=judgehost"
+func (s *Store) SetLabelConfig(label string, cfg AgentConfig) (uint64, error) {
+	if _, _, ok := ParseLabel(label); !ok {
+		return 0, fmt.Errorf("invalid label %q, expected key=value", label)
+	}
+	if err := cfg.Validate(); err != nil {
+		return 0, err
+	}
+	return s.update(func() error {
+		s.data.Labels[label] = cfg
+		return nil
+	})
+}
+
+// DeleteLabelConfig 删除标签配置
+func (s *Store) DeleteLabelConfig(label string) (uint64, error) {
+	return s.update(func() error {
+		if _, ok := s.data.Labels[label]; !ok {
+			return fmt.Errorf("no config for label %s", label)
+		}
+		delete(s.data.Labels, label)
+		return nil
+	})
+}
+
+// SetNodeConfig 设置节点配置
+func (s *Store) SetNodeConfig(nodeID string, cfg AgentConfig) (uint64, error) {
+	if err := cfg.Validate(); err != nil {
+		return 0, err
+	}
+	return s.update(func() error {
+		s.data.Nodes[nodeID] = cfg
+		return nil
+	})
+}
+
+// DeleteNodeConfig 删除节点配置
+func (s *Store) DeleteNodeConfig(nodeID string) (uint64, error) {
+	return s.update(func() error {
+		if _, ok := s.data.Nodes[nodeID]; !ok {
+			return fmt.Errorf("no config for node %s", nodeID)
+		}
+		delete(s.data.Nodes, nodeID)
+		return nil
+	})
+}
+
+// SetNodeLabels 设置由控制端分配的节点标签，会覆盖节点自身上报的同名标签
+func (s *Store) SetNodeLabels(nodeID string, labels map[string]string) (uint64, error) {
+	return s.update(func() error {
+		if len(labels) == 0 {
+			delete(s.data.NodeLabels, nodeID)
+		} else {
+			s.data.NodeLabels[nodeID] = labels
+		}
+		return nil
+	})
+}
+
+// NodeLabels 获取控制端分配的节点标签
+func (s *Store) NodeLabels(nodeID string) map[string]string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	result := make(map[string]string, len(s.data.NodeLabels[nodeID]))
+	for k, v := range s.data.NodeLabels[nodeID] {
+		result[k] = v
+	}
+	return result
+}
+
+// Effective 计算节点的生效配置及其修订号
+//
+// 修订号为该节点生效配置最近一次变化时的全局修订号，配置未变化时保持不变。
+func (s *Store) Effective(nodeID string, labels map[string]string) (AgentConfig, uint64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	cfg := s.effectiveLocked(nodeID, labels)
+	hash := cfg.Hash()
+
+	rec, ok := s.data.NodeStates[nodeID]
+	if !ok {
+		rec = &nodeRecord{}
+		s.data.NodeStates[nodeID] = rec
+	}
+	if ok && rec.Hash == hash && labelsEqual(rec.Labels, labels) {
+		return cfg, rec.Revision
+	}
+	if rec.Hash != hash {
+		rec.Hash = hash
+		rec.Revision = s.data.Revision
+	}
+	rec.Labels = copyLabels(labels)
+	if err := s.saveLocked(); err != nil {
+		zap.L().Sugar().Warnf("Failed to save agent config state: %v", err)
+	}
+	return cfg, rec.Revision
+}
+
+// effectiveLocked 按 默认配置 < 标签配置 < 节点配置 叠加生效配置，调用方需持有锁
+//
+// 多个标签配置同时匹配时按标签字符串排序依次叠加，保证结果稳定。
+func (s *Store) effectiveLocked(nodeID string, labels map[string]string) AgentConfig {
+	cfg := s.data.Default
+
+	matched := make([]string, 0)
+	for label := range s.data.Labels {
+		key, value, _ := ParseLabel(label)
+		if v, ok := labels[key]; ok && v == value {
+			matched = append(matched, label)
+		}
+	}
+	sort.Strings(matched)
+	for _, label := range matched {
+		cfg = cfg.Merge(s.data.Labels[label])
+	}
+
+	if nodeCfg, ok := s.data.Nodes[nodeID]; ok {
+		cfg = cfg.Merge(nodeCfg)
+	}
+	return cfg
+}
+
+// validateLocked 校验默认配置和已知节点的生效配置，调用方需持有写锁
+func (s *Store) validateLocked() error {
+	if err := s.data.Default.ValidateEffective(); err != nil {
+		return err
+	}
+
+	nodeIDs := make([]string, 0, len(s.data.NodeStates))
+	for nodeID := range s.data.NodeStates {
+		nodeIDs = append(nodeIDs, nodeID)
+	}
+	sort.Strings(nodeIDs)
+	for _, nodeID := range nodeIDs {
+		// 控制端分配的标签可能刚被修改，覆盖记录中的旧值
+		labels := copyLabels(s.data.NodeStates[nodeID].Labels)
+		for k, v := range s.data.NodeLabels[nodeID] {
+			labels[k] = v
+		}
+		if err := s.effectiveLocked(nodeID, labels).ValidateEffective(); err != nil {
+			var validationErr *ValidationError
+			if errors.As(err, &validationErr) {
+				return &ValidationError{Field: validationErr.Field, Reason: validationErr.Reason + " on node " + nodeID}
+			}
+			return err
+		}
+	}
+	return nil
+}
+
+// labelsEqual 判断两组标签是否相同
+func labelsEqual(a, b map[string]string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for k, v := range a {
+		if bv, ok := b[k]; !ok || bv != v {
+			return false
+		}
+	}
+	return true
+}
+
+// copyLabels 复制标签
+func copyLabels(labels map[string]string) map[string]string {
+	result := make(map[string]string, len(labels))
+	for k, v := range labels {
+		result[k] = v
+	}
+	return result
+}
+
+// Revision 获取当前修订号
+func (s *Store) Revision() uint64 {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.data.Revision
+}
+
+// Snapshot 获取完整配置（用于 API 展示）
+func (s *Store) Snapshot() map[string]interface{} {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return map[string]interface{}{
+		"revision":    s.data.Revision,
+		"default":     s.data.Default,
+		"labels":      s.data.Labels,
+		"nodes":       s.data.Nodes,
+		"node_labels": s.data.NodeLabels,
+	}
+}
+
+// RecordApplied 记录节点确认的修订号
+//
+// 只有确认的修订号与节点当前的生效修订号一致时才认为节点应用了当前配置。
+func (s *Store) RecordApplied(nodeID string, revision uint64, applyErr string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	rec, ok := s.data.NodeStates[nodeID]
+	if !ok {
+		rec = &nodeRecord{}
+		s.data.NodeStates[nodeID] = rec
+	}
+	// 应用失败时保留上一次成功的修订号
+	if applyErr == "" {
+		rec.AppliedRevision = revision
+		rec.AppliedHash = ""
+		if revision == rec.Revision {
+			rec.AppliedHash = rec.Hash
+		}
+		rec.AppliedAt = time.Now()
+	}
+	rec.Error = applyErr
+
+	if err := s.saveLocked(); err != nil {
+		zap.L().Sugar().Warnf("Failed to save agent config state: %v", err)
+	}
+}
+
+// NodeState 获取节点的配置同步状态，labels 为节点当前的生效标签
+func (s *Store) NodeState(nodeID string, labels map[string]string) NodeConfigState {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	hash := s.effectiveLocked(nodeID, labels).Hash()
+	state := NodeConfigState{
+		NodeID:          nodeID,
+		DesiredRevision: s.data.Revision,
+		DesiredHash:     hash,
+	}
+	rec, ok := s.data.NodeStates[nodeID]
+	if !ok {
+		return state
+	}
+	if rec.Hash == hash {
+		state.DesiredRevision = rec.Revision
+	}
+	state.AppliedRevision = rec.AppliedRevision
+	state.AppliedHash = rec.AppliedHash
+	state.AppliedAt = rec.AppliedAt
+	state.Error = rec.Error
+	state.InSync = rec.AppliedHash == hash && rec.Error == ""
+	return state
+}
@@ -0,0 +1,85 @@
+package agentconfig
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+)
+
+// intPtr 返回整数指针
+func intPtr(v int) *int {
+	return &v
+}
+
+func TestStoreUnrelatedChangeDoesNotDrift(t *testing.T) {
+	s := NewStore("")
+	judge := map[string]string{"role": "judgehost"}
+	web := map[string]string{"role": "web"}
+
+	_, rev := s.Effective("n1", judge)
+	s.RecordApplied("n1", rev, "")
+	_, rev = s.Effective("n2", web)
+	s.RecordApplied("n2", rev, "")
+
+	// 只修改匹配 n2 的标签配置
+	if _, err := s.SetLabelConfig("role=web", AgentConfig{ReportInterval: intPtr(30)}); err != nil {
+		t.Fatal(err)
+	}
+
+	if state := s.NodeState("n1", judge); !state.InSync {
+		t.Errorf("n1 drifted after unrelated change: %+v", state)
+	}
+	if state := s.NodeState("n2", web); state.InSync {
+		t.Errorf("n2 in sync before applying new config: %+v", state)
+	}
+
+	_, rev = s.Effective("n2", web)
+	s.RecordApplied("n2", rev, "")
+	if state := s.NodeState("n2", web); !state.InSync || state.DesiredRevision != rev {
+		t.Errorf("n2 not in sync after applying revision %d: %+v", rev, state)
+	}
+}
+
+func TestStorePersistsAppliedState(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "agent_config.json")
+	labels := map[string]string{"role": "judgehost"}
+
+	s := NewStore(path)
+	if _, err := s.SetDefault(AgentConfig{ReportInterval: intPtr(10)}); err != nil {
+		t.Fatal(err)
+	}
+	_, rev := s.Effective("n1", labels)
+	s.RecordApplied("n1", rev, "")
+
+	// 控制端重启后节点仍为已同步
+	restarted := NewStore(path)
+	if state := restarted.NodeState("n1", labels); !state.InSync || state.AppliedRevision != rev {
+		t.Errorf("state after restart = %+v, want in sync at revision %d", state, rev)
+	}
+}
+
+func TestStoreRejectsHeartbeatTimeoutNotAboveInterval(t *testing.T) {
+	s := NewStore("")
+	labels := map[string]string{"role": "judgehost"}
+	s.Effective("n1", labels)
+
+	if _, err := s.SetDefault(AgentConfig{HeartbeatInterval: intPtr(20)}); err == nil {
+		t.Fatal("interval above the default timeout accepted")
+	}
+
+	if _, err := s.SetDefault(AgentConfig{HeartbeatInterval: intPtr(10), HeartbeatTimeout: intPtr(30)}); err != nil {
+		t.Fatal(err)
+	}
+	// 单独看合法，叠加默认配置后超时不大于间隔
+	_, err := s.SetLabelConfig("role=judgehost", AgentConfig{HeartbeatTimeout: intPtr(10)})
+	var validationErr *ValidationError
+	if !errors.As(err, &validationErr) || validationErr.Field != "heartbeat_timeout" {
+		t.Fatalf("SetLabelConfig error = %v, want heartbeat_timeout validation error", err)
+	}
+
+	// 被拒绝的修改已回滚
+	cfg, _ := s.Effective("n1", labels)
+	if cfg.HeartbeatTimeout == nil || *cfg.HeartbeatTimeout != 30 {
+		t.Errorf("effective heartbeat_timeout = %v, want 30", cfg.HeartbeatTimeout)
+	}
+}
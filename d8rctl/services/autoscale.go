@@ -0,0 +1,827 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"d8rctl/config"
+
+	"go.uber.org/zap"
+)
+
+const (
+	// autoscaleTick 自动扩缩容循环检查各策略是否到期的间隔
+	autoscaleTick = 5 * time.Second
+	// autoscaleMaxEvents 每个栈保留的扩缩容事件数
+	autoscaleMaxEvents = 200
+	// autoscaleStopTimeout 停止 judgehost 容器时等待其退出的秒数
+	autoscaleStopTimeout = 30
+	// autoscaleDrainTimeout 缩容时禁用 judgehost 后等待其完成刚领取的评测的时间
+	autoscaleDrainTimeout = 2 * time.Minute
+)
+
+// 扩缩容事件的动作
+const (
+	AutoscaleActionScaleUp   = "scale_up"
+	AutoscaleActionScaleDown = "scale_down"
+	AutoscaleActionPinned    = "pinned"
+	AutoscaleActionUnpinned  = "unpinned"
+	AutoscaleActionError     = "error"
+)
+
+// QueueProbe 自定义的队列长度探针
+type QueueProbe struct {
+	// URL 以 GET 请求的地址，返回纯数字或 JSON
+	URL string `json:"url"`
+	// Field 返回 JSON 时队列长度所在的字段，嵌套字段以点分隔，如 data.queue
+	Field          string `json:"field,omitempty"`
+	TimeoutSeconds int    `json:"timeout_seconds,omitempty"`
+}
+
+// AutoscalePolicy 栈的 judgehost 自动扩缩容策略
+//
+// 扩缩容在栈声明的 judgehost 容器范围内启停容器，不会超出 stack up 规划的数量。
+// stack up 会启动全部 judgehost，之后再按冷却时间逐步缩容。
+type AutoscalePolicy struct {
+	Stack   string `json:"stack"`
+	Enabled bool   `json:"enabled"`
+	// Min 与 Max 为运行中 judgehost 数量的上下限，Max 为 0 时以栈中规划的全部 judgehost 为上限
+	Min int `json:"min"`
+	Max int `json:"max,omitempty"`
+	// QueuePerJudgehost 每个运行中的 judgehost 对应的排队提交数，默认 2
+	QueuePerJudgehost int `json:"queue_per_judgehost,omitempty"`
+	// IntervalSeconds 查询队列长度的间隔，默认 15
+	IntervalSeconds int `json:"interval_seconds,omitempty"`
+	// ScaleUpCooldownSeconds 上次扩缩容后多久才能再次扩容，默认 60
+	ScaleUpCooldownSeconds int `json:"scale_up_cooldown_seconds,omitempty"`
+	// ScaleDownCooldownSeconds 上次扩缩容后多久才能缩容，默认 300
+	ScaleDownCooldownSeconds int `json:"scale_down_cooldown_seconds,omitempty"`
+	// MaxStep 单次最多启停的容器数，0 表示不限
+	MaxStep int `json:"max_step,omitempty"`
+	// Probe 设置后以探针代替 DomJudge API 查询队列长度
+	Probe *QueueProbe `json:"probe,omitempty"`
+	// Pinned 固定运行的 judgehost 数量，如封榜期间，设置后忽略队列长度、上下限和冷却时间
+	Pinned *int `json:"pinned,omitempty"`
+}
+
+// ScalingEvent 一次扩缩容决策
+type ScalingEvent struct {
+	Time    time.Time `json:"time"`
+	Action  string    `json:"action"`
+	Queue   *int      `json:"queue,omitempty"`
+	Running int       `json:"running"`
+	Desired int       `json:"desired"`
+	Reason  string    `json:"reason,omitempty"`
+	// Containers 被启动或停止的容器，格式为 <node>/<container>
+	Containers []string `json:"containers,omitempty"`
+	Error      string   `json:"error,omitempty"`
+}
+
+// AutoscaleStatus 自动扩缩容的当前状态
+type AutoscaleStatus struct {
+	Policy AutoscalePolicy `json:"policy"`
+	Queue  *int            `json:"queue,omitempty"`
+	// Running 运行中的 judgehost 数，Available 为栈在已连接节点上规划的 judgehost 数
+	Running   int            `json:"running"`
+	Available int            `json:"available"`
+	Desired   int            `json:"desired"`
+	LastEval  time.Time      `json:"last_eval,omitempty"`
+	LastScale time.Time      `json:"last_scale,omitempty"`
+	LastError string         `json:"last_error,omitempty"`
+	Events    []ScalingEvent `json:"events,omitempty"`
+}
+
+// autoscaler 一个栈的自动扩缩容状态
+type autoscaler struct {
+	mu     sync.Mutex
+	policy AutoscalePolicy
+	events []ScalingEvent
+	status AutoscaleStatus
+	// busy 评估进行中，避免慢节点导致评估重叠
+	busy bool
+}
+
+// autoscaleState 持久化到磁盘的内容
+type autoscaleState struct {
+	Policy    AutoscalePolicy `json:"policy"`
+	LastScale time.Time       `json:"last_scale,omitempty"`
+	Events    []ScalingEvent  `json:"events"`
+}
+
+// applyDefaults 填充未设置的字段
+func (p *AutoscalePolicy) applyDefaults() {
+	if p.QueuePerJudgehost == 0 {
+		p.QueuePerJudgehost = 2
+	}
+	if p.IntervalSeconds == 0 {
+		p.IntervalSeconds = 15
+	}
+	if p.ScaleUpCooldownSeconds == 0 {
+		p.ScaleUpCooldownSeconds = 60
+	}
+	if p.ScaleDownCooldownSeconds == 0 {
+		p.ScaleDownCooldownSeconds = 300
+	}
+}
+
+// Validate 检查策略
+func (p *AutoscalePolicy) Validate() error {
+	if !stackNamePattern.MatchString(p.Stack) {
+		return fmt.Errorf("invalid stack name %q", p.Stack)
+	}
+	if p.Min < 0 || p.Max < 0 || (p.Max > 0 && p.Min > p.Max) {
+		return fmt.Errorf("min and max must satisfy 0 <= min <= max")
+	}
+	if p.QueuePerJudgehost < 1 {
+		return fmt.Errorf("queue_per_judgehost must be at least 1")
+	}
+	if p.IntervalSeconds < 5 {
+		return fmt.Errorf("interval_seconds must be at least 5")
+	}
+	if p.ScaleUpCooldownSeconds < 0 || p.ScaleDownCooldownSeconds < 0 || p.MaxStep < 0 {
+		return fmt.Errorf("cooldowns and max_step must not be negative")
+	}
+	if p.Pinned != nil && *p.Pinned < 0 {
+		return fmt.Errorf("pinned size must not be negative")
+	}
+	if p.Probe != nil && !strings.HasPrefix(p.Probe.URL, "http://") && !strings.HasPrefix(p.Probe.URL, "https://") {
+		return fmt.Errorf("invalid probe url %q", p.Probe.URL)
+	}
+	return nil
+}
+
+// autoscaleDir 自动扩缩容策略和事件的保存目录
+func autoscaleDir() string {
+	return filepath.Join(config.GetDataDir(), "autoscale")
+}
+
+// save 保存策略和事件，调用方需持有锁
+func (as *autoscaler) save() error {
+	data, err := json.MarshalIndent(autoscaleState{
+		Policy:    as.policy,
+		LastScale: as.status.LastScale,
+		Events:    as.events,
+	}, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(autoscaleDir(), 0755); err != nil {
+		return err
+	}
+
+	path := filepath.Join(autoscaleDir(), as.policy.Stack+".json")
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// record 记录扩缩容事件并保存，调用方需持有锁
+func (as *autoscaler) record(ev ScalingEvent) {
+	ev.Time = time.Now()
+	as.events = append(as.events, ev)
+	if len(as.events) > autoscaleMaxEvents {
+		as.events = as.events[len(as.events)-autoscaleMaxEvents:]
+	}
+	if err := as.save(); err != nil {
+		zap.L().Sugar().Warnf("Failed to save autoscale events of stack %s: %v", as.policy.Stack, err)
+	}
+
+	if ev.Error != "" {
+		zap.L().Sugar().Warnf("Autoscale %s: %s: %s", as.policy.Stack, ev.Action, ev.Error)
+		return
+	}
+	zap.L().Sugar().Infof("Autoscale %s: %s %d -> %d (%s) %v",
+		as.policy.Stack, ev.Action, ev.Running, ev.Desired, ev.Reason, ev.Containers)
+}
+
+// loadAutoscalers 读取已保存的策略
+func loadAutoscalers() map[string]*autoscaler {
+	result := make(map[string]*autoscaler)
+	entries, err := os.ReadDir(autoscaleDir())
+	if err != nil {
+		if !os.IsNotExist(err) {
+			zap.L().Sugar().Warnf("Failed to read autoscale policies: %v", err)
+		}
+		return result
+	}
+
+	for _, e := range entries {
+		if e.IsDir() || !strings.HasSuffix(e.Name(), ".json") {
+			continue
+		}
+		data, err := os.ReadFile(filepath.Join(autoscaleDir(), e.Name()))
+		if err != nil {
+			zap.L().Sugar().Warnf("Failed to read autoscale policy %s: %v", e.Name(), err)
+			continue
+		}
+		var st autoscaleState
+		if err := json.Unmarshal(data, &st); err != nil {
+			zap.L().Sugar().Warnf("Failed to parse autoscale policy %s: %v", e.Name(), err)
+			continue
+		}
+		as := &autoscaler{policy: st.Policy, events: st.Events}
+		as.status.LastScale = st.LastScale
+		result[st.Policy.Stack] = as
+	}
+	return result
+}
+
+// SetAutoscalePolicy 创建或修改栈的自动扩缩容策略，已固定的数量保持不变
+func (s *DomclusterServer) SetAutoscalePolicy(policy AutoscalePolicy) (*AutoscaleStatus, error) {
+	policy.applyDefaults()
+	policy.Pinned = nil
+	if err := policy.Validate(); err != nil {
+		return nil, err
+	}
+	if _, err := loadStackSpec(policy.Stack); err != nil {
+		return nil, err
+	}
+
+	s.autoscalersMu.Lock()
+	as, ok := s.autoscalers[policy.Stack]
+	if !ok {
+		as = &autoscaler{}
+		s.autoscalers[policy.Stack] = as
+	}
+	s.autoscalersMu.Unlock()
+
+	as.mu.Lock()
+	defer as.mu.Unlock()
+	policy.Pinned = as.policy.Pinned
+	as.policy = policy
+	if err := as.save(); err != nil {
+		return nil, fmt.Errorf("failed to save autoscale policy: %w", err)
+	}
+	// 策略变化后立即重新评估
+	as.status.LastEval = time.Time{}
+	return as.snapshot(false), nil
+}
+
+// RemoveAutoscalePolicy 删除栈的自动扩缩容策略，已启停的容器保持当前状态
+func (s *DomclusterServer) RemoveAutoscalePolicy(stack string) error {
+	s.autoscalersMu.Lock()
+	_, ok := s.autoscalers[stack]
+	delete(s.autoscalers, stack)
+	s.autoscalersMu.Unlock()
+	if !ok {
+		return fmt.Errorf("autoscale policy for stack %s not found", stack)
+	}
+
+	err := os.Remove(filepath.Join(autoscaleDir(), stack+".json"))
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+// PinAutoscale 固定栈运行的 judgehost 数量，size 为 nil 时固定为当前运行的数量
+func (s *DomclusterServer) PinAutoscale(stack string, size *int) (*AutoscaleStatus, error) {
+	as, err := s.getAutoscaler(stack)
+	if err != nil {
+		return nil, err
+	}
+
+	as.mu.Lock()
+	defer as.mu.Unlock()
+	if size == nil {
+		if as.status.LastEval.IsZero() {
+			return nil, fmt.Errorf("current size of stack %s is unknown, specify a size", stack)
+		}
+		running := as.status.Running
+		size = &running
+	}
+	if *size < 0 {
+		return nil, fmt.Errorf("pinned size must not be negative")
+	}
+
+	as.policy.Pinned = size
+	as.record(ScalingEvent{
+		Action:  AutoscaleActionPinned,
+		Running: as.status.Running,
+		Desired: *size,
+		Reason:  fmt.Sprintf("fleet size pinned to %d", *size),
+	})
+	as.status.LastEval = time.Time{}
+	return as.snapshot(false), nil
+}
+
+// UnpinAutoscale 取消固定数量，恢复按队列长度扩缩容
+func (s *DomclusterServer) UnpinAutoscale(stack string) (*AutoscaleStatus, error) {
+	as, err := s.getAutoscaler(stack)
+	if err != nil {
+		return nil, err
+	}
+
+	as.mu.Lock()
+	defer as.mu.Unlock()
+	if as.policy.Pinned == nil {
+		return as.snapshot(false), nil
+	}
+	as.policy.Pinned = nil
+	as.record(ScalingEvent{
+		Action:  AutoscaleActionUnpinned,
+		Running: as.status.Running,
+		Desired: as.status.Desired,
+		Reason:  "fleet size unpinned",
+	})
+	as.status.LastEval = time.Time{}
+	return as.snapshot(false), nil
+}
+
+// GetAutoscaleStatus 查询栈的自动扩缩容状态及最近的事件
+func (s *DomclusterServer) GetAutoscaleStatus(stack string) (*AutoscaleStatus, error) {
+	as, err := s.getAutoscaler(stack)
+	if err != nil {
+		return nil, err
+	}
+	as.mu.Lock()
+	defer as.mu.Unlock()
+	return as.snapshot(true), nil
+}
+
+// ListAutoscaleStatus 列出全部自动扩缩容策略的状态，不含事件
+func (s *DomclusterServer) ListAutoscaleStatus() []AutoscaleStatus {
+	s.autoscalersMu.Lock()
+	list := make([]*autoscaler, 0, len(s.autoscalers))
+	for _, as := range s.autoscalers {
+		list = append(list, as)
+	}
+	s.autoscalersMu.Unlock()
+
+	result := make([]AutoscaleStatus, 0, len(list))
+	for _, as := range list {
+		as.mu.Lock()
+		result = append(result, *as.snapshot(false))
+		as.mu.Unlock()
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Policy.Stack < result[j].Policy.Stack })
+	return result
+}
+
+// getAutoscaler 查找栈的自动扩缩容状态
+func (s *DomclusterServer) getAutoscaler(stack string) (*autoscaler, error) {
+	s.autoscalersMu.Lock()
+	defer s.autoscalersMu.Unlock()
+	as, ok := s.autoscalers[stack]
+	if !ok {
+		return nil, fmt.Errorf("autoscale policy for stack %s not found", stack)
+	}
+	return as, nil
+}
+
+// snapshot 返回状态副本，调用方需持有锁
+func (as *autoscaler) snapshot(events bool) *AutoscaleStatus {
+	st := as.status
+	st.Policy = as.policy
+	if as.policy.Pinned != nil {
+		pinned := *as.policy.Pinned
+		st.Policy.Pinned = &pinned
+	}
+	if events {
+		st.Events = append([]ScalingEvent(nil), as.events...)
+	}
+	return &st
+}
+
+// autoscaleLoop 定期评估各栈的扩缩容策略
+func (s *DomclusterServer) autoscaleLoop() {
+	ticker := time.NewTicker(autoscaleTick)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			s.autoscalersMu.Lock()
+			for _, as := range s.autoscalers {
+				as.mu.Lock()
+				// 固定数量即使未启用自动扩缩容也会保持
+				due := (as.policy.Enabled || as.policy.Pinned != nil) && !as.busy &&
+					time.Since(as.status.LastEval) >= time.Duration(as.policy.IntervalSeconds)*time.Second
+				if due {
+					as.busy = true
+				}
+				as.mu.Unlock()
+				if due {
+					go s.autoscaleEvaluate(as)
+				}
+			}
+			s.autoscalersMu.Unlock()
+		case <-s.cleanupDone:
+			return
+		}
+	}
+}
+
+// autoscaleJudgehost 栈中的一个 judgehost 容器及其当前状态
+type autoscaleJudgehost struct {
+	sc       stackContainer
+	hostname string
+	exists   bool
+	running  bool
+	busy     bool
+}
+
+// autoscaleEvaluate 查询队列长度并启停 judgehost 容器
+func (s *DomclusterServer) autoscaleEvaluate(as *autoscaler) {
+	as.mu.Lock()
+	policy := as.policy
+	lastScale := as.status.LastScale
+	as.mu.Unlock()
+
+	defer func() {
+		as.mu.Lock()
+		as.busy = false
+		as.status.LastEval = time.Now()
+		as.mu.Unlock()
+	}()
+
+	fail := func(running int, queue *int, err error) {
+		as.mu.Lock()
+		defer as.mu.Unlock()
+		// 相同的错误只记录一次，避免每个周期都产生事件
+		if as.status.LastError != err.Error() {
+			as.record(ScalingEvent{Action: AutoscaleActionError, Queue: queue, Running: running, Desired: as.status.Desired, Error: err.Error()})
+		}
+		as.status.LastError = err.Error()
+	}
+
+	// 栈正在部署或删除时不干预
+	if st, err := s.GetStackDeployment(policy.Stack); err == nil && !st.Done {
+		return
+	}
+
+	spec, err := loadStackSpec(policy.Stack)
+	if err != nil {
+		fail(0, nil, err)
+		return
+	}
+	judgehosts, err := s.autoscaleJudgehosts(spec)
+	if err != nil {
+		fail(0, nil, err)
+		return
+	}
+	running := 0
+	for _, jh := range judgehosts {
+		if jh.running {
+			running++
+		}
+	}
+
+	var queue *int
+	if n, err := s.queueLength(policy); err != nil {
+		// 固定数量时不依赖队列长度
+		if policy.Pinned == nil {
+			fail(running, nil, fmt.Errorf("failed to query queue: %w", err))
+			return
+		}
+	} else {
+		queue = &n
+	}
+
+	desired, reason := autoscaleDesired(policy, queue, len(judgehosts))
+
+	as.mu.Lock()
+	as.status.Queue = queue
+	as.status.Running = running
+	as.status.Available = len(judgehosts)
+	as.status.Desired = desired
+	as.status.LastError = ""
+	as.mu.Unlock()
+
+	if desired == running {
+		return
+	}
+
+	action := AutoscaleActionScaleUp
+	cooldown := time.Duration(policy.ScaleUpCooldownSeconds) * time.Second
+	if desired < running {
+		action = AutoscaleActionScaleDown
+		cooldown = time.Duration(policy.ScaleDownCooldownSeconds) * time.Second
+	}
+	if policy.Pinned == nil && time.Since(lastScale) < cooldown {
+		return
+	}
+
+	count := desired - running
+	if count < 0 {
+		count = -count
+	}
+	if policy.MaxStep > 0 && count > policy.MaxStep && policy.Pinned == nil {
+		count = policy.MaxStep
+	}
+
+	var changed []string
+	var scaleErr error
+	if action == AutoscaleActionScaleUp {
+		changed, scaleErr = s.autoscaleStart(judgehosts, count)
+	} else {
+		changed, scaleErr = s.autoscaleStop(judgehosts, count)
+	}
+	// 缩容时所有候选都在评测，本周期不做任何操作
+	if len(changed) == 0 && scaleErr == nil {
+		return
+	}
+
+	ev := ScalingEvent{
+		Action:     action,
+		Queue:      queue,
+		Running:    running,
+		Desired:    desired,
+		Reason:     reason,
+		Containers: changed,
+	}
+	if scaleErr != nil {
+		ev.Error = scaleErr.Error()
+	}
+
+	as.mu.Lock()
+	defer as.mu.Unlock()
+	if len(changed) > 0 {
+		as.status.LastScale = time.Now()
+		if action == AutoscaleActionScaleUp {
+			as.status.Running += len(changed)
+		} else {
+			as.status.Running -= len(changed)
+		}
+	}
+	if scaleErr != nil {
+		as.status.LastError = scaleErr.Error()
+	}
+	as.record(ev)
+}
+
+// autoscaleDesired 根据策略和队列长度计算应运行的 judgehost 数量
+func autoscaleDesired(policy AutoscalePolicy, queue *int, available int) (int, string) {
+	var desired int
+	var reason string
+	if policy.Pinned != nil {
+		desired = *policy.Pinned
+		reason = fmt.Sprintf("pinned to %d", desired)
+	} else {
+		desired = int(math.Ceil(float64(*queue) / float64(policy.QueuePerJudgehost)))
+		reason = fmt.Sprintf("queue %d at %d per judgehost", *queue, policy.QueuePerJudgehost)
+		if desired < policy.Min {
+			desired = policy.Min
+			reason += fmt.Sprintf(", raised to min %d", policy.Min)
+		}
+		if policy.Max > 0 && desired > policy.Max {
+			desired = policy.Max
+			reason += fmt.Sprintf(", capped at max %d", policy.Max)
+		}
+	}
+	if desired > available {
+		desired = available
+		reason += fmt.Sprintf(", limited to %d available", available)
+	}
+	return desired, reason
+}
+
+// autoscaleJudgehosts 列出栈在已连接的 judgehost 节点上规划的容器及其状态
+func (s *DomclusterServer) autoscaleJudgehosts(spec *StackSpec) ([]autoscaleJudgehost, error) {
+	planned, _ := s.stackPlan(spec)
+	actual, errs := s.stackContainersOnNodes(spec.Name)
+
+	state := make(map[string]ManagedContainer)
+	for node, containers := range actual {
+		for _, c := range containers {
+			state[node+"/"+c.Name] = c
+		}
+	}
+
+	// 配置了 DomJudge 时据此判断 judgehost 是否正在评测，缩容时优先停止空闲的
+	busy := make(map[string]bool)
+	if _, err := s.domjudgeClient(); err == nil {
+		ctx, cancel := context.WithTimeout(context.Background(), stackQueryTimeout)
+		hosts, err := s.ListJudgehosts(ctx, false)
+		cancel()
+		if err != nil {
+			zap.L().Sugar().Debugf("Failed to query DomJudge judgehosts: %v", err)
+		}
+		for _, h := range hosts {
+			busy[h.Hostname] = h.CurrentJudging != nil
+		}
+	}
+
+	var result []autoscaleJudgehost
+	for _, sc := range planned {
+		if sc.Component != StackComponentJudgehost {
+			continue
+		}
+		// 无法确认状态的节点不参与扩缩容
+		if _, failed := errs[sc.NodeID]; failed || !s.IsNodeConnected(sc.NodeID) {
+			continue
+		}
+		c, exists := state[sc.NodeID+"/"+sc.Spec.Name]
+		name := judgehostName(sc.Spec)
+		result = append(result, autoscaleJudgehost{
+			sc:       sc,
+			hostname: name,
+			exists:   exists,
+			running:  exists && c.State == "running",
+			busy:     busy[name],
+		})
+	}
+	if len(result) == 0 {
+		return nil, fmt.Errorf("stack %s has no judgehosts on connected nodes", spec.Name)
+	}
+	return result, nil
+}
+
+// autoscaleStart 启动 count 个 judgehost，优先选择运行中 judgehost 较少的节点
+func (s *DomclusterServer) autoscaleStart(judgehosts []autoscaleJudgehost, count int) ([]string, error) {
+	perNode := make(map[string]int)
+	var candidates []autoscaleJudgehost
+	for _, jh := range judgehosts {
+		if jh.running {
+			perNode[jh.sc.NodeID]++
+		} else {
+			candidates = append(candidates, jh)
+		}
+	}
+
+	var started []string
+	var errs []string
+	for len(started) < count && len(candidates) > 0 {
+		sort.SliceStable(candidates, func(i, j int) bool {
+			return perNode[candidates[i].sc.NodeID] < perNode[candidates[j].sc.NodeID]
+		})
+		jh := candidates[0]
+		candidates = candidates[1:]
+
+		// 容器不存在时按声明创建，已存在则直接启动
+		ctx, cancel := context.WithTimeout(context.Background(), stackCreateTimeout)
+		_, err := s.RecreateContainer(ctx, jh.sc.NodeID, jh.sc.Spec, RecreateOptions{OnlyIfChanged: true})
+		cancel()
+		if err != nil {
+			errs = append(errs, fmt.Sprintf("%s/%s: %v", jh.sc.NodeID, jh.sc.Spec.Name, err))
+			continue
+		}
+		perNode[jh.sc.NodeID]++
+		started = append(started, jh.sc.NodeID+"/"+jh.sc.Spec.Name)
+		s.autoscaleSetEnabled(jh.hostname, true)
+	}
+
+	if len(errs) > 0 {
+		return started, fmt.Errorf("failed to start %d judgehosts: %s", len(errs), strings.Join(errs, "; "))
+	}
+	return started, nil
+}
+
+// autoscaleStop 停止最多 count 个空闲的 judgehost，优先选择所在节点运行 judgehost 较多的
+//
+// 正在评测的 judgehost 不会被停止，留到之后的评估周期；选中的 judgehost 先在 DomJudge 中禁用，
+// 确认没有在禁用前领取新的评测后再停止容器。
+func (s *DomclusterServer) autoscaleStop(judgehosts []autoscaleJudgehost, count int) ([]string, error) {
+	perNode := make(map[string]int)
+	var candidates []autoscaleJudgehost
+	for _, jh := range judgehosts {
+		if !jh.running {
+			continue
+		}
+		perNode[jh.sc.NodeID]++
+		if !jh.busy {
+			candidates = append(candidates, jh)
+		}
+	}
+
+	handler := NewDockerHandler(s)
+	var stopped []string
+	var errs []string
+	for len(stopped) < count && len(candidates) > 0 {
+		sort.SliceStable(candidates, func(i, j int) bool {
+			return perNode[candidates[i].sc.NodeID] > perNode[candidates[j].sc.NodeID]
+		})
+		jh := candidates[0]
+		candidates = candidates[1:]
+
+		// 先在 DomJudge 中禁用并等待评测完成，超时未完成时重新启用并保留该 judgehost
+		if err := s.drainJudgehost(jh.hostname, autoscaleDrainTimeout); err != nil {
+			errs = append(errs, fmt.Sprintf("%s/%s: %v", jh.sc.NodeID, jh.sc.Spec.Name, err))
+			continue
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), stackQueryTimeout+autoscaleStopTimeout*time.Second)
+		_, err := handler.StopContainer(ctx, jh.sc.NodeID, jh.sc.Spec.Name, autoscaleStopTimeout)
+		cancel()
+		if err != nil {
+			s.autoscaleSetEnabled(jh.hostname, true)
+			errs = append(errs, fmt.Sprintf("%s/%s: %v", jh.sc.NodeID, jh.sc.Spec.Name, err))
+			continue
+		}
+		perNode[jh.sc.NodeID]--
+		stopped = append(stopped, jh.sc.NodeID+"/"+jh.sc.Spec.Name)
+	}
+
+	if len(errs) > 0 {
+		return stopped, fmt.Errorf("failed to stop %d judgehosts: %s", len(errs), strings.Join(errs, "; "))
+	}
+	return stopped, nil
+}
+
+// autoscaleSetEnabled 配置了 DomJudge 时同步 judgehost 的启用状态，失败只记录日志
+func (s *DomclusterServer) autoscaleSetEnabled(hostname string, enabled bool) {
+	client, err := s.domjudgeClient()
+	if err != nil {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), stackQueryTimeout)
+	defer cancel()
+	if _, err := client.SetJudgehostEnabled(ctx, hostname, enabled); err != nil {
+		// 新启动的 judgehost 尚未注册到 domserver 时会失败，注册后默认即为启用
+		zap.L().Sugar().Debugf("Failed to set judgehost %s enabled=%v: %v", hostname, enabled, err)
+	}
+}
+
+// queueLength 查询排队的提交数，设置了探针时使用探针，否则汇总 DomJudge 中进行中比赛的队列
+func (s *DomclusterServer) queueLength(policy AutoscalePolicy) (int, error) {
+	if policy.Probe != nil {
+		return probeQueueLength(policy.Probe)
+	}
+
+	client, err := s.domjudgeClient()
+	if err != nil {
+		return 0, err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), stackQueryTimeout)
+	defer cancel()
+
+	contests, err := client.ActiveContests(ctx)
+	if err != nil {
+		return 0, err
+	}
+	total := 0
+	for _, c := range contests {
+		n, err := client.Queue(ctx, c.ID)
+		if err != nil {
+			return 0, fmt.Errorf("contest %s: %w", c.ID, err)
+		}
+		total += n
+	}
+	return total, nil
+}
+
+// probeQueueLength 通过 HTTP 探针查询队列长度
+func probeQueueLength(probe *QueueProbe) (int, error) {
+	timeout := 10 * time.Second
+	if probe.TimeoutSeconds > 0 {
+		timeout = time.Duration(probe.TimeoutSeconds) * time.Second
+	}
+	client := &http.Client{Timeout: timeout}
+
+	resp, err := client.Get(probe.URL)
+	if err != nil {
+		return 0, fmt.Errorf("probe failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return 0, fmt.Errorf("probe failed: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return 0, fmt.Errorf("probe returned %d", resp.StatusCode)
+	}
+	return parseQueueLength(data, probe.Field)
+}
+
+// parseQueueLength 从探针的返回中解析队列长度
+func parseQueueLength(data []byte, field string) (int, error) {
+	if field == "" {
+		n, err := strconv.Atoi(strings.TrimSpace(string(data)))
+		if err != nil || n < 0 {
+			return 0, fmt.Errorf("probe returned %q, expected a non-negative number", strings.TrimSpace(string(data)))
+		}
+		return n, nil
+	}
+
+	var value interface{}
+	if err := json.Unmarshal(data, &value); err != nil {
+		return 0, fmt.Errorf("probe returned invalid JSON: %w", err)
+	}
+	for _, key := range strings.Split(field, ".") {
+		obj, ok := value.(map[string]interface{})
+		if !ok {
+			return 0, fmt.Errorf("probe field %s not found", field)
+		}
+		if value, ok = obj[key]; !ok {
+			return 0, fmt.Errorf("probe field %s not found", field)
+		}
+	}
+	n, ok := value.(float64)
+	if !ok || n < 0 {
+		return 0, fmt.Errorf("probe field %s is not a non-negative number", field)
+	}
+	return int(n), nil
+}
@@ -0,0 +1,122 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"domcluster/api/spec"
+)
+
+// 声明类型定义在 api 模块中，与节点端共用，保证两端计算的哈希一致
+type (
+	ContainerSpec     = spec.ContainerSpec
+	HealthcheckSpec   = spec.HealthcheckSpec
+	MountSpec         = spec.MountSpec
+	PortSpec          = spec.PortSpec
+	ResourceSpec      = spec.ResourceSpec
+	RestartPolicySpec = spec.RestartPolicySpec
+)
+
+// ContainerResult 创建或重建容器的结果
+type ContainerResult struct {
+	ContainerID string   `json:"container_id"`
+	Name        string   `json:"name"`
+	SpecHash    string   `json:"spec_hash"`
+	Started     bool     `json:"started"`
+	Warnings    []string `json:"warnings,omitempty"`
+	// Replaced 重建时被替换的旧容器 ID
+	Replaced string `json:"replaced,omitempty"`
+	// Unchanged 容器已与声明一致，未重建
+	Unchanged bool `json:"unchanged,omitempty"`
+}
+
+// ManagedContainer 节点上按声明创建的容器及其运行状态
+type ManagedContainer struct {
+	ID       string            `json:"id"`
+	Name     string            `json:"name"`
+	Image    string            `json:"image"`
+	ImageID  string            `json:"image_id"`
+	SpecHash string            `json:"spec_hash"`
+	Labels   map[string]string `json:"labels,omitempty"`
+	// State created、running、restarting、exited 等
+	State string `json:"state"`
+	// Health starting、healthy、unhealthy，没有健康检查时为空
+	Health       string `json:"health,omitempty"`
+	ExitCode     int    `json:"exit_code"`
+	RestartCount int    `json:"restart_count"`
+	StartedAt    string `json:"started_at,omitempty"`
+}
+
+// RecreateOptions 重建容器的选项
+type RecreateOptions struct {
+	// NoStart 重建后不启动
+	NoStart bool
+	// StopTimeout 停止旧容器时等待的秒数，0 使用节点的默认值
+	StopTimeout int
+	// OnlyIfChanged 已有容器的声明哈希相同时不重建
+	OnlyIfChanged bool
+}
+
+// CreateContainer 在节点上按声明创建容器，镜像不存在时按拉取策略拉取
+func (s *DomclusterServer) CreateContainer(ctx context.Context, nodeID string, spec *ContainerSpec, start bool) (*ContainerResult, error) {
+	if spec.Name == "" || spec.Image == "" {
+		return nil, fmt.Errorf("spec name and image are required")
+	}
+	return s.containerQuery(ctx, nodeID, "docker_create", map[string]interface{}{
+		"spec":  spec,
+		"start": start,
+	})
+}
+
+// RecreateContainer 在节点上按声明重建同名容器，不存在时直接创建
+func (s *DomclusterServer) RecreateContainer(ctx context.Context, nodeID string, spec *ContainerSpec, opts RecreateOptions) (*ContainerResult, error) {
+	if spec.Name == "" || spec.Image == "" {
+		return nil, fmt.Errorf("spec name and image are required")
+	}
+	return s.containerQuery(ctx, nodeID, "docker_recreate", map[string]interface{}{
+		"spec":            spec,
+		"start":           !opts.NoStart,
+		"stop_timeout":    opts.StopTimeout,
+		"only_if_changed": opts.OnlyIfChanged,
+	})
+}
+
+// RemoveContainer 删除节点上的容器
+func (s *DomclusterServer) RemoveContainer(ctx context.Context, nodeID, containerID string, force, volumes bool) (map[string]interface{}, error) {
+	return s.queryNodeMap(ctx, nodeID, "docker_remove", map[string]interface{}{
+		"container_id": containerID,
+		"force":        force,
+		"volumes":      volumes,
+	})
+}
+
+// ListManagedContainers 列出节点上按声明创建的容器，labels 不为空时只返回标签全部匹配的容器
+func (s *DomclusterServer) ListManagedContainers(ctx context.Context, nodeID string, labels map[string]string) ([]ManagedContainer, error) {
+	result, err := s.QueryNode(ctx, nodeID, "docker_managed", map[string]interface{}{"labels": labels})
+	if err != nil {
+		return nil, err
+	}
+
+	var resp struct {
+		Containers []ManagedContainer `json:"containers"`
+	}
+	if err := json.Unmarshal(result, &resp); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal managed containers: %w", err)
+	}
+	return resp.Containers, nil
+}
+
+// containerQuery 发送创建类请求并解析结果
+func (s *DomclusterServer) containerQuery(ctx context.Context, nodeID, cmd string, data map[string]interface{}) (*ContainerResult, error) {
+	result, err := s.QueryNode(ctx, nodeID, cmd, data)
+	if err != nil {
+		return nil, err
+	}
+
+	var resp ContainerResult
+	if err := json.Unmarshal(result, &resp); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal container result: %w", err)
+	}
+	return &resp, nil
+}
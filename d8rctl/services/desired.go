@@ -0,0 +1,515 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"d8rctl/config"
+
+	pb "domcluster/api/proto"
+	"go.uber.org/zap"
+)
+
+// 期望容器停止后的处理策略，与节点端一致
+const (
+	// DesiredPolicyAlways 容器未运行时总是启动，包括经 domcluster 停止的容器
+	DesiredPolicyAlways = "always"
+	// DesiredPolicyUnlessStopped 容器未运行时启动，经 domcluster 停止或删除的容器除外（默认）
+	DesiredPolicyUnlessStopped = "unless-stopped"
+	// DesiredPolicyOnFailure 只启动以非零状态码退出的容器
+	DesiredPolicyOnFailure = "on-failure"
+	// DesiredPolicyNever 缺失时创建但不启动
+	DesiredPolicyNever = "never"
+)
+
+const (
+	// DesiredSourceManual 手动下发的期望容器的来源
+	DesiredSourceManual = "manual"
+	// desiredPushTimeout 下发期望状态的超时时间
+	desiredPushTimeout = 30 * time.Second
+	// desiredStateMissing 节点报告中期望的容器不存在
+	desiredStateMissing = "missing"
+)
+
+// DesiredContainer 节点期望存在的容器，与节点端的定义一致
+type DesiredContainer struct {
+	// Source 声明的来源，如 stack/<name> 或 manual，下发时按来源整体替换
+	Source string        `json:"source,omitempty"`
+	Policy string        `json:"policy,omitempty"`
+	Spec   ContainerSpec `json:"spec"`
+}
+
+// NodeDesiredState 下发给节点的期望状态
+type NodeDesiredState struct {
+	NodeID     string             `json:"node_id"`
+	Revision   uint64             `json:"revision"`
+	Updated    time.Time          `json:"updated"`
+	Containers []DesiredContainer `json:"containers"`
+	// Pushed 节点已接收的修订号，小于 Revision 时在节点重新连接后补发
+	Pushed    uint64 `json:"pushed"`
+	PushError string `json:"push_error,omitempty"`
+}
+
+// DesiredContainerReport 节点上单个期望容器的收敛情况
+type DesiredContainerReport struct {
+	Name   string `json:"name"`
+	Source string `json:"source,omitempty"`
+	Policy string `json:"policy"`
+	// State Docker 的容器状态，不存在时为 missing
+	State string `json:"state"`
+	// Held 经 domcluster 停止或删除，节点按策略不自动处理
+	Held         string    `json:"held,omitempty"`
+	Drift        []string  `json:"drift,omitempty"`
+	LastAction   string    `json:"last_action,omitempty"`
+	LastActionAt time.Time `json:"last_action_at,omitempty"`
+	Failures     int       `json:"failures,omitempty"`
+	Error        string    `json:"error,omitempty"`
+}
+
+// DesiredReport 节点最近一轮收敛的结果
+type DesiredReport struct {
+	Revision   uint64                   `json:"revision"`
+	Checked    time.Time                `json:"checked"`
+	Containers []DesiredContainerReport `json:"containers"`
+	// Extra 由 domcluster 创建但不在期望状态中的容器
+	Extra    []string  `json:"extra,omitempty"`
+	Error    string    `json:"error,omitempty"`
+	Received time.Time `json:"received"`
+}
+
+// drifted 缺失、与声明不一致和多余的容器数量
+func (r *DesiredReport) drifted() int {
+	n := len(r.Extra)
+	for _, c := range r.Containers {
+		if c.State == desiredStateMissing || len(c.Drift) > 0 {
+			n++
+		}
+	}
+	return n
+}
+
+// DesiredStatus 节点的期望状态及其收敛情况
+type DesiredStatus struct {
+	NodeID    string            `json:"node_id"`
+	Connected bool              `json:"connected"`
+	State     *NodeDesiredState `json:"state,omitempty"`
+	Report    *DesiredReport    `json:"report,omitempty"`
+	// InSync 节点已按最新的期望状态收敛
+	InSync bool `json:"in_sync"`
+	// Drifted 缺失、与声明不一致和多余的容器数量
+	Drifted int `json:"drifted"`
+}
+
+// DesiredSyncResult 将栈同步到各节点期望状态的结果
+type DesiredSyncResult struct {
+	Stack string `json:"stack"`
+	// Nodes 各节点上属于该栈的期望容器数量
+	Nodes map[string]int `json:"nodes"`
+	// Errors 未能下发的节点，期望状态已保存，节点重新连接后补发
+	Errors map[string]string `json:"errors,omitempty"`
+}
+
+// stackDesiredSource 栈的容器在期望状态中的来源
+func stackDesiredSource(name string) string {
+	return "stack/" + name
+}
+
+// desiredDir 期望状态的保存目录，每个节点一个文件
+func desiredDir() string {
+	return filepath.Join(config.GetDataDir(), "desired")
+}
+
+// saveDesiredState 保存节点的期望状态，调用方需持有锁
+func saveDesiredState(st *NodeDesiredState) error {
+	data, err := json.MarshalIndent(st, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(desiredDir(), 0755); err != nil {
+		return err
+	}
+
+	path := filepath.Join(desiredDir(), st.NodeID+".json")
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// loadDesiredStates 读取各节点保存的期望状态
+func loadDesiredStates() map[string]*NodeDesiredState {
+	result := make(map[string]*NodeDesiredState)
+	entries, err := os.ReadDir(desiredDir())
+	if err != nil {
+		if !os.IsNotExist(err) {
+			zap.L().Sugar().Warnf("Failed to read desired states: %v", err)
+		}
+		return result
+	}
+
+	for _, e := range entries {
+		if e.IsDir() || !strings.HasSuffix(e.Name(), ".json") {
+			continue
+		}
+		data, err := os.ReadFile(filepath.Join(desiredDir(), e.Name()))
+		if err != nil {
+			zap.L().Sugar().Warnf("Failed to read desired state %s: %v", e.Name(), err)
+			continue
+		}
+		var st NodeDesiredState
+		if err := json.Unmarshal(data, &st); err != nil {
+			zap.L().Sugar().Warnf("Failed to parse desired state %s: %v", e.Name(), err)
+			continue
+		}
+		result[st.NodeID] = &st
+	}
+	return result
+}
+
+// validateDesired 检查容器名不重复且策略有效，完整的声明由节点检查
+func validateDesired(containers []DesiredContainer) error {
+	seen := make(map[string]string, len(containers))
+	for _, c := range containers {
+		if c.Spec.Name == "" || c.Spec.Image == "" {
+			return fmt.Errorf("spec name and image are required")
+		}
+		if source, ok := seen[c.Spec.Name]; ok {
+			return fmt.Errorf("container %s is already desired by %s", c.Spec.Name, source)
+		}
+		seen[c.Spec.Name] = c.Source
+
+		switch c.Policy {
+		case DesiredPolicyAlways, DesiredPolicyUnlessStopped, DesiredPolicyOnFailure, DesiredPolicyNever:
+		default:
+			return fmt.Errorf("container %s: invalid policy %q", c.Spec.Name, c.Policy)
+		}
+	}
+	return nil
+}
+
+// copyDesiredState 复制期望状态，避免调用方修改共享的数据
+func copyDesiredState(st *NodeDesiredState) *NodeDesiredState {
+	if st == nil {
+		return nil
+	}
+	cp := *st
+	cp.Containers = append([]DesiredContainer{}, st.Containers...)
+	return &cp
+}
+
+// SetNodeDesired 替换节点期望状态中来自 source 的容器并下发，containers 为空时删除该来源的容器
+//
+// 期望状态先保存再下发，节点未连接或下发失败时保留在控制端，节点报告的修订号落后时自动补发，
+// 下发结果记录在返回状态的 Pushed 和 PushError 中。
+func (s *DomclusterServer) SetNodeDesired(nodeID, source string, containers []DesiredContainer) (*NodeDesiredState, error) {
+	if nodeID == "" {
+		return nil, fmt.Errorf("node is required")
+	}
+	if source == "" {
+		source = DesiredSourceManual
+	}
+	list := make([]DesiredContainer, 0, len(containers))
+	for _, c := range containers {
+		c.Source = source
+		if c.Policy == "" {
+			c.Policy = DesiredPolicyUnlessStopped
+		}
+		list = append(list, c)
+	}
+
+	s.desiredMu.Lock()
+	cur := s.desired[nodeID]
+	if cur == nil && len(list) == 0 {
+		s.desiredMu.Unlock()
+		return &NodeDesiredState{NodeID: nodeID, Containers: []DesiredContainer{}}, nil
+	}
+
+	next := &NodeDesiredState{NodeID: nodeID, Updated: time.Now()}
+	if cur != nil {
+		next.Revision = cur.Revision
+		next.Pushed = cur.Pushed
+		for _, c := range cur.Containers {
+			if c.Source != source {
+				next.Containers = append(next.Containers, c)
+			}
+		}
+	}
+	next.Containers = append(next.Containers, list...)
+	if next.Containers == nil {
+		next.Containers = []DesiredContainer{}
+	}
+	if err := validateDesired(next.Containers); err != nil {
+		s.desiredMu.Unlock()
+		return nil, err
+	}
+	sort.Slice(next.Containers, func(i, j int) bool { return next.Containers[i].Spec.Name < next.Containers[j].Spec.Name })
+	next.Revision++
+
+	if err := saveDesiredState(next); err != nil {
+		s.desiredMu.Unlock()
+		return nil, fmt.Errorf("failed to save desired state: %w", err)
+	}
+	s.desired[nodeID] = next
+	s.desiredMu.Unlock()
+
+	zap.L().Sugar().Infof("Desired state of node %s updated to revision %d (%d containers from %s)",
+		nodeID, next.Revision, len(list), source)
+	if err := s.pushDesired(nodeID); err != nil {
+		zap.L().Sugar().Warnf("Failed to push desired state to node %s: %v", nodeID, err)
+	}
+
+	s.desiredMu.Lock()
+	defer s.desiredMu.Unlock()
+	return copyDesiredState(s.desired[nodeID]), nil
+}
+
+// pushDesired 将保存的期望状态下发给节点，同一时间只进行一次下发以保证修订号按顺序到达
+func (s *DomclusterServer) pushDesired(nodeID string) error {
+	s.desiredPushMu.Lock()
+	defer s.desiredPushMu.Unlock()
+
+	s.desiredMu.Lock()
+	st := copyDesiredState(s.desired[nodeID])
+	s.desiredMu.Unlock()
+	if st == nil {
+		return nil
+	}
+	if !s.IsNodeConnected(nodeID) {
+		return fmt.Errorf("node %s is not connected, revision %d will be pushed when it reconnects", nodeID, st.Revision)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), desiredPushTimeout)
+	defer cancel()
+	_, err := s.QueryNode(ctx, nodeID, "desired_set", map[string]interface{}{
+		"revision":   st.Revision,
+		"containers": st.Containers,
+	})
+
+	s.desiredMu.Lock()
+	defer s.desiredMu.Unlock()
+	cur := s.desired[nodeID]
+	if cur == nil || cur.Revision != st.Revision {
+		return err
+	}
+	if err != nil {
+		cur.PushError = err.Error()
+	} else {
+		cur.Pushed = st.Revision
+		cur.PushError = ""
+	}
+	if saveErr := saveDesiredState(cur); saveErr != nil {
+		zap.L().Sugar().Warnf("Failed to save desired state of node %s: %v", nodeID, saveErr)
+	}
+	if err == nil {
+		zap.L().Sugar().Infof("Pushed desired state revision %d to node %s", st.Revision, nodeID)
+	}
+	return err
+}
+
+// handleDesiredReport 处理节点上报的收敛结果，节点的修订号与控制端不一致时重新下发
+func (s *DomclusterServer) handleDesiredReport(req *pb.PublishRequest) *pb.PublishResponse {
+	var report DesiredReport
+	if err := json.Unmarshal(req.Data, &report); err != nil {
+		return errorResponse(req.ReqId, "invalid data")
+	}
+	report.Received = time.Now()
+
+	s.desiredMu.Lock()
+	prev := s.desiredReports[req.Issuer]
+	s.desiredReports[req.Issuer] = &report
+	st := s.desired[req.Issuer]
+	stale := st != nil && report.Revision != st.Revision
+	s.desiredMu.Unlock()
+
+	if n := report.drifted(); prev == nil || n != prev.drifted() {
+		if n > 0 {
+			zap.L().Sugar().Warnf("Node %s reports %d drifted containers", req.Issuer, n)
+		} else if prev != nil {
+			zap.L().Sugar().Infof("Node %s has converged to desired state revision %d", req.Issuer, report.Revision)
+		}
+	}
+	// 在接收循环之外下发，等待节点回复不能阻塞接收
+	if stale {
+		go func() {
+			if err := s.pushDesired(req.Issuer); err != nil {
+				zap.L().Sugar().Warnf("Failed to push desired state to node %s: %v", req.Issuer, err)
+			}
+		}()
+	}
+
+	return successResponse(req.ReqId, map[string]interface{}{
+		"message": "acknowledged",
+	})
+}
+
+// desiredStatusLocked 组合节点的期望状态和报告，调用方需持有锁
+func (s *DomclusterServer) desiredStatusLocked(nodeID string) DesiredStatus {
+	status := DesiredStatus{
+		NodeID:    nodeID,
+		Connected: s.IsNodeConnected(nodeID),
+		State:     copyDesiredState(s.desired[nodeID]),
+	}
+	if report, ok := s.desiredReports[nodeID]; ok {
+		cp := *report
+		status.Report = &cp
+		status.Drifted = report.drifted()
+		status.InSync = status.State != nil && report.Revision == status.State.Revision
+	}
+	return status
+}
+
+// GetDesiredStatus 查询节点的期望状态和收敛情况，live 为 true 时向节点获取最新的报告
+func (s *DomclusterServer) GetDesiredStatus(ctx context.Context, nodeID string, live bool) (*DesiredStatus, error) {
+	if live {
+		result, err := s.QueryNode(ctx, nodeID, "desired_status", nil)
+		if err != nil {
+			return nil, err
+		}
+		var resp struct {
+			Report *DesiredReport `json:"report"`
+		}
+		if err := json.Unmarshal(result, &resp); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal desired status: %w", err)
+		}
+		if resp.Report != nil {
+			resp.Report.Received = time.Now()
+			s.desiredMu.Lock()
+			s.desiredReports[nodeID] = resp.Report
+			s.desiredMu.Unlock()
+		}
+	}
+
+	s.desiredMu.Lock()
+	defer s.desiredMu.Unlock()
+	status := s.desiredStatusLocked(nodeID)
+	if status.State == nil && status.Report == nil {
+		return nil, fmt.Errorf("no desired state for node %s: not found", nodeID)
+	}
+	return &status, nil
+}
+
+// ListDesiredStatus 列出所有有期望状态或报告的节点
+func (s *DomclusterServer) ListDesiredStatus() []DesiredStatus {
+	s.desiredMu.Lock()
+	defer s.desiredMu.Unlock()
+
+	nodes := make(map[string]bool)
+	for node := range s.desired {
+		nodes[node] = true
+	}
+	for node := range s.desiredReports {
+		nodes[node] = true
+	}
+	result := make([]DesiredStatus, 0, len(nodes))
+	for node := range nodes {
+		result = append(result, s.desiredStatusLocked(node))
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].NodeID < result[j].NodeID })
+	return result
+}
+
+// SyncStackDesired 按栈当前的声明更新各节点的期望状态，节点之后会自行重建缺失的容器
+func (s *DomclusterServer) SyncStackDesired(name string) (*DesiredSyncResult, error) {
+	spec, err := loadStackSpec(name)
+	if err != nil {
+		return nil, err
+	}
+	// 部分节点无法规划时不同步，否则这些节点上的期望容器会被删除
+	plan, planErrs := s.stackPlan(spec)
+	if len(planErrs) > 0 {
+		nodes := make([]string, 0, len(planErrs))
+		for node := range planErrs {
+			nodes = append(nodes, node)
+		}
+		sort.Strings(nodes)
+		return nil, fmt.Errorf("failed to plan judgehosts on %s: %w", nodes[0], planErrs[nodes[0]])
+	}
+	return s.syncStackDesired(name, plan), nil
+}
+
+// syncStackDesired 将栈的容器写入各节点的期望状态，plan 为空时从所有节点删除该栈
+func (s *DomclusterServer) syncStackDesired(name string, plan []stackContainer) *DesiredSyncResult {
+	source := stackDesiredSource(name)
+	byNode := make(map[string][]DesiredContainer)
+	for _, sc := range plan {
+		byNode[sc.NodeID] = append(byNode[sc.NodeID], DesiredContainer{
+			Policy: DesiredPolicyUnlessStopped,
+			Spec:   *sc.Spec,
+		})
+	}
+
+	// 之前有该栈容器的节点也要更新，以删除不再需要的容器
+	s.desiredMu.Lock()
+	for node, st := range s.desired {
+		for _, c := range st.Containers {
+			if c.Source == source {
+				if _, ok := byNode[node]; !ok {
+					byNode[node] = nil
+				}
+				break
+			}
+		}
+	}
+	s.desiredMu.Unlock()
+
+	nodes := make([]string, 0, len(byNode))
+	for node := range byNode {
+		nodes = append(nodes, node)
+	}
+	sort.Strings(nodes)
+
+	result := &DesiredSyncResult{Stack: name, Nodes: make(map[string]int)}
+	for _, node := range nodes {
+		result.Nodes[node] = len(byNode[node])
+		st, err := s.SetNodeDesired(node, source, byNode[node])
+		switch {
+		case err != nil:
+		case st.PushError != "":
+			err = fmt.Errorf("%s", st.PushError)
+		case st.Pushed != st.Revision:
+			err = fmt.Errorf("node is not connected, will be pushed when it reconnects")
+		}
+		if err != nil {
+			if result.Errors == nil {
+				result.Errors = make(map[string]string)
+			}
+			result.Errors[node] = err.Error()
+		}
+	}
+	return result
+}
+
+// refreshStackDesired 栈的声明变化后更新已同步到期望状态的栈
+func (s *DomclusterServer) refreshStackDesired(name string) {
+	source := stackDesiredSource(name)
+	synced := false
+	s.desiredMu.Lock()
+	for _, st := range s.desired {
+		for _, c := range st.Containers {
+			if c.Source == source {
+				synced = true
+				break
+			}
+		}
+	}
+	s.desiredMu.Unlock()
+	if !synced {
+		return
+	}
+
+	result, err := s.SyncStackDesired(name)
+	if err != nil {
+		zap.L().Sugar().Warnf("Failed to update desired state of stack %s: %v", name, err)
+		return
+	}
+	for node, msg := range result.Errors {
+		zap.L().Sugar().Warnf("Desired state of stack %s on node %s not pushed: %s", name, node, msg)
+	}
+}
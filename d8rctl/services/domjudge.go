@@ -0,0 +1,353 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"d8rctl/config"
+	"d8rctl/services/domjudge"
+
+	"go.uber.org/zap"
+)
+
+// domjudgeCacheTTL judgehost 列表的缓存时间，避免节点列表等高频接口每次都请求 domserver
+const domjudgeCacheTTL = 5 * time.Second
+
+// JudgehostStatus DomJudge 中的 judgehost 及其对应的节点和容器
+type JudgehostStatus struct {
+	Hostname string     `json:"hostname"`
+	Enabled  bool       `json:"enabled"`
+	Hidden   bool       `json:"hidden"`
+	Polltime *time.Time `json:"polltime,omitempty"`
+	// PollAge 距最后一次轮询的秒数，judgehost 停止工作后持续增长
+	PollAge float64 `json:"poll_age,omitempty"`
+	// Stack 等字段在 judgehost 能对应到栈中的容器时才有值
+	Stack          string            `json:"stack,omitempty"`
+	NodeID         string            `json:"node_id,omitempty"`
+	Container      string            `json:"container,omitempty"`
+	CurrentJudging *domjudge.Judging `json:"current_judging,omitempty"`
+}
+
+// domjudgeIntegration DomJudge 集成的配置和缓存
+type domjudgeIntegration struct {
+	mu       sync.Mutex
+	path     string
+	cfg      *domjudge.Config
+	client   *domjudge.Client
+	hosts    []domjudge.Judgehost
+	judgings []domjudge.Judging
+	cachedAt time.Time
+}
+
+// newDomjudgeIntegration 创建集成并读取已保存的配置
+func newDomjudgeIntegration(path string) *domjudgeIntegration {
+	d := &domjudgeIntegration{path: path}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			zap.L().Sugar().Warnf("Failed to load DomJudge config from %s: %v", path, err)
+		}
+		return d
+	}
+	var cfg domjudge.Config
+	if err := json.Unmarshal(data, &cfg); err != nil {
+		zap.L().Sugar().Warnf("Failed to parse DomJudge config %s: %v", path, err)
+		return d
+	}
+	d.cfg = &cfg
+	d.client = domjudge.NewClient(cfg)
+	return d
+}
+
+// domjudgeConfigPath DomJudge 配置的保存位置
+func domjudgeConfigPath() string {
+	return filepath.Join(config.GetDataDir(), "domjudge.json")
+}
+
+// GetDomjudgeConfig 返回 DomJudge 配置，密码不返回；未配置时返回 nil
+func (s *DomclusterServer) GetDomjudgeConfig() *domjudge.Config {
+	d := s.domjudge
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if d.cfg == nil {
+		return nil
+	}
+	cfg := *d.cfg
+	cfg.Password = ""
+	return &cfg
+}
+
+// SetDomjudgeConfig 保存 DomJudge 配置，密码为空时沿用原有密码；URL 为空表示关闭集成
+func (s *DomclusterServer) SetDomjudgeConfig(cfg domjudge.Config) error {
+	d := s.domjudge
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if cfg.URL == "" {
+		if err := os.Remove(d.path); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		d.cfg, d.client, d.hosts, d.judgings = nil, nil, nil, nil
+		return nil
+	}
+
+	if err := cfg.Validate(); err != nil {
+		return fmt.Errorf("invalid DomJudge config: %w", err)
+	}
+	if cfg.Password == "" && d.cfg != nil {
+		cfg.Password = d.cfg.Password
+	}
+
+	data, err := json.MarshalIndent(cfg, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(d.path), 0755); err != nil {
+		return err
+	}
+	// 配置中包含密码，仅 root 可读
+	tmp := d.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, d.path); err != nil {
+		return err
+	}
+
+	d.cfg = &cfg
+	d.client = domjudge.NewClient(cfg)
+	d.hosts, d.judgings = nil, nil
+	d.cachedAt = time.Time{}
+	return nil
+}
+
+// domjudgeClient 返回当前配置的客户端
+func (s *DomclusterServer) domjudgeClient() (*domjudge.Client, error) {
+	d := s.domjudge
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if d.client == nil {
+		return nil, fmt.Errorf("DomJudge integration is not configured")
+	}
+	return d.client, nil
+}
+
+// fetchJudgehosts 查询 judgehost 列表和正在进行的评测，fresh 为 false 时可使用缓存
+func (s *DomclusterServer) fetchJudgehosts(ctx context.Context, fresh bool) ([]domjudge.Judgehost, []domjudge.Judging, error) {
+	d := s.domjudge
+	d.mu.Lock()
+	client := d.client
+	if client == nil {
+		d.mu.Unlock()
+		return nil, nil, fmt.Errorf("DomJudge integration is not configured")
+	}
+	if !fresh && d.hosts != nil && time.Since(d.cachedAt) < domjudgeCacheTTL {
+		hosts, judgings := d.hosts, d.judgings
+		d.mu.Unlock()
+		return hosts, judgings, nil
+	}
+	d.mu.Unlock()
+
+	hosts, err := client.Judgehosts(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// 当前评测只是附加信息，查询失败不影响 judgehost 列表
+	var judgings []domjudge.Judging
+	if contests, err := client.ActiveContests(ctx); err != nil {
+		zap.L().Sugar().Debugf("Failed to list active DomJudge contests: %v", err)
+	} else {
+		for _, c := range contests {
+			running, err := client.RunningJudgings(ctx, c.ID)
+			if err != nil {
+				zap.L().Sugar().Debugf("Failed to list judgings of contest %s: %v", c.ID, err)
+				continue
+			}
+			judgings = append(judgings, running...)
+		}
+	}
+
+	d.mu.Lock()
+	if d.client == client {
+		d.hosts, d.judgings, d.cachedAt = hosts, judgings, time.Now()
+	}
+	d.mu.Unlock()
+	return hosts, judgings, nil
+}
+
+// judgehostContainer judgehost 对应的栈容器
+type judgehostContainer struct {
+	Stack     string
+	NodeID    string
+	Container string
+}
+
+// judgehostName 返回 judgehost 容器注册到 domserver 的名字
+//
+// judgedaemon 取主机名的第一段，设置了 DAEMON_ID 时再追加 -<DAEMON_ID>。
+func judgehostName(spec *ContainerSpec) string {
+	host, _, _ := strings.Cut(spec.Hostname, ".")
+	if id := spec.Env["DAEMON_ID"]; id != "" {
+		return host + "-" + id
+	}
+	return host
+}
+
+// judgehostContainers 按 DomJudge 中的名字索引全部栈的 judgehost 容器
+func (s *DomclusterServer) judgehostContainers() map[string]judgehostContainer {
+	result := make(map[string]judgehostContainer)
+
+	names, err := s.ListStacks()
+	if err != nil {
+		zap.L().Sugar().Warnf("Failed to list stacks: %v", err)
+		return result
+	}
+	for _, name := range names {
+		spec, err := loadStackSpec(name)
+		if err != nil {
+			zap.L().Sugar().Warnf("Failed to load stack %s: %v", name, err)
+			continue
+		}
+		containers, _ := s.stackPlan(spec)
+		indexJudgehosts(result, spec.Name, containers)
+	}
+	return result
+}
+
+// indexJudgehosts 将栈中的 judgehost 容器按 DomJudge 中的名字加入索引
+func indexJudgehosts(index map[string]judgehostContainer, stack string, containers []stackContainer) {
+	for _, sc := range containers {
+		if sc.Component != StackComponentJudgehost {
+			continue
+		}
+		index[judgehostName(sc.Spec)] = judgehostContainer{
+			Stack:     stack,
+			NodeID:    sc.NodeID,
+			Container: sc.Spec.Name,
+		}
+	}
+}
+
+// ListJudgehosts 列出 DomJudge 中的 judgehost，并关联到节点和容器
+func (s *DomclusterServer) ListJudgehosts(ctx context.Context, fresh bool) ([]JudgehostStatus, error) {
+	hosts, judgings, err := s.fetchJudgehosts(ctx, fresh)
+	if err != nil {
+		return nil, err
+	}
+
+	return judgehostStatuses(hosts, judgings, s.judgehostContainers(), time.Now()), nil
+}
+
+// judgehostStatuses 关联 judgehost、正在进行的评测和栈容器，按名字排序
+func judgehostStatuses(hosts []domjudge.Judgehost, judgings []domjudge.Judging, containers map[string]judgehostContainer, now time.Time) []JudgehostStatus {
+	current := make(map[string]*domjudge.Judging)
+	for i := range judgings {
+		if judgings[i].Judgehost != "" {
+			current[judgings[i].Judgehost] = &judgings[i]
+		}
+	}
+
+	result := make([]JudgehostStatus, 0, len(hosts))
+	for _, h := range hosts {
+		st := JudgehostStatus{
+			Hostname:       h.Hostname,
+			Enabled:        h.Enabled,
+			Hidden:         h.Hidden,
+			CurrentJudging: current[h.Hostname],
+		}
+		if !h.Polltime.IsZero() {
+			t := h.Polltime.Time
+			st.Polltime = &t
+			st.PollAge = now.Sub(t).Seconds()
+		}
+		if jc, ok := containers[h.Hostname]; ok {
+			st.Stack = jc.Stack
+			st.NodeID = jc.NodeID
+			st.Container = jc.Container
+		}
+		result = append(result, st)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Hostname < result[j].Hostname })
+	return result
+}
+
+// NodeJudgehosts 返回节点上的 judgehost，DomJudge 未配置时返回 nil
+func (s *DomclusterServer) NodeJudgehosts(ctx context.Context, nodeID string) ([]JudgehostStatus, error) {
+	if _, err := s.domjudgeClient(); err != nil {
+		return nil, nil
+	}
+	all, err := s.ListJudgehosts(ctx, false)
+	if err != nil {
+		return nil, err
+	}
+
+	result := []JudgehostStatus{}
+	for _, h := range all {
+		if h.NodeID == nodeID {
+			result = append(result, h)
+		}
+	}
+	return result, nil
+}
+
+// SetJudgehostEnabled 在 DomJudge 中启用或禁用 judgehost
+//
+// target 为 DomJudge 中的 judgehost 名字，或节点 ID（作用于节点上的全部 judgehost）。
+func (s *DomclusterServer) SetJudgehostEnabled(ctx context.Context, target string, enabled bool) ([]JudgehostStatus, error) {
+	client, err := s.domjudgeClient()
+	if err != nil {
+		return nil, err
+	}
+	all, err := s.ListJudgehosts(ctx, true)
+	if err != nil {
+		return nil, err
+	}
+
+	var hostnames []string
+	for _, h := range all {
+		if h.Hostname == target {
+			hostnames = []string{h.Hostname}
+			break
+		}
+		if h.NodeID == target {
+			hostnames = append(hostnames, h.Hostname)
+		}
+	}
+	if len(hostnames) == 0 {
+		return nil, fmt.Errorf("judgehost %s not found in DomJudge", target)
+	}
+
+	for _, name := range hostnames {
+		if _, err := client.SetJudgehostEnabled(ctx, name, enabled); err != nil {
+			return nil, fmt.Errorf("failed to update judgehost %s: %w", name, err)
+		}
+		zap.L().Sugar().Infof("Judgehost %s enabled=%v in DomJudge", name, enabled)
+	}
+
+	all, err = s.ListJudgehosts(ctx, true)
+	if err != nil {
+		return nil, err
+	}
+	updated := make(map[string]bool, len(hostnames))
+	for _, name := range hostnames {
+		updated[name] = true
+	}
+	result := []JudgehostStatus{}
+	for _, h := range all {
+		if updated[h.Hostname] {
+			result = append(result, h)
+		}
+	}
+	return result, nil
+}
@@ -0,0 +1,279 @@
+package domjudge
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// defaultTimeout 调用 DomJudge API 的默认超时时间
+const defaultTimeout = 10 * time.Second
+
+// Config DomJudge API 的连接配置
+type Config struct {
+	// URL domserver 的地址，如 https://domjudge.example.com/，不含 /api/v4
+	URL      string `json:"url"`
+	Username string `json:"username"`
+	Password string `json:"password,omitempty"`
+	// TimeoutSeconds 单次请求的超时秒数，默认 10
+	TimeoutSeconds int `json:"timeout_seconds,omitempty"`
+	// InsecureSkipVerify 不校验 domserver 的 TLS 证书，仅用于自签名证书的内网部署
+	InsecureSkipVerify bool `json:"insecure_skip_verify,omitempty"`
+}
+
+// Validate 检查配置是否完整
+func (c Config) Validate() error {
+	u, err := url.Parse(c.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("invalid url %q, expected http(s)://host/", c.URL)
+	}
+	if c.Username == "" {
+		return fmt.Errorf("username is required")
+	}
+	if c.TimeoutSeconds < 0 {
+		return fmt.Errorf("timeout_seconds must not be negative")
+	}
+	return nil
+}
+
+// Judgehost DomJudge 中的 judgehost
+type Judgehost struct {
+	ID       string `json:"id"`
+	Hostname string `json:"hostname"`
+	Enabled  bool   `json:"enabled"`
+	Hidden   bool   `json:"hidden"`
+	// Polltime judgehost 最后一次向 domserver 请求任务的时间
+	Polltime Timestamp `json:"polltime"`
+}
+
+// Judging 正在进行的评测
+type Judging struct {
+	ID           string `json:"id"`
+	ContestID    string `json:"contest_id"`
+	SubmissionID string `json:"submission_id"`
+	Judgehost    string `json:"judgehost"`
+	StartTime    string `json:"start_time"`
+}
+
+// Contest DomJudge 中的比赛
+type Contest struct {
+	ID        string `json:"id"`
+	ShortName string `json:"shortname"`
+	Name      string `json:"name"`
+}
+
+// Timestamp DomJudge 返回的 Unix 时间戳，不同版本可能是数字或字符串
+type Timestamp struct {
+	time.Time
+}
+
+// UnmarshalJSON 同时接受 1700000000.123 和 "1700000000.123"，null 为零值
+func (t *Timestamp) UnmarshalJSON(data []byte) error {
+	s := strings.Trim(string(data), `"`)
+	if s == "" || s == "null" {
+		t.Time = time.Time{}
+		return nil
+	}
+	f, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return fmt.Errorf("invalid timestamp %s", data)
+	}
+	sec := int64(f)
+	t.Time = time.Unix(sec, int64((f-float64(sec))*1e9))
+	return nil
+}
+
+// MarshalJSON 输出 RFC 3339 时间，零值为 null
+func (t Timestamp) MarshalJSON() ([]byte, error) {
+	if t.IsZero() {
+		return []byte("null"), nil
+	}
+	return json.Marshal(t.Time)
+}
+
+// APIError DomJudge 返回的错误
+type APIError struct {
+	StatusCode int
+	Message    string
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("domjudge api returned %d: %s", e.StatusCode, e.Message)
+}
+
+// Client DomJudge REST API（/api/v4）客户端
+type Client struct {
+	cfg  Config
+	base string
+	http *http.Client
+}
+
+// NewClient 创建客户端
+func NewClient(cfg Config) *Client {
+	timeout := defaultTimeout
+	if cfg.TimeoutSeconds > 0 {
+		timeout = time.Duration(cfg.TimeoutSeconds) * time.Second
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	if cfg.InsecureSkipVerify {
+		transport.TLSClientConfig = &tls.Config{InsecureSkipVerify: true}
+	}
+
+	return &Client{
+		cfg:  cfg,
+		base: strings.TrimRight(cfg.URL, "/") + "/api/v4",
+		http: &http.Client{Timeout: timeout, Transport: transport},
+	}
+}
+
+// do 发送请求，out 不为 nil 时解析返回的 JSON
+func (c *Client) do(ctx context.Context, method, path string, body, out interface{}) error {
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reader = bytes.NewReader(data)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, c.base+path, reader)
+	if err != nil {
+		return err
+	}
+	req.SetBasicAuth(c.cfg.Username, c.cfg.Password)
+	req.Header.Set("Accept", "application/json")
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return fmt.Errorf("domjudge api request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		data, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		var errResp struct {
+			Message string `json:"message"`
+		}
+		msg := strings.TrimSpace(string(data))
+		if json.Unmarshal(data, &errResp) == nil && errResp.Message != "" {
+			msg = errResp.Message
+		}
+		return &APIError{StatusCode: resp.StatusCode, Message: msg}
+	}
+
+	if out == nil {
+		return nil
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("failed to decode domjudge response: %w", err)
+	}
+	return nil
+}
+
+// Judgehosts 列出全部 judgehost
+func (c *Client) Judgehosts(ctx context.Context) ([]Judgehost, error) {
+	var hosts []Judgehost
+	if err := c.do(ctx, http.MethodGet, "/judgehosts", nil, &hosts); err != nil {
+		return nil, err
+	}
+	return hosts, nil
+}
+
+// SetJudgehostEnabled 启用或禁用 judgehost，禁用后 domserver 不再向其分配新的评测
+func (c *Client) SetJudgehostEnabled(ctx context.Context, hostname string, enabled bool) (*Judgehost, error) {
+	var hosts []Judgehost
+	path := "/judgehosts/" + url.PathEscape(hostname)
+	if err := c.do(ctx, http.MethodPut, path, map[string]bool{"enabled": enabled}, &hosts); err != nil {
+		return nil, err
+	}
+	for i := range hosts {
+		if hosts[i].Hostname == hostname {
+			return &hosts[i], nil
+		}
+	}
+	// 部分版本对未知的 judgehost 返回 200 和空列表，统一为 404 以便调用方区分
+	return nil, &APIError{StatusCode: http.StatusNotFound, Message: fmt.Sprintf("judgehost %s not found", hostname)}
+}
+
+// ActiveContests 列出进行中的比赛
+func (c *Client) ActiveContests(ctx context.Context) ([]Contest, error) {
+	var contests []Contest
+	if err := c.do(ctx, http.MethodGet, "/contests?onlyActive=true", nil, &contests); err != nil {
+		return nil, err
+	}
+	return contests, nil
+}
+
+// RunningJudgings 列出比赛中尚未结束的评测
+//
+// 评测由哪个 judgehost 处理只在非严格模式（strict=false）下返回，旧版本 DomJudge 中 Judgehost 可能为空。
+func (c *Client) RunningJudgings(ctx context.Context, contestID string) ([]Judging, error) {
+	var judgements []struct {
+		Judging
+		EndTime *string `json:"end_time"`
+		Valid   *bool   `json:"valid"`
+	}
+	path := "/contests/" + url.PathEscape(contestID) + "/judgements?strict=false"
+	if err := c.do(ctx, http.MethodGet, path, nil, &judgements); err != nil {
+		return nil, err
+	}
+
+	var running []Judging
+	for _, j := range judgements {
+		if j.EndTime != nil && *j.EndTime != "" {
+			continue
+		}
+		if j.Valid != nil && !*j.Valid {
+			continue
+		}
+		judging := j.Judging
+		judging.ContestID = contestID
+		running = append(running, judging)
+	}
+	return running, nil
+}
+
+// Queue 统计比赛中尚未得出评测结果的提交数量，包括排队中和正在评测的提交
+func (c *Client) Queue(ctx context.Context, contestID string) (int, error) {
+	var submissions []struct {
+		ID string `json:"id"`
+	}
+	if err := c.do(ctx, http.MethodGet, "/contests/"+url.PathEscape(contestID)+"/submissions", nil, &submissions); err != nil {
+		return 0, err
+	}
+	var judgements []struct {
+		SubmissionID    string  `json:"submission_id"`
+		JudgementTypeID *string `json:"judgement_type_id"`
+		Valid           *bool   `json:"valid"`
+	}
+	path := "/contests/" + url.PathEscape(contestID) + "/judgements?strict=false"
+	if err := c.do(ctx, http.MethodGet, path, nil, &judgements); err != nil {
+		return 0, err
+	}
+
+	judged := make(map[string]bool, len(judgements))
+	for _, j := range judgements {
+		if j.JudgementTypeID != nil && *j.JudgementTypeID != "" && (j.Valid == nil || *j.Valid) {
+			judged[j.SubmissionID] = true
+		}
+	}
+	queued := 0
+	for _, s := range submissions {
+		if !judged[s.ID] {
+			queued++
+		}
+	}
+	return queued, nil
+}
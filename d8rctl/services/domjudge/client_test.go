@@ -0,0 +1,196 @@
+package domjudge
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// newTestClient 启动模拟 domserver，handlers 按 "METHOD /api/v4/path" 注册
+func newTestClient(t *testing.T, handlers map[string]http.HandlerFunc) *Client {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user, pass, ok := r.BasicAuth()
+		if !ok || user != "admin" || pass != "secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			json.NewEncoder(w).Encode(map[string]interface{}{"code": 401, "message": "Unauthorized"})
+			return
+		}
+		h, ok := handlers[r.Method+" "+r.URL.Path]
+		if !ok {
+			http.Error(w, "no route for "+r.Method+" "+r.URL.Path, http.StatusNotFound)
+			return
+		}
+		h(w, r)
+	}))
+	t.Cleanup(srv.Close)
+	return NewClient(Config{URL: srv.URL + "/", Username: "admin", Password: "secret"})
+}
+
+// writeBody 输出原样的 JSON
+func writeBody(body string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(body))
+	}
+}
+
+func TestJudgehosts(t *testing.T) {
+	client := newTestClient(t, map[string]http.HandlerFunc{
+		"GET /api/v4/judgehosts": writeBody(`[
+			{"id": "1", "hostname": "judge01-0", "enabled": true, "hidden": false, "polltime": 1700000000.5},
+			{"id": "2", "hostname": "judge01-1", "enabled": false, "hidden": true, "polltime": "1700000100"},
+			{"id": "3", "hostname": "judge02-0", "enabled": true, "hidden": false, "polltime": null}
+		]`),
+	})
+
+	hosts, err := client.Judgehosts(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(hosts) != 3 {
+		t.Fatalf("got %d judgehosts, want 3", len(hosts))
+	}
+	if h := hosts[0]; h.Hostname != "judge01-0" || !h.Enabled || !h.Polltime.Equal(time.Unix(1700000000, 5e8)) {
+		t.Errorf("unexpected judgehost %+v", h)
+	}
+	if h := hosts[1]; h.Enabled || !h.Hidden || !h.Polltime.Equal(time.Unix(1700000100, 0)) {
+		t.Errorf("unexpected judgehost %+v", h)
+	}
+	if !hosts[2].Polltime.IsZero() {
+		t.Errorf("null polltime parsed as %v", hosts[2].Polltime)
+	}
+}
+
+func TestSetJudgehostEnabled(t *testing.T) {
+	var got map[string]bool
+	client := newTestClient(t, map[string]http.HandlerFunc{
+		"PUT /api/v4/judgehosts/judge01-0": func(w http.ResponseWriter, r *http.Request) {
+			if ct := r.Header.Get("Content-Type"); ct != "application/json" {
+				t.Errorf("Content-Type = %q", ct)
+			}
+			json.NewDecoder(r.Body).Decode(&got)
+			writeBody(`[{"id": "1", "hostname": "judge01-0", "enabled": false}]`)(w, r)
+		},
+		// 部分版本对未知的 judgehost 返回空列表
+		"PUT /api/v4/judgehosts/ghost": writeBody(`[]`),
+	})
+
+	host, err := client.SetJudgehostEnabled(context.Background(), "judge01-0", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if enabled, ok := got["enabled"]; !ok || enabled {
+		t.Errorf("request body = %v, want enabled=false", got)
+	}
+	if host.Hostname != "judge01-0" || host.Enabled {
+		t.Errorf("unexpected judgehost %+v", host)
+	}
+
+	_, err = client.SetJudgehostEnabled(context.Background(), "ghost", true)
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusNotFound {
+		t.Errorf("unknown judgehost: got %v, want a 404 APIError", err)
+	}
+}
+
+func TestAPIError(t *testing.T) {
+	client := newTestClient(t, nil)
+
+	// 认证失败时使用 JSON 中的 message
+	bad := NewClient(Config{URL: client.cfg.URL, Username: "admin", Password: "wrong"})
+	_, err := bad.Judgehosts(context.Background())
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) {
+		t.Fatalf("got %v, want an APIError", err)
+	}
+	if apiErr.StatusCode != http.StatusUnauthorized || apiErr.Message != "Unauthorized" {
+		t.Errorf("got %+v, want 401 Unauthorized", apiErr)
+	}
+
+	// 非 JSON 的错误内容原样保留
+	_, err = client.SetJudgehostEnabled(context.Background(), "judge09-0", false)
+	if !errors.As(err, &apiErr) {
+		t.Fatalf("got %v, want an APIError", err)
+	}
+	if apiErr.StatusCode != http.StatusNotFound || apiErr.Message != "no route for PUT /api/v4/judgehosts/judge09-0" {
+		t.Errorf("got %+v, want 404 with the response text", apiErr)
+	}
+}
+
+func TestActiveContests(t *testing.T) {
+	client := newTestClient(t, map[string]http.HandlerFunc{
+		"GET /api/v4/contests": func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Query().Get("onlyActive") != "true" {
+				t.Errorf("query = %q, want onlyActive=true", r.URL.RawQuery)
+			}
+			writeBody(`[{"id": "2", "shortname": "final", "name": "Final"}]`)(w, r)
+		},
+	})
+
+	contests, err := client.ActiveContests(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(contests) != 1 || contests[0].ID != "2" || contests[0].ShortName != "final" {
+		t.Errorf("unexpected contests %+v", contests)
+	}
+}
+
+func TestRunningJudgings(t *testing.T) {
+	client := newTestClient(t, map[string]http.HandlerFunc{
+		"GET /api/v4/contests/2/judgements": func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Query().Get("strict") != "false" {
+				t.Errorf("query = %q, want strict=false", r.URL.RawQuery)
+			}
+			writeBody(`[
+				{"id": "10", "submission_id": "100", "judgehost": "judge01-0", "start_time": "2024-01-01T10:00:00.000+00:00", "end_time": "2024-01-01T10:00:05.000+00:00", "valid": true},
+				{"id": "11", "submission_id": "101", "judgehost": "judge01-1", "start_time": "2024-01-01T10:00:01.000+00:00", "end_time": null, "valid": true},
+				{"id": "12", "submission_id": "102", "judgehost": "judge02-0", "start_time": "2024-01-01T10:00:02.000+00:00", "end_time": null, "valid": false},
+				{"id": "13", "submission_id": "103", "start_time": "2024-01-01T10:00:03.000+00:00"}
+			]`)(w, r)
+		},
+	})
+
+	running, err := client.RunningJudgings(context.Background(), "2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 已结束和已作废的评测被过滤，旧版本中缺少 judgehost 的评测保留
+	if len(running) != 2 {
+		t.Fatalf("got %d running judgings, want 2: %+v", len(running), running)
+	}
+	if j := running[0]; j.ID != "11" || j.Judgehost != "judge01-1" || j.ContestID != "2" || j.SubmissionID != "101" {
+		t.Errorf("unexpected judging %+v", j)
+	}
+	if j := running[1]; j.ID != "13" || j.Judgehost != "" || j.ContestID != "2" {
+		t.Errorf("unexpected judging %+v", j)
+	}
+}
+
+func TestQueue(t *testing.T) {
+	client := newTestClient(t, map[string]http.HandlerFunc{
+		"GET /api/v4/contests/2/submissions": writeBody(`[
+			{"id": "100"}, {"id": "101"}, {"id": "102"}, {"id": "103"}, {"id": "104"}
+		]`),
+		"GET /api/v4/contests/2/judgements": writeBody(`[
+			{"id": "10", "submission_id": "100", "judgement_type_id": "AC", "valid": true},
+			{"id": "11", "submission_id": "101", "judgement_type_id": null, "valid": true},
+			{"id": "12", "submission_id": "102", "judgement_type_id": "WA", "valid": false},
+			{"id": "13", "submission_id": "103", "judgement_type_id": "TLE"}
+		]`),
+	})
+
+	// 100 和 103 已有结果；101 正在评测，102 的结果已作废需要重新评测，104 还在排队
+	queue, err := client.Queue(context.Background(), "2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if queue != 3 {
+		t.Errorf("queue = %d, want 3", queue)
+	}
+}
@@ -0,0 +1,85 @@
+package services
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"d8rctl/services/domjudge"
+)
+
+func TestJudgehostName(t *testing.T) {
+	tests := []struct {
+		hostname string
+		env      map[string]string
+		want     string
+	}{
+		{"judge01", map[string]string{"DAEMON_ID": "3"}, "judge01-3"},
+		// judgedaemon 只取主机名的第一段
+		{"judge01.contest.local", map[string]string{"DAEMON_ID": "0"}, "judge01-0"},
+		{"judge01.contest.local", nil, "judge01"},
+	}
+	for _, tt := range tests {
+		spec := &ContainerSpec{Hostname: tt.hostname, Env: tt.env}
+		if got := judgehostName(spec); got != tt.want {
+			t.Errorf("judgehostName(%q, %v) = %q, want %q", tt.hostname, tt.env, got, tt.want)
+		}
+	}
+}
+
+func TestJudgehostStatuses(t *testing.T) {
+	spec := &StackSpec{Name: "contest"}
+	containers := spec.containers([]judgehostSlot{
+		{NodeID: "node-a", DaemonID: 2, CPUSet: "2", Suffix: "c2"},
+		{NodeID: "node-b.lan", DaemonID: 0, CPUSet: "0", Suffix: "c0"},
+	})
+	index := make(map[string]judgehostContainer)
+	indexJudgehosts(index, spec.Name, containers)
+
+	wantIndex := map[string]judgehostContainer{
+		"node-a-2": {Stack: "contest", NodeID: "node-a", Container: "contest-judgehost-c2"},
+		"node-b-0": {Stack: "contest", NodeID: "node-b.lan", Container: "contest-judgehost-c0"},
+	}
+	if !reflect.DeepEqual(index, wantIndex) {
+		t.Fatalf("index = %+v, want %+v", index, wantIndex)
+	}
+
+	now := time.Unix(1700000100, 0)
+	hosts := []domjudge.Judgehost{
+		{Hostname: "node-b-0", Enabled: true},
+		{Hostname: "node-a-2", Enabled: true, Polltime: domjudge.Timestamp{Time: time.Unix(1700000090, 0)}},
+		{Hostname: "legacy-judge", Enabled: false},
+	}
+	judgings := []domjudge.Judging{
+		{ID: "11", Judgehost: "node-a-2"},
+		{ID: "12"},
+	}
+
+	result := judgehostStatuses(hosts, judgings, index, now)
+	if len(result) != 3 {
+		t.Fatalf("got %d judgehosts, want 3", len(result))
+	}
+	// 按名字排序
+	if result[0].Hostname != "legacy-judge" || result[1].Hostname != "node-a-2" || result[2].Hostname != "node-b-0" {
+		t.Fatalf("unexpected order: %s, %s, %s", result[0].Hostname, result[1].Hostname, result[2].Hostname)
+	}
+
+	// 不属于任何栈的 judgehost 没有节点信息
+	if st := result[0]; st.NodeID != "" || st.Stack != "" || st.Polltime != nil {
+		t.Errorf("unexpected mapping for an unknown judgehost: %+v", st)
+	}
+	a := result[1]
+	if a.NodeID != "node-a" || a.Container != "contest-judgehost-c2" || a.Stack != "contest" {
+		t.Errorf("node-a-2 mapped to %+v", a)
+	}
+	if a.CurrentJudging == nil || a.CurrentJudging.ID != "11" {
+		t.Errorf("node-a-2 current judging = %+v, want 11", a.CurrentJudging)
+	}
+	if a.PollAge != 10 {
+		t.Errorf("node-a-2 poll age = %v, want 10", a.PollAge)
+	}
+	b := result[2]
+	if b.NodeID != "node-b.lan" || b.CurrentJudging != nil {
+		t.Errorf("node-b-0 mapped to %+v", b)
+	}
+}
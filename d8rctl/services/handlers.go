@@ -22,6 +22,14 @@ func (s *DomclusterServer) handleRequest(req *pb.PublishRequest) *pb.PublishResp
 		return s.handleCommandOutput(req)
 	case "status_update":
 		return s.handleStatusUpdate(req)
+	case "status_delta":
+		return s.handleStatusDelta(req)
+	case "agent_config_ack":
+		return s.handleAgentConfigAck(req)
+	case "log_batch":
+		return s.handleLogBatch(req)
+	case "desired_report":
+		return s.handleDesiredReport(req)
 	default:
 		return &pb.PublishResponse{
 			Reporter: "server",
@@ -51,14 +59,31 @@ func (s *DomclusterServer) handleRegister(req *pb.PublishRequest) *pb.PublishRes
 		return errorResponse(req.ReqId, "invalid version field")
 	}
 
+	labels := make(map[string]string)
+	if rawLabels, ok := data["labels"].(map[string]interface{}); ok {
+		for k, v := range rawLabels {
+			if str, ok := v.(string); ok {
+				labels[k] = str
+			}
+		}
+	}
+
 	s.nodeManager.AddNode(req.Issuer, &NodeInfo{
 		Name:    name,
 		Role:    "worker", // 默认角色，后续可由 ctl 分配
 		Version: version,
+		Labels:  labels,
 	})
 
 	zap.L().Sugar().Infof("Node registered: %s (%s)", name, req.Issuer)
 
+	// 注册完成后下发生效配置，发布流在收到注册请求时已建立
+	go func() {
+		if err := s.PushAgentConfig(req.Issuer); err != nil {
+			zap.L().Sugar().Warnf("Failed to push agent config to %s: %v", req.Issuer, err)
+		}
+	}()
+
 	return successResponse(req.ReqId, map[string]interface{}{
 		"message": "registered",
 	})
@@ -105,3 +130,12 @@ func (s *DomclusterServer) handleStatusUpdate(req *pb.PublishRequest) *pb.Publis
 	// 使用监控服务处理状态更新
 	return monitor.HandleStatusUpdate(s.monitor.GetCollector(), req)
 }
+
+// handleStatusDelta 处理状态增量请求
+func (s *DomclusterServer) handleStatusDelta(req *pb.PublishRequest) *pb.PublishResponse {
+	if _, ok := s.nodeManager.GetNode(req.Issuer); !ok {
+		return errorResponse(req.ReqId, "node not registered")
+	}
+
+	return monitor.HandleStatusDelta(s.monitor.GetCollector(), req)
+}
@@ -0,0 +1,600 @@
+package services
+
+import (
+	"archive/tar"
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"d8rctl/config"
+
+	"github.com/docker/docker/client"
+	"go.uber.org/zap"
+)
+
+const (
+	// imageArchiveRetention 镜像包在控制端保留的时间，超时且未被分发任务使用时删除
+	imageArchiveRetention = 7 * 24 * time.Hour
+	// imageDistRetention 已结束的分发任务在内存中保留的时间
+	imageDistRetention = 24 * time.Hour
+	// defaultImageDistConcurrency 默认同时向多少个节点传输
+	defaultImageDistConcurrency = 4
+	// maxImageDistConcurrency 同时传输的节点数上限
+	maxImageDistConcurrency = 32
+)
+
+// 分发任务中节点的状态
+const (
+	ImageDistPending   = "pending"
+	ImageDistChecking  = "checking"
+	ImageDistSkipped   = "skipped"
+	ImageDistUploading = "uploading"
+	ImageDistLoading   = "loading"
+	ImageDistDone      = "done"
+	ImageDistFailed    = "failed"
+	ImageDistCanceled  = "canceled"
+)
+
+// ArchiveImage 镜像包中的一个镜像
+type ArchiveImage struct {
+	// ID 镜像配置的摘要，即 Docker 的镜像 ID
+	ID       string   `json:"id"`
+	RepoTags []string `json:"repo_tags"`
+}
+
+// ImageArchive 控制端暂存的 docker save 格式镜像包，以内容的 SHA-256 标识
+type ImageArchive struct {
+	ID      string         `json:"id"`
+	Size    int64          `json:"size"`
+	Images  []ArchiveImage `json:"images"`
+	Created time.Time      `json:"created"`
+}
+
+// NodeImageDistribution 单个节点的分发进度
+type NodeImageDistribution struct {
+	NodeID string `json:"node_id"`
+	State  string `json:"state"`
+	// Sent 节点已确认接收的字节数，续传时从节点已有的位置开始
+	Sent       int64      `json:"sent"`
+	Total      int64      `json:"total"`
+	Loaded     []string   `json:"loaded,omitempty"`
+	Error      string     `json:"error,omitempty"`
+	StartedAt  *time.Time `json:"started_at,omitempty"`
+	FinishedAt *time.Time `json:"finished_at,omitempty"`
+}
+
+// ImageDistribution 镜像分发任务
+type ImageDistribution struct {
+	ID          string                  `json:"id"`
+	Archive     ImageArchive            `json:"archive"`
+	Concurrency int                     `json:"concurrency"`
+	Force       bool                    `json:"force"`
+	Done        bool                    `json:"done"`
+	Created     time.Time               `json:"created"`
+	Finished    *time.Time              `json:"finished,omitempty"`
+	Nodes       []NodeImageDistribution `json:"nodes"`
+}
+
+// imageDistribution 正在运行或已结束的分发任务，状态由 mu 保护
+type imageDistribution struct {
+	mu     sync.Mutex
+	status ImageDistribution
+	cancel context.CancelFunc
+}
+
+// snapshot 返回任务状态的副本
+func (d *imageDistribution) snapshot() ImageDistribution {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	status := d.status
+	status.Nodes = slices.Clone(d.status.Nodes)
+	return status
+}
+
+// update 修改第 i 个节点的状态
+func (d *imageDistribution) update(i int, fn func(n *NodeImageDistribution)) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	fn(&d.status.Nodes[i])
+}
+
+// imageArchiveDir 镜像包的暂存目录
+func imageArchiveDir() string {
+	return filepath.Join(config.GetDataDir(), "images")
+}
+
+// ImportImageArchive 保存 docker save 格式的镜像包，内容相同的镜像包只保存一份
+//
+// maxSize 为 0 时不限制大小。
+func (s *DomclusterServer) ImportImageArchive(r io.Reader, maxSize int64) (*ImageArchive, error) {
+	dir := imageArchiveDir()
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, fmt.Errorf("failed to create image archive dir: %w", err)
+	}
+	s.cleanupImageArchives()
+
+	tmp, err := os.CreateTemp(dir, "import-*")
+	if err != nil {
+		return nil, err
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+
+	if maxSize > 0 {
+		r = io.LimitReader(r, maxSize+1)
+	}
+	hash := sha256.New()
+	size, err := io.Copy(io.MultiWriter(tmp, hash), r)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read image archive: %w", err)
+	}
+	if maxSize > 0 && size > maxSize {
+		return nil, fmt.Errorf("image archive exceeds limit of %d bytes", maxSize)
+	}
+	if _, err := tmp.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+	images, err := readArchiveManifest(tmp)
+	if err != nil {
+		return nil, err
+	}
+	if err := tmp.Close(); err != nil {
+		return nil, err
+	}
+
+	id := hex.EncodeToString(hash.Sum(nil))
+	if err := os.Rename(tmp.Name(), filepath.Join(dir, id+".tar")); err != nil {
+		return nil, fmt.Errorf("failed to store image archive: %w", err)
+	}
+	zap.L().Sugar().Infof("Stored image archive %s (%d bytes, %d images)", id, size, len(images))
+	return &ImageArchive{ID: id, Size: size, Images: images, Created: time.Now()}, nil
+}
+
+// SaveLocalImages 从控制端本机的 Docker 导出镜像并保存为镜像包
+func (s *DomclusterServer) SaveLocalImages(ctx context.Context, refs []string) (*ImageArchive, error) {
+	cli, err := client.NewClientWithOpts(client.FromEnv, client.WithAPIVersionNegotiation())
+	if err != nil {
+		return nil, fmt.Errorf("failed to create docker client: %w", err)
+	}
+	defer cli.Close()
+
+	rc, err := cli.ImageSave(ctx, refs)
+	if err != nil {
+		return nil, fmt.Errorf("failed to save images: %w", err)
+	}
+	defer rc.Close()
+
+	return s.ImportImageArchive(rc, 0)
+}
+
+// GetImageArchive 查询镜像包
+func (s *DomclusterServer) GetImageArchive(id string) (*ImageArchive, error) {
+	if !validArchiveID(id) {
+		return nil, fmt.Errorf("invalid image archive id %q", id)
+	}
+	f, err := os.Open(filepath.Join(imageArchiveDir(), id+".tar"))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, fmt.Errorf("image archive %s not found", id)
+		}
+		return nil, err
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+	images, err := readArchiveManifest(f)
+	if err != nil {
+		return nil, err
+	}
+	return &ImageArchive{ID: id, Size: info.Size(), Images: images, Created: info.ModTime()}, nil
+}
+
+// ListImageArchives 列出控制端暂存的镜像包，按时间从新到旧排序
+func (s *DomclusterServer) ListImageArchives() []ImageArchive {
+	entries, err := os.ReadDir(imageArchiveDir())
+	if err != nil {
+		return []ImageArchive{}
+	}
+
+	archives := []ImageArchive{}
+	for _, entry := range entries {
+		id, ok := strings.CutSuffix(entry.Name(), ".tar")
+		if !ok || !validArchiveID(id) {
+			continue
+		}
+		archive, err := s.GetImageArchive(id)
+		if err != nil {
+			zap.L().Sugar().Warnf("Skipping image archive %s: %v", id, err)
+			continue
+		}
+		archives = append(archives, *archive)
+	}
+	sort.Slice(archives, func(i, j int) bool { return archives[i].Created.After(archives[j].Created) })
+	return archives
+}
+
+// DeleteImageArchive 删除镜像包，正在分发的镜像包不能删除
+func (s *DomclusterServer) DeleteImageArchive(id string) error {
+	if !validArchiveID(id) {
+		return fmt.Errorf("invalid image archive id %q", id)
+	}
+	if s.archiveInUse()[id] {
+		return fmt.Errorf("image archive %s is being distributed", id)
+	}
+	if err := os.Remove(filepath.Join(imageArchiveDir(), id+".tar")); err != nil {
+		if os.IsNotExist(err) {
+			return fmt.Errorf("image archive %s not found", id)
+		}
+		return err
+	}
+	return nil
+}
+
+// StartImageDistribution 开始将镜像包分发到节点，立即返回任务，进度通过 GetImageDistribution 查询
+//
+// 节点上已有镜像包中全部镜像（镜像 ID 相同）时跳过传输，只补上缺少的标签；force 为 true 时总是传输。
+// 传输 ID 由节点和镜像包决定，任务失败后重新分发同一镜像包时从节点已接收的位置继续。
+func (s *DomclusterServer) StartImageDistribution(archiveID string, nodeIDs []string, concurrency int, force bool) (*ImageDistribution, error) {
+	archive, err := s.GetImageArchive(archiveID)
+	if err != nil {
+		return nil, err
+	}
+	if len(nodeIDs) == 0 {
+		return nil, fmt.Errorf("no target nodes")
+	}
+	if concurrency <= 0 {
+		concurrency = defaultImageDistConcurrency
+	}
+	concurrency = min(concurrency, maxImageDistConcurrency)
+
+	f, err := os.Open(filepath.Join(imageArchiveDir(), archive.ID+".tar"))
+	if err != nil {
+		return nil, err
+	}
+
+	id := make([]byte, 8)
+	if _, err := rand.Read(id); err != nil {
+		f.Close()
+		return nil, err
+	}
+
+	s.imageDistsMu.Lock()
+	s.cleanupImageDistributions()
+	// 同一镜像包同时向同一节点传输会使用相同的传输 ID，互相干扰
+	for _, existing := range s.imageDists {
+		st := existing.snapshot()
+		if st.Done || st.Archive.ID != archive.ID {
+			continue
+		}
+		for _, n := range st.Nodes {
+			if slices.Contains(nodeIDs, n.NodeID) {
+				s.imageDistsMu.Unlock()
+				f.Close()
+				return nil, fmt.Errorf("image archive %s is already being distributed to %s by %s", archive.ID[:12], n.NodeID, st.ID)
+			}
+		}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	d := &imageDistribution{
+		status: ImageDistribution{
+			ID:          hex.EncodeToString(id),
+			Archive:     *archive,
+			Concurrency: concurrency,
+			Force:       force,
+			Created:     time.Now(),
+		},
+		cancel: cancel,
+	}
+	for _, nodeID := range nodeIDs {
+		d.status.Nodes = append(d.status.Nodes, NodeImageDistribution{
+			NodeID: nodeID,
+			State:  ImageDistPending,
+			Total:  archive.Size,
+		})
+	}
+	s.imageDists[d.status.ID] = d
+	s.imageDistsMu.Unlock()
+
+	zap.L().Sugar().Infof("Distributing image archive %s to %d nodes (distribution %s)", archive.ID, len(nodeIDs), d.status.ID)
+	go s.runImageDistribution(ctx, d, f)
+
+	st := d.snapshot()
+	return &st, nil
+}
+
+// GetImageDistribution 查询分发任务
+func (s *DomclusterServer) GetImageDistribution(id string) (*ImageDistribution, bool) {
+	s.imageDistsMu.Lock()
+	d, ok := s.imageDists[id]
+	s.imageDistsMu.Unlock()
+	if !ok {
+		return nil, false
+	}
+	st := d.snapshot()
+	return &st, true
+}
+
+// ListImageDistributions 列出分发任务，按创建时间从新到旧排序
+func (s *DomclusterServer) ListImageDistributions() []ImageDistribution {
+	s.imageDistsMu.Lock()
+	result := make([]ImageDistribution, 0, len(s.imageDists))
+	for _, d := range s.imageDists {
+		result = append(result, d.snapshot())
+	}
+	s.imageDistsMu.Unlock()
+
+	sort.Slice(result, func(i, j int) bool { return result[i].Created.After(result[j].Created) })
+	return result
+}
+
+// CancelImageDistribution 取消分发任务，已接收的部分保留在节点上，重新分发时继续
+func (s *DomclusterServer) CancelImageDistribution(id string) bool {
+	s.imageDistsMu.Lock()
+	d, ok := s.imageDists[id]
+	s.imageDistsMu.Unlock()
+	if ok {
+		d.cancel()
+	}
+	return ok
+}
+
+// runImageDistribution 按并发上限依次向各节点分发
+func (s *DomclusterServer) runImageDistribution(ctx context.Context, d *imageDistribution, f *os.File) {
+	defer f.Close()
+	defer d.cancel()
+
+	st := d.snapshot()
+	sem := make(chan struct{}, st.Concurrency)
+	var wg sync.WaitGroup
+	for i := range st.Nodes {
+		select {
+		case sem <- struct{}{}:
+		case <-ctx.Done():
+		}
+		if ctx.Err() != nil {
+			break
+		}
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			s.distributeToNode(ctx, d, i, f, &st.Archive)
+		}(i)
+	}
+	wg.Wait()
+
+	d.mu.Lock()
+	now := time.Now()
+	failed := 0
+	for i := range d.status.Nodes {
+		n := &d.status.Nodes[i]
+		if n.State == ImageDistPending {
+			n.State = ImageDistCanceled
+		}
+		if n.State == ImageDistFailed || n.State == ImageDistCanceled {
+			failed++
+		}
+	}
+	d.status.Done = true
+	d.status.Finished = &now
+	d.mu.Unlock()
+
+	zap.L().Sugar().Infof("Image distribution %s finished, %d of %d nodes failed", st.ID, failed, len(st.Nodes))
+}
+
+// distributeToNode 检查节点上是否已有镜像，没有时传输镜像包并导入
+func (s *DomclusterServer) distributeToNode(ctx context.Context, d *imageDistribution, i int, f *os.File, archive *ImageArchive) {
+	st := d.snapshot()
+	nodeID := st.Nodes[i].NodeID
+	started := time.Now()
+	d.update(i, func(n *NodeImageDistribution) {
+		n.State = ImageDistChecking
+		n.StartedAt = &started
+	})
+
+	finish := func(state string, err error) {
+		now := time.Now()
+		d.update(i, func(n *NodeImageDistribution) {
+			n.State = state
+			n.FinishedAt = &now
+			if err != nil {
+				n.Error = err.Error()
+			}
+		})
+		if err != nil {
+			zap.L().Sugar().Warnf("Image distribution to %s failed: %v", nodeID, err)
+		}
+	}
+
+	if !st.Force {
+		present, err := s.archiveOnNode(ctx, nodeID, archive)
+		if err != nil {
+			finish(distFailureState(ctx), err)
+			return
+		}
+		if present {
+			finish(ImageDistSkipped, nil)
+			return
+		}
+	}
+
+	d.update(i, func(n *NodeImageDistribution) { n.State = ImageDistUploading })
+	idHash := sha256.Sum256([]byte(fmt.Sprintf("%s|image|%s", nodeID, archive.ID)))
+	begin := map[string]interface{}{
+		"transfer_id": hex.EncodeToString(idHash[:16]),
+		"size":        archive.Size,
+		"sha256":      archive.ID,
+		"load_image":  true,
+	}
+	desc := fmt.Sprintf("%s (image archive %s)", nodeID, archive.ID[:12])
+	result, err := s.uploadChunks(ctx, nodeID, desc, begin, f, archive.Size, func(offset int64) {
+		d.update(i, func(n *NodeImageDistribution) {
+			n.Sent = offset
+			if offset >= archive.Size {
+				n.State = ImageDistLoading
+			}
+		})
+	})
+	if err != nil {
+		finish(distFailureState(ctx), err)
+		return
+	}
+
+	var resp struct {
+		Loaded []string `json:"loaded"`
+	}
+	if err := json.Unmarshal(result, &resp); err != nil {
+		finish(ImageDistFailed, fmt.Errorf("failed to unmarshal load result: %w", err))
+		return
+	}
+	d.update(i, func(n *NodeImageDistribution) { n.Loaded = resp.Loaded })
+	finish(ImageDistDone, nil)
+	zap.L().Sugar().Infof("Loaded image archive %s on %s: %v", archive.ID[:12], nodeID, resp.Loaded)
+}
+
+// archiveOnNode 检查节点上是否已有镜像包中的全部镜像，镜像都在但缺少标签时补上标签
+func (s *DomclusterServer) archiveOnNode(ctx context.Context, nodeID string, archive *ImageArchive) (bool, error) {
+	for _, img := range archive.Images {
+		info, err := s.InspectNodeImage(ctx, nodeID, img.ID)
+		if err != nil {
+			if strings.Contains(strings.ToLower(err.Error()), "no such image") {
+				return false, nil
+			}
+			return false, err
+		}
+		for _, tag := range img.RepoTags {
+			if slices.Contains(info.RepoTags, tag) {
+				continue
+			}
+			if _, err := s.TagNodeImage(ctx, nodeID, img.ID, tag); err != nil {
+				return false, err
+			}
+		}
+	}
+	return true, nil
+}
+
+// distFailureState 任务被取消时节点记为 canceled，否则为 failed
+func distFailureState(ctx context.Context) string {
+	if ctx.Err() != nil {
+		return ImageDistCanceled
+	}
+	return ImageDistFailed
+}
+
+// archiveInUse 返回正在分发的镜像包
+func (s *DomclusterServer) archiveInUse() map[string]bool {
+	s.imageDistsMu.Lock()
+	defer s.imageDistsMu.Unlock()
+
+	inUse := make(map[string]bool)
+	for _, d := range s.imageDists {
+		if st := d.snapshot(); !st.Done {
+			inUse[st.Archive.ID] = true
+		}
+	}
+	return inUse
+}
+
+// cleanupImageDistributions 删除结束超过 imageDistRetention 的任务，调用方持有 imageDistsMu
+func (s *DomclusterServer) cleanupImageDistributions() {
+	cutoff := time.Now().Add(-imageDistRetention)
+	for id, d := range s.imageDists {
+		if st := d.snapshot(); st.Done && st.Finished.Before(cutoff) {
+			delete(s.imageDists, id)
+		}
+	}
+}
+
+// cleanupImageArchives 删除过期且未在分发的镜像包以及中断的导入
+func (s *DomclusterServer) cleanupImageArchives() {
+	entries, err := os.ReadDir(imageArchiveDir())
+	if err != nil {
+		return
+	}
+
+	inUse := s.archiveInUse()
+	cutoff := time.Now().Add(-imageArchiveRetention)
+	for _, entry := range entries {
+		info, err := entry.Info()
+		if err != nil || info.ModTime().After(cutoff) {
+			continue
+		}
+		if id, ok := strings.CutSuffix(entry.Name(), ".tar"); ok && inUse[id] {
+			continue
+		}
+		p := filepath.Join(imageArchiveDir(), entry.Name())
+		if err := os.Remove(p); err == nil {
+			zap.L().Sugar().Infof("Removed stale image archive %s", p)
+		}
+	}
+}
+
+// readArchiveManifest 读取 docker save 镜像包中的 manifest.json
+func readArchiveManifest(r io.Reader) ([]ArchiveImage, error) {
+	tr := tar.NewReader(r)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return nil, fmt.Errorf("not a docker image archive: manifest.json not found")
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid image archive: %w", err)
+		}
+		if path.Clean(hdr.Name) != "manifest.json" {
+			continue
+		}
+
+		var manifest []struct {
+			Config   string   `json:"Config"`
+			RepoTags []string `json:"RepoTags"`
+		}
+		if err := json.NewDecoder(tr).Decode(&manifest); err != nil {
+			return nil, fmt.Errorf("invalid manifest.json: %w", err)
+		}
+		if len(manifest) == 0 {
+			return nil, fmt.Errorf("image archive contains no images")
+		}
+
+		images := make([]ArchiveImage, 0, len(manifest))
+		for _, m := range manifest {
+			// 旧格式为 <hex>.json，OCI 布局为 blobs/sha256/<hex>
+			digest := strings.TrimSuffix(path.Base(m.Config), ".json")
+			if !validArchiveID(digest) {
+				return nil, fmt.Errorf("invalid image config %q in manifest.json", m.Config)
+			}
+			tags := m.RepoTags
+			if tags == nil {
+				tags = []string{}
+			}
+			images = append(images, ArchiveImage{ID: "sha256:" + digest, RepoTags: tags})
+		}
+		return images, nil
+	}
+}
+
+// validArchiveID 镜像包 ID 和镜像摘要都是 64 位十六进制
+func validArchiveID(id string) bool {
+	if len(id) != sha256.Size*2 {
+		return false
+	}
+	_, err := hex.DecodeString(id)
+	return err == nil && strings.ToLower(id) == id
+}
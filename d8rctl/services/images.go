@@ -0,0 +1,324 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// imagePullTimeout 单个节点拉取镜像的超时时间
+const imagePullTimeout = 30 * time.Minute
+
+// ImageInfo 节点上的镜像
+type ImageInfo struct {
+	ID          string   `json:"id"`
+	RepoTags    []string `json:"repo_tags"`
+	RepoDigests []string `json:"repo_digests"`
+	Size        int64    `json:"size"`
+	Created     int64    `json:"created"`
+	Containers  int64    `json:"containers"`
+}
+
+// ImageVariant 同一镜像引用在不同节点上对应的一个具体镜像
+type ImageVariant struct {
+	ID          string   `json:"id"`
+	RepoDigests []string `json:"repo_digests"`
+	Nodes       []string `json:"nodes"`
+}
+
+// ClusterImage 集群中一个镜像引用（仓库:标签）的分布情况
+type ClusterImage struct {
+	Ref      string         `json:"ref"`
+	Variants []ImageVariant `json:"variants"`
+	// Consistent 所有拥有该引用的节点上镜像 ID 相同
+	Consistent bool `json:"consistent"`
+}
+
+// ClusterImages 集群镜像视图
+type ClusterImages struct {
+	Nodes  []string          `json:"nodes"`
+	Images []ClusterImage    `json:"images"`
+	Errors map[string]string `json:"errors"`
+}
+
+// ImageConsistency 指定镜像在一组节点上的一致性检查结果
+type ImageConsistency struct {
+	Image string `json:"image"`
+	// Consistent 所有节点都有该镜像且镜像 ID 相同
+	Consistent bool           `json:"consistent"`
+	Variants   []ImageVariant `json:"variants"`
+	// Missing 没有该镜像的节点
+	Missing []string          `json:"missing"`
+	Errors  map[string]string `json:"errors"`
+}
+
+// ImagePullResult 单个节点的拉取结果
+type ImagePullResult struct {
+	NodeID      string   `json:"node_id"`
+	ID          string   `json:"id,omitempty"`
+	RepoDigests []string `json:"repo_digests,omitempty"`
+	Error       string   `json:"error,omitempty"`
+}
+
+// ListNodeImages 列出节点上的镜像
+func (s *DomclusterServer) ListNodeImages(ctx context.Context, nodeID string, all bool) ([]ImageInfo, error) {
+	result, err := s.QueryNode(ctx, nodeID, "docker_image_list", map[string]interface{}{"all": all})
+	if err != nil {
+		return nil, err
+	}
+
+	var resp struct {
+		Images []ImageInfo `json:"images"`
+	}
+	if err := json.Unmarshal(result, &resp); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal images: %w", err)
+	}
+	return resp.Images, nil
+}
+
+// InspectNodeImage 查询节点上的镜像，镜像不存在时返回错误
+func (s *DomclusterServer) InspectNodeImage(ctx context.Context, nodeID, ref string) (*ImageInfo, error) {
+	result, err := s.QueryNode(ctx, nodeID, "docker_image_inspect", map[string]interface{}{"image": ref})
+	if err != nil {
+		return nil, err
+	}
+
+	var info ImageInfo
+	if err := json.Unmarshal(result, &info); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal image: %w", err)
+	}
+	return &info, nil
+}
+
+// TagNodeImage 在节点上为镜像添加标签
+func (s *DomclusterServer) TagNodeImage(ctx context.Context, nodeID, source, target string) (map[string]interface{}, error) {
+	return s.queryNodeMap(ctx, nodeID, "docker_image_tag", map[string]interface{}{
+		"source": source,
+		"target": target,
+	})
+}
+
+// RemoveNodeImage 删除节点上的镜像
+func (s *DomclusterServer) RemoveNodeImage(ctx context.Context, nodeID, ref string, force bool) (map[string]interface{}, error) {
+	return s.queryNodeMap(ctx, nodeID, "docker_image_remove", map[string]interface{}{
+		"image": ref,
+		"force": force,
+	})
+}
+
+// PruneNodeImages 清理节点上的镜像，all 为 true 时清理所有未被容器使用的镜像
+func (s *DomclusterServer) PruneNodeImages(ctx context.Context, nodeID string, all bool) (map[string]interface{}, error) {
+	return s.queryNodeMap(ctx, nodeID, "docker_image_prune", map[string]interface{}{"all": all})
+}
+
+// queryNodeMap 查询节点并将结果解析为 map
+func (s *DomclusterServer) queryNodeMap(ctx context.Context, nodeID, cmd string, data map[string]interface{}) (map[string]interface{}, error) {
+	result, err := s.QueryNode(ctx, nodeID, cmd, data)
+	if err != nil {
+		return nil, err
+	}
+
+	var resp map[string]interface{}
+	if err := json.Unmarshal(result, &resp); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal response: %w", err)
+	}
+	return resp, nil
+}
+
+// GetClusterImages 汇总各节点的镜像，按镜像引用列出每个节点上的镜像 ID
+func (s *DomclusterServer) GetClusterImages(ctx context.Context, nodeIDs []string) *ClusterImages {
+	type nodeResult struct {
+		images []ImageInfo
+		err    error
+	}
+	results := make(map[string]nodeResult, len(nodeIDs))
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for _, nodeID := range nodeIDs {
+		wg.Add(1)
+		go func(nodeID string) {
+			defer wg.Done()
+			images, err := s.ListNodeImages(ctx, nodeID, false)
+			mu.Lock()
+			results[nodeID] = nodeResult{images: images, err: err}
+			mu.Unlock()
+		}(nodeID)
+	}
+	wg.Wait()
+
+	view := &ClusterImages{Nodes: nodeIDs, Images: []ClusterImage{}, Errors: map[string]string{}}
+	byRef := make(map[string]map[string]*ImageVariant)
+	for _, nodeID := range nodeIDs {
+		res := results[nodeID]
+		if res.err != nil {
+			view.Errors[nodeID] = res.err.Error()
+			continue
+		}
+		for _, img := range res.images {
+			for _, ref := range img.RepoTags {
+				if ref == "<none>:<none>" {
+					continue
+				}
+				variants, ok := byRef[ref]
+				if !ok {
+					variants = make(map[string]*ImageVariant)
+					byRef[ref] = variants
+				}
+				addVariant(variants, img.ID, img.RepoDigests, nodeID)
+			}
+		}
+	}
+
+	for ref, variants := range byRef {
+		view.Images = append(view.Images, ClusterImage{
+			Ref:        ref,
+			Variants:   sortVariants(variants),
+			Consistent: len(variants) == 1,
+		})
+	}
+	sort.Slice(view.Images, func(i, j int) bool { return view.Images[i].Ref < view.Images[j].Ref })
+	return view
+}
+
+// CheckImageConsistency 检查一组节点上指定镜像的镜像 ID 是否一致
+func (s *DomclusterServer) CheckImageConsistency(ctx context.Context, ref string, nodeIDs []string) *ImageConsistency {
+	type nodeResult struct {
+		info *ImageInfo
+		err  error
+	}
+	results := make(map[string]nodeResult, len(nodeIDs))
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for _, nodeID := range nodeIDs {
+		wg.Add(1)
+		go func(nodeID string) {
+			defer wg.Done()
+			info, err := s.InspectNodeImage(ctx, nodeID, ref)
+			mu.Lock()
+			results[nodeID] = nodeResult{info: info, err: err}
+			mu.Unlock()
+		}(nodeID)
+	}
+	wg.Wait()
+
+	check := &ImageConsistency{Image: ref, Missing: []string{}, Errors: map[string]string{}}
+	variants := make(map[string]*ImageVariant)
+	for _, nodeID := range nodeIDs {
+		res := results[nodeID]
+		switch {
+		case res.err != nil && strings.Contains(strings.ToLower(res.err.Error()), "no such image"):
+			check.Missing = append(check.Missing, nodeID)
+		case res.err != nil:
+			check.Errors[nodeID] = res.err.Error()
+		default:
+			addVariant(variants, res.info.ID, res.info.RepoDigests, nodeID)
+		}
+	}
+	check.Variants = sortVariants(variants)
+	check.Consistent = len(variants) == 1 && len(check.Missing) == 0 && len(check.Errors) == 0
+	return check
+}
+
+// PullImageOnNodes 在多个节点上并行拉取镜像，等待全部完成后返回每个节点的结果
+func (s *DomclusterServer) PullImageOnNodes(ctx context.Context, ref, registryAuth string, nodeIDs []string) []ImagePullResult {
+	results := make([]ImagePullResult, len(nodeIDs))
+	var wg sync.WaitGroup
+	for i, nodeID := range nodeIDs {
+		wg.Add(1)
+		go func(i int, nodeID string) {
+			defer wg.Done()
+			results[i] = s.pullImage(ctx, nodeID, ref, registryAuth)
+		}(i, nodeID)
+	}
+	wg.Wait()
+	return results
+}
+
+// pullImage 在单个节点上拉取镜像，忽略中间进度，只等待最终结果
+func (s *DomclusterServer) pullImage(ctx context.Context, nodeID, ref, registryAuth string) ImagePullResult {
+	result := ImagePullResult{NodeID: nodeID}
+
+	data := map[string]interface{}{"image": ref}
+	if registryAuth != "" {
+		data["registry_auth"] = registryAuth
+	}
+	st, err := s.OpenStream(nodeID, "docker_image_pull", data)
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+	defer st.Close()
+
+	timeout := time.NewTimer(imagePullTimeout)
+	defer timeout.Stop()
+
+	for {
+		select {
+		case raw, ok := <-st.C:
+			if !ok {
+				if result.ID == "" && result.Error == "" {
+					result.Error = "node disconnected during pull"
+				}
+				return result
+			}
+			var msg struct {
+				Type        string   `json:"type"`
+				ID          string   `json:"id"`
+				RepoDigests []string `json:"repo_digests"`
+				Error       string   `json:"error"`
+			}
+			if err := json.Unmarshal(raw, &msg); err != nil {
+				continue
+			}
+			switch msg.Type {
+			case "pulled":
+				result.ID = msg.ID
+				result.RepoDigests = msg.RepoDigests
+			case "end":
+				result.Error = msg.Error
+				if result.Error == "" {
+					zap.L().Sugar().Infof("Pulled %s on node %s (%s)", ref, nodeID, result.ID)
+				}
+				return result
+			}
+		case <-timeout.C:
+			result.Error = "pull timed out"
+			return result
+		case <-ctx.Done():
+			result.Error = ctx.Err().Error()
+			return result
+		}
+	}
+}
+
+// addVariant 将节点记入对应镜像 ID 的分组
+func addVariant(variants map[string]*ImageVariant, id string, digests []string, nodeID string) {
+	v, ok := variants[id]
+	if !ok {
+		v = &ImageVariant{ID: id, RepoDigests: digests}
+		variants[id] = v
+	}
+	v.Nodes = append(v.Nodes, nodeID)
+}
+
+// sortVariants 按节点数从多到少排序，数量最多的通常是期望的版本
+func sortVariants(variants map[string]*ImageVariant) []ImageVariant {
+	result := make([]ImageVariant, 0, len(variants))
+	for _, v := range variants {
+		sort.Strings(v.Nodes)
+		result = append(result, *v)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if len(result[i].Nodes) != len(result[j].Nodes) {
+			return len(result[i].Nodes) > len(result[j].Nodes)
+		}
+		return result[i].ID < result[j].ID
+	})
+	return result
+}
@@ -0,0 +1,127 @@
+package services
+
+import (
+	"fmt"
+	"time"
+
+	"d8rctl/services/monitor"
+
+	"go.uber.org/zap"
+)
+
+// NodeTopology 节点的 CPU 拓扑
+type NodeTopology struct {
+	NodeID         string                 `json:"node_id"`
+	NumCPU         int                    `json:"num_cpu"`
+	ThreadsPerCore int                    `json:"threads_per_core"`
+	Cores          []monitor.PhysicalCore `json:"cores"`
+}
+
+// GetNodeTopology 返回节点上报的 CPU 拓扑
+func (s *DomclusterServer) GetNodeTopology(nodeID string) (*NodeTopology, error) {
+	status, ok := s.monitor.GetCollector().GetStatus(nodeID)
+	if !ok || status.Host == nil {
+		return nil, fmt.Errorf("node %s not found", nodeID)
+	}
+	if len(status.Host.Cores) == 0 {
+		return nil, fmt.Errorf("CPU topology of node %s is unknown", nodeID)
+	}
+	return &NodeTopology{
+		NodeID:         nodeID,
+		NumCPU:         status.Host.NumCPU,
+		ThreadsPerCore: status.Host.ThreadsPerCore,
+		Cores:          status.Host.Cores,
+	}, nil
+}
+
+// ProvisionJudgehosts 在节点上为选定的每个物理核心部署一个 judgehost
+//
+// cores 为逻辑 CPU 列表如 "2-7"，为空时恢复为保留 reserve_cores 后的全部核心。
+// 选择保存在栈声明中，之后的 stack up 沿用；不再选中的核心上的 judgehost 会被删除。
+func (s *DomclusterServer) ProvisionJudgehosts(name, nodeID, cores string) (*StackDeployment, error) {
+	spec, err := loadStackSpec(name)
+	if err != nil {
+		return nil, err
+	}
+	if !spec.Judgehosts.PerCore {
+		return nil, fmt.Errorf("stack %s must set judgehosts.per_core to provision by core", name)
+	}
+	if !s.IsNodeConnected(nodeID) {
+		return nil, fmt.Errorf("node %s is not connected", nodeID)
+	}
+
+	isJudgehost := false
+	for _, node := range s.judgehostNodes(spec) {
+		if node == nodeID {
+			isJudgehost = true
+			break
+		}
+	}
+	if !isJudgehost {
+		return nil, fmt.Errorf("node %s is not a judgehost node of stack %s", nodeID, name)
+	}
+
+	if cores == "" {
+		delete(spec.Judgehosts.Cores, nodeID)
+	} else {
+		if spec.Judgehosts.Cores == nil {
+			spec.Judgehosts.Cores = make(map[string]string)
+		}
+		spec.Judgehosts.Cores[nodeID] = cores
+	}
+	if err := spec.Validate(); err != nil {
+		return nil, err
+	}
+	slots, errs := s.judgehostSlots(spec, []string{nodeID})
+	if err := errs[nodeID]; err != nil {
+		return nil, err
+	}
+
+	op, err := s.beginStackOperation(name, "provision")
+	if err != nil {
+		return nil, err
+	}
+	if err := saveStackSpec(spec); err != nil {
+		op.finish(err)
+		return nil, fmt.Errorf("failed to save stack: %w", err)
+	}
+
+	go func() {
+		err := s.provisionNode(spec, nodeID, spec.containers(slots), op)
+		if err != nil {
+			zap.L().Sugar().Errorf("Provisioning judgehosts of stack %s on %s failed: %v", name, nodeID, err)
+		} else {
+			zap.L().Sugar().Infof("Provisioned %d judgehosts of stack %s on %s", len(slots), name, nodeID)
+			// 核心分配变化后节点上的 judgehost 容器也随之变化，更新已同步的期望状态
+			s.refreshStackDesired(name)
+		}
+		op.finish(err)
+	}()
+	return op.snapshot(), nil
+}
+
+// provisionNode 部署节点上的 judgehost 并删除不再需要的容器
+func (s *DomclusterServer) provisionNode(spec *StackSpec, nodeID string, containers []stackContainer, op *stackOperation) error {
+	healthTimeout := time.Duration(spec.HealthTimeout) * time.Second
+
+	// 节点上的数据库和 domserver 容器保持不变，只部署 judgehost
+	var desired []stackContainer
+	failed := 0
+	for _, sc := range containers {
+		if sc.NodeID != nodeID {
+			continue
+		}
+		desired = append(desired, sc)
+		if sc.Component != StackComponentJudgehost {
+			continue
+		}
+		if err := s.deployStackContainer(sc, healthTimeout, op); err != nil {
+			failed++
+		}
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d judgehost containers failed", failed)
+	}
+
+	return s.pruneStack(spec.Name, desired, nodeID, op)
+}
@@ -0,0 +1,67 @@
+package services
+
+import (
+	"encoding/json"
+	"time"
+
+	"d8rctl/services/logstore"
+
+	pb "domcluster/api/proto"
+	"go.uber.org/zap"
+)
+
+// logBatch 节点上报的日志批次
+type logBatch struct {
+	ID       string `json:"id"`
+	NodeID   string `json:"node_id"`
+	NodeName string `json:"node_name"`
+	Entries  []struct {
+		Timestamp   time.Time `json:"ts"`
+		Source      string    `json:"source"`
+		Container   string    `json:"container"`
+		ContainerID string    `json:"container_id"`
+		Stream      string    `json:"stream"`
+		Line        string    `json:"line"`
+	} `json:"entries"`
+}
+
+// handleLogBatch 处理日志批次，写入成功（或已写入过）后回复确认，节点收到确认才会删除本地缓存
+func (s *DomclusterServer) handleLogBatch(req *pb.PublishRequest) *pb.PublishResponse {
+	var batch logBatch
+	if err := json.Unmarshal(req.Data, &batch); err != nil {
+		return errorResponse(req.ReqId, "invalid log batch")
+	}
+	if batch.ID == "" {
+		return errorResponse(req.ReqId, "missing batch id")
+	}
+
+	nodeName := batch.NodeName
+	if node, ok := s.nodeManager.GetNode(req.Issuer); ok && nodeName == "" {
+		nodeName = node.Name
+	}
+
+	// 以连接身份为准，不信任批次中自带的节点 ID
+	entries := make([]logstore.Entry, 0, len(batch.Entries))
+	for _, e := range batch.Entries {
+		entries = append(entries, logstore.Entry{
+			Timestamp:   e.Timestamp,
+			NodeID:      req.Issuer,
+			NodeName:    nodeName,
+			Source:      e.Source,
+			Container:   e.Container,
+			ContainerID: e.ContainerID,
+			Stream:      e.Stream,
+			Line:        e.Line,
+		})
+	}
+
+	if _, err := s.logStore.Append(batch.ID, req.Issuer, entries); err != nil {
+		zap.L().Sugar().Errorf("Failed to store log batch %s from %s: %v", batch.ID, req.Issuer, err)
+		return errorResponse(req.ReqId, "failed to store logs")
+	}
+
+	return successResponse(req.ReqId, map[string]interface{}{
+		"cmd":      "log_ack",
+		"batch_id": batch.ID,
+	})
+}
@@ -0,0 +1,396 @@
+package logstore
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+const (
+	// segmentLayout 分段文件按小时切分（UTC），文件名即该小时的起始时间
+	segmentLayout = "2006010215"
+	segmentExt    = ".jsonl"
+	// recentBatches 每个节点记住的最近批次 ID 数量，用于丢弃重发的批次
+	recentBatches = 256
+	// maxLineSize 单行日志的最大长度
+	maxLineSize = 1024 * 1024
+)
+
+// Entry 一条存储的日志
+type Entry struct {
+	Timestamp   time.Time `json:"ts"`
+	NodeID      string    `json:"node_id"`
+	NodeName    string    `json:"node_name,omitempty"`
+	Source      string    `json:"source"`
+	Container   string    `json:"container,omitempty"`
+	ContainerID string    `json:"container_id,omitempty"`
+	Stream      string    `json:"stream"`
+	Line        string    `json:"line"`
+}
+
+// Query 日志搜索条件，零值字段不参与过滤
+type Query struct {
+	NodeID    string
+	Container string // 容器名称或 ID 前缀
+	Since     time.Time
+	Until     time.Time
+	Text      string // 不区分大小写的子串匹配
+	Limit     int
+}
+
+// SearchResult 搜索结果，按时间倒序排列
+type SearchResult struct {
+	Entries   []Entry `json:"entries"`
+	Truncated bool    `json:"truncated"`
+}
+
+// Store 日志存储
+//
+// 日志按节点分目录、按小时分段写入 JSON Lines 文件，保留策略按段删除：
+// 超过 maxAge 的分段以及总大小超过 maxBytes 时最旧的分段。
+type Store struct {
+	dir      string
+	maxAge   time.Duration
+	maxBytes int64
+
+	mu     sync.Mutex
+	recent map[string]*batchHistory
+
+	stop chan struct{}
+}
+
+// batchHistory 节点最近处理过的批次
+type batchHistory struct {
+	ids   map[string]struct{}
+	order []string
+}
+
+// NewStore 创建日志存储并启动保留策略清理
+func NewStore(dir string, maxAge time.Duration, maxBytes int64) *Store {
+	s := &Store{
+		dir:      dir,
+		maxAge:   maxAge,
+		maxBytes: maxBytes,
+		recent:   make(map[string]*batchHistory),
+		stop:     make(chan struct{}),
+	}
+	go s.retentionLoop()
+	return s
+}
+
+// Append 写入一个批次的日志，重复的批次直接忽略并返回 false
+func (s *Store) Append(batchID, nodeID string, entries []Entry) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	history := s.recent[nodeID]
+	if history == nil {
+		history = &batchHistory{ids: make(map[string]struct{})}
+		s.recent[nodeID] = history
+	}
+	if _, ok := history.ids[batchID]; ok {
+		return false, nil
+	}
+
+	// 按所属小时分组，每个分段只打开一次
+	segments := make(map[string][]Entry)
+	for _, e := range entries {
+		key := e.Timestamp.UTC().Format(segmentLayout)
+		segments[key] = append(segments[key], e)
+	}
+
+	nodeDir := filepath.Join(s.dir, nodeDirName(nodeID))
+	if err := os.MkdirAll(nodeDir, 0755); err != nil {
+		return false, fmt.Errorf("failed to create log dir: %w", err)
+	}
+
+	for key, segEntries := range segments {
+		if err := appendSegment(filepath.Join(nodeDir, key+segmentExt), segEntries); err != nil {
+			return false, err
+		}
+	}
+
+	history.ids[batchID] = struct{}{}
+	history.order = append(history.order, batchID)
+	if len(history.order) > recentBatches {
+		delete(history.ids, history.order[0])
+		history.order = history.order[1:]
+	}
+	return true, nil
+}
+
+// appendSegment 追加写入分段文件
+func appendSegment(path string, entries []Entry) error {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to open log segment: %w", err)
+	}
+	defer f.Close()
+
+	w := bufio.NewWriter(f)
+	enc := json.NewEncoder(w)
+	for _, e := range entries {
+		if err := enc.Encode(e); err != nil {
+			return fmt.Errorf("failed to write log segment: %w", err)
+		}
+	}
+	return w.Flush()
+}
+
+// Search 搜索日志，多个节点的结果合并后按时间倒序截取前 Limit 条
+func (s *Store) Search(q Query) (*SearchResult, error) {
+	if q.Limit <= 0 {
+		q.Limit = 500
+	}
+	text := strings.ToLower(q.Text)
+
+	var nodeDirs []string
+	if q.NodeID != "" {
+		nodeDirs = []string{nodeDirName(q.NodeID)}
+	} else {
+		entries, err := os.ReadDir(s.dir)
+		if err != nil && !os.IsNotExist(err) {
+			return nil, fmt.Errorf("failed to list log dir: %w", err)
+		}
+		for _, entry := range entries {
+			if entry.IsDir() {
+				nodeDirs = append(nodeDirs, entry.Name())
+			}
+		}
+	}
+
+	match := func(e *Entry) bool {
+		if !q.Since.IsZero() && e.Timestamp.Before(q.Since) {
+			return false
+		}
+		if !q.Until.IsZero() && e.Timestamp.After(q.Until) {
+			return false
+		}
+		if q.Container != "" && e.Container != q.Container && !strings.HasPrefix(e.ContainerID, q.Container) {
+			return false
+		}
+		if text != "" && !strings.Contains(strings.ToLower(e.Line), text) {
+			return false
+		}
+		return true
+	}
+
+	result := &SearchResult{Entries: []Entry{}}
+	for _, nodeDir := range nodeDirs {
+		matches, truncated, err := s.searchNode(filepath.Join(s.dir, nodeDir), q, match)
+		if err != nil {
+			return nil, err
+		}
+		result.Entries = append(result.Entries, matches...)
+		result.Truncated = result.Truncated || truncated
+	}
+
+	sort.SliceStable(result.Entries, func(i, j int) bool {
+		return result.Entries[i].Timestamp.After(result.Entries[j].Timestamp)
+	})
+	if len(result.Entries) > q.Limit {
+		result.Entries = result.Entries[:q.Limit]
+		result.Truncated = true
+	}
+	return result, nil
+}
+
+// searchNode 从最新的分段开始搜索单个节点的日志，凑满 Limit 条后停止
+func (s *Store) searchNode(nodeDir string, q Query, match func(*Entry) bool) ([]Entry, bool, error) {
+	segments, err := listSegments(nodeDir)
+	if err != nil {
+		return nil, false, err
+	}
+
+	var results []Entry
+	for i := len(segments) - 1; i >= 0; i-- {
+		seg := segments[i]
+		if !q.Until.IsZero() && seg.start.After(q.Until) {
+			continue
+		}
+		if !q.Since.IsZero() && !seg.start.Add(time.Hour).After(q.Since) {
+			break
+		}
+
+		matches, err := scanSegment(seg.path, match)
+		if err != nil {
+			return nil, false, err
+		}
+		// 同一分段内的日志可能来自多个来源，顺序不严格，需要排序
+		sort.SliceStable(matches, func(i, j int) bool {
+			return matches[i].Timestamp.After(matches[j].Timestamp)
+		})
+		results = append(results, matches...)
+		if len(results) >= q.Limit {
+			return results[:q.Limit], true, nil
+		}
+	}
+	return results, false, nil
+}
+
+// scanSegment 读取分段文件中符合条件的日志
+func scanSegment(path string, match func(*Entry) bool) ([]Entry, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			// 分段可能刚被保留策略删除
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to open log segment: %w", err)
+	}
+	defer f.Close()
+
+	var matches []Entry
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), maxLineSize)
+	for scanner.Scan() {
+		var e Entry
+		if err := json.Unmarshal(scanner.Bytes(), &e); err != nil {
+			continue
+		}
+		if match(&e) {
+			matches = append(matches, e)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read log segment %s: %w", path, err)
+	}
+	return matches, nil
+}
+
+// segment 一个分段文件
+type segment struct {
+	path  string
+	start time.Time
+	size  int64
+}
+
+// listSegments 列出目录中的分段，按时间升序排列
+func listSegments(dir string) ([]segment, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to list log segments: %w", err)
+	}
+
+	var segments []segment
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !strings.HasSuffix(name, segmentExt) {
+			continue
+		}
+		start, err := time.Parse(segmentLayout, strings.TrimSuffix(name, segmentExt))
+		if err != nil {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			continue
+		}
+		segments = append(segments, segment{path: filepath.Join(dir, name), start: start, size: info.Size()})
+	}
+	sort.Slice(segments, func(i, j int) bool { return segments[i].start.Before(segments[j].start) })
+	return segments, nil
+}
+
+// retentionLoop 定期执行保留策略
+func (s *Store) retentionLoop() {
+	ticker := time.NewTicker(10 * time.Minute)
+	defer ticker.Stop()
+
+	s.enforceRetention()
+	for {
+		select {
+		case <-ticker.C:
+			s.enforceRetention()
+		case <-s.stop:
+			return
+		}
+	}
+}
+
+// enforceRetention 删除过期分段，总大小仍超限时从最旧的分段开始删除
+func (s *Store) enforceRetention() {
+	// 与写入互斥，避免删除正在追加的分段
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	nodeDirs, err := os.ReadDir(s.dir)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			zap.L().Sugar().Warnf("Failed to list log dir: %v", err)
+		}
+		return
+	}
+
+	var all []segment
+	var total int64
+	cutoff := time.Now().Add(-s.maxAge)
+	for _, nodeDir := range nodeDirs {
+		if !nodeDir.IsDir() {
+			continue
+		}
+		dir := filepath.Join(s.dir, nodeDir.Name())
+		segments, err := listSegments(dir)
+		if err != nil {
+			zap.L().Sugar().Warnf("Failed to list log segments in %s: %v", dir, err)
+			continue
+		}
+		for _, seg := range segments {
+			if s.maxAge > 0 && seg.start.Add(time.Hour).Before(cutoff) {
+				if err := os.Remove(seg.path); err == nil {
+					zap.L().Sugar().Infof("Removed expired log segment %s", seg.path)
+				}
+				continue
+			}
+			all = append(all, seg)
+			total += seg.size
+		}
+		// 目录为空时一并删除，非空时 Remove 会失败，忽略即可
+		os.Remove(dir)
+	}
+
+	if s.maxBytes <= 0 || total <= s.maxBytes {
+		return
+	}
+	sort.Slice(all, func(i, j int) bool { return all[i].start.Before(all[j].start) })
+	for _, seg := range all {
+		if total <= s.maxBytes {
+			break
+		}
+		if err := os.Remove(seg.path); err == nil {
+			total -= seg.size
+			zap.L().Sugar().Infof("Removed log segment %s to stay within size limit", seg.path)
+		}
+	}
+}
+
+// Stop 停止保留策略清理
+func (s *Store) Stop() {
+	close(s.stop)
+}
+
+// nodeDirName 将节点 ID 转换为安全的目录名
+func nodeDirName(nodeID string) string {
+	name := strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '-', r == '_', r == '.':
+			return r
+		}
+		return '_'
+	}, nodeID)
+	if name == "" || name == "." || name == ".." {
+		name = "_" + name
+	}
+	return name
+}
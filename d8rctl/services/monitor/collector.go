@@ -2,97 +2,174 @@ package monitor
 
 import (
 	"encoding/json"
+	"fmt"
 	"sync"
 	"time"
 
+	"domcluster/api/statusdelta"
 	"go.uber.org/zap"
 )
 
+// statusEntry 单个节点的状态记录
+//
+// 原始文档 doc 用于应用增量，NodeStatus 仅在被读取时从 doc 解码并缓存，
+// 因此处理增量的开销只与变化的字段数量相关。
+type statusEntry struct {
+	doc        map[string]interface{}
+	seq        uint64
+	status     *NodeStatus // 解码缓存，doc 变化后置为 nil
+	lastUpdate time.Time
+	online     bool
+}
+
 // StatusCollector 状态收集器
 type StatusCollector struct {
-	mu               sync.RWMutex
-	statusMap        map[string]*NodeStatus
-	nodeTimeout      time.Duration
-	cleanupTick      time.Duration
+	mu                 sync.Mutex
+	entries            map[string]*statusEntry
+	nodeTimeout        time.Duration
+	cleanupTick        time.Duration
 	nodeRemovalTimeout time.Duration
-	stopChan         chan struct{}
-	once             sync.Once
+	stopChan           chan struct{}
+	once               sync.Once
 }
 
 // NewStatusCollector 创建状态收集器
 func NewStatusCollector(nodeTimeout, cleanupTick, nodeRemovalTimeout time.Duration) *StatusCollector {
 	c := &StatusCollector{
-		statusMap:           make(map[string]*NodeStatus),
-		nodeTimeout:         nodeTimeout,
-		cleanupTick:         cleanupTick,
-		nodeRemovalTimeout:  nodeRemovalTimeout,
-		stopChan:            make(chan struct{}),
+		entries:            make(map[string]*statusEntry),
+		nodeTimeout:        nodeTimeout,
+		cleanupTick:        cleanupTick,
+		nodeRemovalTimeout: nodeRemovalTimeout,
+		stopChan:           make(chan struct{}),
 	}
 	go c.cleanupLoop()
 	return c
 }
 
-// UpdateStatus 更新节点状态（被动接收）
+// UpdateStatus 更新节点状态（被动接收完整快照）
 func (c *StatusCollector) UpdateStatus(nodeID string, data []byte) error {
+	var doc map[string]interface{}
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return err
+	}
+
+	// 旧版本节点不携带序号，此时 seq 为 0，后续也不会发送增量
+	var seq uint64
+	if v, ok := doc["report_seq"].(float64); ok {
+		seq = uint64(v)
+	}
+	delete(doc, "report_seq")
+	statusdelta.KeyArrays(doc)
+
 	c.mu.Lock()
 	defer c.mu.Unlock()
 
-	var status NodeStatus
-	if err := json.Unmarshal(data, &status); err != nil {
+	c.entries[nodeID] = &statusEntry{
+		doc:        doc,
+		seq:        seq,
+		lastUpdate: time.Now(),
+		online:     true,
+	}
+
+	zap.L().Sugar().Infof("Status updated for node %s", nodeID)
+	return nil
+}
+
+// ApplyDelta 将节点上报的增量应用到已保存的快照
+func (c *StatusCollector) ApplyDelta(nodeID string, data []byte) error {
+	var delta StatusDelta
+	if err := json.Unmarshal(data, &delta); err != nil {
 		return err
 	}
 
-	status.NodeID = nodeID
-	status.LastUpdate = time.Now()
-	status.Online = true
+	c.mu.Lock()
+	defer c.mu.Unlock()
 
-	c.statusMap[nodeID] = &status
+	entry, ok := c.entries[nodeID]
+	if !ok || entry.seq == 0 || entry.seq != delta.BaseSeq {
+		return ErrResyncRequired
+	}
 
-	zap.L().Sugar().Infof("Status updated for node %s", nodeID)
+	applyMergePatch(entry.doc, delta.Patch)
+	entry.seq = delta.Seq
+	entry.status = nil
+	entry.lastUpdate = time.Now()
+	entry.online = true
+
+	zap.L().Sugar().Debugf("Status delta applied for node %s (seq %d, %d fields)", nodeID, delta.Seq, len(delta.Patch))
 	return nil
 }
 
+// statusLocked 返回节点状态，必要时从原始文档解码，调用方需持有锁
+func (c *StatusCollector) statusLocked(nodeID string, entry *statusEntry) (*NodeStatus, error) {
+	if entry.status == nil {
+		status, err := decodeStatus(entry.doc)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode status for node %s: %w", nodeID, err)
+		}
+		entry.status = status
+	}
+
+	// 每次返回副本，避免调用方看到后续更新
+	status := *entry.status
+	status.NodeID = nodeID
+	status.LastUpdate = entry.lastUpdate
+	status.Online = entry.online
+	return &status, nil
+}
+
 // GetStatus 获取节点状态
 func (c *StatusCollector) GetStatus(nodeID string) (*NodeStatus, bool) {
-	c.mu.RLock()
-	defer c.mu.RUnlock()
+	c.mu.Lock()
+	defer c.mu.Unlock()
 
-	status, ok := c.statusMap[nodeID]
+	entry, ok := c.entries[nodeID]
 	if !ok {
 		return nil, false
 	}
 
 	// 检查是否超时
-	if time.Since(status.LastUpdate) > c.nodeTimeout {
+	if time.Since(entry.lastUpdate) > c.nodeTimeout {
 		return nil, false
 	}
 
+	status, err := c.statusLocked(nodeID, entry)
+	if err != nil {
+		zap.L().Sugar().Error(err)
+		return nil, false
+	}
 	return status, true
 }
 
 // GetAllStatus 获取所有节点状态
 func (c *StatusCollector) GetAllStatus() map[string]*NodeStatus {
-	c.mu.RLock()
-	defer c.mu.RUnlock()
+	c.mu.Lock()
+	defer c.mu.Unlock()
 
 	result := make(map[string]*NodeStatus)
-	for nodeID, status := range c.statusMap {
-		if time.Since(status.LastUpdate) <= c.nodeTimeout {
-			result[nodeID] = status
+	for nodeID, entry := range c.entries {
+		if time.Since(entry.lastUpdate) > c.nodeTimeout {
+			continue
 		}
+		status, err := c.statusLocked(nodeID, entry)
+		if err != nil {
+			zap.L().Sugar().Error(err)
+			continue
+		}
+		result[nodeID] = status
 	}
 	return result
 }
 
 // GetOnlineNodes 获取在线节点列表
 func (c *StatusCollector) GetOnlineNodes() []string {
-	c.mu.RLock()
-	defer c.mu.RUnlock()
+	c.mu.Lock()
+	defer c.mu.Unlock()
 
 	var nodes []string
 	now := time.Now()
-	for nodeID, status := range c.statusMap {
-		if now.Sub(status.LastUpdate) <= c.nodeTimeout {
+	for nodeID, entry := range c.entries {
+		if now.Sub(entry.lastUpdate) <= c.nodeTimeout {
 			nodes = append(nodes, nodeID)
 		}
 	}
@@ -120,18 +197,18 @@ func (c *StatusCollector) cleanup() {
 	defer c.mu.Unlock()
 
 	now := time.Now()
-	for nodeID, status := range c.statusMap {
-		offlineDuration := now.Sub(status.LastUpdate)
+	for nodeID, entry := range c.entries {
+		offlineDuration := now.Sub(entry.lastUpdate)
 
 		// 标记离线节点
-		if offlineDuration > c.nodeTimeout && status.Online {
-			status.Online = false
+		if offlineDuration > c.nodeTimeout && entry.online {
+			entry.online = false
 			zap.L().Sugar().Warnf("Node %s marked as offline (timeout)", nodeID)
 		}
 
 		// 删除长时间离线的节点
 		if offlineDuration > c.nodeRemovalTimeout {
-			delete(c.statusMap, nodeID)
+			delete(c.entries, nodeID)
 			zap.L().Sugar().Infof("Node %s removed from collector (offline for %v)", nodeID, offlineDuration)
 		}
 	}
@@ -142,7 +219,7 @@ func (c *StatusCollector) RemoveNode(nodeID string) {
 	c.mu.Lock()
 	defer c.mu.Unlock()
 
-	delete(c.statusMap, nodeID)
+	delete(c.entries, nodeID)
 	zap.L().Sugar().Infof("Node %s removed from collector", nodeID)
 }
 
@@ -151,4 +228,4 @@ func (c *StatusCollector) Stop() {
 	c.once.Do(func() {
 		close(c.stopChan)
 	})
-}
\ No newline at end of file
+}
@@ -0,0 +1,55 @@
+package monitor
+
+import (
+	"encoding/json"
+	"errors"
+
+	"domcluster/api/statusdelta"
+)
+
+// ErrResyncRequired 增量的基准序号与已保存的快照不一致，需要节点重新发送完整快照
+var ErrResyncRequired = errors.New("status delta base mismatch, full snapshot required")
+
+// StatusDelta 节点上报的状态增量
+type StatusDelta struct {
+	Seq     uint64                 `json:"seq"`
+	BaseSeq uint64                 `json:"base_seq"`
+	Patch   map[string]interface{} `json:"patch"`
+}
+
+// applyMergePatch 按 JSON Merge Patch（RFC 7386）语义将补丁原地应用到文档
+func applyMergePatch(doc, patch map[string]interface{}) {
+	for key, val := range patch {
+		if val == nil {
+			delete(doc, key)
+			continue
+		}
+
+		patchMap, ok := val.(map[string]interface{})
+		if !ok {
+			doc[key] = val
+			continue
+		}
+
+		target, ok := doc[key].(map[string]interface{})
+		if !ok {
+			target = make(map[string]interface{})
+			doc[key] = target
+		}
+		applyMergePatch(target, patchMap)
+	}
+}
+
+// decodeStatus 将通用 JSON 文档解码为 NodeStatus，按元素键保存的数组先还原为数组
+func decodeStatus(doc map[string]interface{}) (*NodeStatus, error) {
+	data, err := json.Marshal(statusdelta.UnkeyArrays(doc))
+	if err != nil {
+		return nil, err
+	}
+
+	var status NodeStatus
+	if err := json.Unmarshal(data, &status); err != nil {
+		return nil, err
+	}
+	return &status, nil
+}
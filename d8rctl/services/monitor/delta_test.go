@@ -0,0 +1,68 @@
+package monitor
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+// snapshot 构造节点发送的完整快照
+func snapshot(t *testing.T, seq uint64, containers ...DockerContainer) []byte {
+	t.Helper()
+	data, err := json.Marshal(map[string]interface{}{
+		"report_seq": seq,
+		"docker":     map[string]interface{}{"containers": containers},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+func TestApplyDeltaKeyedContainers(t *testing.T) {
+	c := &StatusCollector{entries: make(map[string]*statusEntry), nodeTimeout: time.Minute}
+	if err := c.UpdateStatus("n1", snapshot(t, 1,
+		DockerContainer{ID: "bbb", Name: "web", CPUPercent: 1},
+		DockerContainer{ID: "aaa", Name: "db", CPUPercent: 2},
+	)); err != nil {
+		t.Fatal(err)
+	}
+
+	delta := `{"seq":2,"base_seq":1,"patch":{"docker":{"containers":{
+		"bbb":{"cpu_percent":5},
+		"aaa":null,
+		"ccc":{"id":"ccc","name":"cache","cpu_percent":0}
+	}}}}`
+	if err := c.ApplyDelta("n1", []byte(delta)); err != nil {
+		t.Fatal(err)
+	}
+
+	status, ok := c.GetStatus("n1")
+	if !ok {
+		t.Fatal("status for n1 not found")
+	}
+	got := status.Docker.Containers
+	if len(got) != 2 || got[0].Name != "cache" || got[1].Name != "web" {
+		t.Fatalf("containers = %+v, want [cache web]", got)
+	}
+	if got[1].CPUPercent != 5 {
+		t.Errorf("web cpu_percent = %v, want 5", got[1].CPUPercent)
+	}
+}
+
+func TestDecodeStatusLegacyArrayPatch(t *testing.T) {
+	// 旧版本节点的增量以数组整体替换容器列表
+	doc := map[string]interface{}{}
+	applyMergePatch(doc, map[string]interface{}{
+		"docker": map[string]interface{}{"containers": []interface{}{
+			map[string]interface{}{"id": "aaa", "name": "db"},
+		}},
+	})
+	status, err := decodeStatus(doc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(status.Docker.Containers) != 1 || status.Docker.Containers[0].ID != "aaa" {
+		t.Errorf("containers = %+v", status.Docker.Containers)
+	}
+}
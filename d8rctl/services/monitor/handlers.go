@@ -2,6 +2,7 @@ package monitor
 
 import (
 	"encoding/json"
+	"errors"
 
 	pb "domcluster/api/proto"
 	"go.uber.org/zap"
@@ -21,6 +22,23 @@ func HandleStatusUpdate(collector *StatusCollector, req *pb.PublishRequest) *pb.
 	})
 }
 
+// HandleStatusDelta 处理状态增量请求，基准不一致时要求节点重新发送完整快照
+func HandleStatusDelta(collector *StatusCollector, req *pb.PublishRequest) *pb.PublishResponse {
+	err := collector.ApplyDelta(req.Issuer, req.Data)
+	if errors.Is(err, ErrResyncRequired) {
+		zap.L().Sugar().Infof("Requesting full status snapshot from node %s", req.Issuer)
+		return resyncResponse(req.ReqId)
+	}
+	if err != nil {
+		zap.L().Sugar().Errorf("Failed to apply status delta for node %s: %v", req.Issuer, err)
+		return errorResponse(req.ReqId, "failed to apply status delta")
+	}
+
+	return successResponse(req.ReqId, map[string]interface{}{
+		"message": "status updated",
+	})
+}
+
 // HandleQueryResponse 处理查询响应（客户端响应查询请求）
 func HandleQueryResponse(collector *StatusCollector, req *pb.PublishRequest) *pb.PublishResponse {
 	// 客户端响应查询请求，更新状态
@@ -76,6 +94,20 @@ func successResponse(reqID string, data interface{}) *pb.PublishResponse {
 	}
 }
 
+// resyncResponse 创建重新同步响应，节点根据 cmd 字段路由到对应的处理器
+func resyncResponse(reqID string) *pb.PublishResponse {
+	dataBytes, _ := json.Marshal(map[string]string{
+		"cmd":   "status_resync",
+		"error": ErrResyncRequired.Error(),
+	})
+	return &pb.PublishResponse{
+		Reporter: "server",
+		ReqId:    reqID,
+		Status:   -1,
+		Data:     dataBytes,
+	}
+}
+
 // errorResponse 创建错误响应
 func errorResponse(reqID string, errMsg string) *pb.PublishResponse {
 	dataBytes, err := json.Marshal(map[string]string{"error": errMsg})
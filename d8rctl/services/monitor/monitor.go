@@ -34,6 +34,9 @@ func (m *Monitor) HandleCommand(req *pb.PublishRequest, stream pb.DomclusterServ
 	case "status_update":
 		// 被动接收客户端状态更新
 		return HandleStatusUpdate(m.collector, req)
+	case "status_delta":
+		// 被动接收客户端状态增量
+		return HandleStatusDelta(m.collector, req)
 	case "query_response":
 		// 处理客户端对查询的响应
 		return HandleQueryResponse(m.collector, req)
@@ -9,53 +9,159 @@ type HostInfo struct {
 	Architecture string `json:"architecture"`
 	GoVersion    string `json:"go_version"`
 	NumCPU       int    `json:"num_cpu"`
+	// CPUs 在线逻辑 CPU 的拓扑，节点无法读取 /sys 时为空
+	CPUs []LogicalCPU `json:"cpus,omitempty"`
+	// Cores 物理核心及其 SMT 兄弟线程
+	Cores []PhysicalCore `json:"cores,omitempty"`
+	// ThreadsPerCore 每个物理核心的线程数，大于 1 表示开启了 SMT
+	ThreadsPerCore int `json:"threads_per_core,omitempty"`
+}
+
+// LogicalCPU 一个逻辑 CPU 的拓扑
+type LogicalCPU struct {
+	ID      int `json:"id"`
+	Package int `json:"package"`
+	Core    int `json:"core"`
+	// Siblings 与该 CPU 共享同一物理核心的全部逻辑 CPU（含自身）
+	Siblings []int `json:"siblings"`
+}
+
+// PhysicalCore 一个物理核心，以其编号最小的逻辑 CPU 标识
+type PhysicalCore struct {
+	// CPU 核心中编号最小的逻辑 CPU
+	CPU     int `json:"cpu"`
+	Package int `json:"package"`
+	Core    int `json:"core"`
+	// Threads 核心上的全部逻辑 CPU，超过一个表示开启了 SMT
+	Threads []int `json:"threads"`
 }
 
 // CPUInfo CPU信息
 type CPUInfo struct {
-	CoreCount    int     `json:"core_count"`
+	CoreCount         int         `json:"core_count"`
+	UsagePercent      float64     `json:"usage_percent"`
+	IOWaitPercent     float64     `json:"iowait_percent"`
+	Cores             []CoreUsage `json:"cores,omitempty"`
+	ContextSwitches   uint64      `json:"context_switches"`
+	ContextSwitchRate float64     `json:"context_switch_rate"`
+}
+
+// CoreUsage 单核CPU使用率
+type CoreUsage struct {
+	ID           int     `json:"id"`
 	UsagePercent float64 `json:"usage_percent"`
 }
 
 // MemoryInfo 内存信息
 type MemoryInfo struct {
-	Total        uint64  `json:"total"`
-	Used         uint64  `json:"used"`
-	Available    uint64  `json:"available"`
-	UsagePercent float64 `json:"usage_percent"`
+	Total            uint64  `json:"total"`
+	Used             uint64  `json:"used"`
+	Available        uint64  `json:"available"`
+	Buffers          uint64  `json:"buffers"`
+	Cached           uint64  `json:"cached"`
+	UsagePercent     float64 `json:"usage_percent"`
+	SwapTotal        uint64  `json:"swap_total"`
+	SwapUsed         uint64  `json:"swap_used"`
+	SwapFree         uint64  `json:"swap_free"`
+	SwapUsagePercent float64 `json:"swap_usage_percent"`
+}
+
+// LoadAverage 系统负载
+type LoadAverage struct {
+	Load1        float64 `json:"load1"`
+	Load5        float64 `json:"load5"`
+	Load15       float64 `json:"load15"`
+	RunningProcs int     `json:"running_procs"`
+	TotalProcs   int     `json:"total_procs"`
 }
 
 // DiskInfo 磁盘信息
 type DiskInfo struct {
-	Path         string  `json:"path"`
-	Total        uint64  `json:"total"`
-	Used         uint64  `json:"used"`
-	Free         uint64  `json:"free"`
-	UsagePercent float64 `json:"usage_percent"`
+	Path              string  `json:"path"`
+	Device            string  `json:"device,omitempty"`
+	FSType            string  `json:"fs_type,omitempty"`
+	Total             uint64  `json:"total"`
+	Used              uint64  `json:"used"`
+	Free              uint64  `json:"free"`
+	UsagePercent      float64 `json:"usage_percent"`
+	InodesTotal       uint64  `json:"inodes_total"`
+	InodesUsed        uint64  `json:"inodes_used"`
+	InodesFree        uint64  `json:"inodes_free"`
+	InodeUsagePercent float64 `json:"inode_usage_percent"`
+}
+
+// DiskIOInfo 块设备 I/O 统计
+type DiskIOInfo struct {
+	Device           string  `json:"device"`
+	ReadBytes        uint64  `json:"read_bytes"`
+	WriteBytes       uint64  `json:"write_bytes"`
+	ReadBytesPerSec  float64 `json:"read_bytes_per_sec"`
+	WriteBytesPerSec float64 `json:"write_bytes_per_sec"`
+	ReadIOPS         float64 `json:"read_iops"`
+	WriteIOPS        float64 `json:"write_iops"`
+	UtilPercent      float64 `json:"util_percent"`
 }
 
 // NetworkInfo 网络信息
 type NetworkInfo struct {
-	RxBytes uint64 `json:"rx_bytes"`
-	TxBytes uint64 `json:"tx_bytes"`
+	RxBytes       uint64          `json:"rx_bytes"`
+	TxBytes       uint64          `json:"tx_bytes"`
+	RxBytesPerSec float64         `json:"rx_bytes_per_sec"`
+	TxBytesPerSec float64         `json:"tx_bytes_per_sec"`
+	Interfaces    []InterfaceInfo `json:"interfaces"`
+}
+
+// InterfaceInfo 单个网卡的统计信息
+type InterfaceInfo struct {
+	Name            string  `json:"name"`
+	OperState       string  `json:"oper_state"`
+	SpeedMbps       int     `json:"speed_mbps"`
+	Virtual         bool    `json:"virtual"`
+	CarrierChanges  uint64  `json:"carrier_changes"`
+	RxBytes         uint64  `json:"rx_bytes"`
+	TxBytes         uint64  `json:"tx_bytes"`
+	RxPackets       uint64  `json:"rx_packets"`
+	TxPackets       uint64  `json:"tx_packets"`
+	RxErrors        uint64  `json:"rx_errors"`
+	TxErrors        uint64  `json:"tx_errors"`
+	RxDropped       uint64  `json:"rx_dropped"`
+	TxDropped       uint64  `json:"tx_dropped"`
+	RxBytesPerSec   float64 `json:"rx_bytes_per_sec"`
+	TxBytesPerSec   float64 `json:"tx_bytes_per_sec"`
+	RxPacketsPerSec float64 `json:"rx_packets_per_sec"`
+	TxPacketsPerSec float64 `json:"tx_packets_per_sec"`
 }
 
 // SystemResources 系统资源信息
 type SystemResources struct {
 	CPU     *CPUInfo     `json:"cpu"`
 	Memory  *MemoryInfo  `json:"memory"`
-	Disk    *DiskInfo    `json:"disk"`
+	Disk    *DiskInfo    `json:"disk"` // 根文件系统，兼容旧版本
+	Disks   []DiskInfo   `json:"disks"`
+	DiskIO  []DiskIOInfo `json:"disk_io"`
 	Network *NetworkInfo `json:"network"`
+	LoadAvg *LoadAverage `json:"load_avg"`
+	Uptime  float64      `json:"uptime"`
 }
 
 // DockerContainer Docker容器信息
 type DockerContainer struct {
-	ID        string `json:"id"`
-	Name      string `json:"name"`
-	Image     string `json:"image"`
-	Status    string `json:"status"`
-	Ports     string `json:"ports"`
-	CreatedAt string `json:"created_at"`
+	ID             string  `json:"id"` // 12 位短 ID
+	FullID         string  `json:"full_id,omitempty"`
+	Name           string  `json:"name"`
+	Image          string  `json:"image"`
+	Status         string  `json:"status"`
+	State          string  `json:"state"`
+	Health         string  `json:"health,omitempty"`
+	RestartCount   int     `json:"restart_count"`
+	Ports          string  `json:"ports"`
+	CreatedAt      string  `json:"created_at"`
+	CPUPercent     float64 `json:"cpu_percent"`
+	MemoryUsage    uint64  `json:"memory_usage"`
+	MemoryLimit    uint64  `json:"memory_limit"`
+	MemoryPercent  float64 `json:"memory_percent"`
+	NetworkRxBytes uint64  `json:"network_rx_bytes"`
+	NetworkTxBytes uint64  `json:"network_tx_bytes"`
 }
 
 // DockerInfo Docker信息
@@ -65,13 +171,58 @@ type DockerInfo struct {
 	Containers   []DockerContainer `json:"containers"`
 }
 
+// ProcessInfo 单个进程的资源占用
+type ProcessInfo struct {
+	PID           int     `json:"pid"`
+	PPID          int     `json:"ppid"`
+	Name          string  `json:"name"`
+	Cmdline       string  `json:"cmdline"`
+	State         string  `json:"state"`
+	User          string  `json:"user"`
+	UID           int     `json:"uid"`
+	Cgroup        string  `json:"cgroup,omitempty"`
+	ContainerID   string  `json:"container_id,omitempty"`
+	CPUPercent    float64 `json:"cpu_percent"`
+	MemoryRSS     uint64  `json:"memory_rss"`
+	MemoryPercent float64 `json:"memory_percent"`
+	Threads       int     `json:"threads"`
+	OpenFDs       int     `json:"open_fds"`
+	StartTime     int64   `json:"start_time"`
+}
+
+// TopProcesses 进程排行查询结果
+type TopProcesses struct {
+	SortBy     string        `json:"sort_by"`
+	Limit      int           `json:"limit"`
+	Total      int           `json:"total"`
+	Processes  []ProcessInfo `json:"processes"`
+	SampledFor float64       `json:"sampled_for"`
+	Timestamp  int64         `json:"timestamp"`
+}
+
+// ProbeStatus 健康探针状态
+type ProbeStatus struct {
+	Name                 string    `json:"name"`
+	Type                 string    `json:"type"`
+	Target               string    `json:"target"`
+	State                string    `json:"state"`
+	ConsecutiveSuccesses int       `json:"consecutive_successes"`
+	ConsecutiveFailures  int       `json:"consecutive_failures"`
+	LastSuccess          bool      `json:"last_success"`
+	Message              string    `json:"message,omitempty"`
+	LatencyMs            float64   `json:"latency_ms"`
+	LastCheck            time.Time `json:"last_check"`
+	LastTransition       time.Time `json:"last_transition"`
+}
+
 // NodeStatus 节点状态
 type NodeStatus struct {
-	NodeID           string            `json:"node_id"`
-	LastUpdate       time.Time         `json:"last_update"`
-	Host             *HostInfo         `json:"host"`
-	SystemResources  *SystemResources  `json:"system_resources"`
-	Docker           *DockerInfo       `json:"docker"`
-	Online           bool              `json:"online"`
-	Metadata         map[string]string `json:"metadata,omitempty"`
-}
\ No newline at end of file
+	NodeID          string            `json:"node_id"`
+	LastUpdate      time.Time         `json:"last_update"`
+	Host            *HostInfo         `json:"host"`
+	SystemResources *SystemResources  `json:"system_resources"`
+	Docker          *DockerInfo       `json:"docker"`
+	Probes          []ProbeStatus     `json:"probes"`
+	Online          bool              `json:"online"`
+	Metadata        map[string]string `json:"metadata,omitempty"`
+}
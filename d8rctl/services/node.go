@@ -7,6 +7,7 @@ type NodeInfo struct {
 	Name    string
 	Role    string
 	Version string
+	Labels  map[string]string // 节点注册时上报的标签
 }
 
 // NodeManager 节点管理器
@@ -0,0 +1,106 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"d8rctl/services/monitor"
+
+	pb "domcluster/api/proto"
+	"go.uber.org/zap"
+)
+
+// RegisterQueryResponse 注册查询响应处理器
+func (s *DomclusterServer) RegisterQueryResponse(reqID string, resultChan chan []byte) {
+	s.queryResponsesMu.Lock()
+	defer s.queryResponsesMu.Unlock()
+	s.queryResponses[reqID] = resultChan
+	s.queryResponseTimestamps[reqID] = time.Now()
+}
+
+// unregisterQueryResponse 注销查询响应处理器
+func (s *DomclusterServer) unregisterQueryResponse(reqID string) {
+	s.queryResponsesMu.Lock()
+	defer s.queryResponsesMu.Unlock()
+	delete(s.queryResponses, reqID)
+	delete(s.queryResponseTimestamps, reqID)
+}
+
+// handleQueryResponse 处理查询响应
+func (s *DomclusterServer) handleQueryResponse(req *pb.PublishRequest) {
+	s.queryResponsesMu.Lock()
+	resultChan, ok := s.queryResponses[req.ReqId]
+	if ok {
+		delete(s.queryResponses, req.ReqId)
+		delete(s.queryResponseTimestamps, req.ReqId)
+	}
+	s.queryResponsesMu.Unlock()
+
+	if !ok {
+		zap.L().Sugar().Warnf("No query response handler for reqID: %s", req.ReqId)
+		return
+	}
+
+	select {
+	case resultChan <- req.Data:
+		zap.L().Sugar().Debugf("Query response delivered for reqID: %s", req.ReqId)
+	default:
+		zap.L().Sugar().Warnf("Query response channel full or closed for reqID: %s", req.ReqId)
+	}
+}
+
+// QueryNode 向节点发送查询命令并等待 query_response，节点返回的 error 字段会转换为错误
+func (s *DomclusterServer) QueryNode(ctx context.Context, nodeID, cmd string, data map[string]interface{}) ([]byte, error) {
+	if data == nil {
+		data = make(map[string]interface{})
+	}
+	data["cmd"] = cmd
+	data["timestamp"] = time.Now().Unix()
+
+	dataBytes, err := json.Marshal(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal query: %w", err)
+	}
+
+	reqID := fmt.Sprintf("query_%d", time.Now().UnixNano())
+	resultChan := make(chan []byte, 1)
+	s.RegisterQueryResponse(reqID, resultChan)
+
+	if err := s.SendToNode(nodeID, cmd, reqID, dataBytes); err != nil {
+		s.unregisterQueryResponse(reqID)
+		return nil, fmt.Errorf("failed to send query to node: %w", err)
+	}
+
+	select {
+	case result := <-resultChan:
+		var errResp struct {
+			Error string `json:"error"`
+		}
+		if err := json.Unmarshal(result, &errResp); err == nil && errResp.Error != "" {
+			return nil, fmt.Errorf("node %s: %s", nodeID, errResp.Error)
+		}
+		return result, nil
+	case <-ctx.Done():
+		s.unregisterQueryResponse(reqID)
+		return nil, ctx.Err()
+	}
+}
+
+// QueryTopProcesses 查询节点上按 CPU 或内存排序的进程
+func (s *DomclusterServer) QueryTopProcesses(ctx context.Context, nodeID, sortBy string, limit int) (*monitor.TopProcesses, error) {
+	result, err := s.QueryNode(ctx, nodeID, "process_query", map[string]interface{}{
+		"sort":  sortBy,
+		"limit": limit,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var top monitor.TopProcesses
+	if err := json.Unmarshal(result, &top); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal top processes: %w", err)
+	}
+	return &top, nil
+}
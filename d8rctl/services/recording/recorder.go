@@ -0,0 +1,165 @@
+package recording
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// flushInterval 录像缓冲写入磁盘的最长间隔
+const flushInterval = time.Second
+
+// Recorder 正在进行的会话录制
+//
+// 事件按 asciicast v2 格式追加写入：[秒数, 类型, 数据]，类型 o 为输出、i 为输入、r 为窗口大小调整、
+// m 为标记（如发送给进程的控制信号）。
+type Recorder struct {
+	store *Store
+
+	mu        sync.Mutex
+	meta      Metadata
+	file      *os.File
+	w         *bufio.Writer
+	lastFlush time.Time
+	closed    bool
+}
+
+// Metadata 返回当前元数据
+func (r *Recorder) Metadata() Metadata {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.meta
+}
+
+// Output 记录终端输出
+func (r *Recorder) Output(data string) {
+	r.event("o", data)
+}
+
+// Input 记录用户输入
+func (r *Recorder) Input(data string) {
+	r.event("i", data)
+}
+
+// Signal 以标记事件记录发送给进程的控制信号，与键盘输入区分
+func (r *Recorder) Signal(signal string) {
+	r.event("m", "signal "+signal)
+}
+
+// Resize 记录窗口大小调整
+func (r *Recorder) Resize(cols, rows uint) {
+	r.event("r", fmt.Sprintf("%dx%d", cols, rows))
+}
+
+// event 追加一个事件，写入失败只记录日志，不影响终端会话
+func (r *Recorder) event(kind, data string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.closed {
+		return
+	}
+
+	elapsed := time.Since(r.meta.StartTime).Seconds()
+	if err := r.writeLine([]interface{}{roundSeconds(elapsed), kind, data}); err != nil {
+		zap.L().Sugar().Warnf("Failed to write recording %s: %v", r.meta.ID, err)
+		return
+	}
+	if time.Since(r.lastFlush) >= flushInterval {
+		r.w.Flush()
+		r.lastFlush = time.Now()
+	}
+}
+
+// writeLine 写入一行 JSON，调用方持有锁
+func (r *Recorder) writeLine(v interface{}) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	data = append(data, '\n')
+	if _, err := r.w.Write(data); err != nil {
+		return err
+	}
+	r.meta.Size += int64(len(data))
+	return nil
+}
+
+// Close 结束录制并写入最终元数据
+func (r *Recorder) Close() {
+	r.mu.Lock()
+	if r.closed {
+		r.mu.Unlock()
+		return
+	}
+	r.closed = true
+	r.w.Flush()
+	r.file.Close()
+	r.meta.EndTime = time.Now()
+	meta := r.meta
+	r.mu.Unlock()
+
+	if err := r.store.writeMeta(meta); err != nil {
+		zap.L().Sugar().Warnf("Failed to finalize recording %s: %v", meta.ID, err)
+	}
+	r.store.finish(meta.ID)
+}
+
+// roundSeconds 时间精确到微秒，与 asciinema 一致
+func roundSeconds(s float64) float64 {
+	return float64(int64(s*1e6)) / 1e6
+}
+
+// Header asciicast v2 文件头
+type Header struct {
+	Version   int    `json:"version"`
+	Width     uint   `json:"width"`
+	Height    uint   `json:"height"`
+	Timestamp int64  `json:"timestamp"`
+	Title     string `json:"title,omitempty"`
+}
+
+// Event asciicast v2 事件
+type Event struct {
+	Time float64
+	Type string
+	Data string
+}
+
+// ReadEvents 读取 asciicast v2 文件，header 不为 nil 时填入文件头；fn 返回 false 时停止读取
+func ReadEvents(r io.Reader, header *Header, fn func(Event) bool) error {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 4*1024*1024)
+
+	first := true
+	for scanner.Scan() {
+		line := scanner.Bytes()
+		if first {
+			first = false
+			if header != nil {
+				if err := json.Unmarshal(line, header); err != nil {
+					return fmt.Errorf("invalid asciicast header: %w", err)
+				}
+			}
+			continue
+		}
+
+		var raw []json.RawMessage
+		if err := json.Unmarshal(line, &raw); err != nil || len(raw) != 3 {
+			continue
+		}
+		var e Event
+		if json.Unmarshal(raw[0], &e.Time) != nil || json.Unmarshal(raw[1], &e.Type) != nil || json.Unmarshal(raw[2], &e.Data) != nil {
+			continue
+		}
+		if !fn(e) {
+			return nil
+		}
+	}
+	return scanner.Err()
+}
@@ -0,0 +1,52 @@
+package recording
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestRecorderSignalIsMarker(t *testing.T) {
+	s := &Store{dir: t.TempDir(), active: make(map[string]*Recorder)}
+	r, err := s.Start(Metadata{ID: "s1", Auth: AuthSession, User: "password", NodeID: "n1", StartTime: time.Now()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.Input("ls\r")
+	r.Signal("SIGINT")
+	r.Close()
+
+	f, err := os.Open(filepath.Join(s.dir, "s1"+castExt))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	var events []Event
+	if err := ReadEvents(f, nil, func(e Event) bool {
+		events = append(events, e)
+		return true
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 2 || events[0].Type != "i" || events[1].Type != "m" || events[1].Data != "signal SIGINT" {
+		t.Errorf("events = %+v, want input followed by signal marker", events)
+	}
+}
+
+func TestStoreLegacyMetadataIsSelfDeclared(t *testing.T) {
+	s := &Store{dir: t.TempDir(), active: make(map[string]*Recorder)}
+	legacy := `{"id":"old","kind":"shell","declared_user":"alice","node_id":"n1"}`
+	if err := os.WriteFile(filepath.Join(s.dir, "old"+metaExt), []byte(legacy), 0640); err != nil {
+		t.Fatal(err)
+	}
+
+	meta, err := s.Get("old")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if meta.Auth != AuthSelfDeclared || meta.User != "alice" {
+		t.Errorf("legacy metadata = %+v, want self-declared user alice", meta)
+	}
+}
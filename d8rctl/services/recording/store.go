@@ -0,0 +1,377 @@
+package recording
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+const (
+	castExt = ".cast"
+	metaExt = ".json"
+)
+
+// 录像的认证方式
+const (
+	// AuthSession 通过登录会话认证，User 为会话的操作者身份
+	AuthSession = "session"
+	// AuthNone 未启用登录认证，User 为空，不代表任何操作者
+	AuthNone = "none"
+	// AuthSelfDeclared 旧版本录像，User 为登录时自行填写的名称，未经验证
+	AuthSelfDeclared = "self_declared"
+)
+
+// Metadata 一次终端会话录像的元数据
+type Metadata struct {
+	ID   string `json:"id"`
+	Kind string `json:"kind"` // shell 或 exec
+	// Auth 认证方式，取值为 AuthSession、AuthNone 或 AuthSelfDeclared
+	Auth string `json:"auth"`
+	// User 发起会话的操作者身份，取自认证后的登录会话
+	User string `json:"user"`
+	// LoginSession 发起会话的登录会话 ID，与登录日志中的会话 ID 对应
+	LoginSession string    `json:"login_session,omitempty"`
+	RemoteAddr   string    `json:"remote_addr"`
+	NodeID       string    `json:"node_id"`
+	ContainerID  string    `json:"container_id,omitempty"`
+	StartTime    time.Time `json:"start_time"`
+	// EndTime 为零值表示会话仍在进行
+	EndTime time.Time `json:"end_time,omitempty"`
+	Width   uint      `json:"width"`
+	Height  uint      `json:"height"`
+	Size    int64     `json:"size"`
+}
+
+// operator 用于显示的操作者，未启用登录认证或旧版本录像时注明
+func (m *Metadata) operator() string {
+	switch m.Auth {
+	case AuthNone:
+		return "unauthenticated"
+	case AuthSelfDeclared:
+		return m.User + " (self-declared)"
+	}
+	return m.User
+}
+
+// Query 录像查询条件，零值字段不参与过滤
+type Query struct {
+	NodeID string
+	User   string
+	// Since/Until 筛选与该时间段有重叠的会话
+	Since time.Time
+	Until time.Time
+	// Text 在会话输入输出中做不区分大小写的子串匹配
+	Text string
+}
+
+// Store 终端录像存储
+//
+// 每个会话保存为 asciicast v2 文件（<id>.cast）和元数据文件（<id>.json），
+// 超过 maxAge 的录像以及总大小超过 maxBytes 时最旧的录像会被删除。
+type Store struct {
+	dir      string
+	maxAge   time.Duration
+	maxBytes int64
+
+	mu     sync.Mutex
+	active map[string]*Recorder
+
+	stop chan struct{}
+}
+
+// NewStore 创建录像存储并启动保留策略清理
+func NewStore(dir string, maxAge time.Duration, maxBytes int64) *Store {
+	s := &Store{
+		dir:      dir,
+		maxAge:   maxAge,
+		maxBytes: maxBytes,
+		active:   make(map[string]*Recorder),
+		stop:     make(chan struct{}),
+	}
+	go s.retentionLoop()
+	return s
+}
+
+// Start 开始录制会话，meta.ID 由调用方保证唯一
+func (s *Store) Start(meta Metadata) (*Recorder, error) {
+	if !validID(meta.ID) {
+		return nil, fmt.Errorf("invalid recording id %q", meta.ID)
+	}
+	if err := os.MkdirAll(s.dir, 0750); err != nil {
+		return nil, fmt.Errorf("failed to create recording dir: %w", err)
+	}
+
+	f, err := os.OpenFile(filepath.Join(s.dir, meta.ID+castExt), os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0640)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create recording: %w", err)
+	}
+
+	r := &Recorder{store: s, meta: meta, file: f, w: bufio.NewWriter(f)}
+	header := map[string]interface{}{
+		"version":   2,
+		"width":     meta.Width,
+		"height":    meta.Height,
+		"timestamp": meta.StartTime.Unix(),
+		"title":     fmt.Sprintf("%s@%s", meta.operator(), meta.NodeID),
+		"env":       map[string]string{"TERM": "xterm-256color"},
+	}
+	if err := r.writeLine(header); err != nil {
+		f.Close()
+		return nil, err
+	}
+	if err := s.writeMeta(meta); err != nil {
+		f.Close()
+		return nil, err
+	}
+
+	s.mu.Lock()
+	s.active[meta.ID] = r
+	s.mu.Unlock()
+	return r, nil
+}
+
+// List 按开始时间倒序列出符合条件的录像
+func (s *Store) List(q Query) ([]Metadata, error) {
+	entries, err := os.ReadDir(s.dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return []Metadata{}, nil
+		}
+		return nil, fmt.Errorf("failed to list recordings: %w", err)
+	}
+
+	result := []Metadata{}
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !strings.HasSuffix(name, metaExt) {
+			continue
+		}
+		meta, err := s.Get(strings.TrimSuffix(name, metaExt))
+		if err != nil {
+			continue
+		}
+		if !matchMeta(meta, q) {
+			continue
+		}
+		if q.Text != "" {
+			found, err := s.containsText(meta.ID, q.Text)
+			if err != nil || !found {
+				continue
+			}
+		}
+		result = append(result, *meta)
+	}
+
+	sort.Slice(result, func(i, j int) bool { return result[i].StartTime.After(result[j].StartTime) })
+	return result, nil
+}
+
+// matchMeta 按元数据过滤
+func matchMeta(meta *Metadata, q Query) bool {
+	if q.NodeID != "" && meta.NodeID != q.NodeID {
+		return false
+	}
+	if q.User != "" && meta.User != q.User {
+		return false
+	}
+	// 未结束的会话视为持续到现在
+	end := meta.EndTime
+	if end.IsZero() {
+		end = time.Now()
+	}
+	if !q.Since.IsZero() && end.Before(q.Since) {
+		return false
+	}
+	if !q.Until.IsZero() && meta.StartTime.After(q.Until) {
+		return false
+	}
+	return true
+}
+
+// Get 读取录像元数据，进行中的会话返回实时的大小
+func (s *Store) Get(id string) (*Metadata, error) {
+	if !validID(id) {
+		return nil, os.ErrNotExist
+	}
+
+	s.mu.Lock()
+	r, active := s.active[id]
+	s.mu.Unlock()
+	if active {
+		meta := r.Metadata()
+		return &meta, nil
+	}
+
+	data, err := os.ReadFile(filepath.Join(s.dir, id+metaExt))
+	if err != nil {
+		return nil, err
+	}
+	var meta Metadata
+	if err := json.Unmarshal(data, &meta); err != nil {
+		return nil, fmt.Errorf("invalid recording metadata: %w", err)
+	}
+	// 旧版本没有认证方式，以 user 或 declared_user 字段保存登录时自行填写的名称
+	if meta.Auth == "" {
+		var legacy struct {
+			DeclaredUser string `json:"declared_user"`
+		}
+		if json.Unmarshal(data, &legacy) == nil && legacy.DeclaredUser != "" {
+			meta.User = legacy.DeclaredUser
+		}
+		meta.Auth = AuthSelfDeclared
+	}
+	// 控制端异常退出时会话没有正常结束，以录像文件最后写入的时间作为结束时间
+	if meta.EndTime.IsZero() {
+		if info, err := os.Stat(filepath.Join(s.dir, id+castExt)); err == nil {
+			meta.EndTime = info.ModTime()
+			meta.Size = info.Size()
+		}
+	}
+	return &meta, nil
+}
+
+// Path 返回录像文件路径
+func (s *Store) Path(id string) (string, error) {
+	if !validID(id) {
+		return "", os.ErrNotExist
+	}
+	path := filepath.Join(s.dir, id+castExt)
+	if _, err := os.Stat(path); err != nil {
+		return "", err
+	}
+	return path, nil
+}
+
+// containsText 检查录像的输入输出中是否包含指定文本
+func (s *Store) containsText(id, text string) (bool, error) {
+	f, err := os.Open(filepath.Join(s.dir, id+castExt))
+	if err != nil {
+		return false, err
+	}
+	defer f.Close()
+
+	text = strings.ToLower(text)
+	found := false
+	err = ReadEvents(f, nil, func(e Event) bool {
+		if (e.Type == "i" || e.Type == "o") && strings.Contains(strings.ToLower(e.Data), text) {
+			found = true
+			return false
+		}
+		return true
+	})
+	return found, err
+}
+
+// writeMeta 写入元数据文件，先写临时文件再重命名，避免读到不完整的内容
+func (s *Store) writeMeta(meta Metadata) error {
+	data, err := json.MarshalIndent(meta, "", "  ")
+	if err != nil {
+		return err
+	}
+	path := filepath.Join(s.dir, meta.ID+metaExt)
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0640); err != nil {
+		return fmt.Errorf("failed to write recording metadata: %w", err)
+	}
+	return os.Rename(tmp, path)
+}
+
+// finish 会话结束，移出活动列表
+func (s *Store) finish(id string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.active, id)
+}
+
+// retentionLoop 定期执行保留策略
+func (s *Store) retentionLoop() {
+	ticker := time.NewTicker(time.Hour)
+	defer ticker.Stop()
+
+	s.enforceRetention()
+	for {
+		select {
+		case <-ticker.C:
+			s.enforceRetention()
+		case <-s.stop:
+			return
+		}
+	}
+}
+
+// enforceRetention 删除过期录像，总大小仍超限时从最旧的录像开始删除，进行中的会话不删除
+func (s *Store) enforceRetention() {
+	all, err := s.List(Query{})
+	if err != nil {
+		zap.L().Sugar().Warnf("Failed to list recordings: %v", err)
+		return
+	}
+
+	s.mu.Lock()
+	active := make(map[string]bool, len(s.active))
+	for id := range s.active {
+		active[id] = true
+	}
+	s.mu.Unlock()
+
+	var total int64
+	for _, meta := range all {
+		total += meta.Size
+	}
+
+	cutoff := time.Now().Add(-s.maxAge)
+	// List 返回的顺序为从新到旧，倒序遍历即从最旧的开始
+	for i := len(all) - 1; i >= 0; i-- {
+		meta := all[i]
+		if active[meta.ID] {
+			continue
+		}
+		expired := s.maxAge > 0 && meta.StartTime.Before(cutoff)
+		oversize := s.maxBytes > 0 && total > s.maxBytes
+		if !expired && !oversize {
+			continue
+		}
+		os.Remove(filepath.Join(s.dir, meta.ID+castExt))
+		os.Remove(filepath.Join(s.dir, meta.ID+metaExt))
+		total -= meta.Size
+		zap.L().Sugar().Infof("Removed terminal recording %s (node %s, user %s, started %s)",
+			meta.ID, meta.NodeID, meta.operator(), meta.StartTime.Format(time.RFC3339))
+	}
+}
+
+// Stop 停止保留策略清理，并结束所有进行中的录制
+func (s *Store) Stop() {
+	close(s.stop)
+
+	s.mu.Lock()
+	recorders := make([]*Recorder, 0, len(s.active))
+	for _, r := range s.active {
+		recorders = append(recorders, r)
+	}
+	s.mu.Unlock()
+
+	for _, r := range recorders {
+		r.Close()
+	}
+}
+
+// validID 录像 ID 只允许字母、数字、下划线和连字符，防止路径穿越
+func validID(id string) bool {
+	if id == "" {
+		return false
+	}
+	for _, r := range id {
+		if !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '_' || r == '-') {
+			return false
+		}
+	}
+	return true
+}
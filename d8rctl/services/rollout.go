@@ -0,0 +1,731 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"d8rctl/config"
+	"d8rctl/services/domjudge"
+
+	"go.uber.org/zap"
+)
+
+const (
+	// defaultRolloutDrainTimeout 等待 judgehost 完成当前评测的默认秒数
+	defaultRolloutDrainTimeout = 600
+	// rolloutDrainPollInterval 等待排空时查询 DomJudge 的间隔
+	rolloutDrainPollInterval = 3 * time.Second
+	// rolloutStopTimeout 重建容器时等待旧容器退出的秒数
+	rolloutStopTimeout = 30
+)
+
+// rollout 的状态
+const (
+	RolloutRunning     = "running"
+	RolloutSucceeded   = "succeeded"
+	RolloutHalted      = "halted"
+	RolloutRollingBack = "rolling_back"
+	RolloutRolledBack  = "rolled_back"
+)
+
+// rollout 中单个容器的状态
+const (
+	RolloutTargetPending    = "pending"
+	RolloutTargetDraining   = "draining"
+	RolloutTargetUpdating   = "updating"
+	RolloutTargetUpdated    = "updated"
+	RolloutTargetSkipped    = "skipped"
+	RolloutTargetFailed     = "failed"
+	RolloutTargetRolledBack = "rolled_back"
+)
+
+// 栈部署步骤中 rollout 使用的动作
+const (
+	StackActionDrained    = "drained"
+	StackActionRolledBack = "rolled_back"
+)
+
+// RolloutRequest 滚动更新 judgehost 镜像的请求
+type RolloutRequest struct {
+	Stack string `json:"stack"`
+	Image string `json:"image"`
+	// Nodes 只更新这些节点上的 judgehost，为空时更新栈的全部 judgehost 节点
+	Nodes []string `json:"nodes,omitempty"`
+	// BatchSize 每批同时更新的容器数，默认 1
+	BatchSize int `json:"batch_size,omitempty"`
+	// DrainTimeoutSeconds 等待 judgehost 完成当前评测的秒数，默认 600
+	DrainTimeoutSeconds int `json:"drain_timeout_seconds,omitempty"`
+	// HealthTimeoutSeconds 等待新容器健康的秒数，默认使用栈的 health_timeout
+	HealthTimeoutSeconds int `json:"health_timeout_seconds,omitempty"`
+	// NoRollback 失败时只暂停而不回滚，修复后可以继续
+	NoRollback bool `json:"no_rollback,omitempty"`
+}
+
+// RolloutTarget rollout 中的一个 judgehost 容器
+type RolloutTarget struct {
+	NodeID    string `json:"node_id"`
+	Container string `json:"container"`
+	// Hostname judgehost 在 DomJudge 中的名字
+	Hostname      string    `json:"hostname"`
+	PreviousImage string    `json:"previous_image"`
+	Status        string    `json:"status"`
+	WasRunning    bool      `json:"was_running"`
+	Error         string    `json:"error,omitempty"`
+	Updated       time.Time `json:"updated,omitempty"`
+}
+
+// Rollout 一次滚动更新的状态，保存在磁盘上以便控制器重启后继续
+type Rollout struct {
+	RolloutRequest
+	State    string          `json:"state"`
+	Error    string          `json:"error,omitempty"`
+	Started  time.Time       `json:"started"`
+	Finished time.Time       `json:"finished,omitempty"`
+	Targets  []RolloutTarget `json:"targets"`
+}
+
+// rolloutRun 进行中或最近一次的 rollout
+type rolloutRun struct {
+	mu     sync.Mutex
+	status Rollout
+}
+
+// rolloutDir rollout 状态的保存目录
+func rolloutDir() string {
+	return filepath.Join(config.GetDataDir(), "rollouts")
+}
+
+// saveLocked 保存状态，调用方需持有锁
+func (r *rolloutRun) saveLocked() {
+	data, err := json.MarshalIndent(r.status, "", "  ")
+	if err == nil {
+		err = os.MkdirAll(rolloutDir(), 0755)
+	}
+	if err == nil {
+		path := filepath.Join(rolloutDir(), r.status.Stack+".json")
+		tmp := path + ".tmp"
+		if err = os.WriteFile(tmp, data, 0644); err == nil {
+			err = os.Rename(tmp, path)
+		}
+	}
+	if err != nil {
+		zap.L().Sugar().Warnf("Failed to save rollout of stack %s: %v", r.status.Stack, err)
+	}
+}
+
+// update 修改状态并保存
+func (r *rolloutRun) update(fn func(st *Rollout)) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	fn(&r.status)
+	r.saveLocked()
+}
+
+// setTarget 修改容器状态并保存
+func (r *rolloutRun) setTarget(i int, status string, err error) {
+	r.update(func(st *Rollout) {
+		t := &st.Targets[i]
+		t.Status = status
+		t.Updated = time.Now()
+		t.Error = ""
+		if err != nil {
+			t.Error = err.Error()
+		}
+	})
+}
+
+// snapshot 返回状态副本
+func (r *rolloutRun) snapshot() *Rollout {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	st := r.status
+	st.Nodes = append([]string(nil), r.status.Nodes...)
+	st.Targets = append([]RolloutTarget(nil), r.status.Targets...)
+	return &st
+}
+
+// loadRollouts 读取已保存的 rollout，控制器退出时未完成的标记为暂停
+func loadRollouts() map[string]*rolloutRun {
+	result := make(map[string]*rolloutRun)
+	entries, err := os.ReadDir(rolloutDir())
+	if err != nil {
+		if !os.IsNotExist(err) {
+			zap.L().Sugar().Warnf("Failed to read rollouts: %v", err)
+		}
+		return result
+	}
+
+	for _, e := range entries {
+		if e.IsDir() || !strings.HasSuffix(e.Name(), ".json") {
+			continue
+		}
+		data, err := os.ReadFile(filepath.Join(rolloutDir(), e.Name()))
+		if err != nil {
+			zap.L().Sugar().Warnf("Failed to read rollout %s: %v", e.Name(), err)
+			continue
+		}
+		var st Rollout
+		if err := json.Unmarshal(data, &st); err != nil {
+			zap.L().Sugar().Warnf("Failed to parse rollout %s: %v", e.Name(), err)
+			continue
+		}
+		if st.State == RolloutRunning || st.State == RolloutRollingBack {
+			st.State = RolloutHalted
+			st.Error = "interrupted by controller restart"
+		}
+		result[st.Stack] = &rolloutRun{status: st}
+	}
+	return result
+}
+
+// StartRollout 开始滚动更新栈的 judgehost 镜像
+//
+// 每批更新 BatchSize 个容器：配置了 DomJudge 时先禁用 judgehost 并等待当前评测完成，
+// 然后用新镜像重建容器，健康后重新启用。任一容器失败即停止，默认回滚已更新的容器。
+func (s *DomclusterServer) StartRollout(req RolloutRequest) (*Rollout, error) {
+	if req.Image == "" {
+		return nil, fmt.Errorf("image is required")
+	}
+	if req.BatchSize == 0 {
+		req.BatchSize = 1
+	}
+	if req.DrainTimeoutSeconds == 0 {
+		req.DrainTimeoutSeconds = defaultRolloutDrainTimeout
+	}
+	if req.BatchSize < 0 || req.DrainTimeoutSeconds < 0 || req.HealthTimeoutSeconds < 0 {
+		return nil, fmt.Errorf("batch_size and timeouts must not be negative")
+	}
+
+	spec, err := loadStackSpec(req.Stack)
+	if err != nil {
+		return nil, err
+	}
+	if req.HealthTimeoutSeconds == 0 {
+		req.HealthTimeoutSeconds = spec.HealthTimeout
+	}
+
+	all := s.judgehostNodes(spec)
+	if len(req.Nodes) > 0 {
+		known := make(map[string]bool, len(all))
+		for _, node := range all {
+			known[node] = true
+		}
+		for _, node := range req.Nodes {
+			if !known[node] {
+				return nil, fmt.Errorf("node %s is not a judgehost node of stack %s", node, req.Stack)
+			}
+		}
+	}
+
+	s.rolloutsMu.Lock()
+	prev, ok := s.rollouts[req.Stack]
+	s.rolloutsMu.Unlock()
+	if ok {
+		if st := prev.snapshot(); st.State == RolloutHalted {
+			return nil, fmt.Errorf("conflict: stack %s has a halted rollout, resume or roll it back first", req.Stack)
+		}
+	}
+
+	nodes := req.Nodes
+	if len(nodes) == 0 {
+		nodes = all
+	}
+	for _, node := range nodes {
+		if !s.IsNodeConnected(node) {
+			return nil, fmt.Errorf("node %s is not connected", node)
+		}
+	}
+	current, err := s.rolloutContainers(spec, nodes, "")
+	if err != nil {
+		return nil, err
+	}
+	if len(current) == 0 {
+		return nil, fmt.Errorf("stack %s has no judgehosts on the selected nodes", req.Stack)
+	}
+	actual, errs := s.stackContainersOnNodes(req.Stack)
+	for _, node := range nodes {
+		if err := errs[node]; err != nil {
+			return nil, fmt.Errorf("node %s: %w", node, err)
+		}
+	}
+	running := make(map[string]bool)
+	for node, containers := range actual {
+		for _, c := range containers {
+			running[node+"/"+c.Name] = c.State == "running"
+		}
+	}
+
+	op, err := s.beginStackOperation(req.Stack, "rollout")
+	if err != nil {
+		return nil, err
+	}
+
+	r := &rolloutRun{status: Rollout{
+		RolloutRequest: req,
+		State:          RolloutRunning,
+		Started:        time.Now(),
+		Targets:        make([]RolloutTarget, 0, len(current)),
+	}}
+	for _, sc := range current {
+		key := sc.NodeID + "/" + sc.Spec.Name
+		r.status.Targets = append(r.status.Targets, RolloutTarget{
+			NodeID:        sc.NodeID,
+			Container:     sc.Spec.Name,
+			Hostname:      judgehostName(sc.Spec),
+			PreviousImage: sc.Spec.Image,
+			Status:        RolloutTargetPending,
+			WasRunning:    running[key],
+		})
+	}
+	r.update(func(*Rollout) {})
+
+	s.rolloutsMu.Lock()
+	s.rollouts[req.Stack] = r
+	s.rolloutsMu.Unlock()
+
+	go s.runRollout(r, op)
+	return r.snapshot(), nil
+}
+
+// ResumeRollout 继续已暂停的 rollout，失败和未完成的容器重新开始
+func (s *DomclusterServer) ResumeRollout(stack string) (*Rollout, error) {
+	r, err := s.haltedRollout(stack)
+	if err != nil {
+		return nil, err
+	}
+	op, err := s.beginStackOperation(stack, "rollout")
+	if err != nil {
+		return nil, err
+	}
+
+	r.update(func(st *Rollout) {
+		st.State = RolloutRunning
+		st.Error = ""
+		st.Finished = time.Time{}
+		for i := range st.Targets {
+			switch st.Targets[i].Status {
+			case RolloutTargetDraining, RolloutTargetUpdating, RolloutTargetFailed:
+				st.Targets[i].Status = RolloutTargetPending
+			}
+		}
+	})
+	go s.runRollout(r, op)
+	return r.snapshot(), nil
+}
+
+// RollbackRollout 回滚已暂停的 rollout 中已更新的容器
+func (s *DomclusterServer) RollbackRollout(stack string) (*Rollout, error) {
+	r, err := s.haltedRollout(stack)
+	if err != nil {
+		return nil, err
+	}
+	op, err := s.beginStackOperation(stack, "rollback")
+	if err != nil {
+		return nil, err
+	}
+
+	go func() {
+		err := s.rollbackRollout(r, op)
+		op.finish(err)
+	}()
+	return r.snapshot(), nil
+}
+
+// GetRollout 查询栈最近一次 rollout 的状态
+func (s *DomclusterServer) GetRollout(stack string) (*Rollout, error) {
+	s.rolloutsMu.Lock()
+	r, ok := s.rollouts[stack]
+	s.rolloutsMu.Unlock()
+	if !ok {
+		return nil, fmt.Errorf("no rollout found for stack %s", stack)
+	}
+	return r.snapshot(), nil
+}
+
+// haltedRollout 返回已暂停的 rollout
+func (s *DomclusterServer) haltedRollout(stack string) (*rolloutRun, error) {
+	s.rolloutsMu.Lock()
+	r, ok := s.rollouts[stack]
+	s.rolloutsMu.Unlock()
+	if !ok {
+		return nil, fmt.Errorf("no rollout found for stack %s", stack)
+	}
+	if st := r.snapshot(); st.State != RolloutHalted {
+		return nil, fmt.Errorf("conflict: rollout of stack %s is %s, not halted", stack, st.State)
+	}
+	return r, nil
+}
+
+// rolloutContainers 返回栈在节点上规划的 judgehost 容器，image 不为空时使用该镜像
+func (s *DomclusterServer) rolloutContainers(spec *StackSpec, nodes []string, image string) ([]stackContainer, error) {
+	if image != "" {
+		copied := *spec
+		copied.Judgehosts.NodeImages = make(map[string]string, len(nodes)+len(spec.Judgehosts.NodeImages))
+		for node, img := range spec.Judgehosts.NodeImages {
+			copied.Judgehosts.NodeImages[node] = img
+		}
+		for _, node := range nodes {
+			copied.Judgehosts.NodeImages[node] = image
+		}
+		spec = &copied
+	}
+
+	slots, errs := s.judgehostSlots(spec, nodes)
+	for _, node := range nodes {
+		if err := errs[node]; err != nil {
+			return nil, err
+		}
+	}
+
+	var result []stackContainer
+	for _, sc := range spec.containers(slots) {
+		if sc.Component == StackComponentJudgehost {
+			result = append(result, sc)
+		}
+	}
+	return result, nil
+}
+
+// runRollout 分批更新尚未完成的容器
+func (s *DomclusterServer) runRollout(r *rolloutRun, op *stackOperation) {
+	st := r.snapshot()
+	err := s.rolloutBatches(r, st, op)
+	if err == nil {
+		err = s.commitRollout(st)
+		if err == nil {
+			r.update(func(st *Rollout) {
+				st.State = RolloutSucceeded
+				st.Finished = time.Now()
+			})
+			zap.L().Sugar().Infof("Rollout of stack %s to %s succeeded", st.Stack, st.Image)
+			op.finish(nil)
+			return
+		}
+	}
+
+	zap.L().Sugar().Errorf("Rollout of stack %s to %s failed: %v", st.Stack, st.Image, err)
+	r.update(func(st *Rollout) {
+		st.State = RolloutHalted
+		st.Error = err.Error()
+		st.Finished = time.Now()
+	})
+	if !st.NoRollback {
+		if rbErr := s.rollbackRollout(r, op); rbErr != nil {
+			err = fmt.Errorf("%v; rollback failed: %w", err, rbErr)
+		}
+	}
+	op.finish(err)
+}
+
+// rolloutBatches 按批次更新容器，任一容器失败即停止
+func (s *DomclusterServer) rolloutBatches(r *rolloutRun, st *Rollout, op *stackOperation) error {
+	spec, err := loadStackSpec(st.Stack)
+	if err != nil {
+		return err
+	}
+	nodes := targetNodes(st.Targets)
+	desired, err := s.rolloutContainers(spec, nodes, st.Image)
+	if err != nil {
+		return err
+	}
+	byKey := make(map[string]stackContainer, len(desired))
+	for _, sc := range desired {
+		byKey[sc.NodeID+"/"+sc.Spec.Name] = sc
+	}
+
+	var pending []int
+	for i, t := range st.Targets {
+		if t.Status == RolloutTargetPending {
+			pending = append(pending, i)
+		}
+	}
+
+	drainTimeout := time.Duration(st.DrainTimeoutSeconds) * time.Second
+	healthTimeout := time.Duration(st.HealthTimeoutSeconds) * time.Second
+	for start := 0; start < len(pending); start += st.BatchSize {
+		end := start + st.BatchSize
+		if end > len(pending) {
+			end = len(pending)
+		}
+
+		var wg sync.WaitGroup
+		var mu sync.Mutex
+		var failed []string
+		for _, i := range pending[start:end] {
+			t := st.Targets[i]
+			sc, ok := byKey[t.NodeID+"/"+t.Container]
+			if !ok {
+				// 核心选择等变化后容器不再属于栈，留给 stack up 处理
+				r.setTarget(i, RolloutTargetSkipped, fmt.Errorf("no longer part of the stack"))
+				continue
+			}
+			wg.Add(1)
+			go func(i int, t RolloutTarget, sc stackContainer) {
+				defer wg.Done()
+				if err := s.rolloutTarget(r, i, t, sc, drainTimeout, healthTimeout, op); err != nil {
+					mu.Lock()
+					failed = append(failed, fmt.Sprintf("%s/%s: %v", t.NodeID, t.Container, err))
+					mu.Unlock()
+				}
+			}(i, t, sc)
+		}
+		wg.Wait()
+
+		if len(failed) > 0 {
+			return fmt.Errorf("%d judgehosts failed: %s", len(failed), strings.Join(failed, "; "))
+		}
+	}
+	return nil
+}
+
+// rolloutTarget 排空并更新一个 judgehost 容器
+func (s *DomclusterServer) rolloutTarget(r *rolloutRun, i int, t RolloutTarget, sc stackContainer,
+	drainTimeout, healthTimeout time.Duration, op *stackOperation) error {
+	// 已停止的容器（如被自动扩缩容停止）只更新，不启动
+	if !t.WasRunning {
+		r.setTarget(i, RolloutTargetUpdating, nil)
+		ctx, cancel := context.WithTimeout(context.Background(), stackCreateTimeout)
+		_, err := s.RecreateContainer(ctx, sc.NodeID, sc.Spec, RecreateOptions{NoStart: true, OnlyIfChanged: true, StopTimeout: rolloutStopTimeout})
+		cancel()
+		if err != nil {
+			op.step(sc, StackActionFailed, err)
+			r.setTarget(i, RolloutTargetFailed, err)
+			return err
+		}
+		op.step(sc, StackActionRecreated, nil)
+		r.setTarget(i, RolloutTargetUpdated, nil)
+		return nil
+	}
+
+	r.setTarget(i, RolloutTargetDraining, nil)
+	if err := s.drainJudgehost(t.Hostname, drainTimeout); err != nil {
+		op.step(sc, StackActionFailed, err)
+		r.setTarget(i, RolloutTargetFailed, err)
+		return err
+	}
+	op.step(sc, StackActionDrained, nil)
+
+	r.setTarget(i, RolloutTargetUpdating, nil)
+	recreated := time.Now()
+	ctx, cancel := context.WithTimeout(context.Background(), stackCreateTimeout)
+	_, err := s.RecreateContainer(ctx, sc.NodeID, sc.Spec, RecreateOptions{OnlyIfChanged: true, StopTimeout: rolloutStopTimeout})
+	cancel()
+	if err != nil {
+		op.step(sc, StackActionFailed, err)
+		r.setTarget(i, RolloutTargetFailed, err)
+		return err
+	}
+	op.step(sc, StackActionRecreated, nil)
+
+	err = s.waitContainerHealthy(sc, healthTimeout)
+	if err == nil {
+		s.autoscaleSetEnabled(t.Hostname, true)
+		err = s.waitJudgehostPolling(t.Hostname, recreated, healthTimeout)
+	}
+	if err != nil {
+		op.step(sc, StackActionFailed, err)
+		r.setTarget(i, RolloutTargetFailed, err)
+		return err
+	}
+	op.step(sc, StackActionHealthy, nil)
+	r.setTarget(i, RolloutTargetUpdated, nil)
+	return nil
+}
+
+// drainJudgehost 在 DomJudge 中禁用 judgehost 并等待当前评测完成，未配置 DomJudge 时直接返回
+func (s *DomclusterServer) drainJudgehost(hostname string, timeout time.Duration) error {
+	client, err := s.domjudgeClient()
+	if err != nil {
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), stackQueryTimeout)
+	_, err = client.SetJudgehostEnabled(ctx, hostname, false)
+	cancel()
+	if err != nil {
+		// 从未连接过 domserver 的 judgehost 没有在评测，无需等待
+		var apiErr *domjudge.APIError
+		if errors.As(err, &apiErr) && apiErr.StatusCode == http.StatusNotFound {
+			return nil
+		}
+		return fmt.Errorf("failed to disable judgehost in DomJudge: %w", err)
+	}
+
+	deadline := time.Now().Add(timeout)
+	for {
+		ctx, cancel := context.WithTimeout(context.Background(), stackQueryTimeout)
+		hosts, err := s.ListJudgehosts(ctx, true)
+		cancel()
+		if err == nil {
+			busy := false
+			for _, h := range hosts {
+				if h.Hostname == hostname && h.CurrentJudging != nil {
+					busy = true
+				}
+			}
+			if !busy {
+				return nil
+			}
+		}
+
+		if time.Now().After(deadline) {
+			s.autoscaleSetEnabled(hostname, true)
+			if err != nil {
+				return fmt.Errorf("not drained after %s: %w", timeout, err)
+			}
+			return fmt.Errorf("still judging after %s", timeout)
+		}
+		time.Sleep(rolloutDrainPollInterval)
+	}
+}
+
+// waitJudgehostPolling 配置了 DomJudge 时等待新容器中的 judgedaemon 向 domserver 轮询
+func (s *DomclusterServer) waitJudgehostPolling(hostname string, since time.Time, timeout time.Duration) error {
+	if _, err := s.domjudgeClient(); err != nil {
+		return nil
+	}
+
+	deadline := time.Now().Add(timeout)
+	for {
+		ctx, cancel := context.WithTimeout(context.Background(), stackQueryTimeout)
+		hosts, err := s.ListJudgehosts(ctx, true)
+		cancel()
+		if err == nil {
+			for _, h := range hosts {
+				if h.Hostname == hostname && h.Polltime != nil && h.Polltime.After(since) {
+					return nil
+				}
+			}
+		}
+
+		if time.Now().After(deadline) {
+			return fmt.Errorf("judgehost %s did not poll DomJudge within %s", hostname, timeout)
+		}
+		time.Sleep(rolloutDrainPollInterval)
+	}
+}
+
+// commitRollout 将新镜像写入栈声明，之后的 stack up 沿用
+func (s *DomclusterServer) commitRollout(st *Rollout) error {
+	spec, err := loadStackSpec(st.Stack)
+	if err != nil {
+		return err
+	}
+
+	if len(st.Nodes) == 0 {
+		spec.Judgehosts.Image = st.Image
+		spec.Judgehosts.NodeImages = nil
+	} else {
+		if spec.Judgehosts.NodeImages == nil {
+			spec.Judgehosts.NodeImages = make(map[string]string)
+		}
+		for _, node := range st.Nodes {
+			spec.Judgehosts.NodeImages[node] = st.Image
+		}
+	}
+	if err := saveStackSpec(spec); err != nil {
+		return fmt.Errorf("failed to save stack: %w", err)
+	}
+	s.refreshStackDesired(st.Stack)
+	return nil
+}
+
+// rollbackRollout 将已更新或更新失败的容器恢复为栈声明中的镜像
+func (s *DomclusterServer) rollbackRollout(r *rolloutRun, op *stackOperation) error {
+	r.update(func(st *Rollout) {
+		st.State = RolloutRollingBack
+	})
+	st := r.snapshot()
+
+	// rollout 成功前不会修改栈声明，按当前声明重建即为原来的容器
+	spec, err := loadStackSpec(st.Stack)
+	var previous []stackContainer
+	if err == nil {
+		previous, err = s.rolloutContainers(spec, targetNodes(st.Targets), "")
+	}
+	if err != nil {
+		r.update(func(st *Rollout) {
+			st.State = RolloutHalted
+			st.Error = "rollback failed: " + err.Error()
+		})
+		return err
+	}
+	byKey := make(map[string]stackContainer, len(previous))
+	for _, sc := range previous {
+		byKey[sc.NodeID+"/"+sc.Spec.Name] = sc
+	}
+
+	healthTimeout := time.Duration(st.HealthTimeoutSeconds) * time.Second
+	var failed []string
+	for i, t := range st.Targets {
+		switch t.Status {
+		case RolloutTargetUpdated, RolloutTargetFailed, RolloutTargetDraining, RolloutTargetUpdating:
+		default:
+			continue
+		}
+		sc, ok := byKey[t.NodeID+"/"+t.Container]
+		if !ok {
+			continue
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), stackCreateTimeout)
+		_, err := s.RecreateContainer(ctx, sc.NodeID, sc.Spec, RecreateOptions{
+			NoStart:       !t.WasRunning,
+			OnlyIfChanged: true,
+			StopTimeout:   rolloutStopTimeout,
+		})
+		cancel()
+		if err == nil && t.WasRunning {
+			err = s.waitContainerHealthy(sc, healthTimeout)
+		}
+		if err != nil {
+			op.step(sc, StackActionFailed, err)
+			r.update(func(st *Rollout) { st.Targets[i].Error = "rollback: " + err.Error() })
+			failed = append(failed, fmt.Sprintf("%s/%s: %v", t.NodeID, t.Container, err))
+			continue
+		}
+		if t.WasRunning {
+			s.autoscaleSetEnabled(t.Hostname, true)
+		}
+		op.step(sc, StackActionRolledBack, nil)
+		r.setTarget(i, RolloutTargetRolledBack, nil)
+	}
+
+	if len(failed) > 0 {
+		err := fmt.Errorf("failed to roll back %d judgehosts: %s", len(failed), strings.Join(failed, "; "))
+		r.update(func(st *Rollout) {
+			st.State = RolloutHalted
+			st.Error = err.Error()
+			st.Finished = time.Now()
+		})
+		return err
+	}
+
+	r.update(func(st *Rollout) {
+		st.State = RolloutRolledBack
+		st.Finished = time.Now()
+	})
+	zap.L().Sugar().Infof("Rollout of stack %s rolled back", st.Stack)
+	return nil
+}
+
+// targetNodes 返回容器所在的节点，保持首次出现的顺序
+func targetNodes(targets []RolloutTarget) []string {
+	seen := make(map[string]bool)
+	var nodes []string
+	for _, t := range targets {
+		if !seen[t.NodeID] {
+			seen[t.NodeID] = true
+			nodes = append(nodes, t.NodeID)
+		}
+	}
+	return nodes
+}
@@ -1,26 +1,77 @@
 package services
 
 import (
+	"d8rctl/config"
+	"d8rctl/services/agentconfig"
+	"d8rctl/services/logstore"
 	"d8rctl/services/monitor"
+	"d8rctl/services/recording"
 	"fmt"
 	pb "domcluster/api/proto"
 	"go.uber.org/zap"
+	"path/filepath"
 	"sync"
 	"time"
 )
 
+// 集中日志的保留策略
+const (
+	logRetention = 7 * 24 * time.Hour
+	logMaxBytes  = 2 << 30
+)
+
+// 终端录像的保留策略
+const (
+	recordingRetention = 90 * 24 * time.Hour
+	recordingMaxBytes  = 5 << 30
+)
+
+// nodeStream 节点的发布流，gRPC 流不支持并发 Send，因此需要加锁
+type nodeStream struct {
+	stream pb.DomclusterService_PublishServer
+	mu     sync.Mutex
+}
+
+// Send 发送消息到节点
+func (ns *nodeStream) Send(resp *pb.PublishResponse) error {
+	ns.mu.Lock()
+	defer ns.mu.Unlock()
+	return ns.stream.Send(resp)
+}
+
 // DomclusterServer Domcluster 服务端
 type DomclusterServer struct {
 	pb.UnimplementedDomclusterServiceServer
 	nodeManager              *NodeManager
 	monitor                  *monitor.Monitor
+	agentConfig              *agentconfig.Store
+	logStore                 *logstore.Store
+	recordings               *recording.Store
 	dockerResponses          map[string]chan *DockerResult
 	dockerResponseTimestamps map[string]time.Time
 	dockerResponsesMu        sync.RWMutex
 	shellResponses           map[string]chan []byte
 	shellResponseTimestamps  map[string]time.Time
 	shellResponsesMu         sync.RWMutex
-	streams                  map[string]pb.DomclusterService_PublishServer
+	queryResponses           map[string]chan []byte
+	queryResponseTimestamps  map[string]time.Time
+	queryResponsesMu         sync.Mutex
+	streams                  map[string]*nodeStream
+	agentStreams             map[string]*AgentStream
+	agentStreamsMu           sync.Mutex
+	imageDists               map[string]*imageDistribution
+	imageDistsMu             sync.Mutex
+	stackOps                 map[string]*stackOperation
+	stackOpsMu               sync.Mutex
+	domjudge                 *domjudgeIntegration
+	autoscalers              map[string]*autoscaler
+	autoscalersMu            sync.Mutex
+	rollouts                 map[string]*rolloutRun
+	rolloutsMu               sync.Mutex
+	desired                  map[string]*NodeDesiredState
+	desiredReports           map[string]*DesiredReport
+	desiredMu                sync.Mutex
+	desiredPushMu            sync.Mutex
 	streamsMu                sync.RWMutex
 	cleanupDone              chan struct{}
 }
@@ -30,20 +81,35 @@ func NewDomclusterServer() *DomclusterServer {
 	s := &DomclusterServer{
 		nodeManager:              NewNodeManager(),
 		monitor:                  monitor.NewMonitor(),
+		agentConfig:              agentconfig.NewStore(filepath.Join(config.GetDataDir(), "agent_config.json")),
+		logStore:                 logstore.NewStore(filepath.Join(config.GetDataDir(), "logs"), logRetention, logMaxBytes),
+		recordings:               recording.NewStore(filepath.Join(config.GetDataDir(), "recordings"), recordingRetention, recordingMaxBytes),
 		dockerResponses:          make(map[string]chan *DockerResult),
 		dockerResponseTimestamps: make(map[string]time.Time),
 		shellResponses:           make(map[string]chan []byte),
 		shellResponseTimestamps:  make(map[string]time.Time),
-		streams:                  make(map[string]pb.DomclusterService_PublishServer),
+		queryResponses:           make(map[string]chan []byte),
+		queryResponseTimestamps:  make(map[string]time.Time),
+		streams:                  make(map[string]*nodeStream),
+		agentStreams:             make(map[string]*AgentStream),
+		imageDists:               make(map[string]*imageDistribution),
+		stackOps:                 make(map[string]*stackOperation),
+		domjudge:                 newDomjudgeIntegration(domjudgeConfigPath()),
+		autoscalers:              loadAutoscalers(),
+		rollouts:                 loadRollouts(),
+		desired:                  loadDesiredStates(),
+		desiredReports:           make(map[string]*DesiredReport),
 		cleanupDone:              make(chan struct{}),
 	}
 	go s.cleanupExpiredResponses()
+	go s.autoscaleLoop()
 	return s
 }
 
 // Publish 处理发布流
 func (s *DomclusterServer) Publish(stream pb.DomclusterService_PublishServer) error {
 	var currentIssuer string
+	ns := &nodeStream{stream: stream}
 
 	for {
 		req, err := stream.Recv()
@@ -53,6 +119,7 @@ func (s *DomclusterServer) Publish(stream pb.DomclusterService_PublishServer) er
 				s.streamsMu.Lock()
 				delete(s.streams, currentIssuer)
 				s.streamsMu.Unlock()
+				s.closeNodeStreams(currentIssuer)
 				zap.L().Sugar().Infof("Removed stream for issuer: %s", currentIssuer)
 			}
 			return err
@@ -63,7 +130,7 @@ func (s *DomclusterServer) Publish(stream pb.DomclusterService_PublishServer) er
 		currentIssuer = req.Issuer
 
 		s.streamsMu.Lock()
-		s.streams[req.Issuer] = stream
+		s.streams[req.Issuer] = ns
 		s.streamsMu.Unlock()
 
 		if req.Cmd == "docker_response" {
@@ -76,9 +143,19 @@ func (s *DomclusterServer) Publish(stream pb.DomclusterService_PublishServer) er
 			continue
 		}
 
+		if req.Cmd == "query_response" {
+			s.handleQueryResponse(req)
+			continue
+		}
+
+		if req.Cmd == "stream_data" {
+			s.handleStreamData(req)
+			continue
+		}
+
 		resp := s.handleRequest(req)
 
-		if err := stream.Send(resp); err != nil {
+		if err := ns.Send(resp); err != nil {
 			zap.L().Sugar().Errorf("Publish send error: %v", err)
 			s.streamsMu.Lock()
 			delete(s.streams, req.Issuer)
@@ -94,6 +171,29 @@ func (s *DomclusterServer) GetNodeManager() *NodeManager {
 	return s.nodeManager
 }
 
+// GetLogStore 获取集中日志存储
+func (s *DomclusterServer) GetLogStore() *logstore.Store {
+	return s.logStore
+}
+
+// GetRecordingStore 获取终端录像存储
+func (s *DomclusterServer) GetRecordingStore() *recording.Store {
+	return s.recordings
+}
+
+// GetAgentConfig 获取节点配置存储
+func (s *DomclusterServer) GetAgentConfig() *agentconfig.Store {
+	return s.agentConfig
+}
+
+// IsNodeConnected 检查节点是否有活跃的发布流
+func (s *DomclusterServer) IsNodeConnected(nodeID string) bool {
+	s.streamsMu.RLock()
+	defer s.streamsMu.RUnlock()
+	_, ok := s.streams[nodeID]
+	return ok
+}
+
 // GetMonitor 获取监控服务
 func (s *DomclusterServer) GetMonitor() *monitor.Monitor {
 	return s.monitor
@@ -135,6 +235,8 @@ func (s *DomclusterServer) handleDockerResponse(req *pb.PublishRequest) {
 }
 
 // SendToNode 发送命令到指定节点
+//
+// 节点按 Reporter 字段将消息路由到对应命令的处理器，因此这里填入命令名。
 func (s *DomclusterServer) SendToNode(nodeID, cmd, reqID string, data []byte) error {
 	s.streamsMu.RLock()
 	stream, ok := s.streams[nodeID]
@@ -145,7 +247,7 @@ func (s *DomclusterServer) SendToNode(nodeID, cmd, reqID string, data []byte) er
 	}
 
 	resp := &pb.PublishResponse{
-		Reporter: "server",
+		Reporter: cmd,
 		ReqId:    reqID,
 		Status:   0,
 		Data:     data,
@@ -195,6 +297,17 @@ func (s *DomclusterServer) cleanupOldResponses() {
 			zap.L().Sugar().Infof("Cleaned up expired shell response for reqID: %s", reqID)
 		}
 	}
+
+	// Clean query responses
+	s.queryResponsesMu.Lock()
+	defer s.queryResponsesMu.Unlock()
+	for reqID, timestamp := range s.queryResponseTimestamps {
+		if now.Sub(timestamp) > expiryDuration {
+			delete(s.queryResponses, reqID)
+			delete(s.queryResponseTimestamps, reqID)
+			zap.L().Sugar().Infof("Cleaned up expired query response for reqID: %s", reqID)
+		}
+	}
 }
 
 // RegisterShellResponse 注册 Shell 响应处理器
@@ -233,4 +346,6 @@ func (s *DomclusterServer) Shutdown() {
 	if s.cleanupDone != nil {
 		close(s.cleanupDone)
 	}
+	s.logStore.Stop()
+	s.recordings.Stop()
 }
\ No newline at end of file
@@ -0,0 +1,565 @@
+package services
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"d8rctl/config"
+	"d8rctl/services/monitor"
+)
+
+// 容器标签，用于找出属于某个栈的容器；domcluster. 前缀由节点保留，控制端的标签使用 d8rctl. 前缀
+const (
+	LabelStack          = "d8rctl.stack"
+	LabelStackComponent = "d8rctl.stack.component"
+)
+
+// 栈的组件，按部署顺序排列
+const (
+	StackComponentMariaDB   = "mariadb"
+	StackComponentDomserver = "domserver"
+	StackComponentJudgehost = "judgehost"
+)
+
+// 栈组件的默认值
+const (
+	defaultMariaDBImage     = "mariadb:11.4"
+	defaultDomserverImage   = "domjudge/domserver:latest"
+	defaultJudgehostImage   = "domjudge/judgehost:latest"
+	defaultMariaDBPort      = 3306
+	defaultDomserverPort    = 80
+	defaultMaxConnections   = 1000
+	defaultStackDataDir     = "/var/lib/domcluster/stacks"
+	defaultStackHealthWait  = 300
+	defaultJudgehostPerNode = 1
+	defaultReserveCores     = 1
+)
+
+var stackNamePattern = regexp.MustCompile(`^[a-z0-9][a-z0-9_.-]*$`)
+
+// StackSpec DomJudge 栈的声明：一个 MariaDB、一个或多个 domserver 以及各判题节点上的 judgehost 容器
+type StackSpec struct {
+	Name       string          `json:"name"`
+	Settings   StackSettings   `json:"settings"`
+	MariaDB    StackMariaDB    `json:"mariadb"`
+	Domserver  StackDomserver  `json:"domserver"`
+	Judgehosts StackJudgehosts `json:"judgehosts"`
+	// HealthTimeout 等待每个容器健康的秒数，默认 300
+	HealthTimeout int `json:"health_timeout,omitempty"`
+}
+
+// StackSettings 各组件共用的设置
+type StackSettings struct {
+	DBName         string `json:"db_name,omitempty"`
+	DBUser         string `json:"db_user,omitempty"`
+	DBPassword     string `json:"db_password"`
+	DBRootPassword string `json:"db_root_password"`
+	// BaseURL judgehost 访问 domserver 的地址，如 http://10.0.0.2/
+	BaseURL string `json:"base_url"`
+	// JudgehostUser 与 JudgehostPassword 为 domserver 中 judgehost 账号的凭据
+	JudgehostUser     string `json:"judgehost_user,omitempty"`
+	JudgehostPassword string `json:"judgehost_password"`
+	Timezone          string `json:"timezone,omitempty"`
+}
+
+// StackMariaDB 数据库组件
+type StackMariaDB struct {
+	Node  string `json:"node"`
+	Image string `json:"image,omitempty"`
+	// Host domserver 连接数据库使用的地址，通常为数据库节点的 IP
+	Host string `json:"host"`
+	Port int    `json:"port,omitempty"`
+	// DataDir 节点上保存数据库文件的目录，默认 /var/lib/domcluster/stacks/<name>/mariadb
+	DataDir        string           `json:"data_dir,omitempty"`
+	MaxConnections int              `json:"max_connections,omitempty"`
+	Resources      ResourceSpec     `json:"resources,omitempty"`
+	Healthcheck    *HealthcheckSpec `json:"healthcheck,omitempty"`
+}
+
+// StackDomserver domserver 组件
+type StackDomserver struct {
+	Nodes []string `json:"nodes"`
+	Image string   `json:"image,omitempty"`
+	// Port 节点上映射到 domserver 80 端口的端口
+	Port        int              `json:"port,omitempty"`
+	Resources   ResourceSpec     `json:"resources,omitempty"`
+	Healthcheck *HealthcheckSpec `json:"healthcheck,omitempty"`
+}
+
+// StackJudgehosts judgehost 组件，节点可以直接列出，也可以按角色和标签选择
+type StackJudgehosts struct {
+	Nodes  []string          `json:"nodes,omitempty"`
+	Role   string            `json:"role,omitempty"`
+	Labels map[string]string `json:"labels,omitempty"`
+	// PerNode 每个节点上的 judgehost 容器数量
+	PerNode int `json:"per_node,omitempty"`
+	// PerCore 为每个选定的物理核心创建一个绑定到该核心的 judgehost，设置后忽略 PerNode
+	PerCore bool `json:"per_core,omitempty"`
+	// ReserveCores 未单独选择核心的节点上留给系统的物理核心数，从编号最小的核心开始保留，默认 1
+	ReserveCores *int `json:"reserve_cores,omitempty"`
+	// Cores 各节点选定的核心，取值为逻辑 CPU 列表如 "2-7"，列出 SMT 兄弟线程中的任意一个即选中整个物理核心
+	Cores map[string]string `json:"cores,omitempty"`
+	// PinSiblings 同时绑定核心的 SMT 兄弟线程，使 judgehost 独占整个物理核心
+	PinSiblings bool   `json:"pin_siblings,omitempty"`
+	Image       string `json:"image,omitempty"`
+	// NodeImages 各节点单独使用的镜像，由只更新部分节点的 rollout 写入
+	NodeImages  map[string]string `json:"node_images,omitempty"`
+	Resources   ResourceSpec      `json:"resources,omitempty"`
+	Healthcheck *HealthcheckSpec  `json:"healthcheck,omitempty"`
+}
+
+// judgehostSlot 一个 judgehost 容器的位置
+type judgehostSlot struct {
+	NodeID string
+	// DaemonID judgedaemon 的编号，judgedaemon 会绑定到同编号的 CPU
+	DaemonID int
+	// CPUSet 容器绑定的 CPU，为空时使用 Resources 中的设置
+	CPUSet string
+	// Suffix 容器名的后缀
+	Suffix string
+}
+
+// stackContainer 栈中的一个容器及其所在节点
+type stackContainer struct {
+	Component string
+	NodeID    string
+	Spec      *ContainerSpec
+}
+
+// applyDefaults 填充未设置的字段
+func (s *StackSpec) applyDefaults() {
+	if s.HealthTimeout == 0 {
+		s.HealthTimeout = defaultStackHealthWait
+	}
+
+	st := &s.Settings
+	if st.DBName == "" {
+		st.DBName = "domjudge"
+	}
+	if st.DBUser == "" {
+		st.DBUser = "domjudge"
+	}
+	if st.JudgehostUser == "" {
+		st.JudgehostUser = "judgehost"
+	}
+
+	db := &s.MariaDB
+	if db.Image == "" {
+		db.Image = defaultMariaDBImage
+	}
+	if db.Port == 0 {
+		db.Port = defaultMariaDBPort
+	}
+	if db.DataDir == "" {
+		db.DataDir = filepath.Join(defaultStackDataDir, s.Name, "mariadb")
+	}
+	if db.MaxConnections == 0 {
+		db.MaxConnections = defaultMaxConnections
+	}
+	if db.Healthcheck == nil {
+		// 官方镜像自带 healthcheck.sh，InnoDB 初始化完成前不会通过
+		db.Healthcheck = &HealthcheckSpec{
+			Test:        []string{"CMD", "healthcheck.sh", "--connect", "--innodb_initialized"},
+			Interval:    "10s",
+			Timeout:     "5s",
+			StartPeriod: "30s",
+			Retries:     5,
+		}
+	}
+
+	ds := &s.Domserver
+	if ds.Image == "" {
+		ds.Image = defaultDomserverImage
+	}
+	if ds.Port == 0 {
+		ds.Port = defaultDomserverPort
+	}
+	if ds.Healthcheck == nil {
+		// 首次启动需要初始化数据库，web 服务监听后才算就绪
+		ds.Healthcheck = &HealthcheckSpec{
+			Test:        []string{"CMD-SHELL", "bash -c '</dev/tcp/127.0.0.1/80' || exit 1"},
+			Interval:    "10s",
+			Timeout:     "5s",
+			StartPeriod: "120s",
+			Retries:     5,
+		}
+	}
+
+	jh := &s.Judgehosts
+	if jh.Image == "" {
+		jh.Image = defaultJudgehostImage
+	}
+	if jh.PerNode == 0 {
+		jh.PerNode = defaultJudgehostPerNode
+	}
+	if jh.ReserveCores == nil {
+		reserve := defaultReserveCores
+		jh.ReserveCores = &reserve
+	}
+}
+
+// Validate 填充默认值并检查声明是否完整
+func (s *StackSpec) Validate() error {
+	if !stackNamePattern.MatchString(s.Name) {
+		return fmt.Errorf("invalid stack name %q", s.Name)
+	}
+	s.applyDefaults()
+
+	st := s.Settings
+	if st.DBPassword == "" || st.DBRootPassword == "" {
+		return fmt.Errorf("settings.db_password and settings.db_root_password are required")
+	}
+	if st.JudgehostPassword == "" {
+		return fmt.Errorf("settings.judgehost_password is required")
+	}
+	if u, err := url.Parse(st.BaseURL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("settings.base_url must be an http(s) URL")
+	}
+
+	if s.MariaDB.Node == "" {
+		return fmt.Errorf("mariadb.node is required")
+	}
+	if s.MariaDB.Host == "" {
+		return fmt.Errorf("mariadb.host is required")
+	}
+	if !filepath.IsAbs(s.MariaDB.DataDir) {
+		return fmt.Errorf("mariadb.data_dir must be absolute")
+	}
+	if len(s.Domserver.Nodes) == 0 {
+		return fmt.Errorf("domserver.nodes is required")
+	}
+
+	jh := s.Judgehosts
+	if len(jh.Nodes) == 0 && jh.Role == "" && len(jh.Labels) == 0 {
+		return fmt.Errorf("judgehosts needs nodes, role or labels")
+	}
+	if jh.PerNode < 0 || *jh.ReserveCores < 0 {
+		return fmt.Errorf("judgehosts.per_node and judgehosts.reserve_cores must not be negative")
+	}
+	if len(jh.Cores) > 0 && !jh.PerCore {
+		return fmt.Errorf("judgehosts.cores requires per_core")
+	}
+	for node, list := range jh.Cores {
+		if _, err := parseCPUList(list); err != nil {
+			return fmt.Errorf("judgehosts.cores[%s]: %w", node, err)
+		}
+	}
+	for _, port := range []int{s.MariaDB.Port, s.Domserver.Port} {
+		if port <= 0 || port > 65535 {
+			return fmt.Errorf("invalid port %d", port)
+		}
+	}
+	return nil
+}
+
+// judgehostNodes 返回运行 judgehost 的节点，按角色和标签选择时只包含已连接的节点
+func (s *DomclusterServer) judgehostNodes(spec *StackSpec) []string {
+	jh := spec.Judgehosts
+	if len(jh.Nodes) > 0 {
+		nodes := append([]string(nil), jh.Nodes...)
+		sort.Strings(nodes)
+		return nodes
+	}
+	return s.SelectNodes(jh.Role, jh.Labels)
+}
+
+// judgehostSlots 计算各节点上的 judgehost 容器，按核心部署时无法确定核心的节点记录在返回的错误中
+func (s *DomclusterServer) judgehostSlots(spec *StackSpec, nodes []string) ([]judgehostSlot, map[string]error) {
+	jh := spec.Judgehosts
+	var slots []judgehostSlot
+	errs := make(map[string]error)
+
+	for _, node := range nodes {
+		if !jh.PerCore {
+			for i := 0; i < jh.PerNode; i++ {
+				slots = append(slots, judgehostSlot{NodeID: node, DaemonID: i, Suffix: strconv.Itoa(i)})
+			}
+			continue
+		}
+
+		cores, err := s.selectJudgehostCores(jh, node)
+		if err != nil {
+			errs[node] = err
+			continue
+		}
+		for _, core := range cores {
+			cpuset := strconv.Itoa(core.CPU)
+			if jh.PinSiblings {
+				cpuset = formatCPUList(core.Threads)
+			}
+			slots = append(slots, judgehostSlot{
+				NodeID:   node,
+				DaemonID: core.CPU,
+				CPUSet:   cpuset,
+				Suffix:   "c" + strconv.Itoa(core.CPU),
+			})
+		}
+	}
+	return slots, errs
+}
+
+// selectJudgehostCores 按声明从节点的物理核心中选出运行 judgehost 的核心
+func (s *DomclusterServer) selectJudgehostCores(jh StackJudgehosts, node string) ([]monitor.PhysicalCore, error) {
+	status, ok := s.monitor.GetCollector().GetStatus(node)
+	if !ok || status.Host == nil || len(status.Host.Cores) == 0 {
+		return nil, fmt.Errorf("CPU topology of node %s is unknown", node)
+	}
+	cores := status.Host.Cores
+
+	list, ok := jh.Cores[node]
+	if !ok {
+		reserve := *jh.ReserveCores
+		if reserve >= len(cores) {
+			return nil, fmt.Errorf("node %s has %d cores, all reserved", node, len(cores))
+		}
+		return cores[reserve:], nil
+	}
+
+	cpus, err := parseCPUList(list)
+	if err != nil {
+		return nil, err
+	}
+	var selected []monitor.PhysicalCore
+	seen := make(map[int]bool)
+	for _, cpu := range cpus {
+		found := false
+		for _, core := range cores {
+			for _, t := range core.Threads {
+				if t != cpu {
+					continue
+				}
+				found = true
+				if !seen[core.CPU] {
+					seen[core.CPU] = true
+					selected = append(selected, core)
+				}
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("cpu %d is not online on node %s", cpu, node)
+		}
+	}
+	sort.Slice(selected, func(i, j int) bool { return selected[i].CPU < selected[j].CPU })
+	return selected, nil
+}
+
+// parseCPUList 解析 0-3,8,10-11 形式的 CPU 列表
+func parseCPUList(s string) ([]int, error) {
+	var result []int
+	for _, part := range strings.Split(s, ",") {
+		part = strings.TrimSpace(part)
+		lo, hi, isRange := strings.Cut(part, "-")
+		start, err := strconv.Atoi(lo)
+		if err != nil || start < 0 {
+			return nil, fmt.Errorf("invalid cpu list %q", s)
+		}
+		end := start
+		if isRange {
+			if end, err = strconv.Atoi(hi); err != nil || end < start {
+				return nil, fmt.Errorf("invalid cpu list %q", s)
+			}
+		}
+		for i := start; i <= end; i++ {
+			result = append(result, i)
+		}
+	}
+	return result, nil
+}
+
+// formatCPUList 将 CPU 编号格式化为 Docker cpuset 的写法
+func formatCPUList(cpus []int) string {
+	parts := make([]string, len(cpus))
+	for i, cpu := range cpus {
+		parts[i] = strconv.Itoa(cpu)
+	}
+	return strings.Join(parts, ",")
+}
+
+// stackPlan 生成栈中的全部容器，返回无法确定 judgehost 的节点及原因
+func (s *DomclusterServer) stackPlan(spec *StackSpec) ([]stackContainer, map[string]error) {
+	slots, errs := s.judgehostSlots(spec, s.judgehostNodes(spec))
+	return spec.containers(slots), errs
+}
+
+// containers 按部署顺序生成栈中的全部容器
+func (s *StackSpec) containers(judgehosts []judgehostSlot) []stackContainer {
+	st := s.Settings
+	labels := func(component string) map[string]string {
+		return map[string]string{
+			LabelStack:          s.Name,
+			LabelStackComponent: component,
+		}
+	}
+	withTimezone := func(env map[string]string) map[string]string {
+		if st.Timezone != "" {
+			env["CONTAINER_TIMEZONE"] = st.Timezone
+			env["TZ"] = st.Timezone
+		}
+		return env
+	}
+	restart := RestartPolicySpec{Name: "unless-stopped"}
+
+	var result []stackContainer
+
+	db := s.MariaDB
+	result = append(result, stackContainer{
+		Component: StackComponentMariaDB,
+		NodeID:    db.Node,
+		Spec: &ContainerSpec{
+			Name:    s.Name + "-mariadb",
+			Image:   db.Image,
+			Command: []string{"--max-connections=" + strconv.Itoa(db.MaxConnections)},
+			Env: withTimezone(map[string]string{
+				"MYSQL_ROOT_PASSWORD": st.DBRootPassword,
+				"MYSQL_USER":          st.DBUser,
+				"MYSQL_PASSWORD":      st.DBPassword,
+				"MYSQL_DATABASE":      st.DBName,
+			}),
+			Labels:        labels(StackComponentMariaDB),
+			Mounts:        []MountSpec{{Source: db.DataDir, Target: "/var/lib/mysql"}},
+			Ports:         []PortSpec{{HostPort: db.Port, ContainerPort: 3306}},
+			Resources:     db.Resources,
+			RestartPolicy: restart,
+			Healthcheck:   db.Healthcheck,
+		},
+	})
+
+	ds := s.Domserver
+	for _, node := range ds.Nodes {
+		result = append(result, stackContainer{
+			Component: StackComponentDomserver,
+			NodeID:    node,
+			Spec: &ContainerSpec{
+				Name:  s.Name + "-domserver",
+				Image: ds.Image,
+				Env: withTimezone(map[string]string{
+					"MYSQL_HOST":          db.Host,
+					"MYSQL_PORT":          strconv.Itoa(db.Port),
+					"MYSQL_USER":          st.DBUser,
+					"MYSQL_PASSWORD":      st.DBPassword,
+					"MYSQL_ROOT_PASSWORD": st.DBRootPassword,
+					"MYSQL_DATABASE":      st.DBName,
+				}),
+				Labels:        labels(StackComponentDomserver),
+				Ports:         []PortSpec{{HostPort: ds.Port, ContainerPort: 80}},
+				Resources:     ds.Resources,
+				RestartPolicy: restart,
+				Healthcheck:   ds.Healthcheck,
+			},
+		})
+	}
+
+	jh := s.Judgehosts
+	for _, slot := range judgehosts {
+		res := jh.Resources
+		if slot.CPUSet != "" {
+			res.CpusetCpus = slot.CPUSet
+		}
+		image := jh.Image
+		if img, ok := jh.NodeImages[slot.NodeID]; ok {
+			image = img
+		}
+		// judgedaemon 以 <hostname>-<DAEMON_ID> 的名字注册到 domserver，即 <node>-<core>
+		result = append(result, stackContainer{
+			Component: StackComponentJudgehost,
+			NodeID:    slot.NodeID,
+			Spec: &ContainerSpec{
+				Name:     s.Name + "-judgehost-" + slot.Suffix,
+				Image:    image,
+				Hostname: slot.NodeID,
+				Env: withTimezone(map[string]string{
+					"DOMSERVER_BASEURL":    st.BaseURL,
+					"JUDGEDAEMON_USERNAME": st.JudgehostUser,
+					"JUDGEDAEMON_PASSWORD": st.JudgehostPassword,
+					"DAEMON_ID":            strconv.Itoa(slot.DaemonID),
+				}),
+				Labels:        labels(StackComponentJudgehost),
+				Mounts:        []MountSpec{{Source: "/sys/fs/cgroup", Target: "/sys/fs/cgroup"}},
+				Resources:     res,
+				RestartPolicy: restart,
+				Privileged:    true,
+				Healthcheck:   jh.Healthcheck,
+			},
+		})
+	}
+	return result
+}
+
+// stackDir 栈声明的保存目录
+func stackDir() string {
+	return filepath.Join(config.GetDataDir(), "stacks")
+}
+
+// saveStackSpec 保存栈声明，其中包含密码，仅 root 可读
+func saveStackSpec(spec *StackSpec) error {
+	data, err := json.MarshalIndent(spec, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(stackDir(), 0700); err != nil {
+		return err
+	}
+
+	path := filepath.Join(stackDir(), spec.Name+".json")
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// loadStackSpec 读取已部署的栈声明
+func loadStackSpec(name string) (*StackSpec, error) {
+	if !stackNamePattern.MatchString(name) {
+		return nil, fmt.Errorf("invalid stack name %q", name)
+	}
+	data, err := os.ReadFile(filepath.Join(stackDir(), name+".json"))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, fmt.Errorf("stack %s not found", name)
+		}
+		return nil, err
+	}
+
+	var spec StackSpec
+	if err := json.Unmarshal(data, &spec); err != nil {
+		return nil, fmt.Errorf("failed to parse stack %s: %w", name, err)
+	}
+	return &spec, nil
+}
+
+// removeStackSpec 删除栈声明
+func removeStackSpec(name string) error {
+	err := os.Remove(filepath.Join(stackDir(), name+".json"))
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+// ListStacks 列出已部署的栈
+func (s *DomclusterServer) ListStacks() ([]string, error) {
+	entries, err := os.ReadDir(stackDir())
+	if err != nil {
+		if os.IsNotExist(err) {
+			return []string{}, nil
+		}
+		return nil, err
+	}
+
+	names := []string{}
+	for _, e := range entries {
+		if name, ok := strings.CutSuffix(e.Name(), ".json"); ok && !e.IsDir() {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names, nil
+}
@@ -0,0 +1,577 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+const (
+	// stackHealthPollInterval 等待容器健康时查询状态的间隔
+	stackHealthPollInterval = 2 * time.Second
+	// stackSettleTime 没有健康检查的容器需要持续运行的时间
+	stackSettleTime = 5 * time.Second
+	// stackJudgehostConcurrency 同时部署 judgehost 的节点数
+	stackJudgehostConcurrency = 8
+	// stackQueryTimeout 查询节点容器状态的超时时间
+	stackQueryTimeout = 30 * time.Second
+	// stackCreateTimeout 创建单个容器的超时时间，包括拉取镜像
+	stackCreateTimeout = 30 * time.Minute
+)
+
+// 栈部署步骤的动作
+const (
+	StackActionCreated   = "created"
+	StackActionRecreated = "recreated"
+	StackActionUnchanged = "unchanged"
+	StackActionHealthy   = "healthy"
+	StackActionRemoved   = "removed"
+	StackActionFailed    = "failed"
+)
+
+// StackStep 部署过程中的一个步骤
+type StackStep struct {
+	Component string    `json:"component"`
+	NodeID    string    `json:"node_id"`
+	Container string    `json:"container"`
+	Action    string    `json:"action"`
+	Error     string    `json:"error,omitempty"`
+	Time      time.Time `json:"time"`
+}
+
+// StackDeployment 一次 up 或 down 操作的进度
+type StackDeployment struct {
+	Stack string `json:"stack"`
+	// Operation up 或 down
+	Operation string      `json:"operation"`
+	Done      bool        `json:"done"`
+	Error     string      `json:"error,omitempty"`
+	Started   time.Time   `json:"started"`
+	Finished  time.Time   `json:"finished,omitempty"`
+	Steps     []StackStep `json:"steps"`
+}
+
+// StackContainerStatus 栈中一个容器的状态
+type StackContainerStatus struct {
+	Component string `json:"component"`
+	NodeID    string `json:"node_id"`
+	Name      string `json:"name"`
+	State     string `json:"state"`
+	Health    string `json:"health,omitempty"`
+	Image     string `json:"image,omitempty"`
+	// UpToDate 容器与当前声明一致
+	UpToDate bool `json:"up_to_date"`
+	// Missing 声明中有但节点上不存在
+	Missing bool `json:"missing,omitempty"`
+	// Extra 节点上存在但已不在声明中，下次 up 时删除
+	Extra bool   `json:"extra,omitempty"`
+	Error string `json:"error,omitempty"`
+}
+
+// StackStatus 栈的当前状态
+type StackStatus struct {
+	Name       string                 `json:"name"`
+	Healthy    bool                   `json:"healthy"`
+	Containers []StackContainerStatus `json:"containers"`
+	Deployment *StackDeployment       `json:"deployment,omitempty"`
+}
+
+// stackOperation 进行中或最近一次的栈操作
+type stackOperation struct {
+	mu     sync.Mutex
+	status StackDeployment
+}
+
+// step 记录一个步骤
+func (op *stackOperation) step(sc stackContainer, action string, err error) {
+	st := StackStep{
+		Component: sc.Component,
+		NodeID:    sc.NodeID,
+		Container: sc.Spec.Name,
+		Action:    action,
+		Time:      time.Now(),
+	}
+	if err != nil {
+		st.Error = err.Error()
+	}
+
+	op.mu.Lock()
+	op.status.Steps = append(op.status.Steps, st)
+	op.mu.Unlock()
+}
+
+// finish 标记操作结束
+func (op *stackOperation) finish(err error) {
+	op.mu.Lock()
+	defer op.mu.Unlock()
+	op.status.Done = true
+	op.status.Finished = time.Now()
+	if err != nil {
+		op.status.Error = err.Error()
+	}
+}
+
+// snapshot 返回进度的副本
+func (op *stackOperation) snapshot() *StackDeployment {
+	op.mu.Lock()
+	defer op.mu.Unlock()
+	st := op.status
+	st.Steps = append([]StackStep(nil), op.status.Steps...)
+	return &st
+}
+
+// beginStackOperation 登记栈操作，同一个栈同时只能有一个操作
+func (s *DomclusterServer) beginStackOperation(name, operation string) (*stackOperation, error) {
+	s.stackOpsMu.Lock()
+	defer s.stackOpsMu.Unlock()
+
+	if op, ok := s.stackOps[name]; ok {
+		if st := op.snapshot(); !st.Done {
+			return nil, fmt.Errorf("conflict: stack %s has a %s operation in progress", name, st.Operation)
+		}
+	}
+	op := &stackOperation{status: StackDeployment{
+		Stack:     name,
+		Operation: operation,
+		Started:   time.Now(),
+		Steps:     []StackStep{},
+	}}
+	s.stackOps[name] = op
+	return op, nil
+}
+
+// GetStackDeployment 查询栈最近一次操作的进度
+func (s *DomclusterServer) GetStackDeployment(name string) (*StackDeployment, error) {
+	s.stackOpsMu.Lock()
+	op, ok := s.stackOps[name]
+	s.stackOpsMu.Unlock()
+	if !ok {
+		return nil, fmt.Errorf("no deployment found for stack %s", name)
+	}
+	return op.snapshot(), nil
+}
+
+// StackUp 部署或更新栈，按 MariaDB、domserver、judgehost 的顺序进行，每个容器健康后才继续
+//
+// 已存在且与声明一致的容器保持不变，重复执行会收敛到声明的状态而不会重复创建。
+// 部署在后台进行，通过 GetStackDeployment 查询进度。
+func (s *DomclusterServer) StackUp(spec *StackSpec) (*StackDeployment, error) {
+	if err := spec.Validate(); err != nil {
+		return nil, err
+	}
+	nodes := append([]string{spec.MariaDB.Node}, spec.Domserver.Nodes...)
+	if len(spec.Judgehosts.Nodes) > 0 {
+		nodes = append(nodes, spec.Judgehosts.Nodes...)
+	}
+	for _, node := range nodes {
+		if !s.IsNodeConnected(node) {
+			return nil, fmt.Errorf("node %s is not connected", node)
+		}
+	}
+
+	op, err := s.beginStackOperation(spec.Name, "up")
+	if err != nil {
+		return nil, err
+	}
+	if err := saveStackSpec(spec); err != nil {
+		op.finish(err)
+		return nil, fmt.Errorf("failed to save stack: %w", err)
+	}
+
+	go func() {
+		err := s.stackUp(spec, op)
+		if err != nil {
+			zap.L().Sugar().Errorf("Stack %s up failed: %v", spec.Name, err)
+		} else {
+			zap.L().Sugar().Infof("Stack %s is up", spec.Name)
+		}
+		op.finish(err)
+	}()
+	return op.snapshot(), nil
+}
+
+// stackUp 执行部署
+func (s *DomclusterServer) stackUp(spec *StackSpec, op *stackOperation) error {
+	desired, planErrs := s.stackPlan(spec)
+	healthTimeout := time.Duration(spec.HealthTimeout) * time.Second
+
+	var judgehosts []stackContainer
+	for _, sc := range desired {
+		if sc.Component == StackComponentJudgehost {
+			judgehosts = append(judgehosts, sc)
+			continue
+		}
+		// 数据库和 domserver 依次部署，任何一个失败都不再继续
+		if err := s.deployStackContainer(sc, healthTimeout, op); err != nil {
+			return fmt.Errorf("%s on %s: %w", sc.Component, sc.NodeID, err)
+		}
+	}
+
+	// 各节点的 judgehost 并行部署，同一节点上依次进行
+	byNode := make(map[string][]stackContainer)
+	var nodes []string
+	for _, sc := range judgehosts {
+		if _, ok := byNode[sc.NodeID]; !ok {
+			nodes = append(nodes, sc.NodeID)
+		}
+		byNode[sc.NodeID] = append(byNode[sc.NodeID], sc)
+	}
+
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	failed := 0
+	sem := make(chan struct{}, stackJudgehostConcurrency)
+	for _, node := range nodes {
+		wg.Add(1)
+		go func(list []stackContainer) {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+
+			for _, sc := range list {
+				if err := s.deployStackContainer(sc, healthTimeout, op); err != nil {
+					mu.Lock()
+					failed++
+					mu.Unlock()
+				}
+			}
+		}(byNode[node])
+	}
+	wg.Wait()
+
+	for node, err := range planErrs {
+		op.step(stackContainer{Component: StackComponentJudgehost, NodeID: node, Spec: &ContainerSpec{}}, StackActionFailed, err)
+	}
+	if len(planErrs) > 0 {
+		return fmt.Errorf("%d judgehost containers failed, %d nodes could not be planned", failed, len(planErrs))
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d judgehost containers failed", failed)
+	}
+
+	// 全部成功后再删除不在声明中的容器，如减少了 per_node、更换了核心或移除了节点
+	if err := s.pruneStack(spec.Name, desired, "", op); err != nil {
+		return err
+	}
+
+	// 容器与声明一致后写入各节点的期望状态，之后节点会自行重建缺失或意外停止的容器
+	result := s.syncStackDesired(spec.Name, desired)
+	for node, msg := range result.Errors {
+		zap.L().Sugar().Warnf("Desired state of stack %s on node %s not pushed: %s", spec.Name, node, msg)
+	}
+	return nil
+}
+
+// deployStackContainer 创建或更新一个容器并等待其健康
+func (s *DomclusterServer) deployStackContainer(sc stackContainer, healthTimeout time.Duration, op *stackOperation) error {
+	ctx, cancel := context.WithTimeout(context.Background(), stackCreateTimeout)
+	result, err := s.RecreateContainer(ctx, sc.NodeID, sc.Spec, RecreateOptions{OnlyIfChanged: true})
+	cancel()
+	if err != nil {
+		op.step(sc, StackActionFailed, err)
+		return err
+	}
+
+	switch {
+	case result.Unchanged:
+		op.step(sc, StackActionUnchanged, nil)
+	case result.Replaced != "":
+		op.step(sc, StackActionRecreated, nil)
+	default:
+		op.step(sc, StackActionCreated, nil)
+	}
+
+	if err := s.waitContainerHealthy(sc, healthTimeout); err != nil {
+		op.step(sc, StackActionFailed, err)
+		return err
+	}
+	op.step(sc, StackActionHealthy, nil)
+	return nil
+}
+
+// waitContainerHealthy 等待容器运行并通过健康检查，没有健康检查的容器需要持续运行 stackSettleTime
+func (s *DomclusterServer) waitContainerHealthy(sc stackContainer, timeout time.Duration) error {
+	deadline := time.Now().Add(timeout)
+	labels := map[string]string{
+		LabelStack:          sc.Spec.Labels[LabelStack],
+		LabelStackComponent: sc.Component,
+	}
+
+	last := "not found"
+	for {
+		ctx, cancel := context.WithTimeout(context.Background(), stackQueryTimeout)
+		containers, err := s.ListManagedContainers(ctx, sc.NodeID, labels)
+		cancel()
+
+		if err != nil {
+			last = err.Error()
+		} else {
+			for _, c := range containers {
+				if c.Name != sc.Spec.Name {
+					continue
+				}
+				ok, state, err := containerHealthy(c)
+				if err != nil {
+					return err
+				}
+				if ok {
+					return nil
+				}
+				last = state
+			}
+		}
+
+		if time.Now().After(deadline) {
+			return fmt.Errorf("not healthy after %s (last state: %s)", timeout, last)
+		}
+		time.Sleep(stackHealthPollInterval)
+	}
+}
+
+// containerHealthy 判断容器是否就绪，容器已退出时返回错误
+func containerHealthy(c ManagedContainer) (bool, string, error) {
+	switch c.State {
+	case "running":
+	case "exited", "dead":
+		return false, c.State, fmt.Errorf("container %s (exit code %d)", c.State, c.ExitCode)
+	default:
+		return false, c.State, nil
+	}
+
+	switch c.Health {
+	case "healthy":
+		return true, c.Health, nil
+	case "":
+		started, err := time.Parse(time.RFC3339Nano, c.StartedAt)
+		if err != nil {
+			return false, c.State, nil
+		}
+		return time.Since(started) >= stackSettleTime, c.State, nil
+	default:
+		return false, c.State + "/" + c.Health, nil
+	}
+}
+
+// stackContainersOnNodes 查询已连接节点上属于栈的全部容器，返回节点 ID 到容器列表的映射
+func (s *DomclusterServer) stackContainersOnNodes(name string) (map[string][]ManagedContainer, map[string]error) {
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	result := make(map[string][]ManagedContainer)
+	errs := make(map[string]error)
+
+	for _, node := range s.SelectNodes("", nil) {
+		wg.Add(1)
+		go func(node string) {
+			defer wg.Done()
+			ctx, cancel := context.WithTimeout(context.Background(), stackQueryTimeout)
+			defer cancel()
+
+			containers, err := s.ListManagedContainers(ctx, node, map[string]string{LabelStack: name})
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil {
+				errs[node] = err
+				return
+			}
+			if len(containers) > 0 {
+				result[node] = containers
+			}
+		}(node)
+	}
+	wg.Wait()
+	return result, errs
+}
+
+// pruneStack 删除属于栈但不在 desired 中的容器，desired 为空时删除全部，onlyNode 不为空时只处理该节点
+func (s *DomclusterServer) pruneStack(name string, desired []stackContainer, onlyNode string, op *stackOperation) error {
+	want := make(map[string]bool, len(desired))
+	for _, sc := range desired {
+		want[sc.NodeID+"/"+sc.Spec.Name] = true
+	}
+
+	actual, errs := s.stackContainersOnNodes(name)
+	for node, err := range errs {
+		zap.L().Sugar().Warnf("Failed to list containers of stack %s on %s: %v", name, node, err)
+	}
+
+	// 按部署的相反顺序删除：先 judgehost，最后数据库
+	var remove []stackContainer
+	ids := make(map[string]string)
+	for node, containers := range actual {
+		if onlyNode != "" && node != onlyNode {
+			continue
+		}
+		for _, c := range containers {
+			if want[node+"/"+c.Name] {
+				continue
+			}
+			remove = append(remove, stackContainer{
+				Component: c.Labels[LabelStackComponent],
+				NodeID:    node,
+				Spec:      &ContainerSpec{Name: c.Name},
+			})
+			ids[node+"/"+c.Name] = c.ID
+		}
+	}
+	sort.Slice(remove, func(i, j int) bool {
+		a, b := remove[i], remove[j]
+		if a.Component != b.Component {
+			return stackComponentOrder(a.Component) > stackComponentOrder(b.Component)
+		}
+		return a.NodeID+"/"+a.Spec.Name < b.NodeID+"/"+b.Spec.Name
+	})
+
+	failed := 0
+	for _, sc := range remove {
+		ctx, cancel := context.WithTimeout(context.Background(), stackQueryTimeout)
+		_, err := s.RemoveContainer(ctx, sc.NodeID, ids[sc.NodeID+"/"+sc.Spec.Name], true, false)
+		cancel()
+		if err != nil {
+			op.step(sc, StackActionFailed, err)
+			failed++
+			continue
+		}
+		op.step(sc, StackActionRemoved, nil)
+	}
+	if failed > 0 {
+		return fmt.Errorf("failed to remove %d containers", failed)
+	}
+	return nil
+}
+
+// StackDown 删除栈在所有已连接节点上的容器，数据库目录保留在节点上
+func (s *DomclusterServer) StackDown(name string) (*StackDeployment, error) {
+	if _, err := loadStackSpec(name); err != nil {
+		return nil, err
+	}
+	op, err := s.beginStackOperation(name, "down")
+	if err != nil {
+		return nil, err
+	}
+
+	go func() {
+		// 先从期望状态中删除，避免节点在删除后重建容器
+		result := s.syncStackDesired(name, nil)
+		for node, msg := range result.Errors {
+			zap.L().Sugar().Warnf("Desired state of stack %s on node %s not pushed: %s", name, node, msg)
+		}
+		err := s.pruneStack(name, nil, "", op)
+		if err == nil {
+			if rmErr := removeStackSpec(name); rmErr != nil {
+				err = fmt.Errorf("failed to remove stack: %w", rmErr)
+			}
+		}
+		if err != nil {
+			zap.L().Sugar().Errorf("Stack %s down failed: %v", name, err)
+		} else {
+			zap.L().Sugar().Infof("Stack %s is down", name)
+		}
+		op.finish(err)
+	}()
+	return op.snapshot(), nil
+}
+
+// GetStackStatus 比较栈的声明与各节点上的容器
+func (s *DomclusterServer) GetStackStatus(name string) (*StackStatus, error) {
+	spec, err := loadStackSpec(name)
+	if err != nil {
+		return nil, err
+	}
+	desired, planErrs := s.stackPlan(spec)
+	actual, errs := s.stackContainersOnNodes(name)
+
+	status := &StackStatus{Name: name, Healthy: true, Containers: []StackContainerStatus{}}
+	for node, err := range planErrs {
+		status.Healthy = false
+		status.Containers = append(status.Containers, StackContainerStatus{
+			Component: StackComponentJudgehost,
+			NodeID:    node,
+			Error:     err.Error(),
+		})
+	}
+	seen := make(map[string]bool)
+	for _, sc := range desired {
+		cs := StackContainerStatus{
+			Component: sc.Component,
+			NodeID:    sc.NodeID,
+			Name:      sc.Spec.Name,
+		}
+		seen[sc.NodeID+"/"+sc.Spec.Name] = true
+
+		found := false
+		for _, c := range actual[sc.NodeID] {
+			if c.Name == sc.Spec.Name {
+				found = true
+				cs.State = c.State
+				cs.Health = c.Health
+				cs.Image = c.Image
+				cs.UpToDate = c.SpecHash == sc.Spec.Hash()
+				break
+			}
+		}
+		switch {
+		case errs[sc.NodeID] != nil:
+			cs.Error = errs[sc.NodeID].Error()
+		case !s.IsNodeConnected(sc.NodeID):
+			cs.Error = "node not connected"
+		case !found:
+			cs.Missing = true
+		}
+		if !found || !cs.UpToDate || cs.State != "running" || (cs.Health != "" && cs.Health != "healthy") {
+			status.Healthy = false
+		}
+		status.Containers = append(status.Containers, cs)
+	}
+
+	for node, containers := range actual {
+		for _, c := range containers {
+			if seen[node+"/"+c.Name] {
+				continue
+			}
+			// 无法确定 judgehost 的节点上的容器不算多余
+			component := c.Labels[LabelStackComponent]
+			extra := planErrs[node] == nil || component != StackComponentJudgehost
+			status.Containers = append(status.Containers, StackContainerStatus{
+				Component: component,
+				NodeID:    node,
+				Name:      c.Name,
+				State:     c.State,
+				Health:    c.Health,
+				Image:     c.Image,
+				Extra:     extra,
+			})
+		}
+	}
+	sort.SliceStable(status.Containers, func(i, j int) bool {
+		a, b := status.Containers[i], status.Containers[j]
+		if a.Component != b.Component {
+			return stackComponentOrder(a.Component) < stackComponentOrder(b.Component)
+		}
+		if a.NodeID != b.NodeID {
+			return a.NodeID < b.NodeID
+		}
+		return a.Name < b.Name
+	})
+
+	if dep, err := s.GetStackDeployment(name); err == nil {
+		status.Deployment = dep
+	}
+	return status, nil
+}
+
+// stackComponentOrder 组件的部署顺序
+func stackComponentOrder(component string) int {
+	switch component {
+	case StackComponentMariaDB:
+		return 0
+	case StackComponentDomserver:
+		return 1
+	case StackComponentJudgehost:
+		return 2
+	}
+	return 3
+}
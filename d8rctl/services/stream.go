@@ -0,0 +1,235 @@
+package services
+
+import (
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	pb "domcluster/api/proto"
+	"go.uber.org/zap"
+)
+
+const (
+	// agentStreamBuffer 每个流缓存的消息数，消费者跟不上时丢弃新消息
+	agentStreamBuffer = 1024
+	// interactiveDeliverTimeout 交互式流缓冲区已满时等待消费者的时间，超时后以错误结束会话
+	interactiveDeliverTimeout = 5 * time.Second
+)
+
+// AgentStream 从节点到控制端的数据流（日志跟随、统计推送、交互式会话输出等）
+//
+// 节点通过 stream_data 推送消息，type 为 end 的消息表示流结束。
+// 日志、统计等流在消费者跟不上时丢弃消息；交互式流（终端、容器内会话）不能丢失输出，
+// 缓冲区已满时短暂等待，仍无法投递则结束会话，原因由 Err 返回。
+type AgentStream struct {
+	ID     string
+	NodeID string
+
+	// C 接收节点推送的原始 JSON 消息，流结束后关闭
+	C chan []byte
+
+	server      *DomclusterServer
+	interactive bool
+	done        chan struct{}
+	closeOnce   sync.Once
+	mu          sync.Mutex
+	closed      bool
+	dropped     int
+	err         error
+}
+
+// Dropped 返回因缓冲区已满而丢弃的消息数
+func (st *AgentStream) Dropped() int {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	return st.dropped
+}
+
+// Err 返回交互式流因输出无法投递而被结束的原因，C 关闭后调用
+func (st *AgentStream) Err() error {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	return st.err
+}
+
+// Send 向节点上的流发送控制消息（如终端输入、窗口大小调整）
+func (st *AgentStream) Send(cmd string, data interface{}) error {
+	dataBytes, err := json.Marshal(data)
+	if err != nil {
+		return fmt.Errorf("failed to marshal stream input: %w", err)
+	}
+	return st.server.SendToNode(st.NodeID, cmd, st.ID, dataBytes)
+}
+
+// Close 结束流，并通知节点停止产生数据
+func (st *AgentStream) Close() {
+	st.server.unregisterStream(st.ID)
+	if st.finish() {
+		if err := st.server.SendToNode(st.NodeID, "stream_cancel", st.ID, []byte(`{"cmd":"stream_cancel"}`)); err != nil {
+			zap.L().Sugar().Debugf("Failed to cancel stream %s on node %s: %v", st.ID, st.NodeID, err)
+		}
+	}
+}
+
+// deliver 投递一条消息，缓冲区已满时普通流丢弃消息，交互式流等待消费者或结束会话
+func (st *AgentStream) deliver(data []byte) {
+	if !st.deliverWait(data) {
+		zap.L().Sugar().Warnf("Stream %s on node %s: output not consumed within %v, closing session",
+			st.ID, st.NodeID, interactiveDeliverTimeout)
+		st.abort(fmt.Errorf("session output not consumed within %v", interactiveDeliverTimeout))
+	}
+}
+
+// deliverWait 投递消息，只有交互式流等待超时才返回 false
+//
+// 等待时持有锁以免 C 被关闭，finish 先关闭 done 使等待提前结束。
+func (st *AgentStream) deliverWait(data []byte) bool {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	if st.closed {
+		return true
+	}
+	select {
+	case st.C <- data:
+		return true
+	default:
+	}
+	if !st.interactive {
+		st.dropped++
+		return true
+	}
+
+	timer := time.NewTimer(interactiveDeliverTimeout)
+	defer timer.Stop()
+	select {
+	case st.C <- data:
+		return true
+	case <-st.done:
+		return true
+	case <-timer.C:
+		return false
+	}
+}
+
+// abort 以错误结束流并通知节点停止会话
+func (st *AgentStream) abort(err error) {
+	st.mu.Lock()
+	if !st.closed {
+		st.err = err
+	}
+	st.mu.Unlock()
+	st.Close()
+}
+
+// finish 关闭消息 channel，只有第一次调用返回 true
+func (st *AgentStream) finish() bool {
+	first := false
+	st.closeOnce.Do(func() {
+		close(st.done)
+		st.mu.Lock()
+		st.closed = true
+		close(st.C)
+		st.mu.Unlock()
+		first = true
+	})
+	return first
+}
+
+// OpenStream 在节点上发起流式命令
+func (s *DomclusterServer) OpenStream(nodeID, cmd string, data map[string]interface{}) (*AgentStream, error) {
+	return s.openStream(nodeID, cmd, data, false)
+}
+
+// OpenInteractiveStream 在节点上发起交互式会话，输出不会因缓冲区已满而被丢弃
+func (s *DomclusterServer) OpenInteractiveStream(nodeID, cmd string, data map[string]interface{}) (*AgentStream, error) {
+	return s.openStream(nodeID, cmd, data, true)
+}
+
+// openStream 注册流并向节点发送请求
+func (s *DomclusterServer) openStream(nodeID, cmd string, data map[string]interface{}, interactive bool) (*AgentStream, error) {
+	if !s.IsNodeConnected(nodeID) {
+		return nil, fmt.Errorf("node %s not connected", nodeID)
+	}
+
+	if data == nil {
+		data = make(map[string]interface{})
+	}
+	data["cmd"] = cmd
+	dataBytes, err := json.Marshal(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal stream request: %w", err)
+	}
+
+	st := &AgentStream{
+		ID:          fmt.Sprintf("stream_%d", time.Now().UnixNano()),
+		NodeID:      nodeID,
+		C:           make(chan []byte, agentStreamBuffer),
+		server:      s,
+		interactive: interactive,
+		done:        make(chan struct{}),
+	}
+
+	s.agentStreamsMu.Lock()
+	s.agentStreams[st.ID] = st
+	s.agentStreamsMu.Unlock()
+
+	if err := s.SendToNode(nodeID, cmd, st.ID, dataBytes); err != nil {
+		s.unregisterStream(st.ID)
+		st.finish()
+		return nil, fmt.Errorf("failed to send stream request: %w", err)
+	}
+
+	zap.L().Sugar().Infof("Opened stream %s (%s) on node %s", st.ID, cmd, nodeID)
+	return st, nil
+}
+
+// unregisterStream 移除流
+func (s *DomclusterServer) unregisterStream(id string) {
+	s.agentStreamsMu.Lock()
+	defer s.agentStreamsMu.Unlock()
+	delete(s.agentStreams, id)
+}
+
+// handleStreamData 处理节点推送的流消息
+func (s *DomclusterServer) handleStreamData(req *pb.PublishRequest) {
+	s.agentStreamsMu.Lock()
+	st, ok := s.agentStreams[req.ReqId]
+	s.agentStreamsMu.Unlock()
+
+	if !ok || st.NodeID != req.Issuer {
+		// 流已被关闭，通知节点停止发送
+		zap.L().Sugar().Debugf("No stream for reqID %s from %s, cancelling", req.ReqId, req.Issuer)
+		s.SendToNode(req.Issuer, "stream_cancel", req.ReqId, []byte(`{"cmd":"stream_cancel"}`))
+		return
+	}
+
+	var msg struct {
+		Type string `json:"type"`
+	}
+	json.Unmarshal(req.Data, &msg)
+
+	st.deliver(req.Data)
+	if msg.Type == "end" {
+		s.unregisterStream(st.ID)
+		st.finish()
+	}
+}
+
+// closeNodeStreams 节点断开时结束其所有流
+func (s *DomclusterServer) closeNodeStreams(nodeID string) {
+	s.agentStreamsMu.Lock()
+	var closing []*AgentStream
+	for id, st := range s.agentStreams {
+		if st.NodeID == nodeID {
+			closing = append(closing, st)
+			delete(s.agentStreams, id)
+		}
+	}
+	s.agentStreamsMu.Unlock()
+
+	for _, st := range closing {
+		st.deliver([]byte(`{"type":"end","error":"node disconnected"}`))
+		st.finish()
+	}
+}
@@ -0,0 +1,323 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+const (
+	// transferChunkSize 每个分块的字节数，编码后远小于 gRPC 消息大小上限
+	transferChunkSize = 256 * 1024
+	// transferChunkTimeout 单个分块请求的超时时间
+	transferChunkTimeout = 30 * time.Second
+	// transferCommitTimeout 校验并安装文件（包括复制到容器和导入镜像）的超时时间
+	transferCommitTimeout = 30 * time.Minute
+	// transferReconnectWait 传输中节点断开后等待其重连的时间
+	transferReconnectWait = 60 * time.Second
+	// transferMaxRetries 传输过程中允许的最大重试次数
+	transferMaxRetries = 5
+)
+
+// FileTarget 节点或节点上容器内的文件
+type FileTarget struct {
+	NodeID      string
+	ContainerID string
+	Path        string
+}
+
+// String 返回 node:/path 或 node/container:/path 形式的描述
+func (t FileTarget) String() string {
+	if t.ContainerID != "" {
+		return fmt.Sprintf("%s/%s:%s", t.NodeID, t.ContainerID, t.Path)
+	}
+	return fmt.Sprintf("%s:%s", t.NodeID, t.Path)
+}
+
+// request 构造发给节点的请求字段
+func (t FileTarget) request() map[string]interface{} {
+	data := map[string]interface{}{"path": t.Path}
+	if t.ContainerID != "" {
+		data["container_id"] = t.ContainerID
+	}
+	return data
+}
+
+// FileInfo 节点上文件的信息
+type FileInfo struct {
+	Path    string    `json:"path"`
+	Size    int64     `json:"size"`
+	Mode    string    `json:"mode"`
+	IsDir   bool      `json:"is_dir"`
+	Regular bool      `json:"regular"`
+	ModTime time.Time `json:"mod_time"`
+}
+
+// UploadResult 上传完成后节点上的文件
+type UploadResult struct {
+	Path   string `json:"path"`
+	Size   int64  `json:"size"`
+	SHA256 string `json:"sha256"`
+	Mode   string `json:"mode"`
+}
+
+// DownloadInfo 下载会话信息
+type DownloadInfo struct {
+	TransferID string `json:"transfer_id"`
+	Path       string `json:"path"`
+	Size       int64  `json:"size"`
+	SHA256     string `json:"sha256"`
+	Mode       string `json:"mode"`
+}
+
+// StatFile 查询节点上文件的信息
+func (s *DomclusterServer) StatFile(ctx context.Context, target FileTarget) (*FileInfo, error) {
+	result, err := s.QueryNode(ctx, target.NodeID, "file_stat", target.request())
+	if err != nil {
+		return nil, err
+	}
+
+	var info FileInfo
+	if err := json.Unmarshal(result, &info); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal file info: %w", err)
+	}
+	return &info, nil
+}
+
+// UploadFile 将 src 中的 size 字节分块上传到节点
+//
+// 传输 ID 由目标和文件内容决定，连接中断后重新开始同一传输时节点从已接收的位置继续。
+// mode 为八进制权限字符串，为空时使用 0644；maxSize 为 0 时使用节点配置的上限。
+func (s *DomclusterServer) UploadFile(ctx context.Context, target FileTarget, src io.ReaderAt, size int64, mode string, maxSize int64) (*UploadResult, error) {
+	sum, err := sha256Section(src, size)
+	if err != nil {
+		return nil, err
+	}
+
+	idHash := sha256.Sum256([]byte(fmt.Sprintf("%s|%s|%s|%s|%d|%s", target.NodeID, target.ContainerID, target.Path, sum, size, mode)))
+	data := target.request()
+	data["transfer_id"] = hex.EncodeToString(idHash[:16])
+	data["size"] = size
+	data["sha256"] = sum
+	data["mode"] = mode
+	data["max_size"] = maxSize
+
+	result, err := s.uploadChunks(ctx, target.NodeID, target.String(), data, src, size, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var uploaded UploadResult
+	if err := json.Unmarshal(result, &uploaded); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal upload result: %w", err)
+	}
+	zap.L().Sugar().Infof("Uploaded %d bytes to %s (sha256 %s)", uploaded.Size, target, uploaded.SHA256)
+	return &uploaded, nil
+}
+
+// uploadChunks 按 begin 中的参数开始上传，分块发送 src 后提交，返回节点的提交结果
+//
+// 分块失败时等待节点重连并从节点实际接收的位置继续；progress 不为 nil 时在每个分块确认后调用。
+func (s *DomclusterServer) uploadChunks(ctx context.Context, nodeID, desc string, begin map[string]interface{}, src io.ReaderAt, size int64, progress func(offset int64)) ([]byte, error) {
+	transferID := begin["transfer_id"]
+	start := func() (int64, error) {
+		result, err := s.transferQuery(ctx, nodeID, "file_upload_begin", begin, transferChunkTimeout)
+		if err != nil {
+			return 0, err
+		}
+		var resp struct {
+			Offset int64 `json:"offset"`
+		}
+		if err := json.Unmarshal(result, &resp); err != nil {
+			return 0, fmt.Errorf("failed to unmarshal upload response: %w", err)
+		}
+		if progress != nil {
+			progress(resp.Offset)
+		}
+		return resp.Offset, nil
+	}
+
+	offset, err := start()
+	if err != nil {
+		return nil, err
+	}
+	if offset > 0 {
+		zap.L().Sugar().Infof("Resuming upload to %s at %d/%d bytes", desc, offset, size)
+	}
+
+	buf := make([]byte, transferChunkSize)
+	retries := 0
+	for offset < size {
+		n, err := src.ReadAt(buf[:min(int64(len(buf)), size-offset)], offset)
+		if err != nil && err != io.EOF {
+			return nil, fmt.Errorf("failed to read source: %w", err)
+		}
+		chunkSum := sha256.Sum256(buf[:n])
+
+		_, err = s.transferQuery(ctx, nodeID, "file_upload_chunk", map[string]interface{}{
+			"transfer_id": transferID,
+			"offset":      offset,
+			"data":        buf[:n],
+			"sha256":      hex.EncodeToString(chunkSum[:]),
+		}, transferChunkTimeout)
+		if err == nil {
+			offset += int64(n)
+			if progress != nil {
+				progress(offset)
+			}
+			continue
+		}
+
+		// 重试前等待节点重连，并从节点实际接收的位置继续
+		if retries++; retries > transferMaxRetries {
+			return nil, fmt.Errorf("upload to %s failed after %d retries: %w", desc, transferMaxRetries, err)
+		}
+		zap.L().Sugar().Warnf("Upload chunk to %s at offset %d failed, retrying: %v", desc, offset, err)
+		if err := s.waitNodeConnected(ctx, nodeID); err != nil {
+			return nil, err
+		}
+		if offset, err = start(); err != nil {
+			return nil, err
+		}
+	}
+
+	return s.transferQuery(ctx, nodeID, "file_upload_commit", map[string]interface{}{
+		"transfer_id": transferID,
+	}, transferCommitTimeout)
+}
+
+// sha256Section 计算 src 前 size 字节的 SHA-256
+func sha256Section(src io.ReaderAt, size int64) (string, error) {
+	hash := sha256.New()
+	if _, err := io.Copy(hash, io.NewSectionReader(src, 0, size)); err != nil {
+		return "", fmt.Errorf("failed to read source: %w", err)
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// BeginDownload 在节点上准备下载，返回文件大小和校验和
+//
+// 下载结束后必须调用 EndDownload 释放节点上的会话。
+func (s *DomclusterServer) BeginDownload(ctx context.Context, target FileTarget, maxSize int64) (*DownloadInfo, error) {
+	id := make([]byte, 16)
+	if _, err := rand.Read(id); err != nil {
+		return nil, err
+	}
+
+	data := target.request()
+	data["transfer_id"] = hex.EncodeToString(id)
+	data["max_size"] = maxSize
+	result, err := s.transferQuery(ctx, target.NodeID, "file_download_begin", data, transferCommitTimeout)
+	if err != nil {
+		return nil, err
+	}
+
+	var info DownloadInfo
+	if err := json.Unmarshal(result, &info); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal download info: %w", err)
+	}
+	return &info, nil
+}
+
+// DownloadFile 从 offset 开始分块读取文件并写入 dst，每个分块都校验 SHA-256
+func (s *DomclusterServer) DownloadFile(ctx context.Context, target FileTarget, info *DownloadInfo, offset int64, dst io.Writer) error {
+	retries := 0
+	for offset < info.Size {
+		result, err := s.transferQuery(ctx, target.NodeID, "file_download_chunk", map[string]interface{}{
+			"transfer_id": info.TransferID,
+			"offset":      offset,
+			"length":      transferChunkSize,
+		}, transferChunkTimeout)
+		if err == nil {
+			var chunk struct {
+				Data   []byte `json:"data"`
+				SHA256 string `json:"sha256"`
+			}
+			if err := json.Unmarshal(result, &chunk); err != nil {
+				return fmt.Errorf("failed to unmarshal chunk: %w", err)
+			}
+			sum := sha256.Sum256(chunk.Data)
+			if hex.EncodeToString(sum[:]) != chunk.SHA256 || len(chunk.Data) == 0 {
+				err = fmt.Errorf("chunk checksum mismatch at offset %d", offset)
+			} else {
+				if _, err := dst.Write(chunk.Data); err != nil {
+					return err
+				}
+				offset += int64(len(chunk.Data))
+				continue
+			}
+		}
+
+		if retries++; retries > transferMaxRetries {
+			return fmt.Errorf("download from %s failed after %d retries: %w", target, transferMaxRetries, err)
+		}
+		zap.L().Sugar().Warnf("Download chunk from %s at offset %d failed, retrying: %v", target, offset, err)
+		if err := s.waitNodeConnected(ctx, target.NodeID); err != nil {
+			return err
+		}
+
+		// 节点重启后下载会话已丢失，以相同 ID 重新开始并确认文件未变化
+		data := target.request()
+		data["transfer_id"] = info.TransferID
+		result, err = s.transferQuery(ctx, target.NodeID, "file_download_begin", data, transferCommitTimeout)
+		if err != nil {
+			return err
+		}
+		var again DownloadInfo
+		if err := json.Unmarshal(result, &again); err != nil {
+			return fmt.Errorf("failed to unmarshal download info: %w", err)
+		}
+		if again.SHA256 != info.SHA256 || again.Size != info.Size {
+			return fmt.Errorf("%s changed during download", target)
+		}
+	}
+	return nil
+}
+
+// EndDownload 释放节点上的下载会话
+func (s *DomclusterServer) EndDownload(target FileTarget, info *DownloadInfo) {
+	ctx, cancel := context.WithTimeout(context.Background(), transferChunkTimeout)
+	defer cancel()
+
+	if _, err := s.QueryNode(ctx, target.NodeID, "file_download_end", map[string]interface{}{
+		"transfer_id": info.TransferID,
+	}); err != nil {
+		zap.L().Sugar().Debugf("Failed to end download %s on %s: %v", info.TransferID, target.NodeID, err)
+	}
+}
+
+// transferQuery 发送一次带超时的传输请求
+func (s *DomclusterServer) transferQuery(ctx context.Context, nodeID, cmd string, data map[string]interface{}, timeout time.Duration) ([]byte, error) {
+	queryCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	result, err := s.QueryNode(queryCtx, nodeID, cmd, data)
+	if err != nil && errors.Is(err, context.DeadlineExceeded) && ctx.Err() == nil {
+		return nil, fmt.Errorf("%s to node %s timed out", cmd, nodeID)
+	}
+	return result, err
+}
+
+// waitNodeConnected 等待节点重新连接，超过 transferReconnectWait 仍未连接时返回错误
+func (s *DomclusterServer) waitNodeConnected(ctx context.Context, nodeID string) error {
+	deadline := time.Now().Add(transferReconnectWait)
+	for !s.IsNodeConnected(nodeID) {
+		if time.Now().After(deadline) {
+			return fmt.Errorf("node %s did not reconnect within %s", nodeID, transferReconnectWait)
+		}
+		select {
+		case <-time.After(time.Second):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	return nil
+}
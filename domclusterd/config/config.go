@@ -2,7 +2,6 @@ package config
 
 import (
 	"log"
-
 	"time"
 
 	"github.com/spf13/pflag"
@@ -15,6 +14,56 @@ type Config struct {
 	Role    string
 	UseTLS  bool
 	Timeout time.Duration
+
+	// DiskMounts 需要上报的挂载点，为空时自动发现所有真实文件系统
+	DiskMounts []string
+	// IncludeVirtualNICs 是否上报 lo 回环网卡，veth、网桥等其他虚拟网卡由 ExcludeNICs 过滤
+	IncludeVirtualNICs bool
+	// ExcludeNICs 不上报的网卡名称（glob 模式）
+	ExcludeNICs []string
+	// Labels 节点标签，注册时上报给控制端
+	Labels map[string]string
+	// Probes 本地定义的健康探针（原始配置项），控制端下发探针配置时以下发为准
+	Probes []map[string]interface{}
+	// LogShipping 容器及自身日志的采集上报配置
+	LogShipping LogShippingConfig
+	// Transfer 文件传输配置
+	Transfer TransferConfig
+	// Files 文件浏览配置
+	Files FilesConfig
+	// Reconcile 期望状态收敛配置
+	Reconcile ReconcileConfig
+}
+
+// LogShippingConfig 日志采集配置
+type LogShippingConfig struct {
+	Enabled      bool
+	Containers   []string
+	AgentLog     bool
+	AgentLogPath string
+	SpoolDir     string
+	SpoolMaxMB   int
+	BatchSize    int
+	FlushSeconds int
+}
+
+// TransferConfig 文件传输配置
+type TransferConfig struct {
+	StagingDir     string
+	MaxSizeMB      int
+	MaxImageSizeMB int
+}
+
+// FilesConfig 文件浏览配置
+type FilesConfig struct {
+	WriteRoots []string
+}
+
+// ReconcileConfig 期望状态收敛配置
+type ReconcileConfig struct {
+	Enabled         bool
+	StatePath       string
+	IntervalSeconds int
 }
 
 // Load 加载配置，优先级：命令行参数 > 环境变量 > 配置文件 > 默认值
@@ -26,6 +75,24 @@ func Load() (*Config, error) {
 	v.SetDefault("domclusterd.service.role", []string{"judgehost"})
 	v.SetDefault("domclusterd.config.use_tls", false)
 	v.SetDefault("domclusterd.config.timeout", 10)
+	v.SetDefault("domclusterd.monitor.disk_mounts", []string{})
+	v.SetDefault("domclusterd.monitor.include_virtual_interfaces", false)
+	v.SetDefault("domclusterd.monitor.exclude_interfaces", []string{"veth*", "docker*", "br-*"})
+	v.SetDefault("domclusterd.logs.enabled", false)
+	v.SetDefault("domclusterd.logs.containers", []string{})
+	v.SetDefault("domclusterd.logs.agent_log", true)
+	v.SetDefault("domclusterd.logs.agent_log_path", "/var/log/domclusterd/domclusterd.log")
+	v.SetDefault("domclusterd.logs.spool_dir", "/var/lib/domclusterd/log-spool")
+	v.SetDefault("domclusterd.logs.spool_max_mb", 256)
+	v.SetDefault("domclusterd.logs.batch_size", 500)
+	v.SetDefault("domclusterd.logs.flush_seconds", 2)
+	v.SetDefault("domclusterd.transfer.staging_dir", "/var/lib/domclusterd/transfers")
+	v.SetDefault("domclusterd.transfer.max_size_mb", 1024)
+	v.SetDefault("domclusterd.transfer.max_image_size_mb", 8192)
+	v.SetDefault("domclusterd.files.write_roots", []string{})
+	v.SetDefault("domclusterd.reconcile.enabled", true)
+	v.SetDefault("domclusterd.reconcile.state_path", "/var/lib/domclusterd/desired.json")
+	v.SetDefault("domclusterd.reconcile.interval_seconds", 15)
 
 	// 绑定命令行参数
 	pflag.String("address", "localhost:50051", "服务地址")
@@ -67,6 +134,43 @@ func Load() (*Config, error) {
 		Role:    role,
 		UseTLS:  v.GetBool("domclusterd.config.use_tls"),
 		Timeout: time.Duration(v.GetInt("domclusterd.config.timeout")) * time.Second,
+
+		DiskMounts:         v.GetStringSlice("domclusterd.monitor.disk_mounts"),
+		IncludeVirtualNICs: v.GetBool("domclusterd.monitor.include_virtual_interfaces"),
+		ExcludeNICs:        v.GetStringSlice("domclusterd.monitor.exclude_interfaces"),
+		Labels:             v.GetStringMapString("domclusterd.node.labels"),
+
+		LogShipping: LogShippingConfig{
+			Enabled:      v.GetBool("domclusterd.logs.enabled"),
+			Containers:   v.GetStringSlice("domclusterd.logs.containers"),
+			AgentLog:     v.GetBool("domclusterd.logs.agent_log"),
+			AgentLogPath: v.GetString("domclusterd.logs.agent_log_path"),
+			SpoolDir:     v.GetString("domclusterd.logs.spool_dir"),
+			SpoolMaxMB:   v.GetInt("domclusterd.logs.spool_max_mb"),
+			BatchSize:    v.GetInt("domclusterd.logs.batch_size"),
+			FlushSeconds: v.GetInt("domclusterd.logs.flush_seconds"),
+		},
+
+		Transfer: TransferConfig{
+			StagingDir:     v.GetString("domclusterd.transfer.staging_dir"),
+			MaxSizeMB:      v.GetInt("domclusterd.transfer.max_size_mb"),
+			MaxImageSizeMB: v.GetInt("domclusterd.transfer.max_image_size_mb"),
+		},
+
+		Files: FilesConfig{
+			WriteRoots: v.GetStringSlice("domclusterd.files.write_roots"),
+		},
+
+		Reconcile: ReconcileConfig{
+			Enabled:         v.GetBool("domclusterd.reconcile.enabled"),
+			StatePath:       v.GetString("domclusterd.reconcile.state_path"),
+			IntervalSeconds: v.GetInt("domclusterd.reconcile.interval_seconds"),
+		},
+	}
+
+	if err := v.UnmarshalKey("domclusterd.probes", &cfg.Probes); err != nil {
+		log.Printf("解析健康探针配置失败，忽略本地探针: %v", err)
+		cfg.Probes = nil
 	}
 
 	return cfg, nil
@@ -87,7 +191,12 @@ func (c *Config) GetUseTLS() bool {
 	return c.UseTLS
 }
 
+// GetDiskMounts 获取需要上报的挂载点
+func (c *Config) GetDiskMounts() []string {
+	return c.DiskMounts
+}
+
 // GetTimeout 获取连接超时时间
 func (c *Config) GetTimeout() time.Duration {
 	return c.Timeout
-}
\ No newline at end of file
+}
@@ -26,7 +26,9 @@ type Manager struct {
 	streamCancel      context.CancelFunc
 	nodeID            string
 	nodeName          string
+	labels            map[string]string
 	mu                sync.RWMutex
+	sendMu            sync.Mutex // gRPC 流不支持并发 Send
 	connected         bool
 	reconnecting      bool
 	handlers          map[string]HandlerFunc
@@ -93,9 +95,14 @@ func (m *Manager) RegisterNode(nodeID, name string) error {
 	m.nodeName = name
 	m.mu.Unlock()
 
+	m.mu.RLock()
+	labels := m.labels
+	m.mu.RUnlock()
+
 	data := map[string]interface{}{
 		"name":    name,
 		"version": "1.0.0",
+		"labels":  labels,
 	}
 	dataBytes, _ := json.Marshal(data)
 
@@ -109,6 +116,25 @@ func (m *Manager) RegisterNode(nodeID, name string) error {
 	return nil
 }
 
+// SetLabels 设置注册时上报的节点标签
+func (m *Manager) SetLabels(labels map[string]string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.labels = labels
+}
+
+// SetHeartbeat 修改心跳检查间隔和超时时间，下一次检查时生效
+func (m *Manager) SetHeartbeat(interval, timeout time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if interval > 0 {
+		m.heartbeatInterval = interval
+	}
+	if timeout > 0 {
+		m.heartbeatTimeout = timeout
+	}
+}
+
 // SendHeartbeat 发送心跳
 func (m *Manager) SendHeartbeat() error {
 	m.mu.RLock()
@@ -143,6 +169,8 @@ func (m *Manager) Send(cmd, reqID string, data []byte) error {
 		Data:   data,
 	}
 
+	m.sendMu.Lock()
+	defer m.sendMu.Unlock()
 	return stream.Send(req)
 }
 
@@ -160,8 +188,11 @@ func (m *Manager) receiveLoop() {
 			m.mu.RLock()
 			lastHeartbeat := m.lastHeartbeat
 			heartbeatTimeout := m.heartbeatTimeout
+			heartbeatInterval := m.heartbeatInterval
 			m.mu.RUnlock()
 
+			heartbeatTicker.Reset(heartbeatInterval)
+
 			if time.Since(lastHeartbeat) > heartbeatTimeout {
 				zap.L().Sugar().Warn("Heartbeat timeout, attempting to reconnect...")
 				if err := m.reconnect(); err != nil {
@@ -336,7 +367,6 @@ func (m *Manager) Close() {
 	}
 }
 
-
 func (m *Manager) Start(ctx context.Context, nodeID, nodeName string) error {
 	// 重试连接服务器
 	connectRetryInterval := 5 * time.Second
@@ -0,0 +1,41 @@
+package connections
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	pb "domcluster/api/proto"
+	"go.uber.org/zap"
+)
+
+// QueryFunc 查询处理函数，返回值序列化为 JSON 后通过 query_response 回复
+type QueryFunc func(ctx context.Context, data []byte) (interface{}, error)
+
+// RegisterQueryHandler 注册查询处理函数
+//
+// 处理函数在独立 goroutine 中执行，避免耗时操作阻塞接收循环；返回错误时回复 {"error": ...}，
+// 控制端的 QueryNode 会将其转换为错误。ctx 取消或超过 timeout 时传给处理函数的 context 被取消。
+func (m *Manager) RegisterQueryHandler(ctx context.Context, cmd string, timeout time.Duration, fn QueryFunc) {
+	m.RegisterHandler(cmd, func(resp *pb.PublishResponse) error {
+		go func() {
+			opCtx, cancel := context.WithTimeout(ctx, timeout)
+			defer cancel()
+
+			result, err := fn(opCtx, resp.Data)
+			if err != nil {
+				zap.L().Sugar().Debugf("%s failed: %v", cmd, err)
+				result = map[string]interface{}{"error": err.Error()}
+			}
+			dataBytes, err := json.Marshal(result)
+			if err != nil {
+				zap.L().Sugar().Errorf("Failed to marshal %s result: %v", cmd, err)
+				return
+			}
+			if err := m.Send("query_response", resp.ReqId, dataBytes); err != nil {
+				zap.L().Sugar().Errorf("Failed to send %s response: %v", cmd, err)
+			}
+		}()
+		return nil
+	})
+}
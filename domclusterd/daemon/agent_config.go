@@ -0,0 +1,115 @@
+package daemon
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"domclusterd/monitor"
+
+	pb "domcluster/api/proto"
+	"go.uber.org/zap"
+)
+
+// agentConfig 控制端下发的运行时配置，未设置的字段保持当前值（时间单位为秒）
+type agentConfig struct {
+	ReportInterval           *int                 `json:"report_interval"`
+	HeartbeatInterval        *int                 `json:"heartbeat_interval"`
+	HeartbeatTimeout         *int                 `json:"heartbeat_timeout"`
+	ShellTimeout             *int                 `json:"shell_timeout"`
+	TerminalIdleTimeout      *int                 `json:"terminal_idle_timeout"`
+	DiskMounts               *[]string            `json:"disk_mounts"`
+	IncludeVirtualInterfaces *bool                `json:"include_virtual_interfaces"`
+	ExcludeInterfaces        *[]string            `json:"exclude_interfaces"`
+	Probes                   *[]monitor.ProbeSpec `json:"probes"`
+}
+
+// handleAgentConfig 处理控制端下发的配置，应用后回复确认的修订号
+func (d *Daemon) handleAgentConfig(resp *pb.PublishResponse) error {
+	var msg struct {
+		Revision uint64      `json:"revision"`
+		Config   agentConfig `json:"config"`
+	}
+
+	ack := map[string]interface{}{}
+	if err := json.Unmarshal(resp.Data, &msg); err != nil {
+		ack["error"] = fmt.Sprintf("invalid config: %v", err)
+	} else {
+		ack["revision"] = msg.Revision
+		if err := d.applyAgentConfig(msg.Config); err != nil {
+			ack["error"] = err.Error()
+			zap.L().Sugar().Warnf("Failed to apply agent config revision %d: %v", msg.Revision, err)
+		} else {
+			zap.L().Sugar().Infof("Applied agent config revision %d", msg.Revision)
+		}
+	}
+
+	dataBytes, _ := json.Marshal(ack)
+	return d.manager.Send("agent_config_ack", resp.ReqId, dataBytes)
+}
+
+// applyAgentConfig 应用配置，先整体校验再修改，避免部分生效
+//
+// 控制端未设置的字段恢复为本地默认值，因此撤销控制端的覆盖配置后节点会回到启动时的状态。
+func (d *Daemon) applyAgentConfig(cfg agentConfig) error {
+	for name, v := range map[string]*int{
+		"report_interval":       cfg.ReportInterval,
+		"heartbeat_interval":    cfg.HeartbeatInterval,
+		"heartbeat_timeout":     cfg.HeartbeatTimeout,
+		"shell_timeout":         cfg.ShellTimeout,
+		"terminal_idle_timeout": cfg.TerminalIdleTimeout,
+	} {
+		if v != nil && *v <= 0 {
+			return fmt.Errorf("%s must be positive", name)
+		}
+	}
+
+	// 心跳超时不大于心跳间隔时，控制端会让节点在在线和离线之间反复切换
+	heartbeatInterval := secondsOr(cfg.HeartbeatInterval, defaultHeartbeatInterval)
+	heartbeatTimeout := secondsOr(cfg.HeartbeatTimeout, defaultHeartbeatTimeout)
+	if heartbeatTimeout <= heartbeatInterval {
+		return fmt.Errorf("heartbeat_timeout (%v) must be greater than heartbeat_interval (%v)", heartbeatTimeout, heartbeatInterval)
+	}
+
+	probes := d.localProbes
+	if cfg.Probes != nil {
+		probes = *cfg.Probes
+	}
+	if err := monitor.ValidateProbes(probes); err != nil {
+		return err
+	}
+
+	d.reporter.SetInterval(secondsOr(cfg.ReportInterval, defaultReportInterval))
+	d.manager.SetHeartbeat(heartbeatInterval, heartbeatTimeout)
+
+	d.mu.Lock()
+	d.shellTimeout = secondsOr(cfg.ShellTimeout, defaultShellTimeout)
+	d.terminalIdleTimeout = secondsOr(cfg.TerminalIdleTimeout, defaultTerminalIdleTimeout)
+	d.mu.Unlock()
+
+	diskMounts := d.config.DiskMounts
+	if cfg.DiskMounts != nil {
+		diskMounts = *cfg.DiskMounts
+	}
+	d.monitor.SetDiskMounts(diskMounts)
+
+	includeVirtual := d.config.IncludeVirtualNICs
+	if cfg.IncludeVirtualInterfaces != nil {
+		includeVirtual = *cfg.IncludeVirtualInterfaces
+	}
+	exclude := d.config.ExcludeNICs
+	if cfg.ExcludeInterfaces != nil {
+		exclude = *cfg.ExcludeInterfaces
+	}
+	d.monitor.SetNetworkFilter(includeVirtual, exclude)
+
+	return d.monitor.SetProbes(probes)
+}
+
+// secondsOr 将秒数转换为 time.Duration，未设置时返回默认值
+func secondsOr(n *int, def time.Duration) time.Duration {
+	if n == nil {
+		return def
+	}
+	return time.Duration(*n) * time.Second
+}
@@ -7,23 +7,58 @@ import (
 	"os"
 	"os/exec"
 	"os/signal"
+	"sync"
 	"syscall"
 	"time"
 
 	"domclusterd/config"
 	"domclusterd/connections"
 	"domclusterd/dockerctl"
+	"domclusterd/files"
+	"domclusterd/logship"
 	"domclusterd/monitor"
+	"domclusterd/reconcile"
+	"domclusterd/streams"
+	"domclusterd/transfer"
 
 	pb "domcluster/api/proto"
 	"go.uber.org/zap"
 )
 
+// 运行时配置的本地默认值，控制端未下发对应字段时使用
+const (
+	defaultReportInterval    = 5 * time.Second
+	defaultHeartbeatInterval = 5 * time.Second
+	defaultHeartbeatTimeout  = 15 * time.Second
+	defaultShellTimeout      = 30 * time.Second
+	// defaultTerminalIdleTimeout 交互式终端无输入多久后关闭
+	defaultTerminalIdleTimeout = 30 * time.Minute
+	// dockerQueryTimeout 镜像查询、打标签等操作的超时时间
+	dockerQueryTimeout = 30 * time.Second
+	// dockerCleanupTimeout 删除和清理镜像的超时时间
+	dockerCleanupTimeout = 5 * time.Minute
+	// dockerCreateTimeout 创建和重建容器的超时时间，包括按需拉取镜像
+	dockerCreateTimeout = 30 * time.Minute
+)
+
 // Daemon 守护进程
 type Daemon struct {
-	manager    *connections.Manager
-	startTime  time.Time
-	docker     *dockerctl.DockerClient
+	config    *config.Config
+	manager   *connections.Manager
+	startTime time.Time
+	docker    *dockerctl.DockerClient
+	monitor   *monitor.Monitor
+	reporter  *monitor.StatusReporter
+	streams   *streams.Registry
+	// reconciler 为 nil 表示 Docker 不可用或未启用期望状态收敛
+	reconciler *reconcile.Reconciler
+	// localProbes 配置文件中定义的健康探针，控制端未下发探针配置时使用
+	localProbes []monitor.ProbeSpec
+
+	// 以下配置可由控制端在运行时下发修改
+	mu                  sync.RWMutex
+	shellTimeout        time.Duration
+	terminalIdleTimeout time.Duration
 }
 
 // NewDaemon 创建守护进程
@@ -41,6 +76,11 @@ func NewDaemon(nodeID, nodeName string) (*Daemon, error) {
 		Timeout:  cfg.GetTimeout(),
 	})
 
+	probes, err := localProbes(cfg)
+	if err != nil {
+		zap.L().Sugar().Warnf("Invalid health probe config, local probes disabled: %v", err)
+	}
+
 	// 初始化 Docker 客户端
 	dockerClient, err := dockerctl.NewDockerClient()
 	if err != nil {
@@ -49,9 +89,13 @@ func NewDaemon(nodeID, nodeName string) (*Daemon, error) {
 	}
 
 	return &Daemon{
-		manager:    manager,
-		startTime:  time.Now(),
-		docker:     dockerClient,
+		config:              cfg,
+		manager:             manager,
+		startTime:           time.Now(),
+		docker:              dockerClient,
+		localProbes:         probes,
+		shellTimeout:        defaultShellTimeout,
+		terminalIdleTimeout: defaultTerminalIdleTimeout,
 	}, nil
 }
 
@@ -65,26 +109,43 @@ func (d *Daemon) Run(ctx context.Context, nodeID, nodeName string) error {
 
 	zap.L().Sugar().Infof("Daemon started with PID: %d, NodeID: %s", os.Getpid(), nodeID)
 
-	// 启动连接管理器
-	if err := d.manager.Start(ctx, nodeID, nodeName); err != nil {
-		return fmt.Errorf("failed to start connection manager: %w", err)
-	}
-
 	// 创建监控器
 	m := monitor.NewMonitor(ctx)
+	m.SetDiskMounts(d.config.GetDiskMounts())
+	m.SetNetworkFilter(d.config.IncludeVirtualNICs, d.config.ExcludeNICs)
+	m.SetDockerClient(d.docker)
+	if err := m.SetProbes(d.localProbes); err != nil {
+		zap.L().Sugar().Warnf("Invalid health probe config, local probes disabled: %v", err)
+	}
+	d.monitor = m
 
-	// 创建并启动状态报告器（定时上报）
+	// 创建状态报告器（连接建立后开始定时上报）
 	reporter := monitor.NewStatusReporter(m, d.manager)
-	go reporter.Start(5 * time.Second)
 	defer reporter.Stop()
+	d.reporter = reporter
+
+	// 注册控制端配置下发处理器
+	d.manager.RegisterHandler("agent_config", d.handleAgentConfig)
 
 	// 创建并注册查询处理器
 	queryHandler := monitor.NewQueryHandler(m, d.manager)
 	queryHandler.Register()
 	defer queryHandler.Stop()
 
+	// 创建流式命令注册表
+	d.streams = streams.NewRegistry(ctx, d.manager)
+
 	// 注册 Docker 处理器
 	if d.docker != nil {
+		if d.config.Reconcile.Enabled {
+			reconciler, err := reconcile.NewReconciler(reconcileOptions(d.config), d.manager, d.docker)
+			if err != nil {
+				zap.L().Sugar().Errorf("Failed to start desired state reconciler: %v", err)
+			} else {
+				d.reconciler = reconciler
+			}
+		}
+
 		dockerHandler := dockerctl.NewHandler(d.docker)
 		dockerCommands := []string{"docker_list", "docker_start", "docker_stop", "docker_restart", "docker_logs", "docker_stats", "docker_inspect"}
 
@@ -95,13 +156,37 @@ func (d *Daemon) Run(ctx context.Context, nodeID, nodeName string) error {
 				if err := json.Unmarshal(resp.Data, &data); err != nil {
 					return err
 				}
+				containerID, _ := data["container_id"].(string)
+				done := d.reconciler.Track(command, containerID)
 				result, err := dockerHandler.HandleCommand(command, data)
+				done(command != "docker_stop", err)
 				if err != nil {
 					return err
 				}
 				return d.manager.Send("docker_response", resp.ReqId, result)
 			})
 		}
+		d.streams.Handle("docker_logs_follow", func(ctx context.Context, data []byte, st *streams.Stream) error {
+			return dockerHandler.FollowLogs(ctx, data, st.Send)
+		})
+		d.streams.Handle("docker_stats_stream", func(ctx context.Context, data []byte, st *streams.Stream) error {
+			return dockerHandler.StreamStats(ctx, data, st.Send)
+		})
+		d.streams.Handle("docker_exec_interactive", func(ctx context.Context, data []byte, st *streams.Stream) error {
+			return dockerHandler.InteractiveExec(ctx, data, st.Input(), st.Send)
+		})
+		d.streams.Handle("docker_image_pull", func(ctx context.Context, data []byte, st *streams.Stream) error {
+			return dockerHandler.PullImage(ctx, data, st.Send)
+		})
+		d.manager.RegisterQueryHandler(ctx, "docker_image_list", dockerQueryTimeout, dockerHandler.ListImagesQuery)
+		d.manager.RegisterQueryHandler(ctx, "docker_image_inspect", dockerQueryTimeout, dockerHandler.InspectImageQuery)
+		d.manager.RegisterQueryHandler(ctx, "docker_image_tag", dockerQueryTimeout, dockerHandler.TagImageQuery)
+		d.manager.RegisterQueryHandler(ctx, "docker_image_remove", dockerCleanupTimeout, dockerHandler.RemoveImageQuery)
+		d.manager.RegisterQueryHandler(ctx, "docker_image_prune", dockerCleanupTimeout, dockerHandler.PruneImagesQuery)
+		d.manager.RegisterQueryHandler(ctx, "docker_create", dockerCreateTimeout, d.reconciler.TrackQuery("docker_create", dockerHandler.CreateContainerQuery))
+		d.manager.RegisterQueryHandler(ctx, "docker_remove", dockerCleanupTimeout, d.reconciler.TrackQuery("docker_remove", dockerHandler.RemoveContainerQuery))
+		d.manager.RegisterQueryHandler(ctx, "docker_recreate", dockerCreateTimeout, d.reconciler.TrackQuery("docker_recreate", dockerHandler.RecreateContainerQuery))
+		d.manager.RegisterQueryHandler(ctx, "docker_managed", dockerQueryTimeout, dockerHandler.ListManagedQuery)
 		zap.L().Sugar().Info("Docker handlers registered")
 	} else {
 		// Docker 客户端不可用时，注册统一的错误 handler
@@ -116,9 +201,30 @@ func (d *Daemon) Run(ctx context.Context, nodeID, nodeName string) error {
 				return d.manager.Send("docker_response", resp.ReqId, dataBytes)
 			})
 		}
+		for _, cmd := range []string{"docker_logs_follow", "docker_stats_stream", "docker_exec_interactive", "docker_image_pull"} {
+			d.streams.Handle(cmd, func(ctx context.Context, data []byte, st *streams.Stream) error {
+				return fmt.Errorf("Docker client not available on this node")
+			})
+		}
+		for _, cmd := range []string{"docker_image_list", "docker_image_inspect", "docker_image_tag", "docker_image_remove", "docker_image_prune", "docker_create", "docker_remove", "docker_recreate", "docker_managed"} {
+			d.manager.RegisterQueryHandler(ctx, cmd, dockerQueryTimeout, func(ctx context.Context, data []byte) (interface{}, error) {
+				return nil, fmt.Errorf("Docker client not available on this node")
+			})
+		}
 		zap.L().Sugar().Warn("Docker client not available, Docker handlers registered with error responses")
 	}
 
+	// 注册期望状态处理器，收敛循环在连接控制端之前启动，断线期间节点仍会收敛
+	if d.reconciler != nil {
+		d.reconciler.Register(ctx)
+	} else {
+		for _, cmd := range []string{"desired_set", "desired_status"} {
+			d.manager.RegisterQueryHandler(ctx, cmd, dockerQueryTimeout, func(ctx context.Context, data []byte) (interface{}, error) {
+				return nil, fmt.Errorf("desired state reconciliation is not available on this node")
+			})
+		}
+	}
+
 	// 注册 Shell 执行处理器
 	d.manager.RegisterHandler("shell_exec", func(resp *pb.PublishResponse) error {
 		var data map[string]interface{}
@@ -136,7 +242,10 @@ func (d *Daemon) Run(ctx context.Context, nodeID, nodeName string) error {
 		}
 
 		// 执行命令
-		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		d.mu.RLock()
+		shellTimeout := d.shellTimeout
+		d.mu.RUnlock()
+		ctx, cancel := context.WithTimeout(context.Background(), shellTimeout)
 		defer cancel()
 
 		cmd := exec.CommandContext(ctx, "sh", "-c", command)
@@ -158,6 +267,33 @@ func (d *Daemon) Run(ctx context.Context, nodeID, nodeName string) error {
 	})
 	zap.L().Sugar().Info("Shell exec handler registered")
 
+	// 注册交互式终端处理器
+	d.streams.Handle("shell_session", d.handleShellSession)
+
+	// 注册文件传输处理器，写入节点文件系统时与文件浏览使用相同的写入根目录
+	writeRoots := files.NewWriteRoots(filesOptions(d.config).WriteRoots)
+	transfer.NewHandler(transferOptions(d.config), d.manager, d.docker, writeRoots).Register(ctx)
+
+	// 注册文件浏览处理器
+	files.NewHandler(writeRoots, d.manager).Register(ctx)
+
+	// 处理器全部注册完成后再连接控制端，避免注册后立即下发的命令没有处理器
+	d.manager.SetLabels(d.config.Labels)
+	if err := d.manager.Start(ctx, nodeID, nodeName); err != nil {
+		return fmt.Errorf("failed to start connection manager: %w", err)
+	}
+	go reporter.Start(defaultReportInterval)
+
+	// 启动日志采集上报
+	if d.config.LogShipping.Enabled {
+		shipper, err := logship.NewShipper(logShippingOptions(d.config), d.manager, d.docker, nodeID, nodeName, d.config.Labels)
+		if err != nil {
+			zap.L().Sugar().Errorf("Failed to start log shipping: %v", err)
+		} else {
+			shipper.Start(ctx)
+		}
+	}
+
 	zap.L().Sugar().Info("Daemon running...")
 
 	// 等待停止信号
@@ -251,9 +387,9 @@ func Restart() error {
 	}
 
 	cmd := exec.Command(executable, "daemon")
-	cmd.Stdin = nil   // 不从终端读取
-	cmd.Stdout = nil  // 不输出到终端
-	cmd.Stderr = nil  // 不输出到终端
+	cmd.Stdin = nil  // 不从终端读取
+	cmd.Stdout = nil // 不输出到终端
+	cmd.Stderr = nil // 不输出到终端
 
 	if err := cmd.Start(); err != nil {
 		return fmt.Errorf("failed to start daemon: %w", err)
@@ -261,4 +397,4 @@ func Restart() error {
 
 	zap.L().Sugar().Info("Daemon restarted")
 	return nil
-}
\ No newline at end of file
+}
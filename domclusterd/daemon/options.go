@@ -0,0 +1,70 @@
+package daemon
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"domclusterd/config"
+	"domclusterd/files"
+	"domclusterd/logship"
+	"domclusterd/monitor"
+	"domclusterd/reconcile"
+	"domclusterd/transfer"
+)
+
+// logShippingOptions 由配置构建日志采集选项
+func logShippingOptions(cfg *config.Config) logship.Options {
+	c := cfg.LogShipping
+	return logship.Options{
+		Enabled:      c.Enabled,
+		Containers:   c.Containers,
+		AgentLog:     c.AgentLog,
+		AgentLogPath: c.AgentLogPath,
+		SpoolDir:     c.SpoolDir,
+		SpoolMaxMB:   c.SpoolMaxMB,
+		BatchSize:    c.BatchSize,
+		FlushSeconds: c.FlushSeconds,
+	}
+}
+
+// transferOptions 由配置构建文件传输选项
+func transferOptions(cfg *config.Config) transfer.Options {
+	return transfer.Options{
+		StagingDir:     cfg.Transfer.StagingDir,
+		MaxSizeMB:      cfg.Transfer.MaxSizeMB,
+		MaxImageSizeMB: cfg.Transfer.MaxImageSizeMB,
+	}
+}
+
+// filesOptions 由配置构建文件浏览选项
+func filesOptions(cfg *config.Config) files.Options {
+	return files.Options{
+		WriteRoots: cfg.Files.WriteRoots,
+	}
+}
+
+// reconcileOptions 由配置构建期望状态收敛选项
+func reconcileOptions(cfg *config.Config) reconcile.Options {
+	return reconcile.Options{
+		Enabled:         cfg.Reconcile.Enabled,
+		StatePath:       cfg.Reconcile.StatePath,
+		IntervalSeconds: cfg.Reconcile.IntervalSeconds,
+	}
+}
+
+// localProbes 将配置文件中的探针定义解码为 monitor.ProbeSpec，字段名与控制端下发的 JSON 一致
+func localProbes(cfg *config.Config) ([]monitor.ProbeSpec, error) {
+	if len(cfg.Probes) == 0 {
+		return nil, nil
+	}
+
+	data, err := json.Marshal(cfg.Probes)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode probe config: %w", err)
+	}
+	var probes []monitor.ProbeSpec
+	if err := json.Unmarshal(data, &probes); err != nil {
+		return nil, fmt.Errorf("failed to decode probe config: %w", err)
+	}
+	return probes, nil
+}
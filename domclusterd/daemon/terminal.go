@@ -0,0 +1,50 @@
+package daemon
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"domclusterd/shell"
+	"domclusterd/streams"
+	"domclusterd/terminal"
+
+	"go.uber.org/zap"
+)
+
+// handleShellSession 在 PTY 上运行交互式 shell，直到 shell 退出、控制端取消或空闲超时
+//
+// 请求字段：cols、rows、shell、dir。消息格式见 terminal.Relay，shell 退出时额外推送
+// {"type":"exit","exit_code"}。
+func (d *Daemon) handleShellSession(ctx context.Context, data []byte, st *streams.Stream) error {
+	var req struct {
+		Cols  uint   `json:"cols"`
+		Rows  uint   `json:"rows"`
+		Shell string `json:"shell"`
+		Dir   string `json:"dir"`
+	}
+	if err := json.Unmarshal(data, &req); err != nil {
+		return fmt.Errorf("invalid request: %w", err)
+	}
+
+	session, err := shell.StartSession(shell.SessionOptions{
+		Shell: req.Shell,
+		Dir:   req.Dir,
+		Cols:  req.Cols,
+		Rows:  req.Rows,
+	})
+	if err != nil {
+		return err
+	}
+	defer session.Close()
+
+	d.mu.RLock()
+	idleTimeout := d.terminalIdleTimeout
+	d.mu.RUnlock()
+
+	zap.L().Sugar().Infof("Shell session %s started", st.ID())
+	if err := terminal.Relay(ctx, session, st.Input(), st.Send, idleTimeout); err != nil || ctx.Err() != nil {
+		return err
+	}
+	return st.Send(map[string]interface{}{"type": "exit", "exit_code": session.ExitCode()})
+}
@@ -14,6 +14,7 @@ import (
 // ContainerInfo 容器信息
 type ContainerInfo struct {
 	ID         string
+	FullID     string
 	Name       string
 	Image      string
 	Status     string
@@ -71,7 +72,8 @@ func (dc *DockerClient) ListContainers(all bool) ([]ContainerInfo, error) {
 		}
 
 		result = append(result, ContainerInfo{
-			ID:       c.ID[:12],
+			ID:       shortID(c.ID),
+			FullID:   c.ID,
 			Name:     c.Names[0],
 			Image:    c.Image,
 			Status:   c.Status,
@@ -141,19 +143,6 @@ func (dc *DockerClient) RestartContainer(containerID string, timeout int) error
 	return nil
 }
 
-// RemoveContainer 删除容器
-func (dc *DockerClient) RemoveContainer(containerID string, force bool) error {
-	ctx := context.Background()
-	err := dc.cli.ContainerRemove(ctx, containerID, container.RemoveOptions{
-		Force: force,
-	})
-	if err != nil {
-		return fmt.Errorf("failed to remove container: %w", err)
-	}
-	zap.L().Sugar().Infof("Container %s removed", containerID)
-	return nil
-}
-
 // GetContainerLogs 获取容器日志
 func (dc *DockerClient) GetContainerLogs(containerID string, tail string) (string, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), dc.defaultTimeout)
@@ -209,6 +198,14 @@ func (dc *DockerClient) GetContainerStats(containerID string) ([]byte, error) {
 	return result, nil
 }
 
+// shortID 返回容器 ID 的前 12 位，与 docker ps 显示的一致
+func shortID(id string) string {
+	if len(id) > 12 {
+		return id[:12]
+	}
+	return id
+}
+
 // Close 关闭客户端连接
 func (dc *DockerClient) Close() error {
 	return dc.cli.Close()
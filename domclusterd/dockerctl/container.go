@@ -0,0 +1,357 @@
+package dockerctl
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	cerrdefs "github.com/containerd/errdefs"
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/filters"
+	"go.uber.org/zap"
+)
+
+const (
+	// defaultStopTimeout 重建容器时等待旧容器退出的默认秒数
+	defaultStopTimeout = 10
+	// restoreTimeout 重建失败后恢复旧容器的超时时间，请求本身超时或取消时也要完成恢复
+	restoreTimeout = 30 * time.Second
+)
+
+// ContainerResult 创建或重建容器的结果
+type ContainerResult struct {
+	ContainerID string   `json:"container_id"`
+	Name        string   `json:"name"`
+	SpecHash    string   `json:"spec_hash"`
+	Started     bool     `json:"started"`
+	Warnings    []string `json:"warnings,omitempty"`
+	// Replaced 重建时被替换的旧容器 ID
+	Replaced string `json:"replaced,omitempty"`
+	// Unchanged 容器已与声明一致，未重建
+	Unchanged bool `json:"unchanged,omitempty"`
+}
+
+// RecreateOptions 重建容器的选项
+type RecreateOptions struct {
+	// Start 创建后是否启动
+	Start bool
+	// StopTimeout 停止旧容器时等待的秒数
+	StopTimeout int
+	// OnlyIfChanged 已有容器的声明哈希相同时不重建
+	OnlyIfChanged bool
+}
+
+// ManagedContainer 按声明创建的容器及其运行状态
+type ManagedContainer struct {
+	ID       string            `json:"id"`
+	Name     string            `json:"name"`
+	Image    string            `json:"image"`
+	ImageID  string            `json:"image_id"`
+	SpecHash string            `json:"spec_hash"`
+	Labels   map[string]string `json:"labels,omitempty"`
+	// State created、running、restarting、exited 等
+	State string `json:"state"`
+	// Health starting、healthy、unhealthy，没有健康检查时为空
+	Health       string `json:"health,omitempty"`
+	ExitCode     int    `json:"exit_code"`
+	RestartCount int    `json:"restart_count"`
+	StartedAt    string `json:"started_at,omitempty"`
+}
+
+// EnsureImage 按拉取策略确保镜像在本机可用
+func (dc *DockerClient) EnsureImage(ctx context.Context, ref, policy string) error {
+	if policy != PullAlways {
+		_, err := dc.cli.ImageInspect(ctx, ref)
+		if err == nil {
+			return nil
+		}
+		if !cerrdefs.IsNotFound(err) {
+			return fmt.Errorf("failed to inspect image: %w", err)
+		}
+		if policy == PullNever {
+			return fmt.Errorf("image %s is not present on this node and pull_policy is never", ref)
+		}
+	}
+	return dc.PullImage(ctx, ref, "", func(string, LayerProgress) {})
+}
+
+// CreateContainer 按声明创建容器，start 为 true 时创建后启动
+//
+// 启动失败时保留已创建的容器以便查看原因，返回的错误中包含容器 ID。
+func (dc *DockerClient) CreateContainer(ctx context.Context, spec *ContainerSpec, start bool) (*ContainerResult, error) {
+	if err := ValidateSpec(spec); err != nil {
+		return nil, err
+	}
+	if err := dc.EnsureImage(ctx, spec.Image, spec.PullPolicy); err != nil {
+		return nil, err
+	}
+	return dc.createContainer(ctx, spec, start)
+}
+
+// createContainer 创建容器，调用方已检查声明并准备好镜像
+func (dc *DockerClient) createContainer(ctx context.Context, spec *ContainerSpec, start bool) (*ContainerResult, error) {
+	cfg, hostCfg, err := containerConfig(spec)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := dc.cli.ContainerCreate(ctx, cfg, hostCfg, nil, nil, spec.Name)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create container: %w", err)
+	}
+	zap.L().Sugar().Infof("Container %s created (%s)", spec.Name, resp.ID)
+
+	result := &ContainerResult{
+		ContainerID: resp.ID,
+		Name:        spec.Name,
+		SpecHash:    spec.Hash(),
+		Warnings:    resp.Warnings,
+	}
+	if start {
+		if err := dc.startCreated(ctx, result); err != nil {
+			return nil, err
+		}
+	}
+	return result, nil
+}
+
+// startCreated 启动刚创建的容器
+func (dc *DockerClient) startCreated(ctx context.Context, result *ContainerResult) error {
+	if err := dc.cli.ContainerStart(ctx, result.ContainerID, container.StartOptions{}); err != nil {
+		return fmt.Errorf("container %s created but failed to start: %w", result.ContainerID, err)
+	}
+	result.Started = true
+	zap.L().Sugar().Infof("Container %s started", result.Name)
+	return nil
+}
+
+// RemoveContainer 删除容器，force 为 true 时同时停止运行中的容器，volumes 为 true 时删除匿名卷
+func (dc *DockerClient) RemoveContainer(ctx context.Context, containerID string, force, volumes bool) error {
+	err := dc.cli.ContainerRemove(ctx, containerID, container.RemoveOptions{
+		Force:         force,
+		RemoveVolumes: volumes,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to remove container: %w", err)
+	}
+	zap.L().Sugar().Infof("Container %s removed", containerID)
+	return nil
+}
+
+// RecreateContainer 按声明重建同名容器，不存在时直接创建
+//
+// 先准备好镜像再停止旧容器，镜像不可用时旧容器保持运行。旧容器停止后改名保留，
+// 新容器创建（需要启动时还要启动成功）后才删除；失败时删除新容器，恢复旧容器的名称和运行状态。
+func (dc *DockerClient) RecreateContainer(ctx context.Context, spec *ContainerSpec, opts RecreateOptions) (*ContainerResult, error) {
+	if err := ValidateSpec(spec); err != nil {
+		return nil, err
+	}
+	if err := dc.EnsureImage(ctx, spec.Image, spec.PullPolicy); err != nil {
+		return nil, err
+	}
+
+	existing, err := dc.cli.ContainerInspect(ctx, spec.Name)
+	if err != nil {
+		if cerrdefs.IsNotFound(err) {
+			return dc.createContainer(ctx, spec, opts.Start)
+		}
+		return nil, fmt.Errorf("failed to inspect container: %w", err)
+	}
+
+	hash := spec.Hash()
+	if opts.OnlyIfChanged && existing.Config != nil && existing.Config.Labels[LabelSpecHash] == hash {
+		result := &ContainerResult{
+			ContainerID: existing.ID,
+			Name:        spec.Name,
+			SpecHash:    hash,
+			Started:     existing.State != nil && existing.State.Running,
+			Unchanged:   true,
+		}
+		if opts.Start && !result.Started {
+			if err := dc.cli.ContainerStart(ctx, existing.ID, container.StartOptions{}); err != nil {
+				return nil, fmt.Errorf("failed to start container: %w", err)
+			}
+			result.Started = true
+		}
+		return result, nil
+	}
+
+	timeout := opts.StopTimeout
+	if timeout <= 0 {
+		timeout = defaultStopTimeout
+	}
+	wasRunning := existing.State != nil && existing.State.Running
+	if err := dc.cli.ContainerStop(ctx, existing.ID, container.StopOptions{Timeout: &timeout}); err != nil {
+		return nil, fmt.Errorf("failed to stop container: %w", err)
+	}
+
+	aside := fmt.Sprintf("%s-replaced-%s", spec.Name, shortID(existing.ID))
+	if err := dc.cli.ContainerRename(ctx, existing.ID, aside); err != nil {
+		err = fmt.Errorf("failed to rename old container: %w", err)
+		return nil, dc.restoreContainer(ctx, existing.ID, "", wasRunning, err)
+	}
+
+	result, err := dc.createContainer(ctx, spec, false)
+	if err == nil && opts.Start {
+		if err = dc.startCreated(ctx, result); err != nil {
+			if rmErr := dc.RemoveContainer(context.WithoutCancel(ctx), result.ContainerID, true, false); rmErr != nil {
+				zap.L().Sugar().Warnf("Failed to remove new container %s after failed start: %v", result.ContainerID, rmErr)
+			}
+		}
+	}
+	if err != nil {
+		return nil, dc.restoreContainer(ctx, existing.ID, spec.Name, wasRunning, err)
+	}
+
+	if err := dc.RemoveContainer(ctx, existing.ID, true, false); err != nil {
+		result.Warnings = append(result.Warnings, fmt.Sprintf("old container %s was kept as %s: %v", shortID(existing.ID), aside, err))
+	}
+	result.Replaced = existing.ID
+	return result, nil
+}
+
+// restoreContainer 重建失败后恢复旧容器：name 不为空时改回原名称，原来在运行时重新启动
+//
+// 返回包含原始错误的错误，恢复失败时一并说明。
+func (dc *DockerClient) restoreContainer(ctx context.Context, id, name string, start bool, cause error) error {
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), restoreTimeout)
+	defer cancel()
+
+	if name != "" {
+		if err := dc.cli.ContainerRename(ctx, id, name); err != nil {
+			return fmt.Errorf("%w; failed to restore old container %s: %v", cause, shortID(id), err)
+		}
+	}
+	if start {
+		if err := dc.cli.ContainerStart(ctx, id, container.StartOptions{}); err != nil {
+			return fmt.Errorf("%w; failed to restart old container %s: %v", cause, shortID(id), err)
+		}
+	}
+	zap.L().Sugar().Infof("Restored container %s after failed recreate: %v", shortID(id), cause)
+	return cause
+}
+
+// ListManagedContainers 列出按声明创建的容器，labels 不为空时只返回标签全部匹配的容器
+func (dc *DockerClient) ListManagedContainers(ctx context.Context, labels map[string]string) ([]ManagedContainer, error) {
+	args := filters.NewArgs(filters.Arg("label", LabelManaged+"=true"))
+	for k, v := range labels {
+		args.Add("label", k+"="+v)
+	}
+	list, err := dc.cli.ContainerList(ctx, container.ListOptions{All: true, Filters: args})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list containers: %w", err)
+	}
+
+	result := make([]ManagedContainer, 0, len(list))
+	for _, c := range list {
+		// 健康状态和重启次数只在详情中提供
+		info, err := dc.cli.ContainerInspect(ctx, c.ID)
+		if err != nil {
+			if cerrdefs.IsNotFound(err) {
+				continue
+			}
+			return nil, fmt.Errorf("failed to inspect container: %w", err)
+		}
+
+		mc := ManagedContainer{
+			ID:           info.ID,
+			Name:         strings.TrimPrefix(info.Name, "/"),
+			ImageID:      info.Image,
+			RestartCount: info.RestartCount,
+		}
+		if info.Config != nil {
+			mc.Image = info.Config.Image
+			mc.Labels = info.Config.Labels
+			mc.SpecHash = info.Config.Labels[LabelSpecHash]
+		}
+		if info.State != nil {
+			mc.State = string(info.State.Status)
+			mc.ExitCode = info.State.ExitCode
+			mc.StartedAt = info.State.StartedAt
+			if info.State.Health != nil {
+				mc.Health = string(info.State.Health.Status)
+			}
+		}
+		result = append(result, mc)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Name < result[j].Name })
+	return result, nil
+}
+
+// CreateContainerQuery 处理 docker_create 查询
+func (h *Handler) CreateContainerQuery(ctx context.Context, data []byte) (interface{}, error) {
+	var req struct {
+		Spec  *ContainerSpec `json:"spec"`
+		Start bool           `json:"start"`
+	}
+	if err := json.Unmarshal(data, &req); err != nil {
+		return nil, fmt.Errorf("invalid request: %w", err)
+	}
+	if req.Spec == nil {
+		return nil, fmt.Errorf("missing spec")
+	}
+	return h.client.CreateContainer(ctx, req.Spec, req.Start)
+}
+
+// RemoveContainerQuery 处理 docker_remove 查询
+func (h *Handler) RemoveContainerQuery(ctx context.Context, data []byte) (interface{}, error) {
+	var req struct {
+		ContainerID string `json:"container_id"`
+		Force       bool   `json:"force"`
+		Volumes     bool   `json:"volumes"`
+	}
+	if err := json.Unmarshal(data, &req); err != nil {
+		return nil, fmt.Errorf("invalid request: %w", err)
+	}
+	if req.ContainerID == "" {
+		return nil, fmt.Errorf("missing container_id")
+	}
+	if err := h.client.RemoveContainer(ctx, req.ContainerID, req.Force, req.Volumes); err != nil {
+		return nil, err
+	}
+	return map[string]interface{}{
+		"message":      "container removed",
+		"container_id": req.ContainerID,
+	}, nil
+}
+
+// RecreateContainerQuery 处理 docker_recreate 查询，start 默认为 true
+func (h *Handler) RecreateContainerQuery(ctx context.Context, data []byte) (interface{}, error) {
+	var req struct {
+		Spec          *ContainerSpec `json:"spec"`
+		Start         *bool          `json:"start"`
+		StopTimeout   int            `json:"stop_timeout"`
+		OnlyIfChanged bool           `json:"only_if_changed"`
+	}
+	if err := json.Unmarshal(data, &req); err != nil {
+		return nil, fmt.Errorf("invalid request: %w", err)
+	}
+	if req.Spec == nil {
+		return nil, fmt.Errorf("missing spec")
+	}
+	return h.client.RecreateContainer(ctx, req.Spec, RecreateOptions{
+		Start:         req.Start == nil || *req.Start,
+		StopTimeout:   req.StopTimeout,
+		OnlyIfChanged: req.OnlyIfChanged,
+	})
+}
+
+// ListManagedQuery 处理 docker_managed 查询
+func (h *Handler) ListManagedQuery(ctx context.Context, data []byte) (interface{}, error) {
+	var req struct {
+		Labels map[string]string `json:"labels"`
+	}
+	if len(data) > 0 {
+		if err := json.Unmarshal(data, &req); err != nil {
+			return nil, fmt.Errorf("invalid request: %w", err)
+		}
+	}
+	containers, err := h.client.ListManagedContainers(ctx, req.Labels)
+	if err != nil {
+		return nil, err
+	}
+	return map[string]interface{}{"containers": containers}, nil
+}
@@ -0,0 +1,85 @@
+package dockerctl
+
+import (
+	"archive/tar"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"time"
+
+	"github.com/docker/docker/api/types/container"
+)
+
+// PathStat 容器内路径的信息
+type PathStat struct {
+	Name  string
+	Size  int64
+	Mode  os.FileMode
+	Mtime time.Time
+}
+
+// StatContainerPath 获取容器内路径的信息
+func (dc *DockerClient) StatContainerPath(ctx context.Context, containerID, p string) (*PathStat, error) {
+	stat, err := dc.cli.ContainerStatPath(ctx, containerID, p)
+	if err != nil {
+		return nil, fmt.Errorf("failed to stat %s in container: %w", p, err)
+	}
+	return &PathStat{Name: stat.Name, Size: stat.Size, Mode: stat.Mode, Mtime: stat.Mtime}, nil
+}
+
+// CopyFileToContainer 将 src 中的 size 字节写入容器内的 p，父目录必须已存在
+func (dc *DockerClient) CopyFileToContainer(ctx context.Context, containerID, p string, src io.Reader, size int64, mode os.FileMode) error {
+	pr, pw := io.Pipe()
+	go func() {
+		tw := tar.NewWriter(pw)
+		err := tw.WriteHeader(&tar.Header{
+			Typeflag: tar.TypeReg,
+			Name:     path.Base(p),
+			Mode:     int64(mode.Perm()),
+			Size:     size,
+			ModTime:  time.Now(),
+		})
+		if err == nil {
+			_, err = io.CopyN(tw, src, size)
+		}
+		if err == nil {
+			err = tw.Close()
+		}
+		pw.CloseWithError(err)
+	}()
+	defer pr.Close()
+
+	if err := dc.cli.CopyToContainer(ctx, containerID, path.Dir(p), pr, container.CopyToContainerOptions{}); err != nil {
+		return fmt.Errorf("failed to copy to container: %w", err)
+	}
+	return nil
+}
+
+// CopyFileFromContainer 将容器内的普通文件 p 写入 dst，文件超过 maxSize 字节时返回错误
+func (dc *DockerClient) CopyFileFromContainer(ctx context.Context, containerID, p string, dst io.Writer, maxSize int64) (int64, os.FileMode, error) {
+	rc, stat, err := dc.cli.CopyFromContainer(ctx, containerID, p)
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to copy from container: %w", err)
+	}
+	defer rc.Close()
+
+	if !stat.Mode.IsRegular() {
+		return 0, 0, fmt.Errorf("%s is not a regular file", p)
+	}
+	if maxSize > 0 && stat.Size > maxSize {
+		return 0, 0, fmt.Errorf("%s is %d bytes, exceeds limit of %d bytes", p, stat.Size, maxSize)
+	}
+
+	tr := tar.NewReader(rc)
+	hdr, err := tr.Next()
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to read archive from container: %w", err)
+	}
+	n, err := io.Copy(dst, tr)
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to read %s from container: %w", p, err)
+	}
+	return n, os.FileMode(hdr.Mode).Perm(), nil
+}
@@ -0,0 +1,96 @@
+package dockerctl
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/pkg/stdcopy"
+)
+
+// maxExecOutput 非交互执行时保留的最大输出字节数
+const maxExecOutput = 64 * 1024
+
+// ExecResult 容器内命令的执行结果
+type ExecResult struct {
+	ExitCode int
+	Stdout   string
+	Stderr   string
+}
+
+// ExecCommand 在容器内执行命令并等待其结束，超时由 ctx 控制
+func (dc *DockerClient) ExecCommand(ctx context.Context, containerID string, cmd []string) (*ExecResult, error) {
+	created, err := dc.cli.ContainerExecCreate(ctx, containerID, container.ExecOptions{
+		AttachStdout: true,
+		AttachStderr: true,
+		Cmd:          cmd,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create exec: %w", err)
+	}
+
+	attach, err := dc.cli.ContainerExecAttach(ctx, created.ID, container.ExecAttachOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to attach exec: %w", err)
+	}
+	defer attach.Close()
+
+	// ctx 取消时关闭连接，使阻塞的读取返回
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-ctx.Done():
+			attach.Close()
+		case <-done:
+		}
+	}()
+
+	var stdout, stderr bytes.Buffer
+	_, err = stdcopy.StdCopy(
+		&limitedWriter{w: &stdout, n: maxExecOutput},
+		&limitedWriter{w: &stderr, n: maxExecOutput},
+		attach.Reader,
+	)
+	if ctx.Err() != nil {
+		return nil, fmt.Errorf("exec timed out: %w", ctx.Err())
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read exec output: %w", err)
+	}
+
+	inspect, err := dc.cli.ContainerExecInspect(ctx, created.ID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to inspect exec: %w", err)
+	}
+
+	return &ExecResult{
+		ExitCode: inspect.ExitCode,
+		Stdout:   stdout.String(),
+		Stderr:   stderr.String(),
+	}, nil
+}
+
+// limitedWriter 超出上限的数据直接丢弃，但仍报告写入成功以免中断读取
+type limitedWriter struct {
+	w io.Writer
+	n int
+}
+
+// Write 实现 io.Writer
+func (lw *limitedWriter) Write(p []byte) (int, error) {
+	if lw.n > 0 {
+		chunk := p
+		if len(chunk) > lw.n {
+			chunk = chunk[:lw.n]
+		}
+		written, err := lw.w.Write(chunk)
+		lw.n -= written
+		if err != nil {
+			return written, err
+		}
+	}
+	return len(p), nil
+}
@@ -0,0 +1,152 @@
+package dockerctl
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"domclusterd/terminal"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/container"
+)
+
+const (
+	defaultTermCols = 80
+	defaultTermRows = 24
+)
+
+// defaultExecShell 未指定命令时优先使用 bash，镜像中没有 bash 时退回 sh
+var defaultExecShell = []string{"/bin/sh", "-c", "if command -v bash >/dev/null 2>&1; then exec bash; else exec sh; fi"}
+
+// ExecSessionOptions 交互式执行参数
+type ExecSessionOptions struct {
+	Cmd        []string
+	User       string
+	WorkingDir string
+	Env        []string
+	Cols       uint
+	Rows       uint
+}
+
+// ExecSession 容器内带 TTY 的交互式进程
+type ExecSession struct {
+	ID   string
+	dc   *DockerClient
+	conn types.HijackedResponse
+}
+
+// StartExecSession 在容器内启动带 TTY 的交互式进程
+func (dc *DockerClient) StartExecSession(ctx context.Context, containerID string, opts ExecSessionOptions) (*ExecSession, error) {
+	if len(opts.Cmd) == 0 {
+		opts.Cmd = defaultExecShell
+	}
+	if opts.Cols == 0 || opts.Rows == 0 {
+		opts.Cols, opts.Rows = defaultTermCols, defaultTermRows
+	}
+	size := &[2]uint{opts.Rows, opts.Cols}
+
+	created, err := dc.cli.ContainerExecCreate(ctx, containerID, container.ExecOptions{
+		User:         opts.User,
+		WorkingDir:   opts.WorkingDir,
+		Env:          append([]string{"TERM=xterm-256color"}, opts.Env...),
+		Cmd:          opts.Cmd,
+		Tty:          true,
+		ConsoleSize:  size,
+		AttachStdin:  true,
+		AttachStdout: true,
+		AttachStderr: true,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create exec: %w", err)
+	}
+
+	conn, err := dc.cli.ContainerExecAttach(ctx, created.ID, container.ExecAttachOptions{
+		Tty:         true,
+		ConsoleSize: size,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to attach exec: %w", err)
+	}
+
+	return &ExecSession{ID: created.ID, dc: dc, conn: conn}, nil
+}
+
+// Read 读取终端输出，进程退出后返回 io.EOF
+func (s *ExecSession) Read(p []byte) (int, error) {
+	return s.conn.Reader.Read(p)
+}
+
+// Write 写入终端输入
+func (s *ExecSession) Write(p []byte) (int, error) {
+	return s.conn.Conn.Write(p)
+}
+
+// Resize 调整终端大小
+func (s *ExecSession) Resize(cols, rows uint) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	return s.dc.cli.ContainerExecResize(ctx, s.ID, container.ResizeOptions{Width: cols, Height: rows})
+}
+
+// ExitCode 获取进程退出码，进程仍在运行时返回 -1
+func (s *ExecSession) ExitCode(ctx context.Context) (int, error) {
+	inspect, err := s.dc.cli.ContainerExecInspect(ctx, s.ID)
+	if err != nil {
+		return -1, fmt.Errorf("failed to inspect exec: %w", err)
+	}
+	if inspect.Running {
+		return -1, nil
+	}
+	return inspect.ExitCode, nil
+}
+
+// Close 断开连接，TTY 关闭后容器内的会话进程会收到 SIGHUP
+func (s *ExecSession) Close() {
+	s.conn.Close()
+}
+
+// InteractiveExec 在容器内运行交互式会话，输入来自 input，输出通过 send 推送
+//
+// 消息格式见 terminal.Relay，进程退出时额外推送 {"type":"exit","exit_code"}。
+func (h *Handler) InteractiveExec(ctx context.Context, data []byte, input <-chan []byte, send func(interface{}) error) error {
+	var req struct {
+		ContainerID string   `json:"container_id"`
+		Command     []string `json:"command"`
+		User        string   `json:"user"`
+		WorkingDir  string   `json:"workdir"`
+		Env         []string `json:"env"`
+		Cols        uint     `json:"cols"`
+		Rows        uint     `json:"rows"`
+	}
+	if err := json.Unmarshal(data, &req); err != nil {
+		return fmt.Errorf("invalid request: %w", err)
+	}
+	if req.ContainerID == "" {
+		return fmt.Errorf("missing container_id")
+	}
+
+	session, err := h.client.StartExecSession(ctx, req.ContainerID, ExecSessionOptions{
+		Cmd:        req.Command,
+		User:       req.User,
+		WorkingDir: req.WorkingDir,
+		Env:        req.Env,
+		Cols:       req.Cols,
+		Rows:       req.Rows,
+	})
+	if err != nil {
+		return err
+	}
+	defer session.Close()
+
+	if err := terminal.Relay(ctx, session, input, send, 0); err != nil || ctx.Err() != nil {
+		return err
+	}
+
+	exitCode, err := session.ExitCode(context.Background())
+	if err != nil {
+		return err
+	}
+	return send(map[string]interface{}{"type": "exit", "exit_code": exitCode})
+}
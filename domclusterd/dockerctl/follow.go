@@ -0,0 +1,122 @@
+package dockerctl
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+)
+
+const (
+	// followFlushInterval 跟随日志时合并发送的时间窗口
+	followFlushInterval = 100 * time.Millisecond
+	// followMaxBatch 单条消息最多携带的日志行数
+	followMaxBatch = 200
+)
+
+// followLogLine 推送给控制端的一行日志
+type followLogLine struct {
+	Stream    string `json:"stream"`
+	Timestamp string `json:"ts,omitempty"`
+	Line      string `json:"line"`
+}
+
+// FollowLogs 以 follow 模式读取容器日志并通过 send 推送，直到容器停止或 ctx 取消
+//
+// 日志行按时间窗口合并为 {"type":"logs","lines":[...]} 消息，避免高频输出时逐行发送。
+func (h *Handler) FollowLogs(ctx context.Context, data []byte, send func(interface{}) error) error {
+	var req struct {
+		ContainerID string `json:"container_id"`
+		Since       string `json:"since"`
+		Tail        string `json:"tail"`
+		Timestamps  bool   `json:"timestamps"`
+	}
+	if err := json.Unmarshal(data, &req); err != nil {
+		return fmt.Errorf("invalid request: %w", err)
+	}
+	if req.ContainerID == "" {
+		return fmt.Errorf("missing container_id")
+	}
+	if req.Tail == "" {
+		req.Tail = "100"
+	}
+
+	var (
+		mu      sync.Mutex
+		pending []followLogLine
+		sendErr error
+	)
+	flush := func() error {
+		mu.Lock()
+		defer mu.Unlock()
+		if sendErr != nil {
+			return sendErr
+		}
+		if len(pending) == 0 {
+			return nil
+		}
+		sendErr = send(map[string]interface{}{"type": "logs", "lines": pending})
+		pending = nil
+		return sendErr
+	}
+
+	streamCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	flushDone := make(chan struct{})
+	go func() {
+		defer close(flushDone)
+		ticker := time.NewTicker(followFlushInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				// 发送失败说明与控制端的连接已断开，结束读取
+				if flush() != nil {
+					cancel()
+					return
+				}
+			case <-streamCtx.Done():
+				return
+			}
+		}
+	}()
+
+	opts := LogStreamOptions{
+		Follow:     true,
+		Since:      req.Since,
+		Tail:       req.Tail,
+		Timestamps: req.Timestamps,
+	}
+	err := h.client.StreamContainerLogs(streamCtx, req.ContainerID, opts, func(line LogLine) error {
+		entry := followLogLine{Stream: line.Stream, Line: line.Line}
+		if !line.Timestamp.IsZero() {
+			entry.Timestamp = line.Timestamp.Format(time.RFC3339Nano)
+		}
+
+		mu.Lock()
+		pending = append(pending, entry)
+		full := len(pending) >= followMaxBatch
+		mu.Unlock()
+
+		if full {
+			return flush()
+		}
+		return nil
+	})
+
+	// 记录读取结束时是否已被取消，取消导致的读取错误不需要上报
+	cancelled := streamCtx.Err() != nil
+	cancel()
+	<-flushDone
+
+	// 先发送剩余日志；发送失败时 flush 返回该错误
+	if flushErr := flush(); flushErr != nil {
+		return flushErr
+	}
+	if err != nil && !cancelled {
+		return err
+	}
+	return nil
+}
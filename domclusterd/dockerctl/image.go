@@ -0,0 +1,379 @@
+package dockerctl
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/docker/docker/api/types/filters"
+	"github.com/docker/docker/api/types/image"
+	"github.com/docker/docker/client"
+	"go.uber.org/zap"
+)
+
+// pullProgressInterval 拉取镜像时推送进度的最小间隔
+const pullProgressInterval = 500 * time.Millisecond
+
+// ImageInfo 镜像信息
+type ImageInfo struct {
+	ID          string   `json:"id"`
+	RepoTags    []string `json:"repo_tags"`
+	RepoDigests []string `json:"repo_digests"`
+	Size        int64    `json:"size"`
+	Created     int64    `json:"created"`
+	Containers  int64    `json:"containers"`
+}
+
+// ImageDeleteResult 删除镜像的结果
+type ImageDeleteResult struct {
+	Untagged []string `json:"untagged"`
+	Deleted  []string `json:"deleted"`
+}
+
+// ImagePruneResult 清理镜像的结果
+type ImagePruneResult struct {
+	Deleted        []string `json:"deleted"`
+	SpaceReclaimed uint64   `json:"space_reclaimed"`
+}
+
+// LayerProgress 单个镜像层的拉取进度
+type LayerProgress struct {
+	Status  string `json:"status"`
+	Current int64  `json:"current,omitempty"`
+	Total   int64  `json:"total,omitempty"`
+}
+
+// pullMessage Docker 拉取镜像时返回的 JSON 消息
+type pullMessage struct {
+	ID             string `json:"id"`
+	Status         string `json:"status"`
+	ProgressDetail struct {
+		Current int64 `json:"current"`
+		Total   int64 `json:"total"`
+	} `json:"progressDetail"`
+	Error       string `json:"error"`
+	ErrorDetail struct {
+		Message string `json:"message"`
+	} `json:"errorDetail"`
+}
+
+// ListImages 列出镜像，all 为 true 时包含中间层镜像
+func (dc *DockerClient) ListImages(ctx context.Context, all bool) ([]ImageInfo, error) {
+	images, err := dc.cli.ImageList(ctx, image.ListOptions{All: all})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list images: %w", err)
+	}
+
+	result := make([]ImageInfo, 0, len(images))
+	for _, img := range images {
+		result = append(result, ImageInfo{
+			ID:          img.ID,
+			RepoTags:    nonNil(img.RepoTags),
+			RepoDigests: nonNil(img.RepoDigests),
+			Size:        img.Size,
+			Created:     img.Created,
+			Containers:  img.Containers,
+		})
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Created > result[j].Created })
+	return result, nil
+}
+
+// InspectImage 查询镜像，返回镜像 ID 和仓库摘要
+func (dc *DockerClient) InspectImage(ctx context.Context, ref string) (*ImageInfo, error) {
+	img, err := dc.cli.ImageInspect(ctx, ref)
+	if err != nil {
+		return nil, fmt.Errorf("failed to inspect image: %w", err)
+	}
+
+	created := int64(0)
+	if t, err := time.Parse(time.RFC3339Nano, img.Created); err == nil {
+		created = t.Unix()
+	}
+	return &ImageInfo{
+		ID:          img.ID,
+		RepoTags:    nonNil(img.RepoTags),
+		RepoDigests: nonNil(img.RepoDigests),
+		Size:        img.Size,
+		Created:     created,
+	}, nil
+}
+
+// PullImage 拉取镜像，fn 接收每条进度消息，拉取失败时返回 Docker 报告的错误
+func (dc *DockerClient) PullImage(ctx context.Context, ref, registryAuth string, fn func(id string, layer LayerProgress)) error {
+	rc, err := dc.cli.ImagePull(ctx, ref, image.PullOptions{RegistryAuth: registryAuth})
+	if err != nil {
+		return fmt.Errorf("failed to pull image: %w", err)
+	}
+	defer rc.Close()
+
+	dec := json.NewDecoder(rc)
+	for {
+		var msg pullMessage
+		if err := dec.Decode(&msg); err != nil {
+			if err == io.EOF {
+				break
+			}
+			return fmt.Errorf("failed to read pull progress: %w", err)
+		}
+		if msg.Error != "" {
+			return fmt.Errorf("failed to pull image: %s", msg.Error)
+		}
+		fn(msg.ID, LayerProgress{
+			Status:  msg.Status,
+			Current: msg.ProgressDetail.Current,
+			Total:   msg.ProgressDetail.Total,
+		})
+	}
+
+	zap.L().Sugar().Infof("Image %s pulled", ref)
+	return nil
+}
+
+// TagImage 为镜像添加标签
+func (dc *DockerClient) TagImage(ctx context.Context, source, target string) error {
+	if err := dc.cli.ImageTag(ctx, source, target); err != nil {
+		return fmt.Errorf("failed to tag image: %w", err)
+	}
+	zap.L().Sugar().Infof("Image %s tagged as %s", source, target)
+	return nil
+}
+
+// RemoveImage 删除镜像，force 为 true 时同时删除被停止的容器引用的镜像
+func (dc *DockerClient) RemoveImage(ctx context.Context, ref string, force bool) (*ImageDeleteResult, error) {
+	items, err := dc.cli.ImageRemove(ctx, ref, image.RemoveOptions{Force: force, PruneChildren: true})
+	if err != nil {
+		return nil, fmt.Errorf("failed to remove image: %w", err)
+	}
+
+	result := &ImageDeleteResult{Untagged: []string{}, Deleted: []string{}}
+	for _, item := range items {
+		if item.Untagged != "" {
+			result.Untagged = append(result.Untagged, item.Untagged)
+		}
+		if item.Deleted != "" {
+			result.Deleted = append(result.Deleted, item.Deleted)
+		}
+	}
+	zap.L().Sugar().Infof("Image %s removed (%d layers deleted)", ref, len(result.Deleted))
+	return result, nil
+}
+
+// PruneImages 清理镜像，all 为 false 时只清理悬空镜像，为 true 时清理所有未被容器使用的镜像
+func (dc *DockerClient) PruneImages(ctx context.Context, all bool) (*ImagePruneResult, error) {
+	args := filters.NewArgs()
+	if all {
+		args.Add("dangling", "false")
+	}
+	report, err := dc.cli.ImagesPrune(ctx, args)
+	if err != nil {
+		return nil, fmt.Errorf("failed to prune images: %w", err)
+	}
+
+	result := &ImagePruneResult{Deleted: []string{}, SpaceReclaimed: report.SpaceReclaimed}
+	for _, item := range report.ImagesDeleted {
+		if item.Deleted != "" {
+			result.Deleted = append(result.Deleted, item.Deleted)
+		}
+	}
+	zap.L().Sugar().Infof("Pruned %d images, reclaimed %d bytes", len(result.Deleted), result.SpaceReclaimed)
+	return result, nil
+}
+
+// LoadImage 从 docker save 格式的 tar 流导入镜像，返回导入的镜像引用（无标签的镜像为镜像 ID）
+func (dc *DockerClient) LoadImage(ctx context.Context, r io.Reader) ([]string, error) {
+	resp, err := dc.cli.ImageLoad(ctx, r, client.ImageLoadWithQuiet(true))
+	if err != nil {
+		return nil, fmt.Errorf("failed to load image: %w", err)
+	}
+	defer resp.Body.Close()
+
+	loaded := []string{}
+	dec := json.NewDecoder(resp.Body)
+	for {
+		var msg struct {
+			Stream string `json:"stream"`
+			Error  string `json:"error"`
+		}
+		if err := dec.Decode(&msg); err != nil {
+			if err == io.EOF {
+				break
+			}
+			return nil, fmt.Errorf("failed to read load response: %w", err)
+		}
+		if msg.Error != "" {
+			return nil, fmt.Errorf("failed to load image: %s", msg.Error)
+		}
+		// 输出形如 "Loaded image: name:tag" 或 "Loaded image ID: sha256:..."
+		line := strings.TrimSpace(msg.Stream)
+		if _, ref, ok := strings.Cut(line, ": "); ok && strings.HasPrefix(line, "Loaded image") {
+			loaded = append(loaded, ref)
+		}
+	}
+
+	zap.L().Sugar().Infof("Loaded images %v", loaded)
+	return loaded, nil
+}
+
+// nonNil 将 nil 切片转换为空切片，序列化为 [] 而不是 null
+func nonNil(s []string) []string {
+	if s == nil {
+		return []string{}
+	}
+	return s
+}
+
+// ListImagesQuery 处理 docker_image_list 查询
+func (h *Handler) ListImagesQuery(ctx context.Context, data []byte) (interface{}, error) {
+	var req struct {
+		All bool `json:"all"`
+	}
+	if err := json.Unmarshal(data, &req); err != nil {
+		return nil, fmt.Errorf("invalid request: %w", err)
+	}
+	images, err := h.client.ListImages(ctx, req.All)
+	if err != nil {
+		return nil, err
+	}
+	return map[string]interface{}{"images": images}, nil
+}
+
+// InspectImageQuery 处理 docker_image_inspect 查询
+func (h *Handler) InspectImageQuery(ctx context.Context, data []byte) (interface{}, error) {
+	var req struct {
+		Image string `json:"image"`
+	}
+	if err := json.Unmarshal(data, &req); err != nil {
+		return nil, fmt.Errorf("invalid request: %w", err)
+	}
+	if req.Image == "" {
+		return nil, fmt.Errorf("missing image")
+	}
+	return h.client.InspectImage(ctx, req.Image)
+}
+
+// TagImageQuery 处理 docker_image_tag 查询
+func (h *Handler) TagImageQuery(ctx context.Context, data []byte) (interface{}, error) {
+	var req struct {
+		Source string `json:"source"`
+		Target string `json:"target"`
+	}
+	if err := json.Unmarshal(data, &req); err != nil {
+		return nil, fmt.Errorf("invalid request: %w", err)
+	}
+	if req.Source == "" || req.Target == "" {
+		return nil, fmt.Errorf("missing source or target")
+	}
+	if err := h.client.TagImage(ctx, req.Source, req.Target); err != nil {
+		return nil, err
+	}
+	return map[string]interface{}{
+		"message": "image tagged",
+		"source":  req.Source,
+		"target":  req.Target,
+	}, nil
+}
+
+// RemoveImageQuery 处理 docker_image_remove 查询
+func (h *Handler) RemoveImageQuery(ctx context.Context, data []byte) (interface{}, error) {
+	var req struct {
+		Image string `json:"image"`
+		Force bool   `json:"force"`
+	}
+	if err := json.Unmarshal(data, &req); err != nil {
+		return nil, fmt.Errorf("invalid request: %w", err)
+	}
+	if req.Image == "" {
+		return nil, fmt.Errorf("missing image")
+	}
+	return h.client.RemoveImage(ctx, req.Image, req.Force)
+}
+
+// PruneImagesQuery 处理 docker_image_prune 查询
+func (h *Handler) PruneImagesQuery(ctx context.Context, data []byte) (interface{}, error) {
+	var req struct {
+		All bool `json:"all"`
+	}
+	if err := json.Unmarshal(data, &req); err != nil {
+		return nil, fmt.Errorf("invalid request: %w", err)
+	}
+	return h.client.PruneImages(ctx, req.All)
+}
+
+// PullImage 拉取镜像并通过 send 推送进度
+//
+// 进度按 pullProgressInterval 合并为 {"type":"progress","layers":{...},"current","total"} 消息，
+// 完成后推送 {"type":"pulled","image","id","repo_digests"}。
+func (h *Handler) PullImage(ctx context.Context, data []byte, send func(interface{}) error) error {
+	var req struct {
+		Image        string `json:"image"`
+		RegistryAuth string `json:"registry_auth"`
+	}
+	if err := json.Unmarshal(data, &req); err != nil {
+		return fmt.Errorf("invalid request: %w", err)
+	}
+	if req.Image == "" {
+		return fmt.Errorf("missing image")
+	}
+
+	layers := make(map[string]LayerProgress)
+	var lastSent time.Time
+	var sendErr error
+	flush := func(force bool) {
+		if sendErr != nil || (!force && time.Since(lastSent) < pullProgressInterval) {
+			return
+		}
+		lastSent = time.Now()
+
+		var current, total int64
+		for _, l := range layers {
+			current += l.Current
+			total += l.Total
+		}
+		sendErr = send(map[string]interface{}{
+			"type":    "progress",
+			"layers":  layers,
+			"current": current,
+			"total":   total,
+		})
+	}
+
+	pullCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	err := h.client.PullImage(pullCtx, req.Image, req.RegistryAuth, func(id string, layer LayerProgress) {
+		if id == "" || strings.HasPrefix(layer.Status, "Pulling from") {
+			return
+		}
+		// 下载完成后的状态不带字节数，保留之前的总量以便计算整体进度
+		if prev, ok := layers[id]; ok && layer.Total == 0 {
+			layer.Total = prev.Total
+			layer.Current = prev.Total
+		}
+		layers[id] = layer
+		flush(false)
+		// 推送失败说明控制端已断开，停止拉取
+		if sendErr != nil {
+			cancel()
+		}
+	})
+	if err != nil {
+		return err
+	}
+	flush(true)
+
+	img, err := h.client.InspectImage(ctx, req.Image)
+	if err != nil {
+		return err
+	}
+	return send(map[string]interface{}{
+		"type":         "pulled",
+		"image":        req.Image,
+		"id":           img.ID,
+		"repo_digests": img.RepoDigests,
+	})
+}
@@ -0,0 +1,132 @@
+package dockerctl
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/pkg/stdcopy"
+)
+
+// LogStreamOptions 容器日志流选项
+type LogStreamOptions struct {
+	Follow     bool
+	Since      string // RFC3339 时间、Unix 时间戳或相对时长（如 10m）
+	Tail       string
+	Timestamps bool
+}
+
+// LogLine 一行容器日志
+type LogLine struct {
+	Stream    string    // stdout 或 stderr
+	Timestamp time.Time // 仅在 Timestamps 为 true 时有效
+	Line      string
+}
+
+// StreamContainerLogs 读取容器日志并按行回调，直到日志结束、ctx 取消或回调返回错误
+//
+// 非 TTY 容器的日志为多路复用格式，需要拆分 stdout 和 stderr；TTY 容器只有一路输出，统一视为 stdout。
+func (dc *DockerClient) StreamContainerLogs(ctx context.Context, containerID string, opts LogStreamOptions, fn func(LogLine) error) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	inspect, err := dc.cli.ContainerInspect(ctx, containerID)
+	if err != nil {
+		return fmt.Errorf("failed to inspect container: %w", err)
+	}
+
+	reader, err := dc.cli.ContainerLogs(ctx, containerID, container.LogsOptions{
+		ShowStdout: true,
+		ShowStderr: true,
+		Follow:     opts.Follow,
+		Since:      opts.Since,
+		Tail:       opts.Tail,
+		Timestamps: opts.Timestamps,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to get container logs: %w", err)
+	}
+	defer reader.Close()
+
+	// 两路输出各自按行拆分后汇总到同一个 channel，保证回调串行执行
+	lines := make(chan LogLine, 256)
+	stdoutR, stdoutW := io.Pipe()
+	stderrR, stderrW := io.Pipe()
+
+	scanDone := make(chan struct{}, 2)
+	scan := func(r *io.PipeReader, stream string) {
+		// 关闭读取端使写入端返回错误，避免提前退出时拷贝协程阻塞
+		defer func() {
+			r.Close()
+			scanDone <- struct{}{}
+		}()
+		scanner := bufio.NewScanner(r)
+		scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+		for scanner.Scan() {
+			line := parseLogLine(scanner.Text(), stream, opts.Timestamps)
+			select {
+			case lines <- line:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}
+	go scan(stdoutR, "stdout")
+	go scan(stderrR, "stderr")
+
+	copyErr := make(chan error, 1)
+	go func() {
+		var err error
+		if inspect.Config != nil && inspect.Config.Tty {
+			_, err = io.Copy(stdoutW, reader)
+		} else {
+			_, err = stdcopy.StdCopy(stdoutW, stderrW, reader)
+		}
+		stdoutW.Close()
+		stderrW.Close()
+		copyErr <- err
+	}()
+
+	go func() {
+		<-scanDone
+		<-scanDone
+		close(lines)
+	}()
+
+	for line := range lines {
+		if err := fn(line); err != nil {
+			return err
+		}
+	}
+
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	if err := <-copyErr; err != nil && err != io.EOF {
+		return fmt.Errorf("failed to read container logs: %w", err)
+	}
+	return nil
+}
+
+// parseLogLine 解析日志行，启用时间戳时 Docker 会在行首加上 RFC3339Nano 时间
+func parseLogLine(text, stream string, timestamps bool) LogLine {
+	line := LogLine{Stream: stream, Line: text}
+	if !timestamps {
+		return line
+	}
+
+	ts, rest, ok := strings.Cut(text, " ")
+	if !ok {
+		ts = text
+		rest = ""
+	}
+	if t, err := time.Parse(time.RFC3339Nano, ts); err == nil {
+		line.Timestamp = t
+		line.Line = rest
+	}
+	return line
+}
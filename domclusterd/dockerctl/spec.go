@@ -0,0 +1,263 @@
+package dockerctl
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"domcluster/api/spec"
+
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/mount"
+	"github.com/docker/go-connections/nat"
+	"github.com/docker/go-units"
+)
+
+const (
+	// LabelManaged 由 domcluster 按声明创建的容器带有此标签
+	LabelManaged = "domcluster.managed"
+	// LabelSpecHash 创建容器时声明的哈希，用于判断容器是否与声明一致
+	LabelSpecHash = "domcluster.spec-hash"
+	// reservedLabelPrefix 节点保留的标签前缀，声明中不能使用
+	reservedLabelPrefix = "domcluster."
+)
+
+// 镜像拉取策略
+const (
+	PullMissing = "missing"
+	PullAlways  = "always"
+	PullNever   = "never"
+)
+
+var containerNamePattern = regexp.MustCompile(`^[a-zA-Z0-9][a-zA-Z0-9_.-]*$`)
+
+// 声明类型定义在 api 模块中，与控制端共用，保证两端计算的哈希一致
+type (
+	ContainerSpec     = spec.ContainerSpec
+	HealthcheckSpec   = spec.HealthcheckSpec
+	MountSpec         = spec.MountSpec
+	PortSpec          = spec.PortSpec
+	ResourceSpec      = spec.ResourceSpec
+	RestartPolicySpec = spec.RestartPolicySpec
+)
+
+// ValidateSpec 检查声明是否完整有效
+func ValidateSpec(s *ContainerSpec) error {
+	if !containerNamePattern.MatchString(s.Name) {
+		return fmt.Errorf("invalid container name %q", s.Name)
+	}
+	if s.Image == "" {
+		return fmt.Errorf("image is required")
+	}
+	for k := range s.Labels {
+		if strings.HasPrefix(k, reservedLabelPrefix) {
+			return fmt.Errorf("label %q uses the reserved %s prefix", k, reservedLabelPrefix)
+		}
+	}
+	switch s.PullPolicy {
+	case "", PullMissing, PullAlways, PullNever:
+	default:
+		return fmt.Errorf("invalid pull_policy %q", s.PullPolicy)
+	}
+	for _, m := range s.Mounts {
+		switch m.Type {
+		case "", "bind", "volume":
+			if m.Source == "" {
+				return fmt.Errorf("mount %s: source is required", m.Target)
+			}
+		case "tmpfs":
+		default:
+			return fmt.Errorf("mount %s: invalid type %q", m.Target, m.Type)
+		}
+		if !strings.HasPrefix(m.Target, "/") {
+			return fmt.Errorf("mount target must be absolute: %q", m.Target)
+		}
+	}
+	for _, p := range s.Ports {
+		if p.ContainerPort <= 0 || p.ContainerPort > 65535 || p.HostPort < 0 || p.HostPort > 65535 {
+			return fmt.Errorf("invalid port mapping %d:%d", p.HostPort, p.ContainerPort)
+		}
+		if p.Protocol != "" && p.Protocol != "tcp" && p.Protocol != "udp" {
+			return fmt.Errorf("invalid port protocol %q", p.Protocol)
+		}
+	}
+	switch s.RestartPolicy.Name {
+	case "", "no", "always", "unless-stopped":
+		if s.RestartPolicy.MaxRetries != 0 {
+			return fmt.Errorf("max_retries is only valid with the on-failure restart policy")
+		}
+	case "on-failure":
+	default:
+		return fmt.Errorf("invalid restart policy %q", s.RestartPolicy.Name)
+	}
+	switch s.CgroupnsMode {
+	case "", "host", "private":
+	default:
+		return fmt.Errorf("invalid cgroupns_mode %q", s.CgroupnsMode)
+	}
+	if s.Resources.CPUs < 0 {
+		return fmt.Errorf("cpus must not be negative")
+	}
+	for _, v := range []string{s.Resources.Memory, s.Resources.MemorySwap, s.Resources.ShmSize} {
+		if _, err := parseBytes(v); err != nil {
+			return err
+		}
+	}
+	if s.Healthcheck != nil {
+		if _, err := healthConfig(s.Healthcheck); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// healthConfig 转换为 Docker 的健康检查配置
+func healthConfig(h *HealthcheckSpec) (*container.HealthConfig, error) {
+	if len(h.Test) == 0 {
+		return nil, fmt.Errorf("healthcheck test is required")
+	}
+	if h.Retries < 0 {
+		return nil, fmt.Errorf("healthcheck retries must not be negative")
+	}
+	cfg := &container.HealthConfig{Test: h.Test, Retries: h.Retries}
+	for _, d := range []struct {
+		value string
+		dst   *time.Duration
+	}{
+		{h.Interval, &cfg.Interval},
+		{h.Timeout, &cfg.Timeout},
+		{h.StartPeriod, &cfg.StartPeriod},
+	} {
+		if d.value == "" {
+			continue
+		}
+		v, err := time.ParseDuration(d.value)
+		if err != nil || v < 0 {
+			return nil, fmt.Errorf("invalid healthcheck duration %q", d.value)
+		}
+		*d.dst = v
+	}
+	return cfg, nil
+}
+
+// containerConfig 将声明转换为 Docker 的创建参数
+func containerConfig(s *ContainerSpec) (*container.Config, *container.HostConfig, error) {
+	labels := make(map[string]string, len(s.Labels)+2)
+	for k, v := range s.Labels {
+		labels[k] = v
+	}
+	labels[LabelManaged] = "true"
+	labels[LabelSpecHash] = s.Hash()
+
+	env := make([]string, 0, len(s.Env))
+	for k, v := range s.Env {
+		env = append(env, k+"="+v)
+	}
+	sort.Strings(env)
+
+	cfg := &container.Config{
+		Image:        s.Image,
+		Cmd:          s.Command,
+		Entrypoint:   s.Entrypoint,
+		WorkingDir:   s.WorkingDir,
+		User:         s.User,
+		Hostname:     s.Hostname,
+		Env:          env,
+		Labels:       labels,
+		ExposedPorts: nat.PortSet{},
+	}
+	if s.Healthcheck != nil {
+		health, err := healthConfig(s.Healthcheck)
+		if err != nil {
+			return nil, nil, err
+		}
+		cfg.Healthcheck = health
+	}
+
+	hostCfg := &container.HostConfig{
+		NetworkMode: container.NetworkMode(s.NetworkMode),
+		RestartPolicy: container.RestartPolicy{
+			Name:              container.RestartPolicyMode(s.RestartPolicy.Name),
+			MaximumRetryCount: s.RestartPolicy.MaxRetries,
+		},
+		Privileged:   s.Privileged,
+		CapAdd:       s.CapAdd,
+		CgroupnsMode: container.CgroupnsMode(s.CgroupnsMode),
+		Sysctls:      s.Sysctls,
+		PortBindings: nat.PortMap{},
+	}
+	if s.Init {
+		init := true
+		hostCfg.Init = &init
+	}
+
+	for _, m := range s.Mounts {
+		typ := mount.TypeBind
+		if m.Type != "" {
+			typ = mount.Type(m.Type)
+		}
+		hostCfg.Mounts = append(hostCfg.Mounts, mount.Mount{
+			Type:     typ,
+			Source:   m.Source,
+			Target:   m.Target,
+			ReadOnly: m.ReadOnly,
+		})
+	}
+
+	for _, p := range s.Ports {
+		proto := p.Protocol
+		if proto == "" {
+			proto = "tcp"
+		}
+		port := nat.Port(fmt.Sprintf("%d/%s", p.ContainerPort, proto))
+		cfg.ExposedPorts[port] = struct{}{}
+		if p.HostPort > 0 {
+			hostCfg.PortBindings[port] = append(hostCfg.PortBindings[port], nat.PortBinding{
+				HostIP:   p.HostIP,
+				HostPort: strconv.Itoa(p.HostPort),
+			})
+		}
+	}
+
+	res := &hostCfg.Resources
+	res.CgroupParent = s.CgroupParent
+	res.CpusetCpus = s.Resources.CpusetCpus
+	res.CpusetMems = s.Resources.CpusetMems
+	if s.Resources.CPUs > 0 {
+		res.NanoCPUs = int64(math.Round(s.Resources.CPUs * 1e9))
+	}
+	if s.Resources.PidsLimit != 0 {
+		limit := s.Resources.PidsLimit
+		res.PidsLimit = &limit
+	}
+	var err error
+	if res.Memory, err = parseBytes(s.Resources.Memory); err != nil {
+		return nil, nil, err
+	}
+	if res.MemorySwap, err = parseBytes(s.Resources.MemorySwap); err != nil {
+		return nil, nil, err
+	}
+	if hostCfg.ShmSize, err = parseBytes(s.Resources.ShmSize); err != nil {
+		return nil, nil, err
+	}
+	return cfg, hostCfg, nil
+}
+
+// parseBytes 解析 512m、2g 等内存大小，-1 表示不限制，空字符串为 0
+func parseBytes(v string) (int64, error) {
+	switch v {
+	case "":
+		return 0, nil
+	case "-1":
+		return -1, nil
+	}
+	n, err := units.RAMInBytes(v)
+	if err != nil {
+		return 0, fmt.Errorf("invalid size %q: %w", v, err)
+	}
+	return n, nil
+}
@@ -0,0 +1,24 @@
+package dockerctl
+
+import "testing"
+
+func TestValidateSpecRejectsReservedLabels(t *testing.T) {
+	for _, key := range []string{LabelManaged, LabelSpecHash, "domcluster.other"} {
+		s := &ContainerSpec{Name: "web", Image: "nginx", Labels: map[string]string{key: "x"}}
+		if err := ValidateSpec(s); err == nil {
+			t.Errorf("label %q accepted", key)
+		}
+	}
+
+	s := &ContainerSpec{Name: "web", Image: "nginx", Labels: map[string]string{"d8rctl.stack": "contest"}}
+	if err := ValidateSpec(s); err != nil {
+		t.Errorf("ValidateSpec() = %v", err)
+	}
+	cfg, _, err := containerConfig(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Labels[LabelManaged] != "true" || cfg.Labels[LabelSpecHash] != s.Hash() || cfg.Labels["d8rctl.stack"] != "contest" {
+		t.Errorf("labels = %v", cfg.Labels)
+	}
+}
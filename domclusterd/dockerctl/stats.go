@@ -0,0 +1,82 @@
+package dockerctl
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/docker/docker/api/types/container"
+)
+
+// GetContainerStatsOneShot 获取容器单次统计采样
+//
+// 与 GetContainerStats 不同，one-shot 模式不会等待 Docker 进行预采样，
+// 因此 PreCPUStats 为空，CPU 使用率需要调用方根据两次采样自行计算。
+func (dc *DockerClient) GetContainerStatsOneShot(ctx context.Context, containerID string) (*container.StatsResponse, error) {
+	stats, err := dc.cli.ContainerStatsOneShot(ctx, containerID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get container stats: %w", err)
+	}
+	defer stats.Body.Close()
+
+	var result container.StatsResponse
+	if err := json.NewDecoder(stats.Body).Decode(&result); err != nil {
+		return nil, fmt.Errorf("failed to decode container stats: %w", err)
+	}
+	return &result, nil
+}
+
+// CalculateCPUPercent 根据两次采样计算容器 CPU 使用率（与 docker stats 一致，满核为 100% * 核数）
+func CalculateCPUPercent(prev, cur container.CPUStats) float64 {
+	if cur.CPUUsage.TotalUsage < prev.CPUUsage.TotalUsage || cur.SystemUsage <= prev.SystemUsage {
+		return 0
+	}
+
+	cpuDelta := float64(cur.CPUUsage.TotalUsage - prev.CPUUsage.TotalUsage)
+	systemDelta := float64(cur.SystemUsage - prev.SystemUsage)
+
+	onlineCPUs := float64(cur.OnlineCPUs)
+	if onlineCPUs == 0 {
+		onlineCPUs = float64(len(cur.CPUUsage.PercpuUsage))
+	}
+	if onlineCPUs == 0 {
+		onlineCPUs = 1
+	}
+
+	return cpuDelta / systemDelta * onlineCPUs * 100
+}
+
+// CalculateMemoryUsage 计算容器实际内存占用（扣除可回收的页缓存，与 docker stats 一致）
+func CalculateMemoryUsage(mem container.MemoryStats) uint64 {
+	// cgroup v1 使用 total_inactive_file，cgroup v2 使用 inactive_file
+	if v, ok := mem.Stats["total_inactive_file"]; ok && v < mem.Usage {
+		return mem.Usage - v
+	}
+	if v, ok := mem.Stats["inactive_file"]; ok && v < mem.Usage {
+		return mem.Usage - v
+	}
+	return mem.Usage
+}
+
+// CalculateNetworkIO 汇总容器所有网卡的收发字节数
+func CalculateNetworkIO(networks map[string]container.NetworkStats) (rx, tx uint64) {
+	for _, n := range networks {
+		rx += n.RxBytes
+		tx += n.TxBytes
+	}
+	return rx, tx
+}
+
+// CalculateBlockIO 汇总容器块设备读写字节数
+func CalculateBlockIO(blkio container.BlkioStats) (read, write uint64) {
+	for _, entry := range blkio.IoServiceBytesRecursive {
+		switch strings.ToLower(entry.Op) {
+		case "read":
+			read += entry.Value
+		case "write":
+			write += entry.Value
+		}
+	}
+	return read, write
+}
@@ -0,0 +1,192 @@
+package dockerctl
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/docker/docker/api/types/container"
+)
+
+const (
+	// statsStreamInterval 流式统计的采样间隔
+	statsStreamInterval = time.Second
+	// statsStreamConcurrency 全部容器模式下同时进行的 stats 请求数上限
+	statsStreamConcurrency = 8
+)
+
+// ContainerStatsSample 一个容器的归一化资源统计，速率字段单位为字节/秒
+type ContainerStatsSample struct {
+	ID              string  `json:"id"`
+	Name            string  `json:"name"`
+	CPUPercent      float64 `json:"cpu_percent"`
+	MemoryUsage     uint64  `json:"memory_usage"`
+	MemoryLimit     uint64  `json:"memory_limit"`
+	MemoryPercent   float64 `json:"memory_percent"`
+	NetworkRxBytes  uint64  `json:"network_rx_bytes"`
+	NetworkTxBytes  uint64  `json:"network_tx_bytes"`
+	NetworkRxRate   float64 `json:"network_rx_rate"`
+	NetworkTxRate   float64 `json:"network_tx_rate"`
+	BlockReadBytes  uint64  `json:"block_read_bytes"`
+	BlockWriteBytes uint64  `json:"block_write_bytes"`
+	BlockReadRate   float64 `json:"block_read_rate"`
+	BlockWriteRate  float64 `json:"block_write_rate"`
+	PIDs            uint64  `json:"pids"`
+}
+
+// statsSnapshot 上一次采样的累计值，用于计算速率
+type statsSnapshot struct {
+	read       time.Time
+	cpu        container.CPUStats
+	rx, tx     uint64
+	blkR, blkW uint64
+}
+
+// StreamStats 每秒采样容器资源使用情况并通过 send 推送，直到 ctx 取消
+//
+// container_id 为空时采集节点上所有运行中的容器。CPU 使用率和各项速率由相邻两次采样计算，
+// 因此容器的第一条数据中这些字段为 0。
+func (h *Handler) StreamStats(ctx context.Context, data []byte, send func(interface{}) error) error {
+	var req struct {
+		ContainerID string `json:"container_id"`
+	}
+	if err := json.Unmarshal(data, &req); err != nil {
+		return fmt.Errorf("invalid request: %w", err)
+	}
+
+	// 单容器模式下先确认容器存在，名称只需获取一次
+	var single *ContainerInfo
+	if req.ContainerID != "" {
+		inspect, err := h.client.InspectContainer(req.ContainerID)
+		if err != nil {
+			return err
+		}
+		single = &ContainerInfo{ID: inspect.ID, Name: strings.TrimPrefix(inspect.Name, "/")}
+	}
+
+	prev := make(map[string]*statsSnapshot)
+	ticker := time.NewTicker(statsStreamInterval)
+	defer ticker.Stop()
+
+	for {
+		var targets []ContainerInfo
+		if single != nil {
+			targets = []ContainerInfo{*single}
+		} else {
+			list, err := h.client.ListContainers(false)
+			if err != nil {
+				return err
+			}
+			targets = list
+		}
+
+		samples, snapshots, err := h.sampleStats(ctx, targets, prev, single != nil)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return err
+		}
+		prev = snapshots
+
+		if err := send(map[string]interface{}{
+			"type":       "stats",
+			"timestamp":  time.Now().Unix(),
+			"containers": samples,
+		}); err != nil {
+			return err
+		}
+
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return nil
+		}
+	}
+}
+
+// sampleStats 并发采集一组容器的统计，返回结果及本轮快照
+//
+// strict 为 true（单容器模式）时采集失败直接返回错误，否则跳过失败的容器（通常是刚刚退出）。
+func (h *Handler) sampleStats(ctx context.Context, targets []ContainerInfo, prev map[string]*statsSnapshot, strict bool) ([]ContainerStatsSample, map[string]*statsSnapshot, error) {
+	samples := make([]*ContainerStatsSample, len(targets))
+	snapshots := make(map[string]*statsSnapshot, len(targets))
+	errs := make([]error, len(targets))
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, statsStreamConcurrency)
+	for i, c := range targets {
+		wg.Add(1)
+		go func(i int, c ContainerInfo) {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+
+			stats, err := h.client.GetContainerStatsOneShot(ctx, c.ID)
+			if err != nil {
+				errs[i] = err
+				return
+			}
+
+			cur := &statsSnapshot{read: stats.Read, cpu: stats.CPUStats}
+			cur.rx, cur.tx = CalculateNetworkIO(stats.Networks)
+			cur.blkR, cur.blkW = CalculateBlockIO(stats.BlkioStats)
+
+			s := &ContainerStatsSample{
+				ID:              c.ID,
+				Name:            strings.TrimPrefix(c.Name, "/"),
+				MemoryUsage:     CalculateMemoryUsage(stats.MemoryStats),
+				MemoryLimit:     stats.MemoryStats.Limit,
+				NetworkRxBytes:  cur.rx,
+				NetworkTxBytes:  cur.tx,
+				BlockReadBytes:  cur.blkR,
+				BlockWriteBytes: cur.blkW,
+				PIDs:            stats.PidsStats.Current,
+			}
+			if s.MemoryLimit > 0 {
+				s.MemoryPercent = float64(s.MemoryUsage) / float64(s.MemoryLimit) * 100
+			}
+
+			mu.Lock()
+			last := prev[c.ID]
+			snapshots[c.ID] = cur
+			mu.Unlock()
+
+			if last != nil {
+				s.CPUPercent = CalculateCPUPercent(last.cpu, cur.cpu)
+				if elapsed := cur.read.Sub(last.read).Seconds(); elapsed > 0 {
+					s.NetworkRxRate = counterRate(last.rx, cur.rx, elapsed)
+					s.NetworkTxRate = counterRate(last.tx, cur.tx, elapsed)
+					s.BlockReadRate = counterRate(last.blkR, cur.blkR, elapsed)
+					s.BlockWriteRate = counterRate(last.blkW, cur.blkW, elapsed)
+				}
+			}
+			samples[i] = s
+		}(i, c)
+	}
+	wg.Wait()
+
+	result := make([]ContainerStatsSample, 0, len(targets))
+	for i, s := range samples {
+		if s != nil {
+			result = append(result, *s)
+			continue
+		}
+		if strict && errs[i] != nil {
+			return nil, nil, errs[i]
+		}
+	}
+	return result, snapshots, nil
+}
+
+// counterRate 根据累计计数器计算速率，计数器回绕或重置时返回 0
+func counterRate(prev, cur uint64, seconds float64) float64 {
+	if cur < prev {
+		return 0
+	}
+	return float64(cur-prev) / seconds
+}
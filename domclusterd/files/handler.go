@@ -0,0 +1,62 @@
+package files
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"time"
+
+	"domclusterd/connections"
+
+	"go.uber.org/zap"
+)
+
+// operationTimeout 单次文件操作的超时时间（大文件计算校验和可能较慢）
+const operationTimeout = 5 * time.Minute
+
+// Options 文件浏览配置
+type Options struct {
+	// WriteRoots 允许写入的根目录，为空时禁止所有写操作
+	WriteRoots []string
+}
+
+// Handler 节点文件系统浏览
+//
+// 读操作（列目录、查询信息、按范围读取、读取末尾、计算校验和）不限制路径；
+// 写操作（写文件、创建目录、删除、重命名）只允许在写入根目录之下进行，符号链接解析后再检查。
+type Handler struct {
+	manager *connections.Manager
+	roots   WriteRoots
+}
+
+// NewHandler 创建文件浏览处理器
+func NewHandler(roots WriteRoots, manager *connections.Manager) *Handler {
+	return &Handler{manager: manager, roots: roots}
+}
+
+// Register 注册处理器
+func (h *Handler) Register(ctx context.Context) {
+	handlers := map[string]connections.QueryFunc{
+		"fs_list":     h.list,
+		"fs_stat":     h.stat,
+		"fs_read":     h.read,
+		"fs_tail":     h.tail,
+		"fs_checksum": h.checksum,
+		"fs_write":    h.write,
+		"fs_mkdir":    h.mkdir,
+		"fs_remove":   h.remove,
+		"fs_rename":   h.rename,
+	}
+	for cmd, fn := range handlers {
+		h.manager.RegisterQueryHandler(ctx, cmd, operationTimeout, fn)
+	}
+	zap.L().Sugar().Infof("File browser handlers registered (write roots: %v)", h.roots)
+}
+
+// cleanPath 请求中的路径必须是绝对路径
+func cleanPath(p string) (string, error) {
+	if p == "" || !filepath.IsAbs(p) {
+		return "", fmt.Errorf("path must be absolute: %q", p)
+	}
+	return filepath.Clean(p), nil
+}
@@ -0,0 +1,382 @@
+package files
+
+import (
+	"bytes"
+	"context"
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"hash"
+	"io"
+	"os"
+	"os/user"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"sync"
+	"syscall"
+	"time"
+)
+
+const (
+	// defaultListLimit 列目录默认返回的条目数
+	defaultListLimit = 1000
+	// maxListLimit 列目录最多返回的条目数
+	maxListLimit = 10000
+	// defaultReadLength 按范围读取的默认字节数
+	defaultReadLength = 64 * 1024
+	// maxReadLength 按范围读取和读取末尾的最大字节数
+	maxReadLength = 1024 * 1024
+	// defaultTailLines 读取末尾的默认行数
+	defaultTailLines = 100
+)
+
+// Entry 文件或目录的信息
+type Entry struct {
+	Name     string    `json:"name"`
+	Path     string    `json:"path"`
+	Type     string    `json:"type"` // file、dir、symlink 或 other
+	Size     int64     `json:"size"`
+	Mode     string    `json:"mode"`
+	ModTime  time.Time `json:"mod_time"`
+	Owner    string    `json:"owner"`
+	Group    string    `json:"group"`
+	Target   string    `json:"target,omitempty"` // 符号链接指向的路径
+	Writable bool      `json:"writable"`
+}
+
+// newEntry 根据 Lstat 结果构造条目
+func (h *Handler) newEntry(p string, info os.FileInfo) Entry {
+	entry := Entry{
+		Name:     info.Name(),
+		Path:     p,
+		Type:     fileType(info.Mode()),
+		Size:     info.Size(),
+		Mode:     fmt.Sprintf("%04o", info.Mode().Perm()),
+		ModTime:  info.ModTime(),
+		Writable: h.roots.Writable(p),
+	}
+	if st, ok := info.Sys().(*syscall.Stat_t); ok {
+		entry.Owner = lookupUser(st.Uid)
+		entry.Group = lookupGroup(st.Gid)
+	}
+	if entry.Type == "symlink" {
+		entry.Target, _ = os.Readlink(p)
+	}
+	return entry
+}
+
+// fileType 文件类型
+func fileType(mode os.FileMode) string {
+	switch {
+	case mode.IsRegular():
+		return "file"
+	case mode.IsDir():
+		return "dir"
+	case mode&os.ModeSymlink != 0:
+		return "symlink"
+	default:
+		return "other"
+	}
+}
+
+// list 列出目录内容，目录在前，按名称排序
+func (h *Handler) list(ctx context.Context, data []byte) (interface{}, error) {
+	var req struct {
+		Path   string `json:"path"`
+		Hidden bool   `json:"hidden"`
+		Limit  int    `json:"limit"`
+	}
+	if err := json.Unmarshal(data, &req); err != nil {
+		return nil, fmt.Errorf("invalid request: %w", err)
+	}
+	dir, err := cleanPath(req.Path)
+	if err != nil {
+		return nil, err
+	}
+	if req.Limit <= 0 {
+		req.Limit = defaultListLimit
+	}
+	req.Limit = min(req.Limit, maxListLimit)
+
+	dirEntries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	entries := make([]Entry, 0, min(len(dirEntries), req.Limit))
+	for _, de := range dirEntries {
+		if !req.Hidden && de.Name()[0] == '.' {
+			continue
+		}
+		info, err := de.Info()
+		if err != nil {
+			// 列目录与 stat 之间文件被删除
+			continue
+		}
+		entries = append(entries, h.newEntry(filepath.Join(dir, de.Name()), info))
+	}
+
+	sort.Slice(entries, func(i, j int) bool {
+		if (entries[i].Type == "dir") != (entries[j].Type == "dir") {
+			return entries[i].Type == "dir"
+		}
+		return entries[i].Name < entries[j].Name
+	})
+
+	total := len(entries)
+	truncated := total > req.Limit
+	if truncated {
+		entries = entries[:req.Limit]
+	}
+
+	return map[string]interface{}{
+		"path":      dir,
+		"entries":   entries,
+		"total":     total,
+		"truncated": truncated,
+		"writable":  h.roots.WritableDir(dir),
+	}, nil
+}
+
+// stat 查询单个路径的信息，符号链接返回链接本身的信息
+func (h *Handler) stat(ctx context.Context, data []byte) (interface{}, error) {
+	var req struct {
+		Path string `json:"path"`
+	}
+	if err := json.Unmarshal(data, &req); err != nil {
+		return nil, fmt.Errorf("invalid request: %w", err)
+	}
+	p, err := cleanPath(req.Path)
+	if err != nil {
+		return nil, err
+	}
+
+	info, err := os.Lstat(p)
+	if err != nil {
+		return nil, err
+	}
+	return h.newEntry(p, info), nil
+}
+
+// read 按范围读取普通文件
+func (h *Handler) read(ctx context.Context, data []byte) (interface{}, error) {
+	var req struct {
+		Path   string `json:"path"`
+		Offset int64  `json:"offset"`
+		Length int    `json:"length"`
+	}
+	if err := json.Unmarshal(data, &req); err != nil {
+		return nil, fmt.Errorf("invalid request: %w", err)
+	}
+	p, err := cleanPath(req.Path)
+	if err != nil {
+		return nil, err
+	}
+	if req.Offset < 0 {
+		return nil, fmt.Errorf("offset must not be negative")
+	}
+	if req.Length <= 0 {
+		req.Length = defaultReadLength
+	}
+	req.Length = min(req.Length, maxReadLength)
+
+	f, size, err := openRegular(p)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	buf := make([]byte, req.Length)
+	n, err := f.ReadAt(buf, req.Offset)
+	if err != nil && err != io.EOF {
+		return nil, fmt.Errorf("failed to read %s: %w", p, err)
+	}
+
+	return map[string]interface{}{
+		"path":   p,
+		"offset": req.Offset,
+		"size":   size,
+		"data":   buf[:n],
+		"eof":    req.Offset+int64(n) >= size,
+	}, nil
+}
+
+// tail 读取文件最后若干行，最多读取 maxReadLength 字节
+func (h *Handler) tail(ctx context.Context, data []byte) (interface{}, error) {
+	var req struct {
+		Path  string `json:"path"`
+		Lines int    `json:"lines"`
+	}
+	if err := json.Unmarshal(data, &req); err != nil {
+		return nil, fmt.Errorf("invalid request: %w", err)
+	}
+	p, err := cleanPath(req.Path)
+	if err != nil {
+		return nil, err
+	}
+	if req.Lines <= 0 {
+		req.Lines = defaultTailLines
+	}
+
+	f, size, err := openRegular(p)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	// 从文件末尾按块向前读取，直到找到足够的换行符
+	const blockSize = 64 * 1024
+	var buf []byte
+	offset := size
+	for offset > 0 && int64(len(buf)) < maxReadLength {
+		n := min(int64(blockSize), offset, maxReadLength-int64(len(buf)))
+		offset -= n
+		block := make([]byte, n)
+		if _, err := f.ReadAt(block, offset); err != nil && err != io.EOF {
+			return nil, fmt.Errorf("failed to read %s: %w", p, err)
+		}
+		buf = append(block, buf...)
+		// 末尾的换行不算作一行的开始
+		if bytes.Count(bytes.TrimSuffix(buf, []byte("\n")), []byte("\n")) >= req.Lines {
+			break
+		}
+	}
+
+	trimmed := bytes.TrimSuffix(buf, []byte("\n"))
+	truncated := offset > 0
+	if idx := lastNthIndex(trimmed, '\n', req.Lines); idx >= 0 {
+		buf = buf[idx+1:]
+		truncated = true
+	}
+
+	return map[string]interface{}{
+		"path":      p,
+		"size":      size,
+		"offset":    size - int64(len(buf)),
+		"data":      buf,
+		"truncated": truncated,
+	}, nil
+}
+
+// lastNthIndex 返回从末尾数第 n 个 sep 的位置，不足 n 个时返回 -1
+func lastNthIndex(b []byte, sep byte, n int) int {
+	for i := len(b) - 1; i >= 0; i-- {
+		if b[i] == sep {
+			n--
+			if n == 0 {
+				return i
+			}
+		}
+	}
+	return -1
+}
+
+// checksum 计算文件的校验和，支持 sha256（默认）、sha1 和 md5
+func (h *Handler) checksum(ctx context.Context, data []byte) (interface{}, error) {
+	var req struct {
+		Path      string `json:"path"`
+		Algorithm string `json:"algorithm"`
+	}
+	if err := json.Unmarshal(data, &req); err != nil {
+		return nil, fmt.Errorf("invalid request: %w", err)
+	}
+	p, err := cleanPath(req.Path)
+	if err != nil {
+		return nil, err
+	}
+
+	var hasher hash.Hash
+	switch req.Algorithm {
+	case "", "sha256":
+		req.Algorithm = "sha256"
+		hasher = sha256.New()
+	case "sha1":
+		hasher = sha1.New()
+	case "md5":
+		hasher = md5.New()
+	default:
+		return nil, fmt.Errorf("unsupported algorithm %q", req.Algorithm)
+	}
+
+	f, _, err := openRegular(p)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	n, err := io.Copy(hasher, &contextReader{ctx: ctx, r: f})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", p, err)
+	}
+
+	return map[string]interface{}{
+		"path":      p,
+		"algorithm": req.Algorithm,
+		"checksum":  hex.EncodeToString(hasher.Sum(nil)),
+		"size":      n,
+	}, nil
+}
+
+// openRegular 打开普通文件，目录和设备文件等返回错误
+func openRegular(p string) (*os.File, int64, error) {
+	f, err := os.Open(p)
+	if err != nil {
+		return nil, 0, err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, 0, err
+	}
+	if !info.Mode().IsRegular() {
+		f.Close()
+		return nil, 0, fmt.Errorf("%s is not a regular file", p)
+	}
+	return f, info.Size(), nil
+}
+
+// contextReader 读取时检查 context，大文件计算校验和时可以被超时中断
+type contextReader struct {
+	ctx context.Context
+	r   io.Reader
+}
+
+func (cr *contextReader) Read(p []byte) (int, error) {
+	if err := cr.ctx.Err(); err != nil {
+		return 0, err
+	}
+	return cr.r.Read(p)
+}
+
+// userNames、groupNames 缓存 uid/gid 对应的名称，列大目录时避免反复读取 /etc/passwd
+var userNames, groupNames sync.Map
+
+// lookupUser 将 uid 转换为用户名，查不到时返回数字
+func lookupUser(uid uint32) string {
+	if name, ok := userNames.Load(uid); ok {
+		return name.(string)
+	}
+	name := strconv.FormatUint(uint64(uid), 10)
+	if u, err := user.LookupId(name); err == nil {
+		name = u.Username
+	}
+	userNames.Store(uid, name)
+	return name
+}
+
+// lookupGroup 将 gid 转换为组名，查不到时返回数字
+func lookupGroup(gid uint32) string {
+	if name, ok := groupNames.Load(gid); ok {
+		return name.(string)
+	}
+	name := strconv.FormatUint(uint64(gid), 10)
+	if g, err := user.LookupGroupId(name); err == nil {
+		name = g.Name
+	}
+	groupNames.Store(gid, name)
+	return name
+}
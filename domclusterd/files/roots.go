@@ -0,0 +1,80 @@
+package files
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"go.uber.org/zap"
+)
+
+// WriteRoots 允许写入的根目录，文件浏览和文件传输共用，为空时禁止写入节点文件系统
+type WriteRoots []string
+
+// NewWriteRoots 规范化配置的写入根目录，忽略相对路径
+func NewWriteRoots(roots []string) WriteRoots {
+	result := make(WriteRoots, 0, len(roots))
+	for _, root := range roots {
+		if !filepath.IsAbs(root) {
+			zap.L().Sugar().Warnf("Ignoring relative file write root %q", root)
+			continue
+		}
+		// 根目录本身可能是符号链接，按解析后的真实路径比较
+		if resolved, err := filepath.EvalSymlinks(root); err == nil {
+			root = resolved
+		}
+		result = append(result, filepath.Clean(root))
+	}
+	return result
+}
+
+// Writable 检查路径是否位于允许写入的根目录之下
+//
+// 父目录的符号链接解析后再判断，防止通过链接写到根目录之外；目标本身为符号链接时操作的是链接而不是其指向的文件。
+func (r WriteRoots) Writable(p string) bool {
+	parent, err := filepath.EvalSymlinks(filepath.Dir(p))
+	if err != nil {
+		return false
+	}
+	return r.contains(filepath.Join(parent, filepath.Base(p)), false)
+}
+
+// WritableDir 检查能否在目录中创建或修改条目
+func (r WriteRoots) WritableDir(dir string) bool {
+	resolved, err := filepath.EvalSymlinks(dir)
+	if err != nil {
+		return false
+	}
+	return r.contains(resolved, true)
+}
+
+// Check 写操作前检查路径，不允许修改根目录本身，返回清理后的路径
+func (r WriteRoots) Check(p string) (string, error) {
+	p, err := cleanPath(p)
+	if err != nil {
+		return "", err
+	}
+	if !r.Writable(p) {
+		if len(r) == 0 {
+			return "", fmt.Errorf("file writes are disabled on this node")
+		}
+		return "", fmt.Errorf("%s is outside the allowed write roots", p)
+	}
+	return p, nil
+}
+
+// contains 判断已解析的路径是否位于某个写入根目录之下，includeRoot 为 true 时根目录本身也算
+func (r WriteRoots) contains(resolved string, includeRoot bool) bool {
+	for _, root := range r {
+		if resolved == root {
+			if includeRoot {
+				return true
+			}
+			continue
+		}
+		if root == "/" || strings.HasPrefix(resolved, root+"/") {
+			return true
+		}
+	}
+	return false
+}
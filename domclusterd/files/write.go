@@ -0,0 +1,192 @@
+package files
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+)
+
+// maxWriteSize 单次写入的最大字节数，更大的文件使用分块传输
+const maxWriteSize = 1024 * 1024
+
+// parseMode 解析八进制权限字符串，为空时返回默认值
+func parseMode(s string, def os.FileMode) (os.FileMode, error) {
+	if s == "" {
+		return def, nil
+	}
+	m, err := strconv.ParseUint(s, 8, 32)
+	if err != nil || m > 0o7777 {
+		return 0, fmt.Errorf("invalid mode %q", s)
+	}
+	return os.FileMode(m), nil
+}
+
+// write 写入小文件，先写临时文件再重命名；文件已存在且未指定 mode 时保留原权限
+func (h *Handler) write(ctx context.Context, data []byte) (interface{}, error) {
+	var req struct {
+		Path string `json:"path"`
+		Data []byte `json:"data"`
+		Mode string `json:"mode"`
+	}
+	if err := json.Unmarshal(data, &req); err != nil {
+		return nil, fmt.Errorf("invalid request: %w", err)
+	}
+	p, err := h.roots.Check(req.Path)
+	if err != nil {
+		return nil, err
+	}
+	if len(req.Data) > maxWriteSize {
+		return nil, fmt.Errorf("content exceeds %d bytes, use file transfer instead", maxWriteSize)
+	}
+
+	def := os.FileMode(0644)
+	if info, err := os.Lstat(p); err == nil {
+		if !info.Mode().IsRegular() {
+			return nil, fmt.Errorf("%s is not a regular file", p)
+		}
+		def = info.Mode().Perm()
+	}
+	mode, err := parseMode(req.Mode, def)
+	if err != nil {
+		return nil, err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(p), "."+filepath.Base(p)+".d8r-*")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create temp file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(req.Data); err != nil {
+		tmp.Close()
+		return nil, fmt.Errorf("failed to write %s: %w", p, err)
+	}
+	if err := tmp.Chmod(mode); err != nil {
+		tmp.Close()
+		return nil, fmt.Errorf("failed to set mode on %s: %w", p, err)
+	}
+	if err := tmp.Close(); err != nil {
+		return nil, err
+	}
+	if err := os.Rename(tmp.Name(), p); err != nil {
+		return nil, fmt.Errorf("failed to write %s: %w", p, err)
+	}
+
+	info, err := os.Lstat(p)
+	if err != nil {
+		return nil, err
+	}
+	return h.newEntry(p, info), nil
+}
+
+// mkdir 创建目录，parents 为 true 时同时创建不存在的上级目录
+func (h *Handler) mkdir(ctx context.Context, data []byte) (interface{}, error) {
+	var req struct {
+		Path    string `json:"path"`
+		Mode    string `json:"mode"`
+		Parents bool   `json:"parents"`
+	}
+	if err := json.Unmarshal(data, &req); err != nil {
+		return nil, fmt.Errorf("invalid request: %w", err)
+	}
+	mode, err := parseMode(req.Mode, 0755)
+	if err != nil {
+		return nil, err
+	}
+
+	p, err := cleanPath(req.Path)
+	if err != nil {
+		return nil, err
+	}
+	if req.Parents {
+		// 找到最近的已存在上级目录，只要求新建的第一层位于允许的根目录下
+		first := p
+		for {
+			parent := filepath.Dir(first)
+			if _, err := os.Lstat(parent); err == nil || parent == first {
+				break
+			}
+			first = parent
+		}
+		if _, err := h.roots.Check(first); err != nil {
+			return nil, err
+		}
+		err = os.MkdirAll(p, mode)
+	} else {
+		if _, err := h.roots.Check(p); err != nil {
+			return nil, err
+		}
+		err = os.Mkdir(p, mode)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	info, err := os.Lstat(p)
+	if err != nil {
+		return nil, err
+	}
+	return h.newEntry(p, info), nil
+}
+
+// remove 删除文件或目录，非空目录需要 recursive
+func (h *Handler) remove(ctx context.Context, data []byte) (interface{}, error) {
+	var req struct {
+		Path      string `json:"path"`
+		Recursive bool   `json:"recursive"`
+	}
+	if err := json.Unmarshal(data, &req); err != nil {
+		return nil, fmt.Errorf("invalid request: %w", err)
+	}
+	p, err := h.roots.Check(req.Path)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := os.Lstat(p); err != nil {
+		return nil, err
+	}
+
+	if req.Recursive {
+		err = os.RemoveAll(p)
+	} else {
+		err = os.Remove(p)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return map[string]interface{}{"path": p, "removed": true}, nil
+}
+
+// rename 重命名或移动，源和目标都必须位于允许的根目录下
+func (h *Handler) rename(ctx context.Context, data []byte) (interface{}, error) {
+	var req struct {
+		Path    string `json:"path"`
+		NewPath string `json:"new_path"`
+	}
+	if err := json.Unmarshal(data, &req); err != nil {
+		return nil, fmt.Errorf("invalid request: %w", err)
+	}
+	from, err := h.roots.Check(req.Path)
+	if err != nil {
+		return nil, err
+	}
+	to, err := h.roots.Check(req.NewPath)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := os.Lstat(to); err == nil {
+		return nil, fmt.Errorf("%s already exists", to)
+	}
+
+	if err := os.Rename(from, to); err != nil {
+		return nil, err
+	}
+	info, err := os.Lstat(to)
+	if err != nil {
+		return nil, err
+	}
+	return h.newEntry(to, info), nil
+}
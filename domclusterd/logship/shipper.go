@@ -0,0 +1,278 @@
+package logship
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"domclusterd/connections"
+	"domclusterd/dockerctl"
+
+	pb "domcluster/api/proto"
+	"go.uber.org/zap"
+)
+
+const (
+	// ackTimeout 等待控制端确认批次的超时时间，超时后重发
+	ackTimeout = 15 * time.Second
+	// retryInterval 发送失败后的重试间隔
+	retryInterval = 5 * time.Second
+	// entryBufferSize 日志行缓冲区大小，写满时采集端阻塞等待
+	entryBufferSize = 4096
+)
+
+// Options 日志采集配置
+type Options struct {
+	Enabled bool
+	// Containers 需要采集的容器名称或 ID
+	Containers []string
+	// AgentLog 是否采集 domclusterd 自身日志
+	AgentLog     bool
+	AgentLogPath string
+	// SpoolDir 断线期间缓存批次的目录，SpoolMaxMB 为其容量上限
+	SpoolDir   string
+	SpoolMaxMB int
+	// BatchSize 单个批次的最大行数，FlushSeconds 为未满批次的最长等待时间
+	BatchSize    int
+	FlushSeconds int
+}
+
+// Entry 一条日志
+type Entry struct {
+	Timestamp   time.Time `json:"ts"`
+	Source      string    `json:"source"` // container 或 agent
+	Container   string    `json:"container,omitempty"`
+	ContainerID string    `json:"container_id,omitempty"`
+	Stream      string    `json:"stream"` // stdout、stderr 或 file
+	Line        string    `json:"line"`
+}
+
+// Batch 一批日志，ID 在重发时保持不变，供控制端去重
+type Batch struct {
+	ID       string            `json:"id"`
+	NodeID   string            `json:"node_id"`
+	NodeName string            `json:"node_name"`
+	Labels   map[string]string `json:"labels,omitempty"`
+	Entries  []Entry           `json:"entries"`
+}
+
+// Shipper 日志采集与上报
+//
+// 所有批次先写入磁盘缓冲区，再由发送协程按顺序逐个发送并等待控制端确认，
+// 因此断线或进程重启期间产生的日志会在恢复后补发。
+type Shipper struct {
+	opts     Options
+	manager  *connections.Manager
+	docker   *dockerctl.DockerClient
+	nodeID   string
+	nodeName string
+	labels   map[string]string
+
+	spool   *spool
+	entries chan Entry
+	batchID string // 本次启动的批次 ID 前缀
+	seq     uint64
+
+	ackMu   sync.Mutex
+	waiting string
+	ackCh   chan struct{}
+}
+
+// NewShipper 创建日志采集器
+func NewShipper(opts Options, manager *connections.Manager, docker *dockerctl.DockerClient, nodeID, nodeName string, labels map[string]string) (*Shipper, error) {
+	if opts.AgentLogPath == "" {
+		opts.AgentLogPath = "/var/log/domclusterd/domclusterd.log"
+	}
+	if opts.SpoolDir == "" {
+		opts.SpoolDir = "/var/lib/domclusterd/log-spool"
+	}
+	if opts.SpoolMaxMB <= 0 {
+		opts.SpoolMaxMB = 256
+	}
+	if opts.BatchSize <= 0 {
+		opts.BatchSize = 500
+	}
+	if opts.FlushSeconds <= 0 {
+		opts.FlushSeconds = 2
+	}
+
+	sp, err := openSpool(opts.SpoolDir, int64(opts.SpoolMaxMB)*1024*1024)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Shipper{
+		opts:     opts,
+		manager:  manager,
+		docker:   docker,
+		nodeID:   nodeID,
+		nodeName: nodeName,
+		labels:   labels,
+		spool:    sp,
+		entries:  make(chan Entry, entryBufferSize),
+		batchID:  strconv.FormatInt(time.Now().UnixNano(), 36),
+		ackCh:    make(chan struct{}, 1),
+	}, nil
+}
+
+// Start 启动采集和发送协程，ctx 取消时全部退出
+func (s *Shipper) Start(ctx context.Context) {
+	s.manager.RegisterHandler("log_ack", s.handleAck)
+
+	if s.opts.AgentLog {
+		go s.tailFile(ctx, s.opts.AgentLogPath)
+	}
+	if len(s.opts.Containers) > 0 {
+		if s.docker == nil {
+			zap.L().Sugar().Warn("Docker client not available, container logs will not be shipped")
+		} else {
+			for _, name := range s.opts.Containers {
+				go s.tailContainer(ctx, name)
+			}
+		}
+	}
+
+	go s.batchLoop(ctx)
+	go s.sendLoop(ctx)
+
+	zap.L().Sugar().Infof("Log shipping started: agent_log=%v, containers=%v", s.opts.AgentLog, s.opts.Containers)
+}
+
+// emit 提交一条日志，ctx 取消时放弃
+func (s *Shipper) emit(ctx context.Context, e Entry) {
+	select {
+	case s.entries <- e:
+	case <-ctx.Done():
+	}
+}
+
+// batchLoop 将日志按行数或时间聚合为批次并写入缓冲区
+func (s *Shipper) batchLoop(ctx context.Context) {
+	ticker := time.NewTicker(time.Duration(s.opts.FlushSeconds) * time.Second)
+	defer ticker.Stop()
+
+	batch := make([]Entry, 0, s.opts.BatchSize)
+	flush := func() {
+		if len(batch) == 0 {
+			return
+		}
+		s.seq++
+		b := &Batch{
+			ID:       fmt.Sprintf("%s-%d", s.batchID, s.seq),
+			NodeID:   s.nodeID,
+			NodeName: s.nodeName,
+			Labels:   s.labels,
+			Entries:  batch,
+		}
+		if err := s.spool.push(b); err != nil {
+			// 写盘失败不能输出到 domclusterd 日志，否则会被再次采集形成循环
+			fmt.Fprintf(os.Stderr, "failed to spool log batch: %v\n", err)
+		}
+		batch = make([]Entry, 0, s.opts.BatchSize)
+	}
+
+	for {
+		select {
+		case e := <-s.entries:
+			batch = append(batch, e)
+			if len(batch) >= s.opts.BatchSize {
+				flush()
+			}
+		case <-ticker.C:
+			flush()
+		case <-ctx.Done():
+			flush()
+			return
+		}
+	}
+}
+
+// sendLoop 按顺序发送缓冲区中的批次，收到确认后删除
+func (s *Shipper) sendLoop(ctx context.Context) {
+	for {
+		seq, batch, err := s.spool.peek()
+		if err != nil {
+			zap.L().Sugar().Warnf("Dropping unreadable log batch %d: %v", seq, err)
+			s.spool.remove(seq)
+			continue
+		}
+		if batch == nil {
+			select {
+			case <-s.spool.notify:
+				continue
+			case <-ctx.Done():
+				return
+			}
+		}
+
+		if err := s.sendBatch(ctx, batch); err != nil {
+			if ctx.Err() != nil {
+				return
+			}
+			select {
+			case <-time.After(retryInterval):
+			case <-ctx.Done():
+				return
+			}
+			continue
+		}
+		s.spool.remove(seq)
+	}
+}
+
+// sendBatch 发送一个批次并等待确认
+func (s *Shipper) sendBatch(ctx context.Context, batch *Batch) error {
+	data, err := json.Marshal(batch)
+	if err != nil {
+		return err
+	}
+
+	s.ackMu.Lock()
+	s.waiting = batch.ID
+	s.ackMu.Unlock()
+
+	// 清除上一个批次遗留的确认信号
+	select {
+	case <-s.ackCh:
+	default:
+	}
+
+	if err := s.manager.Send("log_batch", batch.ID, data); err != nil {
+		return err
+	}
+
+	select {
+	case <-s.ackCh:
+		return nil
+	case <-time.After(ackTimeout):
+		return fmt.Errorf("timed out waiting for ack of batch %s", batch.ID)
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// handleAck 处理控制端的批次确认
+func (s *Shipper) handleAck(resp *pb.PublishResponse) error {
+	var ack struct {
+		BatchID string `json:"batch_id"`
+	}
+	if err := json.Unmarshal(resp.Data, &ack); err != nil {
+		return err
+	}
+
+	s.ackMu.Lock()
+	matched := ack.BatchID == s.waiting
+	s.ackMu.Unlock()
+
+	if matched {
+		select {
+		case s.ackCh <- struct{}{}:
+		default:
+		}
+	}
+	return nil
+}
@@ -0,0 +1,156 @@
+package logship
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"os"
+	"strings"
+	"syscall"
+	"time"
+
+	"domclusterd/dockerctl"
+)
+
+const (
+	// filePollInterval 轮询日志文件新内容的间隔
+	filePollInterval = 500 * time.Millisecond
+	// containerRetryInterval 容器日志流中断（如容器停止）后的重试间隔
+	containerRetryInterval = 5 * time.Second
+)
+
+// tailFile 跟踪文件末尾的新增行，文件被轮转或截断后从头读取新文件
+func (s *Shipper) tailFile(ctx context.Context, path string) {
+	var (
+		f      *os.File
+		reader *bufio.Reader
+		offset int64
+		ino    uint64
+		// 不完整的行留到下次读取时拼接
+		partial string
+		// 启动时已存在的文件从末尾开始，避免重复上报历史日志；之后新建或轮转的文件从头读取
+		fromEnd = true
+	)
+	defer func() {
+		if f != nil {
+			f.Close()
+		}
+	}()
+
+	ticker := time.NewTicker(filePollInterval)
+	defer ticker.Stop()
+
+	for {
+		if f == nil {
+			opened, err := os.Open(path)
+			if err == nil {
+				offset = 0
+				if fromEnd {
+					offset, _ = opened.Seek(0, io.SeekEnd)
+				}
+				f = opened
+				reader = bufio.NewReader(f)
+				ino = fileInode(f)
+			}
+		}
+
+		if f != nil {
+			for {
+				line, err := reader.ReadString('\n')
+				offset += int64(len(line))
+				if err != nil {
+					partial += line
+					break
+				}
+				text := strings.TrimRight(partial+line, "\r\n")
+				partial = ""
+				s.emit(ctx, Entry{
+					Timestamp: time.Now(),
+					Source:    "agent",
+					Stream:    "file",
+					Line:      text,
+				})
+			}
+
+			// inode 变化说明文件被轮转，大小小于已读位置说明文件被截断
+			if info, err := os.Stat(path); err == nil {
+				if statInode(info) != ino || info.Size() < offset {
+					f.Close()
+					f = nil
+					partial = ""
+				}
+			}
+		}
+
+		fromEnd = false
+
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// tailContainer 持续跟踪容器日志，日志流中断后从最后一行的时间继续
+func (s *Shipper) tailContainer(ctx context.Context, name string) {
+	since := time.Now()
+
+	for {
+		var containerID string
+		if inspect, err := s.docker.InspectContainer(name); err == nil {
+			containerID = inspect.ID
+		}
+
+		if containerID != "" {
+			opts := dockerctl.LogStreamOptions{
+				Follow:     true,
+				Since:      since.Format(time.RFC3339Nano),
+				Timestamps: true,
+			}
+			s.docker.StreamContainerLogs(ctx, containerID, opts, func(line dockerctl.LogLine) error {
+				ts := line.Timestamp
+				if ts.IsZero() {
+					ts = time.Now()
+				}
+				// since 参数包含边界，跳过已上报时间点及之前的行
+				if !ts.After(since) {
+					return nil
+				}
+				since = ts
+				s.emit(ctx, Entry{
+					Timestamp:   ts,
+					Source:      "container",
+					Container:   name,
+					ContainerID: containerID,
+					Stream:      line.Stream,
+					Line:        line.Line,
+				})
+				return nil
+			})
+		}
+
+		select {
+		case <-time.After(containerRetryInterval):
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// fileInode 返回已打开文件的 inode
+func fileInode(f *os.File) uint64 {
+	info, err := f.Stat()
+	if err != nil {
+		return 0
+	}
+	return statInode(info)
+}
+
+// statInode 从文件信息中取出 inode
+func statInode(info os.FileInfo) uint64 {
+	if st, ok := info.Sys().(*syscall.Stat_t); ok {
+		return st.Ino
+	}
+	return 0
+}
@@ -0,0 +1,178 @@
+package logship
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"go.uber.org/zap"
+)
+
+// spoolFileExt 落盘批次的文件扩展名
+const spoolFileExt = ".json"
+
+// spool 磁盘缓冲区，每个批次一个文件，文件名为递增序号，按序号顺序发送
+type spool struct {
+	dir      string
+	maxBytes int64
+
+	mu      sync.Mutex
+	nextSeq uint64
+	files   []spoolFile // 按序号升序
+	size    int64
+	notify  chan struct{}
+	dropped uint64
+}
+
+// spoolFile 缓冲区中的一个批次文件
+type spoolFile struct {
+	seq  uint64
+	size int64
+}
+
+// openSpool 打开缓冲目录并加载上次未发送完的批次
+func openSpool(dir string, maxBytes int64) (*spool, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create spool dir: %w", err)
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read spool dir: %w", err)
+	}
+
+	s := &spool{
+		dir:      dir,
+		maxBytes: maxBytes,
+		nextSeq:  1,
+		notify:   make(chan struct{}, 1),
+	}
+
+	for _, entry := range entries {
+		name := entry.Name()
+		if !strings.HasSuffix(name, spoolFileExt) {
+			// 清理写入中途崩溃留下的临时文件
+			if strings.HasSuffix(name, ".tmp") {
+				os.Remove(filepath.Join(dir, name))
+			}
+			continue
+		}
+		seq, err := strconv.ParseUint(strings.TrimSuffix(name, spoolFileExt), 10, 64)
+		if err != nil {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			continue
+		}
+		s.files = append(s.files, spoolFile{seq: seq, size: info.Size()})
+		s.size += info.Size()
+		if seq >= s.nextSeq {
+			s.nextSeq = seq + 1
+		}
+	}
+	sort.Slice(s.files, func(i, j int) bool { return s.files[i].seq < s.files[j].seq })
+
+	if len(s.files) > 0 {
+		zap.L().Sugar().Infof("Log spool has %d pending batches (%d bytes)", len(s.files), s.size)
+	}
+	return s, nil
+}
+
+// path 返回序号对应的文件路径
+func (s *spool) path(seq uint64) string {
+	return filepath.Join(s.dir, fmt.Sprintf("%020d%s", seq, spoolFileExt))
+}
+
+// push 将批次写入缓冲区，超过容量时丢弃最旧的批次
+func (s *spool) push(b *Batch) error {
+	data, err := json.Marshal(b)
+	if err != nil {
+		return fmt.Errorf("failed to marshal batch: %w", err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	seq := s.nextSeq
+	s.nextSeq++
+
+	// 先写临时文件再重命名，避免崩溃时留下不完整的批次
+	path := s.path(seq)
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("failed to write spool file: %w", err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("failed to commit spool file: %w", err)
+	}
+
+	s.files = append(s.files, spoolFile{seq: seq, size: int64(len(data))})
+	s.size += int64(len(data))
+
+	for s.maxBytes > 0 && s.size > s.maxBytes && len(s.files) > 1 {
+		oldest := s.files[0]
+		os.Remove(s.path(oldest.seq))
+		s.files = s.files[1:]
+		s.size -= oldest.size
+		s.dropped++
+		zap.L().Sugar().Warnf("Log spool full, dropped batch %d (%d dropped in total)", oldest.seq, s.dropped)
+	}
+
+	select {
+	case s.notify <- struct{}{}:
+	default:
+	}
+	return nil
+}
+
+// peek 读取最旧的批次，缓冲区为空时返回 nil
+func (s *spool) peek() (uint64, *Batch, error) {
+	s.mu.Lock()
+	if len(s.files) == 0 {
+		s.mu.Unlock()
+		return 0, nil, nil
+	}
+	seq := s.files[0].seq
+	s.mu.Unlock()
+
+	data, err := os.ReadFile(s.path(seq))
+	if err != nil {
+		return seq, nil, err
+	}
+
+	var b Batch
+	if err := json.Unmarshal(data, &b); err != nil {
+		return seq, nil, fmt.Errorf("corrupt spool file %d: %w", seq, err)
+	}
+	return seq, &b, nil
+}
+
+// remove 删除已确认的批次
+func (s *spool) remove(seq uint64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for i, f := range s.files {
+		if f.seq == seq {
+			os.Remove(s.path(seq))
+			s.files = append(s.files[:i], s.files[i+1:]...)
+			s.size -= f.size
+			return
+		}
+	}
+}
+
+// pending 返回待发送的批次数和字节数
+func (s *spool) pending() (int, int64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.files), s.size
+}
@@ -0,0 +1,66 @@
+package monitor
+
+import (
+	"encoding/json"
+	"reflect"
+)
+
+// 状态增量采用 JSON Merge Patch（RFC 7386）语义：
+// 对象逐字段比较，变化的字段给出新值，删除的字段置为 null，数组整体替换。
+// statusdelta.KeyedArrays 所列的数组例外，比较前转换为以元素键为键的对象。
+
+// toJSONDocument 将任意值转换为通用 JSON 文档（map/slice/float64/string/bool/nil）
+func toJSONDocument(v interface{}) (map[string]interface{}, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+
+	var doc map[string]interface{}
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, err
+	}
+	return doc, nil
+}
+
+// diffDocuments 计算从 oldDoc 到 newDoc 的合并补丁，没有变化时返回空 map
+func diffDocuments(oldDoc, newDoc map[string]interface{}) map[string]interface{} {
+	patch := make(map[string]interface{})
+
+	for key, newVal := range newDoc {
+		oldVal, ok := oldDoc[key]
+		if !ok {
+			patch[key] = newVal
+			continue
+		}
+
+		oldMap, oldIsMap := oldVal.(map[string]interface{})
+		newMap, newIsMap := newVal.(map[string]interface{})
+		if oldIsMap && newIsMap {
+			if sub := diffDocuments(oldMap, newMap); len(sub) > 0 {
+				patch[key] = sub
+			}
+			continue
+		}
+
+		// null 在补丁中表示删除，因此新值为 null 的字段无法表达，直接跳过
+		if newVal == nil {
+			if oldVal != nil {
+				patch[key] = nil
+			}
+			continue
+		}
+
+		if !reflect.DeepEqual(oldVal, newVal) {
+			patch[key] = newVal
+		}
+	}
+
+	for key := range oldDoc {
+		if _, ok := newDoc[key]; !ok {
+			patch[key] = nil
+		}
+	}
+
+	return patch
+}
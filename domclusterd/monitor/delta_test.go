@@ -0,0 +1,73 @@
+package monitor
+
+import (
+	"reflect"
+	"testing"
+
+	"domcluster/api/statusdelta"
+)
+
+// dockerDoc 构造只包含容器列表的状态文档
+func dockerDoc(t *testing.T, containers ...DockerContainer) map[string]interface{} {
+	t.Helper()
+	doc, err := toJSONDocument(map[string]interface{}{
+		"docker": DockerInfo{RunningCount: len(containers), TotalCount: len(containers), Containers: containers},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	statusdelta.KeyArrays(doc)
+	return doc
+}
+
+func TestDiffDocumentsKeyedContainers(t *testing.T) {
+	web := DockerContainer{ID: "aaa", Name: "web", State: "running", CPUPercent: 1.5}
+	db := DockerContainer{ID: "bbb", Name: "db", State: "running", CPUPercent: 3}
+	old := dockerDoc(t, web, db)
+
+	// 只有 web 的 CPU 变化，db 被删除，新增 cache
+	web.CPUPercent = 2.5
+	cache := DockerContainer{ID: "ccc", Name: "cache", State: "created"}
+	patch := diffDocuments(old, dockerDoc(t, web, cache))
+
+	containers := patch["docker"].(map[string]interface{})["containers"].(map[string]interface{})
+	if got := containers["aaa"]; !reflect.DeepEqual(got, map[string]interface{}{"cpu_percent": 2.5}) {
+		t.Errorf("patch for web = %v, want only cpu_percent", got)
+	}
+	if v, ok := containers["bbb"]; !ok || v != nil {
+		t.Errorf("patch for removed db = %v (present %v), want null", v, ok)
+	}
+	if added, _ := containers["ccc"].(map[string]interface{}); added["name"] != "cache" {
+		t.Errorf("patch for cache = %v, want full entry", containers["ccc"])
+	}
+}
+
+func TestDiffDocumentsKeyedResources(t *testing.T) {
+	resourcesDoc := func(core1 float64, ethRate float64) map[string]interface{} {
+		doc, err := toJSONDocument(map[string]interface{}{
+			"system_resources": SystemResources{
+				CPU: &CPUInfo{Cores: []CoreUsage{{ID: 0, UsagePercent: 10}, {ID: 1, UsagePercent: core1}}},
+				Network: &NetworkInfo{Interfaces: []InterfaceInfo{
+					{Name: "lo", OperState: "unknown"},
+					{Name: "eth0", OperState: "up", RxBytesPerSec: ethRate},
+				}},
+			},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		statusdelta.KeyArrays(doc)
+		return doc
+	}
+
+	patch := diffDocuments(resourcesDoc(20, 100), resourcesDoc(30, 200))
+	want := map[string]interface{}{
+		"system_resources": map[string]interface{}{
+			"cpu":     map[string]interface{}{"cores": map[string]interface{}{"1": map[string]interface{}{"usage_percent": 30.0}}},
+			"network": map[string]interface{}{"interfaces": map[string]interface{}{"eth0": map[string]interface{}{"rx_bytes_per_sec": 200.0}}},
+		},
+	}
+	if !reflect.DeepEqual(patch, want) {
+		t.Errorf("patch = %v, want %v", patch, want)
+	}
+}
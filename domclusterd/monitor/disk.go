@@ -0,0 +1,184 @@
+package monitor
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// diskSectorSize /proc/diskstats 中扇区计数的固定单位（与设备实际扇区大小无关）
+const diskSectorSize = 512
+
+// pseudoFSTypes 不属于真实磁盘的文件系统类型
+var pseudoFSTypes = map[string]bool{
+	"autofs": true, "binfmt_misc": true, "bpf": true, "cgroup": true, "cgroup2": true,
+	"configfs": true, "debugfs": true, "devpts": true, "devtmpfs": true, "efivarfs": true,
+	"fuse.lxcfs": true, "fusectl": true, "hugetlbfs": true, "mqueue": true, "nsfs": true,
+	"overlay": true, "proc": true, "pstore": true, "ramfs": true, "rpc_pipefs": true,
+	"securityfs": true, "selinuxfs": true, "squashfs": true, "sysfs": true, "tmpfs": true,
+	"tracefs": true,
+}
+
+// DiskIOInfo 块设备 I/O 统计
+type DiskIOInfo struct {
+	Device           string  `json:"device"`
+	ReadBytes        uint64  `json:"read_bytes"`
+	WriteBytes       uint64  `json:"write_bytes"`
+	ReadBytesPerSec  float64 `json:"read_bytes_per_sec"`
+	WriteBytesPerSec float64 `json:"write_bytes_per_sec"`
+	ReadIOPS         float64 `json:"read_iops"`
+	WriteIOPS        float64 `json:"write_iops"`
+	UtilPercent      float64 `json:"util_percent"`
+}
+
+// SetDiskMounts 设置需要上报的挂载点，为空时自动发现所有真实文件系统
+func (m *Monitor) SetDiskMounts(paths []string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.diskMounts = append([]string(nil), paths...)
+}
+
+// getDisks 获取所有需上报挂载点的磁盘信息
+func (m *Monitor) getDisks() ([]DiskInfo, error) {
+	m.mu.Lock()
+	configured := append([]string(nil), m.diskMounts...)
+	m.mu.Unlock()
+
+	mounts, err := readMounts(procPath(m.procRoot, "self", "mounts"))
+	if err != nil {
+		return nil, err
+	}
+
+	var targets []mountEntry
+	if len(configured) > 0 {
+		targets = selectMounts(mounts, configured)
+	} else {
+		targets = discoverMounts(mounts)
+	}
+
+	disks := make([]DiskInfo, 0, len(targets))
+	for _, mnt := range targets {
+		info, err := m.getDiskInfo(mnt.MountPoint)
+		if err != nil {
+			zap.L().Warn("failed to stat filesystem", zap.String("path", mnt.MountPoint), zap.Error(err))
+			continue
+		}
+		info.Device = mnt.Device
+		info.FSType = mnt.FSType
+		disks = append(disks, *info)
+	}
+
+	return disks, nil
+}
+
+// discoverMounts 选出真实文件系统，同一设备只保留最先出现的挂载点
+func discoverMounts(mounts []mountEntry) []mountEntry {
+	seen := make(map[string]bool)
+	var result []mountEntry
+	for _, mnt := range mounts {
+		if pseudoFSTypes[mnt.FSType] || mnt.Device == "none" {
+			continue
+		}
+		if seen[mnt.Device] {
+			continue
+		}
+		seen[mnt.Device] = true
+		result = append(result, mnt)
+	}
+	return result
+}
+
+// selectMounts 按配置的路径选出挂载记录；路径不是挂载点时使用其所在的挂载
+func selectMounts(mounts []mountEntry, paths []string) []mountEntry {
+	result := make([]mountEntry, 0, len(paths))
+	for _, path := range paths {
+		path = filepath.Clean(path)
+
+		// 取最长前缀匹配的挂载点，后出现的挂载会覆盖先出现的同名挂载
+		var best *mountEntry
+		for i := range mounts {
+			mp := mounts[i].MountPoint
+			if path != mp && !strings.HasPrefix(path, strings.TrimSuffix(mp, "/")+"/") {
+				continue
+			}
+			if best == nil || len(mp) >= len(best.MountPoint) {
+				best = &mounts[i]
+			}
+		}
+
+		entry := mountEntry{MountPoint: path}
+		if best != nil {
+			entry.Device = best.Device
+			entry.FSType = best.FSType
+		}
+		result = append(result, entry)
+	}
+	return result
+}
+
+// getDiskIO 获取块设备 I/O 统计，速率根据两次采样之间的增量计算
+func (m *Monitor) getDiskIO() ([]DiskIOInfo, error) {
+	stats, err := readDiskStats(procPath(m.procRoot, "diskstats"))
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+
+	m.mu.Lock()
+	prev := m.lastDiskStats
+	elapsed := now.Sub(m.lastDiskTime).Seconds()
+	current := make(map[string]diskStat, len(stats))
+	m.lastDiskStats = current
+	m.lastDiskTime = now
+	m.mu.Unlock()
+
+	result := make([]DiskIOInfo, 0, len(stats))
+	for _, s := range stats {
+		if !m.isWholeDisk(s.Name) {
+			continue
+		}
+		current[s.Name] = s
+
+		info := DiskIOInfo{
+			Device:     s.Name,
+			ReadBytes:  s.SectorsRead * diskSectorSize,
+			WriteBytes: s.SectorsWritten * diskSectorSize,
+		}
+
+		if last, ok := prev[s.Name]; ok && elapsed > 0 {
+			info.ReadBytesPerSec = counterRate(last.SectorsRead, s.SectorsRead, elapsed) * diskSectorSize
+			info.WriteBytesPerSec = counterRate(last.SectorsWritten, s.SectorsWritten, elapsed) * diskSectorSize
+			info.ReadIOPS = counterRate(last.ReadsCompleted, s.ReadsCompleted, elapsed)
+			info.WriteIOPS = counterRate(last.WritesCompleted, s.WritesCompleted, elapsed)
+			// io_ticks 单位为毫秒
+			info.UtilPercent = counterRate(last.IOTicks, s.IOTicks, elapsed) / 10
+			if info.UtilPercent > 100 {
+				info.UtilPercent = 100
+			}
+		}
+
+		result = append(result, info)
+	}
+
+	return result, nil
+}
+
+// isWholeDisk 判断设备是否为整块磁盘（排除分区、loop 和 ram 设备）
+func (m *Monitor) isWholeDisk(name string) bool {
+	if strings.HasPrefix(name, "loop") || strings.HasPrefix(name, "ram") {
+		return false
+	}
+	_, err := os.Stat(filepath.Join(m.sysRoot, "block", name))
+	return err == nil
+}
+
+// counterRate 计算单调递增计数器的每秒增量，计数器回绕或重置时返回 0
+func counterRate(prev, cur uint64, elapsed float64) float64 {
+	if cur < prev || elapsed <= 0 {
+		return 0
+	}
+	return float64(cur-prev) / elapsed
+}
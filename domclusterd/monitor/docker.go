@@ -0,0 +1,220 @@
+package monitor
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"domclusterd/dockerctl"
+
+	"github.com/docker/docker/api/types/container"
+	"go.uber.org/zap"
+)
+
+const (
+	// dockerStatsConcurrency 同时进行的容器 stats/inspect 请求数上限
+	dockerStatsConcurrency = 8
+	// dockerCollectTimeout 单次采集所有容器指标的超时时间
+	dockerCollectTimeout = 4 * time.Second
+)
+
+// DockerContainer Docker容器信息
+type DockerContainer struct {
+	ID             string  `json:"id"` // 12 位短 ID，与 docker ps 一致
+	FullID         string  `json:"full_id"`
+	Name           string  `json:"name"`
+	Image          string  `json:"image"`
+	Status         string  `json:"status"`
+	State          string  `json:"state"`
+	Health         string  `json:"health,omitempty"`
+	RestartCount   int     `json:"restart_count"`
+	Ports          string  `json:"ports"`
+	CreatedAt      string  `json:"created_at"`
+	CPUPercent     float64 `json:"cpu_percent"`
+	MemoryUsage    uint64  `json:"memory_usage"`
+	MemoryLimit    uint64  `json:"memory_limit"`
+	MemoryPercent  float64 `json:"memory_percent"`
+	NetworkRxBytes uint64  `json:"network_rx_bytes"`
+	NetworkTxBytes uint64  `json:"network_tx_bytes"`
+}
+
+// DockerInfo Docker信息
+type DockerInfo struct {
+	RunningCount int               `json:"running_count"`
+	TotalCount   int               `json:"total_count"`
+	Containers   []DockerContainer `json:"containers"`
+}
+
+// containerInspect 缓存的容器 inspect 结果
+//
+// 重启次数和健康状态只在容器状态变化时改变，list 返回的 State 和 Status
+// （如 "Up 3 minutes (healthy)"）不变时沿用缓存，不必每次上报都 inspect 所有容器。
+type containerInspect struct {
+	stateKey     string
+	restartCount int
+	health       string
+}
+
+// SetDockerClient 设置 Docker 客户端，为 nil 时不采集容器信息
+func (m *Monitor) SetDockerClient(client *dockerctl.DockerClient) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.docker = client
+}
+
+// GetDockerInfo 获取Docker容器情况
+func (m *Monitor) GetDockerInfo() (*DockerInfo, error) {
+	containers, err := m.getDockerContainers()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get docker containers: %w", err)
+	}
+
+	// 按名字排序，控制端从增量还原的列表使用相同的顺序
+	sort.Slice(containers, func(i, j int) bool { return containers[i].Name < containers[j].Name })
+
+	runningCount := 0
+	for _, c := range containers {
+		if c.State == "running" {
+			runningCount++
+		}
+	}
+
+	return &DockerInfo{
+		RunningCount: runningCount,
+		TotalCount:   len(containers),
+		Containers:   containers,
+	}, nil
+}
+
+// getDockerContainers 通过 Docker API 获取容器列表及资源使用情况
+func (m *Monitor) getDockerContainers() ([]DockerContainer, error) {
+	m.mu.Lock()
+	client := m.docker
+	m.mu.Unlock()
+
+	if client == nil {
+		return nil, fmt.Errorf("docker client not available")
+	}
+
+	list, err := client.ListContainers(true)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(m.ctx, dockerCollectTimeout)
+	defer cancel()
+
+	containers := make([]DockerContainer, len(list))
+	samples := make(map[string]container.CPUStats, len(list))
+	inspected := make(map[string]containerInspect, len(list))
+	var resultsMu sync.Mutex
+
+	m.mu.Lock()
+	prevSamples := m.lastContainerCPU
+	prevInspect := m.inspectCache
+	m.mu.Unlock()
+
+	sem := make(chan struct{}, dockerStatsConcurrency)
+	var wg sync.WaitGroup
+	for i, c := range list {
+		containers[i] = DockerContainer{
+			ID:        c.ID,
+			FullID:    c.FullID,
+			Name:      strings.TrimPrefix(c.Name, "/"),
+			Image:     c.Image,
+			Status:    c.Status,
+			State:     c.State,
+			Ports:     formatPorts(c.Ports),
+			CreatedAt: time.Unix(c.Created, 0).Format(time.RFC3339),
+		}
+
+		wg.Add(1)
+		go func(dc *DockerContainer) {
+			defer wg.Done()
+
+			stateKey := dc.State + "|" + dc.Status
+			cached, fresh := prevInspect[dc.FullID]
+			fresh = fresh && cached.stateKey == stateKey
+			if fresh {
+				dc.RestartCount = cached.restartCount
+				dc.Health = cached.health
+				resultsMu.Lock()
+				inspected[dc.FullID] = cached
+				resultsMu.Unlock()
+				if dc.State != "running" {
+					return
+				}
+			}
+
+			select {
+			case sem <- struct{}{}:
+				defer func() { <-sem }()
+			case <-ctx.Done():
+				return
+			}
+
+			if !fresh {
+				if inspect, err := client.InspectContainer(dc.FullID); err == nil {
+					dc.RestartCount = inspect.RestartCount
+					if inspect.State != nil && inspect.State.Health != nil {
+						dc.Health = inspect.State.Health.Status
+					}
+					resultsMu.Lock()
+					inspected[dc.FullID] = containerInspect{stateKey: stateKey, restartCount: dc.RestartCount, health: dc.Health}
+					resultsMu.Unlock()
+				} else {
+					zap.L().Debug("failed to inspect container", zap.String("id", dc.ID), zap.Error(err))
+				}
+			}
+
+			if dc.State != "running" {
+				return
+			}
+
+			stats, err := client.GetContainerStatsOneShot(ctx, dc.FullID)
+			if err != nil {
+				zap.L().Debug("failed to get container stats", zap.String("id", dc.ID), zap.Error(err))
+				return
+			}
+
+			if prev, ok := prevSamples[dc.FullID]; ok {
+				dc.CPUPercent = dockerctl.CalculateCPUPercent(prev, stats.CPUStats)
+			}
+			dc.MemoryUsage = dockerctl.CalculateMemoryUsage(stats.MemoryStats)
+			dc.MemoryLimit = stats.MemoryStats.Limit
+			if dc.MemoryLimit > 0 {
+				dc.MemoryPercent = float64(dc.MemoryUsage) / float64(dc.MemoryLimit) * 100
+			}
+			dc.NetworkRxBytes, dc.NetworkTxBytes = dockerctl.CalculateNetworkIO(stats.Networks)
+
+			resultsMu.Lock()
+			samples[dc.FullID] = stats.CPUStats
+			resultsMu.Unlock()
+		}(&containers[i])
+	}
+	wg.Wait()
+
+	// 只保留本轮仍存在的容器的采样和 inspect 结果，已删除容器的数据随之丢弃
+	m.mu.Lock()
+	m.lastContainerCPU = samples
+	m.inspectCache = inspected
+	m.mu.Unlock()
+
+	return containers, nil
+}
+
+// formatPorts 将端口映射格式化为与 docker ps 相同的字符串
+func formatPorts(ports []container.Port) string {
+	parts := make([]string, 0, len(ports))
+	for _, p := range ports {
+		if p.PublicPort != 0 {
+			parts = append(parts, fmt.Sprintf("%s:%d->%d/%s", p.IP, p.PublicPort, p.PrivatePort, p.Type))
+		} else {
+			parts = append(parts, fmt.Sprintf("%d/%s", p.PrivatePort, p.Type))
+		}
+	}
+	return strings.Join(parts, ", ")
+}
@@ -2,17 +2,23 @@ package monitor
 
 import (
 	"context"
-	"encoding/json"
-	"fmt"
 	"os"
-	"os/exec"
 	"runtime"
-	"strings"
+	"sync"
+	"syscall"
 	"time"
 
+	"domclusterd/dockerctl"
+
+	"domcluster/api/format"
+
+	"github.com/docker/docker/api/types/container"
 	"go.uber.org/zap"
 )
 
+// cpuSampleInterval 后台采样 /proc/stat 的间隔，CPU 使用率为最近一个间隔内的平均值
+const cpuSampleInterval = 2 * time.Second
+
 // HostInfo 主机基本信息
 type HostInfo struct {
 	Hostname     string `json:"hostname"`
@@ -20,74 +26,133 @@ type HostInfo struct {
 	Architecture string `json:"architecture"`
 	GoVersion    string `json:"go_version"`
 	NumCPU       int    `json:"num_cpu"`
+	// CPUs 在线逻辑 CPU 的拓扑，无法读取 /sys 时为空
+	CPUs []LogicalCPU `json:"cpus,omitempty"`
+	// Cores 物理核心及其 SMT 兄弟线程
+	Cores []PhysicalCore `json:"cores,omitempty"`
+	// ThreadsPerCore 每个物理核心的线程数，大于 1 表示开启了 SMT
+	ThreadsPerCore int `json:"threads_per_core,omitempty"`
 }
 
 // CPUInfo CPU信息
 type CPUInfo struct {
-	CoreCount    int     `json:"core_count"`
+	CoreCount         int         `json:"core_count"`
+	UsagePercent      float64     `json:"usage_percent"`
+	IOWaitPercent     float64     `json:"iowait_percent"`
+	Cores             []CoreUsage `json:"cores,omitempty"`
+	ContextSwitches   uint64      `json:"context_switches"`
+	ContextSwitchRate float64     `json:"context_switch_rate"`
+}
+
+// CoreUsage 单核CPU使用率
+type CoreUsage struct {
+	ID           int     `json:"id"`
 	UsagePercent float64 `json:"usage_percent"`
 }
 
 // MemoryInfo 内存信息
 type MemoryInfo struct {
-	Total        uint64  `json:"total"`
-	Used         uint64  `json:"used"`
-	Available    uint64  `json:"available"`
-	UsagePercent float64 `json:"usage_percent"`
+	Total            uint64  `json:"total"`
+	Used             uint64  `json:"used"`
+	Available        uint64  `json:"available"`
+	Buffers          uint64  `json:"buffers"`
+	Cached           uint64  `json:"cached"`
+	UsagePercent     float64 `json:"usage_percent"`
+	SwapTotal        uint64  `json:"swap_total"`
+	SwapUsed         uint64  `json:"swap_used"`
+	SwapFree         uint64  `json:"swap_free"`
+	SwapUsagePercent float64 `json:"swap_usage_percent"`
+}
+
+// LoadAverage 系统负载
+type LoadAverage struct {
+	Load1        float64 `json:"load1"`
+	Load5        float64 `json:"load5"`
+	Load15       float64 `json:"load15"`
+	RunningProcs int     `json:"running_procs"`
+	TotalProcs   int     `json:"total_procs"`
 }
 
 // DiskInfo 磁盘信息
 type DiskInfo struct {
-	Path         string  `json:"path"`
-	Total        uint64  `json:"total"`
-	Used         uint64  `json:"used"`
-	Free         uint64  `json:"free"`
-	UsagePercent float64 `json:"usage_percent"`
+	Path              string  `json:"path"`
+	Device            string  `json:"device,omitempty"`
+	FSType            string  `json:"fs_type,omitempty"`
+	Total             uint64  `json:"total"`
+	Used              uint64  `json:"used"`
+	Free              uint64  `json:"free"`
+	UsagePercent      float64 `json:"usage_percent"`
+	InodesTotal       uint64  `json:"inodes_total"`
+	InodesUsed        uint64  `json:"inodes_used"`
+	InodesFree        uint64  `json:"inodes_free"`
+	InodeUsagePercent float64 `json:"inode_usage_percent"`
 }
 
-// NetworkInfo 网络信息
+// NetworkInfo 网络信息，汇总值只统计通过过滤的网卡
 type NetworkInfo struct {
-	RxBytes uint64 `json:"rx_bytes"`
-	TxBytes uint64 `json:"tx_bytes"`
+	RxBytes       uint64          `json:"rx_bytes"`
+	TxBytes       uint64          `json:"tx_bytes"`
+	RxBytesPerSec float64         `json:"rx_bytes_per_sec"`
+	TxBytesPerSec float64         `json:"tx_bytes_per_sec"`
+	Interfaces    []InterfaceInfo `json:"interfaces"`
 }
 
 // SystemResources 系统资源信息
 type SystemResources struct {
 	CPU     *CPUInfo     `json:"cpu"`
 	Memory  *MemoryInfo  `json:"memory"`
-	Disk    *DiskInfo    `json:"disk"`
+	Disk    *DiskInfo    `json:"disk"` // 根文件系统，兼容旧版本
+	Disks   []DiskInfo   `json:"disks"`
+	DiskIO  []DiskIOInfo `json:"disk_io"`
 	Network *NetworkInfo `json:"network"`
-}
-
-// DockerContainer Docker容器信息
-type DockerContainer struct {
-	ID        string `json:"id"`
-	Name      string `json:"name"`
-	Image     string `json:"image"`
-	Status    string `json:"status"`
-	Ports     string `json:"ports"`
-	CreatedAt string `json:"created_at"`
-}
-
-// DockerInfo Docker信息
-type DockerInfo struct {
-	RunningCount int               `json:"running_count"`
-	TotalCount   int               `json:"total_count"`
-	Containers   []DockerContainer `json:"containers"`
+	LoadAvg *LoadAverage `json:"load_avg"`
+	Uptime  float64      `json:"uptime"`
 }
 
 // Monitor 监控器
 type Monitor struct {
 	ctx      context.Context
 	diskPath string
+	procRoot string
+	sysRoot  string
+
+	mu                 sync.Mutex
+	diskMounts         []string
+	includeVirtualNICs bool
+	excludeNICs        []string
+
+	// CPU 使用率由后台采样器计算
+	cpu *cpuSampler
+
+	// 上一次采样，用于计算磁盘 I/O 和网络速率
+	lastDiskStats map[string]diskStat
+	lastDiskTime  time.Time
+	lastNetStats  map[string]netDevStat
+	lastNetTime   time.Time
+
+	docker           *dockerctl.DockerClient
+	lastContainerCPU map[string]container.CPUStats
+	inspectCache     map[string]containerInspect
+
+	// UID 到用户名的缓存
+	userNames map[int]string
+
+	// 健康探针
+	probeMu sync.Mutex
+	probes  []*probeRunner
 }
 
 // NewMonitor 创建监控器
 func NewMonitor(ctx context.Context) *Monitor {
-	return &Monitor{
+	m := &Monitor{
 		ctx:      ctx,
 		diskPath: "/",
+		procRoot: "/proc",
+		sysRoot:  "/sys",
 	}
+	m.cpu = newCPUSampler(procPath(m.procRoot, "stat"))
+	go m.cpu.run(ctx)
+	return m
 }
 
 // GetHostInfo 获取主机基本信息
@@ -98,13 +163,25 @@ func (m *Monitor) GetHostInfo() (*HostInfo, error) {
 		hostname = "unknown"
 	}
 
-	return &HostInfo{
+	info := &HostInfo{
 		Hostname:     hostname,
 		OS:           runtime.GOOS,
 		Architecture: runtime.GOARCH,
 		GoVersion:    runtime.Version(),
 		NumCPU:       runtime.NumCPU(),
-	}, nil
+	}
+
+	cpus, cores, err := readCPUTopology(m.sysRoot)
+	if err != nil {
+		zap.L().Debug("failed to read CPU topology", zap.Error(err))
+		return info, nil
+	}
+	info.CPUs = cpus
+	info.Cores = cores
+	if len(cores) > 0 {
+		info.ThreadsPerCore = (len(cpus) + len(cores) - 1) / len(cores)
+	}
+	return info, nil
 }
 
 // GetSystemResources 获取系统资源使用情况
@@ -124,212 +201,233 @@ func (m *Monitor) GetSystemResources() (*SystemResources, error) {
 		zap.L().Warn("failed to get disk info", zap.Error(err))
 	}
 
+	disks, err := m.getDisks()
+	if err != nil {
+		zap.L().Warn("failed to get mounted disks", zap.Error(err))
+	}
+
+	diskIO, err := m.getDiskIO()
+	if err != nil {
+		zap.L().Warn("failed to get disk io stats", zap.Error(err))
+	}
+
 	networkInfo, err := m.getNetworkInfo()
 	if err != nil {
 		zap.L().Warn("failed to get network info", zap.Error(err))
 	}
 
+	loadAvg, err := readLoadAvg(procPath(m.procRoot, "loadavg"))
+	if err != nil {
+		zap.L().Warn("failed to get load average", zap.Error(err))
+	}
+
+	uptime, err := readUptime(procPath(m.procRoot, "uptime"))
+	if err != nil {
+		zap.L().Warn("failed to get uptime", zap.Error(err))
+	}
+
 	return &SystemResources{
 		CPU:     cpuInfo,
 		Memory:  memInfo,
 		Disk:    diskInfo,
+		Disks:   disks,
+		DiskIO:  diskIO,
 		Network: networkInfo,
+		LoadAvg: loadAvg,
+		Uptime:  uptime,
 	}, nil
 }
 
-// GetDockerInfo 获取Docker容器情况
-func (m *Monitor) GetDockerInfo() (*DockerInfo, error) {
-	containers, err := m.getDockerContainers()
-	if err != nil {
-		return nil, fmt.Errorf("failed to get docker containers: %w", err)
-	}
+// getCPUInfo 获取CPU信息
+//
+// 返回后台采样器最近一次计算的结果；启动后尚未完成两次采样时返回 nil，不上报使用率。
+func (m *Monitor) getCPUInfo() (*CPUInfo, error) {
+	return m.cpu.current(), nil
+}
 
-	runningCount := 0
-	for _, c := range containers {
-		if strings.Contains(c.Status, "Up") {
-			runningCount++
-		}
-	}
+// cpuSampler 按固定间隔采样 /proc/stat，所有读取者共用最近两次采样之间的使用率，
+// 状态上报和资源查询互不影响彼此的采样窗口
+type cpuSampler struct {
+	path string
 
-	return &DockerInfo{
-		RunningCount: runningCount,
-		TotalCount:   len(containers),
-		Containers:   containers,
-	}, nil
+	mu       sync.Mutex
+	prev     *procStat
+	prevTime time.Time
+	latest   *CPUInfo
 }
 
-// getCPUInfo 获取CPU信息
-func (m *Monitor) getCPUInfo() (*CPUInfo, error) {
-	cmd := exec.Command("sh", "-c", "top -bn1 | grep 'Cpu(s)' | awk '{print $2}' | cut -d'%' -f1")
-	output, err := cmd.Output()
-	if err != nil {
-		return nil, err
-	}
+// newCPUSampler 创建 CPU 采样器
+func newCPUSampler(path string) *cpuSampler {
+	return &cpuSampler{path: path}
+}
 
-	var usage float64
-	_, err = fmt.Sscanf(strings.TrimSpace(string(output)), "%f", &usage)
-	if err != nil {
-		return &CPUInfo{
-			CoreCount:    runtime.NumCPU(),
-			UsagePercent: 0,
-		}, nil
-	}
+// run 立即采样一次，之后每隔 cpuSampleInterval 采样，直到 ctx 结束
+func (s *cpuSampler) run(ctx context.Context) {
+	ticker := time.NewTicker(cpuSampleInterval)
+	defer ticker.Stop()
 
-	return &CPUInfo{
-		CoreCount:    runtime.NumCPU(),
-		UsagePercent: usage,
-	}, nil
+	for {
+		if err := s.sample(time.Now()); err != nil {
+			zap.L().Debug("failed to sample CPU stats", zap.Error(err))
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
 }
 
-// getMemoryInfo 获取内存信息
-func (m *Monitor) getMemoryInfo() (*MemoryInfo, error) {
-	cmd := exec.Command("sh", "-c", "free -b | grep Mem")
-	output, err := cmd.Output()
+// sample 读取一次 /proc/stat，与上一次采样比较得出使用率
+func (s *cpuSampler) sample(now time.Time) error {
+	stat, err := readProcStat(s.path)
 	if err != nil {
-		return nil, err
+		return err
 	}
 
-	fields := strings.Fields(string(output))
-	if len(fields) < 4 {
-		return &MemoryInfo{}, nil
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.prev != nil {
+		s.latest = cpuInfoBetween(s.prev, stat, now.Sub(s.prevTime))
 	}
+	s.prev = stat
+	s.prevTime = now
+	return nil
+}
 
-	var total, used, free, available uint64
-	fmt.Sscanf(fields[1], "%d", &total)
-	fmt.Sscanf(fields[2], "%d", &used)
-	fmt.Sscanf(fields[3], "%d", &free)
-	if len(fields) >= 7 {
-		fmt.Sscanf(fields[6], "%d", &available)
-	} else {
-		available = free
+// current 返回最近一次计算结果的副本，尚无两次采样时返回 nil
+func (s *cpuSampler) current() *CPUInfo {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.latest == nil {
+		return nil
 	}
-
-	usagePercent := float64(used) / float64(total) * 100
-
-	return &MemoryInfo{
-		Total:        total,
-		Used:         used,
-		Available:    available,
-		UsagePercent: usagePercent,
-	}, nil
+	info := *s.latest
+	info.Cores = append([]CoreUsage(nil), s.latest.Cores...)
+	return &info
 }
 
-// getDiskInfo 获取磁盘信息
-func (m *Monitor) getDiskInfo(path string) (*DiskInfo, error) {
-	cmd := exec.Command("df", "-B1", path)
-	output, err := cmd.Output()
-	if err != nil {
-		return nil, err
+// cpuInfoBetween 根据相隔 elapsed 的两次采样计算 CPU 信息
+func cpuInfoBetween(prev, stat *procStat, elapsed time.Duration) *CPUInfo {
+	info := &CPUInfo{
+		CoreCount:       len(stat.Cores),
+		UsagePercent:    cpuUsagePercent(prev.Total, stat.Total),
+		IOWaitPercent:   cpuIOWaitPercent(prev.Total, stat.Total),
+		Cores:           make([]CoreUsage, 0, len(stat.Cores)),
+		ContextSwitches: stat.ContextSwitches,
 	}
-
-	lines := strings.Split(string(output), "\n")
-	if len(lines) < 2 {
-		return &DiskInfo{}, nil
+	if info.CoreCount == 0 {
+		info.CoreCount = runtime.NumCPU()
 	}
 
-	fields := strings.Fields(lines[1])
-	if len(fields) < 5 {
-		return &DiskInfo{}, nil
+	for i, cur := range stat.Cores {
+		var last cpuTimes
+		if i < len(prev.Cores) {
+			last = prev.Cores[i]
+		}
+		info.Cores = append(info.Cores, CoreUsage{
+			ID:           i,
+			UsagePercent: cpuUsagePercent(last, cur),
+		})
 	}
 
-	var total, used, free uint64
-	fmt.Sscanf(fields[1], "%d", &total)
-	fmt.Sscanf(fields[2], "%d", &used)
-	fmt.Sscanf(fields[3], "%d", &free)
-
-	usagePercent := float64(used) / float64(total) * 100
-
-	return &DiskInfo{
-		Path:         path,
-		Total:        total,
-		Used:         used,
-		Free:         free,
-		UsagePercent: usagePercent,
-	}, nil
+	if stat.ContextSwitches >= prev.ContextSwitches && elapsed > 0 {
+		info.ContextSwitchRate = float64(stat.ContextSwitches-prev.ContextSwitches) / elapsed.Seconds()
+	}
+	return info
 }
 
-// getNetworkInfo 获取网络信息
-func (m *Monitor) getNetworkInfo() (*NetworkInfo, error) {
-	// 读取 /proc/net/dev 获取网络流量统计
-	cmd := exec.Command("sh", "-c", "cat /proc/net/dev | tail -n +3 | awk '{rx+=$2; tx+=$10} END {print rx, tx}'")
-	output, err := cmd.Output()
-	if err != nil {
-		return &NetworkInfo{}, nil
+// cpuUsagePercent 计算两次采样之间的 CPU 使用率
+func cpuUsagePercent(prev, cur cpuTimes) float64 {
+	total := float64(cur.total()) - float64(prev.total())
+	if total <= 0 {
+		return 0
 	}
+	idle := float64(cur.idle()) - float64(prev.idle())
+	return (total - idle) / total * 100
+}
 
-	var rxBytes, txBytes uint64
-	_, err = fmt.Sscanf(strings.TrimSpace(string(output)), "%d %d", &rxBytes, &txBytes)
-	if err != nil {
-		return &NetworkInfo{}, nil
+// cpuIOWaitPercent 计算两次采样之间的 iowait 占比
+func cpuIOWaitPercent(prev, cur cpuTimes) float64 {
+	total := float64(cur.total()) - float64(prev.total())
+	if total <= 0 {
+		return 0
 	}
-
-	return &NetworkInfo{
-		RxBytes: rxBytes,
-		TxBytes: txBytes,
-	}, nil
+	return (float64(cur.IOWait) - float64(prev.IOWait)) / total * 100
 }
 
-// getDockerContainers 获取Docker容器列表
-func (m *Monitor) getDockerContainers() ([]DockerContainer, error) {
-	// 检查docker是否可用
-	if _, err := exec.LookPath("docker"); err != nil {
-		return nil, fmt.Errorf("docker command not found: %w", err)
+// getMemoryInfo 获取内存信息
+func (m *Monitor) getMemoryInfo() (*MemoryInfo, error) {
+	mem, err := readMemInfo(procPath(m.procRoot, "meminfo"))
+	if err != nil {
+		return nil, err
 	}
 
-	cmd := exec.Command("docker", "ps", "-a", "--format", "{{json .}}")
-	output, err := cmd.Output()
-	if err != nil {
-		return nil, fmt.Errorf("failed to execute docker ps: %w", err)
+	info := &MemoryInfo{
+		Total:     mem.MemTotal,
+		Available: mem.MemAvailable,
+		Buffers:   mem.Buffers,
+		Cached:    mem.Cached,
+		SwapTotal: mem.SwapTotal,
+		SwapFree:  mem.SwapFree,
+	}
+	if mem.MemTotal > mem.MemAvailable {
+		info.Used = mem.MemTotal - mem.MemAvailable
+	}
+	if mem.MemTotal > 0 {
+		info.UsagePercent = float64(info.Used) / float64(mem.MemTotal) * 100
+	}
+	if mem.SwapTotal > mem.SwapFree {
+		info.SwapUsed = mem.SwapTotal - mem.SwapFree
+	}
+	if mem.SwapTotal > 0 {
+		info.SwapUsagePercent = float64(info.SwapUsed) / float64(mem.SwapTotal) * 100
 	}
 
-	lines := strings.Split(string(output), "\n")
-	containers := make([]DockerContainer, 0, len(lines))
+	return info, nil
+}
 
-	for _, line := range lines {
-		line = strings.TrimSpace(line)
-		if line == "" {
-			continue
-		}
+// getDiskInfo 获取磁盘信息
+func (m *Monitor) getDiskInfo(path string) (*DiskInfo, error) {
+	var fs syscall.Statfs_t
+	if err := syscall.Statfs(path, &fs); err != nil {
+		return nil, err
+	}
 
-		var container struct {
-			ID      string `json:"ID"`
-			Names   string `json:"Names"`
-			Image   string `json:"Image"`
-			Status  string `json:"Status"`
-			Ports   string `json:"Ports"`
-			Created string `json:"CreatedAt"`
-		}
+	bsize := uint64(fs.Bsize)
+	total := fs.Blocks * bsize
+	free := fs.Bavail * bsize
+	used := (fs.Blocks - fs.Bfree) * bsize
 
-		if err := json.Unmarshal([]byte(line), &container); err != nil {
-			zap.L().Warn("failed to parse docker container info", zap.String("line", line), zap.Error(err))
-			continue
-		}
+	// 与 df 一致：使用率 = 已用 / (已用 + 普通用户可用)
+	var usagePercent float64
+	if used+free > 0 {
+		usagePercent = float64(used) / float64(used+free) * 100
+	}
 
-		containers = append(containers, DockerContainer{
-			ID:        container.ID[:12],
-			Name:      strings.TrimPrefix(container.Names, "/"),
-			Image:     container.Image,
-			Status:    container.Status,
-			Ports:     container.Ports,
-			CreatedAt: container.Created,
-		})
+	// 部分文件系统（如 btrfs）不提供 inode 计数，此时均为 0
+	var inodeUsagePercent float64
+	inodesUsed := fs.Files - fs.Ffree
+	if fs.Files > 0 {
+		inodeUsagePercent = float64(inodesUsed) / float64(fs.Files) * 100
 	}
 
-	return containers, nil
+	return &DiskInfo{
+		Path:              path,
+		Total:             total,
+		Used:              used,
+		Free:              free,
+		UsagePercent:      usagePercent,
+		InodesTotal:       fs.Files,
+		InodesUsed:        inodesUsed,
+		InodesFree:        fs.Ffree,
+		InodeUsagePercent: inodeUsagePercent,
+	}, nil
 }
 
 // FormatBytes 格式化字节数
 func FormatBytes(bytes uint64) string {
-	const unit = 1024
-	if bytes < unit {
-		return fmt.Sprintf("%d B", bytes)
-	}
-	div, exp := uint64(unit), 0
-	for n := bytes / unit; n >= unit; n /= unit {
-		div *= unit
-		exp++
-	}
-	return fmt.Sprintf("%.2f %cB", float64(bytes)/float64(div), "KMGTPE"[exp])
+	return format.Bytes(bytes)
 }
 
 // GetMonitorReport 获取监控报告
@@ -370,5 +468,6 @@ func (m *Monitor) GetMonitorReport() (map[string]interface{}, error) {
 		"host":             hostInfo,
 		"system_resources": systemResources,
 		"docker":           dockerInfo,
+		"probes":           m.GetProbeStatuses(),
 	}, nil
-}
\ No newline at end of file
+}
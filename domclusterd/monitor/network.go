@@ -0,0 +1,158 @@
+package monitor
+
+import (
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// arphrdLoopback sysfs type 属性中回环网卡的取值（ARPHRD_LOOPBACK）
+const arphrdLoopback = "772"
+
+// InterfaceInfo 单个网卡的统计信息
+type InterfaceInfo struct {
+	Name            string  `json:"name"`
+	OperState       string  `json:"oper_state"`
+	SpeedMbps       int     `json:"speed_mbps"` // 未知时为 -1
+	Virtual         bool    `json:"virtual"`
+	CarrierChanges  uint64  `json:"carrier_changes"`
+	RxBytes         uint64  `json:"rx_bytes"`
+	TxBytes         uint64  `json:"tx_bytes"`
+	RxPackets       uint64  `json:"rx_packets"`
+	TxPackets       uint64  `json:"tx_packets"`
+	RxErrors        uint64  `json:"rx_errors"`
+	TxErrors        uint64  `json:"tx_errors"`
+	RxDropped       uint64  `json:"rx_dropped"`
+	TxDropped       uint64  `json:"tx_dropped"`
+	RxBytesPerSec   float64 `json:"rx_bytes_per_sec"`
+	TxBytesPerSec   float64 `json:"tx_bytes_per_sec"`
+	RxPacketsPerSec float64 `json:"rx_packets_per_sec"`
+	TxPacketsPerSec float64 `json:"tx_packets_per_sec"`
+}
+
+// SetNetworkFilter 设置网卡过滤规则
+//
+// includeVirtual 为 false 时忽略回环网卡。bond、VLAN、网桥以及容器内的 veth 都可能是节点的上行链路，
+// 因此不按是否有物理设备过滤，veth、docker 网桥等由 exclude 的 glob 模式（如 "docker*"）排除。
+func (m *Monitor) SetNetworkFilter(includeVirtual bool, exclude []string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.includeVirtualNICs = includeVirtual
+	m.excludeNICs = append([]string(nil), exclude...)
+}
+
+// getNetworkInfo 获取网络信息，速率根据两次采样之间的增量计算
+func (m *Monitor) getNetworkInfo() (*NetworkInfo, error) {
+	stats, err := readNetDev(procPath(m.procRoot, "net", "dev"))
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+
+	m.mu.Lock()
+	includeVirtual := m.includeVirtualNICs
+	exclude := m.excludeNICs
+	prev := m.lastNetStats
+	elapsed := now.Sub(m.lastNetTime).Seconds()
+	current := make(map[string]netDevStat, len(stats))
+	m.lastNetStats = current
+	m.lastNetTime = now
+	m.mu.Unlock()
+
+	info := &NetworkInfo{
+		Interfaces: make([]InterfaceInfo, 0, len(stats)),
+	}
+	for _, s := range stats {
+		current[s.Name] = s
+
+		if matchesAny(s.Name, exclude) {
+			continue
+		}
+		if !includeVirtual && m.isLoopbackInterface(s.Name) {
+			continue
+		}
+
+		iface := InterfaceInfo{
+			Name:           s.Name,
+			OperState:      m.readInterfaceAttr(s.Name, "operstate"),
+			SpeedMbps:      -1,
+			Virtual:        m.isVirtualInterface(s.Name),
+			RxBytes:        s.RxBytes,
+			TxBytes:        s.TxBytes,
+			RxPackets:      s.RxPackets,
+			TxPackets:      s.TxPackets,
+			RxErrors:       s.RxErrors,
+			TxErrors:       s.TxErrors,
+			RxDropped:      s.RxDropped,
+			TxDropped:      s.TxDropped,
+			CarrierChanges: m.readInterfaceCounter(s.Name, "carrier_changes"),
+		}
+		// 链路断开或虚拟网卡读取 speed 会失败或返回 -1
+		if speed, err := strconv.Atoi(m.readInterfaceAttr(s.Name, "speed")); err == nil && speed > 0 {
+			iface.SpeedMbps = speed
+		}
+
+		if last, ok := prev[s.Name]; ok && elapsed > 0 {
+			iface.RxBytesPerSec = counterRate(last.RxBytes, s.RxBytes, elapsed)
+			iface.TxBytesPerSec = counterRate(last.TxBytes, s.TxBytes, elapsed)
+			iface.RxPacketsPerSec = counterRate(last.RxPackets, s.RxPackets, elapsed)
+			iface.TxPacketsPerSec = counterRate(last.TxPackets, s.TxPackets, elapsed)
+		}
+
+		info.RxBytes += iface.RxBytes
+		info.TxBytes += iface.TxBytes
+		info.RxBytesPerSec += iface.RxBytesPerSec
+		info.TxBytesPerSec += iface.TxBytesPerSec
+		info.Interfaces = append(info.Interfaces, iface)
+	}
+
+	return info, nil
+}
+
+// isVirtualInterface 判断网卡是否为虚拟网卡（sysfs 中没有对应的物理设备）
+func (m *Monitor) isVirtualInterface(name string) bool {
+	_, err := os.Stat(filepath.Join(m.sysRoot, "class", "net", name, "device"))
+	return err != nil
+}
+
+// isLoopbackInterface 根据 sysfs 中的网卡类型判断是否为回环网卡，无法读取时按名称判断
+func (m *Monitor) isLoopbackInterface(name string) bool {
+	if typ := m.readInterfaceAttr(name, "type"); typ != "" {
+		return typ == arphrdLoopback
+	}
+	return name == "lo"
+}
+
+// readInterfaceAttr 读取 /sys/class/net/<name>/<attr>，失败时返回空字符串
+func (m *Monitor) readInterfaceAttr(name, attr string) string {
+	data, err := os.ReadFile(filepath.Join(m.sysRoot, "class", "net", name, attr))
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(data))
+}
+
+// readInterfaceCounter 读取 sysfs 中的网卡计数器
+func (m *Monitor) readInterfaceCounter(name, attr string) uint64 {
+	v, _ := strconv.ParseUint(m.readInterfaceAttr(name, attr), 10, 64)
+	return v
+}
+
+// matchesAny 判断名称是否匹配任意一个 glob 模式
+func matchesAny(name string, patterns []string) bool {
+	for _, pattern := range patterns {
+		ok, err := filepath.Match(pattern, name)
+		if err != nil {
+			zap.L().Warn("invalid interface pattern", zap.String("pattern", pattern), zap.Error(err))
+			continue
+		}
+		if ok {
+			return true
+		}
+	}
+	return false
+}
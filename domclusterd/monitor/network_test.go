@@ -0,0 +1,65 @@
+package monitor
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// uplinkMonitor 返回读取 testdata/uplink 中 bond + VLAN 上行链路夹具的监控器
+func uplinkMonitor(includeVirtual bool) *Monitor {
+	root := fixture("uplink")
+	m := &Monitor{
+		procRoot: filepath.Join(root, "proc"),
+		sysRoot:  filepath.Join(root, "sys"),
+	}
+	m.SetNetworkFilter(includeVirtual, []string{"veth*", "docker*", "br-*"})
+	return m
+}
+
+// interfaceNames 返回上报的网卡名称
+func interfaceNames(info *NetworkInfo) []string {
+	names := make([]string, 0, len(info.Interfaces))
+	for _, iface := range info.Interfaces {
+		names = append(names, iface.Name)
+	}
+	return names
+}
+
+func TestNetworkInfoBondVLANUplink(t *testing.T) {
+	info, err := uplinkMonitor(false).getNetworkInfo()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 没有物理设备的 bond0 和 bond0.100 是上行链路，只有回环网卡和匹配排除规则的网卡被忽略
+	want := []string{"eno1", "eno2", "bond0", "bond0.100"}
+	if got := interfaceNames(info); !reflect.DeepEqual(got, want) {
+		t.Fatalf("interfaces = %v, want %v", got, want)
+	}
+
+	byName := make(map[string]InterfaceInfo)
+	for _, iface := range info.Interfaces {
+		byName[iface.Name] = iface
+	}
+	if bond := byName["bond0.100"]; !bond.Virtual || bond.OperState != "up" || bond.SpeedMbps != 20000 {
+		t.Errorf("bond0.100 = %+v, want virtual, up, 20000 Mbps", bond)
+	}
+	if eno := byName["eno1"]; eno.Virtual {
+		t.Errorf("eno1 reported as virtual")
+	}
+	if info.RxBytes != 5000000+4000000+9000000+8000000 {
+		t.Errorf("RxBytes = %d", info.RxBytes)
+	}
+}
+
+func TestNetworkInfoIncludeLoopback(t *testing.T) {
+	info, err := uplinkMonitor(true).getNetworkInfo()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"lo", "eno1", "eno2", "bond0", "bond0.100"}
+	if got := interfaceNames(info); !reflect.DeepEqual(got, want) {
+		t.Errorf("interfaces = %v, want %v", got, want)
+	}
+}
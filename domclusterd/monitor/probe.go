@@ -0,0 +1,414 @@
+package monitor
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// 探针类型
+const (
+	ProbeHTTP    = "http"
+	ProbeTCP     = "tcp"
+	ProbeExec    = "exec"
+	ProbeFile    = "file"
+	ProbeProcess = "process"
+)
+
+// 探针状态
+const (
+	ProbeStateUnknown   = "unknown"
+	ProbeStateHealthy   = "healthy"
+	ProbeStateUnhealthy = "unhealthy"
+)
+
+// ProbeSpec 健康探针定义
+//
+// 连续成功 SuccessThreshold 次后判定为 healthy，连续失败 FailureThreshold 次后判定为 unhealthy，
+// 未达到任一阈值前保持上一次的判定（初始为 unknown）。
+type ProbeSpec struct {
+	Name string `json:"name"`
+	Type string `json:"type"`
+
+	// http：请求地址与期望状态码，ExpectStatus 为 0 时接受 2xx 和 3xx
+	URL          string `json:"url,omitempty"`
+	ExpectStatus int    `json:"expect_status,omitempty"`
+	// tcp：host:port
+	Address string `json:"address,omitempty"`
+	// exec：在容器内执行命令，退出码为 0 视为成功
+	Container string   `json:"container,omitempty"`
+	Command   []string `json:"command,omitempty"`
+	// file：路径存在即成功
+	Path string `json:"path,omitempty"`
+	// process：进程名（comm）完全匹配或命令行包含该字符串
+	Process string `json:"process,omitempty"`
+
+	IntervalSeconds  int `json:"interval_seconds,omitempty"`
+	TimeoutSeconds   int `json:"timeout_seconds,omitempty"`
+	SuccessThreshold int `json:"success_threshold,omitempty"`
+	FailureThreshold int `json:"failure_threshold,omitempty"`
+}
+
+// ProbeStatus 探针当前状态，随状态报告上报
+type ProbeStatus struct {
+	Name                 string    `json:"name"`
+	Type                 string    `json:"type"`
+	Target               string    `json:"target"`
+	State                string    `json:"state"`
+	ConsecutiveSuccesses int       `json:"consecutive_successes"`
+	ConsecutiveFailures  int       `json:"consecutive_failures"`
+	LastSuccess          bool      `json:"last_success"`
+	Message              string    `json:"message,omitempty"`
+	LatencyMs            float64   `json:"latency_ms"`
+	LastCheck            time.Time `json:"last_check"`
+	LastTransition       time.Time `json:"last_transition"`
+}
+
+// withDefaults 返回填充默认值后的探针定义
+func (s ProbeSpec) withDefaults() ProbeSpec {
+	if s.IntervalSeconds <= 0 {
+		s.IntervalSeconds = 10
+	}
+	if s.TimeoutSeconds <= 0 {
+		s.TimeoutSeconds = 3
+	}
+	if s.SuccessThreshold <= 0 {
+		s.SuccessThreshold = 1
+	}
+	if s.FailureThreshold <= 0 {
+		s.FailureThreshold = 3
+	}
+	return s
+}
+
+// Validate 校验探针定义
+func (s ProbeSpec) Validate() error {
+	if s.Name == "" {
+		return fmt.Errorf("probe name is required")
+	}
+
+	var missing string
+	switch s.Type {
+	case ProbeHTTP:
+		if s.URL == "" {
+			missing = "url"
+		}
+	case ProbeTCP:
+		if s.Address == "" {
+			missing = "address"
+		}
+	case ProbeExec:
+		if s.Container == "" {
+			missing = "container"
+		} else if len(s.Command) == 0 {
+			missing = "command"
+		}
+	case ProbeFile:
+		if s.Path == "" {
+			missing = "path"
+		}
+	case ProbeProcess:
+		if s.Process == "" {
+			missing = "process"
+		}
+	default:
+		return fmt.Errorf("probe %s: unsupported type %q", s.Name, s.Type)
+	}
+	if missing != "" {
+		return fmt.Errorf("probe %s: %s is required for %s probes", s.Name, missing, s.Type)
+	}
+	return nil
+}
+
+// target 返回探针检查对象的简短描述
+func (s ProbeSpec) target() string {
+	switch s.Type {
+	case ProbeHTTP:
+		return s.URL
+	case ProbeTCP:
+		return s.Address
+	case ProbeExec:
+		return s.Container + ": " + strings.Join(s.Command, " ")
+	case ProbeFile:
+		return s.Path
+	case ProbeProcess:
+		return s.Process
+	}
+	return ""
+}
+
+// probeRunner 单个探针的运行状态
+type probeRunner struct {
+	spec   ProbeSpec
+	cancel context.CancelFunc
+	mu     sync.Mutex
+	status ProbeStatus
+}
+
+// ValidateProbes 校验一组探针定义，名称不可重复
+func ValidateProbes(specs []ProbeSpec) error {
+	names := make(map[string]bool, len(specs))
+	for _, spec := range specs {
+		if err := spec.Validate(); err != nil {
+			return err
+		}
+		if names[spec.Name] {
+			return fmt.Errorf("duplicate probe name: %s", spec.Name)
+		}
+		names[spec.Name] = true
+	}
+	return nil
+}
+
+// SetProbes 替换正在运行的健康探针，传入空列表时停止所有探针
+// 定义未变化的探针继续运行并保留状态，避免重复下发相同配置时状态被重置
+func (m *Monitor) SetProbes(specs []ProbeSpec) error {
+	if err := ValidateProbes(specs); err != nil {
+		return err
+	}
+
+	m.probeMu.Lock()
+	defer m.probeMu.Unlock()
+
+	existing := make(map[string]*probeRunner, len(m.probes))
+	for _, runner := range m.probes {
+		existing[runner.spec.Name] = runner
+	}
+
+	probes := make([]*probeRunner, 0, len(specs))
+	started := 0
+	for _, spec := range specs {
+		spec = spec.withDefaults()
+		if runner, ok := existing[spec.Name]; ok && reflect.DeepEqual(runner.spec, spec) {
+			delete(existing, spec.Name)
+			probes = append(probes, runner)
+			continue
+		}
+
+		ctx, cancel := context.WithCancel(m.ctx)
+		runner := &probeRunner{
+			spec:   spec,
+			cancel: cancel,
+			status: ProbeStatus{
+				Name:   spec.Name,
+				Type:   spec.Type,
+				Target: spec.target(),
+				State:  ProbeStateUnknown,
+			},
+		}
+		probes = append(probes, runner)
+		go m.runProbe(ctx, runner)
+		started++
+	}
+
+	// 停止已删除或定义发生变化的探针
+	for _, runner := range existing {
+		runner.cancel()
+	}
+	m.probes = probes
+
+	if started > 0 || len(existing) > 0 {
+		zap.L().Sugar().Infof("Health probes updated: %d running, %d started, %d stopped", len(probes), started, len(existing))
+	}
+	return nil
+}
+
+// GetProbeStatuses 获取所有探针的当前状态，顺序与定义一致
+func (m *Monitor) GetProbeStatuses() []ProbeStatus {
+	m.probeMu.Lock()
+	probes := m.probes
+	m.probeMu.Unlock()
+
+	statuses := make([]ProbeStatus, 0, len(probes))
+	for _, runner := range probes {
+		runner.mu.Lock()
+		statuses = append(statuses, runner.status)
+		runner.mu.Unlock()
+	}
+	return statuses
+}
+
+// runProbe 按间隔执行探针直到 ctx 取消
+func (m *Monitor) runProbe(ctx context.Context, runner *probeRunner) {
+	ticker := time.NewTicker(time.Duration(runner.spec.IntervalSeconds) * time.Second)
+	defer ticker.Stop()
+
+	for {
+		m.checkProbe(ctx, runner)
+
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// checkProbe 执行一次检查并根据阈值更新探针状态
+func (m *Monitor) checkProbe(ctx context.Context, runner *probeRunner) {
+	spec := runner.spec
+	checkCtx, cancel := context.WithTimeout(ctx, time.Duration(spec.TimeoutSeconds)*time.Second)
+	defer cancel()
+
+	start := time.Now()
+	message, err := m.executeProbe(checkCtx, spec)
+	latency := time.Since(start)
+
+	// 探针被替换或停止时不记录本次结果
+	if ctx.Err() != nil {
+		return
+	}
+
+	runner.mu.Lock()
+	defer runner.mu.Unlock()
+
+	status := &runner.status
+	status.LastCheck = start
+	status.LatencyMs = float64(latency.Microseconds()) / 1000
+	status.LastSuccess = err == nil
+
+	newState := status.State
+	if err == nil {
+		status.ConsecutiveSuccesses++
+		status.ConsecutiveFailures = 0
+		status.Message = message
+		if status.ConsecutiveSuccesses >= spec.SuccessThreshold {
+			newState = ProbeStateHealthy
+		}
+	} else {
+		status.ConsecutiveFailures++
+		status.ConsecutiveSuccesses = 0
+		status.Message = err.Error()
+		if status.ConsecutiveFailures >= spec.FailureThreshold {
+			newState = ProbeStateUnhealthy
+		}
+	}
+
+	if newState != status.State {
+		zap.L().Sugar().Infof("Probe %s changed state: %s -> %s (%s)", spec.Name, status.State, newState, status.Message)
+		status.State = newState
+		status.LastTransition = start
+	}
+}
+
+// executeProbe 执行探针检查，成功时返回简短说明
+func (m *Monitor) executeProbe(ctx context.Context, spec ProbeSpec) (string, error) {
+	switch spec.Type {
+	case ProbeHTTP:
+		return probeHTTP(ctx, spec)
+	case ProbeTCP:
+		return probeTCP(ctx, spec)
+	case ProbeExec:
+		return m.probeExec(ctx, spec)
+	case ProbeFile:
+		return probeFile(spec)
+	case ProbeProcess:
+		return m.probeProcess(spec)
+	}
+	return "", fmt.Errorf("unsupported probe type: %s", spec.Type)
+}
+
+// probeHTTP 发送 HTTP GET 请求并检查状态码
+func probeHTTP(ctx context.Context, spec ProbeSpec) (string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, spec.URL, nil)
+	if err != nil {
+		return "", fmt.Errorf("invalid request: %w", err)
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return "", err
+	}
+	resp.Body.Close()
+
+	if spec.ExpectStatus != 0 {
+		if resp.StatusCode != spec.ExpectStatus {
+			return "", fmt.Errorf("unexpected status %d, expected %d", resp.StatusCode, spec.ExpectStatus)
+		}
+	} else if resp.StatusCode < 200 || resp.StatusCode >= 400 {
+		return "", fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+
+	return fmt.Sprintf("HTTP %d", resp.StatusCode), nil
+}
+
+// probeTCP 建立 TCP 连接
+func probeTCP(ctx context.Context, spec ProbeSpec) (string, error) {
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "tcp", spec.Address)
+	if err != nil {
+		return "", err
+	}
+	conn.Close()
+	return "connected", nil
+}
+
+// probeExec 在容器内执行命令
+func (m *Monitor) probeExec(ctx context.Context, spec ProbeSpec) (string, error) {
+	if m.docker == nil {
+		return "", fmt.Errorf("docker client not available")
+	}
+
+	result, err := m.docker.ExecCommand(ctx, spec.Container, spec.Command)
+	if err != nil {
+		return "", err
+	}
+	if result.ExitCode != 0 {
+		output := strings.TrimSpace(result.Stderr)
+		if output == "" {
+			output = strings.TrimSpace(result.Stdout)
+		}
+		return "", fmt.Errorf("exit code %d: %s", result.ExitCode, truncateMessage(output))
+	}
+	return "exit code 0", nil
+}
+
+// probeFile 检查文件是否存在
+func probeFile(spec ProbeSpec) (string, error) {
+	if _, err := os.Stat(spec.Path); err != nil {
+		return "", err
+	}
+	return "exists", nil
+}
+
+// probeProcess 检查是否存在匹配的进程
+func (m *Monitor) probeProcess(spec ProbeSpec) (string, error) {
+	entries, err := os.ReadDir(m.procRoot)
+	if err != nil {
+		return "", fmt.Errorf("failed to list processes: %w", err)
+	}
+
+	for _, entry := range entries {
+		if _, err := strconv.Atoi(entry.Name()); err != nil {
+			continue
+		}
+		stat, err := readProcPIDStat(procPath(m.procRoot, entry.Name(), "stat"))
+		if err != nil {
+			continue
+		}
+		if stat.Comm == spec.Process ||
+			strings.Contains(readProcPIDCmdline(procPath(m.procRoot, entry.Name(), "cmdline")), spec.Process) {
+			return fmt.Sprintf("pid %d", stat.PID), nil
+		}
+	}
+
+	return "", fmt.Errorf("no process matching %q", spec.Process)
+}
+
+// truncateMessage 截断过长的探针输出，避免状态报告膨胀
+func truncateMessage(s string) string {
+	const maxLen = 256
+	if len(s) > maxLen {
+		return s[:maxLen] + "..."
+	}
+	return s
+}
@@ -0,0 +1,365 @@
+package monitor
+
+import (
+	"fmt"
+	"os"
+	"os/user"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// defaultTopLimit 默认返回的进程数
+	defaultTopLimit = 10
+	// maxTopLimit 单次查询允许返回的最大进程数
+	maxTopLimit = 200
+	// processSampleInterval 计算进程 CPU 使用率的采样间隔
+	processSampleInterval = 500 * time.Millisecond
+	// userHZ 内核向用户态导出的时钟频率，Linux 各主流架构均为 100
+	userHZ = 100
+)
+
+// containerIDPattern 匹配 cgroup 路径中的 64 位容器 ID（cgroupfs 与 systemd 驱动均适用）
+var containerIDPattern = regexp.MustCompile(`[0-9a-f]{64}`)
+
+// ProcessInfo 单个进程的资源占用
+type ProcessInfo struct {
+	PID           int     `json:"pid"`
+	PPID          int     `json:"ppid"`
+	Name          string  `json:"name"`
+	Cmdline       string  `json:"cmdline"`
+	State         string  `json:"state"`
+	User          string  `json:"user"`
+	UID           int     `json:"uid"`
+	Cgroup        string  `json:"cgroup,omitempty"`
+	ContainerID   string  `json:"container_id,omitempty"`
+	CPUPercent    float64 `json:"cpu_percent"`
+	MemoryRSS     uint64  `json:"memory_rss"`
+	MemoryPercent float64 `json:"memory_percent"`
+	Threads       int     `json:"threads"`
+	OpenFDs       int     `json:"open_fds"` // 无权限读取时为 -1
+	StartTime     int64   `json:"start_time"`
+}
+
+// TopProcesses 进程排行查询结果
+type TopProcesses struct {
+	SortBy     string        `json:"sort_by"`
+	Limit      int           `json:"limit"`
+	Total      int           `json:"total"`
+	Processes  []ProcessInfo `json:"processes"`
+	SampledFor float64       `json:"sampled_for"` // CPU 采样时长，单位秒
+	Timestamp  int64         `json:"timestamp"`
+}
+
+// procPIDStat /proc/[pid]/stat 中用到的字段
+type procPIDStat struct {
+	PID        int
+	Comm       string
+	State      string
+	PPID       int
+	CPUTicks   uint64 // utime + stime
+	Threads    int
+	StartTicks uint64 // 进程启动时距开机的 jiffies
+}
+
+// readProcPIDStat 解析 /proc/[pid]/stat
+func readProcPIDStat(path string) (*procPIDStat, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	// comm 可能包含空格和括号，以最后一个 ')' 为界
+	line := string(data)
+	open := strings.IndexByte(line, '(')
+	end := strings.LastIndexByte(line, ')')
+	if open < 0 || end < open {
+		return nil, fmt.Errorf("unexpected format in %s", path)
+	}
+
+	pid, err := strconv.Atoi(strings.TrimSpace(line[:open]))
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse pid in %s: %w", path, err)
+	}
+
+	// fields[0] 为 state（总第 3 列），以下索引均相对于它
+	fields := strings.Fields(line[end+1:])
+	if len(fields) < 20 {
+		return nil, fmt.Errorf("unexpected format in %s", path)
+	}
+
+	stat := &procPIDStat{
+		PID:   pid,
+		Comm:  line[open+1 : end],
+		State: fields[0],
+	}
+	stat.PPID, _ = strconv.Atoi(fields[1])
+	utime, _ := strconv.ParseUint(fields[11], 10, 64)
+	stime, _ := strconv.ParseUint(fields[12], 10, 64)
+	stat.CPUTicks = utime + stime
+	stat.Threads, _ = strconv.Atoi(fields[17])
+	stat.StartTicks, _ = strconv.ParseUint(fields[19], 10, 64)
+
+	return stat, nil
+}
+
+// readProcPIDUID 从 /proc/[pid]/status 读取真实 UID
+func readProcPIDUID(path string) (int, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return -1, err
+	}
+
+	for _, line := range strings.Split(string(data), "\n") {
+		rest, ok := strings.CutPrefix(line, "Uid:")
+		if !ok {
+			continue
+		}
+		fields := strings.Fields(rest)
+		if len(fields) == 0 {
+			break
+		}
+		return strconv.Atoi(fields[0])
+	}
+
+	return -1, fmt.Errorf("Uid not found in %s", path)
+}
+
+// readProcPIDRSS 从 /proc/[pid]/statm 读取常驻内存，单位为字节
+func readProcPIDRSS(path string) (uint64, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return 0, err
+	}
+
+	fields := strings.Fields(string(data))
+	if len(fields) < 2 {
+		return 0, fmt.Errorf("unexpected format in %s", path)
+	}
+
+	pages, err := strconv.ParseUint(fields[1], 10, 64)
+	if err != nil {
+		return 0, err
+	}
+	return pages * uint64(os.Getpagesize()), nil
+}
+
+// readProcPIDCmdline 读取 /proc/[pid]/cmdline，参数以空格连接
+func readProcPIDCmdline(path string) string {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(strings.ReplaceAll(string(data), "\x00", " "))
+}
+
+// readProcPIDCgroup 读取 /proc/[pid]/cgroup，返回 cgroup 路径及其中的容器 ID
+func readProcPIDCgroup(path string) (string, string) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", ""
+	}
+
+	var cgroup string
+	for _, line := range strings.Split(strings.TrimSpace(string(data)), "\n") {
+		// 格式为 hierarchy-ID:controllers:path，cgroup v2 只有一行 "0::/path"
+		parts := strings.SplitN(line, ":", 3)
+		if len(parts) != 3 {
+			continue
+		}
+		if cgroup == "" || parts[0] == "0" {
+			cgroup = parts[2]
+		}
+		if id := containerIDPattern.FindString(parts[2]); id != "" {
+			return parts[2], id
+		}
+	}
+
+	return cgroup, ""
+}
+
+// countOpenFDs 统计 /proc/[pid]/fd 中的文件描述符数量
+func countOpenFDs(path string) int {
+	entries, err := os.ReadDir(path)
+	if err != nil {
+		return -1
+	}
+	return len(entries)
+}
+
+// sampleProcessTicks 采样所有进程的 CPU 时间片
+func (m *Monitor) sampleProcessTicks() (map[int]uint64, error) {
+	entries, err := os.ReadDir(m.procRoot)
+	if err != nil {
+		return nil, err
+	}
+
+	ticks := make(map[int]uint64, len(entries))
+	for _, entry := range entries {
+		pid, err := strconv.Atoi(entry.Name())
+		if err != nil {
+			continue
+		}
+		// 进程可能在遍历期间退出，忽略读取失败
+		stat, err := readProcPIDStat(procPath(m.procRoot, entry.Name(), "stat"))
+		if err != nil {
+			continue
+		}
+		ticks[pid] = stat.CPUTicks
+	}
+
+	return ticks, nil
+}
+
+// GetTopProcesses 获取按 CPU 或内存排序的前 limit 个进程
+// CPU 使用率按采样间隔内占全部时间片的比例计算，并乘以核数，与 top 的单核 100% 口径一致
+func (m *Monitor) GetTopProcesses(sortBy string, limit int) (*TopProcesses, error) {
+	switch sortBy {
+	case "":
+		sortBy = "cpu"
+	case "cpu", "memory":
+	default:
+		return nil, fmt.Errorf("unsupported sort key: %s", sortBy)
+	}
+	if limit <= 0 {
+		limit = defaultTopLimit
+	}
+	if limit > maxTopLimit {
+		limit = maxTopLimit
+	}
+
+	statPath := procPath(m.procRoot, "stat")
+	before, err := readProcStat(statPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", statPath, err)
+	}
+	prevTicks, err := m.sampleProcessTicks()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list processes: %w", err)
+	}
+
+	start := time.Now()
+	select {
+	case <-time.After(processSampleInterval):
+	case <-m.ctx.Done():
+		return nil, m.ctx.Err()
+	}
+
+	after, err := readProcStat(statPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", statPath, err)
+	}
+	mem, err := readMemInfo(procPath(m.procRoot, "meminfo"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read meminfo: %w", err)
+	}
+
+	totalDelta := float64(after.Total.total() - before.Total.total())
+	numCores := len(after.Cores)
+	if numCores == 0 {
+		numCores = 1
+	}
+
+	entries, err := os.ReadDir(m.procRoot)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list processes: %w", err)
+	}
+
+	processes := make([]ProcessInfo, 0, len(entries))
+	for _, entry := range entries {
+		pid, err := strconv.Atoi(entry.Name())
+		if err != nil {
+			continue
+		}
+		stat, err := readProcPIDStat(procPath(m.procRoot, entry.Name(), "stat"))
+		if err != nil {
+			continue
+		}
+
+		proc := ProcessInfo{
+			PID:     pid,
+			PPID:    stat.PPID,
+			Name:    stat.Comm,
+			State:   stat.State,
+			Threads: stat.Threads,
+		}
+
+		// 采样期间新启动的进程以 0 为基准
+		if totalDelta > 0 && stat.CPUTicks >= prevTicks[pid] {
+			proc.CPUPercent = float64(stat.CPUTicks-prevTicks[pid]) / totalDelta * 100 * float64(numCores)
+		}
+		if after.BootTime > 0 {
+			proc.StartTime = int64(after.BootTime) + int64(stat.StartTicks/userHZ)
+		}
+
+		if rss, err := readProcPIDRSS(procPath(m.procRoot, entry.Name(), "statm")); err == nil {
+			proc.MemoryRSS = rss
+			if mem.MemTotal > 0 {
+				proc.MemoryPercent = float64(rss) / float64(mem.MemTotal) * 100
+			}
+		}
+
+		processes = append(processes, proc)
+	}
+
+	sort.Slice(processes, func(i, j int) bool {
+		if sortBy == "memory" {
+			return processes[i].MemoryRSS > processes[j].MemoryRSS
+		}
+		if processes[i].CPUPercent != processes[j].CPUPercent {
+			return processes[i].CPUPercent > processes[j].CPUPercent
+		}
+		return processes[i].MemoryRSS > processes[j].MemoryRSS
+	})
+
+	total := len(processes)
+	if len(processes) > limit {
+		processes = processes[:limit]
+	}
+
+	// 只为最终返回的进程读取开销较大的详细信息
+	for i := range processes {
+		pidDir := strconv.Itoa(processes[i].PID)
+		processes[i].Cmdline = readProcPIDCmdline(procPath(m.procRoot, pidDir, "cmdline"))
+		processes[i].Cgroup, processes[i].ContainerID = readProcPIDCgroup(procPath(m.procRoot, pidDir, "cgroup"))
+		processes[i].OpenFDs = countOpenFDs(procPath(m.procRoot, pidDir, "fd"))
+		processes[i].UID, _ = readProcPIDUID(procPath(m.procRoot, pidDir, "status"))
+		processes[i].User = m.lookupUser(processes[i].UID)
+	}
+
+	return &TopProcesses{
+		SortBy:     sortBy,
+		Limit:      limit,
+		Total:      total,
+		Processes:  processes,
+		SampledFor: time.Since(start).Seconds(),
+		Timestamp:  time.Now().Unix(),
+	}, nil
+}
+
+// lookupUser 将 UID 解析为用户名并缓存，解析失败时返回 UID 字符串
+func (m *Monitor) lookupUser(uid int) string {
+	if uid < 0 {
+		return ""
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.userNames == nil {
+		m.userNames = make(map[int]string)
+	}
+	if name, ok := m.userNames[uid]; ok {
+		return name
+	}
+
+	name := strconv.Itoa(uid)
+	if u, err := user.LookupId(name); err == nil {
+		name = u.Username
+	}
+	m.userNames[uid] = name
+	return name
+}
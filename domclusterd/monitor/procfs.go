@@ -0,0 +1,380 @@
+package monitor
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// 以下读取函数只依赖传入的文件路径，便于用夹具文件（fixture）替换 /proc 进行测试
+
+// cpuTimes 单个 CPU（或汇总行）的时间片计数，单位为 jiffies
+type cpuTimes struct {
+	User    uint64
+	Nice    uint64
+	System  uint64
+	Idle    uint64
+	IOWait  uint64
+	IRQ     uint64
+	SoftIRQ uint64
+	Steal   uint64
+}
+
+// total 返回全部时间片之和
+func (t cpuTimes) total() uint64 {
+	return t.User + t.Nice + t.System + t.Idle + t.IOWait + t.IRQ + t.SoftIRQ + t.Steal
+}
+
+// idle 返回空闲时间片（含 iowait）
+func (t cpuTimes) idle() uint64 {
+	return t.Idle + t.IOWait
+}
+
+// procStat /proc/stat 解析结果
+type procStat struct {
+	Total           cpuTimes
+	Cores           []cpuTimes
+	ContextSwitches uint64
+	ProcsRunning    uint64
+	ProcsBlocked    uint64
+	BootTime        uint64
+}
+
+// memInfo /proc/meminfo 解析结果，单位为字节
+type memInfo struct {
+	MemTotal     uint64
+	MemFree      uint64
+	MemAvailable uint64
+	Buffers      uint64
+	Cached       uint64
+	SwapTotal    uint64
+	SwapFree     uint64
+}
+
+// netDevStat /proc/net/dev 中单个网卡的计数
+type netDevStat struct {
+	Name      string
+	RxBytes   uint64
+	RxPackets uint64
+	RxErrors  uint64
+	RxDropped uint64
+	TxBytes   uint64
+	TxPackets uint64
+	TxErrors  uint64
+	TxDropped uint64
+}
+
+// readProcStat 解析 /proc/stat
+func readProcStat(path string) (*procStat, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	stat := &procStat{}
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 2 {
+			continue
+		}
+
+		switch {
+		case fields[0] == "cpu":
+			stat.Total = parseCPUTimes(fields[1:])
+		case strings.HasPrefix(fields[0], "cpu"):
+			stat.Cores = append(stat.Cores, parseCPUTimes(fields[1:]))
+		case fields[0] == "ctxt":
+			stat.ContextSwitches, _ = strconv.ParseUint(fields[1], 10, 64)
+		case fields[0] == "procs_running":
+			stat.ProcsRunning, _ = strconv.ParseUint(fields[1], 10, 64)
+		case fields[0] == "procs_blocked":
+			stat.ProcsBlocked, _ = strconv.ParseUint(fields[1], 10, 64)
+		case fields[0] == "btime":
+			stat.BootTime, _ = strconv.ParseUint(fields[1], 10, 64)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return stat, nil
+}
+
+// parseCPUTimes 解析 cpu 行中的时间片字段，缺失的字段记为 0
+func parseCPUTimes(fields []string) cpuTimes {
+	values := make([]uint64, 8)
+	for i := 0; i < len(values) && i < len(fields); i++ {
+		values[i], _ = strconv.ParseUint(fields[i], 10, 64)
+	}
+	return cpuTimes{
+		User:    values[0],
+		Nice:    values[1],
+		System:  values[2],
+		Idle:    values[3],
+		IOWait:  values[4],
+		IRQ:     values[5],
+		SoftIRQ: values[6],
+		Steal:   values[7],
+	}
+}
+
+// readMemInfo 解析 /proc/meminfo
+func readMemInfo(path string) (*memInfo, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	values := make(map[string]uint64)
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		key, rest, ok := strings.Cut(scanner.Text(), ":")
+		if !ok {
+			continue
+		}
+		fields := strings.Fields(rest)
+		if len(fields) == 0 {
+			continue
+		}
+		v, err := strconv.ParseUint(fields[0], 10, 64)
+		if err != nil {
+			continue
+		}
+		// meminfo 中带 kB 单位的值统一换算为字节
+		if len(fields) > 1 && fields[1] == "kB" {
+			v *= 1024
+		}
+		values[key] = v
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	if _, ok := values["MemTotal"]; !ok {
+		return nil, fmt.Errorf("MemTotal not found in %s", path)
+	}
+
+	info := &memInfo{
+		MemTotal:  values["MemTotal"],
+		MemFree:   values["MemFree"],
+		Buffers:   values["Buffers"],
+		Cached:    values["Cached"],
+		SwapTotal: values["SwapTotal"],
+		SwapFree:  values["SwapFree"],
+	}
+
+	// 旧内核（< 3.14）没有 MemAvailable，使用 free + buffers + cached 近似
+	if available, ok := values["MemAvailable"]; ok {
+		info.MemAvailable = available
+	} else {
+		info.MemAvailable = info.MemFree + info.Buffers + info.Cached
+	}
+
+	return info, nil
+}
+
+// readLoadAvg 解析 /proc/loadavg
+func readLoadAvg(path string) (*LoadAverage, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	fields := strings.Fields(string(data))
+	if len(fields) < 4 {
+		return nil, fmt.Errorf("unexpected format in %s", path)
+	}
+
+	load := &LoadAverage{}
+	if load.Load1, err = strconv.ParseFloat(fields[0], 64); err != nil {
+		return nil, fmt.Errorf("failed to parse load1: %w", err)
+	}
+	if load.Load5, err = strconv.ParseFloat(fields[1], 64); err != nil {
+		return nil, fmt.Errorf("failed to parse load5: %w", err)
+	}
+	if load.Load15, err = strconv.ParseFloat(fields[2], 64); err != nil {
+		return nil, fmt.Errorf("failed to parse load15: %w", err)
+	}
+
+	// 第四列形如 "running/total"
+	if running, total, ok := strings.Cut(fields[3], "/"); ok {
+		load.RunningProcs, _ = strconv.Atoi(running)
+		load.TotalProcs, _ = strconv.Atoi(total)
+	}
+
+	return load, nil
+}
+
+// readUptime 解析 /proc/uptime，返回系统运行秒数
+func readUptime(path string) (float64, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return 0, err
+	}
+
+	fields := strings.Fields(string(data))
+	if len(fields) < 1 {
+		return 0, fmt.Errorf("unexpected format in %s", path)
+	}
+
+	return strconv.ParseFloat(fields[0], 64)
+}
+
+// readNetDev 解析 /proc/net/dev
+func readNetDev(path string) ([]netDevStat, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var stats []netDevStat
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		// 前两行是表头，数据行形如 "  eth0: 123 456 ..."
+		name, rest, ok := strings.Cut(scanner.Text(), ":")
+		if !ok {
+			continue
+		}
+		fields := strings.Fields(rest)
+		if len(fields) < 16 {
+			continue
+		}
+
+		values := make([]uint64, 16)
+		for i := range values {
+			values[i], _ = strconv.ParseUint(fields[i], 10, 64)
+		}
+
+		stats = append(stats, netDevStat{
+			Name:      strings.TrimSpace(name),
+			RxBytes:   values[0],
+			RxPackets: values[1],
+			RxErrors:  values[2],
+			RxDropped: values[3],
+			TxBytes:   values[8],
+			TxPackets: values[9],
+			TxErrors:  values[10],
+			TxDropped: values[11],
+		})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return stats, nil
+}
+
+// procPath 拼接 proc 根目录下的路径
+func procPath(root string, elem ...string) string {
+	return filepath.Join(append([]string{root}, elem...)...)
+}
+
+// mountEntry /proc/self/mounts 中的一条挂载记录
+type mountEntry struct {
+	Device     string
+	MountPoint string
+	FSType     string
+}
+
+// diskStat /proc/diskstats 中单个块设备的计数
+type diskStat struct {
+	Name            string
+	ReadsCompleted  uint64
+	SectorsRead     uint64
+	WritesCompleted uint64
+	SectorsWritten  uint64
+	IOTicks         uint64 // 设备处于忙碌状态的毫秒数
+}
+
+// readMounts 解析 /proc/self/mounts
+func readMounts(path string) ([]mountEntry, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var mounts []mountEntry
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 3 {
+			continue
+		}
+		mounts = append(mounts, mountEntry{
+			Device:     unescapeMountField(fields[0]),
+			MountPoint: unescapeMountField(fields[1]),
+			FSType:     fields[2],
+		})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return mounts, nil
+}
+
+// unescapeMountField 还原挂载记录中的八进制转义（如空格被写作 \040）
+func unescapeMountField(s string) string {
+	if !strings.Contains(s, `\`) {
+		return s
+	}
+
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\\' && i+3 < len(s) {
+			if v, err := strconv.ParseUint(s[i+1:i+4], 8, 8); err == nil {
+				b.WriteByte(byte(v))
+				i += 3
+				continue
+			}
+		}
+		b.WriteByte(s[i])
+	}
+	return b.String()
+}
+
+// readDiskStats 解析 /proc/diskstats
+func readDiskStats(path string) ([]diskStat, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var stats []diskStat
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		// major minor name reads merged sectors ms writes merged sectors ms inflight io_ticks ...
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 13 {
+			continue
+		}
+
+		values := make([]uint64, 10)
+		for i := range values {
+			values[i], _ = strconv.ParseUint(fields[i+3], 10, 64)
+		}
+
+		stats = append(stats, diskStat{
+			Name:            fields[2],
+			ReadsCompleted:  values[0],
+			SectorsRead:     values[2],
+			WritesCompleted: values[4],
+			SectorsWritten:  values[6],
+			IOTicks:         values[9],
+		})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return stats, nil
+}
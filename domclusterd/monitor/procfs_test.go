@@ -0,0 +1,241 @@
+package monitor
+
+import (
+	"math"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// fixture 返回 testdata 中夹具文件的路径
+func fixture(name string) string {
+	return filepath.Join("testdata", name)
+}
+
+func TestReadProcStat(t *testing.T) {
+	stat, err := readProcStat(fixture("stat"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := cpuTimes{User: 1000, Nice: 50, System: 500, Idle: 8000, IOWait: 200, IRQ: 10, SoftIRQ: 40}
+	if stat.Total != want {
+		t.Errorf("Total = %+v, want %+v", stat.Total, want)
+	}
+	if len(stat.Cores) != 2 {
+		t.Fatalf("got %d cores, want 2", len(stat.Cores))
+	}
+	if stat.Cores[1].Idle != 4100 {
+		t.Errorf("cpu1 idle = %d, want 4100", stat.Cores[1].Idle)
+	}
+	if stat.ContextSwitches != 987654 || stat.ProcsRunning != 3 || stat.ProcsBlocked != 1 || stat.BootTime != 1700000000 {
+		t.Errorf("unexpected counters: %+v", stat)
+	}
+}
+
+func TestParseCPUTimesShortLine(t *testing.T) {
+	// 旧内核的 cpu 行只有前四个字段
+	got := parseCPUTimes([]string{"1", "2", "3", "4"})
+	want := cpuTimes{User: 1, Nice: 2, System: 3, Idle: 4}
+	if got != want {
+		t.Errorf("parseCPUTimes = %+v, want %+v", got, want)
+	}
+}
+
+func TestCPUPercent(t *testing.T) {
+	prev, err := readProcStat(fixture("stat"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	cur, err := readProcStat(fixture("stat_next"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		got  float64
+		want float64
+	}{
+		// 总时间片增加 950，其中空闲（含 iowait）550
+		{"total usage", cpuUsagePercent(prev.Total, cur.Total), 400.0 / 950 * 100},
+		{"total iowait", cpuIOWaitPercent(prev.Total, cur.Total), 50.0 / 950 * 100},
+		{"busy core", cpuUsagePercent(prev.Cores[0], cur.Cores[0]), 100},
+		{"idle core", cpuUsagePercent(prev.Cores[1], cur.Cores[1]), 0},
+		// 计数器回绕或采样顺序颠倒时不产生负值
+		{"counter reset", cpuUsagePercent(cur.Total, prev.Total), 0},
+		{"no elapsed time", cpuIOWaitPercent(prev.Total, prev.Total), 0},
+	}
+	for _, tt := range tests {
+		if math.Abs(tt.got-tt.want) > 1e-9 {
+			t.Errorf("%s = %v, want %v", tt.name, tt.got, tt.want)
+		}
+	}
+}
+
+func TestCPUSampler(t *testing.T) {
+	s := newCPUSampler(fixture("stat"))
+	start := time.Now()
+	if err := s.sample(start); err != nil {
+		t.Fatal(err)
+	}
+	// 只有一次采样时没有基准，不上报使用率
+	if info := s.current(); info != nil {
+		t.Fatalf("current after first sample = %+v, want nil", info)
+	}
+
+	s.path = fixture("stat_next")
+	if err := s.sample(start.Add(2 * time.Second)); err != nil {
+		t.Fatal(err)
+	}
+	info := s.current()
+	if info == nil {
+		t.Fatal("current after second sample = nil")
+	}
+	if want := 400.0 / 950 * 100; math.Abs(info.UsagePercent-want) > 1e-9 {
+		t.Errorf("UsagePercent = %v, want %v", info.UsagePercent, want)
+	}
+	if info.CoreCount != 2 || len(info.Cores) != 2 || info.Cores[0].UsagePercent != 100 {
+		t.Errorf("unexpected cores: %+v", info.Cores)
+	}
+
+	// 读取不影响采样窗口，多个读取者看到相同的结果
+	again := s.current()
+	if again.UsagePercent != info.UsagePercent || again.ContextSwitchRate != info.ContextSwitchRate {
+		t.Errorf("second reader got %+v, want %+v", again, info)
+	}
+	again.Cores[0].UsagePercent = -1
+	if s.current().Cores[0].UsagePercent != 100 {
+		t.Error("current returned shared core slice")
+	}
+}
+
+func TestReadMemInfo(t *testing.T) {
+	tests := []struct {
+		file string
+		want memInfo
+	}{
+		{
+			file: "meminfo",
+			want: memInfo{
+				MemTotal:     16384000 * 1024,
+				MemFree:      2048000 * 1024,
+				MemAvailable: 8192000 * 1024,
+				Buffers:      512000 * 1024,
+				Cached:       4096000 * 1024,
+				SwapTotal:    2048000 * 1024,
+				SwapFree:     1024000 * 1024,
+			},
+		},
+		{
+			// 没有 MemAvailable 时按 free + buffers + cached 估算
+			file: "meminfo_no_available",
+			want: memInfo{
+				MemTotal:     1024000 * 1024,
+				MemFree:      256000 * 1024,
+				MemAvailable: (256000 + 64000 + 128000) * 1024,
+				Buffers:      64000 * 1024,
+				Cached:       128000 * 1024,
+			},
+		},
+	}
+	for _, tt := range tests {
+		got, err := readMemInfo(fixture(tt.file))
+		if err != nil {
+			t.Errorf("%s: %v", tt.file, err)
+			continue
+		}
+		if *got != tt.want {
+			t.Errorf("%s = %+v, want %+v", tt.file, *got, tt.want)
+		}
+	}
+
+	// 缺少 MemTotal 的文件视为格式错误
+	if _, err := readMemInfo(fixture("loadavg")); err == nil {
+		t.Error("expected an error for a file without MemTotal")
+	}
+}
+
+func TestReadLoadAvg(t *testing.T) {
+	got, err := readLoadAvg(fixture("loadavg"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := LoadAverage{Load1: 0.52, Load5: 1.25, Load15: 2.00, RunningProcs: 3, TotalProcs: 512}
+	if *got != want {
+		t.Errorf("readLoadAvg = %+v, want %+v", *got, want)
+	}
+
+	if _, err := readLoadAvg(fixture("mounts")); err == nil {
+		t.Error("expected an error for malformed loadavg")
+	}
+}
+
+func TestReadNetDev(t *testing.T) {
+	got, err := readNetDev(fixture("net_dev"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []netDevStat{
+		{Name: "lo", RxBytes: 10240, RxPackets: 100, TxBytes: 10240, TxPackets: 100},
+		{
+			Name: "eth0", RxBytes: 9876543, RxPackets: 5432, RxErrors: 1, RxDropped: 2,
+			TxBytes: 1234567, TxPackets: 4321, TxErrors: 3, TxDropped: 4,
+		},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("readNetDev = %+v, want %+v", got, want)
+	}
+}
+
+func TestReadDiskStats(t *testing.T) {
+	got, err := readDiskStats(fixture("diskstats"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 字段不足的 loop0 被跳过
+	want := []diskStat{
+		{Name: "sda", ReadsCompleted: 1000, SectorsRead: 80000, WritesCompleted: 2000, SectorsWritten: 160000, IOTicks: 1800},
+		{Name: "sda1", ReadsCompleted: 900, SectorsRead: 72000, WritesCompleted: 1900, SectorsWritten: 152000, IOTicks: 1700},
+		{Name: "nvme0n1", ReadsCompleted: 300, SectorsRead: 24000, WritesCompleted: 400, SectorsWritten: 32000, IOTicks: 250},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("readDiskStats = %+v, want %+v", got, want)
+	}
+}
+
+func TestReadMounts(t *testing.T) {
+	got, err := readMounts(fixture("mounts"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []mountEntry{
+		{Device: "/dev/sda1", MountPoint: "/", FSType: "ext4"},
+		{Device: "proc", MountPoint: "/proc", FSType: "proc"},
+		{Device: "/dev/sdb1", MountPoint: "/mnt/contest data", FSType: "ext4"},
+		{Device: "tmpfs", MountPoint: `/mnt/odd\dir`, FSType: "tmpfs"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("readMounts = %+v, want %+v", got, want)
+	}
+}
+
+func TestUnescapeMountField(t *testing.T) {
+	tests := []struct {
+		in, want string
+	}{
+		{"/plain", "/plain"},
+		{`/a\040b`, "/a b"},
+		{`/tab\011sep`, "/tab\tsep"},
+		{`/back\134slash`, `/back\slash`},
+		// 不完整或非八进制的转义原样保留
+		{`/end\04`, `/end\04`},
+		{`/not\999octal`, `/not\999octal`},
+	}
+	for _, tt := range tests {
+		if got := unescapeMountField(tt.in); got != tt.want {
+			t.Errorf("unescapeMountField(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
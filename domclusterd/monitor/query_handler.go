@@ -4,11 +4,16 @@ import (
 	"context"
 	"domclusterd/connections"
 	"encoding/json"
+	"fmt"
+	"time"
 
 	pb "domcluster/api/proto"
 	"go.uber.org/zap"
 )
 
+// processQueryTimeout 进程排行查询的超时时间，包含 processSampleInterval 的采样等待
+const processQueryTimeout = 10 * time.Second
+
 // QueryHandler 查询处理器
 type QueryHandler struct {
 	monitor *Monitor
@@ -32,6 +37,7 @@ func NewQueryHandler(monitor *Monitor, manager *connections.Manager) *QueryHandl
 func (qh *QueryHandler) Register() {
 	qh.manager.RegisterHandler("status_query", qh.handleStatusQuery)
 	qh.manager.RegisterHandler("resource_query", qh.handleResourceQuery)
+	qh.manager.RegisterQueryHandler(qh.ctx, "process_query", processQueryTimeout, qh.processQuery)
 	zap.L().Sugar().Info("Query handlers registered")
 }
 
@@ -97,7 +103,24 @@ func (qh *QueryHandler) handleResourceQuery(resp *pb.PublishResponse) error {
 	return qh.manager.Send("query_response", resp.ReqId, dataBytes)
 }
 
+// processQuery 处理进程排行查询，采样需要等待一段时间，由查询处理器在独立 goroutine 中执行
+func (qh *QueryHandler) processQuery(ctx context.Context, data []byte) (interface{}, error) {
+	var query struct {
+		Sort  string `json:"sort"`
+		Limit int    `json:"limit"`
+	}
+	if err := json.Unmarshal(data, &query); err != nil {
+		return nil, fmt.Errorf("invalid process query: %w", err)
+	}
+
+	top, err := qh.monitor.GetTopProcesses(query.Sort, query.Limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get top processes: %w", err)
+	}
+	return top, nil
+}
+
 // Stop 停止处理器
 func (qh *QueryHandler) Stop() {
 	qh.cancel()
-}
\ No newline at end of file
+}
@@ -5,27 +5,42 @@ import (
 	"domclusterd/connections"
 	"encoding/json"
 	"fmt"
+	"sync"
 	"time"
 
+	pb "domcluster/api/proto"
+	"domcluster/api/statusdelta"
 	"go.uber.org/zap"
 )
 
+// fullSnapshotEvery 每发送多少次报告发送一次完整快照，其余发送增量
+const fullSnapshotEvery = 12
+
 // StatusReporter 状态报告器
 type StatusReporter struct {
 	monitor *Monitor
 	manager *connections.Manager
 	ctx     context.Context
 	cancel  context.CancelFunc
+
+	mu        sync.Mutex
+	seq       uint64                 // 最近一次成功发送的报告序号
+	lastDoc   map[string]interface{} // 最近一次成功发送的完整报告
+	sinceFull int                    // 距上次完整快照已发送的增量数
+	forceFull bool                   // 下一次强制发送完整快照
+
+	intervalCh chan time.Duration
 }
 
 // NewStatusReporter 创建状态报告器
 func NewStatusReporter(monitor *Monitor, manager *connections.Manager) *StatusReporter {
 	ctx, cancel := context.WithCancel(context.Background())
 	return &StatusReporter{
-		monitor: monitor,
-		manager: manager,
-		ctx:     ctx,
-		cancel:  cancel,
+		monitor:    monitor,
+		manager:    manager,
+		ctx:        ctx,
+		cancel:     cancel,
+		intervalCh: make(chan time.Duration, 1),
 	}
 }
 
@@ -34,6 +49,10 @@ func (sr *StatusReporter) Start(interval time.Duration) {
 	ticker := time.NewTicker(interval)
 	defer ticker.Stop()
 
+	// 控制端丢失基准快照时会要求重新发送完整快照
+	sr.manager.RegisterHandler("status_resync", sr.handleResync)
+	defer sr.manager.UnregisterHandler("status_resync")
+
 	zap.L().Sugar().Infof("Starting status reporter with interval: %v", interval)
 
 	for {
@@ -42,6 +61,9 @@ func (sr *StatusReporter) Start(interval time.Duration) {
 			if err := sr.reportStatus(); err != nil {
 				zap.L().Sugar().Errorf("Failed to report status: %v", err)
 			}
+		case interval := <-sr.intervalCh:
+			ticker.Reset(interval)
+			zap.L().Sugar().Infof("Status report interval changed to %v", interval)
 		case <-sr.ctx.Done():
 			zap.L().Sugar().Info("Status reporter stopped")
 			return
@@ -49,23 +71,89 @@ func (sr *StatusReporter) Start(interval time.Duration) {
 	}
 }
 
-// reportStatus 上报状态
+// reportStatus 上报状态，周期性发送完整快照，其余时间只发送变化的字段
 func (sr *StatusReporter) reportStatus() error {
 	report, err := sr.monitor.GetMonitorReport()
 	if err != nil {
 		return fmt.Errorf("failed to get monitor report: %w", err)
 	}
 
-	dataBytes, err := json.Marshal(report)
+	doc, err := toJSONDocument(report)
 	if err != nil {
 		return fmt.Errorf("failed to marshal report: %w", err)
 	}
 
+	sr.mu.Lock()
+	defer sr.mu.Unlock()
+
+	seq := sr.seq + 1
 	reqID := fmt.Sprintf("status-%d", time.Now().UnixNano())
-	return sr.manager.Send("status_update", reqID, dataBytes)
+
+	if sr.lastDoc == nil || sr.forceFull || sr.sinceFull >= fullSnapshotEvery {
+		doc["report_seq"] = seq
+		dataBytes, err := json.Marshal(doc)
+		delete(doc, "report_seq")
+		if err != nil {
+			return fmt.Errorf("failed to marshal report: %w", err)
+		}
+		if err := sr.manager.Send("status_update", reqID, dataBytes); err != nil {
+			// 发送失败时控制端的基准可能已失效，下次仍发送完整快照
+			sr.forceFull = true
+			return err
+		}
+		// 完整快照以数组发送，保存的基准与控制端一样按 id 索引
+		statusdelta.KeyArrays(doc)
+		sr.seq = seq
+		sr.lastDoc = doc
+		sr.sinceFull = 0
+		sr.forceFull = false
+		return nil
+	}
+
+	statusdelta.KeyArrays(doc)
+	delta := map[string]interface{}{
+		"seq":      seq,
+		"base_seq": sr.seq,
+		"patch":    diffDocuments(sr.lastDoc, doc),
+	}
+	dataBytes, err := json.Marshal(delta)
+	if err != nil {
+		return fmt.Errorf("failed to marshal status delta: %w", err)
+	}
+	if err := sr.manager.Send("status_delta", reqID, dataBytes); err != nil {
+		sr.forceFull = true
+		return err
+	}
+	sr.seq = seq
+	sr.lastDoc = doc
+	sr.sinceFull++
+	return nil
+}
+
+// handleResync 处理控制端的重新同步请求
+func (sr *StatusReporter) handleResync(resp *pb.PublishResponse) error {
+	sr.mu.Lock()
+	sr.forceFull = true
+	sr.mu.Unlock()
+
+	zap.L().Sugar().Info("Controller requested full status snapshot")
+	return nil
+}
+
+// SetInterval 修改上报间隔，立即生效
+func (sr *StatusReporter) SetInterval(interval time.Duration) {
+	if interval <= 0 {
+		return
+	}
+	// 只保留最新的设置
+	select {
+	case <-sr.intervalCh:
+	default:
+	}
+	sr.intervalCh <- interval
 }
 
 // Stop 停止报告器
 func (sr *StatusReporter) Stop() {
 	sr.cancel()
-}
\ No newline at end of file
+}
@@ -0,0 +1,118 @@
+package monitor
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// LogicalCPU 一个逻辑 CPU 的拓扑
+type LogicalCPU struct {
+	ID      int `json:"id"`
+	Package int `json:"package"`
+	Core    int `json:"core"`
+	// Siblings 与该 CPU 共享同一物理核心的全部逻辑 CPU（含自身）
+	Siblings []int `json:"siblings"`
+}
+
+// PhysicalCore 一个物理核心，以其编号最小的逻辑 CPU 标识
+type PhysicalCore struct {
+	// CPU 核心中编号最小的逻辑 CPU
+	CPU     int `json:"cpu"`
+	Package int `json:"package"`
+	Core    int `json:"core"`
+	// Threads 核心上的全部逻辑 CPU，超过一个表示开启了 SMT
+	Threads []int `json:"threads"`
+}
+
+// readCPUTopology 读取 /sys/devices/system/cpu 下在线 CPU 的拓扑
+func readCPUTopology(sysRoot string) ([]LogicalCPU, []PhysicalCore, error) {
+	base := filepath.Join(sysRoot, "devices", "system", "cpu")
+	data, err := os.ReadFile(filepath.Join(base, "online"))
+	if err != nil {
+		return nil, nil, err
+	}
+	online, err := parseCPUList(strings.TrimSpace(string(data)))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	cpus := make([]LogicalCPU, 0, len(online))
+	cores := make(map[[2]int]*PhysicalCore)
+	for _, id := range online {
+		dir := filepath.Join(base, "cpu"+strconv.Itoa(id), "topology")
+		pkg, err := readIntFile(filepath.Join(dir, "physical_package_id"))
+		if err != nil {
+			return nil, nil, err
+		}
+		core, err := readIntFile(filepath.Join(dir, "core_id"))
+		if err != nil {
+			return nil, nil, err
+		}
+		// 较新的内核改名为 core_cpus_list，旧名称仍然保留
+		siblings := []int{id}
+		if data, err := os.ReadFile(filepath.Join(dir, "thread_siblings_list")); err == nil {
+			if list, err := parseCPUList(strings.TrimSpace(string(data))); err == nil && len(list) > 0 {
+				siblings = list
+			}
+		}
+
+		cpus = append(cpus, LogicalCPU{ID: id, Package: pkg, Core: core, Siblings: siblings})
+
+		key := [2]int{pkg, core}
+		pc, ok := cores[key]
+		if !ok {
+			pc = &PhysicalCore{CPU: id, Package: pkg, Core: core}
+			cores[key] = pc
+		}
+		pc.Threads = append(pc.Threads, id)
+		if id < pc.CPU {
+			pc.CPU = id
+		}
+	}
+
+	physical := make([]PhysicalCore, 0, len(cores))
+	for _, pc := range cores {
+		sort.Ints(pc.Threads)
+		physical = append(physical, *pc)
+	}
+	sort.Slice(physical, func(i, j int) bool { return physical[i].CPU < physical[j].CPU })
+	return cpus, physical, nil
+}
+
+// parseCPUList 解析 0-3,8,10-11 形式的 CPU 列表
+func parseCPUList(s string) ([]int, error) {
+	var result []int
+	if s == "" {
+		return result, nil
+	}
+	for _, part := range strings.Split(s, ",") {
+		lo, hi, isRange := strings.Cut(part, "-")
+		start, err := strconv.Atoi(lo)
+		if err != nil {
+			return nil, fmt.Errorf("invalid cpu list %q", s)
+		}
+		end := start
+		if isRange {
+			if end, err = strconv.Atoi(hi); err != nil || end < start {
+				return nil, fmt.Errorf("invalid cpu list %q", s)
+			}
+		}
+		for i := start; i <= end; i++ {
+			result = append(result, i)
+		}
+	}
+	return result, nil
+}
+
+// readIntFile 读取只包含一个整数的文件
+func readIntFile(path string) (int, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return 0, err
+	}
+	return strconv.Atoi(strings.TrimSpace(string(data)))
+}
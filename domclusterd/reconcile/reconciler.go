@@ -0,0 +1,609 @@
+package reconcile
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"domclusterd/connections"
+	"domclusterd/dockerctl"
+
+	cerrdefs "github.com/containerd/errdefs"
+	"github.com/docker/docker/api/types"
+	"go.uber.org/zap"
+)
+
+const (
+	// defaultInterval 两轮收敛之间的默认间隔
+	defaultInterval = 15 * time.Second
+	// queryTimeout 下发和查询期望状态的超时时间
+	queryTimeout = 30 * time.Second
+	// passTimeout 单轮收敛的超时时间，包括按需拉取镜像
+	passTimeout = 30 * time.Minute
+	// backoffBase 和 backoffMax 自动创建或启动容器后，下一次尝试前等待时间的初始值和上限
+	backoffBase = 10 * time.Second
+	backoffMax  = 5 * time.Minute
+	// backoffReset 容器持续运行多久后清除失败计数
+	backoffReset = time.Minute
+	// reportResendInterval 报告没有变化时重新发送的间隔，控制端重启后据此恢复
+	reportResendInterval = 5 * time.Minute
+)
+
+// StateMissing 期望的容器不存在
+const StateMissing = "missing"
+
+// Options 期望状态收敛配置
+type Options struct {
+	Enabled bool
+	// StatePath 保存期望状态的文件
+	StatePath string
+	// IntervalSeconds 两轮收敛之间的间隔
+	IntervalSeconds int
+}
+
+// ContainerReport 单个期望容器的收敛情况
+type ContainerReport struct {
+	Name   string `json:"name"`
+	Source string `json:"source,omitempty"`
+	Policy string `json:"policy"`
+	// State Docker 的容器状态，不存在时为 missing
+	State string `json:"state"`
+	// Held 经 domcluster 停止或删除，按策略不自动处理
+	Held string `json:"held,omitempty"`
+	// Drift 容器与声明不一致之处，如镜像不同、缺少挂载
+	Drift        []string  `json:"drift,omitempty"`
+	LastAction   string    `json:"last_action,omitempty"`
+	LastActionAt time.Time `json:"last_action_at,omitempty"`
+	// Failures 容器未能持续运行的连续次数，用于退避
+	Failures int    `json:"failures,omitempty"`
+	Error    string `json:"error,omitempty"`
+}
+
+// Report 一轮收敛的结果，上报给控制端
+type Report struct {
+	Revision   uint64            `json:"revision"`
+	Checked    time.Time         `json:"checked"`
+	Containers []ContainerReport `json:"containers"`
+	// Extra 由 domcluster 创建但不在期望状态中的容器
+	Extra []string `json:"extra,omitempty"`
+	Error string   `json:"error,omitempty"`
+}
+
+// backoff 单个容器的自动处理记录
+type backoff struct {
+	failures     int
+	next         time.Time
+	lastAction   string
+	lastActionAt time.Time
+	lastError    string
+}
+
+// Reconciler 按控制端下发的期望状态持续收敛本节点的容器
+//
+// 期望状态保存在本地文件中，收敛循环不依赖与控制端的连接，断线或控制端重启期间节点仍会
+// 重建缺失的容器并按策略启动已停止的容器。镜像、挂载等与声明不一致的容器只上报不修改，
+// 由控制端决定是否重建。经 domcluster 停止或删除的容器会留下保留标记，除 always 策略外
+// 不会被自动恢复，因此不会与自动扩缩容或手动停止相冲突。
+type Reconciler struct {
+	opts    Options
+	manager *connections.Manager
+	docker  *dockerctl.DockerClient
+	trigger chan struct{}
+
+	mu      sync.Mutex
+	state   *State
+	report  *Report
+	backoff map[string]*backoff
+	sentSig string
+	sentAt  time.Time
+}
+
+// NewReconciler 创建收敛器并读取本地保存的期望状态
+func NewReconciler(opts Options, manager *connections.Manager, docker *dockerctl.DockerClient) (*Reconciler, error) {
+	if opts.StatePath == "" {
+		opts.StatePath = "/var/lib/domclusterd/desired.json"
+	}
+	if opts.IntervalSeconds <= 0 {
+		opts.IntervalSeconds = int(defaultInterval / time.Second)
+	}
+	st, err := loadState(opts.StatePath)
+	if err != nil {
+		return nil, err
+	}
+	return &Reconciler{
+		opts:    opts,
+		manager: manager,
+		docker:  docker,
+		trigger: make(chan struct{}, 1),
+		state:   st,
+		backoff: make(map[string]*backoff),
+	}, nil
+}
+
+// Register 注册期望状态的下发和查询处理器并启动收敛循环
+func (r *Reconciler) Register(ctx context.Context) {
+	r.manager.RegisterQueryHandler(ctx, "desired_set", queryTimeout, r.setQuery)
+	r.manager.RegisterQueryHandler(ctx, "desired_status", queryTimeout, r.statusQuery)
+
+	go r.loop(ctx)
+	zap.L().Sugar().Infof("Desired state reconciler started (revision %d, %d containers)",
+		r.state.Revision, len(r.state.Containers))
+}
+
+// Track 在经 domcluster 修改容器之前调用，返回的函数在操作完成后调用
+//
+// 停止、删除和重建容器前先设置保留标记，避免收敛循环在操作过程中抢先启动或创建容器；
+// 操作失败时恢复原来的标记。started 表示操作完成后容器是否在运行。
+func (r *Reconciler) Track(cmd, ref string) func(started bool, err error) {
+	noop := func(bool, error) {}
+	if r == nil || ref == "" {
+		return noop
+	}
+	name := r.desiredName(ref)
+	if name == "" {
+		return noop
+	}
+
+	var prev string
+	switch cmd {
+	case "docker_stop":
+		prev = r.setHold(name, HoldStopped)
+	case "docker_remove", "docker_create", "docker_recreate":
+		prev = r.setHold(name, HoldRemoved)
+	case "docker_start", "docker_restart":
+		prev = r.holdOf(name)
+	default:
+		return noop
+	}
+
+	return func(started bool, err error) {
+		switch {
+		case err != nil:
+			r.setHold(name, prev)
+		case cmd == "docker_stop" || cmd == "docker_remove":
+		case started:
+			r.setHold(name, "")
+		default:
+			r.setHold(name, HoldStopped)
+		}
+	}
+}
+
+// TrackQuery 包装创建、删除和重建容器的查询处理器，维护保留标记
+func (r *Reconciler) TrackQuery(cmd string, fn connections.QueryFunc) connections.QueryFunc {
+	if r == nil {
+		return fn
+	}
+	return func(ctx context.Context, data []byte) (interface{}, error) {
+		var req struct {
+			ContainerID string `json:"container_id"`
+			Spec        *struct {
+				Name string `json:"name"`
+			} `json:"spec"`
+		}
+		_ = json.Unmarshal(data, &req)
+		ref := req.ContainerID
+		if req.Spec != nil {
+			ref = req.Spec.Name
+		}
+
+		done := r.Track(cmd, ref)
+		result, err := fn(ctx, data)
+		started := false
+		if res, ok := result.(*dockerctl.ContainerResult); ok && res != nil {
+			started = res.Started
+		}
+		done(started, err)
+		return result, err
+	}
+}
+
+// desiredName 将容器名或 ID 解析为期望状态中的容器名，不在期望状态中时返回空字符串
+func (r *Reconciler) desiredName(ref string) string {
+	name := strings.TrimPrefix(ref, "/")
+	if !r.isDesired(name) {
+		info, err := r.docker.InspectContainer(ref)
+		if err != nil {
+			return ""
+		}
+		name = strings.TrimPrefix(info.Name, "/")
+	}
+	if !r.isDesired(name) {
+		return ""
+	}
+	return name
+}
+
+// isDesired 检查容器名是否在期望状态中
+func (r *Reconciler) isDesired(name string) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, c := range r.state.Containers {
+		if c.Spec.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// holdOf 返回容器的保留标记
+func (r *Reconciler) holdOf(name string) string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.state.Holds[name]
+}
+
+// setHold 设置或清除（hold 为空）容器的保留标记并保存，返回原来的标记
+func (r *Reconciler) setHold(name, hold string) string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	prev := r.state.Holds[name]
+	if prev == hold {
+		return prev
+	}
+	if hold == "" {
+		delete(r.state.Holds, name)
+	} else {
+		if r.state.Holds == nil {
+			r.state.Holds = make(map[string]string)
+		}
+		r.state.Holds[name] = hold
+	}
+	// 成功启动的容器不再沿用之前的失败计数
+	if hold == "" {
+		delete(r.backoff, name)
+	}
+	if err := saveState(r.opts.StatePath, r.state); err != nil {
+		zap.L().Sugar().Warnf("Failed to save hold of container %s: %v", name, err)
+	}
+	return prev
+}
+
+// setQuery 处理 desired_set 查询，替换期望状态并立即开始一轮收敛
+//
+// 控制端是期望状态唯一的写入方，修订号只用于展示和核对，不拒绝较旧的修订。
+func (r *Reconciler) setQuery(ctx context.Context, data []byte) (interface{}, error) {
+	var req struct {
+		Revision   uint64             `json:"revision"`
+		Containers []DesiredContainer `json:"containers"`
+	}
+	if err := json.Unmarshal(data, &req); err != nil {
+		return nil, fmt.Errorf("invalid request: %w", err)
+	}
+	st := &State{
+		Revision:   req.Revision,
+		Updated:    time.Now(),
+		Containers: req.Containers,
+	}
+	if st.Containers == nil {
+		st.Containers = []DesiredContainer{}
+	}
+	if err := st.validate(); err != nil {
+		return nil, err
+	}
+
+	r.mu.Lock()
+	// 只保留仍在期望状态中的容器的保留标记
+	for _, c := range st.Containers {
+		if hold, ok := r.state.Holds[c.Spec.Name]; ok {
+			if st.Holds == nil {
+				st.Holds = make(map[string]string)
+			}
+			st.Holds[c.Spec.Name] = hold
+		}
+	}
+	if err := saveState(r.opts.StatePath, st); err != nil {
+		r.mu.Unlock()
+		return nil, err
+	}
+	r.state = st
+	r.mu.Unlock()
+
+	zap.L().Sugar().Infof("Desired state revision %d received (%d containers)", st.Revision, len(st.Containers))
+	r.kick()
+	return map[string]interface{}{
+		"revision":   st.Revision,
+		"containers": len(st.Containers),
+	}, nil
+}
+
+// statusQuery 处理 desired_status 查询，返回期望状态和最近一轮收敛的结果
+func (r *Reconciler) statusQuery(ctx context.Context, data []byte) (interface{}, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return map[string]interface{}{
+		"state":  r.state,
+		"report": r.report,
+	}, nil
+}
+
+// kick 触发一轮收敛，已有待执行的触发时忽略
+func (r *Reconciler) kick() {
+	select {
+	case r.trigger <- struct{}{}:
+	default:
+	}
+}
+
+// loop 启动后立即收敛一次，之后按间隔或在期望状态变化时收敛
+func (r *Reconciler) loop(ctx context.Context) {
+	ticker := time.NewTicker(time.Duration(r.opts.IntervalSeconds) * time.Second)
+	defer ticker.Stop()
+
+	for {
+		passCtx, cancel := context.WithTimeout(ctx, passTimeout)
+		r.pass(passCtx)
+		cancel()
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		case <-r.trigger:
+		}
+	}
+}
+
+// pass 执行一轮收敛并上报结果
+func (r *Reconciler) pass(ctx context.Context) {
+	r.mu.Lock()
+	revision := r.state.Revision
+	containers := append([]DesiredContainer(nil), r.state.Containers...)
+	r.mu.Unlock()
+
+	report := &Report{
+		Revision:   revision,
+		Checked:    time.Now(),
+		Containers: []ContainerReport{},
+	}
+	// 尚未收到期望状态时不处理任何容器
+	if revision == 0 && len(containers) == 0 {
+		r.publish(report)
+		return
+	}
+
+	desired := make(map[string]bool, len(containers))
+	for _, c := range containers {
+		desired[c.Spec.Name] = true
+		report.Containers = append(report.Containers, r.reconcileContainer(ctx, c))
+	}
+
+	managed, err := r.docker.ListManagedContainers(ctx, nil)
+	if err != nil {
+		report.Error = err.Error()
+	} else {
+		for _, mc := range managed {
+			if !desired[mc.Name] {
+				report.Extra = append(report.Extra, mc.Name)
+			}
+		}
+	}
+	r.publish(report)
+}
+
+// reconcileContainer 检查单个期望容器，缺失时创建，已停止时按策略启动
+func (r *Reconciler) reconcileContainer(ctx context.Context, c DesiredContainer) ContainerReport {
+	name := c.Spec.Name
+	rep := ContainerReport{
+		Name:   name,
+		Source: c.Source,
+		Policy: c.Policy,
+	}
+	hold := r.holdOf(name)
+	if c.Policy != PolicyAlways {
+		rep.Held = hold
+	}
+
+	info, err := r.docker.InspectContainer(name)
+	if err != nil {
+		if !cerrdefs.IsNotFound(err) {
+			rep.State = "unknown"
+			rep.Error = err.Error()
+			return rep
+		}
+		rep.State = StateMissing
+		if rep.Held == "" && r.due(name) {
+			start := c.Policy != PolicyNever
+			result, err := r.docker.CreateContainer(ctx, &c.Spec, start)
+			r.record(name, "created", err)
+			if err == nil {
+				rep.State = "created"
+				if result.Started {
+					rep.State = "running"
+				}
+			}
+		}
+		return r.withHistory(rep)
+	}
+
+	if info.State != nil {
+		rep.State = string(info.State.Status)
+	}
+	rep.Drift = drift(&c.Spec, info)
+
+	switch {
+	case info.State == nil:
+	case info.State.Running:
+		if started, err := time.Parse(time.RFC3339Nano, info.State.StartedAt); err == nil && time.Since(started) >= backoffReset {
+			r.resetBackoff(name)
+		}
+	case rep.Held == "" && shouldStart(c.Policy, rep.State, info.State.ExitCode) && r.due(name):
+		err := r.docker.StartContainer(info.ID)
+		r.record(name, "started", err)
+		if err == nil {
+			rep.State = "running"
+		}
+	}
+	return r.withHistory(rep)
+}
+
+// shouldStart 按策略判断是否启动未运行的容器
+func shouldStart(policy, state string, exitCode int) bool {
+	if state != "exited" && state != "created" {
+		return false
+	}
+	switch policy {
+	case PolicyAlways, PolicyUnlessStopped:
+		return true
+	case PolicyOnFailure:
+		return state == "exited" && exitCode != 0
+	default:
+		return false
+	}
+}
+
+// drift 列出容器与声明不一致之处
+func drift(spec *dockerctl.ContainerSpec, info types.ContainerJSON) []string {
+	if info.Config == nil {
+		return nil
+	}
+	var result []string
+	if info.Config.Labels[dockerctl.LabelManaged] != "true" {
+		result = append(result, "not created by domcluster")
+	}
+	if info.Config.Image != spec.Image {
+		result = append(result, fmt.Sprintf("image is %s, want %s", info.Config.Image, spec.Image))
+	}
+	for _, m := range spec.Mounts {
+		if d := mountDrift(m, info); d != "" {
+			result = append(result, d)
+		}
+	}
+	if len(result) == 0 && info.Config.Labels[dockerctl.LabelSpecHash] != spec.Hash() {
+		result = append(result, "configuration differs from the desired spec")
+	}
+	return result
+}
+
+// mountDrift 检查声明的挂载是否存在且来源一致
+func mountDrift(m dockerctl.MountSpec, info types.ContainerJSON) string {
+	typ := m.Type
+	if typ == "" {
+		typ = "bind"
+	}
+	for _, mp := range info.Mounts {
+		if mp.Destination != m.Target {
+			continue
+		}
+		if string(mp.Type) != typ {
+			return fmt.Sprintf("mount %s is %s, want %s", m.Target, mp.Type, typ)
+		}
+		source := mp.Source
+		if typ == "volume" {
+			source = mp.Name
+		}
+		if typ != "tmpfs" && source != m.Source {
+			return fmt.Sprintf("mount %s is from %s, want %s", m.Target, source, m.Source)
+		}
+		if mp.RW == m.ReadOnly {
+			return fmt.Sprintf("mount %s read-only is %v, want %v", m.Target, !mp.RW, m.ReadOnly)
+		}
+		return ""
+	}
+	// tmpfs 挂载不一定出现在 Mounts 中，再检查创建参数
+	if typ == "tmpfs" && info.HostConfig != nil {
+		for _, hm := range info.HostConfig.Mounts {
+			if hm.Target == m.Target && string(hm.Type) == typ {
+				return ""
+			}
+		}
+	}
+	return fmt.Sprintf("mount %s is missing", m.Target)
+}
+
+// due 检查容器是否已过退避时间
+func (r *Reconciler) due(name string) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	b, ok := r.backoff[name]
+	return !ok || !time.Now().Before(b.next)
+}
+
+// record 记录一次自动处理，无论成功与否都增加失败计数，容器持续运行后才清除
+func (r *Reconciler) record(name, action string, err error) {
+	r.mu.Lock()
+	b, ok := r.backoff[name]
+	if !ok {
+		b = &backoff{}
+		r.backoff[name] = b
+	}
+	b.failures++
+	delay := backoffMax
+	if b.failures <= 6 {
+		delay = min(backoffBase<<(b.failures-1), backoffMax)
+	}
+	b.next = time.Now().Add(delay)
+	b.lastAction = action
+	b.lastActionAt = time.Now()
+	b.lastError = ""
+	if err != nil {
+		b.lastError = err.Error()
+	}
+	r.mu.Unlock()
+
+	if err != nil {
+		zap.L().Sugar().Warnf("Reconcile: container %s not %s: %v (retry in %v)", name, action, err, delay)
+	} else {
+		zap.L().Sugar().Infof("Reconcile: container %s %s", name, action)
+	}
+}
+
+// resetBackoff 清除容器的失败计数，保留最近一次处理的记录
+func (r *Reconciler) resetBackoff(name string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if b, ok := r.backoff[name]; ok {
+		b.failures = 0
+		b.next = time.Time{}
+		b.lastError = ""
+	}
+}
+
+// withHistory 在报告中补充最近一次自动处理的记录
+func (r *Reconciler) withHistory(rep ContainerReport) ContainerReport {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if b, ok := r.backoff[rep.Name]; ok {
+		rep.LastAction = b.lastAction
+		rep.LastActionAt = b.lastActionAt
+		rep.Failures = b.failures
+		if rep.Error == "" {
+			rep.Error = b.lastError
+		}
+	}
+	return rep
+}
+
+// publish 保存报告，内容变化或距上次发送超过重发间隔时上报控制端
+//
+// 未连接时发送失败，下一轮会再次尝试，因此重新连接后控制端能收到最新的报告。
+func (r *Reconciler) publish(report *Report) {
+	sigReport := *report
+	sigReport.Checked = time.Time{}
+	sig, _ := json.Marshal(sigReport)
+
+	r.mu.Lock()
+	r.report = report
+	unchanged := string(sig) == r.sentSig && time.Since(r.sentAt) < reportResendInterval
+	r.mu.Unlock()
+	if unchanged {
+		return
+	}
+
+	data, err := json.Marshal(report)
+	if err != nil {
+		return
+	}
+	if err := r.manager.Send("desired_report", "", data); err != nil {
+		zap.L().Sugar().Debugf("Failed to send desired state report: %v", err)
+		return
+	}
+	r.mu.Lock()
+	r.sentSig = string(sig)
+	r.sentAt = time.Now()
+	r.mu.Unlock()
+}
@@ -0,0 +1,105 @@
+package reconcile
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"domclusterd/dockerctl"
+)
+
+// 容器停止后的处理策略
+const (
+	// PolicyAlways 容器未运行时总是启动，包括经 domcluster 停止的容器
+	PolicyAlways = "always"
+	// PolicyUnlessStopped 容器未运行时启动，经 domcluster 停止或删除的容器除外（默认）
+	PolicyUnlessStopped = "unless-stopped"
+	// PolicyOnFailure 只启动以非零状态码退出的容器
+	PolicyOnFailure = "on-failure"
+	// PolicyNever 缺失时创建但不启动，之后不再处理容器的运行状态
+	PolicyNever = "never"
+)
+
+// 经 domcluster 执行的操作留下的保留标记，带标记的容器不会被自动启动或重建
+const (
+	HoldStopped = "stopped"
+	HoldRemoved = "removed"
+)
+
+// DesiredContainer 期望存在的容器
+type DesiredContainer struct {
+	// Source 声明的来源，如 stack/<name>，仅用于展示
+	Source string                  `json:"source,omitempty"`
+	Policy string                  `json:"policy,omitempty"`
+	Spec   dockerctl.ContainerSpec `json:"spec"`
+}
+
+// State 控制端下发的期望状态，保存在本地以便断线和重启后继续收敛
+type State struct {
+	Revision   uint64             `json:"revision"`
+	Updated    time.Time          `json:"updated"`
+	Containers []DesiredContainer `json:"containers"`
+	// Holds 按容器名记录的保留标记
+	Holds map[string]string `json:"holds,omitempty"`
+}
+
+// validate 检查期望状态中的声明和策略，并补全默认策略
+func (st *State) validate() error {
+	seen := make(map[string]bool, len(st.Containers))
+	for i := range st.Containers {
+		c := &st.Containers[i]
+		if err := dockerctl.ValidateSpec(&c.Spec); err != nil {
+			return fmt.Errorf("container %s: %w", c.Spec.Name, err)
+		}
+		if seen[c.Spec.Name] {
+			return fmt.Errorf("duplicate container %s", c.Spec.Name)
+		}
+		seen[c.Spec.Name] = true
+
+		switch c.Policy {
+		case "":
+			c.Policy = PolicyUnlessStopped
+		case PolicyAlways, PolicyUnlessStopped, PolicyOnFailure, PolicyNever:
+		default:
+			return fmt.Errorf("container %s: invalid policy %q", c.Spec.Name, c.Policy)
+		}
+	}
+	return nil
+}
+
+// loadState 读取本地保存的期望状态，文件不存在时返回空状态
+func loadState(path string) (*State, error) {
+	st := &State{}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return st, nil
+		}
+		return nil, fmt.Errorf("failed to read desired state: %w", err)
+	}
+	if err := json.Unmarshal(data, st); err != nil {
+		return nil, fmt.Errorf("failed to parse desired state: %w", err)
+	}
+	return st, nil
+}
+
+// saveState 先写临时文件再重命名，避免断电后留下不完整的文件
+func saveState(path string, st *State) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("failed to create state dir: %w", err)
+	}
+	data, err := json.MarshalIndent(st, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return fmt.Errorf("failed to write desired state: %w", err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return fmt.Errorf("failed to save desired state: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,84 @@
+package shell
+
+import (
+	"fmt"
+	"os"
+	"syscall"
+	"unsafe"
+)
+
+// winsize 对应内核的 struct winsize
+type winsize struct {
+	Rows   uint16
+	Cols   uint16
+	XPixel uint16
+	YPixel uint16
+}
+
+// ioctl 执行 ioctl 系统调用
+//
+// 通过 SyscallConn 访问描述符，调用 Fd() 会把文件切换为阻塞模式，之后 Close 无法打断进行中的 Read。
+func ioctl(f *os.File, req uintptr, arg uintptr) error {
+	rc, err := f.SyscallConn()
+	if err != nil {
+		return err
+	}
+	var errno syscall.Errno
+	if err := rc.Control(func(fd uintptr) {
+		_, _, errno = syscall.Syscall(syscall.SYS_IOCTL, fd, req, arg)
+	}); err != nil {
+		return err
+	}
+	if errno != 0 {
+		return errno
+	}
+	return nil
+}
+
+// openPTY 通过 /dev/ptmx 分配一对伪终端，返回主端和从端
+func openPTY() (master, slave *os.File, err error) {
+	master, err = os.OpenFile("/dev/ptmx", os.O_RDWR|syscall.O_NOCTTY|syscall.O_CLOEXEC, 0)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to open /dev/ptmx: %w", err)
+	}
+	defer func() {
+		if err != nil {
+			master.Close()
+		}
+	}()
+
+	var unlock int32
+	if err = ioctl(master, syscall.TIOCSPTLCK, uintptr(unsafe.Pointer(&unlock))); err != nil {
+		return nil, nil, fmt.Errorf("failed to unlock pty: %w", err)
+	}
+
+	var n uint32
+	if err = ioctl(master, syscall.TIOCGPTN, uintptr(unsafe.Pointer(&n))); err != nil {
+		return nil, nil, fmt.Errorf("failed to get pty number: %w", err)
+	}
+
+	slavePath := fmt.Sprintf("/dev/pts/%d", n)
+	slave, err = os.OpenFile(slavePath, os.O_RDWR|syscall.O_NOCTTY|syscall.O_CLOEXEC, 0)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to open %s: %w", slavePath, err)
+	}
+	return master, slave, nil
+}
+
+// setWinsize 设置终端窗口大小，内核会向前台进程组发送 SIGWINCH
+func setWinsize(f *os.File, cols, rows uint) error {
+	ws := winsize{Rows: uint16(rows), Cols: uint16(cols)}
+	if err := ioctl(f, syscall.TIOCSWINSZ, uintptr(unsafe.Pointer(&ws))); err != nil {
+		return fmt.Errorf("failed to set window size: %w", err)
+	}
+	return nil
+}
+
+// foregroundPgrp 获取终端的前台进程组
+func foregroundPgrp(f *os.File) (int, error) {
+	var pgrp int32
+	if err := ioctl(f, syscall.TIOCGPGRP, uintptr(unsafe.Pointer(&pgrp))); err != nil {
+		return 0, fmt.Errorf("failed to get foreground process group: %w", err)
+	}
+	return int(pgrp), nil
+}
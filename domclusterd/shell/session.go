@@ -0,0 +1,136 @@
+package shell
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"sync"
+	"syscall"
+	"time"
+)
+
+const (
+	defaultCols = 80
+	defaultRows = 24
+	// killGracePeriod 关闭会话时 SIGHUP 之后等待进程退出的时间，超时发送 SIGKILL
+	killGracePeriod = 2 * time.Second
+)
+
+// SessionOptions 终端会话参数
+type SessionOptions struct {
+	// Shell 为空时优先使用 /bin/bash，不存在时使用 /bin/sh
+	Shell string
+	Dir   string
+	Env   []string
+	Cols  uint
+	Rows  uint
+}
+
+// Session 运行在 PTY 上的长期 shell 会话
+//
+// shell 是新会话的首进程，PTY 为其控制终端，因此 cd、环境变量、作业控制、
+// Ctrl-C 以及 vim、top 等全屏程序都与普通终端一致。
+type Session struct {
+	cmd  *exec.Cmd
+	pty  *os.File
+	done chan struct{}
+
+	closeOnce sync.Once
+}
+
+// StartSession 分配 PTY 并启动 shell
+func StartSession(opts SessionOptions) (*Session, error) {
+	if opts.Shell == "" {
+		opts.Shell = "/bin/sh"
+		if _, err := os.Stat("/bin/bash"); err == nil {
+			opts.Shell = "/bin/bash"
+		}
+	}
+	if opts.Cols == 0 || opts.Rows == 0 {
+		opts.Cols, opts.Rows = defaultCols, defaultRows
+	}
+	if opts.Dir == "" {
+		opts.Dir = os.Getenv("HOME")
+		if opts.Dir == "" {
+			opts.Dir = "/"
+		}
+	}
+
+	master, slave, err := openPTY()
+	if err != nil {
+		return nil, err
+	}
+	// 子进程已持有从端，父进程不再需要
+	defer slave.Close()
+
+	if err := setWinsize(master, opts.Cols, opts.Rows); err != nil {
+		master.Close()
+		return nil, err
+	}
+
+	cmd := exec.Command(opts.Shell, "-l")
+	cmd.Dir = opts.Dir
+	cmd.Env = append(append(os.Environ(), "TERM=xterm-256color"), opts.Env...)
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = slave, slave, slave
+	cmd.SysProcAttr = &syscall.SysProcAttr{
+		Setsid:  true,
+		Setctty: true,
+		Ctty:    0,
+	}
+	if err := cmd.Start(); err != nil {
+		master.Close()
+		return nil, fmt.Errorf("failed to start shell: %w", err)
+	}
+
+	s := &Session{cmd: cmd, pty: master, done: make(chan struct{})}
+	go func() {
+		cmd.Wait()
+		close(s.done)
+	}()
+	return s, nil
+}
+
+// Read 读取终端输出，shell 及其所有子进程退出后返回错误
+func (s *Session) Read(p []byte) (int, error) {
+	return s.pty.Read(p)
+}
+
+// Write 写入终端输入
+func (s *Session) Write(p []byte) (int, error) {
+	return s.pty.Write(p)
+}
+
+// Resize 调整终端大小
+func (s *Session) Resize(cols, rows uint) error {
+	return setWinsize(s.pty, cols, rows)
+}
+
+// Signal 向终端的前台进程组发送信号
+func (s *Session) Signal(sig syscall.Signal) error {
+	pgrp, err := foregroundPgrp(s.pty)
+	if err != nil {
+		return err
+	}
+	return syscall.Kill(-pgrp, sig)
+}
+
+// ExitCode 等待 shell 退出并返回退出码
+func (s *Session) ExitCode() int {
+	<-s.done
+	return s.cmd.ProcessState.ExitCode()
+}
+
+// Close 结束会话：向 shell 的进程组发送 SIGHUP，宽限期后仍未退出则 SIGKILL
+func (s *Session) Close() {
+	s.closeOnce.Do(func() {
+		pgid := s.cmd.Process.Pid
+		syscall.Kill(-pgid, syscall.SIGHUP)
+		select {
+		case <-s.done:
+		case <-time.After(killGracePeriod):
+			syscall.Kill(-pgid, syscall.SIGKILL)
+			<-s.done
+		}
+		s.pty.Close()
+	})
+}
@@ -0,0 +1,210 @@
+package streams
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	"domclusterd/connections"
+
+	pb "domcluster/api/proto"
+	"go.uber.org/zap"
+)
+
+const (
+	// maxActiveStreams 同时运行的流数量上限
+	maxActiveStreams = 64
+	// inputBufferSize 每个流缓存的控制端输入消息数
+	inputBufferSize = 256
+	// inputWaitTimeout 输入缓冲区已满时等待处理函数读取的时间，期间接收循环被阻塞，因此不宜过长
+	inputWaitTimeout = 2 * time.Second
+)
+
+// HandlerFunc 流处理函数，返回即表示流结束；ctx 在控制端取消或节点退出时取消
+type HandlerFunc func(ctx context.Context, data []byte, st *Stream) error
+
+// Stream 一个到控制端的数据流，以请求 ID 标识
+type Stream struct {
+	id      string
+	manager *connections.Manager
+	input   chan []byte
+
+	mu     sync.Mutex
+	failed error
+}
+
+// ID 返回流 ID
+func (s *Stream) ID() string {
+	return s.id
+}
+
+// Input 返回控制端通过 stream_input 发送给该流的消息（如终端输入、窗口大小调整）
+func (s *Stream) Input() <-chan []byte {
+	return s.input
+}
+
+// Send 发送一条消息，payload 会被编码为 JSON，其中应包含 type 字段
+func (s *Stream) Send(payload interface{}) error {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("failed to marshal stream message: %w", err)
+	}
+	return s.manager.Send("stream_data", s.id, data)
+}
+
+// failure 返回流被节点主动结束的原因
+func (s *Stream) failure() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.failed
+}
+
+// Registry 长时间运行的流式命令（日志跟随、统计推送、交互式会话等）
+//
+// 控制端以命令名发起流，节点在独立协程中运行处理函数并通过 stream_data 推送数据，
+// 结束时发送 type 为 end 的消息；控制端发送 stream_cancel 可提前结束流。
+type Registry struct {
+	ctx     context.Context
+	manager *connections.Manager
+
+	mu     sync.Mutex
+	active map[string]*activeStream
+}
+
+// activeStream 正在运行的流
+type activeStream struct {
+	stream *Stream
+	ctx    context.Context
+	cancel context.CancelFunc
+}
+
+// fail 以错误结束流，结束消息中带有原因
+func (a *activeStream) fail(err error) {
+	a.stream.mu.Lock()
+	if a.stream.failed == nil {
+		a.stream.failed = err
+	}
+	a.stream.mu.Unlock()
+	a.cancel()
+}
+
+// NewRegistry 创建流注册表，ctx 取消时所有流结束
+func NewRegistry(ctx context.Context, manager *connections.Manager) *Registry {
+	r := &Registry{
+		ctx:     ctx,
+		manager: manager,
+		active:  make(map[string]*activeStream),
+	}
+	manager.RegisterHandler("stream_cancel", r.handleCancel)
+	manager.RegisterHandler("stream_input", r.handleInput)
+	return r
+}
+
+// Handle 注册流式命令
+func (r *Registry) Handle(cmd string, fn HandlerFunc) {
+	r.manager.RegisterHandler(cmd, func(resp *pb.PublishResponse) error {
+		r.start(cmd, resp.ReqId, resp.Data, fn)
+		return nil
+	})
+}
+
+// start 在独立协程中运行流处理函数，避免阻塞接收循环
+func (r *Registry) start(cmd, id string, data []byte, fn HandlerFunc) {
+	st := &Stream{id: id, manager: r.manager, input: make(chan []byte, inputBufferSize)}
+
+	r.mu.Lock()
+	if _, exists := r.active[id]; exists {
+		r.mu.Unlock()
+		zap.L().Sugar().Warnf("Stream %s already running, ignoring duplicate %s", id, cmd)
+		return
+	}
+	if len(r.active) >= maxActiveStreams {
+		r.mu.Unlock()
+		st.Send(endMessage(fmt.Errorf("too many active streams (limit %d)", maxActiveStreams)))
+		return
+	}
+	ctx, cancel := context.WithCancel(r.ctx)
+	r.active[id] = &activeStream{stream: st, ctx: ctx, cancel: cancel}
+	r.mu.Unlock()
+
+	go func() {
+		defer func() {
+			r.mu.Lock()
+			delete(r.active, id)
+			r.mu.Unlock()
+			cancel()
+		}()
+
+		zap.L().Sugar().Infof("Stream %s started: %s", id, cmd)
+		err := fn(ctx, data, st)
+		if failed := st.failure(); failed != nil {
+			err = failed
+		} else if ctx.Err() != nil && r.ctx.Err() == nil {
+			// 控制端主动取消时不再回复
+			zap.L().Sugar().Infof("Stream %s cancelled", id)
+			return
+		}
+		if err := st.Send(endMessage(err)); err != nil {
+			zap.L().Sugar().Warnf("Failed to send end of stream %s: %v", id, err)
+		}
+		zap.L().Sugar().Infof("Stream %s finished", id)
+	}()
+}
+
+// Cancel 结束指定的流
+func (r *Registry) Cancel(id string) bool {
+	r.mu.Lock()
+	active, ok := r.active[id]
+	r.mu.Unlock()
+	if ok {
+		active.cancel()
+	}
+	return ok
+}
+
+// handleCancel 处理控制端的取消请求
+func (r *Registry) handleCancel(resp *pb.PublishResponse) error {
+	r.Cancel(resp.ReqId)
+	return nil
+}
+
+// handleInput 将控制端的输入转交给对应的流
+//
+// 输入来自交互式会话，丢弃会使终端内容错乱。缓冲区写满时短暂等待处理函数读取，
+// 仍未读取则结束会话并告知控制端，而不是长时间阻塞接收循环。
+func (r *Registry) handleInput(resp *pb.PublishResponse) error {
+	r.mu.Lock()
+	active, ok := r.active[resp.ReqId]
+	r.mu.Unlock()
+	if !ok {
+		return nil
+	}
+
+	select {
+	case active.stream.input <- resp.Data:
+		return nil
+	default:
+	}
+
+	timer := time.NewTimer(inputWaitTimeout)
+	defer timer.Stop()
+	select {
+	case active.stream.input <- resp.Data:
+	case <-active.ctx.Done():
+	case <-timer.C:
+		zap.L().Sugar().Warnf("Stream %s input not consumed within %v, closing session", resp.ReqId, inputWaitTimeout)
+		active.fail(fmt.Errorf("session input not consumed within %v", inputWaitTimeout))
+	}
+	return nil
+}
+
+// endMessage 构造流结束消息
+func endMessage(err error) map[string]interface{} {
+	msg := map[string]interface{}{"type": "end"}
+	if err != nil {
+		msg["error"] = err.Error()
+	}
+	return msg
+}
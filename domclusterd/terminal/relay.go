@@ -0,0 +1,159 @@
+package terminal
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"syscall"
+	"time"
+	"unicode/utf8"
+)
+
+// readBufferSize 单次读取终端输出的缓冲区大小
+const readBufferSize = 32 * 1024
+
+// ErrIdleTimeout 会话长时间没有输入被关闭
+var ErrIdleTimeout = errors.New("terminal session idle timeout")
+
+// Terminal 交互式终端（PTY 上的 shell、容器内的 exec 等）
+type Terminal interface {
+	// Read 读取终端输出，终端关闭后返回错误
+	Read(p []byte) (int, error)
+	// Write 写入终端输入
+	Write(p []byte) (int, error)
+	// Resize 调整窗口大小
+	Resize(cols, rows uint) error
+}
+
+// Signaler 支持向前台进程发送信号的终端
+type Signaler interface {
+	Signal(sig syscall.Signal) error
+}
+
+// signals 允许控制端发送的信号
+var signals = map[string]syscall.Signal{
+	"INT":  syscall.SIGINT,
+	"QUIT": syscall.SIGQUIT,
+	"TERM": syscall.SIGTERM,
+	"HUP":  syscall.SIGHUP,
+	"KILL": syscall.SIGKILL,
+	"TSTP": syscall.SIGTSTP,
+	"CONT": syscall.SIGCONT,
+}
+
+// inputMessage 控制端发送的终端输入
+type inputMessage struct {
+	Type   string `json:"type"`
+	Data   string `json:"data"`
+	Cols   uint   `json:"cols"`
+	Rows   uint   `json:"rows"`
+	Signal string `json:"signal"`
+}
+
+// Relay 在终端与控制端之间双向转发数据，直到终端输出结束、ctx 取消或空闲超时
+//
+// 输入消息为 {"type":"input","data"}、{"type":"resize","cols","rows"} 或
+// {"type":"signal","signal":"INT"}；输出消息为 {"type":"output","data"}。
+// idleTimeout 为 0 时不检查空闲，超时返回 ErrIdleTimeout。终端输出正常结束时返回 nil，
+// 调用方负责关闭终端和上报退出状态。
+func Relay(ctx context.Context, term Terminal, input <-chan []byte, send func(interface{}) error, idleTimeout time.Duration) error {
+	outputDone := make(chan error, 1)
+	go func() {
+		outputDone <- pumpOutput(term, send)
+	}()
+
+	var idle <-chan time.Time
+	var idleTimer *time.Timer
+	if idleTimeout > 0 {
+		idleTimer = time.NewTimer(idleTimeout)
+		defer idleTimer.Stop()
+		idle = idleTimer.C
+	}
+
+	for {
+		select {
+		case msg := <-input:
+			handleInput(term, msg)
+			if idleTimer != nil {
+				idleTimer.Reset(idleTimeout)
+			}
+		case err := <-outputDone:
+			return err
+		case <-idle:
+			send(map[string]interface{}{
+				"type": "output",
+				"data": fmt.Sprintf("\r\n[session closed after %s of inactivity]\r\n", idleTimeout),
+			})
+			return ErrIdleTimeout
+		case <-ctx.Done():
+			return nil
+		}
+	}
+}
+
+// handleInput 处理一条输入消息
+//
+// 写入失败说明终端已经关闭，由输出协程读到错误后结束会话，这里不做处理。
+func handleInput(term Terminal, msg []byte) {
+	var in inputMessage
+	if err := json.Unmarshal(msg, &in); err != nil {
+		return
+	}
+
+	switch in.Type {
+	case "input":
+		term.Write([]byte(in.Data))
+	case "resize":
+		if in.Cols > 0 && in.Rows > 0 {
+			term.Resize(in.Cols, in.Rows)
+		}
+	case "signal":
+		sig, ok := signals[in.Signal]
+		if s, supported := term.(Signaler); ok && supported {
+			s.Signal(sig)
+		}
+	}
+}
+
+// pumpOutput 持续读取终端输出并推送，终端关闭时返回 nil
+//
+// JSON 字符串只能承载合法的 UTF-8，读取边界上被截断的多字节字符留到下一次一起发送。
+func pumpOutput(r Terminal, send func(interface{}) error) error {
+	buf := make([]byte, readBufferSize)
+	var pending []byte
+	for {
+		n, err := r.Read(buf)
+		if n > 0 {
+			pending = append(pending, buf[:n]...)
+			complete, rest := splitUTF8(pending)
+			if len(complete) > 0 {
+				if sendErr := send(map[string]interface{}{"type": "output", "data": string(complete)}); sendErr != nil {
+					return sendErr
+				}
+			}
+			pending = append(pending[:0], rest...)
+		}
+		if err != nil {
+			if len(pending) > 0 {
+				send(map[string]interface{}{"type": "output", "data": string(pending)})
+			}
+			// 终端关闭的方式不一（EOF、EIO 等），读取错误一律视为输出结束
+			return nil
+		}
+	}
+}
+
+// splitUTF8 将数据拆分为完整的部分和末尾未完整的多字节字符
+func splitUTF8(b []byte) (complete, rest []byte) {
+	// UTF-8 字符最长 4 字节，只需检查末尾 3 个字节
+	for i := len(b) - 1; i >= 0 && i >= len(b)-3; i-- {
+		if utf8.RuneStart(b[i]) {
+			if !utf8.FullRune(b[i:]) {
+				return b[:i], b[i:]
+			}
+			break
+		}
+	}
+	return b, nil
+}
@@ -0,0 +1,224 @@
+package transfer
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// download 一次下载会话，容器内的文件先导出到暂存目录
+type download struct {
+	path     string
+	staged   string
+	size     int64
+	sha256   string
+	mode     os.FileMode
+	lastUsed time.Time
+}
+
+// source 下载时实际读取的文件
+func (d *download) source() string {
+	if d.staged != "" {
+		return d.staged
+	}
+	return d.path
+}
+
+// downloadBegin 准备下载，回复文件大小、校验和和权限
+//
+// 相同 transfer_id 的会话已存在时直接返回原有信息，控制端断线重连后可以从任意位置继续。
+func (h *Handler) downloadBegin(ctx context.Context, data []byte) (interface{}, error) {
+	var req struct {
+		TransferID  string `json:"transfer_id"`
+		Path        string `json:"path"`
+		ContainerID string `json:"container_id"`
+		MaxSize     int64  `json:"max_size"`
+	}
+	if err := json.Unmarshal(data, &req); err != nil {
+		return nil, fmt.Errorf("invalid request: %w", err)
+	}
+	if !validTransferID(req.TransferID) {
+		return nil, fmt.Errorf("invalid transfer_id")
+	}
+	if err := validatePath(req.Path); err != nil {
+		return nil, err
+	}
+	if req.ContainerID != "" && h.docker == nil {
+		return nil, errDockerUnavailable
+	}
+	limit := h.maxSize(req.MaxSize)
+
+	h.mu.Lock()
+	if d, ok := h.downloads[req.TransferID]; ok && d.path == req.Path {
+		d.lastUsed = time.Now()
+		h.mu.Unlock()
+		return downloadInfo(req.TransferID, d), nil
+	}
+	h.mu.Unlock()
+
+	d := &download{path: req.Path}
+	if req.ContainerID != "" {
+		if err := os.MkdirAll(h.opts.StagingDir, 0700); err != nil {
+			return nil, fmt.Errorf("failed to create staging dir: %w", err)
+		}
+		d.staged = filepath.Join(h.opts.StagingDir, req.TransferID+".download")
+		f, err := os.OpenFile(d.staged, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0600)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create staging file: %w", err)
+		}
+		_, mode, err := h.docker.CopyFileFromContainer(ctx, req.ContainerID, req.Path, f, limit)
+		f.Close()
+		if err != nil {
+			os.Remove(d.staged)
+			return nil, err
+		}
+		d.mode = mode
+	} else {
+		info, err := os.Stat(req.Path)
+		if err != nil {
+			return nil, err
+		}
+		if !info.Mode().IsRegular() {
+			return nil, fmt.Errorf("%s is not a regular file", req.Path)
+		}
+		if info.Size() > limit {
+			return nil, fmt.Errorf("%s is %d bytes, exceeds limit of %d bytes", req.Path, info.Size(), limit)
+		}
+		d.mode = info.Mode().Perm()
+	}
+
+	size, sum, err := hashFile(d.source())
+	if err != nil {
+		if d.staged != "" {
+			os.Remove(d.staged)
+		}
+		return nil, err
+	}
+	d.size = size
+	d.sha256 = sum
+	d.lastUsed = time.Now()
+
+	h.mu.Lock()
+	if old, ok := h.downloads[req.TransferID]; ok && old.staged != "" && old.staged != d.staged {
+		os.Remove(old.staged)
+	}
+	h.downloads[req.TransferID] = d
+	h.mu.Unlock()
+
+	return downloadInfo(req.TransferID, d), nil
+}
+
+// downloadChunk 读取一个分块，回复分块数据和校验和
+func (h *Handler) downloadChunk(ctx context.Context, data []byte) (interface{}, error) {
+	var req struct {
+		TransferID string `json:"transfer_id"`
+		Offset     int64  `json:"offset"`
+		Length     int    `json:"length"`
+	}
+	if err := json.Unmarshal(data, &req); err != nil {
+		return nil, fmt.Errorf("invalid request: %w", err)
+	}
+	if req.Length <= 0 || req.Length > maxChunkSize {
+		req.Length = maxChunkSize
+	}
+
+	h.mu.Lock()
+	d, ok := h.downloads[req.TransferID]
+	if ok {
+		d.lastUsed = time.Now()
+	}
+	h.mu.Unlock()
+	if !ok {
+		return nil, fmt.Errorf("unknown transfer %s", req.TransferID)
+	}
+	if req.Offset < 0 || req.Offset > d.size {
+		return nil, fmt.Errorf("offset %d out of range", req.Offset)
+	}
+
+	f, err := os.Open(d.source())
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	buf := make([]byte, min(int64(req.Length), d.size-req.Offset))
+	if _, err := f.ReadAt(buf, req.Offset); err != nil && err != io.EOF {
+		return nil, fmt.Errorf("failed to read chunk: %w", err)
+	}
+	sum := sha256.Sum256(buf)
+	return map[string]interface{}{
+		"offset": req.Offset,
+		"data":   buf,
+		"sha256": hex.EncodeToString(sum[:]),
+	}, nil
+}
+
+// downloadEnd 结束下载会话并删除暂存文件
+func (h *Handler) downloadEnd(ctx context.Context, data []byte) (interface{}, error) {
+	var req struct {
+		TransferID string `json:"transfer_id"`
+	}
+	if err := json.Unmarshal(data, &req); err != nil {
+		return nil, fmt.Errorf("invalid request: %w", err)
+	}
+
+	h.mu.Lock()
+	d, ok := h.downloads[req.TransferID]
+	delete(h.downloads, req.TransferID)
+	h.mu.Unlock()
+
+	if ok && d.staged != "" {
+		os.Remove(d.staged)
+	}
+	return map[string]interface{}{"transfer_id": req.TransferID}, nil
+}
+
+// cleanupDownloads 释放长时间没有请求的下载会话
+func (h *Handler) cleanupDownloads() {
+	cutoff := time.Now().Add(-downloadIdleTimeout)
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for id, d := range h.downloads {
+		if d.lastUsed.After(cutoff) {
+			continue
+		}
+		if d.staged != "" {
+			os.Remove(d.staged)
+		}
+		delete(h.downloads, id)
+	}
+}
+
+// downloadInfo 构造下载信息回复
+func downloadInfo(id string, d *download) map[string]interface{} {
+	return map[string]interface{}{
+		"transfer_id": id,
+		"path":        d.path,
+		"size":        d.size,
+		"sha256":      d.sha256,
+		"mode":        fmt.Sprintf("%04o", d.mode),
+	}
+}
+
+// hashFile 计算文件的大小和 SHA-256
+func hashFile(path string) (int64, string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return 0, "", err
+	}
+	defer f.Close()
+
+	hash := sha256.New()
+	n, err := io.Copy(hash, f)
+	if err != nil {
+		return 0, "", fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	return n, hex.EncodeToString(hash.Sum(nil)), nil
+}
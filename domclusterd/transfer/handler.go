@@ -0,0 +1,211 @@
+package transfer
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"domclusterd/connections"
+	"domclusterd/dockerctl"
+	"domclusterd/files"
+
+	"go.uber.org/zap"
+)
+
+const (
+	// maxChunkSize 单个分块的最大字节数，控制端按此上限切分
+	maxChunkSize = 1024 * 1024
+	// staleTransferAge 未完成的传输在暂存目录中保留的时间，超时后清理
+	staleTransferAge = 24 * time.Hour
+	// downloadIdleTimeout 下载会话无请求多久后释放
+	downloadIdleTimeout = 30 * time.Minute
+	// operationTimeout 单次文件操作（包括与容器之间的复制）的超时时间
+	operationTimeout = 10 * time.Minute
+	// commitTimeout 提交上传的超时时间，导入大镜像可能较慢
+	commitTimeout = 30 * time.Minute
+)
+
+// Options 文件传输配置
+type Options struct {
+	// StagingDir 上传分块和从容器导出的文件的暂存目录
+	StagingDir string
+	// MaxSizeMB 单个文件的大小上限
+	MaxSizeMB int
+	// MaxImageSizeMB 离线分发的镜像 tar 的大小上限
+	MaxImageSizeMB int
+}
+
+// Handler 节点与控制端之间的分块文件传输
+//
+// 所有命令通过 query_response 回复，失败时回复 {"error": ...}。上传的分块先写入暂存目录，
+// 校验整个文件的 SHA-256 后才安装到目标路径；传输 ID 由控制端根据目标和内容生成，
+// 连接中断后以相同 ID 重新开始即可从已接收的位置继续。写入节点文件系统的目标路径受写入根目录限制，容器内路径不受限制。
+type Handler struct {
+	opts    Options
+	manager *connections.Manager
+	docker  *dockerctl.DockerClient
+	roots   files.WriteRoots
+
+	mu        sync.Mutex
+	downloads map[string]*download
+	// uploads 正在处理的上传任务的锁，按传输 ID 加锁，不同任务的提交互不阻塞
+	uploads map[string]*uploadLock
+}
+
+// NewHandler 创建文件传输处理器，docker 为 nil 时不支持容器内路径
+func NewHandler(opts Options, manager *connections.Manager, docker *dockerctl.DockerClient, roots files.WriteRoots) *Handler {
+	if opts.StagingDir == "" {
+		opts.StagingDir = "/var/lib/domclusterd/transfers"
+	}
+	if opts.MaxSizeMB <= 0 {
+		opts.MaxSizeMB = 1024
+	}
+	if opts.MaxImageSizeMB <= 0 {
+		opts.MaxImageSizeMB = 8192
+	}
+	return &Handler{
+		opts:      opts,
+		manager:   manager,
+		docker:    docker,
+		roots:     roots,
+		downloads: make(map[string]*download),
+		uploads:   make(map[string]*uploadLock),
+	}
+}
+
+// Register 注册处理器并启动暂存目录清理
+func (h *Handler) Register(ctx context.Context) {
+	handlers := map[string]connections.QueryFunc{
+		"file_stat":           h.stat,
+		"file_upload_begin":   h.uploadBegin,
+		"file_upload_chunk":   h.uploadChunk,
+		"file_upload_commit":  h.uploadCommit,
+		"file_download_begin": h.downloadBegin,
+		"file_download_chunk": h.downloadChunk,
+		"file_download_end":   h.downloadEnd,
+	}
+	for cmd, fn := range handlers {
+		timeout := operationTimeout
+		if cmd == "file_upload_commit" {
+			timeout = commitTimeout
+		}
+		h.manager.RegisterQueryHandler(ctx, cmd, timeout, fn)
+	}
+
+	go h.cleanupLoop(ctx)
+	zap.L().Sugar().Info("File transfer handlers registered")
+}
+
+// maxSize 计算本次传输的大小上限，请求中的限制只能收紧节点配置的上限
+func (h *Handler) maxSize(requested int64) int64 {
+	limit := int64(h.opts.MaxSizeMB) * 1024 * 1024
+	if requested > 0 && requested < limit {
+		return requested
+	}
+	return limit
+}
+
+// maxImageSize 镜像 tar 的大小上限
+func (h *Handler) maxImageSize() int64 {
+	return int64(h.opts.MaxImageSizeMB) * 1024 * 1024
+}
+
+// stat 查询主机或容器内路径的信息
+func (h *Handler) stat(ctx context.Context, data []byte) (interface{}, error) {
+	var req struct {
+		Path        string `json:"path"`
+		ContainerID string `json:"container_id"`
+	}
+	if err := json.Unmarshal(data, &req); err != nil {
+		return nil, fmt.Errorf("invalid request: %w", err)
+	}
+	if err := validatePath(req.Path); err != nil {
+		return nil, err
+	}
+
+	if req.ContainerID != "" {
+		if h.docker == nil {
+			return nil, errDockerUnavailable
+		}
+		st, err := h.docker.StatContainerPath(ctx, req.ContainerID, req.Path)
+		if err != nil {
+			return nil, err
+		}
+		return fileInfo(req.Path, st.Size, st.Mode, st.Mtime), nil
+	}
+
+	info, err := os.Stat(req.Path)
+	if err != nil {
+		return nil, err
+	}
+	return fileInfo(req.Path, info.Size(), info.Mode(), info.ModTime()), nil
+}
+
+// fileInfo 构造文件信息回复
+func fileInfo(path string, size int64, mode os.FileMode, mtime time.Time) map[string]interface{} {
+	return map[string]interface{}{
+		"path":     path,
+		"size":     size,
+		"mode":     fmt.Sprintf("%04o", mode.Perm()),
+		"is_dir":   mode.IsDir(),
+		"regular":  mode.IsRegular(),
+		"mod_time": mtime,
+	}
+}
+
+// cleanupLoop 定期清理过期的暂存文件和空闲的下载会话
+func (h *Handler) cleanupLoop(ctx context.Context) {
+	ticker := time.NewTicker(10 * time.Minute)
+	defer ticker.Stop()
+
+	for {
+		h.cleanupStaging()
+		h.cleanupDownloads()
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// cleanupStaging 删除长时间未更新的暂存文件
+func (h *Handler) cleanupStaging() {
+	entries, err := os.ReadDir(h.opts.StagingDir)
+	if err != nil {
+		return
+	}
+
+	h.mu.Lock()
+	inUse := make(map[string]bool, len(h.downloads)+2*len(h.uploads))
+	for _, d := range h.downloads {
+		if d.staged != "" {
+			inUse[d.staged] = true
+		}
+	}
+	// 正在处理的上传（如耗时较长的提交）不清理
+	for id := range h.uploads {
+		inUse[h.partPath(id)] = true
+		inUse[h.metaPath(id)] = true
+	}
+	h.mu.Unlock()
+
+	cutoff := time.Now().Add(-staleTransferAge)
+	for _, entry := range entries {
+		info, err := entry.Info()
+		if err != nil || info.ModTime().After(cutoff) {
+			continue
+		}
+		path := filepath.Join(h.opts.StagingDir, entry.Name())
+		if inUse[path] {
+			continue
+		}
+		if err := os.Remove(path); err == nil {
+			zap.L().Sugar().Infof("Removed stale transfer file %s", path)
+		}
+	}
+}
@@ -0,0 +1,372 @@
+package transfer
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+var errDockerUnavailable = errors.New("Docker client not available on this node")
+
+// uploadMeta 上传任务的描述，与分块一起保存在暂存目录，节点重启后仍可继续
+type uploadMeta struct {
+	TransferID  string      `json:"transfer_id"`
+	Path        string      `json:"path"`
+	ContainerID string      `json:"container_id,omitempty"`
+	Size        int64       `json:"size"`
+	SHA256      string      `json:"sha256"`
+	Mode        os.FileMode `json:"mode"`
+	// LoadImage 内容为 docker save 格式的 tar，提交时导入 Docker 而不是写入文件
+	LoadImage bool `json:"load_image,omitempty"`
+}
+
+// uploadLock 单个上传任务的锁，refs 为持有或等待该锁的请求数
+type uploadLock struct {
+	mu   sync.Mutex
+	refs int
+}
+
+// lockUpload 锁定指定传输 ID 的暂存文件，返回的函数用于解锁
+//
+// 提交时重新计算校验和、导入镜像或复制到容器可能耗时很久，只阻塞同一任务的请求。
+func (h *Handler) lockUpload(id string) func() {
+	h.mu.Lock()
+	l, ok := h.uploads[id]
+	if !ok {
+		l = &uploadLock{}
+		h.uploads[id] = l
+	}
+	l.refs++
+	h.mu.Unlock()
+
+	l.mu.Lock()
+	return func() {
+		l.mu.Unlock()
+		h.mu.Lock()
+		l.refs--
+		if l.refs == 0 {
+			delete(h.uploads, id)
+		}
+		h.mu.Unlock()
+	}
+}
+
+// uploadBegin 开始或继续上传，回复已接收的字节数
+func (h *Handler) uploadBegin(ctx context.Context, data []byte) (interface{}, error) {
+	var req struct {
+		TransferID  string `json:"transfer_id"`
+		Path        string `json:"path"`
+		ContainerID string `json:"container_id"`
+		Size        int64  `json:"size"`
+		SHA256      string `json:"sha256"`
+		Mode        string `json:"mode"`
+		MaxSize     int64  `json:"max_size"`
+		LoadImage   bool   `json:"load_image"`
+	}
+	if err := json.Unmarshal(data, &req); err != nil {
+		return nil, fmt.Errorf("invalid request: %w", err)
+	}
+	if !validTransferID(req.TransferID) {
+		return nil, fmt.Errorf("invalid transfer_id")
+	}
+	if req.LoadImage {
+		if h.docker == nil {
+			return nil, errDockerUnavailable
+		}
+		req.Path, req.ContainerID = "", ""
+	} else if err := validatePath(req.Path); err != nil {
+		return nil, err
+	}
+	if len(req.SHA256) != sha256.Size*2 {
+		return nil, fmt.Errorf("invalid sha256")
+	}
+	if req.Size < 0 {
+		return nil, fmt.Errorf("invalid size")
+	}
+	limit := h.maxSize(req.MaxSize)
+	if req.LoadImage {
+		limit = h.maxImageSize()
+	}
+	if req.Size > limit {
+		return nil, fmt.Errorf("file is %d bytes, exceeds limit of %d bytes", req.Size, limit)
+	}
+	if req.ContainerID != "" && h.docker == nil {
+		return nil, errDockerUnavailable
+	}
+
+	mode := os.FileMode(0644)
+	if req.Mode != "" {
+		m, err := strconv.ParseUint(req.Mode, 8, 32)
+		if err != nil || m > 0o7777 {
+			return nil, fmt.Errorf("invalid mode %q", req.Mode)
+		}
+		mode = os.FileMode(m)
+	}
+
+	// 非容器目标在开始前检查写入权限和父目录，避免传完才发现无法安装
+	if req.ContainerID == "" && !req.LoadImage {
+		p, err := h.roots.Check(req.Path)
+		if err != nil {
+			return nil, err
+		}
+		req.Path = p
+		if info, err := os.Stat(filepath.Dir(req.Path)); err != nil || !info.IsDir() {
+			return nil, fmt.Errorf("parent directory of %s does not exist", req.Path)
+		}
+	}
+
+	defer h.lockUpload(req.TransferID)()
+
+	if err := os.MkdirAll(h.opts.StagingDir, 0700); err != nil {
+		return nil, fmt.Errorf("failed to create staging dir: %w", err)
+	}
+
+	meta := uploadMeta{
+		TransferID:  req.TransferID,
+		Path:        req.Path,
+		ContainerID: req.ContainerID,
+		Size:        req.Size,
+		SHA256:      strings.ToLower(req.SHA256),
+		Mode:        mode,
+		LoadImage:   req.LoadImage,
+	}
+
+	// 已有相同任务时从暂存文件的末尾继续，描述不一致时重新开始
+	offset := int64(0)
+	if existing, err := h.loadUploadMeta(req.TransferID); err == nil && *existing == meta {
+		if info, err := os.Stat(h.partPath(req.TransferID)); err == nil && info.Size() <= meta.Size {
+			offset = info.Size()
+		}
+	}
+	if offset == 0 {
+		if err := os.WriteFile(h.partPath(req.TransferID), nil, 0600); err != nil {
+			return nil, fmt.Errorf("failed to create staging file: %w", err)
+		}
+		metaBytes, _ := json.Marshal(meta)
+		if err := os.WriteFile(h.metaPath(req.TransferID), metaBytes, 0600); err != nil {
+			return nil, fmt.Errorf("failed to write transfer metadata: %w", err)
+		}
+	}
+
+	return map[string]interface{}{"transfer_id": req.TransferID, "offset": offset}, nil
+}
+
+// uploadChunk 追加一个分块，分块必须从已接收的位置开始且校验和正确
+func (h *Handler) uploadChunk(ctx context.Context, data []byte) (interface{}, error) {
+	var req struct {
+		TransferID string `json:"transfer_id"`
+		Offset     int64  `json:"offset"`
+		Data       []byte `json:"data"`
+		SHA256     string `json:"sha256"`
+	}
+	if err := json.Unmarshal(data, &req); err != nil {
+		return nil, fmt.Errorf("invalid request: %w", err)
+	}
+	if !validTransferID(req.TransferID) {
+		return nil, fmt.Errorf("invalid transfer_id")
+	}
+	if len(req.Data) > maxChunkSize {
+		return nil, fmt.Errorf("chunk exceeds %d bytes", maxChunkSize)
+	}
+	sum := sha256.Sum256(req.Data)
+	if hex.EncodeToString(sum[:]) != strings.ToLower(req.SHA256) {
+		return nil, fmt.Errorf("chunk checksum mismatch at offset %d", req.Offset)
+	}
+
+	defer h.lockUpload(req.TransferID)()
+
+	meta, err := h.loadUploadMeta(req.TransferID)
+	if err != nil {
+		return nil, fmt.Errorf("unknown transfer %s", req.TransferID)
+	}
+
+	f, err := os.OpenFile(h.partPath(req.TransferID), os.O_WRONLY, 0600)
+	if err != nil {
+		return nil, fmt.Errorf("unknown transfer %s", req.TransferID)
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+	// 重发已写入的分块时直接确认，其他位置不一致时要求控制端重新同步
+	if req.Offset+int64(len(req.Data)) <= info.Size() {
+		return map[string]interface{}{"offset": info.Size()}, nil
+	}
+	if req.Offset != info.Size() {
+		return nil, fmt.Errorf("offset mismatch: expected %d, got %d", info.Size(), req.Offset)
+	}
+	if req.Offset+int64(len(req.Data)) > meta.Size {
+		return nil, fmt.Errorf("chunk exceeds declared file size")
+	}
+
+	if _, err := f.WriteAt(req.Data, req.Offset); err != nil {
+		return nil, fmt.Errorf("failed to write chunk: %w", err)
+	}
+	return map[string]interface{}{"offset": req.Offset + int64(len(req.Data))}, nil
+}
+
+// uploadCommit 校验完整文件并安装到目标路径
+func (h *Handler) uploadCommit(ctx context.Context, data []byte) (interface{}, error) {
+	var req struct {
+		TransferID string `json:"transfer_id"`
+	}
+	if err := json.Unmarshal(data, &req); err != nil {
+		return nil, fmt.Errorf("invalid request: %w", err)
+	}
+	if !validTransferID(req.TransferID) {
+		return nil, fmt.Errorf("invalid transfer_id")
+	}
+
+	defer h.lockUpload(req.TransferID)()
+
+	meta, err := h.loadUploadMeta(req.TransferID)
+	if err != nil {
+		return nil, fmt.Errorf("unknown transfer %s", req.TransferID)
+	}
+
+	part := h.partPath(req.TransferID)
+	f, err := os.Open(part)
+	if err != nil {
+		return nil, fmt.Errorf("unknown transfer %s", req.TransferID)
+	}
+	defer f.Close()
+
+	hash := sha256.New()
+	size, err := io.Copy(hash, f)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read staging file: %w", err)
+	}
+	if size != meta.Size {
+		return nil, fmt.Errorf("incomplete upload: received %d of %d bytes", size, meta.Size)
+	}
+	if hex.EncodeToString(hash.Sum(nil)) != meta.SHA256 {
+		// 内容已损坏，删除后由控制端重新上传
+		h.removeUpload(req.TransferID)
+		return nil, fmt.Errorf("file checksum mismatch, upload discarded")
+	}
+
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+	if meta.LoadImage {
+		loaded, err := h.docker.LoadImage(ctx, f)
+		if err != nil {
+			return nil, err
+		}
+		h.removeUpload(req.TransferID)
+		return map[string]interface{}{
+			"size":   meta.Size,
+			"sha256": meta.SHA256,
+			"loaded": loaded,
+		}, nil
+	}
+	if meta.ContainerID != "" {
+		err = h.docker.CopyFileToContainer(ctx, meta.ContainerID, meta.Path, f, meta.Size, meta.Mode)
+	} else {
+		// 开始上传后目录可能被替换为符号链接，或节点重启后写入根目录已变化，安装前重新检查
+		if _, err := h.roots.Check(meta.Path); err != nil {
+			h.removeUpload(req.TransferID)
+			return nil, err
+		}
+		err = installFile(f, meta.Path, meta.Mode)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	h.removeUpload(req.TransferID)
+	return map[string]interface{}{
+		"path":   meta.Path,
+		"size":   meta.Size,
+		"sha256": meta.SHA256,
+		"mode":   fmt.Sprintf("%04o", meta.Mode),
+	}, nil
+}
+
+// installFile 先写入目标目录中的临时文件再重命名，替换过程中目标文件始终完整
+func installFile(src io.Reader, path string, mode os.FileMode) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".d8r-*")
+	if err != nil {
+		return fmt.Errorf("failed to create temp file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := io.Copy(tmp, src); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	if err := tmp.Chmod(mode); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to set mode on %s: %w", path, err)
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("failed to install %s: %w", path, err)
+	}
+	return nil
+}
+
+// loadUploadMeta 读取上传任务描述
+func (h *Handler) loadUploadMeta(id string) (*uploadMeta, error) {
+	data, err := os.ReadFile(h.metaPath(id))
+	if err != nil {
+		return nil, err
+	}
+	var meta uploadMeta
+	if err := json.Unmarshal(data, &meta); err != nil {
+		return nil, err
+	}
+	return &meta, nil
+}
+
+// removeUpload 删除上传任务的暂存文件
+func (h *Handler) removeUpload(id string) {
+	os.Remove(h.partPath(id))
+	os.Remove(h.metaPath(id))
+}
+
+func (h *Handler) partPath(id string) string {
+	return filepath.Join(h.opts.StagingDir, id+".part")
+}
+
+func (h *Handler) metaPath(id string) string {
+	return filepath.Join(h.opts.StagingDir, id+".json")
+}
+
+// validTransferID 传输 ID 用作暂存文件名，只允许十六进制字符
+func validTransferID(id string) bool {
+	if len(id) < 16 || len(id) > 64 {
+		return false
+	}
+	for _, r := range id {
+		if !(r >= '0' && r <= '9' || r >= 'a' && r <= 'f') {
+			return false
+		}
+	}
+	return true
+}
+
+// validatePath 目标路径必须是绝对路径
+func validatePath(p string) error {
+	if p == "" || !filepath.IsAbs(p) {
+		return fmt.Errorf("path must be absolute: %q", p)
+	}
+	return nil
+}
@@ -0,0 +1,41 @@
+package transfer
+
+import (
+	"testing"
+	"time"
+)
+
+func TestLockUploadIsPerTransfer(t *testing.T) {
+	h := NewHandler(Options{StagingDir: t.TempDir()}, nil, nil, nil)
+
+	unlockA := h.lockUpload("aaaaaaaaaaaaaaaa")
+
+	// 其他任务不被正在提交的任务阻塞
+	done := make(chan struct{})
+	go func() {
+		h.lockUpload("bbbbbbbbbbbbbbbb")()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("lock on another transfer blocked")
+	}
+
+	// 同一任务等待解锁
+	acquired := make(chan func())
+	go func() { acquired <- h.lockUpload("aaaaaaaaaaaaaaaa") }()
+	select {
+	case <-acquired:
+		t.Fatal("same transfer locked twice")
+	case <-time.After(50 * time.Millisecond):
+	}
+	unlockA()
+	(<-acquired)()
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if len(h.uploads) != 0 {
+		t.Errorf("upload locks not released: %v", h.uploads)
+	}
+}